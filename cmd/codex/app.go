@@ -2,23 +2,45 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/approvals"
+	"github.com/epuerta/codex-go/internal/changes"
 	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/contextbudget"
+	"github.com/epuerta/codex-go/internal/draft"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
 	"github.com/epuerta/codex-go/internal/fileops"
 	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/index"
+	"github.com/epuerta/codex-go/internal/instructions"
 	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/epuerta/codex-go/internal/memory"
+	"github.com/epuerta/codex-go/internal/plugins"
+	"github.com/epuerta/codex-go/internal/redact"
+	"github.com/epuerta/codex-go/internal/repocontext"
+	"github.com/epuerta/codex-go/internal/repomap"
 	"github.com/epuerta/codex-go/internal/sandbox"
+	"github.com/epuerta/codex-go/internal/scripts"
+	"github.com/epuerta/codex-go/internal/sessions"
+	"github.com/epuerta/codex-go/internal/slash"
+	"github.com/epuerta/codex-go/internal/stats"
+	"github.com/epuerta/codex-go/internal/truncate"
 	"github.com/epuerta/codex-go/internal/ui"
+	"github.com/epuerta/codex-go/internal/webhook"
+	"github.com/fsnotify/fsnotify"
 	"github.com/google/uuid"
 )
 
@@ -28,26 +50,83 @@ type startAgentStreamMsg struct {
 	content string
 }
 
-type agentResponseMsg struct {
-	item agent.ResponseItem
-}
-
 type agentErrorMsg struct {
 	err error
 }
 
 type agentStreamCompleteMsg struct{}
 
-type agentFollowUpCompleteMsg struct{}
+// Engine events forwarded to the Update loop by the engine bridge
+
+type engineMessageMsg struct {
+	content string // Full assistant message so far
+}
+
+// agentChanMsg carries a message of agentMsgChan to the Update loop
+type agentChanMsg struct {
+	msg tea.Msg
+}
+
+// engineMessageCompleteMsg carries an assistant message once it is complete
+type engineMessageCompleteMsg struct {
+	content string
+}
+
+type engineToolCallMsg struct {
+	call agent.FunctionCall
+}
+
+// engineToolCallPreviewMsg carries a call the agent is still generating
+type engineToolCallPreviewMsg struct {
+	call agent.FunctionCall // Arguments streamed so far
+}
+
+type engineToolResultMsg struct {
+	call agent.FunctionCall
+	res  *executor.Result
+}
+
+type engineToolDeniedMsg struct {
+	call   agent.FunctionCall
+	reason string
+}
 
-// Represents a function result to be sent back to the agent
-type sendFunctionResultMsg struct {
-	ctx          context.Context
-	functionName string // Name from the *original* call
-	callID       string // ID from the *original* call
-	originalArgs string // Arguments JSON from the *original* call
-	output       string // Result content from execution
-	success      bool   // Result status from execution
+// engineContextDistilledMsg reports the end of the explore phase of /explore
+type engineContextDistilledMsg struct {
+	distillation engine.Distillation
+}
+
+// engineBlockedMsg reports a response stopped by a content filter or refused
+type engineBlockedMsg struct {
+	blocked agent.Blocked
+}
+
+// engineRetryMsg reports a response stream that broke off and is retried
+type engineRetryMsg struct {
+	retry agent.StreamRetry
+}
+
+// engineFallbackMsg reports that another model of model_fallbacks answers
+// for the rest of the turn
+type engineFallbackMsg struct {
+	fallback agent.ModelFallback
+}
+
+// engineRedactedMsg reports the secrets redacted from the messages starting
+// a turn
+type engineRedactedMsg struct {
+	findings []redact.Finding
+}
+
+// engineApprovalMsg carries the decision on whether a function call may run
+type engineApprovalMsg struct {
+	event agent.ApprovalEvent
+}
+
+// approvalRequestMsg asks the UI to approve a call; the decision is sent on reply
+type approvalRequestMsg struct {
+	call  agent.FunctionCall
+	reply chan engine.Decision
 }
 
 // UserInputSubmitMsg signals that the user pressed Enter in the chat input
@@ -63,31 +142,87 @@ type App struct {
 	ChatModel        ui.ChatModel // ChatModel is now a sub-model
 	Config           *config.Config
 	FunctionRegistry *functions.Registry
+	Commands         *slash.Registry // Slash commands typed in the chat input
 	IsRunning        bool
 	Sandbox          sandbox.Sandbox
+	Executor         *executor.Executor
+	Engine           *engine.Engine
+	Searcher         *index.Searcher  // Semantic index of the repository; nil if it has none
+	Plugins          *plugins.Manager // User plugins providing tools; nil if there are none
+	Webhook          *webhook.Webhook // Receives the session's events; nil without webhook_url
+	closeTelemetry   func()           // Flushes the session's traces
+	Memory           *memory.Memory   // Conventions saved in earlier sessions
+	Approvals        *approvals.Store // Calls the user chose to always allow in the project
 	Logger           logging.Logger
 
 	// Rollout tracking
 	CurrentRollout *AppRollout
 	RolloutPath    string
 
+	// PatchMetrics tracks patch application and approvals for this session
+	PatchMetrics stats.PatchMetrics
+
 	// We need width/height for layout
 	width  int
 	height int
 
-	agentMsgChan      chan tea.Msg // Channel for agent messages
-	isFirstAgentChunk bool         // Track if we are processing the first chunk of a stream
-	isAgentProcessing bool         // Track if the agent is busy with a request/response cycle
+	agentMsgChan      chan tea.Msg      // Channel for agent messages
+	listening         bool              // A listenForAgentMessages command is waiting on agentMsgChan
+	watcher           *fsnotify.Watcher // Watches the instruction files, if it could be set up
+	reloads           map[string]bool   // Instruction files changed since the last turn
+	done              chan struct{}     // Closed by Close to release engine goroutines
+	isFirstAgentChunk bool              // Track if we are processing the first chunk of a stream
+	isAgentProcessing bool              // Track if the agent is busy with a request/response cycle
 
 	// State for Approval UI
 	isAwaitingApproval  bool
 	approvalModel       ui.ApprovalModel
-	pendingFunctionCall *agent.FunctionCall // Store the function call needing approval
-	pendingApprovalArgs string              // Store the specific args shown in the prompt
+	pendingFunctionCall *agent.FunctionCall  // Store the function call needing approval
+	pendingApprovalArgs string               // Store the specific args shown in the prompt
+	pendingApproval     chan engine.Decision // Receives the decision for the pending call
+
+	// Hunk-by-hunk review of a large patch, shown instead of approvalModel
+	isReviewingPatch bool
+	reviewModel      ui.ReviewModel
+
+	// Question state, while the agent waits for a pick from present_choices
+	isChoosing    bool
+	choiceModel   ui.ChoiceModel
+	pendingChoice chan int // Receives the index of the option picked
+
+	// State for the live preview of a call being generated
+	isPreviewingCall bool
+	previewModel     ui.ApprovalModel
+	previewCall      agent.FunctionCall
+	cancelRun        context.CancelFunc // Cancels the engine run in progress
+	interrupting     bool               // The user stopped the run in progress, which has yet to end
+
+	commandOutput   *commandOutput    // Streams shell command output into the chat
+	pendingCommands map[string]string // Commands awaiting approval, by call ID
+
+	// repoContextSent is set once the repository context is in the history,
+	// or when it is disabled
+	repoContextSent bool
+
+	// Drafts keeps the unsent input across sessions in the repository draftRepo;
+	// nil when the session does not take input
+	Drafts          *draft.Store
+	draftRepo       string
+	quitRequestedAt time.Time // When quitting was last asked to be confirmed
+
+	// The user is told when the session needs them while the terminal is
+	// unfocused
+	unfocused bool
+	bell      io.Writer // The terminal, whose bell is rung
 }
 
 // AppRollout represents a saved session that can be loaded later
 type AppRollout struct {
+	// Version is the rollout format; see rolloutVersion
+	Version int `json:"version,omitempty"`
+	// MigratedFrom is the version the rollout was read as, if it was migrated
+	MigratedFrom int `json:"migrated_from,omitempty"`
+
 	Messages      []agent.Message `json:"messages"`
 	Responses     []agent.Message `json:"responses"`
 	CommandsRun   []string        `json:"commands_run"`
@@ -95,6 +230,42 @@ type AppRollout struct {
 	CreatedAt     time.Time       `json:"created_at"`
 	UpdatedAt     time.Time       `json:"updated_at"`
 	SessionID     string          `json:"session_id"`
+	Title         string          `json:"title,omitempty"`
+	Repo          string          `json:"repo,omitempty"`
+	Model         string          `json:"model,omitempty"`
+
+	// Approvals is the audit trail of decisions on the session's function calls
+	Approvals []agent.ApprovalEvent `json:"approvals,omitempty"`
+
+	// Commands lists the shell commands run, with the environment of each
+	Commands []CommandRecord `json:"commands,omitempty"`
+
+	// Blocked lists the responses stopped by a content filter or refused
+	Blocked []BlockedRecord `json:"blocked,omitempty"`
+
+	// Events is the log of everything that happened in the session, in order:
+	// messages, tool calls and their results, approvals, denials and errors
+	Events []RolloutEvent `json:"events,omitempty"`
+}
+
+// BlockedRecord is a response a content filter stopped or the model refused
+type BlockedRecord struct {
+	agent.Blocked
+	At time.Time `json:"at"`
+}
+
+// CommandRecord is a shell command run during a session, with what is needed
+// to reproduce it
+type CommandRecord struct {
+	CallID      string               `json:"call_id,omitempty"` // Empty for commands the user ran with /run
+	Command     string               `json:"command"`
+	ExitCode    int                  `json:"exit_code"`
+	Success     bool                 `json:"success"`
+	Interrupted bool                 `json:"interrupted,omitempty"`
+	TimedOut    bool                 `json:"timed_out,omitempty"`
+	DurationMs  int64                `json:"duration_ms"`
+	FinishedAt  time.Time            `json:"finished_at"`
+	Environment *sandbox.Environment `json:"environment,omitempty"`
 }
 
 // NewApp creates a new application instance
@@ -106,6 +277,13 @@ func NewApp(config *config.Config, logger logging.Logger) (*App, error) {
 		logger.Log("Failed to initialize agent: %v", err)
 		return nil, fmt.Errorf("failed to initialize agent: %w", err)
 	}
+	return newAppWithAgent(config, logger, a)
+}
+
+// newAppWithAgent creates an application conversing with a, such as a
+// scripted agent in tests
+func newAppWithAgent(config *config.Config, logger logging.Logger, a agent.Agent) (*App, error) {
+	var err error
 
 	// Create chat model (no callback needed here)
 	chatModel := ui.NewChatModel()
@@ -128,16 +306,13 @@ func NewApp(config *config.Config, logger logging.Logger) (*App, error) {
 	// Create function registry
 	registry := functions.NewRegistry()
 
-	// Register core functions
-	registry.Register("read_file", functions.ReadFile)
-	registry.Register("write_file", functions.WriteFile)
-	registry.Register("patch_file", functions.PatchFile)
-	registry.Register("execute_command", functions.ExecuteCommand)
-	registry.Register("list_directory", functions.ListDirectory)
-
 	// Create sandbox
 	sb := sandbox.NewSandbox()
 
+	// Create the executor and the engine driving the tool loop
+	exec := executor.New(config, sb, registry, logger)
+	registerCoreFunctions(registry, exec.Workspace)
+
 	app := &App{
 		Agent:            a,
 		ChatModel:        chatModel,
@@ -145,25 +320,86 @@ func NewApp(config *config.Config, logger logging.Logger) (*App, error) {
 		FunctionRegistry: registry,
 		IsRunning:        false,
 		Sandbox:          sb,
+		Executor:         exec,
+		Engine:           engine.New(a, exec, config, logger),
 		Logger:           logger,
 		agentMsgChan:     make(chan tea.Msg),
+		pendingCommands:  make(map[string]string),
+		done:             make(chan struct{}),
+		bell:             os.Stdout,
 		// Initialize approval state
 		isAwaitingApproval: false,
 	}
 
-	logger.Log("Repository context check: DisableProjectDoc=%t", config.DisableProjectDoc)
-	// Initialize repository context if not disabled
-	if !config.DisableProjectDoc {
-		if err := app.initRepositoryContext(); err != nil {
-			// Log the error but continue
-			logger.Log("Warning: Failed to initialize repository context: %v", err)
-		}
+	// Stream command output into the chat as it is produced
+	app.commandOutput = &commandOutput{app: app}
+	exec.Stdout = app.commandOutput
+	exec.Stderr = app.commandOutput
+	exec.Terminal = app
+	exec.SnapshotEnvironment = true // Recorded in the rollout
+	// Track the files the agent writes, for /diff and the rollout
+	exec.Changes = changes.NewTracker(config.CWD)
+	app.ChatModel.SetCollapseLines(config.UICollapseLines)
+	if len(config.StatusBar) > 0 {
+		app.ChatModel.SetStatusItems(config.StatusBar)
+	}
+	app.refreshGitStatus()
+	if !config.FullStdout {
+		app.ChatModel.SetOutputLimits(truncate.Limits{
+			HeadLines: config.UIOutputHeadLines,
+			TailLines: config.UIOutputTailLines,
+			MaxBytes:  config.OutputMaxBytes,
+		})
 	}
 
+	// Offer the project's scripts to the agent and as /run completions
+	setupProjectScripts(exec, config)
+	setupInstructions(exec, config)
+	app.Commands = app.newCommands()
+	app.ChatModel.SetSuggestions(commandSuggestions(app.Commands))
+	app.ChatModel.SetFileLister(app.repositoryFiles)
+	app.Searcher = setupSemanticIndex(registry, config)
+	setupNetworkTools(registry, config)
+	registry.RegisterTool(functions.ChoicesTool(functions.ChooserFunc(app.choose)))
+	app.Memory = setupMemory(registry, config)
+	if app.Approvals, err = setupApprovals(config); err != nil {
+		app.ChatModel.SetNotice(fmt.Sprintf("Always allow disabled: %v", err))
+	} else {
+		app.Engine.AllowList = app.Approvals
+	}
+	var pluginWarnings int
+	if app.Plugins, pluginWarnings = setupPlugins(registry, app.Engine, config); pluginWarnings > 0 {
+		app.ChatModel.SetNotice(pluginWarningNotice(pluginWarnings))
+	}
+	if app.Webhook, err = setupWebhook(app.Engine, config, sessionID); err != nil {
+		app.ChatModel.SetNotice(fmt.Sprintf("Webhook disabled: %v", err))
+	}
+	if app.closeTelemetry, err = setupTelemetry(config); err != nil {
+		app.ChatModel.SetNotice(fmt.Sprintf("Telemetry disabled: %v", err))
+	}
+	if setter, ok := a.(toolSourceSetter); ok {
+		setter.SetToolSource(registry)
+	}
+	app.ChatModel.SetToolsInfo(app.toolsInfo())
+	logger.Log("Tools: %s", toolsOrigins(offeredTools(registry)))
+	app.watcher = app.watchInstructions()
+
+	// The repository context goes with the first message, so that it can be
+	// fitted to what the user asks
+	logger.Log("Repository context check: DisableProjectDoc=%t", config.DisableProjectDoc)
+	app.repoContextSent = config.DisableProjectDoc
+
 	logger.Log("App initialized successfully.")
 	return app, nil
 }
 
+// registerCoreFunctions registers the core functions, with file access
+// confined to ws
+func registerCoreFunctions(registry *functions.Registry, ws *fileops.Workspace) {
+	functions.FileFunctions{Workspace: ws}.Register(registry)
+	functions.RegisterShell(registry)
+}
+
 // Init initializes the application model
 func (app *App) Init() tea.Cmd {
 	app.Logger.Log("App.Init called")
@@ -174,10 +410,20 @@ func (app *App) Init() tea.Cmd {
 // listenForAgentMessages returns a command that continuously listens on the
 // agent message channel and sends received messages back to the App's Update loop.
 func (app *App) listenForAgentMessages() tea.Cmd {
+	// A second listener could hand over the messages out of order
+	if app.listening {
+		return nil
+	}
+	app.listening = true
 	return func() tea.Msg {
-		msg := <-app.agentMsgChan // Block and wait for the next message
-		app.Logger.Log("listenForAgentMessages: Received %T from channel, returning to Update.", msg)
-		return msg
+		// Block and wait for the next message, until the app is closed
+		select {
+		case msg := <-app.agentMsgChan:
+			app.Logger.Log("listenForAgentMessages: Received %T from channel, returning to Update.", msg)
+			return agentChanMsg{msg: msg}
+		case <-app.done:
+			return nil
+		}
 	}
 }
 
@@ -188,8 +434,23 @@ func (app *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var agentMessageHandled bool = false
 	var skipChatModelUpdate bool = false
 
+	if m, ok := msg.(agentChanMsg); ok {
+		app.listening = false // Handling the message listens again
+		msg = m.msg
+	}
+
 	app.Logger.Log("App.Update received msg type: %T, isAwaitingApproval: %t", msg, app.isAwaitingApproval)
 
+	// Focus changes are tracked whatever is shown
+	switch msg.(type) {
+	case tea.FocusMsg:
+		app.unfocused = false
+		return app, nil
+	case tea.BlurMsg:
+		app.unfocused = true
+		return app, nil
+	}
+
 	// *** Approval UI Handling ***
 	if app.isAwaitingApproval {
 		switch approvalMsg := msg.(type) {
@@ -197,167 +458,41 @@ func (app *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			app.Logger.Log("Received ApprovalResultMsg: Approved=%t", approvalMsg.Approved)
 			app.isAwaitingApproval = false // Exit approval mode
 
-			app.ChatModel.SetThinkingStatus("Processing function result...")
-
-			var agentOutput string
-			var success bool
 			functionName := app.pendingFunctionCall.Name
-			handlerExecuted := false // Flag to prevent fallthrough
-
+			if functionName == "patch_file" {
+				app.PatchMetrics.RecordDecision(approvalMsg.Approved)
+			}
 			if approvalMsg.Approved {
 				app.Logger.Log("Approval granted for %s. Executing...", functionName)
-
-				// *** Execute the approved function ***
-				if functionName == "execute_command" {
-					handlerExecuted = true // Mark as handled
-					cmdStr := app.pendingApprovalArgs
-					app.Logger.Log("Executing approved command via sandbox: %s", cmdStr)
-					result, err := app.Sandbox.Execute(context.Background(), sandbox.SandboxOptions{Command: cmdStr, WorkingDir: app.Config.CWD, Timeout: 30 * time.Second})
-					uiResult := &ui.CommandResult{Command: cmdStr, Stdout: result.Stdout, Stderr: result.Stderr, ExitCode: result.ExitCode, Duration: result.Duration, Error: err}
-					app.ChatModel.AddCommandMessage(cmdStr, uiResult)
-					app.ChatModel.ForceUpdateViewport()
-					agentOutput = result.Stdout
-					success = err == nil && result.ExitCode == 0
-					if !success {
-						if err != nil {
-							agentOutput = fmt.Sprintf("Execution Error: %v", err)
-						} else {
-							agentOutput = fmt.Sprintf("Command Failed (code %d): %s", result.ExitCode, result.Stderr)
-						}
-					}
-					app.Logger.Log("Executed command. Agent output: %s, Success: %t", agentOutput, success)
-
-				} else if functionName == "patch_file" {
-					handlerExecuted = true // Mark as handled
-					patchContent := app.pendingApprovalArgs
-					app.Logger.Log("Executing approved patch. Content length: %d", len(patchContent))
-					app.ChatModel.SetThinkingStatus("Applying patch...")
-					app.Logger.Log("Calling fileops.ParseAgentPatch...")
-					operations, parseErr := fileops.ParseAgentPatch(patchContent)
-					if parseErr != nil {
-						app.Logger.Log("ERROR: Failed to parse agent patch: %v", parseErr)
-						agentOutput = fmt.Sprintf("Error parsing patch: %v", parseErr)
-						success = false
-						patchResMsg := &fileops.AgentPatchResult{
-							Success: false,
-							Error:   parseErr,
-							Diff:    "Patch parsing failed",
-						}
-						app.Logger.Log("Adding patch parse error message to UI: %+v", *patchResMsg)
-						app.ChatModel.AddAgentPatchResultMessage(patchResMsg)
-						app.Logger.Log("Calling ForceUpdateViewport after adding parse error.")
-						app.ChatModel.ForceUpdateViewport()
-						app.Logger.Log("ForceUpdateViewport completed after adding parse error.")
-					} else {
-						app.Logger.Log("Parsed %d operations from patch. Calling fileops.ApplyAgentPatch...", len(operations))
-						applyResults, applyErr := fileops.ApplyAgentPatch(operations)
-						app.Logger.Log("ApplyAgentPatch finished. Results count: %d, Overall error: %v", len(applyResults), applyErr)
-
-						successCount, failureCount := 0, 0
-						app.Logger.Log("Adding patch results to UI...")
-						for i, res := range applyResults {
-							app.Logger.Log("Processing applyResult %d: Success=%t, Path=%s, Diff=%s, Error=%v", i+1, res.Success, res.Path, res.Diff, res.Error)
-							if res.Success {
-								successCount++
-								// --- Start: Auto-format successful patch ---
-								formatCmdStr := getFormatterCommand(res.Path)
-								if formatCmdStr != "" {
-									app.Logger.Log("Attempting to auto-format successfully patched file: %s with command: %s", res.Path, formatCmdStr)
-									formatCtx, formatCancel := context.WithTimeout(context.Background(), 15*time.Second)
-									formatResult, formatErr := app.Sandbox.Execute(formatCtx, sandbox.SandboxOptions{
-										Command:    formatCmdStr,
-										WorkingDir: app.Config.CWD,
-									})
-									formatCancel()
-									if formatErr != nil || formatResult.ExitCode != 0 {
-										formatErrMsg := fmt.Sprintf("Auto-formatting failed for %s.", res.Path)
-										if formatErr != nil {
-											formatErrMsg = fmt.Sprintf("%s Error: %v", formatErrMsg, formatErr)
-										} else {
-											formatErrMsg = fmt.Sprintf("%s Exit Code: %d, Stderr: %s", formatErrMsg, formatResult.ExitCode, formatResult.Stderr)
-										}
-										app.Logger.Log("ERROR: %s", formatErrMsg)
-										app.ChatModel.AddSystemMessage(formatErrMsg)
-									} else {
-										app.Logger.Log("Successfully auto-formatted %s.", res.Path)
-									}
-								} else {
-									app.Logger.Log("No formatter identified for file extension of %s, skipping auto-format.", res.Path)
-								}
-								// --- End: Auto-format successful patch ---
-							} else {
-								failureCount++
-							}
-							app.ChatModel.AddAgentPatchResultMessage(res)
-							app.ChatModel.ForceUpdateViewport()
-						}
-						app.Logger.Log("Finished adding %d patch result messages to ChatModel.", len(applyResults))
-						app.Logger.Log("Finished processing patch apply results.")
-
-						if applyErr != nil {
-							agentOutput = fmt.Sprintf("Patch application finished with errors. Succeeded: %d, Failed: %d. First error: %v", successCount, failureCount, applyErr)
-							success = false
-						} else if failureCount > 0 {
-							agentOutput = fmt.Sprintf("Patch application finished. Succeeded: %d, Failed: %d.", successCount, failureCount)
-							success = false
-						} else {
-							agentOutput = fmt.Sprintf("Patch application finished successfully. Operations applied: %d.", successCount)
-							success = true
-						}
-						app.Logger.Log("Patch application summary for agent: %s", agentOutput)
-					}
-				}
-
-				// *** Generic Handler for other approved functions (if not handled above) ***
-				if !handlerExecuted {
-					fn := app.FunctionRegistry.Get(functionName)
-					if fn != nil {
-						app.Logger.Log("Executing approved registered function: %s", functionName)
-						app.ChatModel.SetThinkingStatus(fmt.Sprintf("Executing: %s", functionName))
-						result, err := fn(app.pendingFunctionCall.Arguments)
-						app.Logger.Log("Approved Function '%s' execution result: ResultLen=%d, Error=%v", functionName, len(result), err)
-						success = err == nil
-						agentOutput = result
-						if err != nil {
-							agentOutput = fmt.Sprintf("Error: %v", err)
-							app.ChatModel.AddSystemMessage(agentOutput)
-						}
-						app.ChatModel.AddFunctionResultMessage(agentOutput, !success)
-						app.ChatModel.ForceUpdateViewport()
-					} else {
-						app.Logger.Log("ERROR: Approved function %s not found in registry!", functionName)
-						agentOutput = fmt.Sprintf("Internal error: approved function %s not found", functionName)
-						success = false
-					}
-				}
-			} else { // Denied
-				agentOutput = fmt.Sprintf("Operation '%s' denied by user.", functionName)
-				success = false
+				app.ChatModel.SetThinkingStatus(fmt.Sprintf("Executing: %s", functionName))
+			} else {
 				app.Logger.Log("Approval denied for %s.", functionName)
-				app.ChatModel.AddSystemMessage(agentOutput)
-				app.ChatModel.ForceUpdateViewport()
+				app.ChatModel.SetThinkingStatus("Processing function result...")
 			}
 
-			// --- Send result back to agent ---
-			resultMsg := sendFunctionResultMsg{
-				ctx:          context.Background(),
-				functionName: app.pendingFunctionCall.Name,
-				callID:       app.pendingFunctionCall.ID,
-				originalArgs: app.pendingFunctionCall.Arguments,
-				output:       agentOutput,
-				success:      success,
+			if approvalMsg.Approved && approvalMsg.Always {
+				app.rememberApproval(*app.pendingFunctionCall)
 			}
-			app.Logger.Log("App.Update (ApprovalResultMsg): Starting goroutine to send sendFunctionResultMsg for %s.", resultMsg.functionName)
-			go func() {
-				time.Sleep(50 * time.Millisecond)
-				app.agentMsgChan <- resultMsg
-			}()
+
+			// The engine is blocked waiting for the decision; the reply channel is buffered
+			app.pendingApproval <- engine.Decision{Approved: approvalMsg.Approved, Call: *app.pendingFunctionCall}
+			app.pendingApproval = nil
 			app.pendingFunctionCall = nil
 			app.pendingApprovalArgs = ""
 
 			skipChatModelUpdate = true
 
+		case ui.ReviewResultMsg:
+			app.finishReview(approvalMsg)
+			skipChatModelUpdate = true
+
 		case tea.KeyMsg, tea.MouseMsg: // Pass other messages to approval model
+			if app.isReviewingPatch {
+				app.reviewModel, cmd = app.reviewModel.Update(msg)
+				cmds = append(cmds, cmd)
+				skipChatModelUpdate = true
+				break
+			}
 			app.Logger.Log("Passing msg %T to ApprovalModel", msg)
 			var updatedApprovalModel ui.ApprovalModel
 			updatedApprovalModel, cmd = app.approvalModel.Update(msg)
@@ -366,14 +501,48 @@ func (app *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			skipChatModelUpdate = true
 
 		default:
-			app.Logger.Log("Ignoring msg %T while awaiting approval", msg)
-			skipChatModelUpdate = true
+			if app.isReviewingPatch {
+				// The review handles the return from the editor
+				app.reviewModel, cmd = app.reviewModel.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+			// The chat keeps its size and thinking time while hidden
+			_, cmd = app.ChatModel.Update(msg)
+			cmds = append(cmds, cmd)
 		}
 		// Return early to avoid processing other cases while approval is active
+		cmds = append(cmds, app.listenForAgentMessages())
 		return app, tea.Batch(cmds...)
 	}
 	// *** End Approval UI Handling ***
 
+	// Keys answer the agent's question while it is shown
+	if app.isChoosing {
+		switch msg.(type) {
+		case ui.ChoiceResultMsg, tea.KeyMsg:
+			return app, app.updateChoice(msg)
+		}
+	}
+
+	// *** Call Preview Handling ***
+	// Keys scroll the preview, and Esc stops the generation instead of quitting
+	if app.isPreviewingCall {
+		switch previewMsg := msg.(type) {
+		case tea.KeyMsg:
+			if previewMsg.Type == tea.KeyEsc {
+				app.stopGeneration()
+				return app, nil
+			}
+			if previewMsg.Type != tea.KeyCtrlC {
+				app.previewModel, cmd = app.previewModel.Update(msg)
+				return app, cmd
+			}
+		case tea.MouseMsg:
+			app.previewModel, cmd = app.previewModel.Update(msg)
+			return app, cmd
+		}
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		app.Logger.Log("Received WindowSizeMsg: Width=%d, Height=%d", msg.Width, msg.Height)
@@ -383,113 +552,327 @@ func (app *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if app.isAwaitingApproval {
 			app.approvalModel.SetSize(msg.Width, msg.Height)
 		}
+		if app.isChoosing {
+			app.choiceModel.SetSize(msg.Width, msg.Height)
+		}
 
 	case tea.KeyMsg:
 		app.Logger.Log("Received KeyMsg: Type=%v, Rune=%q, Alt=%t", msg.Type, msg.Runes, msg.Alt)
-		if msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEsc || (msg.String() == "q" && app.ChatModel.InputIsEmpty()) {
+		if msg.Type == tea.KeyCtrlC && app.Executor.InterruptCommand() {
+			// Ctrl+C stops a running command rather than the app
+			app.Logger.Log("Ctrl+C: interrupting the running command")
+			app.ChatModel.SetThinkingStatus("Stopping the command...")
+			return app, nil
+		}
+		// Esc and q belong to a search of the input history or the
+		// conversation while one is on
+		searching := app.ChatModel.SearchingHistory() || app.ChatModel.SearchingChat()
+		if msg.Type == tea.KeyEsc && !searching && app.isAgentProcessing {
+			// Esc stops the turn rather than the app
+			app.interruptTurn()
+			return app, nil
+		}
+		if msg.Type == tea.KeyCtrlC || (!searching && (msg.Type == tea.KeyEsc || (msg.String() == "q" && app.ChatModel.InputIsEmpty()))) {
+			if ok, confirmCmd := app.confirmQuit(msg.String()); !ok {
+				app.Logger.Log("Quit key detected; waiting for confirmation.")
+				return app, confirmCmd
+			}
 			app.Logger.Log("Quit key detected. Shutting down.")
+			if app.Plugins != nil {
+				app.Logger.Log("App.Close: Stopping plugins...")
+				if err := app.Plugins.Close(); err != nil {
+					app.Logger.Log("App.Close: Error stopping plugins: %v", err)
+				}
+			}
+
+			app.saveDraft()
 			app.Agent.Cancel() // Cancel any pending agent work
 			app.IsRunning = false
 			return app, tea.Quit
 		}
+		app.cancelQuit()
+		if msg.Type == tea.KeyCtrlV {
+			app.Logger.Log("Ctrl+V: reading image from clipboard")
+			cmds = append(cmds, attachClipboardCmd(true))
+			skipChatModelUpdate = true
+		} else if msg.Paste {
+			// A file dragged into the terminal arrives as a pasted path
+			if path := ui.PathFromPaste(string(msg.Runes)); path != "" {
+				app.Logger.Log("Pasted file path: %s", path)
+				cmds = append(cmds, attachFileCmd(path))
+				skipChatModelUpdate = true
+			}
+		}
+
+	case quitConfirmExpiredMsg:
+		if msg.at.Equal(app.quitRequestedAt) {
+			app.cancelQuit()
+		}
+		skipChatModelUpdate = true
+
+	case attachmentLoadedMsg:
+		if msg.pasteText && msg.err != nil {
+			// No usable image; let the chat input paste the clipboard text
+			app.Logger.Log("Ctrl+V: no clipboard image (%v), pasting text", msg.err)
+			_, cmd = app.ChatModel.Update(tea.KeyMsg{Type: tea.KeyCtrlV})
+			cmds = append(cmds, cmd)
+		} else if msg.err != nil {
+			app.Logger.Log("Failed to attach file: %v", msg.err)
+			app.ChatModel.AddNotice(fmt.Sprintf("Could not attach file: %v", msg.err))
+		} else if err := app.ChatModel.AddAttachment(msg.attachment); err != nil {
+			app.Logger.Log("Failed to attach %s: %v", msg.attachment.Name, err)
+			app.ChatModel.AddNotice(fmt.Sprintf("Could not attach %s: %v", msg.attachment.Name, err))
+		} else {
+			app.Logger.Log("Attached %s", msg.attachment.Label())
+		}
+		skipChatModelUpdate = true
+
+	case compactDoneMsg:
+		app.finishCompact(msg)
+		skipChatModelUpdate = true
+
+	case reviewDoneMsg:
+		app.finishCodeReview(msg)
+		skipChatModelUpdate = true
+
+	case promptExpandedMsg:
+		cmds = append(cmds, app.finishPrompt(msg))
+		skipChatModelUpdate = true
+
+	case scriptResultMsg:
+		app.Logger.Log("Project script %s finished. Success: %t", msg.name, msg.result.Success)
+		if msg.result.Command == "" {
+			// The script was not found or its arguments were refused
+			app.ChatModel.RemoveCommandMessage()
+			app.ChatModel.AddNotice(msg.result.Output)
+		} else {
+			app.renderExecutionResult(scripts.ToolName, msg.result)
+			app.recordCommand("", msg.result)
+		}
+		skipChatModelUpdate = true
 
 	case ui.UserInputSubmitMsg:
 		if strings.HasPrefix(msg.Content, "/") {
-			command := strings.TrimSpace(msg.Content)
-			if command == "/clear" {
-				app.Logger.Log("User command: /clear")
-				app.Agent.ClearHistory()
-				app.ChatModel.ClearMessages()
-				app.ChatModel.AddSystemMessage("Chat history cleared.")
-				skipChatModelUpdate = true
-				cmd = nil
-			} else if command == "/help" {
-				app.Logger.Log("User command: /help")
-				helpText := `Codex-Go Help:
-  /clear : Clears the current conversation history.
-  /help  : Shows this help message.
-  Ctrl+C : Quits the application.
-  Enter  : Sends your message to the assistant.`
-				app.ChatModel.AddSystemMessage(helpText)
-				skipChatModelUpdate = true
-				cmd = nil
-			} else {
-				app.Logger.Log("User command: Unknown command: %s", command)
-				app.ChatModel.AddSystemMessage(fmt.Sprintf("Unknown command: %s", command))
-				skipChatModelUpdate = true
-				cmd = nil
-			}
+			cmds = append(cmds, app.runCommand(msg.Content))
+			skipChatModelUpdate = true
 		} else {
 			if app.isAgentProcessing {
 				app.Logger.Log("WARN: User submitted input while agent is processing. Ignoring.")
 				skipChatModelUpdate = true
 				cmd = nil
 			} else {
-				app.Logger.Log("User submitted input. Starting agent stream: %q", msg.Content)
-				app.ChatModel.AddUserMessage(msg.Content)
+				app.attachFileRefs(msg.Content)
+				attached := app.ChatModel.TakeAttachments()
+				app.Logger.Log("User submitted input with %d attachment(s). Starting agent stream: %q", len(attached), msg.Content)
+				userMsg := userMessage(msg.Content, attached)
+				if r := app.rollout(); r.Title == "" {
+					r.Title = sessions.Title(msg.Content)
+				}
+				app.applyReloads()
+				app.ChatModel.AddUserMessageWithAttachments(msg.Content, attachmentLabels(attached))
+				app.recordEvent(RolloutEvent{Type: eventUserMessage, Content: msg.Content, Attachments: attachmentLabels(attached)})
 				app.ChatModel.StartThinking()
 				app.isFirstAgentChunk = true
 				app.isAgentProcessing = true
-				cmd = app.listenAgentStreamCmd(msg.Content)
+				cmd = app.listenAgentStreamCmd(userMsg)
 				skipChatModelUpdate = true
 			}
 		}
 
-	case agentResponseMsg:
-		app.Logger.Log("Received agentResponseMsg")
-		app.handleAgentResponseItem(msg.item)
-		// Refocus input *unless* we are now awaiting approval
-		if !app.isAwaitingApproval {
-			cmds = append(cmds, textinput.Blink)
+	case engineMessageMsg:
+		app.handleAgentMessage(msg.content)
+		cmds = append(cmds, app.listenForAgentMessages(), textinput.Blink)
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineMessageCompleteMsg:
+		app.recordEvent(RolloutEvent{Type: eventAssistantMessage, Content: msg.content})
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineToolCallPreviewMsg:
+		app.previewToolCall(msg.call)
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineToolCallMsg:
+		app.Logger.Log("Received engineToolCallMsg. Name: %s, ID: %s, Full Args JSON: %s", msg.call.Name, msg.call.ID, msg.call.Arguments)
+		app.endPreview()
+		app.ChatModel.SetThinkingStatus(fmt.Sprintf("Evaluating %s...", msg.call.Name))
+		app.ChatModel.AddFunctionCallMessage(msg.call.Name, msg.call.Arguments)
+		app.recordEvent(RolloutEvent{Type: eventToolCall, CallID: msg.call.ID, Tool: msg.call.Name, Arguments: msg.call.Arguments})
+		if executor.IsCommandFunction(msg.call.Name) || msg.call.Name == scripts.ToolName {
+			app.pendingCommands[msg.call.ID] = executor.ApprovalArgs(msg.call)
 		}
-		cmds = append(cmds, app.listenForAgentMessages()) // Ensure we continue listening
+		cmds = append(cmds, app.listenForAgentMessages())
 		agentMessageHandled = true
 		skipChatModelUpdate = true
 
-	case agentErrorMsg:
-		app.Logger.Log("ERROR: Received agentErrorMsg: %v", msg.err)
-		app.ChatModel.AddSystemMessage(fmt.Sprintf("Error: %v", msg.err))
-		app.ChatModel.StopThinking()
-		app.isFirstAgentChunk = false
-		app.isAgentProcessing = false
-		cmds = append(cmds, app.listenForAgentMessages(), textinput.Blink)
+	case choiceRequestMsg:
+		app.showChoices(msg)
+		cmds = append(cmds, app.listenForAgentMessages(), app.notifyInputNeeded("The assistant has a question"))
 		agentMessageHandled = true
 		skipChatModelUpdate = true
 
-	case agentStreamCompleteMsg:
-		app.Logger.Log("Received agentStreamCompleteMsg (no tool calls)")
+	case terminalRequestMsg:
+		cmds = append(cmds, app.lendTerminal(msg.loan), app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case instructionsChangedMsg:
+		if app.reloads == nil {
+			app.reloads = make(map[string]bool)
+		}
+		app.reloads[msg.path] = true
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case terminalReturnedMsg:
+		app.terminalReturned(msg)
+		agentMessageHandled = true // The listener is still waiting
+		skipChatModelUpdate = true
+
+	case approvalRequestMsg:
+		app.Logger.Log("Received approvalRequestMsg for %s", msg.call.Name)
+		cmds = append(cmds, app.listenForAgentMessages())
+		if app.requestApproval(msg.call, msg.reply) {
+			cmds = append(cmds, app.notifyInputNeeded(fmt.Sprintf("Approve %s?", msg.call.Name)))
+		}
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineToolResultMsg:
+		app.Logger.Log("Received engineToolResultMsg for %s. Success: %t", msg.call.Name, msg.res.Success)
+		app.renderExecutionResult(msg.call.Name, msg.res)
+		app.recordCommand(msg.call.ID, msg.res)
+		rollout := app.rollout()
+		rollout.addEvent(rollout.toolResultEvent(msg.call, msg.res, time.Now()))
+		if msg.res.PatchResults != nil || msg.res.PatchParseErr != nil {
+			app.PatchMetrics.RecordApply(msg.res.PatchResults, msg.res.PatchParseErr)
+		}
+		app.awaitFollowUp()
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineContextDistilledMsg:
+		d := msg.distillation
+		app.Logger.Log("Received engineContextDistilledMsg. Calls: %d, messages: %d, tokens: %d -> %d", d.Calls, d.Messages, d.TokensBefore, d.TokensAfter)
+		app.ChatModel.AddNotice(distillationSummary(d))
+		app.isFirstAgentChunk = true // The task's answer follows the summary
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineBlockedMsg:
+		app.Logger.Log("Received engineBlockedMsg: %s (finish reason %q)", msg.blocked.Reason, msg.blocked.FinishReason)
+		app.endPreview()
+		rollout := app.rollout()
+		rollout.Blocked = append(rollout.Blocked, BlockedRecord{Blocked: msg.blocked, At: time.Now()})
+		app.recordEvent(RolloutEvent{Type: eventBlocked, Blocked: &msg.blocked})
+		app.ChatModel.AddNotice(msg.blocked.Explain())
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineRetryMsg:
+		app.Logger.Log("Received engineRetryMsg: attempt %d/%d: %s", msg.retry.Attempt, msg.retry.MaxAttempts, msg.retry.Error)
+		app.endPreview()
+		app.ChatModel.AddNotice(msg.retry.Explain())
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineFallbackMsg:
+		app.Logger.Log("Received engineFallbackMsg: %s -> %s: %s", msg.fallback.From, msg.fallback.To, msg.fallback.Error)
+		app.ChatModel.AddNotice(msg.fallback.Explain())
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineRedactedMsg:
+		app.ChatModel.AddNotice(fmt.Sprintf("Warning: your message contained what look like secrets (%s). They were replaced with placeholders before it was sent to the assistant.", redact.Summary(msg.findings)))
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineApprovalMsg:
+		app.recordApproval(msg.event)
+		if command, ok := app.pendingCommands[msg.event.CallID]; ok {
+			delete(app.pendingCommands, msg.event.CallID)
+			if msg.event.Approved() {
+				app.startCommandMessage(command)
+			}
+		}
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case engineToolDeniedMsg:
+		// The approval annotation recorded just before already shows the denial
+		app.Logger.Log("Received engineToolDeniedMsg for %s: %s", msg.call.Name, msg.reason)
+		app.recordEvent(RolloutEvent{Type: eventToolDenied, CallID: msg.call.ID, Tool: msg.call.Name, Reason: msg.reason})
+		app.awaitFollowUp()
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case commandOutputMsg:
+		if output := app.commandOutput.take(); output != "" {
+			app.ChatModel.AppendCommandOutput(output)
+		}
+		cmds = append(cmds, app.listenForAgentMessages())
+		agentMessageHandled = true
+		skipChatModelUpdate = true
+
+	case agentErrorMsg:
+		app.Logger.Log("ERROR: Received agentErrorMsg: %v", msg.err)
+		if app.interrupting && errors.Is(msg.err, context.Canceled) {
+			// The engine noted the interruption in the history
+			app.recordEvent(RolloutEvent{Type: eventInterrupted})
+		} else {
+			app.recordEvent(RolloutEvent{Type: eventError, Content: msg.err.Error()})
+		}
+		app.interrupting = false
+		app.endPreview()
+		if !errors.Is(msg.err, context.Canceled) { // Cancelled by the user, already reported
+			app.ChatModel.AddNotice(fmt.Sprintf("Error: %v", msg.err))
+		}
+		if app.ChatModel.ThinkingTime() >= longTurn {
+			cmds = append(cmds, app.notifyInputNeeded("The assistant stopped with an error"))
+		}
 		app.ChatModel.StopThinking()
 		app.isFirstAgentChunk = false
 		app.isAgentProcessing = false
+		// The turn may have changed files or the branch
+		app.refreshGitStatus()
 		cmds = append(cmds, app.listenForAgentMessages(), textinput.Blink)
 		agentMessageHandled = true
 		skipChatModelUpdate = true
 
-	case agentFollowUpCompleteMsg:
-		app.Logger.Log("Received agentFollowUpCompleteMsg")
+	case agentStreamCompleteMsg:
+		app.Logger.Log("Received agentStreamCompleteMsg")
+		app.interrupting = false // The run ended before it could be stopped
+		app.endPreview()
+		if app.ChatModel.ThinkingTime() >= longTurn {
+			cmds = append(cmds, app.notifyInputNeeded("The assistant is done"))
+		}
 		app.ChatModel.StopThinking()
 		app.isFirstAgentChunk = false
 		app.isAgentProcessing = false
+		// The turn may have changed files or the branch
+		app.refreshGitStatus()
 		cmds = append(cmds, app.listenForAgentMessages(), textinput.Blink)
 		agentMessageHandled = true
 		skipChatModelUpdate = true
-
-	case sendFunctionResultMsg:
-		app.Logger.Log("Received sendFunctionResultMsg for %s", msg.functionName)
-		app.sendFunctionResultCmd(msg)
-		cmds = append(cmds, app.listenForAgentMessages())
-		agentMessageHandled = true
-		skipChatModelUpdate = true
-
 	}
 
 	if !skipChatModelUpdate {
 		app.Logger.Log("Passing message %T to ChatModel.Update", msg)
-		var updatedChatModel tea.Model
-		updatedChatModel, cmd = app.ChatModel.Update(msg)
-		if updatedChatModelTyped, ok := updatedChatModel.(ui.ChatModel); ok {
-			app.ChatModel = updatedChatModelTyped
-		}
+		_, cmd = app.ChatModel.Update(msg)
 		cmds = append(cmds, cmd)
 	} else if !agentMessageHandled && len(cmds) == 0 {
 		// If we skipped chat model update AND no other command was generated AND it wasn't an agent message we handled,
@@ -516,396 +899,329 @@ func (app *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // View renders the application UI
 func (app *App) View() string {
-	if app.isAwaitingApproval {
+	if app.isReviewingPatch {
+		app.reviewModel.SetSize(app.width, app.height)
+		return app.reviewModel.View()
+	} else if app.isAwaitingApproval {
 		// Ensure the approval model has the correct size based on current terminal dimensions
 		app.approvalModel.SetSize(app.width, app.height)
 		// Render the approval UI (it handles its own centering via lipgloss.Place)
 		approvalView := app.approvalModel.View()
 		return approvalView
+	} else if app.isChoosing {
+		return app.choiceModel.View()
+	} else if app.isPreviewingCall {
+		app.previewModel.SetSize(app.width, app.height)
+		return app.previewModel.View()
 	} else {
 		return app.ChatModel.View()
 	}
 }
 
-// listenAgentStreamCmd starts the agent stream goroutine which sends messages to app.agentMsgChan
-func (app *App) listenAgentStreamCmd(content string) tea.Cmd {
-	app.Logger.Log("listenAgentStreamCmd: Starting agent stream goroutine for content: %q", content)
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		defer cancel()
+// listenAgentStreamCmd runs the engine for the user's message in a goroutine.
+// Engine events reach the Update loop through app.agentMsgChan.
+func (app *App) listenAgentStreamCmd(msg agent.Message) tea.Cmd {
+	app.Logger.Log("listenAgentStreamCmd: Starting engine goroutine for content: %q", msg.Content)
+	withRepoContext := app.takeRepoContext()
+	return app.runEngineCmd(5*time.Minute, func(ctx context.Context, bridge *engineBridge) (*engine.Outcome, error) {
+		var messages []agent.Message
+		if withRepoContext {
+			messages = append(messages, app.repositoryContext(msg.Content)...)
+		}
+		if extra, ok := semanticContext(ctx, app.Searcher, app.Config, msg.Content); ok {
+			messages = append(messages, extra)
+		}
+		return app.Engine.RunMessages(ctx, append(messages, msg), bridge, bridge)
+	})
+}
 
-		message := agent.Message{Role: "user", Content: content}
+// exploreStreamCmd runs task with a read-only explore phase first (/explore).
+// It gets more time than a plain message, since it makes two runs.
+func (app *App) exploreStreamCmd(task string) tea.Cmd {
+	app.Logger.Log("exploreStreamCmd: Starting engine goroutine for task: %q", task)
+	withRepoContext := app.takeRepoContext()
+	return app.runEngineCmd(10*time.Minute, func(ctx context.Context, bridge *engineBridge) (*engine.Outcome, error) {
+		opts := engine.ExploreOptions{MaxCalls: app.Config.ExploreCalls}
+		if withRepoContext {
+			opts.Context = app.repositoryContext(task)
+		}
+		return app.Engine.RunExplore(ctx, task, opts, bridge, bridge)
+	})
+}
 
-		app.Logger.Log("listenAgentStreamCmd: Goroutine started. Calling Agent.SendMessage...")
-		streamEndedWithTools, err := app.Agent.SendMessage(ctx, []agent.Message{message}, func(itemJSON string) {
-			app.Logger.Log("listenAgentStreamCmd Handler: Received JSON string: %q", itemJSON)
+// takeRepoContext reports whether the next run should send the repository
+// context, and marks it sent
+func (app *App) takeRepoContext() bool {
+	if app.repoContextSent {
+		return false
+	}
+	app.repoContextSent = true
+	return true
+}
 
-			var item agent.ResponseItem
-			err := json.Unmarshal([]byte(itemJSON), &item)
-			if err != nil {
-				app.Logger.Log("ERROR: listenAgentStreamCmd Handler: Failed to unmarshal ResponseItem JSON: %v. JSON: %s", err, itemJSON)
-				app.agentMsgChan <- agentErrorMsg{err: fmt.Errorf("failed to unmarshal agent response: %w", err)}
-				return
-			}
+// runEngineCmd starts an engine run in a goroutine and reports its end to
+// the Update loop
+func (app *App) runEngineCmd(timeout time.Duration, start func(ctx context.Context, bridge *engineBridge) (*engine.Outcome, error)) tea.Cmd {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	app.cancelRun = cancel
+	go func() {
+		defer cancel()
 
-			switch item.Type {
-			case "message", "function_call":
-				fcCopy := item.FunctionCall
-				if item.FunctionCall != nil {
-					copiedFC := *item.FunctionCall
-					fcCopy = &copiedFC
-				}
-				itemToSend := agent.ResponseItem{
-					Type:             item.Type,
-					Message:          item.Message,
-					FunctionCall:     fcCopy,
-					ThinkingDuration: item.ThinkingDuration,
-				}
-				app.Logger.Log("listenAgentStreamCmd Handler: Sending agentResponseMsg to channel (Type: %s).", item.Type)
-				app.agentMsgChan <- agentResponseMsg{item: itemToSend}
-			case "followup_complete":
-				app.Logger.Log("listenAgentStreamCmd Handler: Sending agentFollowUpCompleteMsg to channel.")
-				app.agentMsgChan <- agentFollowUpCompleteMsg{}
-			default:
-				app.Logger.Log("WARN: listenAgentStreamCmd Handler: Received unknown item type '%s'. Ignoring.", item.Type)
-			}
-		})
-		app.Logger.Log("listenAgentStreamCmd: Goroutine finished Agent.SendMessage call. Error: %v, EndedWithTools: %t", err, streamEndedWithTools)
+		bridge := &engineBridge{app: app}
+		outcome, err := start(ctx, bridge)
+		app.Logger.Log("runEngineCmd: Engine run finished. Error: %v, Tool calls: %d", err, outcome.ToolCalls)
 
 		if err != nil {
-			app.Logger.Log("listenAgentStreamCmd: Goroutine sending agentErrorMsg to channel.")
-			app.agentMsgChan <- agentErrorMsg{err: err}
-		} else if !streamEndedWithTools {
-			app.Logger.Log("listenAgentStreamCmd: Goroutine finished normally and without tool calls. Sending agentStreamCompleteMsg.")
-			app.agentMsgChan <- agentStreamCompleteMsg{}
+			app.sendAgentMsg(agentErrorMsg{err: err})
 		} else {
-			app.Logger.Log("listenAgentStreamCmd: Goroutine finished normally, ended with tool calls. NOT sending agentStreamCompleteMsg.")
+			app.sendAgentMsg(agentStreamCompleteMsg{})
 		}
 	}()
 
-	app.Logger.Log("listenAgentStreamCmd: Returning nil command.")
 	return nil
 }
 
-// handleAgentResponseItem processes a single response item from the agent
-func (app *App) handleAgentResponseItem(item agent.ResponseItem) {
-	app.Logger.Log("App.handleAgentResponseItem received item type: %s", item.Type)
-
-	switch item.Type {
-	case "message":
-		app.Logger.Log("Handling 'message' item.")
-		if item.Message != nil {
-			app.Logger.Log("Message item content length: %d", len(item.Message.Content))
-			app.Logger.Log("isFirstAgentChunk state *before* processing message: %t", app.isFirstAgentChunk)
-			app.ChatModel.SetThinkingStatus(fmt.Sprintf("Receiving message chunk..."))
-			content := item.Message.Content
-
-			if app.isFirstAgentChunk {
-				app.Logger.Log("isFirstAgentChunk=true, adding new assistant message.")
-				app.ChatModel.AddAssistantMessage(content)
-				app.isFirstAgentChunk = false
-				app.Logger.Log("Called AddAssistantMessage, set isFirstAgentChunk=false")
-			} else {
-				app.Logger.Log("isFirstAgentChunk=false, updating last assistant message.")
-				app.ChatModel.UpdateLastAssistantMessage(content)
-				app.Logger.Log("Called UpdateLastAssistantMessage")
-			}
+// distillationSummary describes how /explore condensed the exploration
+func distillationSummary(d engine.Distillation) string {
+	msg := fmt.Sprintf("Exploration done after %d read-only tool call(s). Replaced %d message(s) with the summary above (about %d -> %d tokens of history). Starting the task...", d.Calls, d.Messages, d.TokensBefore, d.TokensAfter)
+	if d.BudgetExceeded {
+		msg += "\nThe assistant asked for more calls than allowed; raise explore_calls in the config if exploration is cut short."
+	}
+	return msg
+}
 
-			app.ChatModel.ForceUpdateViewport()
-			app.Logger.Log("Called ForceUpdateViewport in message handler.")
-		} else {
-			app.Logger.Log("WARN: Handling 'message' item, but item.Message is nil.")
-		}
-		app.Logger.Log("App.handleAgentResponseItem finished processing message.")
+// sendAgentMsg delivers a message to the Update loop unless the app is closing
+func (app *App) sendAgentMsg(msg tea.Msg) bool {
+	select {
+	case app.agentMsgChan <- msg:
+		return true
+	case <-app.done:
+		return false
+	}
+}
 
-	case "function_call":
-		if item.FunctionCall != nil {
-			app.Logger.Log("Handling 'function_call' item. Name: %s, ID: %s, Full Args JSON: %s", item.FunctionCall.Name, item.FunctionCall.ID, item.FunctionCall.Arguments)
-			app.ChatModel.SetThinkingStatus(fmt.Sprintf("Evaluating %s...", item.FunctionCall.Name))
-			app.ChatModel.AddFunctionCallMessage(item.FunctionCall.Name, item.FunctionCall.Arguments)
-			app.ChatModel.ForceUpdateViewport()
+// handleAgentMessage shows the assistant message streamed so far
+func (app *App) handleAgentMessage(content string) {
+	app.Logger.Log("Handling assistant message. Content length: %d, isFirstAgentChunk: %t", len(content), app.isFirstAgentChunk)
+	app.ChatModel.SetThinkingStatus("Receiving message chunk...")
 
-			// --- Decide if Approval Needed ---
-			needsApproval := app.needsApprovalForFunction(item.FunctionCall.Name)
-			var argsForApproval string
-			if needsApproval {
-				if item.FunctionCall.Name == "execute_command" || item.FunctionCall.Name == "patch_file" || item.FunctionCall.Name == "write_file" {
-					var argsMap map[string]interface{}
-					if err := json.Unmarshal([]byte(item.FunctionCall.Arguments), &argsMap); err == nil {
-						if cmd, ok := argsMap["command"].(string); ok {
-							argsForApproval = cmd
-						} else if patch, ok := argsMap["code_edit"].(string); ok {
-							argsForApproval = patch
-						} else if patch, ok := argsMap["patch_content"].(string); ok { // Handle alternative key
-							argsForApproval = patch
-						} else if content, ok := argsMap["content"].(string); ok { // For write_file
-							argsForApproval = content
-						} else {
-							argsForApproval = item.FunctionCall.Arguments
-							app.Logger.Log("WARN: Could not extract specific arg (command/code_edit/patch_content/content) for approval display from: %s", item.FunctionCall.Arguments)
-						}
-					} else {
-						argsForApproval = item.FunctionCall.Arguments
-						app.Logger.Log("WARN: Failed to unmarshal args JSON for approval display: %s", item.FunctionCall.Arguments)
-					}
-				} else {
-					argsForApproval = item.FunctionCall.Arguments // For other functions, just show the JSON args
-				}
-			}
-			app.Logger.Log("Determined argsForApproval length: %d", len(argsForApproval))
-
-			if needsApproval {
-				app.Logger.Log("Function %s requires approval.", item.FunctionCall.Name)
-
-				// --- Add Summary Message to Chat (if patch_file) ---
-				if item.FunctionCall.Name == "patch_file" {
-					// Extract target files from the patch content for the summary
-					targetFiles := extractTargetFilesFromPatch(argsForApproval)
-					summary := ""
-					if len(targetFiles) > 0 {
-						summary = fmt.Sprintf("Assistant proposes patching file(s): %s. Approval required.", strings.Join(targetFiles, ", "))
-					} else {
-						summary = "Assistant proposes applying a patch. Approval required."
-					}
-					app.Logger.Log("Adding patch proposal summary to chat: %s", summary)
-					app.ChatModel.AddSystemMessage(summary)
-					app.ChatModel.ForceUpdateViewport() // Update view to show the summary
-				}
-				// ----------------------------------------------------
+	if app.isFirstAgentChunk {
+		app.ChatModel.AddAssistantMessage(content)
+		app.isFirstAgentChunk = false
+	} else {
+		app.ChatModel.UpdateLastAssistantMessage(content)
+	}
+	app.ChatModel.ForceUpdateViewport()
+}
 
-				// Trigger the approval UI
-				app.askForApproval(item.FunctionCall.Name, argsForApproval, item.FunctionCall)
-				// Stop processing here, wait for ApprovalResultMsg
-				return
-			}
+// requestApproval shows the approval UI for a call the engine is waiting on.
+// It returns false if it answered without asking the user.
+func (app *App) requestApproval(call agent.FunctionCall, reply chan engine.Decision) bool {
+	argsForApproval := executor.ApprovalArgs(call)
+	app.Logger.Log("Function %s requires approval. Args for approval length: %d", call.Name, len(argsForApproval))
+
+	if call.Name == "patch_file" {
+		// A patch that cannot be applied goes back to the assistant with
+		// the reason, rather than to the user
+		if preview, err := app.Executor.ValidatePatch(call); err != nil {
+			app.Logger.Log("Patch of call %s cannot be applied, refusing it without asking: %v", call.ID, err)
+			app.ChatModel.AddNotice("The assistant proposed a patch that cannot be applied. It was sent back to the assistant without asking you.")
+			app.ChatModel.ForceUpdateViewport()
+			reply <- engine.Decision{Call: call, Refusal: app.Executor.RefusePatch(preview)}
+			return false
+		}
+		targetFiles := fileops.PatchFiles(argsForApproval)
+		summary := "Assistant proposes applying a patch. Approval required."
+		if len(targetFiles) > 0 {
+			summary = fmt.Sprintf("Assistant proposes patching file(s): %s. Approval required.", strings.Join(targetFiles, ", "))
+		}
+		app.ChatModel.AddNotice(summary)
+		app.ChatModel.ForceUpdateViewport()
+	}
 
-			// --- Execute Function Directly (No Approval Needed) ---
-			app.Logger.Log("Function %s does not require approval. Executing directly.", item.FunctionCall.Name)
-			app.ChatModel.SetThinkingStatus(fmt.Sprintf("Executing: %s...", item.FunctionCall.Name))
-			var agentOutput string
-			var success bool
-
-			if item.FunctionCall.Name == "execute_command" {
-				var args map[string]interface{}
-				cmdStr := ""
-				if err := json.Unmarshal([]byte(item.FunctionCall.Arguments), &args); err != nil {
-					agentOutput = fmt.Sprintf("Error parsing command args: %v", err)
-					success = false
-					app.ChatModel.AddSystemMessage(agentOutput)
-				} else {
-					var ok bool
-					cmdStr, ok = args["command"].(string)
-					if !ok || cmdStr == "" {
-						agentOutput = "Missing command argument for execute_command"
-						success = false
-						app.ChatModel.AddSystemMessage(agentOutput)
-					} else {
-						result, err := app.Sandbox.Execute(context.Background(), sandbox.SandboxOptions{
-							Command:    cmdStr,
-							WorkingDir: app.Config.CWD,
-							Timeout:    30 * time.Second,
-						})
-						uiResult := &ui.CommandResult{Command: cmdStr, Stdout: result.Stdout, Stderr: result.Stderr, ExitCode: result.ExitCode, Duration: result.Duration, Error: err}
-						app.ChatModel.AddCommandMessage(cmdStr, uiResult)
-						agentOutput = result.Stdout
-						success = err == nil && result.ExitCode == 0
-						if !success { /* Set error output */
-							if err != nil {
-								agentOutput = fmt.Sprintf("Execution Error: %v", err)
-							} else {
-								agentOutput = fmt.Sprintf("Command Failed (code %d): %s", result.ExitCode, result.Stderr)
-							}
-						}
-					}
-				}
-			} else if item.FunctionCall.Name == "patch_file" {
-				var args map[string]interface{}
-				if err := json.Unmarshal([]byte(item.FunctionCall.Arguments), &args); err != nil {
-					agentOutput = fmt.Sprintf("Error parsing patch_file args: %v", err)
-					success = false
-					app.ChatModel.AddSystemMessage(agentOutput)
-				} else {
-					patchContent := ""
-					if pc, ok := args["code_edit"].(string); ok {
-						patchContent = pc
-					} else if pc, ok := args["patch_content"].(string); ok {
-						patchContent = pc
-					}
-					if patchContent == "" {
-						agentOutput = "Missing patch_content argument for patch_file"
-						success = false
-						app.ChatModel.AddSystemMessage(agentOutput)
-					} else {
-						// --- Approval Check ---
-						if app.needsApprovalForFunction(item.FunctionCall.Name) {
-							app.askForApproval(item.FunctionCall.Name, patchContent, item.FunctionCall)
-							// If approval is needed, we stop processing here and wait for ApprovalResultMsg
-							app.Logger.Log("Approval required for patch_file. Skipping direct execution.")
-							return // Don't proceed to execution or sending result yet
-						}
-						// --- Direct Execution (if no approval needed) ---
-						app.Logger.Log("Calling fileops.ParseAgentPatch directly...")
-						operations, parseErr := fileops.ParseAgentPatch(patchContent)
-						if parseErr != nil {
-							agentOutput = fmt.Sprintf("Error parsing patch: %v", parseErr)
-							success = false
-							// Add parse error result to UI
-							app.ChatModel.AddAgentPatchResultMessage(&fileops.AgentPatchResult{
-								Success: false,
-								Error:   parseErr,
-								Diff:    "Patch parsing failed",
-							})
-						} else {
-							app.Logger.Log("Calling fileops.ApplyAgentPatch directly...")
-							applyResults, applyErr := fileops.ApplyAgentPatch(operations)
-							successCount, failureCount := 0, 0
-							for _, res := range applyResults {
-								if res.Success {
-									successCount++
-									// --- Start: Auto-format successful patch ---
-									formatCmdStr := getFormatterCommand(res.Path)
-									if formatCmdStr != "" {
-										app.Logger.Log("[Direct Execute] Attempting to auto-format successfully patched file: %s with command: %s", res.Path, formatCmdStr)
-										formatCtx, formatCancel := context.WithTimeout(context.Background(), 15*time.Second)
-										formatResult, formatErr := app.Sandbox.Execute(formatCtx, sandbox.SandboxOptions{
-											Command:    formatCmdStr,
-											WorkingDir: app.Config.CWD,
-										})
-										formatCancel()
-										if formatErr != nil || formatResult.ExitCode != 0 {
-											formatErrMsg := fmt.Sprintf("[Direct Execute] Auto-formatting failed for %s.", res.Path)
-											if formatErr != nil {
-												formatErrMsg = fmt.Sprintf("%s Error: %v", formatErrMsg, formatErr)
-											} else {
-												formatErrMsg = fmt.Sprintf("%s Exit Code: %d, Stderr: %s", formatErrMsg, formatResult.ExitCode, formatResult.Stderr)
-											}
-											app.Logger.Log("ERROR: %s", formatErrMsg)
-											app.ChatModel.AddSystemMessage(formatErrMsg)
-										} else {
-											app.Logger.Log("[Direct Execute] Successfully auto-formatted %s.", res.Path)
-										}
-									} else {
-										app.Logger.Log("[Direct Execute] No formatter identified for file extension of %s, skipping auto-format.", res.Path)
-									}
-									// --- End: Auto-format successful patch ---
-								} else {
-									failureCount++
-								}
-								app.ChatModel.AddAgentPatchResultMessage(res)
-								app.ChatModel.ForceUpdateViewport()
-							}
-							if applyErr != nil {
-								agentOutput = fmt.Sprintf("Patch application finished with errors. Succeeded: %d, Failed: %d. First error: %v", successCount, failureCount, applyErr)
-								success = false
-							} else if failureCount > 0 {
-								agentOutput = fmt.Sprintf("Patch application finished. Succeeded: %d, Failed: %d.", successCount, failureCount)
-								success = false
-							} else {
-								agentOutput = fmt.Sprintf("Patch application finished successfully. Operations applied: %d.", successCount)
-								success = true
-							}
-						}
-					}
-				}
-			} else { // Generic function from registry
-				fn := app.FunctionRegistry.Get(item.FunctionCall.Name)
-				if fn == nil { /* Handle unknown function */
-					agentOutput = fmt.Sprintf("Unknown function: %s", item.FunctionCall.Name)
-					success = false
-					app.ChatModel.AddSystemMessage(agentOutput)
-				} else {
-					result, err := fn(item.FunctionCall.Arguments)
-					success = err == nil
-					agentOutput = result
-					if err != nil { /* Set agentOutput, add system message */
-						agentOutput = fmt.Sprintf("Error: %v", err)
-						app.ChatModel.AddSystemMessage(agentOutput)
-					}
-					app.ChatModel.AddFunctionResultMessage(agentOutput, !success)
-				}
-			}
+	if escapes := app.Executor.Escapes(call); len(escapes) > 0 {
+		app.ChatModel.AddNotice(fmt.Sprintf("Warning: %s writes outside the allowed roots (%s): %s. Approve it only if you expect the change there.", call.Name, strings.Join(app.Executor.Workspace.AllowedRoots(), ", "), strings.Join(escapes, ", ")))
+		app.ChatModel.ForceUpdateViewport()
+	}
 
-			// --- Send result back to agent --- (Only if approval wasn't needed)
-			resultMsg := sendFunctionResultMsg{
-				ctx:          context.Background(),
-				functionName: item.FunctionCall.Name,
-				callID:       item.FunctionCall.ID,
-				originalArgs: item.FunctionCall.Arguments,
-				output:       agentOutput,
-				success:      success,
-			}
+	app.pendingApproval = reply
+	if call.Name == "patch_file" && app.reviewPatch(call, argsForApproval) {
+		return true
+	}
+	app.askForApproval(call.Name, argsForApproval, &call)
+	return true
+}
 
-			app.Logger.Log("App.handleAgentResponseItem (Direct Execute): Starting goroutine to send sendFunctionResultMsg for %s.", resultMsg.functionName)
-			go func() {
-				time.Sleep(50 * time.Millisecond)
-				app.agentMsgChan <- resultMsg
-				app.Logger.Log("App.handleAgentResponseItem (Direct Execute): Goroutine finished sending sendFunctionResultMsg.")
-			}()
+// minPreviewBytes is the size a call's arguments must reach to be previewed
+const minPreviewBytes = 256
 
-		} else {
-			app.Logger.Log("WARN: Handling 'function_call' item, but item.FunctionCall is nil.")
-		}
+// previewToolCall shows the arguments of a call the assistant is still
+// generating, so that a long patch or file can be reviewed, or stopped, before
+// it is complete. Only calls that will need approval and have grown past
+// minPreviewBytes are shown; short ones would just flash by.
+func (app *App) previewToolCall(call agent.FunctionCall) {
+	if !executor.NeedsApproval(app.Config.ApprovalMode, call.Name) || len(call.Arguments) < minPreviewBytes {
+		return
+	}
 
-	default:
-		app.Logger.Log("WARN: App.handleAgentResponseItem received unhandled item type: %s.", item.Type)
+	content := executor.PreviewArgs(call)
+	if call.Name == "patch_file" {
+		content = ui.FormatPatchForDisplay(content)
 	}
+	if app.isPreviewingCall && app.previewCall.ID == call.ID && app.previewCall.Name == call.Name {
+		app.previewModel.SetAction(content)
+	} else {
+		app.Logger.Log("Previewing %s (ID: %s) while it is generated", call.Name, call.ID)
+		app.previewModel = ui.NewPreviewModel(
+			fmt.Sprintf("Generating %s", call.Name),
+			"The assistant is still writing this call. Approval will be requested once it is complete.",
+			content)
+		app.previewModel.SetSize(app.width, app.height)
+		app.isPreviewingCall = true
+	}
+	app.previewCall = call
+	app.ChatModel.SetThinkingStatus(fmt.Sprintf("Receiving %s (%d bytes)...", call.Name, len(call.Arguments)))
 }
 
-// sendFunctionResultCmd processes the function result and sends it back to the agent
-func (app *App) sendFunctionResultCmd(msg sendFunctionResultMsg) {
-	app.Logger.Log("sendFunctionResultCmd: Preparing to send result for %s (callID: %s), success=%t", msg.functionName, msg.callID, msg.success)
-	if app.Agent != nil {
-		go func() {
-			app.Logger.Log("sendFunctionResultCmd Goroutine: Calling Agent.SendFunctionResult for %s...", msg.functionName)
-			err := app.Agent.SendFunctionResult(msg.ctx, msg.callID, msg.functionName, msg.output, msg.success)
-			app.Logger.Log("sendFunctionResultCmd Goroutine: Agent.SendFunctionResult returned error: %v", err)
-			if err != nil {
-				app.Logger.Log("ERROR: sendFunctionResultCmd Goroutine: Sending agentErrorMsg due to SendFunctionResult failure: %v", err)
-				app.agentMsgChan <- agentErrorMsg{err: fmt.Errorf("failed to send function result for %s: %w", msg.functionName, err)}
-			} else {
-				app.Logger.Log("sendFunctionResultCmd Goroutine: Agent.SendFunctionResult success. Handler will send next messages.")
-			}
-		}()
+// endPreview hides the preview of a call being generated
+func (app *App) endPreview() {
+	app.isPreviewingCall = false
+	app.previewCall = agent.FunctionCall{}
+}
 
-		app.isFirstAgentChunk = true
-		app.Logger.Log("sendFunctionResultCmd: Reset isFirstAgentChunk=true")
+// stopGeneration cancels the run whose call is being previewed. The partial
+// call is discarded without being executed.
+func (app *App) stopGeneration() {
+	app.Logger.Log("Stopping generation of %s after %d bytes", app.previewCall.Name, len(app.previewCall.Arguments))
+	app.ChatModel.AddNotice(fmt.Sprintf("Stopped the assistant while it was generating %s (%d bytes received).", app.previewCall.Name, len(app.previewCall.Arguments)))
+	app.ChatModel.ForceUpdateViewport()
+	app.endPreview()
+	app.interrupting = true
+	if app.cancelRun != nil {
+		app.cancelRun()
+	}
+}
 
-		app.ChatModel.AddSystemMessage("Function complete - waiting for assistant response...")
-		app.ChatModel.ForceUpdateViewport()
+// interruptTurn stops the turn in progress: the response being streamed
+// and the command running, if any. The engine run then ends with
+// context.Canceled, which gives the input back.
+func (app *App) interruptTurn() {
+	if app.interrupting {
+		return
+	}
+	app.Logger.Log("Esc: interrupting the turn")
+	app.interrupting = true
+	app.ChatModel.SetNotice("Interrupted. Send a message to continue.")
+	app.ChatModel.SetThinkingStatus("Stopping...")
+	app.Executor.InterruptCommand()
+	if app.cancelRun != nil {
+		app.cancelRun()
+	}
+	app.Agent.Cancel()
+}
 
-		app.ChatModel.SetThinkingStatus("Function executed, waiting for assistant response...")
+// recordApproval adds an approval decision to the rollout's audit trail.
+// Decisions the user made and denials are also annotated in the chat; calls
+// the approval mode allows without asking are only recorded.
+func (app *App) recordApproval(event agent.ApprovalEvent) {
+	app.Logger.Log("Approval event: %s (call %s)", event, event.CallID)
+	rollout := app.rollout()
+	rollout.Approvals = append(rollout.Approvals, event)
+	app.recordEvent(RolloutEvent{Type: eventApproval, CallID: event.CallID, Approval: &event})
+	if event.DecidedBy == agent.DecidedByUser || !event.Approved() {
+		app.ChatModel.AddApprovalMessage(event)
+		app.ChatModel.ForceUpdateViewport()
+	}
+}
 
-		app.Logger.Log("sendFunctionResultCmd: Finished initiating send.")
+// recordCommand adds the shell command res ran, if any, to the rollout
+func (app *App) recordCommand(callID string, res *executor.Result) {
+	if res.Command == "" {
+		return
+	}
+	record := CommandRecord{
+		CallID:      callID,
+		Command:     res.Command,
+		Success:     res.Success,
+		Interrupted: res.Interrupted,
+		TimedOut:    res.TimedOut,
+		FinishedAt:  time.Now(),
+		Environment: res.Environment,
+	}
+	if res.CommandResult != nil {
+		record.ExitCode = res.CommandResult.ExitCode
+		record.DurationMs = res.CommandResult.Duration.Milliseconds()
 	} else {
-		app.Logger.Log("ERROR: sendFunctionResultCmd: Agent is nil!")
-		app.agentMsgChan <- agentErrorMsg{err: fmt.Errorf("agent is nil, cannot send function result")}
+		record.ExitCode = -1 // The command did not start
 	}
+	rollout := app.rollout()
+	rollout.Commands = append(rollout.Commands, record)
+	rollout.CommandsRun = append(rollout.CommandsRun, res.Command)
 }
 
-// needsApprovalForFunction determines if a function needs approval based on the current mode
-func (app *App) needsApprovalForFunction(functionName string) bool {
-	// Logging the check
-	app.Logger.Log("Checking approval for function '%s' with mode '%s'", functionName, app.Config.ApprovalMode)
-
-	switch app.Config.ApprovalMode {
-	case config.Suggest:
-		needs := functionName != "read_file" && functionName != "list_directory"
-		app.Logger.Log("Suggest Mode: Needs approval = %t", needs)
-		return needs
-	case config.AutoEdit:
-		needs := functionName == "execute_command"
-		app.Logger.Log("AutoEdit Mode: Needs approval = %t", needs)
-		return needs
-	case config.FullAuto:
-		app.Logger.Log("FullAuto Mode: Needs approval = false")
-		return false
-	case config.DangerousAutoApprove:
-		app.Logger.Log("Dangerous Mode: Needs approval = false")
-		return false
+// awaitFollowUp prepares the chat for the assistant's response to a function result
+func (app *App) awaitFollowUp() {
+	app.isFirstAgentChunk = true
+	app.ChatModel.AddNotice("Function complete - waiting for assistant response...")
+	app.ChatModel.SetThinkingStatus("Function executed, waiting for assistant response...")
+	app.ChatModel.ForceUpdateViewport()
+}
+
+// renderExecutionResult adds the outcome of an executed function call to the chat
+func (app *App) renderExecutionResult(functionName string, res *executor.Result) {
+	if res.Command == "" {
+		app.ChatModel.RemoveCommandMessage() // Nothing was run
+	}
+	switch {
+	case res.Command != "":
+		uiResult := &ui.CommandResult{Command: res.Command, Error: res.CommandErr, Interrupted: res.Interrupted, TimedOut: res.TimedOut}
+		if res.CommandResult != nil {
+			uiResult.Stdout = res.CommandResult.Stdout
+			uiResult.Stderr = res.CommandResult.Stderr
+			uiResult.ExitCode = res.CommandResult.ExitCode
+			uiResult.Duration = res.CommandResult.Duration
+		}
+		app.ChatModel.AddCommandMessage(res.Command, uiResult)
+	case res.PatchParseErr != nil:
+		diff := fmt.Sprintf("Patch parsing failed, attempt %d of %d", res.PatchAttempts, executor.MaxPatchAttempts)
+		if res.PatchAttempts >= executor.MaxPatchAttempts {
+			diff = fmt.Sprintf("Patch parsing failed %d times in a row; the assistant was asked to stop and check with you", res.PatchAttempts)
+		}
+		app.ChatModel.AddAgentPatchResultMessage(&fileops.AgentPatchResult{
+			Success: false,
+			Error:   res.PatchParseErr,
+			Diff:    diff,
+		})
+	case res.PatchResults != nil:
+		for _, formatErr := range res.FormatErrors {
+			app.ChatModel.AddNotice(formatErr)
+		}
+		for _, patchRes := range res.PatchResults {
+			if !res.DryRun || !patchRes.Success {
+				app.ChatModel.AddAgentPatchResultMessage(patchRes)
+			}
+		}
+		if res.DryRun {
+			app.ChatModel.AddDryRunMessage(res.Diff)
+		}
+	case res.DryRun:
+		app.ChatModel.AddDryRunMessage(res.Diff)
+	case executor.IsCommandFunction(functionName) || functionName == "patch_file":
+		// Arguments could not be used; nothing was executed
+		app.ChatModel.AddNotice(res.Output)
 	default:
-		app.Logger.Log("WARN: Unknown approval mode '%s', defaulting to 'suggest' behavior.", app.Config.ApprovalMode)
-		return functionName != "read_file" && functionName != "list_directory"
+		if !res.Success {
+			app.ChatModel.AddNotice(res.Output)
+		}
+		app.ChatModel.AddFunctionResultMessage(res.Output, !res.Success)
+	}
+	if len(res.Redactions) > 0 {
+		app.ChatModel.AddNotice(fmt.Sprintf("Warning: the %s output contained what look like secrets (%s). They were replaced with placeholders before it was sent to the assistant.", functionName, redact.Summary(res.Redactions)))
+	}
+	if res.Verdict.Suspicious {
+		app.ChatModel.AddNotice(fmt.Sprintf("Warning: the %s output looks like a prompt-injection attempt (%s). It was passed to the assistant marked as untrusted; review the assistant's next steps carefully.", functionName, res.Verdict.Summary()))
 	}
+	app.ChatModel.ForceUpdateViewport()
 }
 
 // askForApproval sets the state to show the approval UI instead of blocking
@@ -919,13 +1235,30 @@ func (app *App) askForApproval(functionName, argsToDisplay string, originalCall
 	case "write_file":
 		title = "Approve File Write"
 		description = "The assistant wants to write to a file on your filesystem:"
+	case "edit_lines":
+		title = "Approve Line Edit"
+		description = "The assistant wants to change these lines of a file:"
+		if diff := app.Executor.WriteDiff(*originalCall); diff != "" {
+			contentToDisplay = diff
+		}
 	case "patch_file":
 		title = "Approve File Patch"
 		description = "The assistant wants to modify file(s) using the following patch:"
-		// Format the patch content for display
-		app.Logger.Log("Formatting patch content for display...")
-		contentToDisplay = ui.FormatPatchForDisplay(argsToDisplay)
-	case "execute_command":
+		// The changes the patch would make, or the patch itself if it
+		// cannot be applied as it stands
+		if diff := app.Executor.WriteDiff(*originalCall); diff != "" {
+			description = "The assistant wants to patch file(s), making these changes:"
+			contentToDisplay = diff
+		} else {
+			contentToDisplay = ui.FormatPatchForDisplay(argsToDisplay)
+		}
+		if conflicts := app.Executor.CreateConflicts(*originalCall); len(conflicts) > 0 {
+			description = strings.TrimSuffix(description, ":") + ". " + strings.Join(conflicts, " ")
+		}
+	case memory.ToolName:
+		title = "Approve Project Memory"
+		description = fmt.Sprintf("The assistant wants to remember this in %s, which is loaded at the start of future sessions:", memory.FileName)
+	case "execute_command", "shell":
 		title = "Approve Command Execution"
 		description = "The assistant wants to execute the following shell command:"
 	default:
@@ -935,6 +1268,11 @@ func (app *App) askForApproval(functionName, argsToDisplay string, originalCall
 
 	app.Logger.Log("Creating ApprovalModel. Title: %s, Desc: %s, Content Length: %d", title, description, len(contentToDisplay))
 	app.approvalModel = ui.NewApprovalModel(title, description, contentToDisplay)
+	if escapes := app.Executor.Escapes(*originalCall); len(escapes) > 0 {
+		description = fmt.Sprintf("%s\nOutside the allowed roots: %s", description, strings.Join(escapes, ", "))
+	} else if app.Approvals != nil && approvals.Rememberable(functionName) && len(app.Executor.Overwrites(*originalCall)) == 0 {
+		app.approvalModel.AlwaysText = "Always allow"
+	}
 	app.isAwaitingApproval = true
 	app.pendingFunctionCall = originalCall  // Store the original call details
 	app.pendingApprovalArgs = argsToDisplay // Store the *original*, unformatted args shown to the user
@@ -948,48 +1286,91 @@ func (app *App) askForApproval(functionName, argsToDisplay string, originalCall
 	app.Logger.Log("Approval state set. Waiting for ui.ApprovalResultMsg.")
 }
 
-// initRepositoryContext loads project-specific context from codex.md files
-func (app *App) initRepositoryContext() error {
-	app.Logger.Log("Initializing repository context...")
-	repoContext, err := app.loadRepositoryContext()
+// repositoryContext returns the system messages with the project docs
+// (AGENTS.md and codex.md), the project memory and the repository map sent
+// ahead of the first message, one for each source of the context budget.
+// When they exceed repo_context_tokens, the sections most relevant to prompt
+// are kept. It returns none if there is nothing to send.
+func (app *App) repositoryContext(prompt string) []agent.Message {
+	app.Logger.Log("Loading repository context...")
+	sections, headers, err := app.loadRepositoryContext()
 	if err != nil {
-		app.Logger.Log("Error loading repository context: %v", err)
-		return err
+		app.Logger.Log("Warning: Failed to load repository context: %v", err)
 	}
-
-	if repoContext == "" {
-		app.Logger.Log("No repository context found (codex.md files). Skipping.")
+	if len(sections) == 0 {
+		app.Logger.Log("No repository context found (codex.md files or repository map). Skipping.")
 		return nil
 	}
 
-	app.Logger.Log("Found repository context. Adding as system message.")
-	ctx := context.Background()
-	systemMsg := agent.Message{
-		Role:    "system",
-		Content: "Repository Context:\n" + repoContext,
+	selection := repocontext.Select(sections, prompt, app.Config.RepoContextTokens)
+	var messages []agent.Message
+	tokens := 0
+	for _, source := range []contextbudget.Source{contextbudget.ProjectDocs, contextbudget.Memory, contextbudget.RepoMap} {
+		part := selection.Only(func(s repocontext.Section) bool { return app.contextSource(s.Source) == source })
+		if len(part.Sections) == 0 && len(part.Omitted) == 0 {
+			continue
+		}
+		content := part.Render(headers)
+		tokens += repomap.EstimateTokens(content)
+		messages = append(messages, agent.Message{Role: "system", Content: "Repository Context:\n" + content, Source: source})
 	}
+	app.Logger.Log("Repository context: %d of %d sections kept, about %d tokens (budget %d)",
+		len(selection.Sections), len(sections), tokens, app.Config.RepoContextTokens)
+	return messages
+}
 
-	app.Logger.Log("Sending repository context to agent history...")
-	_, err = app.Agent.SendMessage(ctx, []agent.Message{systemMsg}, func(itemJSON string) {
-		app.Logger.Log("Repository context SendMessage handler received item (should be empty): %s", itemJSON)
-	})
-	if err != nil {
-		app.Logger.Log("Error sending repository context to agent: %v", err)
+// contextSource returns the source of the context budget a section of the
+// repository context loaded from source belongs to
+func (app *App) contextSource(source string) contextbudget.Source {
+	switch {
+	case source == repoMapSource:
+		return contextbudget.RepoMap
+	case app.Memory != nil && source == app.Memory.Path:
+		return contextbudget.Memory
+	default:
+		return contextbudget.ProjectDocs
 	}
-	return err
 }
 
-// loadRepositoryContext looks for and loads codex.md files
-func (app *App) loadRepositoryContext() (string, error) {
-	var contextParts []string
+// repoMapSource is the source of the sections of the repository map
+const repoMapSource = "repository map"
+
+// loadRepositoryContext looks for and loads the instruction files (AGENTS.md
+// and codex.md) of the repository root and the working directory, and the
+// repository map, split into sections, along with the header of each source
+func (app *App) loadRepositoryContext() ([]repocontext.Section, map[string]string, error) {
+	var sections []repocontext.Section
+	headers := make(map[string]string)
+
+	// The executor need not hand out the files loaded here again
+	var docs []string
+	defer func() {
+		if app.Executor.Instructions != nil {
+			app.Executor.Instructions.MarkSent(docs...)
+		}
+	}()
+	loadDoc := func(path, header string) {
+		if slices.Contains(docs, path) {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		app.Logger.Log("Found instruction file %s", path)
+		headers[path] = header
+		docs = append(docs, path)
+		sections = append(sections, repocontext.SplitMarkdown(path, string(data))...)
+	}
 
 	if app.Config.ProjectDocPath != "" {
 		app.Logger.Log("Loading project doc from specified path: %s", app.Config.ProjectDocPath)
 		data, err := os.ReadFile(app.Config.ProjectDocPath)
 		if err != nil {
-			return "", fmt.Errorf("failed to read project doc from path %s: %w", app.Config.ProjectDocPath, err)
+			return nil, nil, fmt.Errorf("failed to read project doc from path %s: %w", app.Config.ProjectDocPath, err)
 		}
-		contextParts = append(contextParts, string(data))
+		docs = append(docs, app.Config.ProjectDocPath)
+		sections = append(sections, repocontext.SplitMarkdown(app.Config.ProjectDocPath, string(data))...)
 	}
 
 	cwd := app.Config.CWD
@@ -997,31 +1378,139 @@ func (app *App) loadRepositoryContext() (string, error) {
 	if err == nil {
 		app.Logger.Log("Found repository root: %s", repoRoot)
 		if repoRoot != cwd {
-			repoRootDocPath := filepath.Join(repoRoot, "codex.md")
-			if _, err := os.Stat(repoRootDocPath); err == nil {
-				app.Logger.Log("Found codex.md in repository root: %s", repoRootDocPath)
-				data, err := os.ReadFile(repoRootDocPath)
-				if err == nil {
-					contextParts = append(contextParts, fmt.Sprintf("Repository Root codex.md:\n%s", string(data)))
-				}
+			for _, name := range instructions.FileNames {
+				loadDoc(filepath.Join(repoRoot, name), "Repository Root "+name+":")
+			}
+		}
+		if !app.Config.DisableRepoMap {
+			if repoMap := app.loadRepoMap(repoRoot); repoMap != "" {
+				headers[repoMapSource] = "Repository Map (directory tree and exported symbols):"
+				sections = append(sections, repocontext.SplitTree(repoMapSource, repoMap)...)
 			}
 		}
 	} else {
 		app.Logger.Log("Could not find repository root starting from %s: %v", cwd, err)
 	}
 
-	cwdDocPath := filepath.Join(cwd, "codex.md")
-	if _, err := os.Stat(cwdDocPath); err == nil {
-		app.Logger.Log("Found codex.md in current directory: %s", cwdDocPath)
-		data, err := os.ReadFile(cwdDocPath)
-		if err == nil {
-			contextParts = append(contextParts, fmt.Sprintf("Current Directory codex.md:\n%s", string(data)))
+	if app.Memory != nil {
+		if data, err := app.Memory.Load(); err != nil {
+			app.Logger.Log("Warning: Failed to read project memory: %v", err)
+		} else if strings.TrimSpace(data) != "" {
+			app.Logger.Log("Found project memory: %s", app.Memory.Path)
+			headers[app.Memory.Path] = "Project Memory (" + memory.FileName + ", conventions saved in earlier sessions):"
+			sections = append(sections, repocontext.SplitMarkdown(app.Memory.Path, data)...)
+		}
+	}
+
+	for _, name := range instructions.FileNames {
+		loadDoc(filepath.Join(cwd, name), "Current Directory "+name+":")
+	}
+
+	app.Logger.Log("Repository context: %d sections", len(sections))
+	return sections, headers, nil
+}
+
+// loadRepoMap refreshes the cached repository map and renders it for the agent
+func (app *App) loadRepoMap(repoRoot string) string {
+	start := time.Now()
+	m, stats, err := repomap.Refresh(repoRoot, ignoredPaths(app.Config, repoRoot))
+	if err != nil {
+		app.Logger.Log("Warning: Repository map refresh failed: %v", err)
+		if m == nil {
+			return ""
+		}
+	}
+	app.Logger.Log("Repository map refreshed in %v: %d files parsed, %d reused, %d removed", time.Since(start), stats.Parsed, stats.Reused, stats.Removed)
+	return m.Render(app.Config.RepoMapTokens)
+}
+
+// repoMapSummary renders the repository map as the agent sees it, for /map
+func (app *App) repoMapSummary() string {
+	repoRoot, err := findRepositoryRoot(app.Config.CWD)
+	if err != nil {
+		return "No repository map: the working directory is not in a git repository."
+	}
+	repoMap := app.loadRepoMap(repoRoot)
+	if repoMap == "" {
+		return "The repository map could not be built; see the log for details."
+	}
+	note := ""
+	if app.Config.DisableRepoMap {
+		note = " It is not included in the context because disable_repo_map is set."
+	}
+	return fmt.Sprintf("Repository map of %s (about %d of %d tokens).%s\n\n%s",
+		repoRoot, repomap.EstimateTokens(repoMap), app.Config.RepoMapTokens, note, strings.TrimSuffix(repoMap, "\n"))
+}
+
+// recordFilesModified adds the files the agent wrote to the rollout, keeping
+// those of a resumed session
+func (app *App) recordFilesModified() {
+	if app.Executor == nil || app.Executor.Changes == nil {
+		return
+	}
+	for _, path := range app.Executor.Changes.Files() {
+		if !slices.Contains(app.CurrentRollout.FilesModified, path) {
+			app.CurrentRollout.FilesModified = append(app.CurrentRollout.FilesModified, path)
+		}
+	}
+}
+
+// auditSummary lists the commands run in the rollout, with their exit codes,
+// and the files the agent modified, for --view
+func (r *AppRollout) auditSummary() string {
+	var b strings.Builder
+	switch {
+	case len(r.Commands) > 0:
+		fmt.Fprintf(&b, "Commands run (%d):\n", len(r.Commands))
+		for _, c := range r.Commands {
+			status := fmt.Sprintf("exit %d", c.ExitCode)
+			switch {
+			case c.Interrupted:
+				status = "interrupted"
+			case c.TimedOut:
+				status = "timed out"
+			case c.ExitCode == -1:
+				status = "not started"
+			}
+			fmt.Fprintf(&b, "  [%s] %s\n", status, c.Command)
+		}
+	case len(r.CommandsRun) > 0:
+		// Sessions saved before commands were recorded with their outcome
+		fmt.Fprintf(&b, "Commands run (%d):\n", len(r.CommandsRun))
+		for _, c := range r.CommandsRun {
+			fmt.Fprintf(&b, "  %s\n", c)
 		}
+	default:
+		b.WriteString("No commands were run.\n")
+	}
+	if len(r.FilesModified) == 0 {
+		b.WriteString("No files were modified.")
+	} else {
+		fmt.Fprintf(&b, "Files modified (%d): %s", len(r.FilesModified), strings.Join(r.FilesModified, ", "))
 	}
+	return b.String()
+}
 
-	combinedContext := strings.Join(contextParts, "\n\n---\n\n")
-	app.Logger.Log("Combined repository context length: %d bytes", len(combinedContext))
-	return combinedContext, nil
+// sessionDiff shows the changes made since the session started, for /diff
+func (app *App) sessionDiff() string {
+	tracker := app.Executor.Changes
+	diff, err := tracker.Diff()
+	if err != nil {
+		app.Logger.Log("Session diff failed: %v", err)
+		return fmt.Sprintf("Could not diff the session's changes: %v", err)
+	}
+	written := "The assistant has not written any files."
+	if files := tracker.Files(); len(files) > 0 {
+		written = fmt.Sprintf("The assistant wrote %d file(s): %s.", len(files), strings.Join(files, ", "))
+	}
+	scope := "the files the assistant wrote"
+	if tracker.UsesGit() {
+		scope = "the working tree, changes made by commands included"
+	}
+	if diff == "" {
+		return fmt.Sprintf("No changes to %s since the session started. %s", scope, written)
+	}
+	return fmt.Sprintf("Changes to %s since the session started. %s\n\n%s", scope, written, strings.TrimSuffix(diff, "\n"))
 }
 
 // findRepositoryRoot walks up the directory tree to find the repository root
@@ -1041,21 +1530,41 @@ func findRepositoryRoot(startDir string) (string, error) {
 	}
 }
 
-// SaveRollout saves the current session to a file
-func (app *App) SaveRollout() error {
+// rollout returns the session's rollout, starting one if needed
+func (app *App) rollout() *AppRollout {
 	if app.CurrentRollout == nil {
 		app.CurrentRollout = &AppRollout{
+			Version:   rolloutVersion,
 			CreatedAt: time.Now(),
 			SessionID: uuid.New().String(),
 		}
 	}
+	return app.CurrentRollout
+}
 
+// sessionTitleSummary describes the session's title and how to change it
+func (app *App) sessionTitleSummary() string {
+	title := app.rollout().Title
+	if title == "" {
+		return "This session has no title yet; it is named after your first message. Set one with /title <text>."
+	}
+	return fmt.Sprintf("Session title: %s\nRename it with /title <text>, or later with codex sessions rename.", title)
+}
+
+// SaveRollout saves the current session to a file
+func (app *App) SaveRollout() error {
+	app.rollout()
 	app.CurrentRollout.UpdatedAt = time.Now()
+	app.CurrentRollout.Model = app.Config.Model
+	if app.CurrentRollout.Repo == "" {
+		app.CurrentRollout.Repo = repositoryName(app.Config.CWD)
+	}
 
 	history := app.Agent.GetHistory()
 	if history != nil {
 		app.CurrentRollout.Messages = history.GetMessages()
 	}
+	app.recordFilesModified()
 
 	if app.RolloutPath == "" {
 		timestamp := time.Now().Format("20060102-150405")
@@ -1074,52 +1583,71 @@ func (app *App) SaveRollout() error {
 	}
 
 	app.Logger.Log("Saving rollout to: %s", app.RolloutPath)
-	data, err := json.MarshalIndent(app.CurrentRollout, "", "  ")
-	if err != nil {
-		app.Logger.Log("Error marshaling rollout: %v", err)
-		return fmt.Errorf("failed to marshal rollout: %w", err)
-	}
-
-	if err := os.WriteFile(app.RolloutPath, data, 0644); err != nil {
+	if err := writeRollout(app.RolloutPath, app.CurrentRollout); err != nil {
 		app.Logger.Log("Error writing rollout file %s: %v", app.RolloutPath, err)
-		return fmt.Errorf("failed to save rollout: %w", err)
+		return err
 	}
 
 	app.Logger.Log("Rollout saved successfully.")
+
+	// Record usage in the stats store; failures here should not lose the rollout
+	if err := recordSessionStats(app.CurrentRollout, &app.PatchMetrics); err != nil {
+		app.Logger.Log("Error recording session stats: %v", err)
+	}
 	return nil
 }
 
 // LoadRollout loads a saved session from a file
 func (app *App) LoadRollout(path string) error {
 	app.Logger.Log("Loading rollout from: %s", path)
-	data, err := os.ReadFile(path)
+	rollout, migrated, err := readRollout(path)
 	if err != nil {
-		app.Logger.Log("Error reading rollout file %s: %v", path, err)
-		return fmt.Errorf("failed to read rollout file: %w", err)
+		app.Logger.Log("Error loading rollout %s: %v", path, err)
+		return err
 	}
-
-	var rollout AppRollout
-	if err := json.Unmarshal(data, &rollout); err != nil {
-		app.Logger.Log("Error unmarshaling rollout from %s: %v", path, err)
-		return fmt.Errorf("failed to unmarshal rollout: %w", err)
+	if migrated {
+		app.Logger.Log("Migrated rollout %s from version %d: rebuilt %d events", path, rollout.MigratedFrom, len(rollout.Events))
 	}
 
-	app.CurrentRollout = &rollout
+	app.CurrentRollout = rollout
 	app.RolloutPath = path
 	app.Logger.Log("Rollout loaded successfully. SessionID: %s, CreatedAt: %s", rollout.SessionID, rollout.CreatedAt)
 
-	// Add the messages to the chat model
+	// Add the messages to the chat model, with each approval annotation
+	// placed before the result of the call it decided on
+	approvals := make(map[string][]agent.ApprovalEvent)
+	var unmatched []agent.ApprovalEvent
+	for _, event := range rollout.Approvals {
+		if event.CallID == "" {
+			unmatched = append(unmatched, event)
+			continue
+		}
+		approvals[event.CallID] = append(approvals[event.CallID], event)
+	}
 	for _, msg := range rollout.Messages {
+		if msg.Role == "tool" {
+			for _, event := range approvals[msg.ToolCallID] {
+				app.ChatModel.AddApprovalMessage(event)
+			}
+			delete(approvals, msg.ToolCallID)
+		}
 		switch msg.Role {
 		case "user":
-			app.ChatModel.AddUserMessage(msg.Content)
+			app.ChatModel.AddUserMessageWithAttachments(msg.Content, imageChipLabels(msg.Images))
 		case "assistant":
 			app.ChatModel.AddAssistantMessage(msg.Content)
 		case "system":
 			app.ChatModel.AddSystemMessage(msg.Content)
 		}
 	}
-	app.Logger.Log("Loaded %d messages from rollout into ChatModel.", len(rollout.Messages))
+	for _, events := range approvals {
+		unmatched = append(unmatched, events...)
+	}
+	sort.Slice(unmatched, func(i, j int) bool { return unmatched[i].Timestamp.Before(unmatched[j].Timestamp) })
+	for _, event := range unmatched {
+		app.ChatModel.AddApprovalMessage(event)
+	}
+	app.Logger.Log("Loaded %d messages and %d approval events from rollout into ChatModel.", len(rollout.Messages), len(rollout.Approvals))
 
 	return nil
 }
@@ -1153,6 +1681,22 @@ func (app *App) Close() error {
 		}
 	}
 
+	if app.Webhook != nil {
+		app.Logger.Log("App.Close: Delivering queued webhook events...")
+		if err := app.Webhook.Close(); err != nil {
+			app.Logger.Log("App.Close: Error closing webhook: %v", err)
+		}
+	}
+
+	if app.watcher != nil {
+		app.watcher.Close()
+	}
+
+	if app.closeTelemetry != nil {
+		app.Logger.Log("App.Close: Flushing traces...")
+		app.closeTelemetry()
+	}
+
 	// Ensure sandbox is closed if needed
 	if closer, ok := app.Sandbox.(io.Closer); ok {
 		app.Logger.Log("App.Close: Closing sandbox...")
@@ -1162,6 +1706,8 @@ func (app *App) Close() error {
 		}
 	}
 
+	app.saveDraft()
+
 	// Save current session state if needed
 	app.Logger.Log("App.Close: Saving rollout...")
 	if err := app.SaveRollout(); err != nil {
@@ -1169,49 +1715,14 @@ func (app *App) Close() error {
 		// Continue with cleanup despite errors
 	}
 
-	// Close the agent message channel to unblock any waiting goroutines
-	if app.agentMsgChan != nil {
-		app.Logger.Log("App.Close: Closing agent message channel...")
-		close(app.agentMsgChan)
+	// Release engine goroutines blocked on the agent message channel or an approval
+	select {
+	case <-app.done:
+	default:
+		app.Logger.Log("App.Close: Releasing engine goroutines...")
+		close(app.done)
 	}
 
 	app.Logger.Log("App.Close: Cleanup complete")
 	return nil
 }
-
-// extractTargetFilesFromPatch is a simple helper to find // FILE: lines in a patch string
-func extractTargetFilesFromPatch(patchContent string) []string {
-	var files []string
-	lines := strings.Split(patchContent, "\n")
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmedLine, "// FILE:") {
-			filename := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "// FILE:"))
-			if filename != "" {
-				files = append(files, filename)
-			}
-		}
-	}
-	return files
-}
-
-// getFormatterCommand returns a suitable formatting command string for a given file path
-// based on its extension. Returns an empty string if no suitable formatter is known.
-func getFormatterCommand(filePath string) string {
-	ext := strings.ToLower(filepath.Ext(filePath))
-
-	switch ext {
-	case ".go":
-		// gofmt is built-in and standard
-		return fmt.Sprintf("gofmt -w %s", filePath)
-	case ".py":
-		// black is a very common and opinionated formatter
-		return fmt.Sprintf("black --quiet %s", filePath)
-	case ".js", ".jsx", ".ts", ".tsx", ".json", ".css", ".scss", ".html", ".yaml", ".yml", ".md":
-		// prettier is common for web/config files
-		return fmt.Sprintf("prettier --write --log-level=warn %s", filePath)
-	// Add cases for other languages as needed (e.g., rustfmt, clang-format)
-	default:
-		return ""
-	}
-}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/approvals"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/slash"
+)
+
+// setupApprovals returns the calls always allowed in the repository holding
+// the working directory, or in the working directory outside a repository
+func setupApprovals(cfg *config.Config) (*approvals.Store, error) {
+	path, err := approvals.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	root, err := findRepositoryRoot(cfg.CWD)
+	if err != nil {
+		root = cfg.CWD
+	}
+	appLogger.Log("Always-allowed calls of %s are kept in %s", root, path)
+	return approvals.NewStore(path, root), nil
+}
+
+// rememberApproval always allows calls identical to call in the project,
+// after the user chose "Always allow"
+func (app *App) rememberApproval(call agent.FunctionCall) {
+	rule, err := app.Approvals.Add(call)
+	if err != nil {
+		app.Logger.Log("Failed to remember approval of %s: %v", call.Name, err)
+		app.ChatModel.AddNotice(fmt.Sprintf("Approved this once; it could not be remembered: %v", err))
+		return
+	}
+	app.ChatModel.AddNotice(fmt.Sprintf("Always allowing %s in this project. /approvals lists and revokes these rules.", rule))
+}
+
+// approvalsCommand handles /approvals
+func (app *App) approvalsCommand(args slash.Args) tea.Cmd {
+	if app.Approvals == nil {
+		app.ChatModel.AddNotice("Always allow is disabled in this session.")
+		return nil
+	}
+	switch args.Arg(0) {
+	case "":
+		app.ChatModel.AddNotice(app.approvalsSummary())
+	case "revoke":
+		app.ChatModel.AddNotice(app.revokeApproval(args.Arg(1)))
+	default:
+		app.ChatModel.AddNotice("Usage: /approvals lists the calls always allowed in this project; /approvals revoke <n|all> revokes them.")
+	}
+	return nil
+}
+
+// approvalsSummary lists the rules of the project for /approvals
+func (app *App) approvalsSummary() string {
+	rules, err := app.Approvals.Rules()
+	if err != nil {
+		return fmt.Sprintf("Failed to read the approvals: %v", err)
+	}
+	if len(rules) == 0 {
+		return "No calls are always allowed in this project. Choose \"Always allow\" when asked to approve one."
+	}
+	var b strings.Builder
+	b.WriteString("Always allowed in this project (revoke one with /approvals revoke <n>):\n")
+	for i, rule := range rules {
+		fmt.Fprintf(&b, "  %d. %s (added %s)\n", i+1, rule, rule.Added.Local().Format("2006-01-02 15:04"))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// revokeApproval removes the rule numbered arg, or all rules
+func (app *App) revokeApproval(arg string) string {
+	if arg == "all" {
+		n, err := app.Approvals.Clear()
+		if err != nil {
+			return fmt.Sprintf("Failed to revoke the approvals: %v", err)
+		}
+		return fmt.Sprintf("Revoked %d always-allowed calls; they will be asked about again.", n)
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return "Usage: /approvals revoke <n|all>, with n as numbered by /approvals."
+	}
+	rule, err := app.Approvals.Revoke(n)
+	if err != nil {
+		return fmt.Sprintf("Failed to revoke the approval: %v", err)
+	}
+	return fmt.Sprintf("Revoked %s; it will be asked about again.", rule)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/images"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// clipboardTimeout bounds how long reading the clipboard may take
+const clipboardTimeout = 5 * time.Second
+
+// attachmentLoadedMsg reports the result of loading a file to attach
+type attachmentLoadedMsg struct {
+	attachment ui.Attachment
+	err        error
+
+	// pasteText makes the chat input paste clipboard text instead if no
+	// image could be read, so Ctrl+V keeps working for text
+	pasteText bool
+}
+
+// attachFileCmd loads a file in the background
+func attachFileCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		att, err := ui.LoadAttachment(path)
+		return attachmentLoadedMsg{attachment: att, err: err}
+	}
+}
+
+// attachClipboardCmd reads an image from the clipboard in the background
+func attachClipboardCmd(pasteText bool) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+		defer cancel()
+		img, err := images.ReadClipboard(ctx)
+		if err != nil {
+			return attachmentLoadedMsg{err: err, pasteText: pasteText}
+		}
+		return attachmentLoadedMsg{attachment: ui.Attachment{Name: img.Name, Size: int64(len(img.Data)), Image: img}, pasteText: pasteText}
+	}
+}
+
+// loadImages loads the images given with --image
+func loadImages(paths []string) ([]ui.Attachment, error) {
+	var loaded []ui.Attachment
+	for _, path := range paths {
+		img, err := images.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach %s: %w", path, err)
+		}
+		loaded = append(loaded, ui.Attachment{Name: img.Name, Path: path, Size: int64(len(img.Data)), Image: img})
+	}
+	return loaded, nil
+}
+
+// userMessage builds a user message carrying the attached files and images
+func userMessage(content string, attached []ui.Attachment) agent.Message {
+	msg := agent.Message{Role: "user", Content: ui.AttachmentPrompt(content, attached)}
+	for _, att := range attached {
+		if att.Image != nil {
+			msg.Images = append(msg.Images, att.Image.DataURL())
+		}
+	}
+	return msg
+}
+
+// attachmentLabels labels attachments for the chips under a user message
+func attachmentLabels(attached []ui.Attachment) []string {
+	labels := make([]string, len(attached))
+	for i, att := range attached {
+		labels[i] = att.Label()
+	}
+	return labels
+}
+
+// imageChipLabels labels the images of a saved message, whose names are not kept
+func imageChipLabels(dataURLs []string) []string {
+	labels := make([]string, len(dataURLs))
+	for i, url := range dataURLs {
+		mimeType := strings.TrimPrefix(strings.SplitN(url, ";", 2)[0], "data:")
+		labels[i] = fmt.Sprintf("image %d (%s)", i+1, mimeType)
+	}
+	return labels
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/credentials"
+	"github.com/spf13/cobra"
+)
+
+// authCmd creates the command that saves API keys in the OS keychain
+func authCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Save, remove and check the API key of the provider",
+		Long: `Save the API key of the configured provider in the OS keychain: the macOS
+keychain, the Secret Service on Linux (with secret-tool installed) or the
+Windows Credential Manager. Where there is none, the key is saved in
+~/.codex/credentials.enc, encrypted with a key kept in ~/.codex/credentials.key.
+
+A saved key is used when neither the provider's environment variable nor
+api_key in the config sets one. Each provider has its own key, so select the
+provider with --profile or base_url first.
+
+Examples:
+  codex auth login
+  echo "$KEY" | codex auth login --profile mistral
+  codex auth status
+  codex auth logout`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "login",
+		Short: "Save the API key of the provider",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			p := cfg.Provider()
+			if p.NoKey {
+				return fmt.Errorf("%s needs no API key", p.Name)
+			}
+			key, err := readAPIKey(p)
+			if err != nil {
+				return err
+			}
+			check := *cfg
+			check.APIKey = key
+			if err := check.CheckCredentials(); err != nil {
+				return err
+			}
+			store, err := config.OpenCredentials().Set(config.CredentialAccount(p), key)
+			if err != nil {
+				return fmt.Errorf("failed to save the key: %w", err)
+			}
+			fmt.Printf("Saved the %s key in %s\n", p.Name, store.Name())
+			if _, isFile := store.(*credentials.File); isFile {
+				fmt.Println("No OS keychain is available, so the key is in an encrypted file readable by you only.")
+			}
+			if os.Getenv(p.KeyEnv) != "" {
+				fmt.Printf("Note: %s is set and takes precedence over the saved key.\n", p.KeyEnv)
+			}
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "logout",
+		Short: "Remove the saved API key of the provider",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			p := cfg.Provider()
+			if err := config.OpenCredentials().Delete(config.CredentialAccount(p)); err != nil {
+				return fmt.Errorf("failed to remove the key: %w", err)
+			}
+			fmt.Printf("Removed the saved %s key\n", p.Name)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show where the API key of the provider comes from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			p := cfg.Provider()
+			fmt.Printf("Provider: %s (%s)\n", p.Name, cfg.BaseURL)
+			switch {
+			case p.NoKey:
+				fmt.Println("API key: not needed")
+				return nil
+			case cfg.APIKeySource != "":
+				fmt.Printf("API key: saved in %s\n", cfg.APIKeySource)
+			case cfg.APIKey != "" && os.Getenv(p.KeyEnv) == cfg.APIKey:
+				fmt.Printf("API key: from %s\n", p.KeyEnv)
+			case cfg.APIKey != "":
+				fmt.Println("API key: from api_key in the config")
+			default:
+				fmt.Println("API key: none")
+			}
+			if _, store, err := config.OpenCredentials().Get(config.CredentialAccount(p)); err == nil && cfg.APIKeySource == "" {
+				fmt.Printf("A saved key in %s is not used, as the key above takes precedence.\n", store.Name())
+			} else if err != nil && !errors.Is(err, credentials.ErrNotFound) {
+				fmt.Printf("Warning: the saved keys cannot be read: %v\n", err)
+			}
+			return cfg.CheckCredentials()
+		},
+	})
+
+	return cmd
+}
+
+// readAPIKey reads the key of provider p from standard input, without
+// echoing it when that is a terminal
+func readAPIKey(p config.Provider) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read the key from standard input: %w", err)
+		}
+		return strings.TrimSpace(line), nil
+	}
+	if p.KeyURL != "" {
+		fmt.Printf("Create a key at %s\n", p.KeyURL)
+	}
+	fmt.Printf("Paste your %s API key: ", p.Name)
+	key, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the key: %w", err)
+	}
+	return strings.TrimSpace(string(key)), nil
+}
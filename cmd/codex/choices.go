@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// choiceRequestMsg asks the UI to show the agent's question; the index of
+// the option picked, or -1, is sent on reply
+type choiceRequestMsg struct {
+	title   string
+	options []string
+	reply   chan int
+}
+
+// choose implements functions.Chooser for present_choices: it shows the
+// options and blocks until the user picks one
+func (app *App) choose(ctx context.Context, title string, options []string) (int, error) {
+	reply := make(chan int, 1)
+	if !app.sendAgentMsg(choiceRequestMsg{title: title, options: options, reply: reply}) {
+		return -1, errAppClosed
+	}
+
+	select {
+	case index := <-reply:
+		return index, nil
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case <-app.done:
+		return -1, errAppClosed
+	}
+}
+
+// showChoices replaces the chat with the agent's question
+func (app *App) showChoices(msg choiceRequestMsg) {
+	app.Logger.Log("Showing %d choices: %q", len(msg.options), msg.title)
+	app.choiceModel = ui.NewChoiceModel(msg.title, msg.options)
+	app.choiceModel.SetSize(app.width, app.height)
+	app.pendingChoice = msg.reply
+	app.isChoosing = true
+	app.ChatModel.SetThinkingStatus("Waiting for your choice...")
+}
+
+// updateChoice passes input to the question and returns the pick to the
+// engine once the user makes it
+func (app *App) updateChoice(msg tea.Msg) tea.Cmd {
+	if result, ok := msg.(ui.ChoiceResultMsg); ok {
+		app.Logger.Log("Choice made: %d", result.Index)
+		app.isChoosing = false
+		app.pendingChoice <- result.Index // Buffered; the engine is waiting
+		app.pendingChoice = nil
+		app.ChatModel.SetThinkingStatus("Processing your choice...")
+		return nil
+	}
+	var cmd tea.Cmd
+	app.choiceModel, cmd = app.choiceModel.Update(msg)
+	return cmd
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/codereview"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/slash"
+	"github.com/spf13/cobra"
+)
+
+// reviewCmd creates the command that reviews the current branch against a base
+func reviewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "review [flags] [focus]",
+		Short: "Review the changes of the current branch",
+		Long: `Ask the model to review the changes of the current branch since it forked
+from a base branch (git diff base...HEAD), as for a pull request. The diff is
+split into parts that fit --max-tokens, each reviewed on its own, and the
+comments are listed with their file, line, severity and a suggestion, the
+most serious first.
+
+Any arguments are passed to the model as what to focus on. The base defaults
+to the remote's default branch, or main or master.
+
+Examples:
+  codex review
+  codex review --base develop "error handling"
+  codex review --format markdown --output review.md
+  codex review --format json | jq '.comments[] | select(.severity == "error")'`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, _ := cmd.Flags().GetString("base")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			maxTokens, _ := cmd.Flags().GetInt("max-tokens")
+			if format != "text" && format != "json" && format != "markdown" {
+				return fmt.Errorf("invalid --format %q: use text, json or markdown", format)
+			}
+
+			closeLogger := setupLogger(cmd)
+			defer closeLogger()
+
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if err := cfg.CheckCredentials(); err != nil {
+				return err
+			}
+
+			report, err := codereview.Run(cmd.Context(), reviewCompletion(cfg), codereview.Options{
+				Dir:       cfg.CWD,
+				Base:      base,
+				MaxTokens: maxTokens,
+				Guidance:  strings.Join(args, " "),
+				Progress: func(i int, c codereview.Chunk) {
+					fmt.Fprintf(os.Stderr, "Reviewing %s...\n", strings.Join(c.Files, ", "))
+				},
+			})
+			if errors.Is(err, codereview.ErrNoChanges) {
+				fmt.Fprintf(os.Stderr, "Nothing to review: no changes since %s.\n", report.Base)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			var rendered string
+			switch format {
+			case "json":
+				if rendered, err = report.JSON(); err != nil {
+					return err
+				}
+			case "markdown":
+				rendered = report.Markdown()
+			default:
+				rendered = report.Text()
+			}
+			if output == "" {
+				fmt.Print(rendered)
+				return nil
+			}
+			if err := os.WriteFile(output, []byte(rendered), 0644); err != nil {
+				return fmt.Errorf("failed to write the review: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "%s\nWrote the review to %s\n", report.Summary(), output)
+			return nil
+		},
+	}
+
+	cmd.Flags().String("base", "", "The branch to review against (default: the remote's default branch, main or master)")
+	cmd.Flags().String("format", "text", "Output format: text, json or markdown")
+	cmd.Flags().StringP("output", "o", "", "Write the review to a file instead of standard output")
+	cmd.Flags().Int("max-tokens", codereview.DefaultMaxTokens, "The most tokens of diff sent in one request")
+
+	return cmd
+}
+
+// reviewCompletion is chatCompletion with the API timeout applied to each
+// request rather than to the whole review, which may take several
+func reviewCompletion(cfg *config.Config) codereview.Complete {
+	complete := chatCompletion(cfg)
+	timeout := time.Duration(cfg.APITimeout) * time.Second
+	return func(ctx context.Context, system, user string) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return complete(ctx, system, user)
+	}
+}
+
+// reviewDoneMsg reports the end of /review
+type reviewDoneMsg struct {
+	report codereview.Report
+	err    error
+}
+
+// reviewCommand handles /review: the branch is reviewed in the background
+// and the comments are shown in the chat
+func (app *App) reviewCommand(args slash.Args) tea.Cmd {
+	if app.isAgentProcessing {
+		app.ChatModel.AddNotice("The assistant is busy; run /review once it is done.")
+		return nil
+	}
+	base := args.Arg(0)
+	if base == "" {
+		base = codereview.DefaultBase(app.Config.CWD)
+	}
+	app.ChatModel.AddNotice(fmt.Sprintf("Reviewing the changes since %s...", base))
+	app.ChatModel.StartThinking()
+	app.isAgentProcessing = true
+
+	complete := reviewCompletion(app.Config)
+	opts := codereview.Options{Dir: app.Config.CWD, Base: base}
+	return func() tea.Msg {
+		report, err := codereview.Run(context.Background(), complete, opts)
+		return reviewDoneMsg{report: report, err: err}
+	}
+}
+
+// finishCodeReview shows the comments of /review
+func (app *App) finishCodeReview(msg reviewDoneMsg) {
+	app.ChatModel.StopThinking()
+	app.isAgentProcessing = false
+	switch {
+	case errors.Is(msg.err, codereview.ErrNoChanges):
+		app.ChatModel.AddNotice(fmt.Sprintf("Nothing to review: no changes since %s.", msg.report.Base))
+	case msg.err != nil:
+		app.Logger.Log("Review failed: %v", msg.err)
+		app.ChatModel.AddNotice(fmt.Sprintf("Could not review the changes: %v", msg.err))
+	default:
+		app.Logger.Log("Reviewed %d file(s) in %d part(s): %d comment(s)", msg.report.Files, msg.report.Chunks, len(msg.report.Comments))
+		app.ChatModel.AddAssistantMessage(msg.report.Markdown())
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// commandOutputDelay batches the output a command writes in quick succession
+// into one update of the chat
+const commandOutputDelay = 50 * time.Millisecond
+
+// commandOutputMsg tells the Update loop that command output is waiting in
+// the app's commandOutput buffer
+type commandOutputMsg struct{}
+
+// commandOutput receives the output of running shell commands from the
+// executor and forwards it to the chat as it is produced
+type commandOutput struct {
+	app     *App
+	mu      sync.Mutex
+	buf     strings.Builder
+	pending bool // A commandOutputMsg is on its way
+}
+
+// Write buffers p and schedules an update of the chat. It never blocks on
+// the Update loop, so a slow UI cannot stall the command.
+func (w *commandOutput) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if !w.pending {
+		w.pending = true
+		go func() {
+			time.Sleep(commandOutputDelay)
+			w.app.sendAgentMsg(commandOutputMsg{})
+		}()
+	}
+	return len(p), nil
+}
+
+// take returns the output buffered since the last call
+func (w *commandOutput) take() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := w.buf.String()
+	w.buf.Reset()
+	w.pending = false
+	return out
+}
+
+// startCommandMessage shows a command whose output will stream into the chat
+func (app *App) startCommandMessage(command string) {
+	app.ChatModel.StartCommandMessage(command)
+	app.ChatModel.SetThinkingStatus("Running command... (Ctrl+C stops it)")
+	app.ChatModel.ForceUpdateViewport()
+}
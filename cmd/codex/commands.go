@@ -0,0 +1,355 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/scripts"
+	"github.com/epuerta/codex-go/internal/sessions"
+	"github.com/epuerta/codex-go/internal/slash"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// keyHelp follows the commands in the /help text
+const keyHelp = `  Ctrl+C        : Stops the running command, or quits the application.
+  Esc           : Interrupts the assistant while it works, keeping the session.
+  Enter         : Sends your message to the assistant.
+  Tab           : Completes the command or argument highlighted above the input.
+  ↑/↓           : Recalls earlier prompts, including those of past sessions.
+  Ctrl+R        : Searches earlier prompts; Enter sends the match, Esc cancels.
+  @             : Completes repository file paths; a sent @path attaches the file.
+  PgUp/PgDn     : Scrolls the conversation; Ctrl+Home and Ctrl+End jump to its top and bottom.
+  Ctrl+F        : Searches the conversation; n and N go to the match above and below, Esc ends.
+  Ctrl+O        : Expands or collapses the selected long output; Shift+↑/↓ selects another.
+
+Dragging a file into the terminal or pasting its path attaches it too.
+Text files are cut to the first 64 KB; binary files other than images are refused.`
+
+// maxSessionsListed is the number of sessions /sessions shows
+const maxSessionsListed = 10
+
+// newCommands declares the slash commands of the TUI
+func (app *App) newCommands() *slash.Registry {
+	r := slash.NewRegistry()
+	r.Register(slash.Command{
+		Name:        "clear",
+		Description: "Clears the current conversation history.",
+		Run: func(slash.Args) tea.Cmd {
+			app.Agent.ClearHistory()
+			app.repoContextSent = app.Config.DisableProjectDoc // Sent again with the next message
+			app.ChatModel.ClearMessages()
+			app.ChatModel.AddNotice("Chat history cleared.")
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "attach",
+		Aliases:     []string{"image"},
+		Usage:       "<path>",
+		Description: "Attaches a file or image to your next message (/attach clear removes them).",
+		MaxArgs:     -1,
+		Complete:    func() []string { return []string{"clear"} },
+		Run:         app.attachCommand,
+	})
+	r.Register(slash.Command{
+		Name:        "paste",
+		Description: "Attaches the image on the clipboard (also Ctrl+V).",
+		Run: func(slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice("Reading image from clipboard...")
+			return attachClipboardCmd(false)
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "run",
+		Usage:       "[name] [args...]",
+		Description: "Lists the project scripts, or runs one.",
+		MaxArgs:     -1,
+		Complete:    func() []string { return scripts.Names(app.Executor.Scripts) },
+		Run: func(args slash.Args) tea.Cmd {
+			if len(args.Fields) == 0 {
+				app.ChatModel.AddNotice("Project scripts (run one with /run <name> [args...]):\n" + scripts.Summary(app.Executor.Scripts))
+				return nil
+			}
+			app.startCommandMessage(strings.Join(args.Fields, " "))
+			return app.runScriptCmd(args.Fields[0], args.Fields[1:])
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "prompt",
+		Usage:       "[name] [KEY=VALUE...]",
+		Description: "Lists the prompt templates in ~/.codex/prompts, or fills one in and sends it.",
+		MaxArgs:     -1,
+		Complete:    promptNames,
+		Run:         app.promptCommand,
+	})
+	r.Register(slash.Command{
+		Name:        "title",
+		Usage:       "[text]",
+		Description: "Shows the session's title, or renames the session.",
+		MaxArgs:     -1,
+		Run: func(args slash.Args) tea.Cmd {
+			if args.Raw == "" {
+				app.ChatModel.AddNotice(app.sessionTitleSummary())
+			} else {
+				app.rollout().Title = strings.Join(strings.Fields(args.Raw), " ")
+				app.ChatModel.AddNotice(fmt.Sprintf("Session renamed to %q.", app.CurrentRollout.Title))
+			}
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "sessions",
+		Usage:       "[query]",
+		Description: "Lists recent saved sessions, or those matching the query.",
+		MaxArgs:     -1,
+		Run: func(args slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(sessionsSummary(args.Raw))
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "export",
+		Usage:       "[md|html|json] [path]",
+		Description: "Exports this session, with command outputs and diffs, as a markdown, HTML or JSON file.",
+		MaxArgs:     2,
+		Complete:    func() []string { return exportFormats },
+		Run: func(args slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(app.exportSession(args.Arg(0), args.Arg(1)))
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "explore",
+		Usage:       "<task>",
+		Description: "Explores the repository read-only first, condenses it to a summary, then does the task.",
+		MaxArgs:     -1,
+		Run:         app.exploreCommand,
+	})
+	r.Register(slash.Command{
+		Name:        "model",
+		Usage:       "[name]",
+		Description: "Shows the model, or switches to another one for the next messages.",
+		MaxArgs:     1,
+		Complete:    app.cachedModelIDs,
+		Run: func(args slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(app.switchModel(args.Arg(0)))
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "approval",
+		Usage:       "[mode]",
+		Description: "Shows the approval mode, or switches it.",
+		MaxArgs:     1,
+		Complete: func() []string {
+			modes := make([]string, len(config.ApprovalModes))
+			for i, mode := range config.ApprovalModes {
+				modes[i] = string(mode)
+			}
+			return modes
+		},
+		Run: func(args slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(app.switchApproval(args.Arg(0)))
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "dryrun",
+		Usage:       "[on|off]",
+		Description: "Toggles dry-run mode: file changes are shown as diffs but not written.",
+		MaxArgs:     1,
+		Complete:    func() []string { return []string{"on", "off"} },
+		Run: func(args slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(app.switchDryRun(args.Arg(0)))
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "approvals",
+		Usage:       "[revoke <n|all>]",
+		Description: "Lists the calls always allowed in this project, or revokes them.",
+		MaxArgs:     2,
+		Complete:    func() []string { return []string{"revoke"} },
+		Run:         app.approvalsCommand,
+	})
+	r.Register(slash.Command{
+		Name:        "compact",
+		Description: "Replaces the earlier conversation with a summary to free up context.",
+		Run:         app.compactCommand,
+	})
+	r.Register(slash.Command{
+		Name:        "review",
+		Usage:       "[base]",
+		Description: "Reviews the changes of the current branch since the base branch.",
+		MaxArgs:     1,
+		Run:         app.reviewCommand,
+	})
+	r.Register(slash.Command{
+		Name:        "diff",
+		Description: "Shows a diff of the changes made since the session started.",
+		Run: func(slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(app.sessionDiff())
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "map",
+		Description: "Shows the repository map included in the assistant's context.",
+		Run: func(slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(app.repoMapSummary())
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "tools",
+		Description: "Lists the tools the assistant can use and which need your approval.",
+		Run: func(slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(toolsSummary(app.FunctionRegistry, app.Config.ApprovalMode))
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "memory",
+		Description: "Shows the conventions saved to the project memory.",
+		Run: func(slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice(app.memorySummary())
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "stats",
+		Description: "Shows patch statistics for this session.",
+		Run: func(slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice("Patch statistics for this session:\n" + app.PatchMetrics.Format())
+			return nil
+		},
+	})
+	r.Register(slash.Command{
+		Name:        "help",
+		Description: "Shows this help message.",
+		Run: func(slash.Args) tea.Cmd {
+			app.ChatModel.AddNotice("Codex-Go Help:\n" + r.Help() + keyHelp)
+			return nil
+		},
+	})
+	return r
+}
+
+// runCommand dispatches a slash command typed in the chat input
+func (app *App) runCommand(input string) tea.Cmd {
+	app.Logger.Log("User command: %s", input)
+	cmd, err := app.Commands.Dispatch(input)
+	var unknown *slash.UnknownError
+	var usage *slash.UsageError
+	switch {
+	case errors.As(err, &unknown):
+		msg := fmt.Sprintf("Unknown command: /%s.", unknown.Name)
+		if len(unknown.Similar) > 0 {
+			msg += fmt.Sprintf(" Did you mean %s?", strings.Join(unknown.Similar, ", "))
+		}
+		app.ChatModel.AddNotice(msg + " Type /help for the list of commands.")
+	case errors.As(err, &usage):
+		app.ChatModel.AddNotice(fmt.Sprintf("Usage: %s\n%s", usage.Command.Synopsis(), usage.Command.Description))
+	}
+	return cmd
+}
+
+// commandSuggestions lists the completions of the slash commands
+func commandSuggestions(r *slash.Registry) []ui.Suggestion {
+	completions := r.Completions()
+	suggestions := make([]ui.Suggestion, len(completions))
+	for i, c := range completions {
+		suggestions[i] = ui.Suggestion{Value: c.Value, Description: c.Description}
+	}
+	return suggestions
+}
+
+// attachCommand handles /attach and /image
+func (app *App) attachCommand(args slash.Args) tea.Cmd {
+	switch args.Raw {
+	case "":
+		app.ChatModel.AddNotice("Usage: /attach <path> attaches a file or image, /attach clear removes attached files.")
+	case "clear":
+		app.ChatModel.TakeAttachments()
+		app.ChatModel.AddNotice("Removed attached files.")
+	default:
+		path := args.Raw
+		if p := ui.PathFromPaste(args.Raw); p != "" {
+			path = p
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(app.Config.CWD, path)
+		}
+		return attachFileCmd(path)
+	}
+	return nil
+}
+
+// exploreCommand handles /explore
+func (app *App) exploreCommand(args slash.Args) tea.Cmd {
+	task := args.Raw
+	switch {
+	case task == "":
+		app.ChatModel.AddNotice(fmt.Sprintf("Usage: /explore <task> explores the repository with up to %d read-only tool calls, condenses what was found into a summary, then carries out the task.", app.Config.ExploreCalls))
+	case app.isAgentProcessing:
+		app.Logger.Log("WARN: /explore submitted while agent is processing. Ignoring.")
+	default:
+		if r := app.rollout(); r.Title == "" {
+			r.Title = sessions.Title(task)
+		}
+		app.applyReloads()
+		app.ChatModel.AddUserMessage(task)
+		app.ChatModel.AddNotice(fmt.Sprintf("Exploring the repository with up to %d read-only tool calls before starting the task...", app.Config.ExploreCalls))
+		app.ChatModel.StartThinking()
+		app.isFirstAgentChunk = true
+		app.isAgentProcessing = true
+		return app.exploreStreamCmd(task)
+	}
+	return nil
+}
+
+// cachedModelIDs returns the models of the cached listing for /model
+// completions, without asking the provider
+func (app *App) cachedModelIDs() []string {
+	cache, err := modelsCache()
+	if err != nil {
+		return nil
+	}
+	listing, err := cache.Load(app.Config.BaseURL)
+	if err != nil || listing == nil {
+		return nil
+	}
+	return listing.IDs()
+}
+
+// sessionsSummary lists the most recent saved sessions matching query
+func sessionsSummary(query string) string {
+	entries, err := loadSessions()
+	if err != nil {
+		return fmt.Sprintf("Failed to list sessions: %v", err)
+	}
+	if query != "" {
+		entries = sessions.Filter(entries, query)
+	}
+	if len(entries) == 0 {
+		return "No saved sessions found."
+	}
+	var b strings.Builder
+	b.WriteString("Saved sessions, most recent first:\n")
+	for i, e := range entries {
+		if i == maxSessionsListed {
+			fmt.Fprintf(&b, "  ... and %d more; codex sessions lists them all\n", len(entries)-i)
+			break
+		}
+		title := e.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Fprintf(&b, "  %s  %s  %s  %s\n", shortSessionID(e), e.UpdatedAt.Local().Format("2006-01-02 15:04"), e.Repo, title)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/epuerta/codex-go/internal/commitmsg"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/provider"
+	"github.com/epuerta/codex-go/internal/ui"
+	"github.com/sashabaranov/go-openai"
+	"github.com/spf13/cobra"
+)
+
+// commitCmd creates the command that writes a commit message for the staged changes
+func commitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "commit [flags] [hint]",
+		Short: "Write a commit message for the staged changes and commit them",
+		Long: `Ask the model for a Conventional Commits message describing the staged
+changes (git diff --cached), then show it for review. Commit it with y or
+enter, edit it first with e, or cancel with n.
+
+Any arguments are passed to the model as a hint, such as the issue the change
+fixes. Without a terminal, or with --print, the message is printed instead.
+
+Examples:
+  git add -p && codex commit
+  codex commit "mention that this fixes #42"
+  codex commit --yes
+  codex commit --print > msg.txt`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yes, _ := cmd.Flags().GetBool("yes")
+			printOnly, _ := cmd.Flags().GetBool("print")
+
+			closeLogger := setupLogger(cmd)
+			defer closeLogger()
+
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if err := cfg.CheckCredentials(); err != nil {
+				return err
+			}
+			staged, err := commitmsg.StagedChanges(cfg.CWD)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(os.Stderr, "Writing a commit message for:\n"+staged.Stat)
+			ctx, cancel := context.WithTimeout(cmd.Context(), time.Duration(cfg.APITimeout)*time.Second)
+			defer cancel()
+			message, err := commitmsg.Generate(ctx, chatCompletion(cfg), staged, strings.Join(args, " "))
+			if err != nil {
+				return fmt.Errorf("failed to write a commit message: %w", err)
+			}
+
+			if printOnly || (!yes && !term.IsTerminal(os.Stdin.Fd())) {
+				fmt.Println(message)
+				return nil
+			}
+			if !yes {
+				review := ui.NewCommitModel(message, staged.Stat)
+				if _, err := tea.NewProgram(review).Run(); err != nil {
+					return err
+				}
+				if !review.Confirmed {
+					fmt.Fprintln(os.Stderr, "Commit cancelled; the changes are still staged.")
+					return nil
+				}
+				message = review.Message
+			}
+
+			summary, err := commitmsg.Commit(cfg.CWD, message)
+			if err != nil {
+				return err
+			}
+			fmt.Println(summary)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolP("yes", "y", false, "Commit with the generated message without reviewing it")
+	cmd.Flags().Bool("print", false, "Print the message instead of committing")
+
+	return cmd
+}
+
+// chatCompletion asks the configured model for a single reply, without tools.
+// It fits the Complete type of both commitmsg and codereview.
+func chatCompletion(cfg *config.Config) func(ctx context.Context, system, user string) (string, error) {
+	client := provider.NewClient(provider.ChatOptions(cfg))
+	return func(ctx context.Context, system, user string) (string, error) {
+		resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model: cfg.Model,
+			Messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleSystem, Content: system},
+				{Role: openai.ChatMessageRoleUser, Content: user},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 {
+			return "", errors.New("no reply in the response")
+		}
+		return resp.Choices[0].Message.Content, nil
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/slash"
+)
+
+// compactDoneMsg reports the end of /compact
+type compactDoneMsg struct {
+	result agent.CompactResult
+	err    error
+}
+
+// compactCommand handles /compact: the history before the latest turn is
+// replaced by a summary the model writes
+func (app *App) compactCommand(slash.Args) tea.Cmd {
+	if app.isAgentProcessing {
+		app.ChatModel.AddNotice("The assistant is busy; run /compact once it is done.")
+		return nil
+	}
+	app.ChatModel.AddNotice("Summarizing the earlier conversation...")
+	app.ChatModel.StartThinking()
+	app.isAgentProcessing = true
+
+	history := app.Agent.GetHistory()
+	timeout := time.Duration(app.Config.APITimeout) * time.Second
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		result, err := history.Compact(ctx)
+		return compactDoneMsg{result: result, err: err}
+	}
+}
+
+// finishCompact reports how much /compact shrank the history
+func (app *App) finishCompact(msg compactDoneMsg) {
+	app.ChatModel.StopThinking()
+	app.isAgentProcessing = false
+	switch {
+	case errors.Is(msg.err, agent.ErrNothingToCompact):
+		app.ChatModel.AddNotice("Nothing to compact: the conversation has no earlier turns yet.")
+	case msg.err != nil:
+		app.Logger.Log("Compact failed: %v", msg.err)
+		app.ChatModel.AddNotice(fmt.Sprintf("Could not compact the conversation: %v. The history was left as it was.", msg.err))
+	default:
+		r := msg.result
+		app.Logger.Log("Compacted %d messages: about %d -> %d tokens", r.Messages, r.TokensBefore, r.TokensAfter)
+		app.ChatModel.AddNotice(fmt.Sprintf("Replaced %d earlier message(s) with a summary, reclaiming about %d tokens (%d -> %d).\n\n%s",
+			r.Messages, r.TokensBefore-r.TokensAfter, r.TokensBefore, r.TokensAfter, r.Summary))
+	}
+}
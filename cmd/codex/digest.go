@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/stats"
+	"github.com/spf13/cobra"
+)
+
+// digestCmd creates the digest command that reports agent activity per repository
+func digestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "digest",
+		Short: "Summarize agent activity per repository",
+		Long: `Summarize agent activity per repository using saved rollouts and the
+local stats store (~/.codex/stats.jsonl).
+
+The report lists sessions, tasks attempted, files changed, tests run and
+estimated cost for each repository.
+
+Examples:
+  codex digest --since 7d
+  codex digest --since 2w --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceStr, _ := cmd.Flags().GetString("since")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			window, err := stats.ParseSince(sinceStr)
+			if err != nil {
+				return fmt.Errorf("invalid --since value: %w", err)
+			}
+			since := time.Now().Add(-window)
+
+			sessions, err := collectSessionSummaries(since)
+			if err != nil {
+				return err
+			}
+			digests := stats.BuildDigest(sessions)
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(digests)
+			}
+			return stats.WriteDigest(os.Stdout, since, digests)
+		},
+	}
+
+	cmd.Flags().String("since", "7d", "Lookback window, e.g. 7d, 2w or 36h")
+	cmd.Flags().Bool("json", false, "Print the report as JSON")
+
+	return cmd
+}
+
+// rolloutsDir returns the directory where session rollouts are saved
+func rolloutsDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".codex", "rollouts"), nil
+}
+
+// collectSessionSummaries merges rollouts and stats records updated since the given time
+func collectSessionSummaries(since time.Time) ([]stats.SessionSummary, error) {
+	dir, err := rolloutsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	storePath, err := stats.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	records, err := stats.NewStore(storePath).Sessions(since)
+	if err != nil {
+		return nil, err
+	}
+	recordByID := make(map[string]stats.SessionRecord, len(records))
+	for _, r := range records {
+		recordByID[r.SessionID] = r
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollouts: %w", err)
+	}
+
+	var summaries []stats.SessionSummary
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping unreadable rollout %s: %v\n", path, err)
+			continue
+		}
+		var rollout AppRollout
+		if err := json.Unmarshal(data, &rollout); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping invalid rollout %s: %v\n", path, err)
+			continue
+		}
+		if rollout.UpdatedAt.Before(since) {
+			continue
+		}
+
+		summary := summarizeRollout(&rollout)
+		if record, ok := recordByID[rollout.SessionID]; ok {
+			summary.PromptTokens = record.PromptTokens
+			summary.CompletionTokens = record.CompletionTokens
+			if summary.Repo == "" {
+				summary.Repo = record.Repo
+			}
+			if summary.Model == "" {
+				summary.Model = record.Model
+			}
+		}
+		seen[rollout.SessionID] = true
+		summaries = append(summaries, summary)
+	}
+
+	// Sessions whose rollout was deleted still count from the stats store
+	for _, r := range records {
+		if seen[r.SessionID] {
+			continue
+		}
+		summaries = append(summaries, stats.SessionSummary{
+			SessionID:        r.SessionID,
+			Repo:             r.Repo,
+			Model:            r.Model,
+			CreatedAt:        r.StartedAt,
+			Tasks:            r.Tasks,
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+		})
+	}
+
+	return summaries, nil
+}
+
+// summarizeRollout converts a rollout into a digest input
+func summarizeRollout(rollout *AppRollout) stats.SessionSummary {
+	tasks, promptTokens, completionTokens := estimateUsage(rollout.Messages)
+	return stats.SessionSummary{
+		SessionID:        rollout.SessionID,
+		Repo:             rollout.Repo,
+		Model:            rollout.Model,
+		CreatedAt:        rollout.CreatedAt,
+		Tasks:            tasks,
+		CommandsRun:      rollout.CommandsRun,
+		FilesModified:    rollout.FilesModified,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+	}
+}
+
+// estimateUsage counts user tasks and estimates token usage for a conversation.
+// Uses the same 4-characters-per-token heuristic as the conversation history.
+func estimateUsage(messages []agent.Message) (tasks, promptTokens, completionTokens int) {
+	for _, msg := range messages {
+		tokens := int(math.Ceil(float64(len(msg.Content))/4)) + 4
+		switch msg.Role {
+		case "user":
+			tasks++
+			promptTokens += tokens
+		case "assistant":
+			completionTokens += tokens
+		default:
+			promptTokens += tokens
+		}
+	}
+	return tasks, promptTokens, completionTokens
+}
+
+// recordSessionStats appends the usage of a rollout and its patch metrics to the stats store
+func recordSessionStats(rollout *AppRollout, patch *stats.PatchMetrics) error {
+	storePath, err := stats.DefaultPath()
+	if err != nil {
+		return err
+	}
+	tasks, promptTokens, completionTokens := estimateUsage(rollout.Messages)
+	record := stats.SessionRecord{
+		SessionID:        rollout.SessionID,
+		Repo:             rollout.Repo,
+		Model:            rollout.Model,
+		StartedAt:        rollout.CreatedAt,
+		UpdatedAt:        rollout.UpdatedAt,
+		Tasks:            tasks,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+	}
+	if patch != nil && !patch.Empty() {
+		record.Patch = patch
+	}
+	return stats.NewStore(storePath).Append(record)
+}
+
+// repositoryName returns the repository root for dir, or dir itself outside a repository
+func repositoryName(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if root, err := findRepositoryRoot(dir); err == nil {
+		return root
+	}
+	return strings.TrimRight(dir, string(filepath.Separator))
+}
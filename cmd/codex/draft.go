@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/draft"
+	"github.com/epuerta/codex-go/internal/inputhistory"
+)
+
+// quitConfirmWindow is how long a quit key must be pressed again to confirm
+// quitting with a draft or a generation in progress
+const quitConfirmWindow = 3 * time.Second
+
+// quitConfirmExpiredMsg withdraws the request to confirm quitting made at at
+type quitConfirmExpiredMsg struct {
+	at time.Time
+}
+
+// restoreDraft enables draft persistence for the repository and puts back
+// the input left unsent when the previous session in it quit
+func (app *App) restoreDraft() {
+	dir, err := draft.DefaultDir()
+	if err != nil {
+		app.Logger.Log("Warning: drafts disabled: %v", err)
+		return
+	}
+	app.Drafts = draft.NewStore(dir)
+	app.draftRepo = indexRoot(app.Config)
+
+	d, err := app.Drafts.Load(app.draftRepo)
+	if err != nil {
+		app.Logger.Log("Warning: failed to load draft: %v", err)
+		return
+	}
+	if d == nil {
+		return
+	}
+	app.Logger.Log("Restoring draft of %d bytes saved %s", len(d.Text), d.SavedAt)
+	app.ChatModel.SetInputValue(d.Text)
+	app.ChatModel.SetNotice(fmt.Sprintf("Restored your unsent draft from %s", d.SavedAt.Local().Format("Jan 2 15:04")))
+}
+
+// loadInputHistory makes the prompts of this and past sessions, kept in
+// ~/.codex/input_history, recallable in the chat input
+func (app *App) loadInputHistory() {
+	path, err := inputhistory.DefaultPath()
+	if err != nil {
+		app.Logger.Log("Warning: input history disabled: %v", err)
+		return
+	}
+	history, err := inputhistory.Open(path)
+	if err != nil {
+		app.Logger.Log("Warning: input history disabled: %v", err)
+		return
+	}
+	app.ChatModel.SetInputHistory(history)
+}
+
+// saveDraft stores the current input for the next session in the
+// repository, or removes the stored draft if the input is empty
+func (app *App) saveDraft() {
+	if app.Drafts == nil {
+		return
+	}
+	if err := app.Drafts.Save(app.draftRepo, app.ChatModel.InputValue()); err != nil {
+		app.Logger.Log("Warning: failed to save draft: %v", err)
+	}
+}
+
+// confirmQuit reports whether quitting may go ahead. Quitting with a draft or
+// a generation in progress takes a second press within quitConfirmWindow;
+// the first press explains what quitting will do and returns false along
+// with a command withdrawing the request once the window has passed.
+func (app *App) confirmQuit(key string) (bool, tea.Cmd) {
+	var reasons []string
+	if app.isAgentProcessing {
+		reasons = append(reasons, "the assistant is still working and will be stopped")
+	}
+	if app.Drafts != nil && !app.ChatModel.InputIsEmpty() {
+		reasons = append(reasons, "your draft will be restored next time")
+	}
+	if len(reasons) == 0 || (!app.quitRequestedAt.IsZero() && time.Since(app.quitRequestedAt) < quitConfirmWindow) {
+		return true, nil
+	}
+
+	at := time.Now()
+	app.quitRequestedAt = at
+	app.ChatModel.SetNotice(fmt.Sprintf("Press %s again to quit: %s", key, strings.Join(reasons, "; ")))
+	return false, tea.Tick(quitConfirmWindow, func(time.Time) tea.Msg { return quitConfirmExpiredMsg{at: at} })
+}
+
+// cancelQuit withdraws a request to confirm quitting and clears the notice
+func (app *App) cancelQuit() {
+	app.quitRequestedAt = time.Time{}
+	app.ChatModel.SetNotice("")
+}
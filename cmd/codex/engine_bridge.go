@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/redact"
+)
+
+// errAppClosed is returned to the engine when the UI shuts down mid-run
+var errAppClosed = errors.New("application closed")
+
+// engineBridge connects the engine to the Bubble Tea program. It implements
+// engine.Notifier, engine.ToolCallPreviewer, engine.ApprovalRecorder,
+// engine.MessageObserver, engine.DistillationReporter, engine.BlockObserver,
+// engine.RetryObserver, engine.FallbackObserver, engine.RedactionObserver, engine.Approver and engine.Reviewer by forwarding events to the Update loop.
+type engineBridge struct {
+	app *App
+}
+
+func (b *engineBridge) OnMessage(content string) {
+	b.app.sendAgentMsg(engineMessageMsg{content: content})
+}
+
+func (b *engineBridge) OnMessageComplete(content string) {
+	b.app.sendAgentMsg(engineMessageCompleteMsg{content: content})
+}
+
+func (b *engineBridge) OnToolCall(call agent.FunctionCall) {
+	b.app.sendAgentMsg(engineToolCallMsg{call: call})
+}
+
+func (b *engineBridge) OnToolCallPreview(call agent.FunctionCall) {
+	b.app.sendAgentMsg(engineToolCallPreviewMsg{call: call})
+}
+
+func (b *engineBridge) OnToolResult(call agent.FunctionCall, res *executor.Result) {
+	b.app.sendAgentMsg(engineToolResultMsg{call: call, res: res})
+}
+
+func (b *engineBridge) OnToolDenied(call agent.FunctionCall, reason string) {
+	b.app.sendAgentMsg(engineToolDeniedMsg{call: call, reason: reason})
+}
+
+func (b *engineBridge) OnApproval(event agent.ApprovalEvent) {
+	b.app.sendAgentMsg(engineApprovalMsg{event: event})
+}
+
+func (b *engineBridge) OnBlocked(blocked agent.Blocked) {
+	b.app.sendAgentMsg(engineBlockedMsg{blocked: blocked})
+}
+
+func (b *engineBridge) OnRetry(retry agent.StreamRetry) {
+	b.app.sendAgentMsg(engineRetryMsg{retry: retry})
+}
+
+func (b *engineBridge) OnModelFallback(fallback agent.ModelFallback) {
+	b.app.sendAgentMsg(engineFallbackMsg{fallback: fallback})
+}
+
+func (b *engineBridge) OnRedacted(findings []redact.Finding) {
+	b.app.sendAgentMsg(engineRedactedMsg{findings: findings})
+}
+
+func (b *engineBridge) OnContextDistilled(d engine.Distillation) {
+	b.app.sendAgentMsg(engineContextDistilledMsg{distillation: d})
+}
+
+// Approve shows the approval UI and blocks until the user decides
+func (b *engineBridge) Approve(ctx context.Context, call agent.FunctionCall) (bool, error) {
+	decision, err := b.Review(ctx, call)
+	return decision.Approved, err
+}
+
+// Review is Approve for calls the user may change before they run: large
+// patches are reviewed hunk by hunk
+func (b *engineBridge) Review(ctx context.Context, call agent.FunctionCall) (engine.Decision, error) {
+	reply := make(chan engine.Decision, 1)
+	if !b.app.sendAgentMsg(approvalRequestMsg{call: call, reply: reply}) {
+		return engine.Decision{}, errAppClosed
+	}
+
+	select {
+	case decision := <-reply:
+		return decision, nil
+	case <-ctx.Done():
+		return engine.Decision{}, ctx.Err()
+	case <-b.app.done:
+		return engine.Decision{}, errAppClosed
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/contextbudget"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by `codex exec`
+const (
+	exitSuccess    = 0
+	exitTaskFailed = 1 // The agent reported failure or could not complete the request
+	exitToolFailed = 2 // At least one tool execution failed
+)
+
+// execStatusInstructions asks the model to report an explicit outcome we can map to an exit code
+const execStatusInstructions = `You are running non-interactively from a script. Nobody can answer questions, so complete the task using the available tools.
+When you are done, end your final message with a line containing exactly "STATUS: SUCCESS" if the task was completed, or "STATUS: FAILURE: <reason>" if it was not.`
+
+// execCmd creates the exec command for running a task non-interactively
+func execCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec [flags] <task>",
+		Short: "Run a task non-interactively with tool execution enabled",
+		Long: `Run a task without the TUI. The agent runs in full-auto mode: file edits and
+commands are applied without prompting, command output is written to stdout,
+and the final assistant message is printed when the task completes.
+
+Exit codes:
+  0  the task completed and every tool call succeeded
+  1  the agent reported failure or the request could not be completed
+  2  at least one tool execution failed
+
+Examples:
+  codex exec "Run the tests and fix any failures"
+  codex exec -m gpt-4o-mini "Add a .gitignore for Go projects"
+  codex exec --explore "Add request tracing to the HTTP handlers"
+
+With --explore the agent first explores the repository with a bounded number
+of read-only tool calls (explore_calls in the config, default 12), condenses
+what it found into a summary that replaces the raw tool outputs, and only then
+starts the task. This keeps long tasks within the model's context limit.
+
+With branch_isolation set in the config, the task runs on a new branch named
+codex/<task>-<timestamp>. Its changes are committed there, the original branch
+is checked out again, and the commands to merge or discard the branch are
+printed at the end.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(runExec(cmd, strings.Join(args, " ")))
+		},
+	}
+	cmd.Flags().Bool("explore", false, "Explore the repository read-only and condense the findings before starting the task")
+
+	return cmd
+}
+
+// runExec runs the exec command and returns the process exit code
+func runExec(cmd *cobra.Command, task string) int {
+	closeLogger := setupLogger(cmd)
+	defer closeLogger()
+
+	cfg, err := loadConfigFromFlags(cmd)
+	if err != nil {
+		appLogger.Log("Error loading config: %v", err)
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return exitTaskFailed
+	}
+	if err := cfg.CheckCredentials(); err != nil {
+		appLogger.Log("Error: invalid credentials: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitTaskFailed
+	}
+	// exec never prompts, so anything short of the dangerous mode runs as full-auto
+	if cfg.ApprovalMode != config.DangerousAutoApprove {
+		cfg.ApprovalMode = config.FullAuto
+	}
+	appLogger.Log("Exec mode: Model=%s, ApprovalMode=%s, CWD=%s", cfg.Model, cfg.ApprovalMode, cfg.CWD)
+	iso, err := startIsolation(cfg, task)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitTaskFailed
+	}
+	if iso != nil {
+		fmt.Fprintf(os.Stderr, "Working on branch %s\n", iso.Branch)
+		defer func() { fmt.Fprintln(os.Stderr, finishIsolation(iso)) }()
+	}
+
+	ai, err := agent.NewOpenAIAgent(cfg, appLogger)
+	if err != nil {
+		appLogger.Log("Error creating agent: %v", err)
+		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
+		return exitTaskFailed
+	}
+	defer ai.Close()
+	setupResponseCache(cmd, ai, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle OS signals for graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+			appLogger.Log("Exec: cancellation signal received.")
+			fmt.Fprintln(os.Stderr, "\nCancelling...")
+			cancel()
+			ai.Cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	closeTelemetry, err := setupTelemetry(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: telemetry disabled: %v\n", err)
+	}
+	defer closeTelemetry()
+
+	eng, searcher := newHeadlessEngine(ai, cfg)
+	if hook, err := setupWebhook(eng, cfg, uuid.New().String()[:16]); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: webhook disabled: %v\n", err)
+	} else if hook != nil {
+		defer hook.Close()
+	}
+	eng.Executor.Stdout = os.Stdout
+	eng.Executor.Stderr = os.Stderr
+	notifier := &consoleNotifier{commands: os.Stdout, progress: os.Stderr, warnings: os.Stderr}
+
+	messages := []agent.Message{{Role: "system", Content: execStatusInstructions, Source: contextbudget.SystemPrompt}}
+	if msg, ok := semanticContext(ctx, searcher, cfg, task); ok {
+		messages = append(messages, msg)
+	}
+	var outcome *engine.Outcome
+	if explore, _ := cmd.Flags().GetBool("explore"); explore {
+		outcome, err = eng.RunExplore(ctx, task, engine.ExploreOptions{MaxCalls: cfg.ExploreCalls, Context: messages}, nil, notifier)
+	} else {
+		messages = append(messages, agent.Message{Role: "user", Content: task})
+		outcome, err = eng.RunMessages(ctx, messages, nil, notifier)
+	}
+	if err != nil {
+		appLogger.Log("Exec: agent loop failed: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitTaskFailed
+	}
+
+	fmt.Println(outcome.FinalMessage)
+	reportCacheHits(ai)
+
+	switch {
+	case execReportedFailure(outcome.FinalMessage), outcome.Blocked != nil:
+		return exitTaskFailed
+	case outcome.ToolFailures > 0:
+		fmt.Fprintf(os.Stderr, "%d tool execution(s) failed\n", outcome.ToolFailures)
+		return exitToolFailed
+	default:
+		return exitSuccess
+	}
+}
+
+// execReportedFailure reports whether the final message ends with a failure status line
+func execReportedFailure(message string) bool {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	return strings.HasPrefix(strings.ToUpper(last), "STATUS: FAILURE")
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/sessions"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// exportFormats are the formats a session can be exported in
+var exportFormats = []string{"md", "html", "json"}
+
+// transcriptBlock is one entry of an exported transcript: a message, a tool
+// call or result, or a note such as an approval
+type transcriptBlock struct {
+	Heading string
+	Time    time.Time
+	Text    string // Prose, as markdown
+	Code    string // Diff, command line or output shown verbatim
+	Lang    string // Language of Code: "diff", "console" or ""
+	Note    bool   // Whether the block is a note rather than a message
+}
+
+// transcript is a session prepared for export
+type transcript struct {
+	Title     string         `json:"title"`
+	SessionID string         `json:"session_id"`
+	Repo      string         `json:"repo,omitempty"`
+	Model     string         `json:"model,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Events    []RolloutEvent `json:"events"`
+
+	blocks []transcriptBlock
+}
+
+// checkExportFormat reports an error unless format is one of exportFormats
+func checkExportFormat(format string) error {
+	for _, f := range exportFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown export format %q (want one of %s)", format, strings.Join(exportFormats, ", "))
+}
+
+// exportFormatOf returns the format a file name's extension asks for, or ""
+func exportFormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return "md"
+	case ".html", ".htm":
+		return "html"
+	case ".json":
+		return "json"
+	}
+	return ""
+}
+
+// exportTranscript renders a rollout as a markdown, HTML or JSON document:
+// its messages, the commands run with their output and the diffs applied
+func exportTranscript(r *AppRollout, format string) ([]byte, error) {
+	if err := checkExportFormat(format); err != nil {
+		return nil, err
+	}
+	t := &transcript{
+		Title:     r.Title,
+		SessionID: r.SessionID,
+		Repo:      r.Repo,
+		Model:     r.Model,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		Events:    r.Events,
+		blocks:    transcriptBlocks(r.Events),
+	}
+	if t.Title == "" {
+		for _, e := range r.Events {
+			if e.Type == eventUserMessage && e.Content != "" {
+				t.Title = sessions.Title(e.Content)
+				break
+			}
+		}
+	}
+	if t.Title == "" {
+		t.Title = "Untitled session"
+	}
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode transcript: %w", err)
+		}
+		return append(data, '\n'), nil
+	case "html":
+		return t.html()
+	}
+	return t.markdown(), nil
+}
+
+// transcriptBlocks turns the events of a rollout into the blocks of a transcript
+func transcriptBlocks(events []RolloutEvent) []transcriptBlock {
+	var blocks []transcriptBlock
+	for _, e := range events {
+		b := transcriptBlock{Time: e.Time}
+		switch e.Type {
+		case eventUserMessage:
+			b.Heading, b.Text = "User", e.Content
+			if len(e.Attachments) > 0 {
+				b.Text += "\n\nAttached: " + strings.Join(e.Attachments, ", ")
+			}
+		case eventAssistantMessage:
+			b.Heading, b.Text = "Assistant", e.Content
+		case eventToolCall:
+			b.Heading = "Tool call: " + e.Tool
+			args := executor.ApprovalArgs(agent.FunctionCall{ID: e.CallID, Name: e.Tool, Arguments: e.Arguments})
+			switch {
+			case e.Tool == "patch_file":
+				b.Code, b.Lang = strings.TrimRight(ansi.Strip(ui.FormatPatchForDisplay(args)), "\n"), "diff"
+			case executor.IsCommandFunction(e.Tool):
+				b.Code, b.Lang = "$ "+args, "console"
+			default:
+				b.Code = args
+			}
+		case eventApproval:
+			if e.Approval == nil {
+				continue
+			}
+			b.Note, b.Text = true, e.Approval.String()
+		case eventToolResult:
+			b.Heading = "Result: " + e.Tool
+			switch {
+			case e.Command != "" && e.ExitCode != nil:
+				b.Text = fmt.Sprintf("Exited with %d", *e.ExitCode)
+			case e.Command != "":
+				b.Text = "Did not start"
+			case e.Success != nil && !*e.Success:
+				b.Text = "Failed"
+			}
+			if b.Text != "" && e.DurationMs > 0 {
+				b.Text += fmt.Sprintf(" after %s", time.Duration(e.DurationMs)*time.Millisecond)
+			}
+			b.Code = strings.TrimRight(e.Output, "\n")
+			if b.Code == "" {
+				b.Code = "(no output)"
+			}
+		case eventToolDenied:
+			b.Note, b.Text = true, fmt.Sprintf("%s was not run: %s", e.Tool, e.Reason)
+		case eventBlocked:
+			if e.Blocked == nil {
+				continue
+			}
+			b.Note, b.Text = true, e.Blocked.Explain()
+		case eventError:
+			b.Note, b.Text = true, "Error: "+e.Content
+		case eventInterrupted:
+			b.Note, b.Text = true, "Interrupted by the user"
+		default:
+			continue
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// details lists the session, repository, model and start of a transcript
+func (t *transcript) details() [][2]string {
+	var details [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			details = append(details, [2]string{name, value})
+		}
+	}
+	add("Session", t.SessionID)
+	add("Repository", t.Repo)
+	add("Model", t.Model)
+	if !t.CreatedAt.IsZero() {
+		add("Started", t.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return details
+}
+
+// markdown renders the transcript as a markdown document
+func (t *transcript) markdown() []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", t.Title)
+	for _, d := range t.details() {
+		fmt.Fprintf(&sb, "- **%s:** %s\n", d[0], d[1])
+	}
+	for _, b := range t.blocks {
+		sb.WriteString("\n")
+		if b.Note {
+			sb.WriteString("> " + strings.ReplaceAll(b.Text, "\n", "\n> ") + "\n")
+			continue
+		}
+		heading := b.Heading
+		if !b.Time.IsZero() {
+			heading += " · " + b.Time.Local().Format("15:04:05")
+		}
+		sb.WriteString("### " + heading + "\n\n")
+		if b.Text != "" {
+			sb.WriteString(strings.TrimRight(b.Text, "\n") + "\n")
+			if b.Code != "" {
+				sb.WriteString("\n")
+			}
+		}
+		if b.Code != "" {
+			// The fence is longer than any run of backticks in the code
+			fence := "```"
+			for strings.Contains(b.Code, fence) {
+				fence += "`"
+			}
+			sb.WriteString(fence + b.Lang + "\n" + b.Code + "\n" + fence + "\n")
+		}
+	}
+	return []byte(sb.String())
+}
+
+// codeLine is a line of a code block in the HTML transcript
+type codeLine struct {
+	Class string
+	Text  string
+}
+
+// Lines splits a block's code into lines, classing the additions and
+// deletions of a diff
+func (b transcriptBlock) Lines() []codeLine {
+	var lines []codeLine
+	for _, line := range strings.Split(b.Code, "\n") {
+		class := ""
+		if b.Lang == "diff" {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				class = "add"
+			case strings.HasPrefix(line, "-"):
+				class = "del"
+			}
+		}
+		lines = append(lines, codeLine{Class: class, Text: line})
+	}
+	return lines
+}
+
+// Clock returns the time of the block as shown in the HTML transcript
+func (b transcriptBlock) Clock() string {
+	if b.Time.IsZero() {
+		return ""
+	}
+	return b.Time.Local().Format("15:04:05")
+}
+
+// transcriptTemplate is the page of an HTML transcript. It is
+// self-contained, so the file can be shared as is.
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 56rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; line-height: 1.5; }
+dl { display: grid; grid-template-columns: max-content auto; gap: 0.25rem 1rem; color: #59636e; }
+dt { font-weight: 600; }
+dd { margin: 0; }
+section { margin: 1.25rem 0; }
+h3 { font-size: 1rem; margin: 0 0 0.5rem; }
+h3 time { font-weight: normal; color: #59636e; margin-left: 0.5rem; }
+.text { white-space: pre-wrap; }
+.note { border-left: 3px solid #d1d9e0; padding-left: 0.75rem; color: #59636e; white-space: pre-wrap; }
+pre { background: #f6f8fa; padding: 0.75rem; overflow-x: auto; border-radius: 6px; }
+pre span { display: block; }
+.add { background: #dafbe1; }
+.del { background: #ffebe9; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl>
+{{- range .Details}}
+<dt>{{index . 0}}</dt><dd>{{index . 1}}</dd>
+{{- end}}
+</dl>
+{{- range .Blocks}}
+{{- if .Note}}
+<section class="note">{{.Text}}</section>
+{{- else}}
+<section>
+<h3>{{.Heading}}{{with .Clock}}<time>{{.}}</time>{{end}}</h3>
+{{- if .Text}}
+<div class="text">{{.Text}}</div>
+{{- end}}
+{{- if .Code}}
+<pre><code>{{range .Lines}}<span{{with .Class}} class="{{.}}"{{end}}>{{.Text}}</span>{{end}}</code></pre>
+{{- end}}
+</section>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// html renders the transcript as an HTML page
+func (t *transcript) html() ([]byte, error) {
+	var buf bytes.Buffer
+	err := transcriptTemplate.Execute(&buf, map[string]any{
+		"Title":   t.Title,
+		"Details": t.details(),
+		"Blocks":  t.blocks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render transcript: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// exportSession writes the current session to path in format, md unless
+// given, and describes the outcome. A path alone sets the format by its
+// extension; without one the file is named after the session, in the
+// working directory.
+func (app *App) exportSession(format, path string) string {
+	if path == "" && exportFormatOf(format) != "" {
+		format, path = exportFormatOf(format), format
+	}
+	if format == "" {
+		format = "md"
+	}
+	rollout := *app.rollout()
+	rollout.Model = app.Config.Model
+	if rollout.Repo == "" {
+		rollout.Repo = repositoryName(app.Config.CWD)
+	}
+	data, err := exportTranscript(&rollout, format)
+	if err != nil {
+		return fmt.Sprintf("Export failed: %v.", err)
+	}
+
+	if path == "" {
+		path = fmt.Sprintf("codex-session-%s.%s", shortSessionID(sessions.Entry{SessionID: rollout.SessionID}), format)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(app.Config.CWD, path)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Sprintf("Export failed: %v.", err)
+	}
+	return fmt.Sprintf("Exported the session to %s.", path)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/repomap"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// fileRefPattern matches the @path references of a message
+var fileRefPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// repositoryFiles lists the files completed after @ in the chat input: those
+// of the repository, less the ones the ignore setting and .codexignore leave
+// out
+func (app *App) repositoryFiles() []string {
+	root := indexRoot(app.Config)
+	files, err := repomap.ListFiles(root)
+	if err != nil {
+		app.Logger.Log("Failed to list files for completion: %v", err)
+		return nil
+	}
+	return ignoredPaths(app.Config, root).Filter(files)
+}
+
+// resolveFileRef returns the path, relative to the repository root, of the
+// file a reference names, looked up from the root and then the working
+// directory. ok is false if the reference names no file of the repository,
+// or an ignored one, in which case it is left as plain text.
+func (app *App) resolveFileRef(ref string, ignored repomap.Ignore) (abs, rel string, ok bool) {
+	root := indexRoot(app.Config)
+	ref = strings.TrimRight(ref, ".,;:!?)]}'\"`")
+	if ref == "" || filepath.IsAbs(ref) {
+		return "", "", false
+	}
+	for _, dir := range []string{root, app.Config.CWD} {
+		abs := filepath.Join(dir, filepath.FromSlash(ref))
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if ignored(rel) {
+			continue
+		}
+		if info, err := os.Stat(abs); err == nil && info.Mode().IsRegular() {
+			return abs, rel, true
+		}
+	}
+	return "", "", false
+}
+
+// attachFileRefs attaches the files content references as @path to the next
+// message, unless already attached. Files that cannot be attached are
+// reported in notices.
+func (app *App) attachFileRefs(content string) {
+	refs := fileRefPattern.FindAllStringSubmatch(content, -1)
+	if len(refs) == 0 {
+		return
+	}
+	ignored := ignoredPaths(app.Config, indexRoot(app.Config))
+	attached := make(map[string]bool)
+	for _, att := range app.ChatModel.Attachments() {
+		attached[att.Path] = true
+	}
+	for _, ref := range refs {
+		abs, rel, ok := app.resolveFileRef(ref[1], ignored)
+		if !ok || attached[abs] || attached[rel] {
+			continue
+		}
+		attached[rel] = true
+		att, err := ui.LoadAttachment(abs)
+		if err == nil {
+			// The model sees the file by the name it was referenced with
+			att.Name, att.Path = rel, rel
+			err = app.ChatModel.AddAttachment(att)
+		}
+		if err != nil {
+			app.Logger.Log("Failed to attach %s: %v", rel, err)
+			app.ChatModel.AddNotice(fmt.Sprintf("Could not attach %s: %v", rel, err))
+		}
+	}
+}
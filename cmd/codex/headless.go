@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/index"
+	"github.com/epuerta/codex-go/internal/redact"
+	"github.com/epuerta/codex-go/internal/sandbox"
+)
+
+// newHeadlessEngine creates an engine with the core functions and the platform sandbox,
+// along with the searcher of the repository's semantic index if it has one.
+// Headless runs pass a nil approver, so calls the approval mode would require a
+// user to approve are denied, since nobody can be asked.
+func newHeadlessEngine(ai agent.Agent, cfg *config.Config) (*engine.Engine, *index.Searcher) {
+	registry := functions.NewRegistry()
+	exec := executor.New(cfg, sandbox.NewSandbox(), registry, appLogger)
+	registerCoreFunctions(registry, exec.Workspace)
+	setupProjectScripts(exec, cfg)
+	setupInstructions(exec, cfg)
+	searcher := setupSemanticIndex(registry, cfg)
+	setupNetworkTools(registry, cfg)
+	if setter, ok := ai.(toolSourceSetter); ok {
+		setter.SetToolSource(registry)
+	}
+	return engine.New(ai, exec, cfg, appLogger), searcher
+}
+
+// consoleNotifier reports engine progress on plain writers
+type consoleNotifier struct {
+	engine.NopNotifier
+
+	commands io.Writer // Receives "$ command" lines before commands run; may be nil
+	progress io.Writer // Receives notes about tool activity; may be nil
+	warnings io.Writer // Receives safety warnings, such as suspected prompt injection
+}
+
+func (n *consoleNotifier) OnToolCall(call agent.FunctionCall) {
+	appLogger.Log("Headless run: tool call %s (ID: %s)", call.Name, call.ID)
+	switch {
+	case executor.IsCommandFunction(call.Name):
+		if n.commands != nil {
+			fmt.Fprintf(n.commands, "$ %s\n", executor.ApprovalArgs(call))
+		}
+	case call.Name != "patch_file":
+		n.note("%s %s\n", call.Name, call.Arguments)
+	}
+}
+
+func (n *consoleNotifier) OnToolResult(call agent.FunctionCall, res *executor.Result) {
+	for _, patchRes := range res.PatchResults {
+		if patchRes.Success && res.DryRun {
+			n.note("would patch %s (dry run)\n", patchRes.Path)
+		} else if patchRes.Success {
+			n.note("patched %s\n", patchRes.Path)
+		} else {
+			n.note("failed to patch %s: %v\n", patchRes.Path, patchRes.Error)
+		}
+	}
+	if !res.Success && res.PatchResults == nil {
+		n.note("%s failed: %s\n", call.Name, res.Output)
+	}
+	if res.DryRun && res.Diff != "" {
+		n.note("%s", res.Diff)
+	}
+	if len(res.Redactions) > 0 && n.warnings != nil {
+		fmt.Fprintf(n.warnings, "Warning: the %s output contained what look like secrets (%s); they were replaced with placeholders before it was sent to the agent.\n", call.Name, redact.Summary(res.Redactions))
+	}
+	if res.Verdict.Suspicious && n.warnings != nil {
+		fmt.Fprintf(n.warnings, "Warning: the %s output looks like a prompt-injection attempt (%s); it was passed to the agent marked as untrusted.\n", call.Name, res.Verdict.Summary())
+	}
+}
+
+func (n *consoleNotifier) OnToolDenied(call agent.FunctionCall, reason string) {
+	n.note("%s\n", reason)
+}
+
+func (n *consoleNotifier) OnBlocked(blocked agent.Blocked) {
+	if n.warnings != nil {
+		fmt.Fprintf(n.warnings, "Warning: %s\n", blocked.Explain())
+	}
+}
+
+func (n *consoleNotifier) OnRetry(retry agent.StreamRetry) {
+	if n.warnings != nil {
+		fmt.Fprintf(n.warnings, "Warning: %s\n", retry.Explain())
+	}
+}
+
+func (n *consoleNotifier) OnModelFallback(fallback agent.ModelFallback) {
+	if n.warnings != nil {
+		fmt.Fprintf(n.warnings, "Warning: %s\n", fallback.Explain())
+	}
+}
+
+func (n *consoleNotifier) OnRedacted(findings []redact.Finding) {
+	if n.warnings != nil {
+		fmt.Fprintf(n.warnings, "Warning: the prompt contained what look like secrets (%s); they were replaced with placeholders before it was sent to the agent.\n", redact.Summary(findings))
+	}
+}
+
+func (n *consoleNotifier) OnContextDistilled(d engine.Distillation) {
+	n.note("explored with %d read-only call(s); condensed %d message(s) into a summary (about %d -> %d tokens)\n", d.Calls, d.Messages, d.TokensBefore, d.TokensAfter)
+}
+
+// note writes a progress line if a progress writer is configured
+func (n *consoleNotifier) note(format string, args ...interface{}) {
+	if n.progress != nil {
+		fmt.Fprintf(n.progress, format, args...)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/contextbudget"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/ignore"
+	"github.com/epuerta/codex-go/internal/index"
+	"github.com/epuerta/codex-go/internal/repomap"
+	"github.com/spf13/cobra"
+)
+
+// Automatic semantic context: snippets scoring below minContextScore are
+// left out, and the rest are kept within semanticContextBudget bytes
+const (
+	minContextScore       = 0.3
+	semanticContextBudget = 6000
+)
+
+// indexCmd creates the index command that manages the semantic codebase index
+func indexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Manage the semantic codebase index",
+		Long: `Manage the embeddings index of the repository, stored in .codex/index.
+
+Once an index exists, the agent gets a semantic_search tool and the most
+relevant snippets are added to each prompt (see semantic_context_results).
+Rebuilding only embeds files changed since the last build.
+
+Examples:
+  codex index build
+  codex index status`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "build",
+		Short: "Build or update the index for the current repository",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if err := cfg.CheckCredentials(); err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			root := indexRoot(cfg)
+			start := time.Now()
+			ix, stats, err := index.Refresh(ctx, root, index.NewOpenAIEmbedder(cfg), ignoredPaths(cfg, root), func(done, total int) {
+				fmt.Fprintf(os.Stderr, "\rEmbedding chunks: %d/%d", done, total)
+				if done == total {
+					fmt.Fprintln(os.Stderr)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Indexed %s with %s in %v: %d files embedded (%d chunks), %d reused, %d removed\n",
+				root, ix.Model, time.Since(start).Round(time.Millisecond), stats.Embedded, stats.Chunks, stats.Reused, stats.Removed)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show whether the index is up to date",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			root := indexRoot(cfg)
+			ix, err := index.Load(root)
+			if err != nil {
+				return err
+			}
+			if ix == nil {
+				fmt.Printf("No index for %s. Run 'codex index build' to create one.\n", root)
+				return nil
+			}
+			st, err := ix.Status(ignoredPaths(cfg, root))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Index:   %s\n", index.Path(root))
+			fmt.Printf("Model:   %s\n", st.Model)
+			fmt.Printf("Built:   %s\n", st.BuiltAt.Local().Format(time.RFC1123))
+			fmt.Printf("Files:   %d (%d chunks)\n", st.Files, st.Chunks)
+			if !st.Stale() {
+				fmt.Println("Status:  up to date")
+				return nil
+			}
+			fmt.Printf("Status:  stale (%d changed, %d new, %d removed); run 'codex index build' to update\n", st.Changed, st.Added, st.Removed)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// indexRoot returns the repository root containing the working directory,
+// or the working directory itself outside a repository
+func indexRoot(cfg *config.Config) string {
+	if root, err := findRepositoryRoot(cfg.CWD); err == nil {
+		return root
+	}
+	return cfg.CWD
+}
+
+// ignoredPaths reports the paths of root, relative and slash-separated,
+// left out of the index and the repository map: those matching the ignore
+// setting or the .codexignore file
+func ignoredPaths(cfg *config.Config, root string) repomap.Ignore {
+	m, err := ignore.Load(root)
+	if err != nil {
+		appLogger.Log("Warning: %v", err)
+	}
+	return func(rel string) bool { return cfg.Ignored(rel) || m.Match(rel, false) }
+}
+
+// setupSemanticIndex registers semantic_search when the repository has been
+// indexed. It returns nil otherwise.
+func setupSemanticIndex(registry *functions.Registry, cfg *config.Config) *index.Searcher {
+	root := indexRoot(cfg)
+	if _, err := os.Stat(index.Path(root)); err != nil {
+		return nil
+	}
+	appLogger.Log("Found semantic index at %s", index.Path(root))
+
+	searcher := index.NewSearcher(root, index.NewOpenAIEmbedder(cfg))
+	searcher.Ignore = ignoredPaths(cfg, root)
+	registry.RegisterTool(searcher.Tool())
+	return searcher
+}
+
+// semanticContext returns a system message with the indexed snippets most
+// relevant to the user's message, or false if there are none worth adding
+func semanticContext(ctx context.Context, searcher *index.Searcher, cfg *config.Config, query string) (agent.Message, bool) {
+	if searcher == nil || cfg.SemanticContextResults == 0 {
+		return agent.Message{}, false
+	}
+	results, err := searcher.Search(ctx, query, cfg.SemanticContextResults)
+	if err != nil {
+		appLogger.Log("Warning: semantic context search failed: %v", err)
+		return agent.Message{}, false
+	}
+	relevant := results[:0]
+	for _, r := range results {
+		if r.Score >= minContextScore {
+			relevant = append(relevant, r)
+		}
+	}
+	if len(relevant) == 0 {
+		return agent.Message{}, false
+	}
+	appLogger.Log("Adding %d semantic index snippets to the prompt", len(relevant))
+	return agent.Message{
+		Role:    "system",
+		Source:  contextbudget.RetrievedFiles,
+		Content: "Code from the repository's semantic index that may be relevant to the next request (it may be stale; read files before editing them):\n" + index.FormatResults(relevant, semanticContextBudget),
+	}, true
+}
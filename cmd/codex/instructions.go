@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/instructions"
+)
+
+// setupInstructions has exec add the AGENTS.md and codex.md files of the
+// repository's directories to the results of the calls touching their files,
+// unless the project docs are disabled
+func setupInstructions(exec *executor.Executor, cfg *config.Config) {
+	if cfg.DisableProjectDoc {
+		return
+	}
+	root, err := findRepositoryRoot(cfg.CWD)
+	if err != nil {
+		root = cfg.CWD
+	}
+	appLogger.Log("Instruction files below %s go with the first call touching their files (budget %d tokens)", root, cfg.InstructionTokens)
+	exec.Instructions = instructions.NewTracker(root, cfg.InstructionTokens)
+}
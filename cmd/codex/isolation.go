@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/branch"
+	"github.com/epuerta/codex-go/internal/config"
+)
+
+// startIsolation moves an unattended run onto a branch of its own when
+// branch_isolation is set and the run needs no approval for changes. It
+// returns nil when the run stays on the current branch.
+func startIsolation(cfg *config.Config, task string) (*branch.Isolation, error) {
+	if !cfg.BranchIsolation || (cfg.ApprovalMode != config.FullAuto && cfg.ApprovalMode != config.DangerousAutoApprove) {
+		return nil, nil
+	}
+	iso, err := branch.Start(cfg.CWD, task, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("branch isolation: %w", err)
+	}
+	appLogger.Log("Branch isolation: running on %s (from %s)", iso.Branch, iso.Original)
+	return iso, nil
+}
+
+// finishIsolation commits the run's changes to its branch, checks out the
+// original branch again and returns the lines telling the user how to
+// merge or discard the branch
+func finishIsolation(iso *branch.Isolation) string {
+	res, err := iso.Finish()
+	if err != nil {
+		appLogger.Log("Branch isolation: finishing %s failed: %v", iso.Branch, err)
+		return fmt.Sprintf("Branch isolation: %v\nThe changes are on %s; check out %s yourself once they are committed.", err, iso.Branch, iso.Original)
+	}
+	appLogger.Log("Branch isolation: finished %s (committed: %v)", iso.Branch, res.Committed)
+	return res.Instructions()
+}
@@ -2,11 +2,9 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
@@ -17,7 +15,9 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/epuerta/codex-go/internal/agent"
 	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/contextbudget"
 	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/epuerta/codex-go/internal/respcache"
 	"github.com/epuerta/codex-go/internal/ui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -32,7 +32,7 @@ var (
 	BuildDate = "unknown"
 
 	// Logger instance - global within main package for simplicity
-	appLogger logging.Logger
+	appLogger logging.Logger = logging.NewNilLogger() // Replaced by runCmdImpl; subcommands log nowhere
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -46,8 +46,13 @@ and execute commands - all via natural language.
 Examples:
   codex "Write a Go function to parse JSON"
   codex "Explain this codebase to me"
-  codex --approval-mode full-auto "Create a CLI tool that converts markdown to HTML"`,
+  codex --approval-mode full-auto "Create a CLI tool that converts markdown to HTML"
+  codex --prompt release-notes VERSION=v1.2`,
 	Args: cobra.ArbitraryArgs,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("cd")
+		return changeDirectory(dir)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// Call the run implementation directly
 		runCmdImpl(cmd, args)
@@ -58,17 +63,23 @@ Examples:
 func init() {
 	// Add global flags using cobra/pflag
 	rootCmd.PersistentFlags().StringP("model", "m", "gpt-4o", "AI model to use for completions")
+	rootCmd.PersistentFlags().StringP("cd", "C", "", "Run as if codex was started in this directory")
+	rootCmd.PersistentFlags().StringP("profile", "p", "", "Config profile to use (see profiles in ~/.codex/config.yaml)")
 	rootCmd.PersistentFlags().StringP("approval-mode", "a", "suggest", "Approval mode: suggest, auto-edit, or full-auto")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Non-interactive mode that only prints the assistant's final output")
 	rootCmd.PersistentFlags().StringArrayP("image", "i", nil, "Path to image file(s) to include as input")
-	rootCmd.PersistentFlags().Bool("no-project-doc", false, "Do not automatically include the repository's 'codex.md'")
+	rootCmd.PersistentFlags().Bool("no-project-doc", false, "Do not automatically include the repository's AGENTS.md and codex.md files")
 	rootCmd.PersistentFlags().String("project-doc", "", "Include an additional markdown file as context")
-	rootCmd.PersistentFlags().Bool("full-stdout", false, "Do not truncate stdout/stderr from command outputs")
+	rootCmd.PersistentFlags().Bool("full-stdout", false, "Do not truncate stdout/stderr from command outputs in the chat")
+	rootCmd.PersistentFlags().Int("timeout", 0, "Seconds shell commands may run before they are stopped (default: command_timeout, 30)")
 	rootCmd.PersistentFlags().Bool("auto-edit", false, "Automatically approve file edits; still prompt for commands")
 	rootCmd.PersistentFlags().Bool("full-auto", false, "Automatically approve edits and commands when executed in the sandbox")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Show the diffs of file changes instead of writing them; commands still run")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Send every request to the model, even with response_cache set in the config")
 	rootCmd.PersistentFlags().Bool("dangerously-auto-approve-everything", false, "Skip all confirmation prompts and execute commands without sandboxing. EXTREMELY DANGEROUS - use only in ephemeral environments.")
 	rootCmd.PersistentFlags().BoolP("config", "c", false, "Open the instructions file in your editor")
 	rootCmd.PersistentFlags().StringP("view", "v", "", "Inspect a previously saved rollout instead of starting a session")
+	rootCmd.Flags().String("prompt", "", "Start with the template ~/.codex/prompts/<name>.md, filled in with the arguments (KEY=VALUE...)")
 
 	// Add logging flags
 	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug logging to a file")
@@ -78,7 +89,34 @@ func init() {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
 	// Add subcommands
+	rootCmd.AddCommand(authCmd())
+	rootCmd.AddCommand(commitCmd())
 	rootCmd.AddCommand(completionCmd())
+	rootCmd.AddCommand(digestCmd())
+	rootCmd.AddCommand(execCmd())
+	rootCmd.AddCommand(indexCmd())
+	rootCmd.AddCommand(modelsCmd())
+	rootCmd.AddCommand(replayCmd())
+	rootCmd.AddCommand(reviewCmd())
+	rootCmd.AddCommand(serveCmd())
+	rootCmd.AddCommand(sessionsCmd())
+}
+
+// changeDirectory makes dir the working directory, as if codex had been
+// started there (-C). Config files, the repository context, commands and
+// relative paths then all start from it.
+func changeDirectory(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("cannot change to the directory: %w", err)
+	}
+	// Commands inherit PWD, which shells trust when it names their directory
+	if cwd, err := os.Getwd(); err == nil {
+		os.Setenv("PWD", cwd)
+	}
+	return nil
 }
 
 // completionCmd creates the completion command for shell completion scripts
@@ -118,60 +156,15 @@ Fish:
 // runCmdImpl implements the run command functionality
 func runCmdImpl(cmd *cobra.Command, args []string) {
 	// Get flags
-	model, _ := cmd.Flags().GetString("model")
-	approvalModeStr, _ := cmd.Flags().GetString("approval-mode")
 	quiet, _ := cmd.Flags().GetBool("quiet")
-	noProjectDoc, _ := cmd.Flags().GetBool("no-project-doc")
-	projectDoc, _ := cmd.Flags().GetString("project-doc")
-	fullStdout, _ := cmd.Flags().GetBool("full-stdout")
-	autoEdit, _ := cmd.Flags().GetBool("auto-edit")
-	fullAuto, _ := cmd.Flags().GetBool("full-auto")
-	dangerouslyAutoApprove, _ := cmd.Flags().GetBool("dangerously-auto-approve-everything")
 	configFlag, _ := cmd.Flags().GetBool("config")
 	viewRollout, _ := cmd.Flags().GetString("view")
 	images, _ := cmd.Flags().GetStringArray("image")
-	// Get logging flags
-	debugFlag, _ := cmd.Flags().GetBool("debug")
-	logFileFlag, _ := cmd.Flags().GetString("log-file")
+	promptName, _ := cmd.Flags().GetString("prompt")
 
 	// --- Initialize Logger FIRST ---
-	var err error
-	if debugFlag {
-		logPath := logFileFlag
-		if logPath == "" {
-			// Determine default log path
-			cacheDir, err := os.UserCacheDir()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Could not get user cache directory: %v. Logging to current dir.\n", err)
-				cacheDir = "."
-			}
-			logDir := filepath.Join(cacheDir, "codex-go", "logs")
-			logFile := fmt.Sprintf("codex-go-%s.log", time.Now().Format("20060102-150405"))
-			logPath = filepath.Join(logDir, logFile)
-		}
-		appLogger, err = logging.NewFileLogger(logPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating file logger: %v\n", err)
-			os.Exit(1)
-		}
-		// Ensure logger is closed on exit
-		defer func() {
-			if appLogger != nil {
-				if closeErr := appLogger.Close(); closeErr != nil {
-					fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", closeErr)
-				}
-			}
-		}()
-
-		// Optional: Add symlink logic here
-		createLatestLogSymlink(logPath)
-
-		appLogger.Log("--- Codex-Go Session Start --- Version: %s, Commit: %s, Built: %s", Version, GitCommit, BuildDate)
-		appLogger.Log("Debug logging enabled. Log file: %s", logPath)
-	} else {
-		appLogger = logging.NewNilLogger()
-	}
-	// --- End Logger Initialization ---
+	closeLogger := setupLogger(cmd)
+	defer closeLogger()
 
 	// Check if we need to open the config
 	if configFlag {
@@ -186,57 +179,22 @@ func runCmdImpl(cmd *cobra.Command, args []string) {
 	}
 
 	// Load config
-	cfg, err := config.Load()
+	cfg, err := loadConfigFromFlags(cmd)
 	if err != nil {
 		appLogger.Log("Error loading config: %v", err) // Use logger
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Override config with flags
-	if model != "" {
-		cfg.Model = model
-	}
-	// Set logging config AFTER loading base config but before using it
-	cfg.Debug = debugFlag
-	cfg.LogFile = logFileFlag // Store the *flag* value, logger uses resolved path
-
-	// Set approval mode based on flags in order of priority
-	if dangerouslyAutoApprove {
-		cfg.ApprovalMode = config.DangerousAutoApprove
-	} else if fullAuto {
-		cfg.ApprovalMode = config.FullAuto
-	} else if autoEdit {
-		cfg.ApprovalMode = config.AutoEdit
-	} else if approvalModeStr != "" {
-		switch strings.ToLower(approvalModeStr) {
-		case "suggest":
-			cfg.ApprovalMode = config.Suggest
-		case "auto-edit":
-			cfg.ApprovalMode = config.AutoEdit
-		case "full-auto":
-			cfg.ApprovalMode = config.FullAuto
-		case "dangerous":
-			cfg.ApprovalMode = config.DangerousAutoApprove
-		default:
-			appLogger.Log("Invalid approval mode: %s. Using 'suggest'.", approvalModeStr) // Use logger
-			fmt.Fprintf(os.Stderr, "Invalid approval mode: %s. Using 'suggest'.\n", approvalModeStr)
-		}
-	}
-
-	// Set full stdout option
-	cfg.FullStdout = fullStdout
+	appLogger.Log("Config loaded: Model=%s, ApprovalMode=%s, CWD=%s", cfg.Model, cfg.ApprovalMode, cfg.CWD)
 
-	// Override project doc settings
-	if noProjectDoc {
-		cfg.DisableProjectDoc = true
-	}
-	if projectDoc != "" {
-		cfg.ProjectDocPath = projectDoc
+	// Check the key before the TUI takes over the terminal
+	if err := cfg.CheckCredentials(); err != nil {
+		appLogger.Log("Error: invalid credentials: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	appLogger.Log("Config loaded: Model=%s, ApprovalMode=%s, CWD=%s", cfg.Model, cfg.ApprovalMode, cfg.CWD)
-
 	// Create agent
 	ai, err := agent.NewOpenAIAgent(cfg, appLogger)
 	if err != nil {
@@ -248,7 +206,15 @@ func runCmdImpl(cmd *cobra.Command, args []string) {
 
 	// Get prompt from args
 	var prompt string
-	if len(args) > 0 {
+	if promptName != "" {
+		// The arguments fill in the template rather than being the prompt
+		prompt, err = expandPrompt(context.Background(), cfg, promptName, args)
+		if err != nil {
+			appLogger.Log("Error expanding prompt %s: %v", promptName, err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if len(args) > 0 {
 		prompt = strings.Join(args, " ")
 	}
 
@@ -260,7 +226,8 @@ func runCmdImpl(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 
-		runQuietMode(ai, prompt, cfg)
+		setupResponseCache(cmd, ai, cfg)
+		runQuietMode(ai, prompt, cfg, images)
 		return
 	}
 
@@ -268,9 +235,153 @@ func runCmdImpl(cmd *cobra.Command, args []string) {
 	runInteractiveMode(ai, prompt, cfg, images)
 }
 
+// setupLogger initializes the global logger from the --debug and --log-file flags.
+// The returned function closes the logger and should be deferred by the caller.
+func setupLogger(cmd *cobra.Command) func() {
+	debugFlag, _ := cmd.Flags().GetBool("debug")
+	logFileFlag, _ := cmd.Flags().GetString("log-file")
+
+	if !debugFlag {
+		appLogger = logging.NewNilLogger()
+		return func() {}
+	}
+
+	logPath := logFileFlag
+	if logPath == "" {
+		// Determine default log path
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not get user cache directory: %v. Logging to current dir.\n", err)
+			cacheDir = "."
+		}
+		logDir := filepath.Join(cacheDir, "codex-go", "logs")
+		logFile := fmt.Sprintf("codex-go-%s.log", time.Now().Format("20060102-150405"))
+		logPath = filepath.Join(logDir, logFile)
+	}
+
+	var err error
+	appLogger, err = logging.NewFileLogger(logPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating file logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Optional: Add symlink logic here
+	createLatestLogSymlink(logPath)
+
+	appLogger.Log("--- Codex-Go Session Start --- Version: %s, Commit: %s, Built: %s", Version, GitCommit, BuildDate)
+	appLogger.Log("Debug logging enabled. Log file: %s", logPath)
+
+	// Ensure logger is closed on exit
+	return func() {
+		if appLogger != nil {
+			if closeErr := appLogger.Close(); closeErr != nil {
+				fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", closeErr)
+			}
+		}
+	}
+}
+
+// loadConfigFromFlags loads the config and applies command-line overrides
+func loadConfigFromFlags(cmd *cobra.Command) (*config.Config, error) {
+	model, _ := cmd.Flags().GetString("model")
+	profile, _ := cmd.Flags().GetString("profile")
+	approvalModeStr, _ := cmd.Flags().GetString("approval-mode")
+	noProjectDoc, _ := cmd.Flags().GetBool("no-project-doc")
+	projectDoc, _ := cmd.Flags().GetString("project-doc")
+	fullStdout, _ := cmd.Flags().GetBool("full-stdout")
+	timeout, _ := cmd.Flags().GetInt("timeout")
+	autoEdit, _ := cmd.Flags().GetBool("auto-edit")
+	fullAuto, _ := cmd.Flags().GetBool("full-auto")
+	dangerouslyAutoApprove, _ := cmd.Flags().GetBool("dangerously-auto-approve-everything")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	debugFlag, _ := cmd.Flags().GetBool("debug")
+	logFileFlag, _ := cmd.Flags().GetString("log-file")
+
+	cfg, err := config.LoadProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Profile != "" {
+		appLogger.Log("Using profile %s: model %s at %s", cfg.Profile, cfg.Model, cfg.BaseURL)
+	}
+	if cfg.ProjectConfigPath != "" {
+		appLogger.Log("Merged project config %s", cfg.ProjectConfigPath)
+	}
+	if cfg.APIKeySource != "" {
+		appLogger.Log("Using the API key saved in %s", cfg.APIKeySource)
+	}
+	for _, warning := range cfg.ProjectConfigWarnings {
+		appLogger.Log("Warning: %s", warning)
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	// Override config with flags. The model and approval mode flags have
+	// defaults, so they only override the config files when given.
+	if model != "" && cmd.Flags().Changed("model") {
+		cfg.Model = model
+		if err := checkModel(cfg); err != nil {
+			return nil, err
+		}
+	}
+	// Set logging config AFTER loading base config but before using it
+	cfg.Debug = debugFlag
+	cfg.LogFile = logFileFlag // Store the *flag* value, logger uses resolved path
+
+	// Set approval mode based on flags in order of priority
+	if dangerouslyAutoApprove {
+		cfg.ApprovalMode = config.DangerousAutoApprove
+	} else if fullAuto {
+		cfg.ApprovalMode = config.FullAuto
+	} else if autoEdit {
+		cfg.ApprovalMode = config.AutoEdit
+	} else if approvalModeStr != "" && cmd.Flags().Changed("approval-mode") {
+		if mode, err := config.ParseApprovalMode(approvalModeStr); err == nil {
+			cfg.ApprovalMode = mode
+		} else {
+			appLogger.Log("Invalid approval mode: %s. Using 'suggest'.", approvalModeStr) // Use logger
+			fmt.Fprintf(os.Stderr, "Invalid approval mode: %s. Using 'suggest'.\n", approvalModeStr)
+		}
+	}
+
+	// The flag can only turn full output on; full_stdout in the config also does
+	if fullStdout {
+		cfg.FullStdout = true
+	}
+	if dryRun {
+		cfg.DryRun = true
+	}
+
+	if timeout < 0 {
+		return nil, fmt.Errorf("invalid --timeout %d: it must be positive", timeout)
+	}
+	if timeout > 0 {
+		cfg.CommandTimeout = timeout
+		// Longer timeouts the agent asks for are still allowed up to the larger of the two
+		cfg.MaxCommandTimeout = max(cfg.MaxCommandTimeout, timeout)
+	}
+
+	// Override project doc settings
+	if noProjectDoc {
+		cfg.DisableProjectDoc = true
+	}
+	if projectDoc != "" {
+		cfg.ProjectDocPath = projectDoc
+	}
+
+	return cfg, nil
+}
+
 // runQuietMode runs the agent in quiet mode with a prompt
-func runQuietMode(ai *agent.OpenAIAgent, prompt string, cfg *config.Config) {
+func runQuietMode(ai *agent.OpenAIAgent, prompt string, cfg *config.Config, images []string) {
 	appLogger.Log("Running in quiet mode with prompt: %s", prompt)
+	attached, err := loadImages(images)
+	if err != nil {
+		appLogger.Log("Error loading images: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -289,42 +400,73 @@ func runQuietMode(ai *agent.OpenAIAgent, prompt string, cfg *config.Config) {
 	// Create messages including system prompt
 	messages := []agent.Message{}
 	if cfg.Instructions != "" {
-		messages = append(messages, agent.Message{Role: "system", Content: cfg.Instructions})
+		messages = append(messages, agent.Message{Role: "system", Content: cfg.Instructions, Source: contextbudget.SystemPrompt})
 	}
-	messages = append(messages, agent.Message{Role: "user", Content: prompt})
 
-	// Send message and collect response
-	var finalResponse string
-
-	handler := func(itemJSON string) {
-		appLogger.Log("Quiet mode received item: %s", itemJSON) // Use logger
-		// Unmarshal
-		var item agent.ResponseItem
-		if err := json.Unmarshal([]byte(itemJSON), &item); err != nil {
-			appLogger.Log("[ERROR] Quiet mode failed to unmarshal response: %v", err) // Use logger
-			fmt.Fprintf(os.Stderr, "[ERROR] Quiet mode failed to unmarshal response: %v\n", err)
-			return
-		}
+	iso, err := startIsolation(cfg, prompt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-		if item.Type == "message" && item.Message != nil && item.Message.Role == "assistant" {
-			// Content in each item is the full message so far.
-			finalResponse = item.Message.Content
-		}
-		// We don't print streamed parts in quiet mode, just collect the final full message.
+	closeTelemetry, err := setupTelemetry(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: telemetry disabled: %v\n", err)
 	}
+	defer closeTelemetry()
 
-	_, err := ai.SendMessage(ctx, messages, handler)
+	// Run the agent loop, executing tool calls the approval mode allows
+	eng, searcher := newHeadlessEngine(ai, cfg)
+	if msg, ok := semanticContext(ctx, searcher, cfg, prompt); ok {
+		messages = append(messages, msg)
+	}
+	messages = append(messages, userMessage(prompt, attached))
+	outcome, err := eng.RunMessages(ctx, messages, nil, &consoleNotifier{warnings: os.Stderr})
+	if iso != nil {
+		// Leave the branch whether or not the run succeeded
+		fmt.Fprintln(os.Stderr, finishIsolation(iso))
+	}
 	if err != nil {
-		appLogger.Log("Error sending message in quiet mode: %v", err) // Use logger
+		appLogger.Log("Error running agent in quiet mode: %v", err) // Use logger
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Print final response after the stream completes
-	fmt.Println(finalResponse)
+	fmt.Println(outcome.FinalMessage)
+	reportCacheHits(ai)
 	appLogger.Log("Quiet mode finished.") // Use logger
 }
 
+// setupResponseCache makes ai answer requests it has cached before, when
+// response_cache is set and --no-cache is not. Expired responses are
+// removed first.
+func setupResponseCache(cmd *cobra.Command, ai *agent.OpenAIAgent, cfg *config.Config) {
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache || !cfg.ResponseCache {
+		return
+	}
+	dir, err := respcache.DefaultDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: response cache disabled: %v\n", err)
+		return
+	}
+	cache := respcache.New(dir, time.Duration(cfg.ResponseCacheTTL)*time.Second)
+	if n, err := cache.Prune(); err != nil {
+		appLogger.Log("Failed to prune the response cache: %v", err)
+	} else if n > 0 {
+		appLogger.Log("Removed %d expired response(s) from the cache", n)
+	}
+	ai.SetResponseCache(cache)
+}
+
+// reportCacheHits notes on standard error how many responses came from the
+// response cache, so a stale answer is not mistaken for a new one
+func reportCacheHits(ai *agent.OpenAIAgent) {
+	if n := ai.CacheHits(); n > 0 {
+		fmt.Fprintf(os.Stderr, "%d response(s) came from the response cache; run with --no-cache to ask the model again\n", n)
+	}
+}
+
 // openConfigInEditor opens the instructions file in the user's editor
 func openConfigInEditor() {
 	// Get config directory
@@ -362,16 +504,7 @@ Always explain what you're doing before making changes.
 	}
 
 	// Open the file in the user's editor
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		if runtime.GOOS == "windows" {
-			editor = "notepad"
-		} else {
-			editor = "vi"
-		}
-	}
-
-	cmd := exec.Command(editor, instructionsPath)
+	cmd := ui.EditorCommand(instructionsPath)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -420,8 +553,8 @@ func viewSavedRollout(rolloutPath string) {
 	}
 
 	// Add a system message to indicate this is a view-only session
-	app.ChatModel.AddSystemMessage(fmt.Sprintf("Viewing session from %s (read-only)",
-		app.CurrentRollout.CreatedAt.Format("Jan 2, 2006 15:04")))
+	app.ChatModel.AddNotice(fmt.Sprintf("Viewing session from %s (read-only)\n\n%s",
+		app.CurrentRollout.CreatedAt.Format("Jan 2, 2006 15:04"), app.CurrentRollout.auditSummary()))
 
 	// Create and run the program in view-only mode
 	p := tea.NewProgram(app, tea.WithAltScreen())
@@ -445,11 +578,29 @@ func runInteractiveMode(ai *agent.OpenAIAgent, initialPrompt string, cfg *config
 		os.Exit(1)
 	}
 
-	// Handle images if provided
-	// ... (image handling logic - needs logger integration if errors occur)
+	// Show what the session runs with before the first tool does
+	app.showStartupSummary()
+
+	// Put back the input left unsent by the last session in this repository
+	app.restoreDraft()
+	app.loadInputHistory()
+
+	// Attach --image files to the first message
+	attached, err := loadImages(images)
+	if err != nil {
+		appLogger.Log("Error loading images: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, att := range attached {
+		if err := app.ChatModel.AddAttachment(att); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Create Bubble Tea program
-	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithReportFocus())
 
 	// Start the program
 	app.IsRunning = true
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/memory"
+)
+
+// setupMemory registers remember for the memory of the repository holding
+// the working directory, or of the working directory outside a repository
+func setupMemory(registry *functions.Registry, cfg *config.Config) *memory.Memory {
+	root, err := findRepositoryRoot(cfg.CWD)
+	if err != nil {
+		root = cfg.CWD
+	}
+	m := memory.New(root)
+	appLogger.Log("Registering %s for %s", memory.ToolName, m.Path)
+	registry.RegisterTool(m.Tool())
+	return m
+}
+
+// memorySummary lists the notes of the project memory for /memory
+func (app *App) memorySummary() string {
+	notes, err := app.Memory.Notes()
+	if err != nil {
+		return fmt.Sprintf("Failed to read %s: %v", app.Memory.Path, err)
+	}
+	if len(notes) == 0 {
+		return fmt.Sprintf("No project memory yet. The assistant offers to save conventions it learns to %s, and you can edit that file yourself.", app.Memory.Path)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Project memory (%s), loaded at the start of each session:\n", app.Memory.Path)
+	for _, note := range notes {
+		b.WriteString("  - " + note + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// modelCheckTimeout bounds how long checking the -m flag may wait for the provider
+const modelCheckTimeout = 5 * time.Second
+
+// modelsCmd creates the command that lists the provider's models
+func modelsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "models",
+		Short: "List the models the configured provider serves",
+		Long: `List the models served at the configured base_url, with the context size
+and whether they support tools and images, where known.
+
+The listing is cached in ~/.codex/models for a day, and the cached one is
+shown when the provider cannot be reached. The -m flag is checked against it.
+
+Examples:
+  codex models
+  codex models --refresh
+  codex models --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			refresh, _ := cmd.Flags().GetBool("refresh")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			cfg, err := loadConfigFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if err := cfg.CheckCredentials(); err != nil {
+				return err
+			}
+			cache, err := modelsCache()
+			if err != nil {
+				return err
+			}
+			listing, err := cache.List(cmd.Context(), cfg.BaseURL, models.NewOpenAIFetcher(cfg), refresh)
+			if listing == nil {
+				return err
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+
+			if asJSON {
+				type entry struct {
+					models.Model
+					ContextWindow int  `json:"context_window,omitempty"`
+					Tools         bool `json:"tools"`
+					Vision        bool `json:"vision"`
+					Known         bool `json:"known"`
+				}
+				entries := make([]entry, len(listing.Models))
+				for i, m := range listing.Models {
+					caps, known := models.Lookup(m.ID)
+					entries[i] = entry{Model: m, ContextWindow: caps.ContextWindow, Tools: caps.Tools, Vision: caps.Vision, Known: known}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "MODEL\tCONTEXT\tTOOLS\tVISION")
+			for _, m := range listing.Models {
+				window, tools, vision := "?", "?", "?"
+				if caps, ok := models.Lookup(m.ID); ok {
+					window = fmt.Sprintf("%dk", caps.ContextWindow/1000)
+					tools, vision = yesNo(caps.Tools), yesNo(caps.Vision)
+				}
+				marker := ""
+				if m.ID == cfg.Model {
+					marker = " *"
+				}
+				fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", m.ID, marker, window, tools, vision)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Printf("\n%d models at %s, listed %s", len(listing.Models), cfg.Provider().Name, listing.FetchedAt.Local().Format("2006-01-02 15:04"))
+			if listing.Stale {
+				fmt.Print(" (the provider could not be reached; this is the cached listing)")
+			}
+			fmt.Println(". * marks the configured model; ? marks models whose capabilities are unknown.")
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("refresh", false, "Ask the provider again instead of using the cached listing")
+	cmd.Flags().Bool("json", false, "Print the models as JSON")
+	return cmd
+}
+
+// yesNo renders a capability for the models table
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// modelsCache opens the model listings cache in the default directory
+func modelsCache() (*models.Cache, error) {
+	dir, err := models.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return models.NewCache(dir), nil
+}
+
+// checkModel checks a model given with -m against the provider's listing
+// and suggests close names when it is not there. The check is skipped when
+// no listing can be had, so being offline never stops a session.
+func checkModel(cfg *config.Config) error {
+	if caps, ok := models.Lookup(cfg.Model); ok && !caps.Tools {
+		fmt.Fprintf(os.Stderr, "Warning: %s does not support function calling, so the assistant cannot read files or run commands.\n", cfg.Model)
+	}
+	if cfg.CheckCredentials() != nil {
+		return nil // Reported where the key is needed
+	}
+	cache, err := modelsCache()
+	if err != nil {
+		appLogger.Log("Warning: cannot check model %s: %v", cfg.Model, err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), modelCheckTimeout)
+	defer cancel()
+	listing, err := cache.List(ctx, cfg.BaseURL, models.NewOpenAIFetcher(cfg), false)
+	if listing == nil || len(listing.Models) == 0 {
+		appLogger.Log("Warning: cannot check model %s: %v", cfg.Model, err)
+		return nil
+	}
+	if listing.Has(cfg.Model) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("unknown model %q at %s", cfg.Model, cfg.Provider().Name)
+	if suggestions := models.Suggest(cfg.Model, listing.IDs()); len(suggestions) > 0 {
+		msg += "; did you mean " + strings.Join(suggestions, ", ") + "?"
+	}
+	msg += " Run 'codex models' to list the models"
+	if !listing.Stale {
+		msg += " (or 'codex models --refresh' if it was just released)"
+	}
+	return fmt.Errorf("%s", msg)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/epuerta/codex-go/internal/notify"
+)
+
+var (
+	// longTurn is how long a turn runs before its end is notified
+	longTurn = 30 * time.Second
+	// notifyTimeout bounds showing a desktop notification
+	notifyTimeout = 5 * time.Second
+)
+
+// notifyInputNeeded tells the user the session needs them, for reason, if
+// the terminal is unfocused: it rings the bell and, with notify, shows a
+// desktop notification in the background. Terminals that do not report
+// focus are never notified.
+func (app *App) notifyInputNeeded(reason string) tea.Cmd {
+	if !app.unfocused {
+		return nil
+	}
+	if err := notify.RingBell(app.bell); err != nil {
+		app.Logger.Log("Failed to ring the bell: %v", err)
+	}
+	if !app.Config.Notify {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := notify.Desktop(ctx, "codex-go", reason); err != nil {
+			app.Logger.Log("Failed to show a desktop notification: %v", err)
+		}
+		return nil
+	}
+}
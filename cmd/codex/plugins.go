@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/plugins"
+)
+
+// setupPlugins starts the plugins in ~/.codex/plugins, registers their tools
+// and lets them observe the engine's tool calls. It returns nil if there are
+// none, along with the number of plugins that failed to start or to register
+// a tool.
+func setupPlugins(registry *functions.Registry, eng *engine.Engine, cfg *config.Config) (*plugins.Manager, int) {
+	if cfg.DisablePlugins {
+		return nil, 0
+	}
+	dir, err := plugins.DefaultDir()
+	if err != nil {
+		appLogger.Log("Warning: plugins disabled: %v", err)
+		return nil, 0
+	}
+
+	m, warnings := plugins.Load(dir, cfg.CWD, appLogger)
+	warnings = append(warnings, m.Register(registry)...)
+	for _, w := range warnings {
+		appLogger.Log("Warning: %v", w)
+	}
+	if len(m.Plugins) == 0 {
+		return nil, len(warnings)
+	}
+	for _, p := range m.Plugins {
+		appLogger.Log("Loaded plugin %s from %s with %d tool(s)", p.Name(), p.Path, len(p.Manifest.Tools))
+	}
+	eng.Observers = append(eng.Observers, m)
+	return m, len(warnings)
+}
+
+// pluginWarningNotice tells the user that some plugins did not load
+func pluginWarningNotice(failed int) string {
+	return fmt.Sprintf("%d plugin problem(s) at startup; run with --debug and see the log for details", failed)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/prompts"
+	"github.com/epuerta/codex-go/internal/slash"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// promptExpandedMsg carries a template expanded by /prompt
+type promptExpandedMsg struct {
+	name    string
+	content string
+	err     error
+}
+
+// expandPrompt loads the template called name from ~/.codex/prompts and fills
+// it in with args, running its shell snippets in the working directory
+func expandPrompt(ctx context.Context, cfg *config.Config, name string, args []string) (string, error) {
+	dir, err := prompts.DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	t, err := prompts.Load(dir, name)
+	if err != nil {
+		return "", err
+	}
+	e := &prompts.Expander{Dir: cfg.CWD, Timeout: time.Duration(cfg.CommandTimeout) * time.Second}
+	return e.Expand(ctx, t, prompts.ParseArgs(args))
+}
+
+// promptCommand handles /prompt: without a name it lists the templates,
+// otherwise it expands one in the background and sends it as a message
+func (app *App) promptCommand(args slash.Args) tea.Cmd {
+	switch {
+	case len(args.Fields) == 0:
+		app.ChatModel.AddNotice(promptsSummary())
+		return nil
+	case app.isAgentProcessing:
+		app.ChatModel.AddNotice("The assistant is busy; run /prompt once it is done.")
+		return nil
+	}
+	name, rest := args.Fields[0], args.Fields[1:]
+	cfg := app.Config
+	return func() tea.Msg {
+		content, err := expandPrompt(context.Background(), cfg, name, rest)
+		return promptExpandedMsg{name: name, content: content, err: err}
+	}
+}
+
+// finishPrompt sends an expanded template as if the user had typed it
+func (app *App) finishPrompt(msg promptExpandedMsg) tea.Cmd {
+	switch {
+	case msg.err != nil:
+		app.Logger.Log("Prompt %s failed: %v", msg.name, msg.err)
+		app.ChatModel.AddNotice(fmt.Sprintf("Could not use prompt %s: %v", msg.name, msg.err))
+		return nil
+	case msg.content == "":
+		app.ChatModel.AddNotice(fmt.Sprintf("Prompt %s is empty; nothing was sent.", msg.name))
+		return nil
+	}
+	app.Logger.Log("Expanded prompt %s to %d bytes", msg.name, len(msg.content))
+	return func() tea.Msg { return ui.UserInputSubmitMsg{Content: msg.content} }
+}
+
+// promptsSummary lists the templates for /prompt
+func promptsSummary() string {
+	dir, err := prompts.DefaultDir()
+	if err != nil {
+		return fmt.Sprintf("Failed to find the prompts directory: %v", err)
+	}
+	templates, err := prompts.List(dir)
+	if err != nil {
+		return fmt.Sprintf("Failed to list prompts: %v", err)
+	}
+	if len(templates) == 0 {
+		return fmt.Sprintf("No prompts yet. Add markdown templates to %s, then run /prompt <name> [KEY=VALUE...].", dir)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Prompts in %s (send one with /prompt <name> [KEY=VALUE...]):\n", dir)
+	for _, t := range templates {
+		fmt.Fprintf(&b, "  %-16s %s\n", t.Name, t.Description)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// promptNames returns the template names for /prompt completions
+func promptNames() []string {
+	dir, err := prompts.DefaultDir()
+	if err != nil {
+		return nil
+	}
+	templates, _ := prompts.List(dir)
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		names[i] = t.Name
+	}
+	return names
+}
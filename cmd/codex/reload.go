@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/contextbudget"
+	"github.com/fsnotify/fsnotify"
+)
+
+// instructionsChangedMsg reports that an instruction file was written,
+// created or removed
+type instructionsChangedMsg struct {
+	path string
+}
+
+// watchInstructions watches the instructions file and, unless they are
+// disabled, the project docs. Changes are applied at the start of the next
+// turn by applyReloads. It returns nil if the files cannot be watched.
+func (app *App) watchInstructions() *fsnotify.Watcher {
+	paths := []string{config.InstructionsPath()}
+	if !app.Config.DisableProjectDoc {
+		paths = append(paths, projectDocCandidates(app.Config)...)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		app.Logger.Log("Warning: Cannot watch the instruction files: %v", err)
+		return nil
+	}
+	// Editors often replace a file rather than write it, so the directories
+	// are watched
+	var dirs []string
+	for _, path := range paths {
+		if dir := filepath.Dir(path); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+			if err := watcher.Add(dir); err != nil {
+				app.Logger.Log("Warning: Cannot watch %s: %v", dir, err)
+			}
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !slices.Contains(paths, event.Name) || event.Has(fsnotify.Chmod) {
+					continue
+				}
+				app.Logger.Log("Instruction file %s changed (%s)", event.Name, event.Op)
+				select {
+				case app.agentMsgChan <- instructionsChangedMsg{path: event.Name}:
+				case <-app.done:
+					return
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				app.Logger.Log("Warning: Watching the instruction files: %v", err)
+			}
+		}
+	}()
+	return watcher
+}
+
+// applyReloads applies the instruction files changed since the last turn:
+// the instructions file becomes the system prompt again, and the new content
+// of the project docs already sent is added to the history. The notice
+// names the files reloaded.
+func (app *App) applyReloads() {
+	if len(app.reloads) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(app.reloads))
+	for path := range app.reloads {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	app.reloads = nil
+
+	history := app.Agent.GetHistory()
+	var names []string
+	for _, path := range paths {
+		names = append(names, summaryPath(app.Config, path))
+		data, err := os.ReadFile(path)
+		removed := err != nil
+		if path == config.InstructionsPath() {
+			app.Config.Instructions = string(data)
+			prompt := app.Config.Instructions
+			if prompt == "" {
+				prompt = agent.DefaultHistoryOptions().SystemPrompt
+			}
+			history.SetSystemPrompt(prompt)
+			continue
+		}
+		if !app.repoContextSent {
+			continue // The first message takes the docs as they are then
+		}
+		content := fmt.Sprintf("The project doc %s changed; this replaces its earlier content:\n\n%s", summaryPath(app.Config, path), string(data))
+		if removed {
+			content = fmt.Sprintf("The project doc %s was removed; disregard its earlier content.", summaryPath(app.Config, path))
+		}
+		history.AddMessage(agent.Message{Role: "system", Content: content, Source: contextbudget.ProjectDocs})
+	}
+	app.Logger.Log("Reloaded instruction files: %s", strings.Join(names, ", "))
+	app.ChatModel.SetNotice("Instructions reloaded: " + strings.Join(names, ", "))
+}
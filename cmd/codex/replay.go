@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/sessions"
+	"github.com/epuerta/codex-go/internal/truncate"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// replayCmd creates the command that steps through a saved session
+func replayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay <file|id>",
+		Short: "Step through a saved session event by event",
+		Long: `Step through a saved session in the TUI, one event at a time: the prompts,
+the assistant's messages, each tool call with the patch or command it made,
+the approval decisions and the results, as they happened.
+
+Press space to show the next event and ← to go back; g and G jump to the
+start and the end. The session is given as a rollout file or as the ID of a
+session listed by codex sessions.
+
+Examples:
+  codex replay 3f2a9c1e
+  codex replay ~/.codex/rollouts/codex-session-20250102-100000.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveRolloutPath(args[0])
+			if err != nil {
+				return err
+			}
+			rollout, _, err := readRollout(path)
+			if err != nil {
+				return err
+			}
+			steps := replaySteps(rollout)
+			if len(steps) == 0 {
+				return fmt.Errorf("session %s has no events to replay", args[0])
+			}
+
+			title := rollout.Title
+			if title == "" {
+				title = path
+			}
+			model := ui.NewReplayModel(title, steps)
+			model.Limits = truncate.Limits{HeadLines: 20, TailLines: 20}
+			_, err = tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
+			return err
+		},
+	}
+	return cmd
+}
+
+// resolveRolloutPath returns arg if it is a file, or the path of the saved
+// session whose ID starts with arg
+func resolveRolloutPath(arg string) (string, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		return arg, nil
+	}
+	entries, err := loadSessions()
+	if err != nil {
+		return "", err
+	}
+	e, err := sessions.Find(entries, arg)
+	if err != nil {
+		return "", err
+	}
+	return e.Path, nil
+}
+
+// replaySteps turns the events of a rollout into the messages of a replay.
+// Patches are shown as diffs and commands with their output.
+func replaySteps(r *AppRollout) []ui.ReplayStep {
+	var steps []ui.ReplayStep
+	for _, e := range r.Events {
+		step := ui.ReplayStep{Label: e.Type, Time: e.Time}
+		msg := ui.Message{Timestamp: e.Time}
+		switch e.Type {
+		case eventUserMessage:
+			msg.Role, msg.Content, msg.Attachments = "user", e.Content, e.Attachments
+		case eventAssistantMessage:
+			msg.Role, msg.Content = "assistant", e.Content
+		case eventToolCall:
+			step.Label += " " + e.Tool
+			call := agent.FunctionCall{ID: e.CallID, Name: e.Tool, Arguments: e.Arguments}
+			args := executor.ApprovalArgs(call)
+			if e.Tool == "patch_file" {
+				args = "\n" + ui.FormatPatchForDisplay(args)
+			}
+			msg.Role, msg.Content = "function_call", fmt.Sprintf("Call: %s\nArgs: %s", e.Tool, args)
+		case eventApproval:
+			if e.Approval == nil {
+				continue
+			}
+			step.Label += " " + e.Approval.Decision
+			msg.Role, msg.Content, msg.Approval = "approval", e.Approval.String(), e.Approval
+		case eventToolResult:
+			step.Label += " " + e.Tool
+			if e.Command != "" {
+				res := &ui.CommandResult{Command: e.Command, Stdout: e.Output, Duration: time.Duration(e.DurationMs) * time.Millisecond}
+				if e.ExitCode != nil {
+					res.ExitCode = *e.ExitCode
+				} else {
+					res.ExitCode = -1
+				}
+				if res.ExitCode != 0 {
+					// The output the model got mixes both streams
+					res.Stdout, res.Stderr = "", e.Output
+				}
+				msg.Role, msg.Content, msg.CommandResult = "command", e.Command, res
+			} else {
+				msg.Role, msg.Content = "function_result", e.Output
+			}
+		case eventToolDenied:
+			step.Label += " " + e.Tool
+			msg.Role, msg.Content = ui.RoleNotice, fmt.Sprintf("%s was not run: %s", e.Tool, e.Reason)
+		case eventBlocked:
+			if e.Blocked == nil {
+				continue
+			}
+			msg.Role, msg.Content = ui.RoleNotice, e.Blocked.Explain()
+		case eventError:
+			msg.Role, msg.Content = ui.RoleNotice, "Error: "+e.Content
+		case eventInterrupted:
+			msg.Role, msg.Content = ui.RoleNotice, "Interrupted by the user"
+		default:
+			continue
+		}
+		step.Message = msg
+		steps = append(steps, step)
+	}
+	return steps
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/fileops"
+	"github.com/epuerta/codex-go/internal/ui"
+)
+
+// reviewPatch shows the hunk-by-hunk review for a patch_file call whose patch
+// has at least patch_review_hunks hunks. It returns false for smaller
+// patches, which are approved whole.
+func (app *App) reviewPatch(call agent.FunctionCall, patch string) bool {
+	if app.Config.PatchReviewHunks == 0 {
+		return false
+	}
+	hunks, err := fileops.SplitAgentPatch(patch)
+	if err != nil || len(hunks) < app.Config.PatchReviewHunks {
+		return false
+	}
+
+	app.Logger.Log("Reviewing patch for call %s hunk by hunk: %d hunks", call.ID, len(hunks))
+	title := fmt.Sprintf("Review Patch (%d hunks)", len(hunks))
+	if conflicts := app.Executor.CreateConflicts(call); len(conflicts) > 0 {
+		title += ": " + strings.Join(conflicts, " ")
+	}
+	app.reviewModel = ui.NewReviewModel(title, hunks)
+	app.reviewModel.SetSize(app.width, app.height)
+	app.isAwaitingApproval = true
+	app.isReviewingPatch = true
+	app.pendingFunctionCall = &call
+	app.pendingApprovalArgs = patch
+	app.ChatModel.SetThinkingStatus("Reviewing patch hunks...")
+	app.ChatModel.ForceUpdateViewport()
+	return true
+}
+
+// finishReview sends the outcome of a patch review to the engine: the patch
+// reduced to the accepted hunks, and a note on what was skipped or edited
+func (app *App) finishReview(result ui.ReviewResultMsg) {
+	call := *app.pendingFunctionCall
+	app.isAwaitingApproval = false
+	app.isReviewingPatch = false
+	app.PatchMetrics.RecordDecision(result.Approved)
+
+	decision := engine.Decision{Approved: result.Approved, Call: call}
+	if result.Approved && (len(result.Skipped) > 0 || len(result.Edited) > 0) {
+		reviewed, err := executor.WithPatch(call, fileops.JoinAgentPatch(result.Accepted))
+		if err != nil {
+			// Applying the whole patch instead would go against the review
+			app.Logger.Log("ERROR: Could not rebuild reviewed patch: %v", err)
+			app.ChatModel.AddNotice(fmt.Sprintf("The reviewed patch could not be assembled (%v); it was not applied.", err))
+			decision = engine.Decision{Call: call}
+		} else {
+			decision.Call = reviewed
+			decision.Note = reviewNote(result)
+		}
+	}
+
+	if decision.Approved {
+		app.Logger.Log("Patch review done: %d hunks accepted (%d edited), %d skipped", len(result.Accepted), len(result.Edited), len(result.Skipped))
+		if len(result.Skipped) > 0 {
+			app.ChatModel.AddNotice(fmt.Sprintf("Applying %d of %d hunks; the skipped ones are reported to the assistant.", len(result.Accepted), len(result.Accepted)+len(result.Skipped)))
+		}
+		app.ChatModel.SetThinkingStatus("Executing: patch_file")
+	} else {
+		app.Logger.Log("Patch review denied the patch")
+		app.ChatModel.SetThinkingStatus("Processing function result...")
+	}
+
+	// The engine is blocked waiting for the decision; the reply channel is buffered
+	app.pendingApproval <- decision
+	app.pendingApproval = nil
+	app.pendingFunctionCall = nil
+	app.pendingApprovalArgs = ""
+}
+
+// reviewNote tells the assistant which hunks of its patch were left out or
+// changed by the user
+func reviewNote(result ui.ReviewResultMsg) string {
+	var b strings.Builder
+	b.WriteString("The user reviewed this patch hunk by hunk; only the accepted hunks were applied.")
+	if len(result.Skipped) > 0 {
+		b.WriteString("\nSkipped hunks, not applied:")
+		for _, h := range result.Skipped {
+			b.WriteString("\n- " + h.Summary())
+		}
+	}
+	if len(result.Edited) > 0 {
+		b.WriteString("\nHunks the user edited before they were applied (read these files again before changing them):")
+		for _, h := range result.Edited {
+			b.WriteString("\n- " + h.Summary())
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/executor"
+)
+
+// rolloutVersion is the version of the rollout format written by this build.
+// Version 1 rollouts, saved without a version, have no event log; they are
+// migrated when read.
+const rolloutVersion = 2
+
+// Types of rollout events
+const (
+	eventUserMessage      = "user_message"
+	eventAssistantMessage = "assistant_message"
+	eventToolCall         = "tool_call"
+	eventApproval         = "approval"
+	eventToolResult       = "tool_result"
+	eventToolDenied       = "tool_denied"
+	eventBlocked          = "blocked"
+	eventError            = "error"
+	eventInterrupted      = "interrupted"
+)
+
+// RolloutEvent is one entry of a rollout's event log. Fields that do not
+// apply to an event's type are left out.
+type RolloutEvent struct {
+	Seq  int       `json:"seq"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"` // Zero for events migrated from messages
+
+	Content     string   `json:"content,omitempty"`     // For messages and errors
+	Attachments []string `json:"attachments,omitempty"` // For user_message
+
+	CallID    string `json:"call_id,omitempty"`   // For tool events and approval
+	Tool      string `json:"tool,omitempty"`      // For tool events
+	Arguments string `json:"arguments,omitempty"` // For tool_call
+
+	Output     string `json:"output,omitempty"`      // For tool_result
+	Success    *bool  `json:"success,omitempty"`     // For tool_result
+	Command    string `json:"command,omitempty"`     // For tool_result of shell commands
+	ExitCode   *int   `json:"exit_code,omitempty"`   // For tool_result of shell commands that started
+	DurationMs int64  `json:"duration_ms,omitempty"` // For tool_result: from the approval, or the call, to the result
+	Reason     string `json:"reason,omitempty"`      // For tool_denied
+
+	Approval *agent.ApprovalEvent `json:"approval,omitempty"` // For approval
+	Blocked  *agent.Blocked       `json:"blocked,omitempty"`  // For blocked
+}
+
+// addEvent appends an event to the rollout's log, numbering it
+func (r *AppRollout) addEvent(event RolloutEvent) {
+	event.Seq = len(r.Events) + 1
+	r.Events = append(r.Events, event)
+}
+
+// recordEvent adds an event that happens now to the session's rollout
+func (app *App) recordEvent(event RolloutEvent) {
+	event.Time = time.Now()
+	app.rollout().addEvent(event)
+}
+
+// toolResultEvent describes the result of a call, timed from the last
+// approval of the call, or the call itself, recorded in the log
+func (r *AppRollout) toolResultEvent(call agent.FunctionCall, res *executor.Result, at time.Time) RolloutEvent {
+	success := res.Success
+	event := RolloutEvent{
+		Type:    eventToolResult,
+		Time:    at,
+		CallID:  call.ID,
+		Tool:    call.Name,
+		Output:  res.Output,
+		Success: &success,
+		Command: res.Command,
+	}
+	if res.CommandResult != nil {
+		exitCode := res.CommandResult.ExitCode
+		event.ExitCode = &exitCode
+	}
+	for i := len(r.Events) - 1; i >= 0; i-- {
+		e := r.Events[i]
+		if e.CallID == call.ID && (e.Type == eventApproval || e.Type == eventToolCall) && !e.Time.IsZero() {
+			event.DurationMs = at.Sub(e.Time).Milliseconds()
+			break
+		}
+	}
+	return event
+}
+
+// migrateRollout brings a rollout read from disk to the current version,
+// reporting whether it changed. The event log of a version 1 rollout is
+// rebuilt from its messages, approvals, commands and blocked responses; it
+// lacks the times of messages and calls, and the outputs of calls are those
+// the model was sent.
+func migrateRollout(r *AppRollout) bool {
+	if r.Version >= rolloutVersion {
+		return false
+	}
+
+	approvals := make(map[string][]agent.ApprovalEvent)
+	var unmatched []agent.ApprovalEvent
+	for _, event := range r.Approvals {
+		if event.CallID == "" {
+			unmatched = append(unmatched, event)
+			continue
+		}
+		approvals[event.CallID] = append(approvals[event.CallID], event)
+	}
+	commands := make(map[string]CommandRecord)
+	for _, c := range r.Commands {
+		if c.CallID != "" {
+			commands[c.CallID] = c
+		}
+	}
+	tools := make(map[string]string) // Function names by call ID
+
+	r.Events = nil
+	for _, msg := range r.Messages {
+		switch msg.Role {
+		case "user":
+			r.addEvent(RolloutEvent{Type: eventUserMessage, Content: msg.Content})
+		case "assistant":
+			if msg.Content != "" {
+				r.addEvent(RolloutEvent{Type: eventAssistantMessage, Content: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				tools[tc.ID] = tc.Function.Name
+				r.addEvent(RolloutEvent{Type: eventToolCall, CallID: tc.ID, Tool: tc.Function.Name, Arguments: tc.Function.Arguments})
+				for _, event := range approvals[tc.ID] {
+					r.addEvent(RolloutEvent{Type: eventApproval, Time: event.Timestamp, CallID: tc.ID, Approval: &event})
+				}
+				delete(approvals, tc.ID)
+			}
+		case "tool":
+			event := RolloutEvent{Type: eventToolResult, CallID: msg.ToolCallID, Tool: tools[msg.ToolCallID], Output: msg.Content}
+			if c, ok := commands[msg.ToolCallID]; ok {
+				success, exitCode := c.Success, c.ExitCode
+				event.Time, event.Success, event.Command, event.DurationMs = c.FinishedAt, &success, c.Command, c.DurationMs
+				if exitCode != -1 {
+					event.ExitCode = &exitCode
+				}
+			}
+			r.addEvent(event)
+		}
+	}
+
+	// Approvals of calls missing from the messages, and blocked responses,
+	// follow in the order they happened
+	for _, events := range approvals {
+		unmatched = append(unmatched, events...)
+	}
+	var rest []RolloutEvent
+	for _, event := range unmatched {
+		rest = append(rest, RolloutEvent{Type: eventApproval, Time: event.Timestamp, CallID: event.CallID, Approval: &event})
+	}
+	for _, b := range r.Blocked {
+		blocked := b.Blocked
+		rest = append(rest, RolloutEvent{Type: eventBlocked, Time: b.At, Blocked: &blocked})
+	}
+	sort.SliceStable(rest, func(i, j int) bool { return rest[i].Time.Before(rest[j].Time) })
+	for _, event := range rest {
+		r.addEvent(event)
+	}
+
+	r.MigratedFrom = max(r.Version, 1)
+	r.Version = rolloutVersion
+	return true
+}
+
+// readRollout reads the rollout at path, migrated to the current version.
+// It reports whether the rollout was migrated.
+func readRollout(path string) (*AppRollout, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read rollout file: %w", err)
+	}
+	var rollout AppRollout
+	if err := json.Unmarshal(data, &rollout); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal rollout: %w", err)
+	}
+	if rollout.Version > rolloutVersion {
+		return nil, false, fmt.Errorf("rollout %s has version %d; this build reads up to version %d", path, rollout.Version, rolloutVersion)
+	}
+	migrated := migrateRollout(&rollout)
+	return &rollout, migrated, nil
+}
+
+// writeRollout saves a rollout to path
+func writeRollout(path string, rollout *AppRollout) error {
+	data, err := json.MarshalIndent(rollout, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollout: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save rollout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/scripts"
+)
+
+// toolSourceSetter is implemented by agents that advertise the tools of a
+// source, such as the function registry
+type toolSourceSetter interface {
+	SetToolSource(tools agent.ToolSource)
+}
+
+// setupProjectScripts detects the project's scripts, adds the custom tools of
+// the config and lets exec run them, which registers run_project_script. It
+// returns the scripts found.
+func setupProjectScripts(exec *executor.Executor, cfg *config.Config) []scripts.Script {
+	var found []scripts.Script
+	if !cfg.DisableProjectScripts {
+		var err error
+		if found, err = scripts.Detect(cfg.CWD); err != nil {
+			appLogger.Log("Warning: failed to read project scripts: %v", err)
+		}
+	}
+	for _, tool := range cfg.Tools {
+		found = append(found, scripts.Script{Name: tool.Name, Source: "tool", Command: tool.Command, Description: tool.Description})
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	appLogger.Log("Found %d project scripts: %s", len(found), strings.Join(scripts.Names(found), ", "))
+
+	exec.UseScripts(found)
+	return found
+}
+
+// scriptResultMsg reports a project script run by the user with /run
+type scriptResultMsg struct {
+	name   string
+	result *executor.Result
+}
+
+// runScriptCmd runs a project script in the background
+func (app *App) runScriptCmd(name string, args []string) tea.Cmd {
+	return func() tea.Msg {
+		return scriptResultMsg{name: name, result: app.Executor.RunScript(context.Background(), name, args)}
+	}
+}
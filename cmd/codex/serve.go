@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/rpc"
+	"github.com/epuerta/codex-go/internal/server"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// serveTokenEnv names the environment variable holding the default API token
+const serveTokenEnv = "CODEX_SERVE_TOKEN"
+
+// serveCmd creates the serve command exposing the agent over HTTP
+func serveCmd() *cobra.Command {
+	var listen, grpcListen, token string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a REST/SSE API for driving agent sessions",
+		Long: `Start an HTTP server exposing agent sessions, for web frontends and editor plugins.
+
+Endpoints:
+  POST   /sessions                          create a session
+  GET    /sessions/{id}                     session status and pending approvals
+  DELETE /sessions/{id}                     cancel and remove a session
+  POST   /sessions/{id}/messages            send a message: {"content": "..."}
+  POST   /sessions/{id}/cancel              cancel the active run
+  GET    /sessions/{id}/events              stream events (Server-Sent Events)
+  POST   /sessions/{id}/approvals/{callID}  approve or deny a tool call: {"approved": true}
+  GET    /sessions/{id}/history             conversation history
+
+Tool calls follow the approval mode; calls needing approval wait for the
+approvals endpoint. Set --token (or ` + serveTokenEnv + `) to require
+"Authorization: Bearer <token>" on every request.
+
+With --grpc-listen, the codex.v1.CodexService gRPC API (api/codex/v1/codex.proto)
+is served as well, using the same token as "authorization" metadata.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runServe(cmd, listen, grpcListen, token); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", "localhost:8080", "Address to listen on")
+	cmd.Flags().StringVar(&grpcListen, "grpc-listen", "", "Address to serve the gRPC API on (disabled if empty)")
+	cmd.Flags().StringVar(&token, "token", os.Getenv(serveTokenEnv), "Bearer token required by the API")
+
+	return cmd
+}
+
+// runServe starts the API servers and blocks until they are interrupted
+func runServe(cmd *cobra.Command, listen, grpcListen, token string) error {
+	closeLogger := setupLogger(cmd)
+	defer closeLogger()
+
+	cfg, err := loadConfigFromFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.CheckCredentials(); err != nil {
+		return err
+	}
+	appLogger.Log("Serve mode: Listen=%s, Model=%s, ApprovalMode=%s, CWD=%s", listen, cfg.Model, cfg.ApprovalMode, cfg.CWD)
+
+	closeTelemetry, err := setupTelemetry(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: telemetry disabled: %v\n", err)
+	}
+	defer closeTelemetry()
+
+	newEngine := func() (*engine.Engine, error) {
+		ai, err := agent.NewOpenAIAgent(cfg, appLogger)
+		if err != nil {
+			return nil, err
+		}
+		eng, _ := newHeadlessEngine(ai, cfg)
+		return eng, nil
+	}
+	srv := server.New(newEngine, appLogger)
+	srv.Token = token
+	defer srv.Close()
+
+	for _, addr := range []string{listen, grpcListen} {
+		if addr != "" && token == "" && !isLoopbackAddr(addr) {
+			fmt.Fprintf(os.Stderr, "Warning: serving on %s without a token; anyone who can reach it can run tools on this machine.\n", addr)
+		}
+	}
+
+	var grpcServer *grpc.Server
+	if grpcListen != "" {
+		lis, err := net.Listen("tcp", grpcListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", grpcListen, err)
+		}
+		rpcSrv := rpc.New(newEngine, appLogger)
+		rpcSrv.Token = token
+		defer rpcSrv.Close()
+
+		grpcServer = rpcSrv.NewGRPCServer()
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				appLogger.Log("[ERROR] gRPC server stopped: %v", err)
+			}
+		}()
+		fmt.Fprintf(os.Stderr, "gRPC listening on %s\n", grpcListen)
+	}
+
+	httpServer := &http.Server{Addr: listen, Handler: srv.Handler()}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		appLogger.Log("Serve: shutdown signal received.")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Close() // End event streams so Shutdown does not wait on them
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		httpServer.Shutdown(ctx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s (approval mode: %s)\n", listen, cfg.ApprovalMode)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local connections
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
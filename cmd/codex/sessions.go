@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/epuerta/codex-go/internal/sessions"
+	"github.com/spf13/cobra"
+)
+
+// sessionsCmd creates the command that lists, searches, renames, exports
+// and migrates saved sessions
+func sessionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions [query...]",
+		Short: "List and search saved sessions",
+		Long: `List the sessions saved in ~/.codex/rollouts, most recent first.
+
+Each session is titled after its first prompt unless it was renamed. A query
+keeps the sessions whose title, repository or prompts contain all its words.
+
+Examples:
+  codex sessions
+  codex sessions flaky test --limit 5
+  codex sessions rename 3f2a "Fix the flaky login test"
+  codex sessions export 3f2a --format html -o session.html
+  codex sessions migrate`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			limit, _ := cmd.Flags().GetInt("limit")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			entries, err := loadSessions()
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				entries = sessions.Filter(entries, strings.Join(args, " "))
+			}
+			if limit > 0 && len(entries) > limit {
+				entries = entries[:limit]
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+			if len(entries) == 0 {
+				fmt.Println("No sessions found.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tUPDATED\tREPO\tTITLE")
+			for _, e := range entries {
+				title := e.Title
+				if title == "" {
+					title = "(untitled)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shortSessionID(e), e.UpdatedAt.Local().Format("2006-01-02 15:04"), e.Repo, title)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Println("\nRename a session with: codex sessions rename <id> <title>")
+			return nil
+		},
+	}
+
+	cmd.Flags().Int("limit", 20, "Maximum number of sessions to list (0 for all)")
+	cmd.Flags().Bool("json", false, "Print the sessions as JSON")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rename <id> <title...>",
+		Short: "Rename a saved session",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := loadSessions()
+			if err != nil {
+				return err
+			}
+			e, err := sessions.Find(entries, args[0])
+			if err != nil {
+				return err
+			}
+			title := strings.Join(args[1:], " ")
+			if err := sessions.Rename(e.Path, title); err != nil {
+				return err
+			}
+			fmt.Printf("Renamed session %s to %q\n", shortSessionID(e), title)
+			return nil
+		},
+	})
+
+	export := &cobra.Command{
+		Use:   "export <id>",
+		Short: "Export a saved session as markdown, HTML or JSON",
+		Long: `Export a saved session as a document to share, such as in a code review or
+a postmortem: its prompts and messages, each command with its output, and the
+diffs of the patches applied.
+
+The format is taken from --format, or else from the extension of --output.
+Without --output the document is written to standard output.
+
+Examples:
+  codex sessions export 3f2a > session.md
+  codex sessions export 3f2a --format html -o session.html
+  codex sessions export 3f2a --format json | jq '.events | length'`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			if !cmd.Flags().Changed("format") && exportFormatOf(output) != "" {
+				format = exportFormatOf(output)
+			}
+
+			path, err := resolveRolloutPath(args[0])
+			if err != nil {
+				return err
+			}
+			rollout, _, err := readRollout(path)
+			if err != nil {
+				return err
+			}
+			data, err := exportTranscript(rollout, format)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+			fmt.Fprintf(os.Stderr, "Exported session %s to %s\n", args[0], output)
+			return nil
+		},
+	}
+	export.Flags().String("format", "md", "Format of the document: "+strings.Join(exportFormats, ", "))
+	export.Flags().StringP("output", "o", "", "File to write the document to (default: standard output)")
+	cmd.AddCommand(export)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "migrate [id...]",
+		Short: "Rewrite saved sessions in the current rollout format",
+		Long: `Rewrite saved sessions in the current rollout format, all of them unless
+IDs are given. Sessions saved before rollouts had an event log get one rebuilt
+from their messages, approvals and commands. Sessions are also migrated when
+they are loaded, so this is only needed for tools that read the files.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := loadSessions()
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				var selected []sessions.Entry
+				for _, id := range args {
+					e, err := sessions.Find(entries, id)
+					if err != nil {
+						return err
+					}
+					selected = append(selected, e)
+				}
+				entries = selected
+			}
+
+			count := 0
+			for _, e := range entries {
+				rollout, migrated, err := readRollout(e.Path)
+				if err != nil {
+					return err
+				}
+				if !migrated {
+					continue
+				}
+				if err := writeRollout(e.Path, rollout); err != nil {
+					return err
+				}
+				fmt.Printf("Migrated session %s from version %d (%d events)\n", shortSessionID(e), rollout.MigratedFrom, len(rollout.Events))
+				count++
+			}
+			fmt.Printf("%d of %d session(s) migrated to version %d\n", count, len(entries), rolloutVersion)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// loadSessions lists the saved sessions, warning about unreadable ones
+func loadSessions() ([]sessions.Entry, error) {
+	dir, err := rolloutsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, warnings, err := sessions.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", w)
+	}
+	return entries, nil
+}
+
+// shortSessionID returns the prefix of a session's ID used to refer to it,
+// or its file name for rollouts saved without an ID
+func shortSessionID(e sessions.Entry) string {
+	if len(e.SessionID) >= 8 {
+		return e.SessionID[:8]
+	}
+	if e.SessionID != "" {
+		return e.SessionID
+	}
+	return strings.TrimSuffix(filepath.Base(e.Path), ".json")
+}
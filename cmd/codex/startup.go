@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/instructions"
+)
+
+// approvalModeSummaries say what each approval mode lets tools do
+var approvalModeSummaries = map[config.ApprovalMode]string{
+	config.Suggest:              "asks before every change and command",
+	config.AutoEdit:             "applies edits, asks before commands",
+	config.FullAuto:             "applies edits and runs commands in the sandbox without asking",
+	config.DangerousAutoApprove: "applies edits and runs commands without asking",
+}
+
+// showStartupSummary shows the effective configuration above the chat, so
+// that the user can check what they enabled before the first tool runs
+func (app *App) showStartupSummary() {
+	mode := app.Config.ApprovalMode
+	app.ChatModel.SetStartupSummary(app.startupSummary(), mode == config.FullAuto || mode == config.DangerousAutoApprove)
+}
+
+// startupSummary describes the model and provider, the approval mode, the
+// sandbox, and the project docs and files in effect, one per line
+func (app *App) startupSummary() string {
+	cfg := app.Config
+	var b strings.Builder
+	line := func(label, value string) {
+		fmt.Fprintf(&b, "%-15s %s\n", label+":", value)
+	}
+
+	model := cfg.Model
+	if models := cfg.Models(); len(models) > 1 {
+		model += ", falling back to " + strings.Join(models[1:], ", ")
+	}
+	line("Model", model)
+	line("Provider", fmt.Sprintf("%s (%s)", cfg.Provider().Name, cfg.BaseURL))
+
+	mode := string(cfg.ApprovalMode)
+	if summary, ok := approvalModeSummaries[cfg.ApprovalMode]; ok {
+		mode += ": " + summary
+	}
+	line("Approval mode", mode)
+	if app.Executor.DryRun() {
+		line("Dry run", "on: edits show their diff instead of writing")
+	}
+
+	network := "network off"
+	if cfg.Sandbox.AllowNetwork {
+		network = "network on"
+	}
+	line("Sandbox", fmt.Sprintf("%s, %s", app.Sandbox.Name(), network))
+
+	docs := "disabled"
+	if !cfg.DisableProjectDoc {
+		docs = "none"
+		if paths := projectDocs(cfg); len(paths) > 0 {
+			for i, path := range paths {
+				paths[i] = summaryPath(cfg, path)
+			}
+			docs = strings.Join(paths, ", ")
+		}
+	}
+	line("Project docs", docs)
+	if cfg.ProjectConfigPath != "" {
+		line("Project config", summaryPath(cfg, cfg.ProjectConfigPath))
+	}
+	if app.Approvals != nil {
+		allowed := summaryPath(cfg, app.Approvals.Path())
+		if rules, err := app.Approvals.Rules(); err == nil && len(rules) > 0 {
+			allowed = fmt.Sprintf("%d rules in %s (/approvals)", len(rules), allowed)
+		}
+		line("Always allowed", allowed)
+	}
+
+	if cfg.ApprovalMode == config.FullAuto || cfg.ApprovalMode == config.DangerousAutoApprove {
+		b.WriteString("\nTools run without asking; check these settings before sending a task.")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// projectDocs lists the instruction files sent with the first message, as
+// loadRepositoryContext finds them
+func projectDocs(cfg *config.Config) []string {
+	var paths []string
+	for _, path := range projectDocCandidates(cfg) {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// projectDocCandidates lists the paths of the instruction files that go with
+// the first message if they exist: the project doc, then the AGENTS.md and
+// codex.md of the repository root and of the working directory
+func projectDocCandidates(cfg *config.Config) []string {
+	var paths []string
+	add := func(path string) {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	if cfg.ProjectDocPath != "" {
+		add(cfg.ProjectDocPath)
+	}
+	if root, err := findRepositoryRoot(cfg.CWD); err == nil {
+		for _, name := range instructions.FileNames {
+			add(filepath.Join(root, name))
+		}
+	}
+	for _, name := range instructions.FileNames {
+		add(filepath.Join(cfg.CWD, name))
+	}
+	return paths
+}
+
+// summaryPath shortens path relative to the working directory, or to ~
+// outside of it
+func summaryPath(cfg *config.Config, path string) string {
+	if rel, err := filepath.Rel(cfg.CWD, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.Join("~", rel)
+		}
+	}
+	return path
+}
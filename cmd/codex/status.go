@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// refreshGitStatus shows the branch and the number of changed files of the
+// repository in the status bar
+func (app *App) refreshGitStatus() {
+	app.ChatModel.SetGitStatus(gitStatus(app.Config.CWD))
+}
+
+// gitStatus returns the branch checked out in the repository containing
+// dir, and how many files are changed or untracked. The branch is "" outside
+// a repository, and "HEAD" when none is checked out.
+func gitStatus(dir string) (branch string, changed int) {
+	out, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--branch").Output()
+	if err != nil {
+		return "", 0
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	// The first line is like "## main...origin/main [ahead 1]"
+	head := strings.TrimPrefix(lines[0], "## ")
+	head = strings.TrimPrefix(head, "No commits yet on ")
+	head, _, _ = strings.Cut(head, "...")
+	branch, _, _ = strings.Cut(head, " ")
+	return branch, len(lines) - 1
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/models"
+)
+
+// The agent, engine and executor share app.Config, so changing the model or
+// approval mode there takes effect with the next request. The switches are
+// refused while a request is running so a turn never mixes two settings.
+
+// switchModel handles /model [name] and returns the message to show
+func (app *App) switchModel(name string) string {
+	if name == "" {
+		return fmt.Sprintf("Model: %s\nSwitch with /model <name>; codex models lists the models at %s.", app.Config.Model, app.Config.Provider().Name)
+	}
+	if app.isAgentProcessing {
+		return "The model can be changed once the assistant has finished responding."
+	}
+	if name == app.Config.Model {
+		return fmt.Sprintf("Already using %s.", name)
+	}
+
+	// Only the cached listing is consulted, so switching never waits on the provider
+	if cache, err := modelsCache(); err == nil {
+		if listing, err := cache.Load(app.Config.BaseURL); err == nil && listing != nil && len(listing.Models) > 0 && !listing.Has(name) {
+			msg := fmt.Sprintf("Unknown model %q at %s.", name, app.Config.Provider().Name)
+			if suggestions := models.Suggest(name, listing.IDs()); len(suggestions) > 0 {
+				msg += " Did you mean " + strings.Join(suggestions, ", ") + "?"
+			}
+			return msg + " Run codex models --refresh if it was just released."
+		}
+	}
+
+	previous := app.Config.Model
+	app.Config.Model = name
+	app.ChatModel.SetSessionInfo("", "", name, "")
+	app.Logger.Log("Model switched from %s to %s", previous, name)
+
+	msg := fmt.Sprintf("Switched model from %s to %s.", previous, name)
+	if caps, ok := models.Lookup(name); ok && !caps.Tools {
+		msg += fmt.Sprintf(" Note that %s does not support function calling, so the assistant cannot read files or run commands.", name)
+	}
+	return msg
+}
+
+// switchApproval handles /approval [mode] and returns the message to show
+func (app *App) switchApproval(arg string) string {
+	if arg == "" {
+		return fmt.Sprintf("Approval mode: %s\nSwitch with /approval <suggest|auto-edit|full-auto|dangerous>.", app.Config.ApprovalMode)
+	}
+	if app.isAgentProcessing {
+		return "The approval mode can be changed once the assistant has finished responding."
+	}
+	mode, err := config.ParseApprovalMode(arg)
+	if err != nil {
+		return fmt.Sprintf("Unknown approval mode %q; use suggest, auto-edit, full-auto or dangerous.", arg)
+	}
+	if mode == app.Config.ApprovalMode {
+		return fmt.Sprintf("Already in %s mode.", mode)
+	}
+
+	previous := app.Config.ApprovalMode
+	app.Config.ApprovalMode = mode
+	app.ChatModel.SetSessionInfo("", "", "", string(mode))
+	app.ChatModel.SetToolsInfo(app.toolsInfo())
+	app.Logger.Log("Approval mode switched from %s to %s", previous, mode)
+
+	msg := fmt.Sprintf("Switched approval mode from %s to %s.", previous, mode)
+	if mode == config.DangerousAutoApprove {
+		msg += " Every file change and command now runs without asking."
+	}
+	return msg
+}
+
+// switchDryRun handles /dryrun [on|off], which toggles without an argument,
+// and returns the message to show
+func (app *App) switchDryRun(arg string) string {
+	on := !app.Executor.DryRun()
+	switch arg {
+	case "":
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		return "Usage: /dryrun [on|off] toggles dry-run mode, in which patch_file, write_file and edit_lines show their diff instead of writing."
+	}
+	if app.isAgentProcessing {
+		return "Dry-run mode can be changed once the assistant has finished responding."
+	}
+	if on == app.Executor.DryRun() {
+		return fmt.Sprintf("Dry-run mode is already %s.", onOff(on))
+	}
+
+	app.Executor.SetDryRun(on)
+	app.Config.DryRun = on
+	app.ChatModel.SetToolsInfo(app.toolsInfo())
+	app.Logger.Log("Dry-run mode switched %s", onOff(on))
+	if on {
+		return "Dry-run mode on: patch_file, write_file and edit_lines show the diff they would apply and leave the files alone. Commands still run as the approval mode says."
+	}
+	return "Dry-run mode off: file changes are written again. The assistant still believes its simulated changes were made; ask it to make them again if you want them."
+}
+
+// onOff returns "on" or "off"
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
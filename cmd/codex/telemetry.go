@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/telemetry"
+)
+
+// telemetryFlushTimeout bounds how long exiting waits for traces to be exported
+const telemetryFlushTimeout = 5 * time.Second
+
+// setupTelemetry exports traces of the session when the config's telemetry
+// is on. The returned function flushes them before exit; it does nothing
+// when telemetry is off or could not be set up, along with the error.
+func setupTelemetry(cfg *config.Config) (func(), error) {
+	shutdown, err := telemetry.Setup(context.Background(), cfg.Telemetry, Version)
+	if err != nil {
+		appLogger.Log("Warning: telemetry disabled: %v", err)
+		return func() {}, err
+	}
+	if cfg.Telemetry.On() {
+		appLogger.Log("Exporting traces to %s", telemetryEndpoint(cfg.Telemetry))
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			appLogger.Log("Error flushing traces: %v", err)
+		}
+	}, nil
+}
+
+// telemetryEndpoint describes where traces are exported
+func telemetryEndpoint(t config.TelemetryConfig) string {
+	if t.Endpoint != "" {
+		return t.Endpoint
+	}
+	return "the endpoint of OTEL_EXPORTER_OTLP_ENDPOINT"
+}
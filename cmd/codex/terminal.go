@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// terminalRequestMsg asks the Update loop to suspend the UI and lend the
+// terminal to run; run's error is sent on done
+type terminalRequestMsg struct {
+	loan *terminalLoan
+}
+
+// terminalReturnedMsg is delivered once the UI has the terminal back
+type terminalReturnedMsg struct {
+	loan *terminalLoan
+	err  error
+}
+
+// terminalLoan implements tea.ExecCommand: Bubble Tea releases the terminal,
+// hands its input and output to Run, and restores the UI when Run returns
+type terminalLoan struct {
+	run    func(stdin io.Reader, stdout io.Writer) error
+	done   chan error // Buffered; the engine is waiting
+	stdin  io.Reader
+	stdout io.Writer
+	ran    bool
+}
+
+func (l *terminalLoan) SetStdin(r io.Reader)  { l.stdin = r }
+func (l *terminalLoan) SetStdout(w io.Writer) { l.stdout = w }
+func (l *terminalLoan) SetStderr(io.Writer)   {}
+
+// Run runs the interactive command. Its error goes to the engine rather
+// than to Bubble Tea, which only needs to know to take the terminal back.
+func (l *terminalLoan) Run() error {
+	l.ran = true
+	fmt.Fprint(l.stdout, "Codex-Go: running an interactive command; the chat returns when it exits.\r\n\r\n")
+	l.done <- l.run(l.stdin, l.stdout)
+	return nil
+}
+
+// Lend implements executor.Terminal: it suspends the UI while run uses the
+// terminal and blocks until the command is done
+func (app *App) Lend(ctx context.Context, run func(stdin io.Reader, stdout io.Writer) error) error {
+	loan := &terminalLoan{run: run, done: make(chan error, 1)}
+	if !app.sendAgentMsg(terminalRequestMsg{loan: loan}) {
+		return errAppClosed
+	}
+
+	select {
+	case err := <-loan.done:
+		return err
+	case <-app.done:
+		return errAppClosed
+	}
+}
+
+// lendTerminal returns the command that hands the terminal to the loan
+func (app *App) lendTerminal(loan *terminalLoan) tea.Cmd {
+	app.Logger.Log("Lending the terminal to an interactive command")
+	app.ChatModel.SetThinkingStatus("Running an interactive command...")
+	return tea.Exec(loan, func(err error) tea.Msg {
+		return terminalReturnedMsg{loan: loan, err: err}
+	})
+}
+
+// terminalReturned resumes the UI after an interactive command
+func (app *App) terminalReturned(msg terminalReturnedMsg) {
+	if msg.err != nil {
+		app.Logger.Log("WARN: Terminal handover failed: %v", msg.err)
+	}
+	if !msg.loan.ran {
+		// The terminal could not be released, so the command never started
+		msg.loan.done <- fmt.Errorf("could not hand over the terminal: %w", msg.err)
+	}
+	app.ChatModel.SetThinkingStatus("Processing function result...")
+}
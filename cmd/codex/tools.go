@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+)
+
+// offeredTools returns the tools the model is offered; tools registered
+// without a schema can be called but are not offered
+func offeredTools(registry *functions.Registry) []functions.Tool {
+	var offered []functions.Tool
+	for _, tool := range registry.Tools() {
+		if tool.Parameters != nil {
+			offered = append(offered, tool)
+		}
+	}
+	return offered
+}
+
+// toolsStatus summarizes the offered tools for the status bar, like
+// "9 tools, 4 ask first (/tools)"
+func toolsStatus(registry *functions.Registry, mode config.ApprovalMode) string {
+	tools := offeredTools(registry)
+	asking := 0
+	for _, tool := range tools {
+		if executor.NeedsApproval(mode, tool.Name) {
+			asking++
+		}
+	}
+	return fmt.Sprintf("%d tools, %d ask first (/tools)", len(tools), asking)
+}
+
+// toolsInfo is the status bar summary of the tools, noting dry-run mode
+func (app *App) toolsInfo() string {
+	info := toolsStatus(app.FunctionRegistry, app.Config.ApprovalMode)
+	if app.Executor.DryRun() {
+		info += " · dry run"
+	}
+	return info
+}
+
+// toolsOrigins counts the offered tools by origin, like "8 core, 1 plugin lint"
+func toolsOrigins(tools []functions.Tool) string {
+	counts := map[string]int{}
+	var origins []string
+	for _, tool := range tools {
+		if counts[tool.Origin()] == 0 {
+			origins = append(origins, tool.Origin())
+		}
+		counts[tool.Origin()]++
+	}
+	parts := make([]string, len(origins))
+	for i, origin := range origins {
+		parts[i] = fmt.Sprintf("%d %s", counts[origin], origin)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// toolsSummary lists the offered tools for /tools: where each comes from
+// and whether the approval mode asks before it runs, and why
+func toolsSummary(registry *functions.Registry, mode config.ApprovalMode) string {
+	tools := offeredTools(registry)
+	if len(tools) == 0 {
+		return "No tools are enabled."
+	}
+	nameWidth, originWidth := 0, 0
+	for _, tool := range tools {
+		nameWidth = max(nameWidth, len(tool.Name))
+		originWidth = max(originWidth, len(tool.Origin()))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d tools enabled in %s mode (%s):\n", len(tools), mode, toolsOrigins(tools))
+	for _, tool := range tools {
+		approval := "runs freely"
+		if executor.NeedsApproval(mode, tool.Name) {
+			approval = "asks first"
+		}
+		fmt.Fprintf(&b, "  %-*s  %-*s  %s (%s)\n", nameWidth, tool.Name, originWidth, tool.Origin(), approval, executor.ApprovalReason(mode, tool.Name))
+	}
+	b.WriteString("Switch the approval mode with /approval.")
+	return b.String()
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/webfetch"
+)
+
+// setupNetworkTools registers fetch_url when the config allows network tools
+// and lists the domains they may reach
+func setupNetworkTools(registry *functions.Registry, cfg *config.Config) {
+	if !webfetch.Enabled(cfg) {
+		if cfg.AllowNetworkTools {
+			appLogger.Log("Warning: allow_network_tools is set but allowed_domains is empty; fetch_url is disabled")
+		}
+		return
+	}
+	appLogger.Log("Registering %s for domains %v", webfetch.ToolName, cfg.AllowedDomains)
+	registry.RegisterTool(webfetch.New(cfg).Tool())
+}
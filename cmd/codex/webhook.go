@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/webhook"
+)
+
+// setupWebhook streams the engine's events to the webhook_url of the config.
+// It returns nil if none is set, or if it is refused, along with the error.
+func setupWebhook(eng *engine.Engine, cfg *config.Config, sessionID string) (*webhook.Webhook, error) {
+	if cfg.WebhookURL == "" {
+		return nil, nil
+	}
+	w, err := webhook.New(cfg.WebhookURL, sessionID, cfg.CWD, appLogger)
+	if err != nil {
+		appLogger.Log("Warning: webhook disabled: %v", err)
+		return nil, err
+	}
+	appLogger.Log("Streaming session events to webhook %s", cfg.WebhookURL)
+	eng.Observers = append(eng.Observers, w)
+	return w, nil
+}
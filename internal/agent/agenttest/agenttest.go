@@ -0,0 +1,236 @@
+// Package agenttest provides a scripted agent.Agent, so the engine and the
+// App can be tested without an API key or a chat server.
+package agenttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/agent"
+)
+
+// Reply is a scripted response: text, tool calls, or both. Blocked makes it
+// a blocked response instead, and Err fails the request.
+type Reply struct {
+	Content   string
+	ToolCalls []agent.FunctionCall // IDs are generated from the call's position if empty
+	Blocked   *agent.Blocked
+	Err       error
+}
+
+// Call records a call to one of the agent's methods
+type Call struct {
+	Method   string          // "SendMessage" or "SendFunctionResult"
+	Messages []agent.Message // Sent with SendMessage
+
+	// The result sent with SendFunctionResult
+	CallID   string
+	Function string
+	Output   string
+	Success  bool
+}
+
+// MockAgent answers with scripted replies, in order: one per SendMessage
+// and one per SendFunctionResult, as OpenAIAgent requests a response for
+// each. It keeps a real history and records every call.
+type MockAgent struct {
+	t testing.TB
+
+	mu      sync.Mutex
+	replies []Reply
+	calls   []Call
+	handler agent.ResponseHandler
+	history *agent.ConversationHistory
+	cancels int
+	closed  bool
+}
+
+// New returns an agent answering with replies. Requests past the script
+// fail the test.
+func New(t testing.TB, replies ...Reply) *MockAgent {
+	history, err := agent.NewConversationHistory(agent.DefaultHistoryOptions())
+	if err != nil {
+		t.Fatalf("agenttest: %v", err)
+	}
+	return &MockAgent{t: t, replies: replies, history: history}
+}
+
+// Script adds replies to those still to be sent
+func (a *MockAgent) Script(replies ...Reply) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.replies = append(a.replies, replies...)
+}
+
+// Calls returns the calls received so far
+func (a *MockAgent) Calls() []Call {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Call(nil), a.calls...)
+}
+
+// Results returns the function results received so far
+func (a *MockAgent) Results() []Call {
+	var results []Call
+	for _, call := range a.Calls() {
+		if call.Method == "SendFunctionResult" {
+			results = append(results, call)
+		}
+	}
+	return results
+}
+
+// Remaining returns how many scripted replies have not been sent
+func (a *MockAgent) Remaining() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.replies)
+}
+
+// Cancels returns how often Cancel was called
+func (a *MockAgent) Cancels() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cancels
+}
+
+// Closed reports whether Close was called
+func (a *MockAgent) Closed() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closed
+}
+
+// SendMessage records the messages in the history and streams the next reply
+func (a *MockAgent) SendMessage(ctx context.Context, messages []agent.Message, handler agent.ResponseHandler) (bool, error) {
+	a.mu.Lock()
+	a.calls = append(a.calls, Call{Method: "SendMessage", Messages: messages})
+	a.handler = handler
+	a.mu.Unlock()
+	a.history.AddMessages(messages)
+
+	calls, err := a.respond(ctx, handler)
+	return len(calls) > 0, err
+}
+
+// SendFunctionResult records the result in the history and streams the
+// next reply, ending the turn with a "followup_complete" item if it has no
+// tool calls
+func (a *MockAgent) SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) error {
+	a.mu.Lock()
+	a.calls = append(a.calls, Call{Method: "SendFunctionResult", CallID: callID, Function: functionName, Output: output, Success: success})
+	handler := a.handler
+	a.mu.Unlock()
+	a.history.AddMessage(agent.Message{Role: "tool", ToolCallID: callID, Name: functionName, Content: output})
+	if handler == nil {
+		return nil
+	}
+
+	calls, err := a.respond(ctx, handler)
+	if err == nil && len(calls) == 0 {
+		emit(handler, agent.ResponseItem{Type: "followup_complete"})
+	}
+	return err
+}
+
+// respond streams the next reply to handler and returns its tool calls
+func (a *MockAgent) respond(ctx context.Context, handler agent.ResponseHandler) ([]agent.FunctionCall, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	if len(a.replies) == 0 {
+		n := len(a.calls)
+		a.mu.Unlock()
+		a.t.Errorf("agenttest: unexpected request %d past the scripted replies", n)
+		return nil, errors.New("agenttest: no scripted reply left")
+	}
+	reply := a.replies[0]
+	a.replies = a.replies[1:]
+	n := len(a.calls)
+	a.mu.Unlock()
+
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	if reply.Content != "" {
+		emit(handler, agent.ResponseItem{Type: "message", Message: &agent.Message{Role: "assistant", Content: reply.Content}})
+	}
+	if reply.Blocked != nil {
+		a.history.AddMessage(agent.Message{Role: "assistant", Content: reply.Blocked.HistoryContent(reply.Content)})
+		emit(handler, agent.ResponseItem{Type: "blocked", Blocked: reply.Blocked})
+		return nil, nil
+	}
+	if len(reply.ToolCalls) == 0 {
+		if reply.Content != "" {
+			a.history.AddMessage(agent.Message{Role: "assistant", Content: reply.Content})
+		}
+		return nil, nil
+	}
+
+	calls := make([]agent.FunctionCall, len(reply.ToolCalls))
+	toolCalls := make([]agent.ToolCall, len(reply.ToolCalls))
+	for i, call := range reply.ToolCalls {
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d_%d", n, i+1)
+		}
+		calls[i] = call
+		toolCalls[i] = agent.ToolCall{ID: call.ID, Type: "function", Function: call}
+	}
+	a.history.AddMessage(agent.Message{Role: "assistant", Content: reply.Content, ToolCalls: toolCalls})
+	for i := range calls {
+		emit(handler, agent.ResponseItem{Type: "function_call", FunctionCall: &calls[i]})
+	}
+	return calls, nil
+}
+
+// emit sends item to handler as JSON
+func emit(handler agent.ResponseHandler, item agent.ResponseItem) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		panic(err)
+	}
+	handler(string(data))
+}
+
+// SendFileChange approves every file change
+func (a *MockAgent) SendFileChange(ctx context.Context, filePath string, diff string) (*agent.FileChangeConfirmation, error) {
+	return &agent.FileChangeConfirmation{Approved: true}, nil
+}
+
+// GetCommandConfirmation approves every command
+func (a *MockAgent) GetCommandConfirmation(ctx context.Context, command string, args []string) (*agent.CommandConfirmation, error) {
+	return &agent.CommandConfirmation{Approved: true}, nil
+}
+
+// ClearHistory clears the history
+func (a *MockAgent) ClearHistory() {
+	a.history.Clear()
+}
+
+// GetHistory returns the history of the messages sent and replies given
+func (a *MockAgent) GetHistory() *agent.ConversationHistory {
+	return a.history
+}
+
+// Cancel records the cancellation
+func (a *MockAgent) Cancel() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cancels++
+}
+
+// Close records that the agent was closed
+func (a *MockAgent) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	return nil
+}
+
+// MockAgent implements agent.Agent
+var _ agent.Agent = (*MockAgent)(nil)
@@ -0,0 +1,62 @@
+package agenttest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/agent"
+)
+
+func TestMockAgent(t *testing.T) {
+	a := New(t,
+		Reply{Content: "Looking.", ToolCalls: []agent.FunctionCall{{Name: "read_file", Arguments: `{"path":"go.mod"}`}}},
+		Reply{Content: "It is a Go module."},
+	)
+	var items []string
+	handler := func(itemJSON string) {
+		var item agent.ResponseItem
+		if err := json.Unmarshal([]byte(itemJSON), &item); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item.Type)
+		if item.FunctionCall != nil && item.FunctionCall.ID != "call_1_1" {
+			t.Errorf("Expected a generated call ID, got %q", item.FunctionCall.ID)
+		}
+	}
+
+	toolCalls, err := a.SendMessage(context.Background(), []agent.Message{{Role: "user", Content: "what is this?"}}, handler)
+	if err != nil || !toolCalls {
+		t.Fatalf("Expected a tool call, got %t, %v", toolCalls, err)
+	}
+	if err := a.SendFunctionResult(context.Background(), "call_1_1", "read_file", "module example", true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"message", "function_call", "message", "followup_complete"}
+	if len(items) != len(want) {
+		t.Fatalf("Expected items %v, got %v", want, items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Fatalf("Expected items %v, got %v", want, items)
+		}
+	}
+	if results := a.Results(); len(results) != 1 || results[0].Output != "module example" {
+		t.Errorf("Expected the result to be recorded, got %+v", results)
+	}
+
+	// The history holds the turn as OpenAIAgent records it
+	var roles []string
+	for _, msg := range a.GetHistory().GetMessages() {
+		if msg.Role != "system" {
+			roles = append(roles, msg.Role)
+		}
+	}
+	if len(roles) != 4 || roles[0] != "user" || roles[1] != "assistant" || roles[2] != "tool" || roles[3] != "assistant" {
+		t.Errorf("Unexpected history: %v", roles)
+	}
+	if a.Remaining() != 0 {
+		t.Errorf("Expected the script to be used up, %d replies left", a.Remaining())
+	}
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Approval decisions
+const (
+	ApprovalApproved = "approved"
+	ApprovalDenied   = "denied"
+)
+
+// Who made an approval decision
+const (
+	DecidedByUser   = "user"   // The user answered an approval prompt
+	DecidedByPolicy = "policy" // The approval mode decided without asking
+)
+
+// ApprovalEvent records whether a function call was authorized to run. The
+// arguments are kept as a hash, so the record identifies exactly what was
+// authorized without duplicating file contents or secrets.
+type ApprovalEvent struct {
+	CallID    string    `json:"call_id"`
+	Tool      string    `json:"tool"`
+	ArgsHash  string    `json:"args_hash"`
+	Decision  string    `json:"decision"`
+	DecidedBy string    `json:"decided_by"`
+	Mode      string    `json:"mode"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewApprovalEvent records a decision on call made now
+func NewApprovalEvent(call FunctionCall, mode string, approved bool, decidedBy string) ApprovalEvent {
+	decision := ApprovalDenied
+	if approved {
+		decision = ApprovalApproved
+	}
+	return ApprovalEvent{
+		CallID:    call.ID,
+		Tool:      call.Name,
+		ArgsHash:  HashArguments(call.Arguments),
+		Decision:  decision,
+		DecidedBy: decidedBy,
+		Mode:      mode,
+		Timestamp: time.Now(),
+	}
+}
+
+// HashArguments returns the SHA-256 of a call's raw JSON arguments as "sha256:<hex>"
+func HashArguments(args string) string {
+	sum := sha256.Sum256([]byte(args))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Approved reports whether the call was allowed to run
+func (e ApprovalEvent) Approved() bool {
+	return e.Decision == ApprovalApproved
+}
+
+// String describes the event in one line, with the hash shortened
+func (e ApprovalEvent) String() string {
+	hash := e.ArgsHash
+	if len(hash) > len("sha256:")+12 {
+		hash = hash[:len("sha256:")+12]
+	}
+	s := fmt.Sprintf("%s %s (args %s) by %s in %s mode", e.Decision, e.Tool, hash, e.DecidedBy, e.Mode)
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"math"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/contextbudget"
+	"github.com/epuerta/codex-go/internal/models"
+)
+
+// contextTokens returns the budget of the messages of a request: the
+// context_tokens setting, or three quarters of the smallest context window
+// among the models that may serve the turn, leaving room for the tool
+// definitions and the reply. It returns 0, no limit, if no window is known.
+func (a *OpenAIAgent) contextTokens() int {
+	if a.config.ContextTokens > 0 {
+		return a.config.ContextTokens
+	}
+	window := 0
+	for _, model := range a.config.Models() {
+		if caps, ok := models.Lookup(model); ok && caps.ContextWindow > 0 && (window == 0 || caps.ContextWindow < window) {
+			window = caps.ContextWindow
+		}
+	}
+	return window * 3 / 4
+}
+
+// assembledContext returns the messages of the history fitted into the
+// context budget
+func (a *OpenAIAgent) assembledContext(caller string) []Message {
+	weights, err := contextbudget.ParseWeights(a.config.ContextWeights)
+	if err != nil {
+		a.logger.Log("[WARN] Agent.%s: %v; using the default context weights", caller, err)
+		weights = contextbudget.DefaultWeights()
+	}
+	total := a.contextTokens()
+	messages, cut := assembleContext(a.history.GetMessagesForContext(), total, weights)
+	if cut {
+		a.logger.Log("[INFO] Agent.%s: Context cut to its budget of %d tokens: %d of %d messages kept", caller, total, len(messages), len(a.history.GetMessagesForContext()))
+	}
+	return messages
+}
+
+// assembleContext fits messages into total tokens (no limit if 0 or less),
+// sharing them among the sources of the context by weight. The system
+// prompt, project docs, memory, repository map and retrieved files are cut
+// at the end of their messages, in order; the history keeps its most recent
+// turns, with tool calls and their results kept or dropped together, and
+// always its latest one. The messages themselves are not changed.
+func assembleContext(messages []Message, total int, weights contextbudget.Weights) (assembled []Message, cut bool) {
+	if total <= 0 {
+		return messages, false
+	}
+	sources := make([]contextbudget.Source, len(messages))
+	demand := make(map[contextbudget.Source]int)
+	for i, msg := range messages {
+		sources[i] = messageSource(i, msg)
+		demand[sources[i]] += messageTokens(msg)
+	}
+	alloc := contextbudget.Allocate(total, weights, demand)
+
+	keep := make([]bool, len(messages))
+	content := make([]string, len(messages))
+	left := make(map[contextbudget.Source]int, len(alloc))
+	for s, tokens := range alloc {
+		left[s] = tokens
+	}
+	var history []int
+	for i, msg := range messages {
+		content[i] = msg.Content
+		if sources[i] == contextbudget.History {
+			history = append(history, i)
+			continue
+		}
+		tokens := messageTokens(msg)
+		if tokens <= left[sources[i]] {
+			keep[i] = true
+			left[sources[i]] -= tokens
+			continue
+		}
+		cut = true
+		room := left[sources[i]] - (tokens - contentTokens(msg.Content))
+		if text := contextbudget.Truncate(msg.Content, room); text != "" {
+			keep[i] = true
+			content[i] = text
+		}
+		left[sources[i]] = 0
+	}
+
+	// The history keeps whole units from the latest back while they fit
+	units := messageUnits(pick(messages, history))
+	start, used := len(units), 0
+	for start > 0 {
+		tokens := 0
+		for _, msg := range units[start-1] {
+			tokens += messageTokens(msg)
+		}
+		if start < len(units) && used+tokens > alloc[contextbudget.History] {
+			break
+		}
+		start--
+		used += tokens
+	}
+	kept := dropOrphanResults(units[start:])
+	dropped := len(history)
+	for _, unit := range kept {
+		dropped -= len(unit)
+	}
+	for _, i := range history[dropped:] {
+		keep[i] = true
+	}
+	cut = cut || dropped > 0
+
+	for i, msg := range messages {
+		if keep[i] {
+			msg.Content = content[i]
+			assembled = append(assembled, msg)
+		}
+	}
+	return assembled, cut
+}
+
+// messageSource returns the part of the context msg holds. Messages saved
+// before sources were recorded count as history, except the system prompt
+// starting the history.
+func messageSource(i int, msg Message) contextbudget.Source {
+	switch {
+	case msg.Source != "":
+		return msg.Source
+	case i == 0 && msg.Role == "system" && !strings.HasPrefix(msg.Content, summaryPrefix):
+		return contextbudget.SystemPrompt
+	default:
+		return contextbudget.History
+	}
+}
+
+// messageTokens estimates the tokens of msg: a base overhead, about 4
+// characters a token of text, and images billed by tile rather than by the
+// size of their data URL
+func messageTokens(msg Message) int {
+	return 4 + contentTokens(msg.Content) + len(msg.Images)*imageTokenEstimate
+}
+
+// contentTokens estimates the tokens of the text of a message
+func contentTokens(content string) int {
+	return int(math.Ceil(float64(len(content)) / 4))
+}
+
+// pick returns the messages at indexes, in order
+func pick(messages []Message, indexes []int) []Message {
+	picked := make([]Message, len(indexes))
+	for j, i := range indexes {
+		picked[j] = messages[i]
+	}
+	return picked
+}
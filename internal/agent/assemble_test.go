@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/contextbudget"
+)
+
+func TestAssembleContext(t *testing.T) {
+	text := func(n int) string { return strings.Repeat("abc\n", n) }
+	messages := []Message{
+		{Role: "system", Content: text(100), Source: contextbudget.SystemPrompt},
+		{Role: "system", Content: text(1000), Source: contextbudget.ProjectDocs},
+		{Role: "user", Content: text(100)},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "c1", Type: "function", Function: FunctionCall{Name: "read_file"}}}},
+		{Role: "tool", Content: text(100), ToolCallID: "c1"},
+		{Role: "assistant", Content: text(100)},
+		{Role: "user", Content: "What next?"},
+	}
+	weights := contextbudget.Weights{contextbudget.SystemPrompt: 20, contextbudget.ProjectDocs: 20, contextbudget.History: 60}
+
+	if got, cut := assembleContext(messages, 0, weights); cut || !reflect.DeepEqual(got, messages) {
+		t.Errorf("Expected no limit to keep every message")
+	}
+	if got, cut := assembleContext(messages, 5000, weights); cut || !reflect.DeepEqual(got, messages) {
+		t.Errorf("Expected messages within the budget to be kept as they are")
+	}
+
+	// 500 tokens: 100 for the system prompt and the docs each, 300 for the
+	// history, which keeps the tool call with its result but not the first
+	// user message
+	got, cut := assembleContext(messages, 500, weights)
+	if !cut || len(got) != 6 {
+		t.Fatalf("Expected the first user message to be dropped, got %d messages", len(got))
+	}
+	for i, want := range []contextbudget.Source{contextbudget.SystemPrompt, contextbudget.ProjectDocs} {
+		if !strings.HasSuffix(got[i].Content, "[... cut to fit the context budget]") || messageTokens(got[i]) > 100 {
+			t.Errorf("Expected the %s cut to 100 tokens, got %d", want, messageTokens(got[i]))
+		}
+	}
+	if !reflect.DeepEqual(got[2:], messages[3:]) {
+		t.Errorf("Unexpected history kept: %+v", got[2:])
+	}
+	if messages[0].Content != text(100) {
+		t.Error("Expected the messages themselves to be left as they are")
+	}
+	again, _ := assembleContext(messages, 500, weights)
+	if !reflect.DeepEqual(again, got) {
+		t.Error("Expected the same messages every time")
+	}
+
+	// The latest turn is sent even if it is over the budget alone
+	got, _ = assembleContext([]Message{{Role: "user", Content: text(1000)}}, 100, weights)
+	if len(got) != 1 || got[0].Content != text(1000) {
+		t.Errorf("Expected the latest message to be kept whole, got %+v", got)
+	}
+}
+
+func TestMessageSource(t *testing.T) {
+	for i, tt := range []struct {
+		index int
+		msg   Message
+		want  contextbudget.Source
+	}{
+		{0, Message{Role: "system", Content: "You are a coding assistant."}, contextbudget.SystemPrompt},
+		{0, Message{Role: "system", Content: summaryPrefix + "the user asked..."}, contextbudget.History},
+		{1, Message{Role: "system", Content: "Note"}, contextbudget.History},
+		{2, Message{Role: "system", Content: "Snippets", Source: contextbudget.RetrievedFiles}, contextbudget.RetrievedFiles},
+		{3, Message{Role: "user", Content: "hi"}, contextbudget.History},
+	} {
+		if got := messageSource(tt.index, tt.msg); got != tt.want {
+			t.Errorf("%d: messageSource() = %s, want %s", i, got, tt.want)
+		}
+	}
+}
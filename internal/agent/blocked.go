@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Why a response was blocked
+const (
+	BlockedContentFilter = "content_filter" // The provider's filter stopped the response
+	BlockedRefusal       = "refusal"        // The model declined to answer
+)
+
+// contentFilterFinishReasons are the finish reasons providers use when their
+// filters stop a response. OpenAI and Mistral send "content_filter"; Gemini's
+// OpenAI-compatible endpoint passes on its own reasons.
+var contentFilterFinishReasons = map[string]bool{
+	"content_filter":     true,
+	"safety":             true,
+	"recitation":         true,
+	"blocklist":          true,
+	"prohibited_content": true,
+	"spii":               true,
+}
+
+// Blocked describes a response that a content filter stopped or that the
+// model refused to give
+type Blocked struct {
+	Reason       string `json:"reason"`                  // BlockedContentFilter or BlockedRefusal
+	FinishReason string `json:"finish_reason,omitempty"` // As sent by the provider
+	Refusal      string `json:"refusal,omitempty"`       // The model's explanation, for refusals
+	Partial      bool   `json:"partial,omitempty"`       // Part of the response was streamed before it stopped
+}
+
+// blockedResponse returns what blocked a stream that ended with finishReason
+// after streaming refusal, or nil if it completed normally
+func blockedResponse(finishReason, refusal string, partial bool) *Blocked {
+	refusal = strings.TrimSpace(refusal)
+	switch {
+	case refusal != "":
+		return &Blocked{Reason: BlockedRefusal, FinishReason: finishReason, Refusal: refusal, Partial: partial}
+	case contentFilterFinishReasons[strings.ToLower(finishReason)]:
+		return &Blocked{Reason: BlockedContentFilter, FinishReason: finishReason, Partial: partial}
+	}
+	return nil
+}
+
+// Explain describes the block for the user
+func (b *Blocked) Explain() string {
+	if b.Reason == BlockedRefusal {
+		return fmt.Sprintf("The model declined to respond: %s", b.Refusal)
+	}
+	msg := fmt.Sprintf("The provider's content filter stopped the response (finish reason %q).", b.FinishReason)
+	if b.Partial {
+		msg += " What was shown is incomplete, and any tool call it was making was dropped."
+	}
+	return msg + " Rephrasing the request, or leaving out the content that triggered the filter, usually helps."
+}
+
+// HistoryContent is the assistant message recorded in the history in place of
+// the blocked response. The history keeps a well-formed assistant turn, so
+// the next request neither repeats the user's message without an answer nor
+// carries a tool call whose arguments were cut off.
+func (b *Blocked) HistoryContent(partial string) string {
+	if b.Reason == BlockedRefusal {
+		if partial != "" {
+			return partial + "\n\n" + b.Refusal
+		}
+		return b.Refusal
+	}
+	note := fmt.Sprintf("[Response stopped by the provider's content filter (%s).]", b.FinishReason)
+	if partial != "" {
+		return partial + "\n\n" + note
+	}
+	return note
+}
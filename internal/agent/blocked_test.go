@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/config"
+)
+
+// serveDeltas replays deltas as a chat completion SSE stream ending with finishReason
+func serveDeltas(deltas []map[string]interface{}, finishReason string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		send := func(delta map[string]interface{}, finishReason interface{}) {
+			data, _ := json.Marshal(map[string]interface{}{
+				"id": "chatcmpl-test", "object": "chat.completion.chunk", "model": "test",
+				"choices": []interface{}{map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finishReason}},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		send(map[string]interface{}{"role": "assistant"}, nil)
+		for _, delta := range deltas {
+			send(delta, nil)
+		}
+		send(map[string]interface{}{}, finishReason)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestBlockedResponses(t *testing.T) {
+	tests := []struct {
+		name         string
+		deltas       []map[string]interface{}
+		finishReason string
+		wantReason   string
+		wantHistory  string
+	}{
+		{
+			name: "content filter during a tool call",
+			deltas: []map[string]interface{}{{"tool_calls": []interface{}{map[string]interface{}{
+				"index": 0, "id": "call_1", "type": "function",
+				"function": map[string]interface{}{"name": "shell", "arguments": `{"comm`},
+			}}}},
+			finishReason: "content_filter",
+			wantReason:   BlockedContentFilter,
+			wantHistory:  "[Response stopped by the provider's content filter (content_filter).]",
+		},
+		{
+			name:         "content filter after some text",
+			deltas:       []map[string]interface{}{{"content": "Here is"}},
+			finishReason: "SAFETY",
+			wantReason:   BlockedContentFilter,
+			wantHistory:  "Here is\n\n[Response stopped by the provider's content filter (SAFETY).]",
+		},
+		{
+			name:         "refusal",
+			deltas:       []map[string]interface{}{{"refusal": "I can't help "}, {"refusal": "with that."}},
+			finishReason: "stop",
+			wantReason:   BlockedRefusal,
+			wantHistory:  "I can't help with that.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := serveDeltas(tt.deltas, tt.finishReason)
+			defer ts.Close()
+
+			ai, err := NewOpenAIAgent(&config.Config{APIKey: "test", Model: "test", BaseURL: ts.URL}, nil)
+			if err != nil {
+				t.Fatalf("NewOpenAIAgent failed: %v", err)
+			}
+			var blocked *Blocked
+			var calls int
+			requested, err := ai.SendMessage(context.Background(), []Message{{Role: "user", Content: "go"}}, func(itemJSON string) {
+				var item ResponseItem
+				json.Unmarshal([]byte(itemJSON), &item)
+				switch item.Type {
+				case "blocked":
+					blocked = item.Blocked
+				case "function_call":
+					calls++
+				}
+			})
+			if err != nil {
+				t.Fatalf("SendMessage failed: %v", err)
+			}
+			if requested || calls != 0 {
+				t.Errorf("Expected no tool calls, got %d (requested %t)", calls, requested)
+			}
+			if blocked == nil || blocked.Reason != tt.wantReason || blocked.FinishReason != tt.finishReason {
+				t.Fatalf("Expected a %s block, got %+v", tt.wantReason, blocked)
+			}
+
+			// The history must end with a complete assistant message and no tool calls
+			last, _ := ai.GetHistory().GetLastMessage()
+			if last.Role != "assistant" || last.Content != tt.wantHistory || len(last.ToolCalls) != 0 {
+				t.Errorf("Unexpected last history message: %+v", last)
+			}
+		})
+	}
+}
+
+func TestBlockedExplain(t *testing.T) {
+	if blockedResponse("stop", "", true) != nil || blockedResponse("length", " ", false) != nil {
+		t.Error("Expected normal finish reasons not to be blocks")
+	}
+	b := blockedResponse("content_filter", "", true)
+	if msg := b.Explain(); !strings.Contains(msg, "content filter") || !strings.Contains(msg, "incomplete") {
+		t.Errorf("Unexpected explanation: %q", msg)
+	}
+	b = blockedResponse("stop", "Not allowed.", false)
+	if msg := b.Explain(); msg != "The model declined to respond: Not allowed." {
+		t.Errorf("Unexpected explanation: %q", msg)
+	}
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/respcache"
+	"github.com/sashabaranov/go-openai"
+)
+
+// cachedResponse is a streamResponse as kept in the response cache
+type cachedResponse struct {
+	Role         string         `json:"role"`
+	Content      string         `json:"content,omitempty"`
+	FinishReason string         `json:"finish_reason,omitempty"`
+	ToolCalls    []FunctionCall `json:"tool_calls,omitempty"`
+}
+
+// SetResponseCache makes the agent answer requests it has seen before from
+// cache, and store the responses to new ones there. A nil cache turns
+// caching off.
+func (a *OpenAIAgent) SetResponseCache(cache *respcache.Cache) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cache = cache
+}
+
+// CacheHits returns the number of responses served from the response cache
+func (a *OpenAIAgent) CacheHits() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cacheHits
+}
+
+// cacheKey returns the response cache key of req, or "" when caching is off
+func (a *OpenAIAgent) cacheKey(caller string, req openai.ChatCompletionRequest) string {
+	a.mu.Lock()
+	cache := a.cache
+	a.mu.Unlock()
+	if cache == nil {
+		return ""
+	}
+	key, err := respcache.Key(req)
+	if err != nil {
+		a.logger.Log("[WARN] Agent.%s: Not caching the response: %v", caller, err)
+		return ""
+	}
+	return key
+}
+
+// replayResponse returns the cached response of key, sending its text to
+// handler as a "message" item the way processStream would, or nil if there
+// is none
+func (a *OpenAIAgent) replayResponse(caller, key string, handler ResponseHandler, startTime time.Time) *streamResponse {
+	if key == "" {
+		return nil
+	}
+	var cached cachedResponse
+	if !a.cache.Get(key, &cached) {
+		return nil
+	}
+	a.mu.Lock()
+	a.cacheHits++
+	a.mu.Unlock()
+	a.logger.Log("[DEBUG] Agent.%s: Using the cached response %s.", caller, key[:12])
+
+	res := &streamResponse{
+		Role:         cached.Role,
+		Content:      cached.Content,
+		FinishReason: cached.FinishReason,
+		ToolCalls:    newToolCallAccumulator(ToolCallProfile{}),
+		StartTime:    startTime,
+	}
+	for i, call := range cached.ToolCalls {
+		index := i
+		res.ToolCalls.Add(openai.ToolCall{
+			Index:    &index,
+			ID:       call.ID,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+		})
+	}
+	if res.Content != "" {
+		jsonData, err := json.Marshal(ResponseItem{
+			Type:    "message",
+			Message: &Message{Role: res.Role, Content: res.Content},
+		})
+		if err == nil {
+			handler(string(jsonData))
+		}
+	}
+	return res
+}
+
+// storeResponse caches a complete response under key. Refused, cut off
+// and empty responses are not kept, so the next run asks again.
+func (a *OpenAIAgent) storeResponse(caller, key string, res *streamResponse) {
+	if key == "" || res.Refusal != "" {
+		return
+	}
+	if res.FinishReason != string(openai.FinishReasonStop) && res.FinishReason != string(openai.FinishReasonToolCalls) {
+		return
+	}
+	cached := cachedResponse{Role: res.Role, Content: res.Content, FinishReason: res.FinishReason}
+	if res.ToolCalls.Len() > 0 {
+		cached.ToolCalls = res.ToolCalls.Calls()
+	}
+	if cached.Content == "" && len(cached.ToolCalls) == 0 {
+		return
+	}
+	if err := a.cache.Put(key, cached); err != nil {
+		a.logger.Log("[WARN] Agent.%s: Failed to cache the response: %v", caller, err)
+	}
+}
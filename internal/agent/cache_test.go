@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/respcache"
+)
+
+func TestResponseCache(t *testing.T) {
+	responses := []string{
+		streamChunk("Let me look. ", "") + toolCallChunk("", "call_1", "list_directory", `{"path":"."}`),
+		streamChunk("All done.", "stop"),
+	}
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1) - 1
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, responses[n%2]+"data: [DONE]\n\n")
+	}))
+	defer ts.Close()
+	cache := respcache.New(t.TempDir(), time.Hour)
+
+	// run plays a whole turn with a new agent and returns the calls and
+	// final message it got
+	run := func(prompt string) ([]FunctionCall, string) {
+		a := newTestAgent(t, ts.URL, 0)
+		a.SetResponseCache(cache)
+		var calls []FunctionCall
+		var final string
+		handler := func(itemJSON string) {
+			var item ResponseItem
+			json.Unmarshal([]byte(itemJSON), &item)
+			switch item.Type {
+			case "function_call":
+				calls = append(calls, *item.FunctionCall)
+			case "message":
+				final = item.Message.Content
+			}
+		}
+		if _, err := a.SendMessage(context.Background(), []Message{{Role: "user", Content: prompt}}, handler); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.SendFunctionResult(context.Background(), "call_1", "list_directory", "main.go", true); err != nil {
+			t.Fatal(err)
+		}
+		return calls, final
+	}
+
+	calls, final := run("summarize main.go")
+	if requests.Load() != 2 || final != "All done." {
+		t.Fatalf("First run: %d requests, final message %q", requests.Load(), final)
+	}
+
+	calls, final = run("summarize main.go")
+	if requests.Load() != 2 {
+		t.Errorf("Expected the second run to be answered from cache, got %d requests", requests.Load())
+	}
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Name != "list_directory" {
+		t.Errorf("Expected the cached tool call, got %+v", calls)
+	}
+	if final != "All done." {
+		t.Errorf("Expected the cached final message, got %q", final)
+	}
+
+	// A different prompt is a different request
+	run("summarize go.mod")
+	if requests.Load() != 4 {
+		t.Errorf("Expected a new prompt to reach the API, got %d requests", requests.Load())
+	}
+}
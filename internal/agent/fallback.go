@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ModelFallback describes a request that the model_fallbacks chain passed
+// on to the next model
+type ModelFallback struct {
+	From  string `json:"from"`  // The model that failed
+	To    string `json:"to"`    // The model tried next
+	Error string `json:"error"` // Why From failed
+}
+
+// Explain describes the fallback for the user
+func (f *ModelFallback) Explain() string {
+	return fmt.Sprintf("%s could not serve the request (%s); %s is answering instead.", f.From, f.Error, f.To)
+}
+
+// canFallBack reports whether a request failing with err may succeed with
+// another model: the model is unknown, rate limited or overloaded, or
+// refused the request. Authentication and network failures would fail the
+// same way with any model.
+func canFallBack(err error) bool {
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+	switch status {
+	case 0, http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	}
+	return status >= 400
+}
+
+// createStreamWithFallbacks opens a completion stream with the model serving
+// the turn, moving down model_fallbacks while models fail. The model that
+// answers keeps serving the rest of the turn; each fallback is reported to
+// handler as a "model_fallback" item.
+func (a *OpenAIAgent) createStreamWithFallbacks(ctx context.Context, req openai.ChatCompletionRequest, caller string, handler ResponseHandler) (*openai.ChatCompletionStream, openai.ChatCompletionRequest, error) {
+	models := a.config.Models()
+	a.mu.Lock()
+	i := min(a.fallback, len(models)-1)
+	a.mu.Unlock()
+	for ; ; i++ {
+		req.Model = models[i]
+		stream, err := a.createStream(ctx, req)
+		if err == nil || i == len(models)-1 || !canFallBack(err) || ctx.Err() != nil {
+			return stream, req, err
+		}
+
+		fallback := ModelFallback{From: models[i], To: models[i+1], Error: err.Error()}
+		a.logger.Log("[WARN] Agent.%s: Model %s failed, falling back to %s: %v", caller, fallback.From, fallback.To, err)
+		a.mu.Lock()
+		a.fallback = i + 1
+		a.mu.Unlock()
+		if handler == nil {
+			continue
+		}
+		if jsonData, err := json.Marshal(ResponseItem{Type: "model_fallback", Fallback: &fallback}); err == nil {
+			handler(string(jsonData))
+		}
+	}
+}
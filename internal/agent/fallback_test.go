@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestModelFallbacks(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requested = append(requested, req.Model)
+		mu.Unlock()
+		if req.Model != "gpt-4o-mini" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":{"message":"The model does not exist","type":"invalid_request_error","code":"model_not_found"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("Hi", "stop")+"data: [DONE]\n\n")
+	}))
+	defer ts.Close()
+
+	a, err := NewOpenAIAgent(&config.Config{APIKey: "sk-test", BaseURL: ts.URL, Model: "gpt-5", ModelFallbacks: []string{"gpt-4o", "gpt-4o-mini"}}, nil)
+	if err != nil {
+		t.Fatalf("NewOpenAIAgent: %v", err)
+	}
+	var fallbacks []ModelFallback
+	handler := func(itemJSON string) {
+		var item ResponseItem
+		json.Unmarshal([]byte(itemJSON), &item)
+		if item.Type == "model_fallback" {
+			fallbacks = append(fallbacks, *item.Fallback)
+		}
+	}
+
+	if _, err := a.SendMessage(context.Background(), []Message{{Role: "user", Content: "hi"}}, handler); err != nil {
+		t.Fatalf("Expected the last fallback to answer, got %v", err)
+	}
+	if len(fallbacks) != 2 || fallbacks[0].From != "gpt-5" || fallbacks[1].To != "gpt-4o-mini" {
+		t.Errorf("Expected two fallbacks to be reported, got %+v", fallbacks)
+	}
+
+	// The model that answered serves the rest of the turn
+	if err := a.SendFunctionResult(context.Background(), "call_1", "shell", "ok", true); err != nil {
+		t.Fatalf("SendFunctionResult: %v", err)
+	}
+	want := []string{"gpt-5", "gpt-4o", "gpt-4o-mini", "gpt-4o-mini"}
+	if len(requested) != len(want) {
+		t.Fatalf("Expected requests for %v, got %v", want, requested)
+	}
+	for i := range want {
+		if requested[i] != want[i] {
+			t.Fatalf("Expected requests for %v, got %v", want, requested)
+		}
+	}
+
+	// A new turn tries the configured model first
+	requested = nil
+	a.SendMessage(context.Background(), []Message{{Role: "user", Content: "again"}}, handler)
+	if len(requested) == 0 || requested[0] != "gpt-5" {
+		t.Errorf("Expected a new turn to start with the configured model, got %v", requested)
+	}
+}
+
+func TestCanFallBack(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&openai.APIError{HTTPStatusCode: http.StatusNotFound, Code: "model_not_found"}, true},
+		{&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, true},
+		{&openai.APIError{HTTPStatusCode: http.StatusBadRequest}, true},
+		{&openai.RequestError{HTTPStatusCode: http.StatusServiceUnavailable}, true},
+		{&openai.APIError{HTTPStatusCode: http.StatusUnauthorized}, false},
+		{&openai.APIError{HTTPStatusCode: http.StatusForbidden}, false},
+		{errors.New("dial tcp: connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := canFallBack(tt.err); got != tt.want {
+			t.Errorf("canFallBack(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package agent
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -140,6 +144,77 @@ func TestPruneIfNeeded(t *testing.T) {
 	}
 }
 
+// checkToolPairs fails the test if a tool result lacks the assistant message
+// calling it just before, or a tool call other than the latest lacks a result
+func checkToolPairs(t *testing.T, messages []Message) {
+	t.Helper()
+	pending := map[string]bool{}
+	for i, msg := range messages {
+		switch {
+		case msg.Role == "tool":
+			if !pending[msg.ToolCallID] {
+				t.Fatalf("Message %d is a result for %s without its call: %+v", i, msg.ToolCallID, messages)
+			}
+			delete(pending, msg.ToolCallID)
+		case len(pending) > 0:
+			t.Fatalf("Message %d follows calls without results %v: %+v", i, pending, messages)
+		case msg.Role == "assistant":
+			for _, call := range msg.ToolCalls {
+				pending[call.ID] = true
+			}
+		}
+	}
+}
+
+func TestPruneKeepsToolCallPairs(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	for limit := 50; limit <= 400; limit += 25 {
+		history := &ConversationHistory{MaxTokenCount: limit}
+		history.AddMessage(Message{Role: "system", Content: "You are a helpful assistant."})
+		for i := 0; i < 4; i++ {
+			id1, id2 := fmt.Sprintf("call_%d_a", i), fmt.Sprintf("call_%d_b", i)
+			history.AddMessages([]Message{
+				{Role: "user", Content: "Read the two files and compare them."},
+				{Role: "assistant", ToolCalls: []ToolCall{{ID: id1, Type: "function"}, {ID: id2, Type: "function"}}},
+				{Role: "tool", ToolCallID: id1, Content: strings.Repeat("first file ", 20)},
+				{Role: "tool", ToolCallID: id2, Content: strings.Repeat("second file ", 20)},
+				{Role: "assistant", Content: "They differ in one line."},
+			})
+			checkToolPairs(t, history.Messages)
+		}
+		if history.Messages[0].Role != "system" {
+			t.Errorf("Limit %d: expected the system message to be kept, got %+v", limit, history.Messages[0])
+		}
+	}
+}
+
+func TestMessageUnits(t *testing.T) {
+	units := messageUnits([]Message{
+		{Role: "tool", ToolCallID: "gone"},
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "a"}, {ID: "b"}}},
+		{Role: "tool", ToolCallID: "a"},
+		{Role: "tool", ToolCallID: "b"},
+		{Role: "assistant", Content: "done"},
+	})
+	sizes := []int{}
+	for _, unit := range units {
+		sizes = append(sizes, len(unit))
+	}
+	if fmt.Sprint(sizes) != "[1 1 3 1]" {
+		t.Fatalf("Unexpected unit sizes %v", sizes)
+	}
+	if got := recentMessages(units, 4); len(got) != 4 || got[0].Role != "assistant" || len(got[0].ToolCalls) != 2 {
+		t.Errorf("Expected the tool call unit and the reply, got %+v", got)
+	}
+	if got := recentMessages(units[:3], 2); len(got) != 3 {
+		t.Errorf("Expected the latest unit whole even past the limit, got %+v", got)
+	}
+	if got := dropOrphanResults(units); len(got) != 3 || got[0][0].Role != "user" {
+		t.Errorf("Expected the orphaned result to be dropped, got %+v", got)
+	}
+}
+
 func TestSaveAndLoad(t *testing.T) {
 	// Create a temporary directory for the test
 	tempDir, err := os.MkdirTemp("", "history-save-test")
@@ -245,6 +320,13 @@ func TestEstimateTokenCount(t *testing.T) {
 		t.Errorf("Token count estimate %d outside expected range %d-%d",
 			tokenCount, expectedMinimum, expectedMaximum)
 	}
+
+	// Images count by tile, not by the length of their data URL
+	history.AddMessage(Message{Role: "user", Content: "", Images: []string{"data:image/png;base64," + strings.Repeat("A", 100000)}})
+	withImage := history.EstimateTokenCount()
+	if withImage != tokenCount+4+imageTokenEstimate {
+		t.Errorf("Expected image to add %d tokens, got %d", 4+imageTokenEstimate, withImage-tokenCount)
+	}
 }
 
 func TestClear(t *testing.T) {
@@ -269,3 +351,102 @@ func TestClear(t *testing.T) {
 		t.Errorf("Expected 0 messages after clear, got %d", len(history.Messages))
 	}
 }
+
+func TestReplaceFrom(t *testing.T) {
+	history := &ConversationHistory{
+		Messages: []Message{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user", Content: "Explore the repository."},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function"}}},
+			{Role: "tool", ToolCallID: "call_1", Content: strings.Repeat("file contents ", 100)},
+		},
+		MaxTokenCount: 1000,
+	}
+
+	history.ReplaceFrom(1, []Message{{Role: "assistant", Content: "Summary"}})
+	if len(history.Messages) != 2 || history.Messages[0].Role != "system" || history.Messages[1].Content != "Summary" {
+		t.Errorf("Unexpected messages after ReplaceFrom: %+v", history.Messages)
+	}
+	if history.CurrentTokens != history.EstimateTokenCount() {
+		t.Errorf("Expected the token count to be updated, got %d", history.CurrentTokens)
+	}
+
+	// An index past the end appends
+	history.ReplaceFrom(10, []Message{{Role: "user", Content: "Go on"}})
+	if len(history.Messages) != 3 || history.Messages[2].Content != "Go on" {
+		t.Errorf("Expected the message to be appended, got %+v", history.Messages)
+	}
+}
+
+func TestSetSystemPrompt(t *testing.T) {
+	history := &ConversationHistory{
+		Messages: []Message{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user", Content: "Hello"},
+		},
+		MaxTokenCount: 1000,
+	}
+
+	history.SetSystemPrompt("Answer in French.")
+	if len(history.Messages) != 2 || history.Messages[0].Content != "Answer in French." {
+		t.Errorf("Expected the system prompt replaced, got %+v", history.Messages)
+	}
+
+	// A history without one, such as a cleared history, gets one
+	history.Clear()
+	history.SetSystemPrompt("Answer in German.")
+	if len(history.Messages) != 1 || history.Messages[0].Role != "system" || history.Messages[0].Content != "Answer in German." {
+		t.Errorf("Expected a system prompt added, got %+v", history.Messages)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	history, _ := NewConversationHistory(HistoryOptions{MaxTokenCount: 100000, SystemPrompt: "Be helpful."})
+	history.AddMessages([]Message{
+		{Role: "user", Content: "Add a --verbose flag. " + strings.Repeat("context ", 200)},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "read_file", Arguments: `{"path":"main.go"}`}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: strings.Repeat("package main ", 500)},
+		{Role: "assistant", Content: "Done, the flag is in main.go."},
+		{Role: "user", Content: "Now document it."},
+		{Role: "assistant", Content: "Added to the README."},
+	})
+
+	var got string
+	history.Summarizer = func(ctx context.Context, transcript string) (string, error) {
+		got = transcript
+		return "The user added --verbose in main.go.", nil
+	}
+	result, err := history.Compact(context.Background())
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if !strings.Contains(got, "Add a --verbose flag") || !strings.Contains(got, `assistant called read_file({"path":"main.go"})`) || strings.Contains(got, "Now document it") {
+		t.Errorf("Unexpected transcript:\n%s", got)
+	}
+	if result.Messages != 4 || result.TokensAfter >= result.TokensBefore {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	var roles []string
+	for _, msg := range history.Messages {
+		roles = append(roles, msg.Role)
+	}
+	if strings.Join(roles, ",") != "system,system,user,assistant" || history.Messages[1].Content != "Summary of conversation: The user added --verbose in main.go." {
+		t.Errorf("Unexpected history after compacting: %+v", history.Messages)
+	}
+
+	// Only the latest turn is left: there is nothing more to compact
+	if _, err := history.Compact(context.Background()); !errors.Is(err, ErrNothingToCompact) {
+		t.Errorf("Expected ErrNothingToCompact, got %v", err)
+	}
+
+	// A failed summary leaves the history alone
+	history.AddMessages([]Message{{Role: "user", Content: "And a test."}})
+	before := len(history.Messages)
+	history.Summarizer = func(ctx context.Context, transcript string) (string, error) {
+		return "", errors.New("rate limited")
+	}
+	if _, err := history.Compact(context.Background()); err == nil || len(history.Messages) != before {
+		t.Errorf("Expected an error and an unchanged history, got %v and %d messages", err, len(history.Messages))
+	}
+}
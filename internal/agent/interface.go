@@ -2,6 +2,8 @@ package agent
 
 import (
 	"context"
+
+	"github.com/epuerta/codex-go/internal/contextbudget"
 )
 
 // Message represents a single message in a conversation
@@ -11,6 +13,11 @@ type Message struct {
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	Name       string     `json:"name,omitempty"`
+	Images     []string   `json:"images,omitempty"` // Image data URLs attached to a user message
+
+	// Source is the part of the context a system message holds, for the
+	// context budget; messages without one are history
+	Source contextbudget.Source `json:"source,omitempty"`
 }
 
 // ToolCall represents a tool call in a message
@@ -38,10 +45,13 @@ type FunctionCallOutput struct {
 
 // ResponseItem represents a single response item from the AI
 type ResponseItem struct {
-	Type             string              `json:"type"` // "message", "function_call", "followup_complete"
+	Type             string              `json:"type"` // "message", "function_call", "function_call_preview", "blocked", "retry", "model_fallback", "followup_complete"
 	Message          *Message            `json:"message,omitempty"`
 	FunctionCall     *FunctionCall       `json:"functionCall,omitempty"`
 	FunctionOutput   *FunctionCallOutput `json:"functionOutput,omitempty"`
+	Blocked          *Blocked            `json:"blocked,omitempty"`
+	Retry            *StreamRetry        `json:"retry,omitempty"`
+	Fallback         *ModelFallback      `json:"fallback,omitempty"`
 	ThinkingDuration int64               `json:"thinkingDuration"`
 }
 
@@ -62,7 +72,23 @@ type FileChangeConfirmation struct {
 	ModifiedDiff string // Modified diff if any
 }
 
-// Agent defines the interface for AI agents
+// Agent is a model the engine and the UI converse with. OpenAIAgent is the
+// implementation; agenttest.MockAgent is a scripted one for tests.
+//
+// A turn starts with SendMessage, which adds the messages to the history,
+// requests a response and streams it to the handler as JSON-encoded
+// ResponseItems: "message" items with the assistant text so far (each
+// carrying the whole text, not a delta), "function_call_preview" items
+// while a tool call is generated, a "function_call" item per complete call,
+// and "blocked", "retry" and "model_fallback" items when those happen. The
+// handler is called synchronously, on the goroutine of the Send call.
+//
+// Each tool call is answered with SendFunctionResult, which records the
+// result and requests the next response, streamed to the handler of the
+// turn. A response without tool calls ends the turn with a
+// "followup_complete" item. Calls that are never answered, because the turn
+// was cancelled, are reported to the model as cancelled with the next
+// SendMessage.
 type Agent interface {
 	// SendMessage sends a message to the AI and streams the response
 	// Returns true if the stream finished requesting tool calls, false otherwise.
@@ -77,7 +103,8 @@ type Agent interface {
 	// ClearHistory clears the conversation history
 	ClearHistory()
 
-	// GetHistory returns the conversation history
+	// GetHistory returns the conversation history, which the caller may
+	// read, compact or rewind between turns
 	GetHistory() *ConversationHistory
 
 	// Cancel cancels the current streaming response
@@ -86,6 +113,10 @@ type Agent interface {
 	// Close closes the agent and releases any resources
 	Close() error
 
-	// SendFunctionResult sends a function result back to the agent
+	// SendFunctionResult sends a function result back to the agent and
+	// streams the next response to the handler of the turn
 	SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) error
 }
+
+// OpenAIAgent implements Agent
+var _ Agent = (*OpenAIAgent)(nil)
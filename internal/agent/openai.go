@@ -5,15 +5,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/epuerta/codex-go/internal/config"
 	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/epuerta/codex-go/internal/provider"
+	"github.com/epuerta/codex-go/internal/ratelimit"
+	"github.com/epuerta/codex-go/internal/respcache"
+	"github.com/epuerta/codex-go/internal/telemetry"
 	"github.com/google/uuid"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ToolDefinition represents a tool that can be called by the AI
@@ -29,11 +32,18 @@ type FunctionDef struct {
 	Parameters  interface{} `json:"parameters"`
 }
 
+// ToolSource provides the tools advertised to the model, such as a
+// functions.Registry. It is consulted on every request, so tools registered
+// later are offered too.
+type ToolSource interface {
+	ToolDefinitions() []ToolDefinition
+}
+
 // OpenAIAgent implements the Agent interface using OpenAI
 type OpenAIAgent struct {
 	client           *openai.Client
 	config           *config.Config
-	tools            []ToolDefinition
+	tools            ToolSource // Tools advertised to the model; nil for none
 	currentContext   context.Context
 	cancelFunc       context.CancelFunc
 	sessionID        string
@@ -44,20 +54,30 @@ type OpenAIAgent struct {
 	pendingToolCalls map[string]bool // Map of CallID -> true (pending)
 	pendingMu        sync.Mutex      // Mutex for pendingToolCalls map
 	logger           logging.Logger
+	scheduler        *ratelimit.Scheduler // Shared API rate limiter; nil if unlimited
+	toolCallProfile  ToolCallProfile      // How the backend streams tool calls
+	fallback         int                  // Index in config.Models() of the model serving the turn
+	cache            *respcache.Cache     // Responses of earlier runs; nil if not caching
+	cacheHits        int                  // Responses served from cache
+	usage            Usage                // Tokens used by the requests so far
+	streamingTokens  int                  // Estimated tokens of the response streaming now
+	usageMu          sync.Mutex           // Guards usage and streamingTokens, read while streaming
 }
 
 // NewOpenAIAgent creates a new OpenAI agent
 func NewOpenAIAgent(cfg *config.Config, logger logging.Logger) (*OpenAIAgent, error) {
-	if cfg.APIKey == "" {
-		return nil, errors.New("OpenAI API key is required")
+	// Malformed keys are left for the API to refuse; callers that want them
+	// caught up front run cfg.CheckCredentials themselves
+	if cfg.APIKey == "" && !cfg.Provider().NoKey {
+		return nil, cfg.CheckCredentials()
 	}
 
-	clientConfig := openai.DefaultConfig(cfg.APIKey)
-	if cfg.BaseURL != "" {
-		clientConfig.BaseURL = cfg.BaseURL
-	}
+	client := provider.NewClient(provider.ChatOptions(cfg))
 
-	client := openai.NewClientWithConfig(clientConfig)
+	toolCallProfile, err := ResolveToolCallProfile(cfg.ToolCallProfile, cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate a session ID
 	sessionID := uuid.New().String()
@@ -77,101 +97,6 @@ func NewOpenAIAgent(cfg *config.Config, logger logging.Logger) (*OpenAIAgent, er
 		return nil, fmt.Errorf("failed to initialize conversation history: %w", err)
 	}
 
-	// Default tools
-	tools := []ToolDefinition{
-		{
-			Type: "function",
-			Function: FunctionDef{
-				Name:        "shell",
-				Description: "Execute a shell command",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"command": map[string]interface{}{
-							"type":        "string",
-							"description": "The shell command to execute",
-						},
-					},
-					"required": []string{"command"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDef{
-				Name:        "read_file",
-				Description: "Read the contents of a file",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"path": map[string]interface{}{
-							"type":        "string",
-							"description": "The path to the file",
-						},
-					},
-					"required": []string{"path"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDef{
-				Name:        "write_file",
-				Description: "Write content to a file, replacing existing content or creating a new file. Use patch_file for modifying existing files.",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"path": map[string]interface{}{
-							"type":        "string",
-							"description": "The path to the file",
-						},
-						"content": map[string]interface{}{
-							"type":        "string",
-							"description": "The full content to write",
-						},
-					},
-					"required": []string{"path", "content"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDef{
-				Name:        "patch_file",
-				Description: "Modify an existing file by applying a patch in a specific format. Preferred for edits over write_file.",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						// Note: The actual implementation uses a custom format, not standard diff args.
-						// Describe the expected custom format in the parameter description.
-						"patch_content": map[string]interface{}{
-							"type":        "string",
-							"description": "The patch content, including // FILE:, // EDIT:, // END_EDIT, ADD:, and DEL: markers.",
-						},
-					},
-					"required": []string{"patch_content"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDef{
-				Name:        "list_directory",
-				Description: "List the contents of a directory",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"path": map[string]interface{}{
-							"type":        "string",
-							"description": "The path to the directory",
-						},
-					},
-					"required": []string{"path"},
-				},
-			},
-		},
-	}
-
 	// If logger is nil, use a nil logger to avoid null pointer issues
 	if logger == nil {
 		logger = &logging.NilLogger{}
@@ -181,20 +106,46 @@ func NewOpenAIAgent(cfg *config.Config, logger logging.Logger) (*OpenAIAgent, er
 	agent := &OpenAIAgent{
 		client:           client,
 		config:           cfg,
-		tools:            tools,
 		sessionID:        sessionID,
 		history:          history,
 		historyOpts:      historyOpts,
 		logger:           logger,
 		pendingToolCalls: make(map[string]bool), // Initialize the map
+		scheduler:        sharedScheduler(cfg),
+		toolCallProfile:  toolCallProfile,
 	}
+	history.Summarizer = agent.summarize
 
 	return agent, nil
 }
 
+// summarize asks the configured model for a summary of a conversation
+// transcript, for ConversationHistory.Compact
+func (a *OpenAIAgent) summarize(ctx context.Context, transcript string) (string, error) {
+	if err := a.scheduler.Wait(ctx, len(transcript)/4); err != nil {
+		return "", err
+	}
+	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: a.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: summaryInstructions},
+			{Role: openai.ChatMessageRoleUser, Content: transcript},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no summary in the response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
 // SendMessage sends a message to OpenAI and streams the response
 // It returns true if the stream finished requesting tool calls, false otherwise.
-func (a *OpenAIAgent) SendMessage(ctx context.Context, messages []Message, handler ResponseHandler) (bool, error) {
+func (a *OpenAIAgent) SendMessage(ctx context.Context, messages []Message, handler ResponseHandler) (_ bool, err error) {
+	ctx, span := telemetry.Start(ctx, "agent.send_message", attribute.String("codex.model", a.config.Model), attribute.Int("codex.messages", len(messages)))
+	defer func() { telemetry.End(span, err) }()
 	a.mu.Lock()
 	// Cancel any ongoing request
 	if a.cancelFunc != nil {
@@ -205,6 +156,9 @@ func (a *OpenAIAgent) SendMessage(ctx context.Context, messages []Message, handl
 	// Store the handler for potential follow-up calls
 	a.currentHandler = handler
 
+	// Each turn starts with the configured model again
+	a.fallback = 0
+
 	// Create a new context with cancellation
 	a.currentContext, a.cancelFunc = context.WithCancel(ctx)
 	a.mu.Unlock() // Unlock main mutex early
@@ -242,240 +196,30 @@ func (a *OpenAIAgent) SendMessage(ctx context.Context, messages []Message, handl
 	}
 	// --- END CANCELLATION HANDLING ---
 
-	// Get context-aware messages from history
-	historyMessages := a.history.GetMessagesForContext()
+	req := a.chatRequest("SendMessage")
+	startTime := time.Now() // Start thinking timer
 
-	// Convert messages to OpenAI format
-	var openAIMessages []openai.ChatCompletionMessage
-	for _, msg := range historyMessages {
-		// Create the base message
-		apiMsg := openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content, // Content is used for user, system, assistant (text), tool (result JSON)
-		}
-
-		// Handle Assistant requesting tool calls
-		if msg.Role == openai.ChatMessageRoleAssistant && len(msg.ToolCalls) > 0 {
-			apiMsg.ToolCalls = make([]openai.ToolCall, len(msg.ToolCalls))
-			for i, tc := range msg.ToolCalls {
-				apiMsg.ToolCalls[i] = openai.ToolCall{
-					ID:   tc.ID,
-					Type: openai.ToolType(tc.Type), // Assuming type is compatible (e.g., "function")
-					Function: openai.FunctionCall{
-						Name:      tc.Function.Name,
-						Arguments: tc.Function.Arguments,
-					},
-				}
-			}
-			// Content might be empty or null when tool calls are present
-			apiMsg.Content = "" // Or check if msg.Content should be preserved
-		}
-
-		// Handle Tool results
-		if msg.Role == openai.ChatMessageRoleTool {
-			apiMsg.ToolCallID = msg.ToolCallID
-		}
-
-		openAIMessages = append(openAIMessages, apiMsg)
-	}
-
-	// --- ADD LOGGING ---
-	historyForAPILog, _ := json.MarshalIndent(openAIMessages, "", "  ")
-	a.logger.Log("[DEBUG] Agent.SendMessage: History being sent to API:\n%s", string(historyForAPILog))
-	// --- END LOGGING ---
-
-	// Create the request
-	req := openai.ChatCompletionRequest{
-		Model:       a.config.Model,
-		Messages:    openAIMessages,
-		Temperature: 0.7,
-		Tools:       convertToolDefinitions(a.tools),
-		Stream:      true,
-	}
-
-	// Start thinking timer
-	startTime := time.Now()
-
-	a.logger.Log("[DEBUG] Agent.SendMessage: Creating stream request...")
-	stream, err := a.client.CreateChatCompletionStream(a.currentContext, req)
-	if err != nil {
-		a.logger.Log("[ERROR] Agent.SendMessage: Error creating stream: %v", err)
-		return false, fmt.Errorf("error creating chat completion stream: %w", err) // Return false on error
-	}
-	defer stream.Close()
-	a.logger.Log("[DEBUG] Agent.SendMessage: Stream created successfully. Starting Recv() loop.")
-
-	accumulatingToolCalls := make(map[string]*openai.FunctionCall)
-	var currentContent string
-	currentRole := openai.ChatMessageRoleAssistant
-	streamEndedWithToolCall := false // Flag
-	processingToolCall := false      // NEW Flag: Set to true once any tool delta is received
-
-	// Process the stream
-	for {
-		a.logger.Log("[DEBUG] Agent.SendMessage: Calling stream.Recv()...")
-		response, err := stream.Recv()
+	key := a.cacheKey("SendMessage", req)
+	res := a.replayResponse("SendMessage", key, handler, startTime)
+	if res == nil {
+		a.logger.Log("[DEBUG] Agent.SendMessage: Creating stream request...")
+		stream, err := a.openStream(a.currentContext, req, "SendMessage", handler)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				a.logger.Log("[DEBUG] Agent.SendMessage: Received EOF from stream.")
-				break // Exit loop on EOF
-			}
-			a.logger.Log("[ERROR] Agent.SendMessage: Error receiving from stream: %v", err)
-			return false, fmt.Errorf("error receiving from stream: %w", err) // Return false on error
-		}
-		a.logger.Log("[DEBUG] Agent.SendMessage: stream.Recv() successful. Choices: %d", len(response.Choices))
-
-		if len(response.Choices) > 0 {
-			choice := response.Choices[0]
-			a.logger.Log("[DEBUG] Agent.SendMessage: Processing choice 0. Delta Content: %t, Delta ToolCalls: %t, FinishReason: %s", choice.Delta.Content != "", choice.Delta.ToolCalls != nil, choice.FinishReason)
-
-			if choice.Delta.Role != "" {
-				currentRole = choice.Delta.Role
-			}
-
-			// --- Check if we are starting to process tool calls ---
-			if choice.Delta.ToolCalls != nil && len(choice.Delta.ToolCalls) > 0 {
-				if !processingToolCall {
-					a.logger.Log("[DEBUG] Agent.SendMessage: Detected first tool call delta. Switching to tool call processing mode.")
-					processingToolCall = true
-					// Optional: Clear any potentially accumulated 'currentContent' when tool calls start?
-					// currentContent = ""
-				}
-			}
-
-			// --- Process Delta Content ONLY if NOT in tool call mode ---
-			if choice.Delta.Content != "" && !processingToolCall {
-				currentContent += choice.Delta.Content
-				// Send message update to handler for real-time display
-				// We send the update regardless of tool calls now,
-				// because the *history* addition is handled *after* the loop based on finish_reason.
-				a.logger.Log("[DEBUG] Agent.SendMessage: Calling handler with type 'message' update. Current content length: %d", len(currentContent))
-				itemToSend := ResponseItem{
-					Type: "message",
-					Message: &Message{
-						Role:    currentRole,
-						Content: currentContent,
-					},
-					ThinkingDuration: time.Since(startTime).Milliseconds(),
-				}
-				jsonData, err := json.Marshal(itemToSend)
-				if err == nil {
-					handler(string(jsonData))
-				}
-			} else if choice.Delta.Content != "" && processingToolCall {
-				a.logger.Log("[DEBUG] Agent.SendMessage: Ignoring delta content because we are processing tool calls.")
-			}
-
-			// --- Accumulate Tool Calls if in tool call mode ---
-			if processingToolCall && choice.Delta.ToolCalls != nil {
-				streamEndedWithToolCall = true // Mark that we are processing tool calls
-				a.logger.Log("[DEBUG] Agent.SendMessage: Processing Delta.ToolCalls.")
-				for _, toolCallChunk := range choice.Delta.ToolCalls {
-					if toolCallChunk.ID == "" {
-						continue
-					}
-					if _, exists := accumulatingToolCalls[toolCallChunk.ID]; !exists {
-						a.logger.Log("[DEBUG] Agent.SendMessage: Initializing new tool call buffer for ID: %s", toolCallChunk.ID)
-						accumulatingToolCalls[toolCallChunk.ID] = &openai.FunctionCall{Name: toolCallChunk.Function.Name}
-					}
-					if toolCallChunk.Function.Arguments != "" {
-						a.logger.Log("[DEBUG] Agent.SendMessage: Appending arguments chunk '%s' to tool call ID: %s", toolCallChunk.Function.Arguments, toolCallChunk.ID)
-						accumulatingToolCalls[toolCallChunk.ID].Arguments += toolCallChunk.Function.Arguments
-					}
-				}
-			}
-
-			// --- Check FinishReason and Send Function Calls to Handler ---
-			if choice.FinishReason != "" {
-				if choice.FinishReason == "tool_calls" {
-					streamEndedWithToolCall = true // Confirm flag
-					a.logger.Log("[DEBUG] Agent.SendMessage: FinishReason is 'tool_calls'. Sending function calls to handler.")
-
-					// Send function call items to handler IMMEDIATELY
-					for id, completedCall := range accumulatingToolCalls {
-						functionCall := &FunctionCall{
-							Name:      completedCall.Name,
-							Arguments: completedCall.Arguments,
-							ID:        id,
-						}
-						// Track pending call
-						a.pendingMu.Lock()
-						if a.pendingToolCalls == nil {
-							a.pendingToolCalls = make(map[string]bool)
-						}
-						a.pendingToolCalls[id] = true
-						a.logger.Log("[DEBUG] Agent.SendMessage: Added CallID %s to pendingToolCalls", id)
-						a.pendingMu.Unlock()
-
-						a.logger.Log("[DEBUG] Agent.SendMessage: Calling handler with type 'function_call'. Name: %s, Args: '%s', ID: %s", functionCall.Name, functionCall.Arguments, functionCall.ID)
-						itemToSend := ResponseItem{
-							Type:             "function_call",
-							FunctionCall:     &FunctionCall{Name: functionCall.Name, Arguments: functionCall.Arguments, ID: functionCall.ID},
-							ThinkingDuration: time.Since(startTime).Milliseconds(),
-						}
-						jsonData, err := json.Marshal(itemToSend)
-						if err == nil {
-							handler(string(jsonData))
-							a.logger.Log("[DEBUG] Agent.SendMessage: Sent function_call item as JSON string.")
-						}
-					}
-					// DO NOT add to history here. History is added AFTER the loop.
-				} else {
-					// Handle non-tool_call finish reasons (e.g., 'stop')
-					a.logger.Log("[DEBUG] Agent.SendMessage: FinishReason is '%s'.", choice.FinishReason)
-					// History addition happens after the loop based on streamEndedWithToolCall flag.
-				}
-			}
+			a.logger.Log("[ERROR] Agent.SendMessage: Error creating stream: %v", err)
+			return false, fmt.Errorf("error creating chat completion stream: %w", err)
 		}
-	} // End stream processing loop
-
-	a.logger.Log("[DEBUG] Agent.SendMessage: Exited Recv() loop.")
+		defer stream.Close()
 
-	// --- Add Final Assistant Message to History AFTER loop ---
-	if a.history != nil {
-		if streamEndedWithToolCall {
-			// Add assistant message with ONLY tool calls
-			assistantMsgToolCalls := []ToolCall{}
-			for id, completedCall := range accumulatingToolCalls {
-				args := completedCall.Arguments
-				if args == "" {
-					args = "{}"
-				}
-				assistantMsgToolCalls = append(assistantMsgToolCalls, ToolCall{
-					ID:   id,
-					Type: string(openai.ToolTypeFunction),
-					Function: FunctionCall{
-						Name:      completedCall.Name,
-						Arguments: args,
-					},
-				})
-			}
-			if len(assistantMsgToolCalls) > 0 { // Only add if there were actual tool calls requested
-				assistantMsg := Message{
-					Role:      openai.ChatMessageRoleAssistant,
-					ToolCalls: assistantMsgToolCalls,
-					Content:   "", // Explicitly empty content
-				}
-				a.history.AddMessage(assistantMsg)
-				a.logger.Log("[DEBUG] Agent.SendMessage: Added final assistant message (ToolCalls only) to history.")
-			} else {
-				a.logger.Log("[WARN] Agent.SendMessage: Stream ended with tool_calls reason, but no tool calls were accumulated.")
-			}
-		} else if currentContent != "" {
-			// Add assistant message with ONLY text content
-			assistantMsg := Message{
-				Role:    currentRole, // Should be assistant
-				Content: currentContent,
-			}
-			a.history.AddMessage(assistantMsg)
-			a.logger.Log("[DEBUG] Agent.SendMessage: Added final assistant message (Text only) to history.")
+		if res, err = a.processStream(ctx, stream, "SendMessage", handler, startTime); err != nil {
+			return false, err
 		}
-	} else {
-		a.logger.Log("[ERROR] Agent.SendMessage: History is nil when trying to add final assistant message.")
+		a.addUsage(req, res)
+		a.storeResponse("SendMessage", key, res)
 	}
+	calls := a.completeResponse("SendMessage", res, handler)
 
-	a.logger.Log("[DEBUG] Agent.SendMessage: Function returning. Stream ended with tool call: %t", streamEndedWithToolCall)
-	return streamEndedWithToolCall, nil // Return the flag and nil error
+	a.logger.Log("[DEBUG] Agent.SendMessage: Function returning. Stream ended with %d tool call(s).", len(calls))
+	return len(calls) > 0, nil
 }
 
 // SendFileChange sends a file change to the AI for approval
@@ -537,13 +281,33 @@ func (a *OpenAIAgent) ClearHistory() {
 	}
 }
 
+// SetToolSource sets where the tools advertised to the model come from
+func (a *OpenAIAgent) SetToolSource(tools ToolSource) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tools = tools
+}
+
+// toolDefinitions returns the tools to advertise in the next request
+func (a *OpenAIAgent) toolDefinitions() []ToolDefinition {
+	a.mu.Lock()
+	tools := a.tools
+	a.mu.Unlock()
+	if tools == nil {
+		return nil
+	}
+	return tools.ToolDefinitions()
+}
+
 // GetHistory returns the conversation history
 func (a *OpenAIAgent) GetHistory() *ConversationHistory {
 	return a.history
 }
 
 // SendFunctionResult adds the tool result to history and then triggers the next AI response stream.
-func (a *OpenAIAgent) SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) error {
+func (a *OpenAIAgent) SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) (err error) {
+	ctx, span := telemetry.Start(ctx, "agent.send_function_result", attribute.String("codex.model", a.config.Model), attribute.String("codex.tool", functionName))
+	defer func() { telemetry.End(span, err) }()
 	a.mu.Lock()
 	// Get the handler before potentially unlocking in defer
 	handler := a.currentHandler
@@ -595,240 +359,114 @@ func (a *OpenAIAgent) SendFunctionResult(ctx context.Context, callID, functionNa
 		return nil // Or return an error?
 	}
 
-	// 3. Prepare and send the follow-up request to OpenAI
+	// 3. Send the follow-up request, streaming the response to the original handler
 	a.logger.Log("[DEBUG] Agent.SendFunctionResult: Preparing follow-up OpenAI request.")
-	historyMessages := a.history.GetMessagesForContext()
-	var openAIMessages []openai.ChatCompletionMessage
-
-	// --- FILTERING HISTORY FOR API ---
-	// Ensure the sequence Assistant(ToolCall) -> Tool(Result) is strictly maintained
-	// Skip any intermediate Assistant(Content) messages.
-	toolCallIDsExpected := make(map[string]bool) // Keep track of tool IDs we expect results for
-
-	for i := 0; i < len(historyMessages); i++ {
-		msg := historyMessages[i]
-		apiMsg := openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content, // May be overridden below
-		}
-		addMsg := true // Flag to control if we add the message
-
-		if msg.Role == openai.ChatMessageRoleAssistant {
-			if len(msg.ToolCalls) > 0 {
-				// This is an assistant message requesting tool calls
-				apiMsg.ToolCalls = make([]openai.ToolCall, len(msg.ToolCalls))
-				for j, tc := range msg.ToolCalls {
-					apiMsg.ToolCalls[j] = openai.ToolCall{
-						ID:   tc.ID,
-						Type: openai.ToolType(tc.Type),
-						Function: openai.FunctionCall{
-							Name:      tc.Function.Name,
-							Arguments: tc.Function.Arguments,
-						},
-					}
-					// Mark this tool call ID as expected
-					toolCallIDsExpected[tc.ID] = true
-				}
-				apiMsg.Content = "" // Content MUST be empty/null when tool calls are present
-			} else if len(toolCallIDsExpected) > 0 {
-				// This is a text message from the assistant, BUT we are still expecting tool results.
-				// This is the message we need to SKIP.
-				a.logger.Log("[DEBUG] Agent.SendFunctionResult: Skipping assistant text message (Role: %s, Content: %d chars) because tool results are pending.", msg.Role, len(msg.Content))
-				addMsg = false
-			}
-			// Otherwise, it's a normal assistant text message when no tool calls are pending - keep it.
-		}
+	req := a.chatRequest("SendFunctionResult")
+	startTime := time.Now() // Reset start time for this response phase
 
-		if msg.Role == openai.ChatMessageRoleTool {
-			// This is a tool result message
-			apiMsg.ToolCallID = msg.ToolCallID
-			// Mark this tool call ID as fulfilled
-			if _, exists := toolCallIDsExpected[msg.ToolCallID]; exists {
-				delete(toolCallIDsExpected, msg.ToolCallID)
-				a.logger.Log("[DEBUG] Agent.SendFunctionResult: Matched Tool Result for ID %s.", msg.ToolCallID)
-			} else {
-				// This shouldn't normally happen if history is consistent
-				a.logger.Log("[WARN] Agent.SendFunctionResult: Encountered Tool Result for unexpected ID %s.", msg.ToolCallID)
-			}
+	key := a.cacheKey("SendFunctionResult", req)
+	res := a.replayResponse("SendFunctionResult", key, handler, startTime)
+	if res == nil {
+		stream, err := a.openStream(ctx, req, "SendFunctionResult", handler) // Use the passed context
+		if err != nil {
+			a.logger.Log("[ERROR] Agent.SendFunctionResult: Error creating follow-up stream: %v", err)
+			return fmt.Errorf("error creating follow-up chat completion stream: %w", err)
 		}
+		defer stream.Close()
 
-		if addMsg {
-			openAIMessages = append(openAIMessages, apiMsg)
+		if res, err = a.processStream(ctx, stream, "SendFunctionResult", handler, startTime); err != nil {
+			return err
 		}
+		a.addUsage(req, res)
+		a.storeResponse("SendFunctionResult", key, res)
 	}
-	// --- END FILTERING ---
-
-	// --- ADD LOGGING ---
-	historyForAPILog, _ := json.MarshalIndent(openAIMessages, "", "  ")
-	a.logger.Log("[DEBUG] Agent.SendFunctionResult: Filtered History being sent to API:\n%s", string(historyForAPILog))
-	// --- END LOGGING ---
 
-	req := openai.ChatCompletionRequest{
-		Model:       a.config.Model,
-		Messages:    openAIMessages,
-		Temperature: 0.7,
-		Tools:       convertToolDefinitions(a.tools),
-		Stream:      true,
+	// 4. Signal the end of the turn unless the model requested further tool calls
+	if calls := a.completeResponse("SendFunctionResult", res, handler); len(calls) > 0 {
+		a.logger.Log("[DEBUG] Agent.SendFunctionResult: Follow-up stream ended with %d tool call(s). NOT sending completion signal yet.", len(calls))
+		return nil
 	}
-
-	a.logger.Log("[DEBUG] Agent.SendFunctionResult: Making follow-up CreateChatCompletionStream call.")
-	stream, err := a.client.CreateChatCompletionStream(ctx, req) // Use the passed context
+	a.logger.Log("[DEBUG] Agent.SendFunctionResult: Follow-up stream finished without further tool calls. Sending completion signal.")
+	jsonData, err := json.Marshal(ResponseItem{Type: "followup_complete"})
 	if err != nil {
-		a.logger.Log("[ERROR] Agent.SendFunctionResult: Error creating follow-up stream: %v", err)
-		// Should we maybe inform the handler of this error?
-		// For now, just return the error.
-		return fmt.Errorf("error creating follow-up chat completion stream: %w", err)
+		a.logger.Log("[ERROR] Agent.SendFunctionResult: Failed to marshal followup_complete item: %v", err)
+	} else {
+		handler(string(jsonData))
 	}
-	defer stream.Close()
+	return nil
+}
 
-	// 4. Process the new stream, sending results back via the original handler
-	a.logger.Log("[DEBUG] Agent.SendFunctionResult: Processing follow-up stream...")
-	startTime := time.Now() // Reset start time for this response phase
-	var currentContent string
-	currentRole := openai.ChatMessageRoleAssistant // Expecting assistant response now
-	var currentFunctionCall *openai.FunctionCall   // Added for potential nested calls
-	var currentFunctionCallID string               // Added for potential nested calls
-
-	for {
-		response, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			a.logger.Log("[DEBUG] Agent.SendFunctionResult: Received EOF from follow-up stream.")
-			break
-		}
+// dispatchToolCalls adds the assistant message requesting calls to history,
+// along with the text streamed before them, marks the calls pending until
+// their results arrive and sends them to handler
+func (a *OpenAIAgent) dispatchToolCalls(caller, content string, calls []FunctionCall, handler ResponseHandler, startTime time.Time) {
+	toolCalls := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		toolCalls[i] = ToolCall{ID: call.ID, Type: string(openai.ToolTypeFunction), Function: call}
+	}
+	a.history.AddMessage(Message{
+		Role:      openai.ChatMessageRoleAssistant,
+		Content:   content,
+		ToolCalls: toolCalls,
+	})
+	a.logger.Log("[DEBUG] Agent.%s: Added assistant message with %d tool call(s) to history.", caller, len(calls))
+
+	for _, call := range calls {
+		a.pendingMu.Lock()
+		a.pendingToolCalls[call.ID] = true
+		a.pendingMu.Unlock()
+
+		a.logger.Log("[DEBUG] Agent.%s: Calling handler with type 'function_call'. Name: %s, Args: '%s', ID: %s", caller, call.Name, call.Arguments, call.ID)
+		jsonData, err := json.Marshal(ResponseItem{
+			Type:             "function_call",
+			FunctionCall:     &FunctionCall{Name: call.Name, Arguments: call.Arguments, ID: call.ID},
+			ThinkingDuration: time.Since(startTime).Milliseconds(),
+		})
 		if err != nil {
-			a.logger.Log("[ERROR] Agent.SendFunctionResult: Error receiving from follow-up stream: %v", err)
-			// Inform handler?
-			return fmt.Errorf("error receiving from follow-up stream: %w", err)
-		}
-
-		if len(response.Choices) > 0 {
-			choice := response.Choices[0]
-			a.logger.Log("[DEBUG] Agent.SendFunctionResult: Processing choice 0. Delta Content: %t, Delta ToolCalls: %t, FinishReason: %s", choice.Delta.Content != "", choice.Delta.ToolCalls != nil, choice.FinishReason)
-
-			// Handle delta content (for text response)
-			if choice.Delta.Content != "" {
-				currentContent += choice.Delta.Content
-				a.logger.Log("[DEBUG] Agent.SendFunctionResult: Calling handler with type 'message'. Current content length: %d", len(currentContent))
-				itemToSend := ResponseItem{
-					Type: "message",
-					Message: &Message{
-						Role:    currentRole,
-						Content: currentContent,
-					},
-					ThinkingDuration: time.Since(startTime).Milliseconds(),
-				}
-				jsonData, err := json.Marshal(itemToSend)
-				if err != nil {
-					a.logger.Log("[ERROR] Agent.SendFunctionResult: Failed to marshal message item: %v", err)
-				} else {
-					handler(string(jsonData))
-				}
-			}
-
-			// Handle accumulating tool calls data (for potential recursive calls)
-			if choice.Delta.ToolCalls != nil && len(choice.Delta.ToolCalls) > 0 {
-				a.logger.Log("[DEBUG] Agent.SendFunctionResult: Processing Delta.ToolCalls (nested).")
-				toolCall := choice.Delta.ToolCalls[0]
-
-				if currentFunctionCall == nil {
-					a.logger.Log("[DEBUG] Agent.SendFunctionResult: Initializing new function call (nested). Name: %s, ID: %s", toolCall.Function.Name, toolCall.ID)
-					currentFunctionCall = &openai.FunctionCall{
-						Name:      toolCall.Function.Name,
-						Arguments: toolCall.Function.Arguments,
-					}
-					currentFunctionCallID = toolCall.ID
-				} else {
-					a.logger.Log("[DEBUG] Agent.SendFunctionResult: Appending to existing function call arguments (nested).")
-					currentFunctionCall.Arguments += toolCall.Function.Arguments
-				}
-			}
-
-			// Check for FinishReason SEPARATELY (for potential recursive calls)
-			if choice.FinishReason == "tool_calls" && currentFunctionCall != nil {
-				a.logger.Log("[DEBUG] Agent.SendFunctionResult: FinishReason is 'tool_calls' (nested). Preparing function call item.")
-
-				// --- BEGIN FIX: Add Assistant message for nested tool call ---
-				nestedToolCalls := []ToolCall{
-					{
-						ID:   currentFunctionCallID,
-						Type: string(openai.ToolTypeFunction), // Assuming function
-						Function: FunctionCall{
-							Name:      currentFunctionCall.Name,
-							Arguments: currentFunctionCall.Arguments, // Already accumulated
-						},
-					},
-				}
-				// Add this assistant message to history NOW
-				if a.history != nil {
-					a.history.AddMessage(Message{
-						Role:      openai.ChatMessageRoleAssistant,
-						ToolCalls: nestedToolCalls,
-					})
-					a.logger.Log("[DEBUG] Agent.SendFunctionResult: Added assistant message with NESTED ToolCalls to history.")
-				} else {
-					a.logger.Log("[ERROR] Agent.SendFunctionResult: History is nil, cannot add nested assistant message with ToolCalls.")
-				}
-				// --- END FIX ---
-
-				functionCall := &FunctionCall{ // Prepare item for handler
-					Name:      currentFunctionCall.Name,
-					Arguments: currentFunctionCall.Arguments,
-					ID:        currentFunctionCallID,
-				}
-
-				a.logger.Log("[DEBUG] Agent.SendFunctionResult: Calling handler with type 'function_call' (nested). Name: %s, Args: '%s', ID: %s", functionCall.Name, functionCall.Arguments, functionCall.ID)
-				itemToSend := ResponseItem{
-					Type:             "function_call",
-					FunctionCall:     &FunctionCall{Name: functionCall.Name, Arguments: functionCall.Arguments, ID: functionCall.ID},
-					ThinkingDuration: time.Since(startTime).Milliseconds(),
-				}
-				// Marshal and send JSON string via handler
-				jsonData, err := json.Marshal(itemToSend)
-				if err != nil {
-					a.logger.Log("[ERROR] Agent.SendFunctionResult: Failed to marshal function_call item: %v", err)
-					// Consider sending an error message back to the app
-				} else {
-					handler(string(jsonData))
-					a.logger.Log("[DEBUG] Agent.SendFunctionResult: Sent function_call item as JSON string.")
-				}
-
-				// Reset for next potential call in this stream
-				currentFunctionCall = nil
-				currentFunctionCallID = ""
-			}
+			a.logger.Log("[ERROR] Agent.%s: Failed to marshal function_call item: %v", caller, err)
+			continue
 		}
+		handler(string(jsonData))
 	}
+}
 
-	a.logger.Log("[DEBUG] Agent.SendFunctionResult: Follow-up stream processing finished.")
-	// Add the final assistant message from this stream to history
-	if currentContent != "" {
-		if a.history != nil {
-			a.history.AddMessage(Message{
-				Role:    currentRole,
-				Content: currentContent,
-			})
-			a.logger.Log("[DEBUG] Agent.SendFunctionResult: Added final assistant message to history.")
-		}
+// reportBlocked records a response that a content filter stopped or that the
+// model refused: the history gets a complete assistant message in its place,
+// dropping any tool calls, and handler a "blocked" item
+func (a *OpenAIAgent) reportBlocked(caller string, blocked *Blocked, content string, handler ResponseHandler) {
+	a.logger.Log("[WARN] Agent.%s: Response blocked (%s, finish reason %q). Partial: %t", caller, blocked.Reason, blocked.FinishReason, blocked.Partial)
+	a.history.AddMessage(Message{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: blocked.HistoryContent(content),
+	})
+
+	jsonData, err := json.Marshal(ResponseItem{Type: "blocked", Blocked: blocked})
+	if err != nil {
+		a.logger.Log("[ERROR] Agent.%s: Failed to marshal blocked item: %v", caller, err)
+		return
 	}
+	handler(string(jsonData))
+}
 
-	// --- FIX: Signal completion of the follow-up stream ---
-	// If we finished processing the stream and the last action wasn't requesting another tool call,
-	// signal completion back to the App.
-	if currentFunctionCall == nil { // If we are not expecting another tool call
-		a.logger.Log("[DEBUG] Agent.SendFunctionResult: Follow-up stream finished without further tool calls. Sending completion signal.")
-		// Use the handler to send the new completion message
-		completionItem := ResponseItem{Type: "followup_complete"} // Use a unique type
-		jsonData, err := json.Marshal(completionItem)
-		if err != nil {
-			a.logger.Log("[ERROR] Agent.SendFunctionResult: Failed to marshal followup_complete item: %v", err)
-		} else {
-			handler(string(jsonData))
-		}
-	} else {
-		a.logger.Log("[DEBUG] Agent.SendFunctionResult: Follow-up stream ended with pending tool call. NOT sending completion signal yet.")
+// withImages moves the text of a message with image attachments into
+// MultiContent, since the API only accepts images as content parts
+func withImages(apiMsg *openai.ChatCompletionMessage, images []string) {
+	if len(images) == 0 {
+		return
 	}
-
-	return nil
+	if apiMsg.Content != "" {
+		apiMsg.MultiContent = append(apiMsg.MultiContent, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: apiMsg.Content,
+		})
+	}
+	for _, url := range images {
+		apiMsg.MultiContent = append(apiMsg.MultiContent, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: url, Detail: openai.ImageURLDetailAuto},
+		})
+	}
+	apiMsg.Content = ""
 }
 
 // Helper function to convert ToolDefinition to openai.Tool
@@ -875,16 +513,10 @@ func (a *OpenAIAgent) SendFileChanges(ctx context.Context, changes []FileChange)
 	return a.history.Save(a.historyOpts.HistoryPath)
 }
 
-// AddSystemMessage adds a system message to the conversation history
+// AddSystemMessage adds a system message to the conversation history, which
+// the model reads with every request. Status text for the user does not
+// belong here: the UI shows it as a notice, outside the history.
 func (a *OpenAIAgent) AddSystemMessage(content string) error {
-	// Don't add debug messages to history
-	if strings.Contains(content, "DEBUG:") {
-		// Instead, print to stderr
-		// fmt.Fprintf(os.Stderr, "%s\n", content)
-		return nil
-	}
-
-	// If we have a history instance, add the message to it
 	if a.history != nil {
 		a.history.AddMessage(Message{
 			Role:    "system",
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/ratelimit"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxRateLimitRetries bounds how often a request rejected with 429 is retried
+const maxRateLimitRetries = 3
+
+// rateLimitBackoff is the pause applied to all sessions after a 429 response
+const rateLimitBackoff = 10 * time.Second
+
+// sharedScheduler returns the scheduler shared by all sessions using the same
+// API key and endpoint, or nil if no limits are configured
+func sharedScheduler(cfg *config.Config) *ratelimit.Scheduler {
+	sum := sha256.Sum256([]byte(cfg.BaseURL + "\x00" + cfg.APIKey))
+	return ratelimit.Shared(hex.EncodeToString(sum[:]), cfg.RateLimitRPM, cfg.RateLimitTPM)
+}
+
+// estimateTokens roughly estimates the prompt tokens of a request (about 4 characters per token)
+func estimateTokens(messages []openai.ChatCompletionMessage) int {
+	chars := 0
+	for _, msg := range messages {
+		chars += len(msg.Content)
+		for _, tc := range msg.ToolCalls {
+			chars += len(tc.Function.Name) + len(tc.Function.Arguments)
+		}
+	}
+	return chars/4 + 4*len(messages)
+}
+
+// isRateLimited reports whether err is a 429 response from the API
+func isRateLimited(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// createStream opens a completion stream once the scheduler admits the request.
+// When a scheduler is configured, 429 responses pause every session sharing it
+// and the request is retried.
+func (a *OpenAIAgent) createStream(ctx context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	tokens := estimateTokens(req.Messages)
+	for attempt := 1; ; attempt++ {
+		if err := a.scheduler.Wait(ctx, tokens); err != nil {
+			return nil, err
+		}
+		stream, err := a.client.CreateChatCompletionStream(ctx, req)
+		if err == nil || a.scheduler == nil || !isRateLimited(err) || attempt > maxRateLimitRetries {
+			return stream, err
+		}
+		a.logger.Log("[WARN] Agent: rate limited (attempt %d/%d), backing off: %v", attempt, maxRateLimitRetries, err)
+		a.scheduler.Backoff(time.Duration(attempt) * rateLimitBackoff)
+	}
+}
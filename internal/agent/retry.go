@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// streamRetryBackoff is the pause before reopening a stream that broke off;
+// it doubles with each retry
+var streamRetryBackoff = time.Second
+
+// errStreamRestarted is returned by resumableStream.Recv when the stream
+// broke off and was reopened. The response starts over, so what was
+// accumulated from the old stream must be discarded.
+var errStreamRestarted = errors.New("stream restarted")
+
+// StreamRetry describes a response stream that broke off and is retried
+type StreamRetry struct {
+	Attempt     int    `json:"attempt"`      // 1 for the first retry
+	MaxAttempts int    `json:"max_attempts"` // The max_retries setting
+	WaitMs      int64  `json:"wait_ms"`      // Pause before the retry
+	Error       string `json:"error"`        // Why the stream broke off
+}
+
+// Explain describes the retry for the user
+func (r *StreamRetry) Explain() string {
+	return fmt.Sprintf("The response stream broke off (%s); retrying in %s (%d/%d).",
+		r.Error, (time.Duration(r.WaitMs) * time.Millisecond).Round(100*time.Millisecond), r.Attempt, r.MaxAttempts)
+}
+
+// isTransient reports whether a stream failing with err is worth reopening:
+// the connection dropped, or the API sent a rate limit or server error
+// in place of the next chunk
+func isTransient(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Type {
+		case "server_error", "overloaded_error", "rate_limit_error":
+			return true
+		}
+		return transientStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return transientStatus(reqErr.HTTPStatusCode)
+	}
+	return false
+}
+
+// transientStatus reports whether an HTTP status is a rate limit or a
+// server error that may go away
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// resumableStream is a completion stream that is reopened, up to
+// max_retries times, when it breaks off with a transient error. Opening a
+// stream already retries rate limits and server errors, honoring
+// Retry-After; this covers the failures after the response started.
+type resumableStream struct {
+	agent   *OpenAIAgent
+	ctx     context.Context
+	req     openai.ChatCompletionRequest
+	caller  string
+	handler ResponseHandler
+
+	stream   *openai.ChatCompletionStream
+	retries  int
+	finished bool // A finish reason arrived, so the response is complete
+}
+
+// openStream opens a resumable stream for req with the model serving the
+// turn. Retries are reported to handler as "retry" items.
+func (a *OpenAIAgent) openStream(ctx context.Context, req openai.ChatCompletionRequest, caller string, handler ResponseHandler) (*resumableStream, error) {
+	stream, req, err := a.createStreamWithFallbacks(ctx, req, caller, handler)
+	if err != nil {
+		return nil, err
+	}
+	return &resumableStream{agent: a, ctx: ctx, req: req, caller: caller, handler: handler, stream: stream}, nil
+}
+
+// Recv returns the next chunk of the response. When the stream broke off
+// and was reopened it returns errStreamRestarted, and the chunks that
+// follow are those of the new response. A stream that breaks off after its
+// finish reason ends with io.EOF, since nothing of the response is missing.
+func (s *resumableStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	a := s.agent
+	response, err := s.stream.Recv()
+	if err == nil {
+		for _, choice := range response.Choices {
+			if choice.FinishReason != "" {
+				s.finished = true
+			}
+		}
+		return response, nil
+	}
+	if errors.Is(err, io.EOF) {
+		return response, err
+	}
+	if s.finished && isTransient(err) {
+		a.logger.Log("[WARN] Agent.%s: Stream broke off after its finish reason, keeping the response: %v", s.caller, err)
+		return response, io.EOF
+	}
+	if !isTransient(err) || s.retries >= a.config.MaxRetries || s.ctx.Err() != nil {
+		return response, err
+	}
+
+	s.retries++
+	wait := streamRetryBackoff << (s.retries - 1)
+	a.logger.Log("[WARN] Agent.%s: Stream broke off (retry %d/%d in %s): %v", s.caller, s.retries, a.config.MaxRetries, wait, err)
+	s.report(StreamRetry{Attempt: s.retries, MaxAttempts: a.config.MaxRetries, WaitMs: wait.Milliseconds(), Error: err.Error()})
+	s.stream.Close()
+
+	timer := time.NewTimer(wait)
+	select {
+	case <-timer.C:
+	case <-s.ctx.Done():
+		timer.Stop()
+		return response, s.ctx.Err()
+	}
+	stream, err := a.createStream(s.ctx, s.req)
+	if err != nil {
+		return response, err
+	}
+	s.stream = stream
+	return response, errStreamRestarted
+}
+
+// report sends a "retry" item to the handler
+func (s *resumableStream) report(retry StreamRetry) {
+	if s.handler == nil {
+		return
+	}
+	jsonData, err := json.Marshal(ResponseItem{Type: "retry", Retry: &retry})
+	if err != nil {
+		s.agent.logger.Log("[ERROR] Agent.%s: Failed to marshal retry item: %v", s.caller, err)
+		return
+	}
+	s.handler(string(jsonData))
+}
+
+// Close closes the current stream
+func (s *resumableStream) Close() error {
+	return s.stream.Close()
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// streamChunk returns a server-sent event with a chunk of content
+func streamChunk(content, finishReason string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"delta":         map[string]string{"content": content},
+			"finish_reason": finishReason,
+		}},
+	})
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+// breakOff sends events and drops the connection, as a network blip would
+func breakOff(t *testing.T, w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		io.WriteString(w, event)
+	}
+	w.(http.Flusher).Flush()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Errorf("Hijack: %v", err)
+		return
+	}
+	conn.Close()
+}
+
+func newTestAgent(t *testing.T, url string, maxRetries int) *OpenAIAgent {
+	t.Helper()
+	a, err := NewOpenAIAgent(&config.Config{APIKey: "sk-test", BaseURL: url, Model: "gpt-4o", MaxRetries: maxRetries}, nil)
+	if err != nil {
+		t.Fatalf("NewOpenAIAgent: %v", err)
+	}
+	return a
+}
+
+func TestStreamRetry(t *testing.T) {
+	defer func(backoff time.Duration) { streamRetryBackoff = backoff }(streamRetryBackoff)
+	streamRetryBackoff = 0
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			breakOff(t, w, streamChunk("Hel", ""))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("Hello", "")+streamChunk(" again", "stop")+"data: [DONE]\n\n")
+	}))
+	defer ts.Close()
+
+	a := newTestAgent(t, ts.URL, 2)
+	var retries []StreamRetry
+	var last string
+	_, err := a.SendMessage(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(itemJSON string) {
+		var item ResponseItem
+		json.Unmarshal([]byte(itemJSON), &item)
+		switch item.Type {
+		case "retry":
+			retries = append(retries, *item.Retry)
+		case "message":
+			last = item.Message.Content
+		}
+	})
+	if err != nil {
+		t.Fatalf("Expected the broken stream to be retried, got %v", err)
+	}
+	if len(retries) != 1 || retries[0].Attempt != 1 || retries[0].MaxAttempts != 2 {
+		t.Errorf("Expected one retry to be reported, got %+v", retries)
+	}
+	if last != "Hello again" {
+		t.Errorf("Expected the retried response to replace the partial one, got %q", last)
+	}
+	if msgs := a.history.GetMessages(); msgs[len(msgs)-1].Content != "Hello again" {
+		t.Errorf("Expected only the retried response in the history, got %q", msgs[len(msgs)-1].Content)
+	}
+}
+
+func TestStreamRetryGivesUp(t *testing.T) {
+	defer func(backoff time.Duration) { streamRetryBackoff = backoff }(streamRetryBackoff)
+	streamRetryBackoff = 0
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		breakOff(t, w, streamChunk("Hel", ""))
+	}))
+	defer ts.Close()
+
+	a := newTestAgent(t, ts.URL, 1)
+	_, err := a.SendMessage(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(string) {})
+	if err == nil {
+		t.Fatal("Expected an error once the retries are used up")
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+}
+
+func TestStreamKeptAfterFinishReason(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		breakOff(t, w, streamChunk("Done.", "stop"))
+	}))
+	defer ts.Close()
+
+	a := newTestAgent(t, ts.URL, 2)
+	if _, err := a.SendMessage(context.Background(), []Message{{Role: "user", Content: "hi"}}, func(string) {}); err != nil {
+		t.Fatalf("Expected a stream that broke off after its finish reason to be complete, got %v", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected no retry, got %d requests", got)
+	}
+	if msgs := a.history.GetMessages(); msgs[len(msgs)-1].Content != "Done." {
+		t.Errorf("Expected the response in the history, got %q", msgs[len(msgs)-1].Content)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("reading: %w", io.ErrUnexpectedEOF), true},
+		{&openai.APIError{Type: "server_error", Message: "overloaded"}, true},
+		{&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, true},
+		{&openai.APIError{HTTPStatusCode: http.StatusBadRequest, Type: "invalid_request_error"}, false},
+		{&openai.RequestError{HTTPStatusCode: http.StatusBadGateway}, true},
+		{context.Canceled, false},
+		{errors.New("unmarshal error"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStreamRetryExplain(t *testing.T) {
+	retry := &StreamRetry{Attempt: 1, MaxAttempts: 2, WaitMs: 1000, Error: "unexpected EOF"}
+	if got := retry.Explain(); !strings.Contains(got, "unexpected EOF") || !strings.Contains(got, "1s (1/2)") {
+		t.Errorf("Unexpected explanation: %q", got)
+	}
+}
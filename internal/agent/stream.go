@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// streamResponse is what a response stream produced
+type streamResponse struct {
+	Role         string
+	Content      string // Text streamed before any tool call
+	Refusal      string
+	FinishReason string
+	ToolCalls    *toolCallAccumulator
+	StartTime    time.Time     // When the request was sent, for thinking durations
+	Usage        *openai.Usage // Tokens used, if the backend reported them
+}
+
+// chatRequest builds a streaming request for the messages of the history.
+// Assistant text recorded while tool results were still due is left out,
+// so each message requesting tool calls is directly followed by the results.
+func (a *OpenAIAgent) chatRequest(caller string) openai.ChatCompletionRequest {
+	var messages []openai.ChatCompletionMessage
+	pending := make(map[string]bool) // Tool call IDs without a result yet
+	for _, msg := range a.assembledContext(caller) {
+		apiMsg := openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		withImages(&apiMsg, msg.Images)
+
+		switch msg.Role {
+		case openai.ChatMessageRoleAssistant:
+			if len(msg.ToolCalls) == 0 && len(pending) > 0 {
+				a.logger.Log("[DEBUG] Agent.%s: Skipping assistant text message (%d chars) because tool results are pending.", caller, len(msg.Content))
+				continue
+			}
+			for _, tc := range msg.ToolCalls {
+				apiMsg.ToolCalls = append(apiMsg.ToolCalls, openai.ToolCall{
+					ID:   tc.ID,
+					Type: openai.ToolType(tc.Type),
+					Function: openai.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				})
+				pending[tc.ID] = true
+			}
+			if len(msg.ToolCalls) > 0 {
+				apiMsg.Content = "" // Content must be empty when tool calls are present
+			}
+		case openai.ChatMessageRoleTool:
+			apiMsg.ToolCallID = msg.ToolCallID
+			if !pending[msg.ToolCallID] {
+				a.logger.Log("[WARN] Agent.%s: Tool result for unexpected ID %s.", caller, msg.ToolCallID)
+			}
+			delete(pending, msg.ToolCallID)
+		}
+		messages = append(messages, apiMsg)
+	}
+
+	historyForAPILog, _ := json.MarshalIndent(messages, "", "  ")
+	a.logger.Log("[DEBUG] Agent.%s: History being sent to API:\n%s", caller, string(historyForAPILog))
+
+	return openai.ChatCompletionRequest{
+		Model:       a.config.Model,
+		Messages:    messages,
+		Temperature: 0.7,
+		Tools:       convertToolDefinitions(a.toolDefinitions()),
+		Stream:      true,
+		// Ask for the tokens used, reported in a last chunk without choices
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	}
+}
+
+// processStream reads a response stream to its end. The text streamed so
+// far is sent to handler as "message" items and the tool calls being
+// assembled as previews; once a tool call starts, further text is ignored.
+// A stream that was reopened after breaking off starts over.
+func (a *OpenAIAgent) processStream(ctx context.Context, stream *resumableStream, caller string, handler ResponseHandler, startTime time.Time) (*streamResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	newResponse := func() *streamResponse {
+		return &streamResponse{Role: openai.ChatMessageRoleAssistant, ToolCalls: newToolCallAccumulator(a.toolCallProfile), StartTime: startTime}
+	}
+	res := newResponse()
+	preview := &toolCallPreviewer{handler: handler}
+	gotChunk := false // Whether a chunk has arrived
+	defer a.setStreamingTokens(0)
+
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, errStreamRestarted) {
+			// The retried response replaces what was streamed so far
+			res = newResponse()
+			preview = &toolCallPreviewer{handler: handler}
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			a.logger.Log("[DEBUG] Agent.%s: Received EOF from stream.", caller)
+			// Account for completion tokens
+			a.scheduler.Adjust(len(res.Content) / 4)
+			break
+		}
+		if err != nil {
+			a.logger.Log("[ERROR] Agent.%s: Error receiving from stream: %v", caller, err)
+			return nil, fmt.Errorf("error receiving from stream: %w", err)
+		}
+		if !gotChunk {
+			gotChunk = true
+			span.AddEvent("first chunk") // Marks the model's time to first token
+		}
+		if response.Usage != nil {
+			res.Usage = response.Usage
+		}
+		if len(response.Choices) == 0 {
+			continue
+		}
+
+		choice := response.Choices[0]
+		a.logger.Log("[DEBUG] Agent.%s: Delta Content: %t, Delta ToolCalls: %t, FinishReason: %s", caller, choice.Delta.Content != "", choice.Delta.ToolCalls != nil, choice.FinishReason)
+		if choice.Delta.Role != "" {
+			res.Role = choice.Delta.Role
+		}
+		res.Refusal += choice.Delta.Refusal
+		if choice.FinishReason != "" {
+			res.FinishReason = string(choice.FinishReason)
+		}
+
+		for _, chunk := range choice.Delta.ToolCalls {
+			a.logger.Log("[DEBUG] Agent.%s: Tool call chunk. ID: %q, Name: %q, Args: %q", caller, chunk.ID, chunk.Function.Name, chunk.Function.Arguments)
+			res.ToolCalls.Add(chunk)
+		}
+		preview.Update(res.ToolCalls)
+
+		if choice.Delta.Content == "" {
+			continue
+		}
+		if res.ToolCalls.Len() > 0 {
+			a.logger.Log("[DEBUG] Agent.%s: Ignoring delta content because we are processing tool calls.", caller)
+			continue
+		}
+		res.Content += choice.Delta.Content
+		a.setStreamingTokens(len(res.Content) / 4)
+		jsonData, err := json.Marshal(ResponseItem{
+			Type:             "message",
+			Message:          &Message{Role: res.Role, Content: res.Content},
+			ThinkingDuration: time.Since(startTime).Milliseconds(),
+		})
+		if err != nil {
+			a.logger.Log("[ERROR] Agent.%s: Failed to marshal message item: %v", caller, err)
+			continue
+		}
+		handler(string(jsonData))
+	}
+
+	span.SetAttributes(attribute.String("codex.finish_reason", res.FinishReason))
+	return res, nil
+}
+
+// completeResponse records a finished response in the history and
+// dispatches the tool calls it requested, which it returns. A blocked
+// response is recorded as such, without its tool calls.
+func (a *OpenAIAgent) completeResponse(caller string, res *streamResponse, handler ResponseHandler) []FunctionCall {
+	if blocked := blockedResponse(res.FinishReason, res.Refusal, res.Content != "" || res.ToolCalls.Len() > 0); blocked != nil {
+		a.reportBlocked(caller, blocked, res.Content, handler)
+		return nil
+	}
+
+	// Some backends finish with "stop" even when they requested tool calls,
+	// so the calls themselves decide, not the finish reason
+	if res.ToolCalls.Len() > 0 {
+		calls := res.ToolCalls.Calls()
+		if len(calls) == 0 {
+			a.logger.Log("[WARN] Agent.%s: Stream contained tool call chunks, but none named a function.", caller)
+			return nil
+		}
+		a.dispatchToolCalls(caller, res.Content, calls, handler, res.StartTime)
+		return calls
+	}
+
+	if res.Content != "" {
+		a.history.AddMessage(Message{Role: res.Role, Content: res.Content})
+		a.logger.Log("[DEBUG] Agent.%s: Added final assistant message to history.", caller)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// toolCallChunk returns a server-sent event with a whole tool call,
+// after the text given
+func toolCallChunk(content, id, name, args string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []map[string]interface{}{{
+			"index": 0,
+			"delta": map[string]interface{}{
+				"content": content,
+				"tool_calls": []map[string]interface{}{{
+					"index":    0,
+					"id":       id,
+					"type":     "function",
+					"function": map[string]string{"name": name, "arguments": args},
+				}},
+			},
+			"finish_reason": "tool_calls",
+		}},
+	})
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+func TestToolCallChains(t *testing.T) {
+	responses := []string{
+		streamChunk("Let me look. ", "") + toolCallChunk("", "call_1", "list_directory", `{"path":"."}`),
+		streamChunk("Now the file. ", "") + toolCallChunk("", "call_2", "read_file", `{"path":"main.go"}`),
+		streamChunk("All done.", "stop"),
+	}
+	var mu sync.Mutex
+	var requests []openai.ChatCompletionRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		n := len(requests)
+		requests = append(requests, req)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, responses[n]+"data: [DONE]\n\n")
+	}))
+	defer ts.Close()
+
+	a := newTestAgent(t, ts.URL, 0)
+	var calls []FunctionCall
+	var messages []string
+	completed := 0
+	handler := func(itemJSON string) {
+		var item ResponseItem
+		json.Unmarshal([]byte(itemJSON), &item)
+		switch item.Type {
+		case "function_call":
+			calls = append(calls, *item.FunctionCall)
+		case "message":
+			messages = append(messages, item.Message.Content)
+		case "followup_complete":
+			completed++
+		}
+	}
+
+	toolCalls, err := a.SendMessage(context.Background(), []Message{{Role: "user", Content: "summarize main.go"}}, handler)
+	if err != nil || !toolCalls {
+		t.Fatalf("Expected the first response to request a tool call, got %t, %v", toolCalls, err)
+	}
+	if err := a.SendFunctionResult(context.Background(), "call_1", "list_directory", "main.go", true); err != nil {
+		t.Fatal(err)
+	}
+	if completed != 0 || len(calls) != 2 || calls[1].ID != "call_2" || calls[1].Arguments != `{"path":"main.go"}` {
+		t.Fatalf("Expected the follow-up to request a second call, got %+v (completed %d)", calls, completed)
+	}
+	if err := a.SendFunctionResult(context.Background(), "call_2", "read_file", "package main", true); err != nil {
+		t.Fatal(err)
+	}
+	if completed != 1 || messages[len(messages)-1] != "All done." {
+		t.Errorf("Expected the chain to complete with the final message, got %q (completed %d)", messages, completed)
+	}
+
+	// The last request carries each call directly followed by its result
+	last := requests[len(requests)-1].Messages
+	var roles []string
+	for _, msg := range last[len(last)-4:] {
+		roles = append(roles, msg.Role)
+	}
+	if fmt.Sprint(roles) != "[assistant tool assistant tool]" || last[len(last)-2].ToolCalls[0].ID != "call_2" || last[len(last)-1].ToolCallID != "call_2" {
+		t.Errorf("Unexpected messages in the last request: %+v", last)
+	}
+
+	// The text streamed before a call is kept with it in the history
+	history := a.history.GetMessages()
+	if msg := history[len(history)-3]; len(msg.ToolCalls) != 1 || msg.Content != "Now the file. " {
+		t.Errorf("Expected the second call with its text in the history, got %+v", msg)
+	}
+	if msg := history[len(history)-1]; msg.Role != "assistant" || msg.Content != "All done." {
+		t.Errorf("Expected the final message last in the history, got %+v", msg)
+	}
+}
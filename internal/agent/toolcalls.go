@@ -0,0 +1,255 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ToolCallProfile describes how an OpenAI-compatible backend streams tool
+// calls. The accumulator copes with the common variations on its own (IDs
+// only on the first chunk, no IDs at all, missing indexes, whole calls in one
+// chunk); a profile settles the cases that are ambiguous from the stream alone.
+type ToolCallProfile struct {
+	Name string
+
+	// WholeCalls means every chunk that names a function is a complete new
+	// call, even if it reuses the index of an earlier one
+	WholeCalls bool
+
+	// IgnoreIndex means indexes are unreliable (e.g. always 0), so chunks are
+	// matched by ID, or continue the latest call if they have none
+	IgnoreIndex bool
+
+	// CumulativeArguments means each chunk repeats the arguments streamed so
+	// far instead of sending only the new part
+	CumulativeArguments bool
+}
+
+// toolCallProfiles are the known profiles by name
+var toolCallProfiles = map[string]ToolCallProfile{
+	"openai":     {Name: "openai"},
+	"ollama":     {Name: "ollama", WholeCalls: true},
+	"mistral":    {Name: "mistral", WholeCalls: true},
+	"gemini":     {Name: "gemini", IgnoreIndex: true},
+	"cumulative": {Name: "cumulative", CumulativeArguments: true},
+}
+
+// ToolCallProfileNames lists the profiles accepted by ResolveToolCallProfile
+func ToolCallProfileNames() []string {
+	names := []string{"auto"}
+	for name := range toolCallProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// ResolveToolCallProfile returns the named profile. An empty name or "auto"
+// picks one from the API base URL, falling back to the OpenAI behaviour.
+func ResolveToolCallProfile(name, baseURL string) (ToolCallProfile, error) {
+	if name != "" && name != "auto" {
+		profile, ok := toolCallProfiles[name]
+		if !ok {
+			return ToolCallProfile{}, fmt.Errorf("unknown tool call profile %q (want one of %s)", name, strings.Join(ToolCallProfileNames(), ", "))
+		}
+		return profile, nil
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return toolCallProfiles["openai"], nil
+	}
+	host := u.Hostname()
+	switch {
+	case host == "generativelanguage.googleapis.com":
+		return toolCallProfiles["gemini"], nil
+	case host == "api.mistral.ai":
+		return toolCallProfiles["mistral"], nil
+	case u.Port() == "11434" || strings.Contains(host, "ollama"):
+		return toolCallProfiles["ollama"], nil
+	}
+	return toolCallProfiles["openai"], nil
+}
+
+// toolCallSlot is a call being assembled from stream chunks
+type toolCallSlot struct {
+	id   string
+	name string
+	args string
+}
+
+// toolCallAccumulator assembles streamed tool call chunks into calls. Chunks
+// are matched to a call by ID, then by index, and otherwise continue the
+// latest call; nothing is dropped for lacking an ID.
+type toolCallAccumulator struct {
+	profile ToolCallProfile
+	slots   []*toolCallSlot
+	byID    map[string]*toolCallSlot
+	byIndex map[int]*toolCallSlot
+}
+
+func newToolCallAccumulator(profile ToolCallProfile) *toolCallAccumulator {
+	return &toolCallAccumulator{
+		profile: profile,
+		byID:    make(map[string]*toolCallSlot),
+		byIndex: make(map[int]*toolCallSlot),
+	}
+}
+
+// Add merges one chunk into the calls assembled so far
+func (acc *toolCallAccumulator) Add(chunk openai.ToolCall) {
+	slot := acc.route(chunk)
+	if slot == nil {
+		slot = &toolCallSlot{}
+		acc.slots = append(acc.slots, slot)
+	}
+	if chunk.Index != nil && !acc.profile.IgnoreIndex {
+		acc.byIndex[*chunk.Index] = slot
+	}
+	if chunk.ID != "" && slot.id == "" {
+		slot.id = chunk.ID
+		acc.byID[chunk.ID] = slot
+	}
+	if chunk.Function.Name != "" && slot.name == "" {
+		slot.name = chunk.Function.Name
+	}
+
+	args := chunk.Function.Arguments
+	switch {
+	case args == "":
+	case acc.profile.CumulativeArguments:
+		slot.args = args
+	case isCompleteJSON(slot.args) && isCompleteJSON(args):
+		// The full arguments were sent again; keep the latest copy
+		slot.args = args
+	default:
+		slot.args += args
+	}
+}
+
+// route finds the call a chunk belongs to, or nil if it starts a new one
+func (acc *toolCallAccumulator) route(chunk openai.ToolCall) *toolCallSlot {
+	if chunk.ID != "" {
+		if slot, ok := acc.byID[chunk.ID]; ok {
+			return slot
+		}
+		// An ID may arrive after the first chunk of its call
+		if slot := acc.indexed(chunk); slot != nil && slot.id == "" && !acc.startsCall(slot, chunk) {
+			return slot
+		}
+		return nil
+	}
+
+	if chunk.Index != nil && !acc.profile.IgnoreIndex {
+		slot := acc.indexed(chunk)
+		if slot != nil && acc.startsCall(slot, chunk) {
+			return nil
+		}
+		return slot
+	}
+
+	if len(acc.slots) == 0 {
+		return nil
+	}
+	last := acc.slots[len(acc.slots)-1]
+	if acc.startsCall(last, chunk) {
+		return nil
+	}
+	return last
+}
+
+// indexed returns the call last seen at the chunk's index
+func (acc *toolCallAccumulator) indexed(chunk openai.ToolCall) *toolCallSlot {
+	if chunk.Index == nil || acc.profile.IgnoreIndex {
+		return nil
+	}
+	return acc.byIndex[*chunk.Index]
+}
+
+// startsCall reports whether a chunk naming a function begins a new call
+// rather than continuing slot: always for WholeCalls backends, and otherwise
+// when slot already holds complete arguments
+func (acc *toolCallAccumulator) startsCall(slot *toolCallSlot, chunk openai.ToolCall) bool {
+	if chunk.Function.Name == "" || slot.name == "" {
+		return false
+	}
+	return acc.profile.WholeCalls || isCompleteJSON(slot.args)
+}
+
+// Len returns the number of calls seen so far
+func (acc *toolCallAccumulator) Len() int {
+	return len(acc.slots)
+}
+
+// Calls returns the assembled calls in stream order. Calls without an ID get
+// a generated one, and empty arguments become "{}". Chunks that never named a
+// function are dropped.
+func (acc *toolCallAccumulator) Calls() []FunctionCall {
+	var calls []FunctionCall
+	for _, slot := range acc.slots {
+		if slot.name == "" {
+			continue
+		}
+		if slot.id == "" {
+			slot.id = "call_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:24]
+		}
+		args := slot.args
+		if strings.TrimSpace(args) == "" {
+			args = "{}"
+		}
+		calls = append(calls, FunctionCall{ID: slot.id, Name: slot.name, Arguments: args})
+	}
+	return calls
+}
+
+// toolCallPreviewInterval is the minimum time between previews of a call's
+// arguments while they stream in
+var toolCallPreviewInterval = 100 * time.Millisecond
+
+// toolCallPreviewer reports the call being assembled to a handler as
+// "function_call_preview" items, so its arguments can be reviewed before the
+// stream ends. Previews are throttled to one per toolCallPreviewInterval,
+// except that the first preview of each call is sent right away.
+type toolCallPreviewer struct {
+	handler ResponseHandler
+	slot    *toolCallSlot // Call previewed last
+	sentLen int           // Length of the arguments previewed last
+	sentAt  time.Time
+}
+
+// Update previews the latest call of acc if it has grown since the last preview
+func (p *toolCallPreviewer) Update(acc *toolCallAccumulator) {
+	if len(acc.slots) == 0 {
+		return
+	}
+	slot := acc.slots[len(acc.slots)-1]
+	if slot.name == "" {
+		return
+	}
+	if slot == p.slot && (len(slot.args) == p.sentLen || time.Since(p.sentAt) < toolCallPreviewInterval) {
+		return
+	}
+
+	jsonData, err := json.Marshal(ResponseItem{
+		Type:         "function_call_preview",
+		FunctionCall: &FunctionCall{ID: slot.id, Name: slot.name, Arguments: slot.args},
+	})
+	if err != nil {
+		return
+	}
+	p.slot, p.sentLen, p.sentAt = slot, len(slot.args), time.Now()
+	p.handler(string(jsonData))
+}
+
+// isCompleteJSON reports whether s is a complete JSON object
+func isCompleteJSON(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "{") && json.Valid([]byte(s))
+}
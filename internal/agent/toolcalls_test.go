@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected calls in testdata/toolcall_streams")
+
+// toolCallStream is a recorded tool call stream and the calls it must produce
+type toolCallStream struct {
+	Description  string              `json:"description"`
+	Profile      string              `json:"profile"`
+	FinishReason string              `json:"finish_reason,omitempty"` // Defaults to "tool_calls"
+	Chunks       [][]openai.ToolCall `json:"chunks"`                  // Delta.ToolCalls of each stream chunk
+	Want         []wantCall          `json:"want"`
+}
+
+// wantCall is an expected call; ID "*" stands for a generated ID
+type wantCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// serveStream replays chunks as a chat completion SSE stream
+func serveStream(t *testing.T, stream toolCallStream) *httptest.Server {
+	t.Helper()
+	finish := stream.FinishReason
+	if finish == "" {
+		finish = "tool_calls"
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		send := func(delta map[string]interface{}, finishReason interface{}) {
+			data, _ := json.Marshal(map[string]interface{}{
+				"id": "chatcmpl-test", "object": "chat.completion.chunk", "model": "test",
+				"choices": []interface{}{map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finishReason}},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		send(map[string]interface{}{"role": "assistant"}, nil)
+		for _, chunk := range stream.Chunks {
+			send(map[string]interface{}{"tool_calls": chunk}, nil)
+		}
+		send(map[string]interface{}{}, finish)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestToolCallStreams(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "toolcall_streams", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No golden streams found: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stream toolCallStream
+			if err := json.Unmarshal(data, &stream); err != nil {
+				t.Fatalf("Invalid golden stream: %v", err)
+			}
+
+			ts := serveStream(t, stream)
+			defer ts.Close()
+
+			ai, err := NewOpenAIAgent(&config.Config{APIKey: "test", Model: "test", BaseURL: ts.URL, ToolCallProfile: stream.Profile}, nil)
+			if err != nil {
+				t.Fatalf("NewOpenAIAgent failed: %v", err)
+			}
+			var got []FunctionCall
+			requested, err := ai.SendMessage(context.Background(), []Message{{Role: "user", Content: "go"}}, func(itemJSON string) {
+				var item ResponseItem
+				json.Unmarshal([]byte(itemJSON), &item)
+				if item.Type == "function_call" {
+					got = append(got, *item.FunctionCall)
+				}
+			})
+			if err != nil {
+				t.Fatalf("SendMessage failed: %v", err)
+			}
+			if !requested {
+				t.Error("Expected SendMessage to report tool calls")
+			}
+
+			if *update {
+				stream.Want = wantCalls(stream, got)
+				data, _ := json.MarshalIndent(stream, "", "  ")
+				if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if len(got) != len(stream.Want) {
+				t.Fatalf("Expected %d calls, got %d: %+v", len(stream.Want), len(got), got)
+			}
+			for i, want := range stream.Want {
+				call := got[i]
+				if call.Name != want.Name || call.Arguments != want.Arguments {
+					t.Errorf("Call %d: expected %s(%s), got %s(%s)", i, want.Name, want.Arguments, call.Name, call.Arguments)
+				}
+				if want.ID == "*" {
+					if !strings.HasPrefix(call.ID, "call_") {
+						t.Errorf("Call %d: expected a generated ID, got %q", i, call.ID)
+					}
+				} else if call.ID != want.ID {
+					t.Errorf("Call %d: expected ID %q, got %q", i, want.ID, call.ID)
+				}
+			}
+
+			// The calls must be in history for their results to be accepted
+			last, _ := ai.GetHistory().GetLastMessage()
+			if len(last.ToolCalls) != len(got) {
+				t.Errorf("Expected %d tool calls in history, got %d", len(got), len(last.ToolCalls))
+			}
+		})
+	}
+}
+
+// wantCalls converts calls to expectations, marking IDs not in the stream as generated
+func wantCalls(stream toolCallStream, calls []FunctionCall) []wantCall {
+	streamed := make(map[string]bool)
+	for _, chunk := range stream.Chunks {
+		for _, tc := range chunk {
+			streamed[tc.ID] = true
+		}
+	}
+	want := make([]wantCall, len(calls))
+	for i, call := range calls {
+		id := call.ID
+		if !streamed[id] {
+			id = "*"
+		}
+		want[i] = wantCall{ID: id, Name: call.Name, Arguments: call.Arguments}
+	}
+	return want
+}
+
+func TestResolveToolCallProfile(t *testing.T) {
+	tests := []struct {
+		name, baseURL, want string
+	}{
+		{"", config.DefaultBaseURL, "openai"},
+		{"auto", "https://generativelanguage.googleapis.com/v1beta/openai/", "gemini"},
+		{"", "http://localhost:11434/v1", "ollama"},
+		{"", "https://api.mistral.ai/v1", "mistral"},
+		{"", "https://llm.internal.example.com/v1", "openai"},
+		{"cumulative", config.DefaultBaseURL, "cumulative"},
+	}
+	for _, tt := range tests {
+		profile, err := ResolveToolCallProfile(tt.name, tt.baseURL)
+		if err != nil || profile.Name != tt.want {
+			t.Errorf("ResolveToolCallProfile(%q, %q) = %q, %v; want %q", tt.name, tt.baseURL, profile.Name, err, tt.want)
+		}
+	}
+
+	if _, err := ResolveToolCallProfile("bogus", ""); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+}
+
+func TestToolCallPreviews(t *testing.T) {
+	defer func(interval time.Duration) { toolCallPreviewInterval = interval }(toolCallPreviewInterval)
+	toolCallPreviewInterval = 0
+
+	index := 0
+	ts := serveStream(t, toolCallStream{Chunks: [][]openai.ToolCall{
+		{{Index: &index, ID: "call_1", Type: "function", Function: openai.FunctionCall{Name: "patch_file"}}},
+		{{Index: &index, Function: openai.FunctionCall{Arguments: `{"patch_content":"*** Begin`}}},
+		{{Index: &index, Function: openai.FunctionCall{Arguments: ``}}},
+		{{Index: &index, Function: openai.FunctionCall{Arguments: ` Patch"}`}}},
+	}})
+	defer ts.Close()
+
+	ai, err := NewOpenAIAgent(&config.Config{APIKey: "test", Model: "test", BaseURL: ts.URL}, nil)
+	if err != nil {
+		t.Fatalf("NewOpenAIAgent failed: %v", err)
+	}
+	var previews, types []string
+	_, err = ai.SendMessage(context.Background(), []Message{{Role: "user", Content: "go"}}, func(itemJSON string) {
+		var item ResponseItem
+		json.Unmarshal([]byte(itemJSON), &item)
+		types = append(types, item.Type)
+		if item.Type == "function_call_preview" {
+			if item.FunctionCall.ID != "call_1" || item.FunctionCall.Name != "patch_file" {
+				t.Errorf("Unexpected preview call %+v", item.FunctionCall)
+			}
+			previews = append(previews, item.FunctionCall.Arguments)
+		}
+	})
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	// Chunks that add nothing are not previewed again
+	want := []string{"", `{"patch_content":"*** Begin`, `{"patch_content":"*** Begin Patch"}`}
+	if strings.Join(previews, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected previews %q, got %q", want, previews)
+	}
+	if types[len(types)-1] != "function_call" {
+		t.Errorf("Expected the complete call after the previews, got %q", types)
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import "github.com/sashabaranov/go-openai"
+
+// Usage is the tokens the requests of a session used. Backends that report
+// usage at the end of the stream are counted exactly; the tokens of other
+// responses are estimated.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Estimated        bool // Whether some of the tokens are estimates
+}
+
+// Total returns the prompt and completion tokens together
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageReporter is implemented by agents that count the tokens they use
+type UsageReporter interface {
+	Usage() Usage
+}
+
+var _ UsageReporter = (*OpenAIAgent)(nil)
+
+// Usage returns the tokens used by the requests so far, counting those of
+// the response streaming now as it arrives. Responses served from the
+// response cache used none.
+func (a *OpenAIAgent) Usage() Usage {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	usage := a.usage
+	usage.CompletionTokens += a.streamingTokens
+	return usage
+}
+
+// setStreamingTokens records the estimated tokens of the response streaming now
+func (a *OpenAIAgent) setStreamingTokens(tokens int) {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	a.streamingTokens = tokens
+}
+
+// addUsage counts the tokens of a streamed response: those the stream
+// reported, or else an estimate
+func (a *OpenAIAgent) addUsage(req openai.ChatCompletionRequest, res *streamResponse) {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	if res.Usage != nil {
+		a.usage.PromptTokens += res.Usage.PromptTokens
+		a.usage.CompletionTokens += res.Usage.CompletionTokens
+		return
+	}
+	completion := len(res.Content) / 4
+	for _, call := range res.ToolCalls.Calls() {
+		completion += (len(call.Name) + len(call.Arguments)) / 4
+	}
+	a.usage.PromptTokens += estimateTokens(req.Messages)
+	a.usage.CompletionTokens += completion
+	a.usage.Estimated = true
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUsage(t *testing.T) {
+	var reportUsage atomic.Bool
+	reportUsage.Store(true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		json.Unmarshal(body, &req)
+		if !req.StreamOptions.IncludeUsage {
+			t.Error("Expected the request to ask for usage")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk(strings.Repeat("word ", 20), "stop"))
+		if reportUsage.Load() {
+			io.WriteString(w, `data: {"choices":[],"usage":{"prompt_tokens":120,"completion_tokens":30,"total_tokens":150}}`+"\n\n")
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer ts.Close()
+
+	a := newTestAgent(t, ts.URL, 0)
+	send := func() {
+		if _, err := a.SendMessage(context.Background(), []Message{{Role: "user", Content: "describe the repository"}}, func(string) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send()
+	if got := a.Usage(); got.PromptTokens != 120 || got.CompletionTokens != 30 || got.Estimated || got.Total() != 150 {
+		t.Errorf("Expected the reported usage, got %+v", got)
+	}
+
+	// Responses without usage are estimated
+	reportUsage.Store(false)
+	send()
+	got := a.Usage()
+	if !got.Estimated || got.PromptTokens <= 120 || got.CompletionTokens != 30+25 {
+		t.Errorf("Expected an estimate added, got %+v", got)
+	}
+}
@@ -0,0 +1,227 @@
+// Package approvals remembers the tool calls the user chose to always allow
+// in a project, so that identical calls run without asking again. The rules
+// of every project are kept in ~/.codex/approvals.json, outside the
+// repositories, so that a checked-out project cannot approve its own
+// commands.
+package approvals
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/memory"
+	"github.com/epuerta/codex-go/internal/scripts"
+)
+
+// DefaultFileName is the name of the store inside the codex config directory
+const DefaultFileName = "approvals.json"
+
+// maxShownPattern bounds a pattern in the one-line description of a rule
+const maxShownPattern = 100
+
+// Rule allows the calls of a tool whose arguments normalize to Pattern
+type Rule struct {
+	Tool    string    `json:"tool"`
+	Pattern string    `json:"pattern"`
+	Added   time.Time `json:"added"`
+}
+
+// String describes the rule in one line, with a long pattern shortened
+func (r Rule) String() string {
+	pattern := strings.Join(strings.Fields(r.Pattern), " ")
+	if len(pattern) > maxShownPattern {
+		pattern = pattern[:maxShownPattern] + "..."
+	}
+	return fmt.Sprintf("%s: %s", r.Tool, pattern)
+}
+
+// file is the content of the store: the rules of each project root
+type file struct {
+	Projects map[string][]Rule `json:"projects"`
+}
+
+// Store holds the rules of one project in a file shared by all projects
+type Store struct {
+	path    string
+	project string
+	mu      sync.Mutex
+}
+
+// NewStore returns the rules of project kept in the file at path
+func NewStore(path, project string) *Store {
+	return &Store{path: path, project: project}
+}
+
+// DefaultPath returns the default store location (~/.codex/approvals.json)
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".codex", DefaultFileName), nil
+}
+
+// Path returns the file path backing the store
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Rememberable reports whether calls of a tool may be always allowed.
+// Memory notes outlive the session, so the user confirms each one.
+func Rememberable(tool string) bool {
+	return tool != memory.ToolName
+}
+
+// Pattern returns the normalized form of call that a rule matches:
+// commands and scripts by their command line with the spacing collapsed,
+// other tools by their arguments with the keys sorted
+func Pattern(call agent.FunctionCall) string {
+	if executor.IsCommandFunction(call.Name) || call.Name == scripts.ToolName {
+		return strings.Join(strings.Fields(executor.ApprovalArgs(call)), " ")
+	}
+	var args interface{}
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return strings.TrimSpace(call.Arguments)
+	}
+	data, err := json.Marshal(args) // Sorts the keys
+	if err != nil {
+		return strings.TrimSpace(call.Arguments)
+	}
+	return string(data)
+}
+
+// Rules returns the rules of the project, oldest first. A missing store is
+// not an error and yields no rules.
+func (s *Store) Rules() ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return f.Projects[s.project], nil
+}
+
+// Allows reports whether a rule of the project matches call, and which
+func (s *Store) Allows(call agent.FunctionCall) (Rule, bool) {
+	if !Rememberable(call.Name) {
+		return Rule{}, false
+	}
+	rules, err := s.Rules()
+	if err != nil {
+		return Rule{}, false
+	}
+	pattern := Pattern(call)
+	for _, rule := range rules {
+		if rule.Tool == call.Name && rule.Pattern == pattern {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// AllowsCall is Allows with the rule described, for the engine's allow list
+func (s *Store) AllowsCall(call agent.FunctionCall) (string, bool) {
+	rule, ok := s.Allows(call)
+	return rule.String(), ok
+}
+
+// Add remembers to allow calls identical to call in the project and returns
+// the rule, which is the existing one if there is
+func (s *Store) Add(call agent.FunctionCall) (Rule, error) {
+	if !Rememberable(call.Name) {
+		return Rule{}, fmt.Errorf("%s calls cannot be always allowed", call.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return Rule{}, err
+	}
+	rule := Rule{Tool: call.Name, Pattern: Pattern(call), Added: time.Now()}
+	for _, existing := range f.Projects[s.project] {
+		if existing.Tool == rule.Tool && existing.Pattern == rule.Pattern {
+			return existing, nil
+		}
+	}
+	f.Projects[s.project] = append(f.Projects[s.project], rule)
+	return rule, s.save(f)
+}
+
+// Revoke removes the nth rule of the project, counting from 1, and returns it
+func (s *Store) Revoke(n int) (Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return Rule{}, err
+	}
+	rules := f.Projects[s.project]
+	if n < 1 || n > len(rules) {
+		return Rule{}, fmt.Errorf("no rule %d; there are %d", n, len(rules))
+	}
+	rule := rules[n-1]
+	f.Projects[s.project] = append(rules[:n-1:n-1], rules[n:]...)
+	if len(f.Projects[s.project]) == 0 {
+		delete(f.Projects, s.project)
+	}
+	return rule, s.save(f)
+}
+
+// Clear removes all rules of the project and returns how many there were
+func (s *Store) Clear() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	n := len(f.Projects[s.project])
+	if n == 0 {
+		return 0, nil
+	}
+	delete(f.Projects, s.project)
+	return n, s.save(f)
+}
+
+// load reads the store, which is empty if the file does not exist
+func (s *Store) load() (*file, error) {
+	f := &file{}
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read approvals: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+		}
+	}
+	if f.Projects == nil {
+		f.Projects = make(map[string][]Rule)
+	}
+	return f, nil
+}
+
+// save replaces the store with f
+func (s *Store) save(f *file) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create approvals directory: %w", err)
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode approvals: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write approvals: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
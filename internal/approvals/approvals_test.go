@@ -0,0 +1,90 @@
+package approvals
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/agent"
+)
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		call agent.FunctionCall
+		want string
+	}{
+		{agent.FunctionCall{Name: "shell", Arguments: `{"command":"  go   test ./...\n"}`}, "go test ./..."},
+		{agent.FunctionCall{Name: "execute_command", Arguments: `{"command":"make","timeout":30}`}, "make"},
+		{agent.FunctionCall{Name: "write_file", Arguments: `{"path":"a.txt", "content":"x"}`}, `{"content":"x","path":"a.txt"}`},
+		{agent.FunctionCall{Name: "write_file", Arguments: `not json`}, "not json"},
+	}
+	for _, tt := range tests {
+		if got := Pattern(tt.call); got != tt.want {
+			t.Errorf("Pattern(%s %s) = %q, want %q", tt.call.Name, tt.call.Arguments, got, tt.want)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	store, other := NewStore(path, "/src/app"), NewStore(path, "/src/other")
+	test := agent.FunctionCall{Name: "shell", Arguments: `{"command":"go test ./..."}`}
+
+	if _, ok := store.Allows(test); ok {
+		t.Fatal("Expected an empty store to allow nothing")
+	}
+	rule, err := store.Add(test)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if rule.String() != "shell: go test ./..." {
+		t.Errorf("Unexpected rule %q", rule)
+	}
+
+	// Identical calls match, whatever their spacing; other commands, tools
+	// and projects do not
+	if _, ok := store.Allows(agent.FunctionCall{Name: "shell", Arguments: `{"command":"go  test ./..."}`}); !ok {
+		t.Error("Expected the same command to be allowed")
+	}
+	if _, ok := store.Allows(agent.FunctionCall{Name: "shell", Arguments: `{"command":"go test ./... && rm -rf /"}`}); ok {
+		t.Error("Expected a longer command not to be allowed")
+	}
+	if _, ok := store.Allows(agent.FunctionCall{Name: "execute_command", Arguments: test.Arguments}); ok {
+		t.Error("Expected another tool not to be allowed")
+	}
+	if _, ok := other.Allows(test); ok {
+		t.Error("Expected another project not to be allowed")
+	}
+
+	// Adding a call again keeps a single rule
+	if _, err := store.Add(test); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := store.Add(agent.FunctionCall{Name: "shell", Arguments: `{"command":"make lint"}`}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	rules, err := store.Rules()
+	if err != nil || len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %v (%v)", rules, err)
+	}
+
+	revoked, err := store.Revoke(1)
+	if err != nil || revoked.Pattern != "go test ./..." {
+		t.Fatalf("Expected the first rule to be revoked, got %v (%v)", revoked, err)
+	}
+	if _, ok := store.Allows(test); ok {
+		t.Error("Expected a revoked call to be asked about again")
+	}
+	if _, err := store.Revoke(2); err == nil {
+		t.Error("Expected revoking a missing rule to fail")
+	}
+	if n, err := store.Clear(); err != nil || n != 1 {
+		t.Errorf("Expected Clear to remove 1 rule, got %d (%v)", n, err)
+	}
+}
+
+func TestStoreRefusesMemory(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), DefaultFileName), "/src/app")
+	if _, err := store.Add(agent.FunctionCall{Name: "remember", Arguments: `{"note":"x"}`}); err == nil {
+		t.Error("Expected memory notes not to be always allowed")
+	}
+}
@@ -0,0 +1,158 @@
+// Package branch isolates the changes of an unattended run on a git branch
+// of their own, so the branch the user was on is left untouched.
+package branch
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Prefix starts the name of every branch created for a run
+const Prefix = "codex/"
+
+// maxSlugLength bounds the part of a branch name taken from the task
+const maxSlugLength = 40
+
+// ErrDirty is returned by Start when the working tree has uncommitted
+// changes, which would otherwise end up on the run's branch
+var ErrDirty = errors.New("the working tree has uncommitted changes; commit or stash them first")
+
+// Isolation is a run's branch, created by Start and closed by Finish
+type Isolation struct {
+	Dir      string // The repository's working directory
+	Branch   string // The branch the run's changes are committed to
+	Original string // The branch, or commit if detached, the run started from
+	Task     string // The task, used for the commit message
+}
+
+// Result describes what Finish did
+type Result struct {
+	Branch    string // The run's branch; empty when it was removed for having no changes
+	Original  string // The branch checked out again
+	Committed bool   // Whether the run's changes were committed to Branch
+}
+
+// Slug turns a task into the lowercase, dash-separated part of a branch name
+func Slug(task string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(task) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		// Cut at a word boundary when there is one
+		slug = slug[:maxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	if slug == "" {
+		return "task"
+	}
+	return slug
+}
+
+// Name returns the branch name of a task started at t, like
+// "codex/fix-the-tests-20240102-150405"
+func Name(task string, t time.Time) string {
+	return Prefix + Slug(task) + "-" + t.Format("20060102-150405")
+}
+
+// Start creates the branch of a task in the repository holding dir and
+// checks it out. The working tree must be clean.
+func Start(dir, task string, now time.Time) (*Isolation, error) {
+	if _, err := git(dir, "rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository", dir)
+	}
+	status, err := git(dir, "status", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	if status != "" {
+		return nil, ErrDirty
+	}
+	original, err := git(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		// Detached HEAD: come back to the commit
+		if original, err = git(dir, "rev-parse", "HEAD"); err != nil {
+			return nil, fmt.Errorf("the repository has no commits yet")
+		}
+	}
+	iso := &Isolation{Dir: dir, Branch: Name(task, now), Original: original, Task: task}
+	if _, err := git(dir, "checkout", "-q", "-b", iso.Branch); err != nil {
+		return nil, err
+	}
+	return iso, nil
+}
+
+// Finish commits the run's changes to its branch and checks out the
+// original branch again. A branch without changes is deleted.
+func (iso *Isolation) Finish() (Result, error) {
+	res := Result{Branch: iso.Branch, Original: iso.Original}
+	if _, err := git(iso.Dir, "add", "-A"); err != nil {
+		return res, err
+	}
+	status, err := git(iso.Dir, "status", "--porcelain")
+	if err != nil {
+		return res, err
+	}
+	if status != "" {
+		if _, err := git(iso.Dir, "commit", "-q", "-m", commitMessage(iso.Task)); err != nil {
+			return res, err
+		}
+		res.Committed = true
+	}
+	if _, err := git(iso.Dir, "checkout", "-q", iso.Original); err != nil {
+		return res, err
+	}
+	if !res.Committed {
+		if _, err := git(iso.Dir, "branch", "-D", iso.Branch); err != nil {
+			return res, err
+		}
+		res.Branch = ""
+	}
+	return res, nil
+}
+
+// Instructions tells the user how to review, merge or discard the branch
+func (r Result) Instructions() string {
+	if r.Branch == "" {
+		return fmt.Sprintf("No files were changed; the run's branch was removed and %s is checked out.", r.Original)
+	}
+	return fmt.Sprintf(`The changes were committed to %[1]s; %[2]s is checked out and unchanged.
+  Review:  git diff %[2]s...%[1]s
+  Merge:   git merge %[1]s
+  Discard: git branch -D %[1]s`, r.Branch, r.Original)
+}
+
+// commitMessage returns the message of the run's commit
+func commitMessage(task string) string {
+	subject := strings.TrimSpace(strings.SplitN(strings.TrimSpace(task), "\n", 2)[0])
+	if r := []rune(subject); len(r) > 60 {
+		subject = strings.TrimSpace(string(r[:57])) + "..."
+	}
+	return "codex: " + subject + "\n\nTask:\n" + task
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,113 @@
+package branch
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestName(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		task string
+		want string
+	}{
+		{"Fix the failing tests!", "codex/fix-the-failing-tests-20240102-150405"},
+		{"  --Add *README* section--  ", "codex/add-readme-section-20240102-150405"},
+		{"???", "codex/task-20240102-150405"},
+		{strings.Repeat("word ", 20), "codex/word-word-word-word-word-word-word-word-20240102-150405"},
+	}
+	for _, tt := range tests {
+		if got := Name(tt.task, at); got != tt.want {
+			t.Errorf("Name(%q) = %q, want %q", tt.task, got, tt.want)
+		}
+	}
+}
+
+// newRepo creates a repository with one commit on main
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	for _, args := range [][]string{{"init", "-q", "-b", "main"}, {"commit", "-q", "--allow-empty", "-m", "init"}} {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestStartFinish(t *testing.T) {
+	dir := newRepo(t)
+	iso, err := Start(dir, "Add a file", time.Now())
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if current, _ := git(dir, "branch", "--show-current"); current != iso.Branch || iso.Original != "main" {
+		t.Fatalf("Expected to be on %s from main, got %s from %s", iso.Branch, current, iso.Original)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := iso.Finish()
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if !res.Committed || res.Branch != iso.Branch {
+		t.Fatalf("Expected the change to be committed to %s, got %+v", iso.Branch, res)
+	}
+	if current, _ := git(dir, "branch", "--show-current"); current != "main" {
+		t.Errorf("Expected main to be checked out again, got %s", current)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected main to be left without the new file")
+	}
+	if files, _ := git(dir, "show", "--name-only", "--format=", iso.Branch); files != "a.txt" {
+		t.Errorf("Expected the branch to add a.txt, got %q", files)
+	}
+	if !strings.Contains(res.Instructions(), "git merge "+iso.Branch) {
+		t.Errorf("Expected merge instructions, got:\n%s", res.Instructions())
+	}
+}
+
+func TestFinishWithoutChanges(t *testing.T) {
+	dir := newRepo(t)
+	iso, err := Start(dir, "Look around", time.Now())
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	res, err := iso.Finish()
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if res.Committed || res.Branch != "" {
+		t.Errorf("Expected nothing to be committed, got %+v", res)
+	}
+	if branches, _ := git(dir, "branch", "--list", Prefix+"*"); branches != "" {
+		t.Errorf("Expected the branch to be deleted, got %q", branches)
+	}
+}
+
+func TestStartDirty(t *testing.T) {
+	dir := newRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "wip.txt"), []byte("wip\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Start(dir, "task", time.Now()); !errors.Is(err, ErrDirty) {
+		t.Errorf("Expected ErrDirty, got %v", err)
+	}
+	if _, err := Start(t.TempDir(), "task", time.Now()); err == nil {
+		t.Error("Expected an error outside a repository")
+	}
+}
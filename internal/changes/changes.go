@@ -0,0 +1,186 @@
+// Package changes tracks the files changed during a session, for /diff and
+// the rollout. In a git repository the whole working tree is snapshotted when
+// the session starts, so the diff also covers changes made by shell commands;
+// elsewhere each file is saved before the agent first writes to it.
+package changes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Tracker records the files written during a session and what the working
+// tree held when it started. It is safe for concurrent use.
+type Tracker struct {
+	Dir string
+
+	baseTree string // The git tree of the working tree at the start; empty outside git
+
+	mu        sync.Mutex
+	files     []string           // Paths written, relative to Dir, in the order first written
+	originals map[string]*string // Content before the first write; nil if the file did not exist
+}
+
+// NewTracker starts tracking the changes under dir, snapshotting its working
+// tree if it is in a git repository
+func NewTracker(dir string) *Tracker {
+	t := &Tracker{Dir: dir, originals: map[string]*string{}}
+	t.baseTree, _ = snapshotTree(dir)
+	return t
+}
+
+// UsesGit reports whether the tracker diffs against a git snapshot
+func (t *Tracker) UsesGit() bool {
+	return t.baseTree != ""
+}
+
+// rel returns path relative to Dir, or as it is if it lies outside
+func (t *Tracker) rel(path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	rel, err := filepath.Rel(t.Dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// abs returns the path to read for a path relative to Dir
+func (t *Tracker) abs(rel string) string {
+	if filepath.IsAbs(rel) {
+		return rel
+	}
+	return filepath.Join(t.Dir, filepath.FromSlash(rel))
+}
+
+// Before saves the content of path before it is written, the first time
+func (t *Tracker) Before(path string) {
+	rel := t.rel(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.originals[rel]; ok {
+		return
+	}
+	if data, err := os.ReadFile(t.abs(rel)); err == nil {
+		content := string(data)
+		t.originals[rel] = &content
+	} else {
+		t.originals[rel] = nil
+	}
+}
+
+// Written records that path was written
+func (t *Tracker) Written(path string) {
+	rel := t.rel(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, f := range t.files {
+		if f == rel {
+			return
+		}
+	}
+	t.files = append(t.files, rel)
+}
+
+// Files returns the paths written so far, relative to Dir
+func (t *Tracker) Files() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.files...)
+}
+
+// Diff returns a unified diff of the changes since the session started: the
+// whole working tree with git, the files written otherwise
+func (t *Tracker) Diff() (string, error) {
+	if t.UsesGit() {
+		current, err := snapshotTree(t.Dir)
+		if err != nil {
+			return "", err
+		}
+		return git(t.Dir, nil, "diff", "--no-color", "--no-ext-diff", "--relative", t.baseTree, current)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	for _, rel := range t.files {
+		original, saved := t.originals[rel]
+		if !saved {
+			continue // Written without Before; there is nothing to compare with
+		}
+		var current *string
+		if data, err := os.ReadFile(t.abs(rel)); err == nil {
+			content := string(data)
+			current = &content
+		}
+		b.WriteString(Unified(rel, original, current))
+	}
+	return b.String(), nil
+}
+
+// snapshotTree writes the working tree under dir, untracked files included,
+// to a git tree and returns its hash. The repository's index is left alone:
+// a copy of it is used.
+func snapshotTree(dir string) (string, error) {
+	indexPath, err := git(dir, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+	indexPath = strings.TrimSpace(indexPath)
+	if !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(dir, indexPath)
+	}
+
+	tmp, err := os.CreateTemp("", "codex-index-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	// Starting from the real index lets git skip hashing unchanged files
+	if index, err := os.Open(indexPath); err == nil {
+		_, err = io.Copy(tmp, index)
+		index.Close()
+		if err != nil {
+			tmp.Close()
+			return "", err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + tmp.Name()}
+	if _, err := git(dir, env, "add", "--all", "--", "."); err != nil {
+		return "", err
+	}
+	tree, err := git(dir, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// git runs git in dir with the extra environment env and returns its output
+func git(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,131 @@
+package changes
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestUnified(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	want := `--- a/f.txt
++++ b/f.txt
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+`
+	if got := Unified("f.txt", ptr(before), ptr(after)); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := Unified("f.txt", ptr(before), ptr(before)); got != "" {
+		t.Errorf("Expected no diff for unchanged content, got:\n%s", got)
+	}
+	if got := Unified("new.txt", nil, ptr("x\ny\n")); got != "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+x\n+y\n" {
+		t.Errorf("Unexpected diff of a new file:\n%s", got)
+	}
+	if got := Unified("old.txt", ptr("x\n"), nil); got != "--- a/old.txt\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-x\n" {
+		t.Errorf("Unexpected diff of a deleted file:\n%s", got)
+	}
+}
+
+func TestTrackerWithoutGit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewTracker(dir)
+	if tracker.UsesGit() {
+		t.Fatal("Expected no git snapshot outside a repository")
+	}
+
+	tracker.Before(path)
+	os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0644)
+	tracker.Written(path)
+	// Only the content before the first write counts
+	tracker.Before(path)
+	tracker.Written(path)
+	tracker.Before("notes.txt")
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo\n"), 0644)
+	tracker.Written("notes.txt")
+
+	if files := tracker.Files(); strings.Join(files, ",") != "main.go,notes.txt" {
+		t.Errorf("Unexpected files: %v", files)
+	}
+	diff, err := tracker.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--- a/main.go\n", "+func main() {}\n", "--- /dev/null\n+++ b/notes.txt\n", "+todo\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in the diff:\n%s", want, diff)
+		}
+	}
+}
+
+func TestTrackerWithGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	write("main.go", "package main\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "init")
+	// Uncommitted work from before the session is not part of its diff
+	write("draft.txt", "before the session\n")
+
+	tracker := NewTracker(dir)
+	if !tracker.UsesGit() {
+		t.Fatal("Expected a git snapshot")
+	}
+	if diff, err := tracker.Diff(); err != nil || diff != "" {
+		t.Fatalf("Expected no changes yet, got %q, %v", diff, err)
+	}
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("made-by-a-command.txt", "new\n")
+	diff, err := tracker.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+func main() {}") || !strings.Contains(diff, "b/made-by-a-command.txt") || strings.Contains(diff, "draft.txt") {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+
+	// The repository's own index is untouched
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	if !strings.Contains(string(out), "?? draft.txt") || !strings.Contains(string(out), " M main.go") {
+		t.Errorf("Expected the index to be left alone, got:\n%s", out)
+	}
+}
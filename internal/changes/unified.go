@@ -0,0 +1,190 @@
+package changes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around a change
+const contextLines = 3
+
+// maxDiffCells bounds the table of the line diff; larger files are shown
+// as entirely replaced
+const maxDiffCells = 4_000_000
+
+// edit is one line of a line diff
+type edit struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// Unified returns the unified diff of a file from before to after, where nil
+// means the file does not exist. It returns "" when nothing changed.
+func Unified(path string, before, after *string) string {
+	if before != nil && after != nil && *before == *after {
+		return ""
+	}
+	if before == nil && after == nil {
+		return ""
+	}
+	oldName, newName := "a/"+path, "b/"+path
+	if before == nil {
+		oldName = "/dev/null"
+	}
+	if after == nil {
+		newName = "/dev/null"
+	}
+
+	edits := diffLines(splitLines(before), splitLines(after))
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(edits) {
+		b.WriteString(h)
+	}
+	return b.String()
+}
+
+// splitLines splits content into lines without their line breaks
+func splitLines(content *string) []string {
+	if content == nil || *content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(*content, "\n"), "\n")
+}
+
+// diffLines returns the edits turning a into b, from their longest common
+// subsequence of lines
+func diffLines(a, b []string) []edit {
+	// Common lines at both ends need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{' ', line})
+	}
+	edits = append(edits, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+// diffMiddle diffs the lines between the common prefix and suffix
+func diffMiddle(a, b []string) []edit {
+	var edits []edit
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			edits = append(edits, edit{'-', line})
+		}
+		for _, line := range b {
+			edits = append(edits, edit{'+', line})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{'+', b[j]})
+	}
+	return edits
+}
+
+// hunks groups the edits into hunks with their context, merging changes
+// whose context would overlap
+func hunks(edits []edit) []string {
+	var out []string
+	for start := 0; start < len(edits); {
+		// Find the next change
+		first := start
+		for first < len(edits) && edits[first].kind == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		// Extend the hunk while the next change is close enough
+		last := first
+		for k := first; k < len(edits); k++ {
+			if edits[k].kind != ' ' {
+				last = k
+			} else if k-last > 2*contextLines {
+				break
+			}
+		}
+		from := max(first-contextLines, start)
+		to := min(last+contextLines+1, len(edits))
+		out = append(out, formatHunk(edits, from, to))
+		start = to
+	}
+	return out
+}
+
+// formatHunk renders edits[from:to] with its @@ header
+func formatHunk(edits []edit, from, to int) string {
+	oldStart, newStart := 1, 1
+	for _, e := range edits[:from] {
+		if e.kind != '+' {
+			oldStart++
+		}
+		if e.kind != '-' {
+			newStart++
+		}
+	}
+	var body strings.Builder
+	oldCount, newCount := 0, 0
+	for _, e := range edits[from:to] {
+		if e.kind != '+' {
+			oldCount++
+		}
+		if e.kind != '-' {
+			newCount++
+		}
+		body.WriteByte(e.kind)
+		body.WriteString(e.text + "\n")
+	}
+	// An empty side starts at the line before it, as in git
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", oldStart, oldCount, newStart, newCount, body.String())
+}
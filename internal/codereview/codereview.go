@@ -0,0 +1,287 @@
+// Package codereview reviews the changes of a branch with the model, for
+// `codex review`. The diff against the base branch is split into chunks that
+// fit the model's budget, each chunk is reviewed on its own, and the comments
+// are merged into one report.
+package codereview
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/repomap"
+)
+
+// Severities of a comment, from the most to the least serious
+const (
+	SeverityError   = "error"   // A bug, security problem or broken behavior
+	SeverityWarning = "warning" // Likely to cause trouble, or missing handling
+	SeverityInfo    = "info"    // Style, naming, readability and other suggestions
+)
+
+// bytesPerToken matches the estimate of repomap.EstimateTokens
+const bytesPerToken = 4
+
+// severityRank orders severities for sorting
+var severityRank = map[string]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+
+// Instructions is the system prompt for reviewing a chunk
+const Instructions = `You are reviewing a pull request. You are given part of its diff, with the names of the files it covers.
+Point out bugs, security problems, missing error handling, race conditions, unclear code and missing tests. Do not comment on what is fine, and do not repeat the diff.
+Reply with JSON only, with no code fences, in this shape:
+{"comments":[{"file":"path/in/diff.go","line":42,"severity":"error","message":"what is wrong and why","suggestion":"how to fix it, optional"}]}
+"line" is the line number in the new version of the file, from the @@ hunk headers. "severity" is "error" for bugs and security problems, "warning" for likely problems, and "info" for the rest.
+Reply {"comments":[]} if there is nothing worth raising.`
+
+// ErrNoChanges is returned when the branch does not differ from its base
+var ErrNoChanges = errors.New("no changes against the base branch")
+
+// Comment is one review comment
+type Comment struct {
+	File       string `json:"file"`
+	Line       int    `json:"line,omitempty"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Chunk is part of a diff that is reviewed in one request
+type Chunk struct {
+	Files []string // The files the chunk covers, in diff order
+	Diff  string
+}
+
+// Complete asks the model to answer the user message given the system prompt
+type Complete func(ctx context.Context, system, user string) (string, error)
+
+// DefaultBase returns the branch to review against: the remote's default
+// branch if there is one, otherwise main or master
+func DefaultBase(dir string) string {
+	if ref, err := git(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil && ref != "" {
+		return strings.TrimSpace(ref)
+	}
+	for _, name := range []string{"main", "master"} {
+		if _, err := git(dir, "rev-parse", "--verify", "--quiet", name); err == nil {
+			return name
+		}
+	}
+	return "main"
+}
+
+// Diff returns the changes of HEAD since it forked from base
+func Diff(dir, base string) (string, error) {
+	diff, err := git(dir, "diff", "--no-color", "--no-ext-diff", base+"...HEAD")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return "", ErrNoChanges
+	}
+	return diff, nil
+}
+
+// fileDiff is the part of a diff about one file
+type fileDiff struct {
+	path   string
+	header string   // From "diff --git" up to the first hunk
+	hunks  []string // Each starting at its "@@" line
+}
+
+// text returns the file's diff with the given hunks
+func (f fileDiff) text(hunks []string) string {
+	return f.header + strings.Join(hunks, "")
+}
+
+// splitFiles splits a git diff into its files
+func splitFiles(diff string) []fileDiff {
+	var files []fileDiff
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			path := strings.TrimSpace(line[strings.LastIndex(line, " b/")+3:])
+			files = append(files, fileDiff{path: path, header: line})
+		case len(files) == 0:
+			continue // Anything before the first file
+		case strings.HasPrefix(line, "@@"):
+			f := &files[len(files)-1]
+			f.hunks = append(f.hunks, line)
+		default:
+			f := &files[len(files)-1]
+			if len(f.hunks) == 0 {
+				f.header += line
+			} else {
+				f.hunks[len(f.hunks)-1] += line
+			}
+		}
+	}
+	return files
+}
+
+// Split groups the files of a diff into chunks of at most maxTokens each.
+// A file too large for one chunk is split between its hunks, repeating its
+// header, and a hunk too large on its own is cut.
+func Split(diff string, maxTokens int) []Chunk {
+	var chunks []Chunk
+	var current Chunk
+	flush := func() {
+		if current.Diff != "" {
+			chunks = append(chunks, current)
+		}
+		current = Chunk{}
+	}
+	add := func(path, text string) {
+		if current.Diff != "" && repomap.EstimateTokens(current.Diff+text) > maxTokens {
+			flush()
+		}
+		if len(current.Files) == 0 || current.Files[len(current.Files)-1] != path {
+			current.Files = append(current.Files, path)
+		}
+		current.Diff += text
+	}
+
+	for _, f := range splitFiles(diff) {
+		if whole := f.text(f.hunks); repomap.EstimateTokens(whole) <= maxTokens {
+			add(f.path, whole)
+			continue
+		}
+		// One chunk per run of hunks that fits, each with the file header
+		var run []string
+		for _, hunk := range f.hunks {
+			if maxBytes := maxTokens*bytesPerToken - len(f.header); len(hunk) > maxBytes && maxBytes > 0 {
+				hunk = hunk[:maxBytes] + "\n[hunk truncated]\n"
+			}
+			if len(run) > 0 && repomap.EstimateTokens(f.text(append(run, hunk))) > maxTokens {
+				flush()
+				add(f.path, f.text(run))
+				flush()
+				run = nil
+			}
+			run = append(run, hunk)
+		}
+		flush()
+		add(f.path, f.text(run))
+		flush()
+	}
+	flush()
+	return chunks
+}
+
+// Options configures a review run
+type Options struct {
+	Dir       string
+	Base      string // The branch to diff against; DefaultBase if empty
+	MaxTokens int    // The budget of each chunk; DefaultMaxTokens if 0
+	Guidance  string
+	Progress  func(i int, c Chunk)
+}
+
+// DefaultMaxTokens is the default budget of a chunk, leaving room in the
+// context for the instructions and the reply
+const DefaultMaxTokens = 8000
+
+// Run reviews the changes of the branch in opts.Dir against its base
+func Run(ctx context.Context, complete Complete, opts Options) (Report, error) {
+	if opts.Base == "" {
+		opts.Base = DefaultBase(opts.Dir)
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	report := Report{Base: opts.Base}
+	diff, err := Diff(opts.Dir, opts.Base)
+	if err != nil {
+		return report, err
+	}
+	chunks := Split(diff, opts.MaxTokens)
+	report.Files = len(splitFiles(diff))
+	report.Chunks = len(chunks)
+	report.Comments, err = Review(ctx, complete, chunks, opts.Guidance, opts.Progress)
+	return report, err
+}
+
+// Review asks the model to review each chunk and returns the comments, the
+// most serious first. Guidance, if not empty, is passed on from the user.
+// Progress, if not nil, is called before each chunk.
+func Review(ctx context.Context, complete Complete, chunks []Chunk, guidance string, progress func(i int, c Chunk)) ([]Comment, error) {
+	var comments []Comment
+	for i, chunk := range chunks {
+		if progress != nil {
+			progress(i, chunk)
+		}
+		var b strings.Builder
+		if guidance != "" {
+			fmt.Fprintf(&b, "Focus from the user: %s\n\n", guidance)
+		}
+		fmt.Fprintf(&b, "Files in this part (%d of %d): %s\n\n%s", i+1, len(chunks), strings.Join(chunk.Files, ", "), chunk.Diff)
+		reply, err := complete(ctx, Instructions, b.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to review %s: %w", strings.Join(chunk.Files, ", "), err)
+		}
+		found, err := ParseComments(reply)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the review of %s: %w", strings.Join(chunk.Files, ", "), err)
+		}
+		comments = append(comments, found...)
+	}
+	Sort(comments)
+	return comments, nil
+}
+
+// ParseComments reads the comments of a model reply, tolerating a code fence
+// or text around the JSON. Unknown severities become info.
+func ParseComments(reply string) ([]Comment, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("the reply has no JSON object")
+	}
+	var parsed struct {
+		Comments []Comment `json:"comments"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return nil, err
+	}
+	var comments []Comment
+	for _, c := range parsed.Comments {
+		c.Severity = strings.ToLower(strings.TrimSpace(c.Severity))
+		if _, ok := severityRank[c.Severity]; !ok {
+			c.Severity = SeverityInfo
+		}
+		if strings.TrimSpace(c.Message) != "" {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+// Sort orders comments by severity, then file and line
+func Sort(comments []Comment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
+
+// git runs git in dir and returns its output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
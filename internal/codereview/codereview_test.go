@@ -0,0 +1,182 @@
+package codereview
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fileDiffText returns a git diff of path with the given number of hunks of
+// lines added lines each
+func fileDiffText(path string, hunks, lines int) string {
+	var b strings.Builder
+	b.WriteString("diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path + "\n")
+	for h := 0; h < hunks; h++ {
+		b.WriteString("@@ -1,1 +1,2 @@\n")
+		for i := 0; i < lines; i++ {
+			b.WriteString("+added line of some length\n")
+		}
+	}
+	return b.String()
+}
+
+func TestSplit(t *testing.T) {
+	diff := fileDiffText("a.go", 1, 2) + fileDiffText("b.go", 1, 2)
+	chunks := Split(diff, 1000)
+	if len(chunks) != 1 || strings.Join(chunks[0].Files, ",") != "a.go,b.go" || chunks[0].Diff != diff {
+		t.Fatalf("Expected small files in one chunk, got %+v", chunks)
+	}
+
+	// A budget that fits one file at a time
+	chunks = Split(diff, 40)
+	if len(chunks) != 2 || chunks[0].Files[0] != "a.go" || chunks[1].Files[0] != "b.go" {
+		t.Fatalf("Expected a chunk per file, got %+v", chunks)
+	}
+
+	// A large file is split between its hunks, each part with the header
+	big := fileDiffText("big.go", 4, 10)
+	chunks = Split(big, 150)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the large file to be split, got %d chunk(s)", len(chunks))
+	}
+	hunks := 0
+	for _, c := range chunks {
+		if !strings.HasPrefix(c.Diff, "diff --git a/big.go b/big.go\n") || c.Files[0] != "big.go" {
+			t.Errorf("Expected each part to start with the header, got:\n%s", c.Diff)
+		}
+		hunks += strings.Count(c.Diff, "@@ -1,1")
+	}
+	if hunks != 4 {
+		t.Errorf("Expected all 4 hunks across the chunks, got %d", hunks)
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	reply := "Here is the review:\n```json\n" + `{"comments":[
+		{"file":"a.go","line":3,"severity":"WARNING","message":"unchecked error","suggestion":"check it"},
+		{"file":"b.go","severity":"nitpick","message":"naming"},
+		{"file":"c.go","severity":"error","message":" "}
+	]}` + "\n```"
+	comments, err := ParseComments(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 {
+		t.Fatalf("Expected the empty comment to be dropped, got %+v", comments)
+	}
+	if comments[0].Severity != SeverityWarning || comments[0].Suggestion != "check it" {
+		t.Errorf("Unexpected first comment: %+v", comments[0])
+	}
+	if comments[1].Severity != SeverityInfo {
+		t.Errorf("Expected an unknown severity to become info, got %q", comments[1].Severity)
+	}
+
+	if _, err := ParseComments("Looks good to me!"); err == nil {
+		t.Error("Expected an error for a reply without JSON")
+	}
+}
+
+func TestReview(t *testing.T) {
+	chunks := []Chunk{{Files: []string{"b.go"}, Diff: "b"}, {Files: []string{"a.go"}, Diff: "a"}}
+	var prompts []string
+	complete := func(ctx context.Context, system, user string) (string, error) {
+		prompts = append(prompts, user)
+		if strings.Contains(user, "b.go") {
+			return `{"comments":[{"file":"b.go","line":1,"severity":"info","message":"rename"}]}`, nil
+		}
+		return `{"comments":[{"file":"a.go","line":9,"severity":"error","message":"nil dereference"}]}`, nil
+	}
+	comments, err := Review(context.Background(), complete, chunks, "security", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[0].File != "a.go" || comments[1].File != "b.go" {
+		t.Errorf("Expected the error first, got %+v", comments)
+	}
+	if !strings.Contains(prompts[0], "Focus from the user: security") || !strings.Contains(prompts[1], "(2 of 2)") {
+		t.Errorf("Unexpected prompts: %q", prompts)
+	}
+
+	failing := func(ctx context.Context, system, user string) (string, error) {
+		return "", errors.New("rate limited")
+	}
+	if _, err := Review(context.Background(), failing, chunks, "", nil); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("Expected the model error, got %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := Report{Base: "main", Files: 2, Chunks: 1, Comments: []Comment{
+		{File: "a.go", Line: 9, Severity: SeverityError, Message: "nil dereference", Suggestion: "check for nil"},
+		{File: "b.go", Severity: SeverityInfo, Message: "rename"},
+	}}
+	md := report.Markdown()
+	for _, want := range []string{"since main", "**error** `a.go:9`: nil dereference", "Suggestion: check for nil", "`b.go`: rename", "1 error(s)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Expected %q in the markdown:\n%s", want, md)
+		}
+	}
+	js, err := report.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(js, `"line": 9`) || !strings.Contains(js, `"severity": "info"`) {
+		t.Errorf("Unexpected JSON:\n%s", js)
+	}
+	if js, _ := (Report{Base: "main"}).JSON(); !strings.Contains(js, `"comments": []`) {
+		t.Errorf("Expected an empty comment list, got:\n%s", js)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q", "-b", "main")
+	write("main.go", "package main\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "init")
+	run("checkout", "-q", "-b", "feature")
+
+	complete := func(ctx context.Context, system, user string) (string, error) {
+		t.Error("Expected no request without changes")
+		return "", nil
+	}
+	if _, err := Run(context.Background(), complete, Options{Dir: dir}); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Expected ErrNoChanges, got %v", err)
+	}
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	run("commit", "-q", "-am", "add main")
+	complete = func(ctx context.Context, system, user string) (string, error) {
+		if !strings.Contains(user, "+func main() {}") {
+			t.Errorf("Expected the branch diff, got:\n%s", user)
+		}
+		return `{"comments":[{"file":"main.go","line":3,"severity":"info","message":"empty main"}]}`, nil
+	}
+	report, err := Run(context.Background(), complete, Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Base != "main" || report.Files != 1 || report.Chunks != 1 || len(report.Comments) != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
@@ -0,0 +1,78 @@
+package codereview
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Report is the outcome of a review
+type Report struct {
+	Base     string    `json:"base"`
+	Files    int       `json:"files"`
+	Chunks   int       `json:"chunks"`
+	Comments []Comment `json:"comments"`
+}
+
+// Counts returns the number of comments of each severity
+func (r Report) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, c := range r.Comments {
+		counts[c.Severity]++
+	}
+	return counts
+}
+
+// Summary describes the report in one line
+func (r Report) Summary() string {
+	counts := r.Counts()
+	return fmt.Sprintf("%d comment(s) on %d file(s) changed since %s: %d error(s), %d warning(s), %d info",
+		len(r.Comments), r.Files, r.Base, counts[SeverityError], counts[SeverityWarning], counts[SeverityInfo])
+}
+
+// location returns where a comment points, as file:line
+func (c Comment) location() string {
+	if c.Line > 0 {
+		return fmt.Sprintf("%s:%d", c.File, c.Line)
+	}
+	return c.File
+}
+
+// JSON renders the report as indented JSON
+func (r Report) JSON() (string, error) {
+	if r.Comments == nil {
+		r.Comments = []Comment{} // An empty list rather than null
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// Markdown renders the report for a pull request or the chat
+func (r Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Review of the changes since %s\n\n%s\n", r.Base, r.Summary())
+	for _, c := range r.Comments {
+		fmt.Fprintf(&b, "\n- **%s** `%s`: %s\n", c.Severity, c.location(), c.Message)
+		if c.Suggestion != "" {
+			fmt.Fprintf(&b, "  - Suggestion: %s\n", c.Suggestion)
+		}
+	}
+	return b.String()
+}
+
+// Text renders the report as plain text for a terminal
+func (r Report) Text() string {
+	var b strings.Builder
+	for _, c := range r.Comments {
+		fmt.Fprintf(&b, "%-7s %s\n        %s\n", c.Severity, c.location(), c.Message)
+		if c.Suggestion != "" {
+			fmt.Fprintf(&b, "        Suggestion: %s\n", c.Suggestion)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(r.Summary() + "\n")
+	return b.String()
+}
@@ -0,0 +1,127 @@
+// Package commitmsg writes commit messages for the staged changes of a
+// repository with the model, for `codex commit`.
+package commitmsg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// maxDiffBytes bounds the diff sent to the model; the stat still lists every file
+const maxDiffBytes = 48 * 1024
+
+// Instructions is the system prompt for writing a message
+const Instructions = `You write git commit messages in the Conventional Commits format.
+You are given the staged changes of a repository: a summary of the changed files, then the diff.
+Reply with the commit message only, with no code fences or commentary:
+- A subject line "<type>(<optional scope>): <summary>", at most 72 characters, in the imperative mood, without a trailing period. The type is one of feat, fix, docs, style, refactor, perf, test, build, ci or chore.
+- If the change needs explaining, a blank line and a body wrapped at 72 characters saying what changed and why, not how.
+- "BREAKING CHANGE: <description>" as a footer if the change breaks compatibility.`
+
+// ErrNothingStaged is returned when there are no staged changes to describe
+var ErrNothingStaged = errors.New("no staged changes; stage them with git add first")
+
+// Staged is the staged changes of a repository
+type Staged struct {
+	Stat string // git diff --cached --stat
+	Diff string // git diff --cached, cut at 48KB
+}
+
+// Complete asks the model to answer the user message given the system prompt
+type Complete func(ctx context.Context, system, user string) (string, error)
+
+// StagedChanges returns the staged changes of the repository holding dir
+func StagedChanges(dir string) (*Staged, error) {
+	stat, err := git(dir, "diff", "--cached", "--stat")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(stat) == "" {
+		return nil, ErrNothingStaged
+	}
+	diff, err := git(dir, "diff", "--cached", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return nil, err
+	}
+	if len(diff) > maxDiffBytes {
+		diff = diff[:maxDiffBytes] + "\n[diff truncated]"
+	}
+	return &Staged{Stat: strings.TrimRight(stat, "\n"), Diff: diff}, nil
+}
+
+// Generate asks the model for a message describing the staged changes. Hint,
+// if not empty, is passed on as guidance from the user.
+func Generate(ctx context.Context, complete Complete, staged *Staged, hint string) (string, error) {
+	var b strings.Builder
+	if hint != "" {
+		fmt.Fprintf(&b, "Guidance from the user: %s\n\n", hint)
+	}
+	fmt.Fprintf(&b, "Changed files:\n%s\n\nDiff:\n%s", staged.Stat, staged.Diff)
+	reply, err := complete(ctx, Instructions, b.String())
+	if err != nil {
+		return "", err
+	}
+	message := Clean(reply)
+	if message == "" {
+		return "", errors.New("the model returned an empty commit message")
+	}
+	return message, nil
+}
+
+// fenceRe matches a reply wrapped in a code fence
+var fenceRe = regexp.MustCompile("(?s)^```[a-z]*\\n(.*?)\\n?```$")
+
+// Clean removes the code fence or quotes a model may wrap a message in, and
+// trailing spaces
+func Clean(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if m := fenceRe.FindStringSubmatch(reply); m != nil {
+		reply = strings.TrimSpace(m[1])
+	}
+	if len(reply) > 1 && reply[0] == '"' && reply[len(reply)-1] == '"' && !strings.Contains(reply, "\n") {
+		reply = reply[1 : len(reply)-1]
+	}
+	lines := strings.Split(reply, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Subject returns the first line of message
+func Subject(message string) string {
+	subject, _, _ := strings.Cut(message, "\n")
+	return subject
+}
+
+// Commit commits the staged changes of the repository holding dir with
+// message, and returns git's summary
+func Commit(dir, message string) (string, error) {
+	cmd := exec.Command("git", "commit", "-F", "-")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(message + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git commit: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// git runs git in dir and returns its output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
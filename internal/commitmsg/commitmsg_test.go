@@ -0,0 +1,99 @@
+package commitmsg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo creates a repository with one commit
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	for _, args := range [][]string{{"init", "-q"}, {"commit", "-q", "--allow-empty", "-m", "init"}} {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestStagedChangesAndCommit(t *testing.T) {
+	dir := newRepo(t)
+	if _, err := StagedChanges(dir); !errors.Is(err, ErrNothingStaged) {
+		t.Fatalf("Expected ErrNothingStaged, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(dir, "add", "main.go"); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := StagedChanges(dir)
+	if err != nil {
+		t.Fatalf("StagedChanges failed: %v", err)
+	}
+	if !strings.Contains(staged.Stat, "main.go") || !strings.Contains(staged.Diff, "+package main") {
+		t.Errorf("Unexpected staged changes %+v", staged)
+	}
+
+	if _, err := Commit(dir, "feat: add main\n\nStarts the program."); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if log, _ := git(dir, "log", "-1", "--format=%B"); strings.TrimSpace(log) != "feat: add main\n\nStarts the program." {
+		t.Errorf("Unexpected commit message %q", log)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var system, user string
+	complete := func(ctx context.Context, s, u string) (string, error) {
+		system, user = s, u
+		return "```\nfix(api): retry on 503   \n\nThe provider sheds load.\n```", nil
+	}
+	staged := &Staged{Stat: " api.go | 2 +-", Diff: "diff --git a/api.go b/api.go"}
+
+	message, err := Generate(context.Background(), complete, staged, "mention the outage")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if message != "fix(api): retry on 503\n\nThe provider sheds load." {
+		t.Errorf("Unexpected message %q", message)
+	}
+	if system != Instructions || !strings.HasPrefix(user, "Guidance from the user: mention the outage") || !strings.Contains(user, staged.Diff) {
+		t.Errorf("Unexpected prompt %q", user)
+	}
+	if Subject(message) != "fix(api): retry on 503" {
+		t.Errorf("Unexpected subject %q", Subject(message))
+	}
+
+	empty := func(ctx context.Context, s, u string) (string, error) { return "  ", nil }
+	if _, err := Generate(context.Background(), empty, staged, ""); err == nil {
+		t.Error("Expected an error for an empty message")
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := map[string]string{
+		`"docs: fix typo"`:                 "docs: fix typo",
+		"```text\nchore: bump deps\n```":   "chore: bump deps",
+		"  feat: add flag  \n\nbody  \n\n": "feat: add flag\n\nbody",
+	}
+	for in, want := range tests {
+		if got := Clean(in); got != want {
+			t.Errorf("Clean(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
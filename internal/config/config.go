@@ -2,10 +2,15 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/epuerta/codex-go/internal/contextbudget"
+	"github.com/epuerta/codex-go/internal/fileops"
+	"github.com/epuerta/codex-go/internal/sandbox"
 	"github.com/spf13/viper"
 )
 
@@ -24,6 +29,19 @@ const (
 	DangerousAutoApprove ApprovalMode = "dangerous"
 )
 
+// ApprovalModes lists the approval modes, from the most to the least careful
+var ApprovalModes = []ApprovalMode{Suggest, AutoEdit, FullAuto, DangerousAutoApprove}
+
+// ParseApprovalMode returns the approval mode named s, ignoring case
+func ParseApprovalMode(s string) (ApprovalMode, error) {
+	for _, mode := range ApprovalModes {
+		if strings.EqualFold(s, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid approval mode %q (use suggest, auto-edit, full-auto or dangerous)", s)
+}
+
 // Config holds all configuration options for the application
 type Config struct {
 	// API configuration
@@ -31,18 +49,143 @@ type Config struct {
 	Model      string `mapstructure:"model"`
 	BaseURL    string `mapstructure:"base_url"`
 	APITimeout int    `mapstructure:"api_timeout"` // in seconds
+	Proxy      string `mapstructure:"proxy"`       // Proxy of API requests; HTTPS_PROXY and the like if empty
+	MaxRetries int    `mapstructure:"max_retries"` // Retries of API requests failing with 429, 5xx or a network error
+
+	// ModelFallbacks are tried in order when the model is unavailable, rate
+	// limited or rejects a request
+	ModelFallbacks []string `mapstructure:"model_fallbacks"`
+
+	// ToolCallProfile names how the backend streams tool calls ("auto" detects it from BaseURL)
+	ToolCallProfile string `mapstructure:"tool_call_profile"`
+
+	// Rate limits shared by all sessions using the same API key (0 = unlimited)
+	RateLimitRPM int `mapstructure:"rate_limit_rpm"` // Requests per minute
+	RateLimitTPM int `mapstructure:"rate_limit_tpm"` // Tokens per minute
 
 	// Project configuration
 	CWD               string `mapstructure:"cwd"`
 	ProjectDocPath    string `mapstructure:"project_doc_path"`
 	DisableProjectDoc bool   `mapstructure:"disable_project_doc"`
+	DisableRepoMap    bool   `mapstructure:"disable_repo_map"`    // Don't include the cached repository map in context
+	RepoMapTokens     int    `mapstructure:"repo_map_tokens"`     // Approximate token budget of the repository map
+	RepoContextTokens int    `mapstructure:"repo_context_tokens"` // Budget of codex.md files and the map together (0 = no limit)
+	InstructionTokens int    `mapstructure:"instruction_tokens"`  // Budget of the AGENTS.md and codex.md files of subdirectories (0 = no limit)
 	Instructions      string `mapstructure:"instructions"`
 
+	// ContextTokens is the budget of the messages of a request (0 = three
+	// quarters of the smallest context window among the models, or no limit
+	// if none is known). ContextWeights shares it among the sources of the
+	// context; see the contextbudget package.
+	ContextTokens  int                `mapstructure:"context_tokens"`
+	ContextWeights map[string]float64 `mapstructure:"context_weights"`
+
+	// Semantic index configuration (see 'codex index build')
+	EmbeddingModel         string `mapstructure:"embedding_model"`          // Model used to embed the index and queries
+	EmbeddingBaseURL       string `mapstructure:"embedding_base_url"`       // Embeddings API, if not the one at base_url
+	EmbeddingAPIKey        string `mapstructure:"embedding_api_key"`        // Key of the embeddings API, if not api_key
+	SemanticContextResults int    `mapstructure:"semantic_context_results"` // Index snippets added to each prompt (0 = none)
+
+	// DisableProjectScripts hides Makefile, package.json and Taskfile scripts from the agent
+	DisableProjectScripts bool `mapstructure:"disable_project_scripts"`
+
+	// Network tools configuration (fetch_url)
+	AllowNetworkTools bool     `mapstructure:"allow_network_tools"` // Offer tools that reach the network
+	AllowedDomains    []string `mapstructure:"allowed_domains"`     // Domains they may reach, with subdomains; "*" allows any
+	FetchMaxTokens    int      `mapstructure:"fetch_max_tokens"`    // Approximate token budget of a fetched page
+
+	// ExploreCalls bounds the read-only tool calls of the explore phase (/explore, exec --explore)
+	ExploreCalls int `mapstructure:"explore_calls"`
+
+	// PatchReviewHunks is the number of hunks from which the TUI asks for a
+	// patch's approval hunk by hunk; 0 always approves patches whole
+	PatchReviewHunks int `mapstructure:"patch_review_hunks"`
+
+	// BranchIsolation runs each full-auto task of exec and quiet mode on a
+	// new codex/<slug>-<timestamp> branch, leaving the current branch untouched
+	BranchIsolation bool `mapstructure:"branch_isolation"`
+
+	// ResponseCache answers requests quiet and exec mode have sent before
+	// from ~/.codex/responses, for ResponseCacheTTL seconds
+	ResponseCache    bool `mapstructure:"response_cache"`
+	ResponseCacheTTL int  `mapstructure:"response_cache_ttl"`
+
+	// DisablePlugins skips starting the executables in ~/.codex/plugins
+	DisablePlugins bool `mapstructure:"disable_plugins"`
+
+	// Shell command timeouts, in seconds. The agent may ask for a longer one
+	// per command, up to MaxCommandTimeout.
+	CommandTimeout    int `mapstructure:"command_timeout"`
+	MaxCommandTimeout int `mapstructure:"max_command_timeout"`
+
+	// Ignore lists paths left out of the repository map and the semantic
+	// index, as globs relative to the repository root (see Ignored)
+	Ignore []string `mapstructure:"ignore"`
+
+	// Sandbox configures the sandbox shell commands run in
+	Sandbox SandboxConfig `mapstructure:"sandbox"`
+
+	// Tools are custom commands the agent may run, alongside the project scripts
+	Tools []ToolConfig `mapstructure:"tools"`
+
+	// Profile names the entry of the profiles map applied over the rest of
+	// the config, if any. Profiles bundle settings such as model and base_url
+	// (or provider) to switch between providers with --profile.
+	Profile string `mapstructure:"profile"`
+
+	// ProjectConfigPath is the project config file merged over the global
+	// one, if any, and ProjectConfigWarnings lists its settings that were
+	// not applied
+	ProjectConfigPath     string   `mapstructure:"-"`
+	ProjectConfigWarnings []string `mapstructure:"-"`
+
+	// APIKeySource is the credential store the API key was read from, if it
+	// came from neither the environment nor the config
+	APIKeySource string `mapstructure:"-"`
+
+	// Messages replaces the texts sent to the model when a tool call is
+	// denied, times out or is refused
+	Messages MessagesConfig `mapstructure:"messages"`
+
+	// Telemetry exports traces of the session's requests and tool calls
+	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+
+	// WebhookURL, if set, receives each message and tool event as a JSON POST; it must be on localhost
+	WebhookURL string `mapstructure:"webhook_url"`
+
 	// UI configuration
-	FullStdout bool `mapstructure:"full_stdout"` // Don't truncate command output
+	FullStdout bool `mapstructure:"full_stdout"` // Don't truncate command output in the chat
+
+	// Command output truncation. Output sent to the agent keeps its first and
+	// last lines within a byte budget; the chat shows fewer lines. Lines are not
+	// cut when both line limits are 0, nor bytes when the byte limit is 0.
+	OutputHeadLines   int `mapstructure:"output_head_lines"`    // Lines kept from the start for the agent
+	OutputTailLines   int `mapstructure:"output_tail_lines"`    // Lines kept from the end for the agent
+	OutputMaxBytes    int `mapstructure:"output_max_bytes"`     // Bytes kept for the agent and the chat
+	UIOutputHeadLines int `mapstructure:"ui_output_head_lines"` // Lines kept from the start in the chat
+	UIOutputTailLines int `mapstructure:"ui_output_tail_lines"` // Lines kept from the end in the chat
+	UICollapseLines   int `mapstructure:"ui_collapse_lines"`    // Outputs longer than this are collapsed in the chat; 0 never collapses
+
+	// StatusBar lists the items of the status bar, in order, from
+	// StatusBarItems; empty for the default ones
+	StatusBar []string `mapstructure:"status_bar"`
+
+	// Notify shows a desktop notification, besides ringing the terminal bell,
+	// when the session needs the user while the terminal is unfocused
+	Notify bool `mapstructure:"notify"`
 
 	// Approval configuration
 	ApprovalMode ApprovalMode `mapstructure:"approval_mode"`
+	DryRun       bool         `mapstructure:"dry_run"` // patch_file, write_file and edit_lines show their diff instead of writing
+
+	// Safety configuration
+	SymlinkPolicy   string   `mapstructure:"symlink_policy"`    // "follow" links that stay in the allowed roots (default) or "refuse" them
+	CreateConflict  string   `mapstructure:"create_conflict"`   // What patches creating a file that exists do: "fail" (default), "overwrite" (always asking first) or "rename"
+	AllowedRoots    []string `mapstructure:"allowed_roots"`     // Directories file tools may use; the repository root (or CWD) if empty
+	GuardToolOutput bool     `mapstructure:"guard_tool_output"` // Wrap tool results in delimited, untrusted blocks
+	InjectionScan   bool     `mapstructure:"injection_scan"`    // Scan tool results for prompt-injection attempts
+	RedactSecrets   bool     `mapstructure:"redact_secrets"`    // Replace secrets in messages and tool results before the model sees them
+	RedactPatterns  []string `mapstructure:"redact_patterns"`   // Regular expressions redacted besides the built-in detectors
 
 	// Logging configuration
 	Debug   bool   `mapstructure:"debug"`    // Enable debug logging
@@ -55,17 +198,87 @@ const (
 	DefaultBaseURL    = "https://api.openai.com/v1"
 	DefaultAPITimeout = 60 // seconds
 	DefaultConfigDir  = ".codex"
+
+	// DefaultRepoMapTokens is the approximate token budget of the repository map
+	DefaultRepoMapTokens = 2000
+
+	// DefaultRepoContextTokens is the approximate token budget of the project
+	// docs and repository map together
+	DefaultRepoContextTokens = 6000
+
+	// DefaultInstructionTokens is the approximate token budget of the
+	// instruction files of subdirectories, added as their files are touched
+	DefaultInstructionTokens = 2000
+
+	// DefaultSemanticContextResults is the number of index snippets added to each prompt
+	DefaultSemanticContextResults = 3
+
+	// DefaultFetchMaxTokens is the approximate token budget of a page fetched by fetch_url
+	DefaultFetchMaxTokens = 4000
+
+	// DefaultExploreCalls is the number of read-only tool calls the explore phase allows
+	DefaultExploreCalls = 12
+
+	// DefaultPatchReviewHunks is the number of hunks from which patches are reviewed hunk by hunk
+	DefaultPatchReviewHunks = 3
+
+	// DefaultMaxRetries is the number of times a failed API request is retried
+	DefaultMaxRetries = 2
+
+	// DefaultResponseCacheTTL is how long cached responses are used, in seconds
+	DefaultResponseCacheTTL = 24 * 60 * 60
+
+	// Default shell command timeouts, in seconds
+	DefaultCommandTimeout    = 30
+	DefaultMaxCommandTimeout = 600
+
+	// Default command output truncation
+	DefaultOutputHeadLines   = 100
+	DefaultOutputTailLines   = 100
+	DefaultOutputMaxBytes    = 16 * 1024
+	DefaultUIOutputHeadLines = 20
+	DefaultUIOutputTailLines = 20
+	DefaultUICollapseLines   = 12
 )
 
-// Load loads configuration from files, environment variables, and flags
+// StatusBarItems are the items the status bar can show
+var StatusBarItems = []string{"model", "approval", "branch", "changes", "tokens", "thinking", "session", "workdir"}
+
+// Load loads configuration from files and environment variables
 func Load() (*Config, error) {
+	return LoadProfile("")
+}
+
+// LoadProfile loads configuration like Load, applying the named profile over
+// the config files. An empty name applies the profile the config selects, if any.
+func LoadProfile(profile string) (*Config, error) {
 	// Initialize config with defaults
 	config := &Config{
-		Model:        DefaultModel,
-		BaseURL:      DefaultBaseURL,
-		APITimeout:   DefaultAPITimeout,
-		ApprovalMode: Suggest,
-		CWD:          getWorkingDirectory(),
+		Model:                  DefaultModel,
+		BaseURL:                DefaultBaseURL,
+		APITimeout:             DefaultAPITimeout,
+		MaxRetries:             DefaultMaxRetries,
+		ApprovalMode:           Suggest,
+		GuardToolOutput:        true,
+		InjectionScan:          true,
+		RedactSecrets:          true,
+		RepoMapTokens:          DefaultRepoMapTokens,
+		RepoContextTokens:      DefaultRepoContextTokens,
+		InstructionTokens:      DefaultInstructionTokens,
+		SemanticContextResults: DefaultSemanticContextResults,
+		FetchMaxTokens:         DefaultFetchMaxTokens,
+		ExploreCalls:           DefaultExploreCalls,
+		PatchReviewHunks:       DefaultPatchReviewHunks,
+		ResponseCacheTTL:       DefaultResponseCacheTTL,
+		CommandTimeout:         DefaultCommandTimeout,
+		MaxCommandTimeout:      DefaultMaxCommandTimeout,
+		OutputHeadLines:        DefaultOutputHeadLines,
+		OutputTailLines:        DefaultOutputTailLines,
+		OutputMaxBytes:         DefaultOutputMaxBytes,
+		UIOutputHeadLines:      DefaultUIOutputHeadLines,
+		UIOutputTailLines:      DefaultUIOutputTailLines,
+		UICollapseLines:        DefaultUICollapseLines,
+		CWD:                    getWorkingDirectory(),
 	}
 
 	// Set up viper
@@ -95,19 +308,131 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// The nearest project config overrides the global one
+	if projectPath := FindProjectConfig(config.CWD); projectPath != "" {
+		warnings, err := mergeProjectConfig(v, projectPath)
+		if err != nil {
+			return nil, err
+		}
+		config.ProjectConfigPath = projectPath
+		config.ProjectConfigWarnings = warnings
+	}
+
+	// A profile overrides both, as it is chosen for this session
+	if profile == "" {
+		profile = v.GetString("profile")
+	}
+	profileNeedsKey := false
+	if profile != "" {
+		var err error
+		if profileNeedsKey, err = applyProfile(v, profile); err != nil {
+			return nil, err
+		}
+	}
+
 	// Unmarshal config to struct
 	if err := v.Unmarshal(config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
-	// Load instructions from file if it exists
-	instructionsPath := filepath.Join(configDir, "instructions.md")
-	if _, err := os.Stat(instructionsPath); err == nil {
-		data, err := os.ReadFile(instructionsPath)
-		if err == nil {
-			config.Instructions = string(data)
+	config.Profile = profile
+
+	// Providers other than OpenAI are keyed by their own variable, unless the
+	// config file sets the key. A profile that switches to another provider
+	// without a key of its own takes that provider's variable, rather than
+	// sending it the key of the first.
+	if env := config.Provider().KeyEnv; env != "" && !v.IsSet("api_key") {
+		if apiKey := os.Getenv(env); apiKey != "" {
+			config.APIKey = apiKey
+		}
+	}
+	if profileNeedsKey {
+		config.APIKey = ""
+		if env := config.Provider().KeyEnv; env != "" {
+			config.APIKey = os.Getenv(env)
 		}
 	}
+	// A key saved with "codex auth login" is used when neither the
+	// environment nor the config sets one
+	if config.APIKey == "" && (profileNeedsKey || !v.IsSet("api_key")) {
+		config.APIKey, config.APIKeySource = storedKey(config.Provider())
+	}
+
+	if _, err := fileops.ParseSymlinkPolicy(config.SymlinkPolicy); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err := fileops.ParseCreateConflict(config.CreateConflict); err != nil {
+		return nil, fmt.Errorf("invalid config: create_conflict: %w", err)
+	}
+	if err := sandbox.CheckShell(config.Sandbox.Shell); err != nil {
+		return nil, fmt.Errorf("invalid config: sandbox.shell: %w", err)
+	}
+	if config.RepoMapTokens <= 0 {
+		return nil, fmt.Errorf("invalid config: repo_map_tokens must be positive (set disable_repo_map to leave the map out)")
+	}
+	if config.RepoContextTokens < 0 {
+		return nil, fmt.Errorf("invalid config: repo_context_tokens must not be negative (0 means no limit)")
+	}
+	if config.InstructionTokens < 0 {
+		return nil, fmt.Errorf("invalid config: instruction_tokens must not be negative (0 means no limit)")
+	}
+	if config.ContextTokens < 0 {
+		return nil, fmt.Errorf("invalid config: context_tokens must not be negative (0 derives it from the model)")
+	}
+	if _, err := contextbudget.ParseWeights(config.ContextWeights); err != nil {
+		return nil, fmt.Errorf("invalid config: context_weights: %w", err)
+	}
+	if config.SemanticContextResults < 0 {
+		return nil, fmt.Errorf("invalid config: semantic_context_results must not be negative")
+	}
+	if config.FetchMaxTokens <= 0 {
+		return nil, fmt.Errorf("invalid config: fetch_max_tokens must be positive")
+	}
+	if config.ExploreCalls <= 0 {
+		return nil, fmt.Errorf("invalid config: explore_calls must be positive")
+	}
+	if config.MaxRetries < 0 {
+		return nil, fmt.Errorf("invalid config: max_retries must not be negative")
+	}
+	if config.Proxy != "" {
+		if u, err := url.Parse(config.Proxy); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return nil, fmt.Errorf("invalid config: proxy must be an http, https or socks5 URL, like http://proxy.example.com:3128")
+		}
+	}
+	if config.PatchReviewHunks < 0 {
+		return nil, fmt.Errorf("invalid config: patch_review_hunks must not be negative (0 approves patches whole)")
+	}
+	if config.ResponseCacheTTL <= 0 {
+		return nil, fmt.Errorf("invalid config: response_cache_ttl must be positive")
+	}
+	if config.CommandTimeout <= 0 {
+		return nil, fmt.Errorf("invalid config: command_timeout must be positive")
+	}
+	if config.MaxCommandTimeout < config.CommandTimeout {
+		return nil, fmt.Errorf("invalid config: max_command_timeout (%d) must be at least command_timeout (%d)", config.MaxCommandTimeout, config.CommandTimeout)
+	}
+	for _, item := range config.StatusBar {
+		if !slices.Contains(StatusBarItems, item) {
+			return nil, fmt.Errorf("invalid config: unknown status_bar item %q (want some of %s)", item, strings.Join(StatusBarItems, ", "))
+		}
+	}
+	for _, tool := range config.Tools {
+		if tool.Name == "" || tool.Command == "" {
+			return nil, fmt.Errorf("invalid config: every entry of tools needs a name and a command")
+		}
+	}
+	if err := config.Messages.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := config.Telemetry.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if config.OutputHeadLines < 0 || config.OutputTailLines < 0 || config.OutputMaxBytes < 0 || config.UIOutputHeadLines < 0 || config.UIOutputTailLines < 0 || config.UICollapseLines < 0 {
+		return nil, fmt.Errorf("invalid config: output truncation limits must not be negative")
+	}
+
+	// Load instructions from file if it exists
+	config.Instructions = LoadInstructions()
 
 	// Load project doc if it exists and is not disabled
 	if !config.DisableProjectDoc && config.ProjectDocPath == "" {
@@ -152,6 +477,22 @@ func getConfigDir() string {
 	return configDir
 }
 
+// InstructionsPath returns the path of the user's instructions file,
+// ~/.codex/instructions.md
+func InstructionsPath() string {
+	return filepath.Join(getConfigDir(), "instructions.md")
+}
+
+// LoadInstructions returns the content of the instructions file, which
+// replaces the default system prompt, or "" if there is none
+func LoadInstructions() string {
+	data, err := os.ReadFile(InstructionsPath())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
 // getWorkingDirectory returns the current working directory
 func getWorkingDirectory() string {
 	cwd, err := os.Getwd()
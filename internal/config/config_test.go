@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/epuerta/codex-go/internal/credentials"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -49,6 +52,26 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.ApprovalMode != Suggest {
 		t.Errorf("Expected ApprovalMode=%s, got %s", Suggest, cfg.ApprovalMode)
 	}
+
+	if cfg.RepoMapTokens != DefaultRepoMapTokens {
+		t.Errorf("Expected RepoMapTokens=%d, got %d", DefaultRepoMapTokens, cfg.RepoMapTokens)
+	}
+	if cfg.CommandTimeout != DefaultCommandTimeout || cfg.MaxCommandTimeout != DefaultMaxCommandTimeout {
+		t.Errorf("Expected command timeouts %d/%d, got %d/%d", DefaultCommandTimeout, DefaultMaxCommandTimeout, cfg.CommandTimeout, cfg.MaxCommandTimeout)
+	}
+	if cfg.RepoContextTokens != DefaultRepoContextTokens {
+		t.Errorf("Expected RepoContextTokens=%d, got %d", DefaultRepoContextTokens, cfg.RepoContextTokens)
+	}
+
+	if cfg.OutputHeadLines != DefaultOutputHeadLines || cfg.OutputTailLines != DefaultOutputTailLines || cfg.OutputMaxBytes != DefaultOutputMaxBytes {
+		t.Errorf("Expected the default output limits, got %d/%d lines and %d bytes", cfg.OutputHeadLines, cfg.OutputTailLines, cfg.OutputMaxBytes)
+	}
+	if cfg.UICollapseLines != DefaultUICollapseLines {
+		t.Errorf("Expected outputs collapsed beyond %d lines, got %d", DefaultUICollapseLines, cfg.UICollapseLines)
+	}
+	if cfg.ResponseCache || cfg.ResponseCacheTTL != DefaultResponseCacheTTL {
+		t.Errorf("Expected the response cache off with a TTL of %d, got %t and %d", DefaultResponseCacheTTL, cfg.ResponseCache, cfg.ResponseCacheTTL)
+	}
 }
 
 func TestLoadWithAPIKey(t *testing.T) {
@@ -125,3 +148,168 @@ func TestLoadProjectDoc(t *testing.T) {
 		t.Errorf("Expected empty content with disabled project doc, got %q", content)
 	}
 }
+
+func TestLoadProviderAPIKey(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	t.Setenv("MISTRAL_API_KEY", "mistral-key")
+
+	configDir := filepath.Join(tmpHome, DefaultConfigDir)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte("base_url: https://api.mistral.ai/v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.APIKey != "mistral-key" {
+		t.Errorf("Expected the Mistral key, got %q", cfg.APIKey)
+	}
+}
+
+func TestLoadStoredAPIKey(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("MISTRAL_API_KEY", "")
+
+	store := credentials.NewFile(t.TempDir())
+	orig := OpenCredentials
+	OpenCredentials = func() credentials.Chain { return credentials.Chain{store} }
+	t.Cleanup(func() { OpenCredentials = orig })
+
+	if err := store.Set("OPENAI_API_KEY", "sk-stored"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.APIKey != "sk-stored" || cfg.APIKeySource != store.Name() {
+		t.Errorf("Expected the stored key, got %q from %q", cfg.APIKey, cfg.APIKeySource)
+	}
+
+	// The environment wins over the store
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	if cfg, _ := Load(); cfg.APIKey != "sk-env" || cfg.APIKeySource != "" {
+		t.Errorf("Expected the key of the environment, got %q from %q", cfg.APIKey, cfg.APIKeySource)
+	}
+
+	// Another provider reads its own key
+	t.Setenv("OPENAI_API_KEY", "")
+	configDir := filepath.Join(tmpHome, DefaultConfigDir)
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte("base_url: https://api.mistral.ai/v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := Load(); cfg.APIKey != "" {
+		t.Errorf("Expected no Mistral key, got %q", cfg.APIKey)
+	}
+	if err := store.Set("MISTRAL_API_KEY", "mistral-stored"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := Load(); cfg.APIKey != "mistral-stored" {
+		t.Errorf("Expected the stored Mistral key, got %q", cfg.APIKey)
+	}
+}
+
+func TestParseApprovalMode(t *testing.T) {
+	for input, want := range map[string]ApprovalMode{"suggest": Suggest, "Auto-Edit": AutoEdit, "full-auto": FullAuto, "dangerous": DangerousAutoApprove} {
+		got, err := ParseApprovalMode(input)
+		if err != nil || got != want {
+			t.Errorf("ParseApprovalMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseApprovalMode("auto"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestMessagesConfig(t *testing.T) {
+	messages := MessagesConfig{Denied: "{{.Tool}} was declined.{{if .Note}} The user said: {{.Note}}{{end}}"}
+	if err := messages.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if got := messages.Render(MessageDenied, MessageData{Default: "denied", Tool: "shell", Note: "use make"}); got != "shell was declined. The user said: use make" {
+		t.Errorf("Unexpected denial: %q", got)
+	}
+	if got := messages.Render(MessageTimeout, MessageData{Default: "Command timed out."}); got != "Command timed out." {
+		t.Errorf("Expected the default without a template, got %q", got)
+	}
+
+	for _, bad := range []MessagesConfig{{Timeout: "{{.Command"}, {Refused: "{{.Unknown}}"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", bad)
+		}
+		if got := bad.Render(MessageTimeout, MessageData{Default: "default"}); bad.Timeout != "" && got != "default" {
+			t.Errorf("Expected the default for a broken template, got %q", got)
+		}
+	}
+}
+
+func TestTelemetryConfig(t *testing.T) {
+	if (TelemetryConfig{}).On() {
+		t.Error("Expected telemetry to be off by default")
+	}
+	if !(TelemetryConfig{Endpoint: "http://localhost:4318"}).On() {
+		t.Error("Expected an endpoint to turn telemetry on")
+	}
+	for _, endpoint := range []string{"", "http://localhost:4318", "https://otel.example.com/v1/traces"} {
+		if err := (TelemetryConfig{Endpoint: endpoint}).Validate(); err != nil {
+			t.Errorf("Expected %q to be valid: %v", endpoint, err)
+		}
+	}
+	for _, endpoint := range []string{"localhost:4318", "grpc://localhost:4317", "http://"} {
+		if err := (TelemetryConfig{Endpoint: endpoint}).Validate(); err == nil {
+			t.Errorf("Expected %q to be invalid", endpoint)
+		}
+	}
+}
+
+func TestLoadStatusBar(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("OPENAI_API_KEY", "test")
+	chdir(t, t.TempDir())
+	configFile := filepath.Join(home, DefaultConfigDir, "config.yaml")
+
+	writeFile(t, configFile, "status_bar: [tokens, model]\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if strings.Join(cfg.StatusBar, ",") != "tokens,model" {
+		t.Errorf("Unexpected status bar: %v", cfg.StatusBar)
+	}
+
+	writeFile(t, configFile, "status_bar: [model, clock]\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), `unknown status_bar item "clock"`) {
+		t.Errorf("Expected an unknown item error, got %v", err)
+	}
+}
+
+func TestLoadContextWeights(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("OPENAI_API_KEY", "test")
+	chdir(t, t.TempDir())
+	configFile := filepath.Join(home, DefaultConfigDir, "config.yaml")
+
+	writeFile(t, configFile, "context_tokens: 50000\ncontext_weights:\n  history: 60\n  repo_map: 0\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.ContextTokens != 50000 || cfg.ContextWeights["history"] != 60 || cfg.ContextWeights["repo_map"] != 0 {
+		t.Errorf("Unexpected context budget: %d %v", cfg.ContextTokens, cfg.ContextWeights)
+	}
+
+	writeFile(t, configFile, "context_weights:\n  readme: 10\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), `unknown context source "readme"`) {
+		t.Errorf("Expected an unknown source error, got %v", err)
+	}
+}
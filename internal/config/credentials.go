@@ -0,0 +1,33 @@
+package config
+
+import (
+	"github.com/epuerta/codex-go/internal/credentials"
+)
+
+// OpenCredentials returns the stores of the API keys saved with
+// "codex auth login": the platform keychain, then the encrypted file in the
+// config directory. Tests replace it to keep away from the real keychain.
+var OpenCredentials = func() credentials.Chain {
+	return credentials.Open(getConfigDir())
+}
+
+// CredentialAccount names the stored key of provider p. It is the variable
+// the key would otherwise come from, so providers sharing a variable share
+// the key.
+func CredentialAccount(p Provider) string {
+	return p.KeyEnv
+}
+
+// storedKey returns the key of provider p saved in the credential stores and
+// the store it came from. Stores that cannot be read count as holding none;
+// "codex auth status" reports why.
+func storedKey(p Provider) (string, string) {
+	if p.NoKey || p.KeyEnv == "" {
+		return "", ""
+	}
+	key, store, err := OpenCredentials().Get(CredentialAccount(p))
+	if err != nil {
+		return "", ""
+	}
+	return key, store.Name()
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Kinds of tool messages that can be customized
+const (
+	MessageDenied  = "denied"  // The user or the approval policy denied a tool call
+	MessageTimeout = "timeout" // A shell command ran out of time
+	MessageRefused = "refused" // The sandbox refused to run a shell command, or it could not start
+)
+
+// MessagesConfig replaces the texts sent back to the model when a tool call
+// is denied, times out or is refused. Each is a text/template over
+// MessageData, such as "{{.Tool}} was declined; ask me before retrying.";
+// an empty one keeps the default text.
+type MessagesConfig struct {
+	Denied  string `mapstructure:"denied"`
+	Timeout string `mapstructure:"timeout"`
+	Refused string `mapstructure:"refused"`
+}
+
+// MessageData holds the variables of a message template. Fields that do not
+// apply to a kind of message are empty.
+type MessageData struct {
+	Default  string // The text that would be sent without a template
+	Tool     string // The function called, such as shell or patch_file
+	Mode     string // The approval mode
+	Note     string // The note the user gave with a denial
+	Command  string // The shell command
+	Duration string // How long the command ran before it was stopped
+	Output   string // The command's output so far
+	Reason   string // Why the command was refused
+}
+
+// template returns the template text of a kind of message
+func (m MessagesConfig) template(kind string) string {
+	switch kind {
+	case MessageDenied:
+		return m.Denied
+	case MessageTimeout:
+		return m.Timeout
+	case MessageRefused:
+		return m.Refused
+	}
+	return ""
+}
+
+// Validate checks that the templates parse and only use MessageData's fields
+func (m MessagesConfig) Validate() error {
+	for _, kind := range []string{MessageDenied, MessageTimeout, MessageRefused} {
+		text := m.template(kind)
+		if text == "" {
+			continue
+		}
+		tmpl, err := template.New(kind).Parse(text)
+		if err == nil {
+			err = tmpl.Execute(io.Discard, MessageData{})
+		}
+		if err != nil {
+			return fmt.Errorf("messages.%s: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// Render returns the message of the given kind, or data.Default if there is
+// no template for it or it fails to render
+func (m MessagesConfig) Render(kind string, data MessageData) string {
+	text := m.template(kind)
+	if text == "" {
+		return data.Default
+	}
+	tmpl, err := template.New(kind).Parse(text)
+	if err != nil {
+		return data.Default
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return data.Default
+	}
+	return b.String()
+}
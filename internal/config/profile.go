@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// providerBaseURLs are the base URLs of the providers a profile can name
+// with the provider key instead of giving base_url
+var providerBaseURLs = map[string]string{
+	"openai":  DefaultBaseURL,
+	"gemini":  "https://generativelanguage.googleapis.com/v1beta/openai",
+	"mistral": "https://api.mistral.ai/v1",
+	"ollama":  "http://localhost:11434/v1",
+}
+
+// applyProfile merges the settings of the named profile in the profiles map
+// of v over the rest of the config. A profile's provider key stands for the
+// provider's base URL. It reports whether the profile changes the base URL
+// without giving a key, in which case the key of the other provider must not
+// be reused.
+func applyProfile(v *viper.Viper, name string) (needsKey bool, err error) {
+	profiles := v.GetStringMap("profiles")
+	raw, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return false, fmt.Errorf("unknown profile %q: no profiles are defined in the config", name)
+		}
+		return false, fmt.Errorf("unknown profile %q; the profiles are %s", name, strings.Join(names, ", "))
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("invalid profile %q: it must be a map of settings", name)
+	}
+	settings = copyMap(settings)
+
+	if provider, ok := settings["provider"]; ok {
+		delete(settings, "provider")
+		baseURL, known := providerBaseURLs[strings.ToLower(fmt.Sprint(provider))]
+		if !known {
+			return false, fmt.Errorf("invalid profile %q: unknown provider %q (use one of openai, gemini, mistral, ollama, or set base_url)", name, provider)
+		}
+		if _, ok := settings["base_url"]; !ok {
+			settings["base_url"] = baseURL
+		}
+	}
+	_, setsURL := settings["base_url"]
+	_, setsKey := settings["api_key"]
+
+	if err := v.MergeConfigMap(settings); err != nil {
+		return false, fmt.Errorf("error applying profile %q: %w", name, err)
+	}
+	return setsURL && !setsKey, nil
+}
+
+// copyMap returns a shallow copy of m, so that v's own profile is not changed
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const profilesConfig = `model: gpt-4o
+api_key: sk-global
+profiles:
+  work:
+    model: gpt-4.1
+    explore_calls: 20
+  local:
+    provider: ollama
+    model: qwen2.5-coder
+  mistral:
+    base_url: https://api.mistral.ai/v1
+    model: codestral-latest
+`
+
+func TestLoadProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("MISTRAL_API_KEY", "mistral-key")
+	writeFile(t, filepath.Join(home, DefaultConfigDir, "config.yaml"), profilesConfig)
+	chdir(t, t.TempDir())
+
+	cfg, err := LoadProfile("")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if cfg.Profile != "" || cfg.Model != "gpt-4o" {
+		t.Errorf("Expected no profile, got %q with model %s", cfg.Profile, cfg.Model)
+	}
+
+	cfg, err = LoadProfile("work")
+	if err != nil {
+		t.Fatalf("LoadProfile(work) failed: %v", err)
+	}
+	if cfg.Profile != "work" || cfg.Model != "gpt-4.1" || cfg.ExploreCalls != 20 || cfg.APIKey != "sk-global" || cfg.BaseURL != DefaultBaseURL {
+		t.Errorf("Unexpected work profile: profile=%q model=%s explore_calls=%d key=%q base_url=%s", cfg.Profile, cfg.Model, cfg.ExploreCalls, cfg.APIKey, cfg.BaseURL)
+	}
+
+	cfg, err = LoadProfile("local")
+	if err != nil {
+		t.Fatalf("LoadProfile(local) failed: %v", err)
+	}
+	if cfg.BaseURL != "http://localhost:11434/v1" || cfg.Model != "qwen2.5-coder" || cfg.APIKey != "" {
+		t.Errorf("Unexpected local profile: base_url=%s model=%s key=%q", cfg.BaseURL, cfg.Model, cfg.APIKey)
+	}
+
+	// The global key is not sent to another provider
+	cfg, err = LoadProfile("mistral")
+	if err != nil {
+		t.Fatalf("LoadProfile(mistral) failed: %v", err)
+	}
+	if cfg.APIKey != "mistral-key" {
+		t.Errorf("Expected the Mistral key, got %q", cfg.APIKey)
+	}
+
+	if _, err := LoadProfile("nope"); err == nil || !strings.Contains(err.Error(), "local, mistral, work") {
+		t.Errorf("Expected an error listing the profiles, got %v", err)
+	}
+}
+
+func TestLoadProfileSelectedByConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile(t, filepath.Join(home, DefaultConfigDir, "config.yaml"), "profile: local\n"+profilesConfig)
+	chdir(t, t.TempDir())
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Profile != "local" || cfg.Model != "qwen2.5-coder" {
+		t.Errorf("Expected the local profile, got %q with model %s", cfg.Profile, cfg.Model)
+	}
+}
+
+func TestLoadProfileUnknownProvider(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile(t, filepath.Join(home, DefaultConfigDir, "config.yaml"), "profiles:\n  x:\n    provider: nowhere\n")
+	chdir(t, t.TempDir())
+
+	if _, err := LoadProfile("x"); err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Errorf("Expected an unknown provider error, got %v", err)
+	}
+}
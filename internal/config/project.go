@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ProjectConfigNames are the per-repository config files, in order of
+// preference within a directory
+var ProjectConfigNames = []string{".codex.yaml", ".codex.yml", ".codex.toml", "codex.toml"}
+
+// globalOnlyKeys are settings a project config may not change: they hold
+// credentials, choose where conversations, keys and traces are sent, widen
+// what the agent may reach, or tell the model what to do after a denial. A
+// repository is not necessarily trusted.
+var globalOnlyKeys = []string{
+	"api_key", "base_url", "embedding_base_url", "embedding_api_key", "webhook_url", "proxy",
+	"allow_network_tools", "allowed_domains", "log_file", "cwd", "profile", "profiles", "messages",
+	"telemetry", "allowed_roots", "redact_secrets", "redact_patterns",
+}
+
+// approvalStrictness orders approval modes from the most to the least careful
+var approvalStrictness = map[ApprovalMode]int{Suggest: 0, AutoEdit: 1, FullAuto: 2, DangerousAutoApprove: 3}
+
+// SandboxConfig configures the sandbox shell commands run in
+type SandboxConfig struct {
+	AllowNetwork bool     `mapstructure:"allow_network"` // Let commands reach the network where the sandbox can restrict it
+	Env          []string `mapstructure:"env"`           // Extra environment variables for commands, as NAME=value
+	Shell        string   `mapstructure:"shell"`         // Shell commands run with; the platform's default if empty
+}
+
+// EnvMap returns Env as a map. Entries without "=" are skipped.
+func (s SandboxConfig) EnvMap() map[string]string {
+	if len(s.Env) == 0 {
+		return nil
+	}
+	env := make(map[string]string, len(s.Env))
+	for _, entry := range s.Env {
+		if name, value, ok := strings.Cut(entry, "="); ok && name != "" {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// ToolConfig is a custom tool: a named shell command the agent may run
+// through run_project_script, like the project's Makefile targets
+type ToolConfig struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	Command     string `mapstructure:"command"`
+}
+
+// FindProjectConfig returns the nearest project config file in dir or its
+// parents, or "" if there is none
+func FindProjectConfig(dir string) string {
+	for {
+		for _, name := range ProjectConfigNames {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+				return candidate
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// mergeProjectConfig reads the project config file at path into v, over the
+// global settings. Global-only keys are dropped and the approval mode may
+// only become stricter; each such setting is reported in the warnings.
+func mergeProjectConfig(v *viper.Viper, path string) ([]string, error) {
+	pv := viper.New()
+	pv.SetConfigFile(path)
+	if err := pv.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading project config %s: %w", path, err)
+	}
+	settings := pv.AllSettings()
+
+	var warnings []string
+	for _, key := range globalOnlyKeys {
+		if _, ok := settings[key]; ok {
+			delete(settings, key)
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %s, which can only be set in the global config", path, key))
+		}
+	}
+	if sb, ok := settings["sandbox"].(map[string]interface{}); ok {
+		if _, ok := sb["allow_network"]; ok {
+			delete(sb, "allow_network")
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring sandbox.allow_network, which can only be set in the global config", path))
+		}
+	}
+	if mode, ok := settings["approval_mode"]; ok {
+		global := ApprovalMode(v.GetString("approval_mode"))
+		if global == "" {
+			global = Suggest
+		}
+		project := ApprovalMode(fmt.Sprint(mode))
+		strictness, known := approvalStrictness[project]
+		if !known || strictness > approvalStrictness[global] {
+			delete(settings, "approval_mode")
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring approval_mode %q, which is less strict than %q", path, project, global))
+		}
+	}
+
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, fmt.Errorf("error merging project config %s: %w", path, err)
+	}
+	return warnings, nil
+}
+
+// Ignored reports whether the slash-separated path rel, relative to the
+// repository root, matches one of the ignore patterns. Patterns without a
+// slash match any file or directory name, like "*.min.js" or "testdata";
+// patterns with one match from the root, like "docs/generated" or "/vendor".
+// A trailing slash is allowed. Everything
+// under a matching directory is ignored.
+func (c *Config) Ignored(rel string) bool {
+	if len(c.Ignore) == 0 {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for _, pattern := range c.Ignore {
+		pattern = strings.TrimSuffix(pattern, "/")
+		anchored := strings.Contains(pattern, "/")
+		if pattern = strings.TrimPrefix(pattern, "/"); pattern == "" {
+			continue
+		}
+		for i, part := range parts {
+			name := part
+			if anchored {
+				name = strings.Join(parts[:i+1], "/")
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
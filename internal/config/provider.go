@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Provider describes the API service the base URL points to and where its
+// credentials come from
+type Provider struct {
+	Name      string // Display name, e.g. "OpenAI"
+	KeyEnv    string // Environment variable holding the API key
+	KeyPrefix string // Prefix every valid key starts with, if any
+	KeyURL    string // Where to create a key
+	NoKey     bool   // The service runs locally and needs no key
+}
+
+var (
+	providerOpenAI  = Provider{Name: "OpenAI", KeyEnv: "OPENAI_API_KEY", KeyPrefix: "sk-", KeyURL: "https://platform.openai.com/api-keys"}
+	providerGemini  = Provider{Name: "Google Gemini", KeyEnv: "GEMINI_API_KEY", KeyURL: "https://aistudio.google.com/app/apikey"}
+	providerMistral = Provider{Name: "Mistral", KeyEnv: "MISTRAL_API_KEY", KeyURL: "https://console.mistral.ai/api-keys"}
+	providerOllama  = Provider{Name: "Ollama", NoKey: true}
+	providerCustom  = Provider{Name: "OpenAI-compatible API", KeyEnv: "OPENAI_API_KEY"}
+)
+
+// ProviderFor returns the provider serving baseURL. Unknown hosts are treated
+// as OpenAI-compatible services keyed by OPENAI_API_KEY.
+func ProviderFor(baseURL string) Provider {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return providerCustom
+	}
+	host := u.Hostname()
+	switch {
+	case host == "api.openai.com":
+		return providerOpenAI
+	case host == "generativelanguage.googleapis.com":
+		return providerGemini
+	case host == "api.mistral.ai":
+		return providerMistral
+	case u.Port() == "11434" || strings.Contains(host, "ollama"):
+		return providerOllama
+	}
+	return providerCustom
+}
+
+// Provider returns the provider serving the configured base URL
+func (c *Config) Provider() Provider {
+	return ProviderFor(c.BaseURL)
+}
+
+// Models returns the model followed by its fallbacks, without duplicates
+func (c *Config) Models() []string {
+	models := []string{c.Model}
+	for _, model := range c.ModelFallbacks {
+		if model != "" && !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// CredentialsError reports a missing or malformed API key, with guidance on
+// setting one up for the provider
+type CredentialsError struct {
+	Provider Provider
+	Problem  string // What is wrong with the key
+}
+
+func (e *CredentialsError) Error() string {
+	p := e.Provider
+	var b strings.Builder
+	fmt.Fprintf(&b, "missing or invalid %s credentials: %s\n\nTo fix it, either:\n", p.Name, e.Problem)
+	b.WriteString("  - run codex auth login to save the key in the OS keychain\n")
+	fmt.Fprintf(&b, "  - export %s=<your key>\n", p.KeyEnv)
+	fmt.Fprintf(&b, "  - set api_key in ~/%s/config.yaml (codex --config opens it)\n", DefaultConfigDir)
+	if p.KeyURL != "" {
+		fmt.Fprintf(&b, "\nCreate a key at %s", p.KeyURL)
+	} else {
+		b.WriteString("\nUse the key issued by the service at your base_url")
+	}
+	return b.String()
+}
+
+// CheckCredentials reports whether the configured API key can be used with
+// the provider, before anything is sent to it. Local providers need no key.
+func (c *Config) CheckCredentials() error {
+	p := c.Provider()
+	if p.NoKey {
+		return nil
+	}
+
+	key := c.APIKey
+	switch {
+	case key == "":
+		return &CredentialsError{Provider: p, Problem: "no API key is configured"}
+	case strings.TrimSpace(key) != key || strings.ContainsAny(key, " \t\r\n"):
+		return &CredentialsError{Provider: p, Problem: "the API key contains whitespace"}
+	case strings.ContainsAny(key, `"'`):
+		return &CredentialsError{Provider: p, Problem: "the API key contains quotes"}
+	case p.KeyPrefix != "" && !strings.HasPrefix(key, p.KeyPrefix):
+		return &CredentialsError{Provider: p, Problem: fmt.Sprintf("the API key should start with %q", p.KeyPrefix)}
+	}
+	return nil
+}
+
+// EmbeddingEndpoint returns the base URL and API key of the embeddings API:
+// embedding_base_url and embedding_api_key, falling back to the chat API.
+// When embeddings come from another provider, the chat API key is not sent to
+// it; the key is taken from that provider's environment variable instead.
+func (c *Config) EmbeddingEndpoint() (baseURL, apiKey string) {
+	if c.EmbeddingBaseURL == "" {
+		if c.EmbeddingAPIKey != "" {
+			return c.BaseURL, c.EmbeddingAPIKey
+		}
+		return c.BaseURL, c.APIKey
+	}
+	if c.EmbeddingAPIKey != "" {
+		return c.EmbeddingBaseURL, c.EmbeddingAPIKey
+	}
+	p := ProviderFor(c.EmbeddingBaseURL)
+	switch {
+	case p.NoKey:
+		return c.EmbeddingBaseURL, ""
+	case p == c.Provider():
+		return c.EmbeddingBaseURL, c.APIKey
+	default:
+		return c.EmbeddingBaseURL, os.Getenv(p.KeyEnv)
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProviderFor(t *testing.T) {
+	tests := map[string]string{
+		DefaultBaseURL: "OpenAI",
+		"https://generativelanguage.googleapis.com/v1beta/openai": "Google Gemini",
+		"https://api.mistral.ai/v1":                               "Mistral",
+		"http://localhost:11434/v1":                               "Ollama",
+		"https://llm.internal.example/v1":                         "OpenAI-compatible API",
+	}
+	for baseURL, want := range tests {
+		if got := ProviderFor(baseURL).Name; got != want {
+			t.Errorf("ProviderFor(%q) = %s, want %s", baseURL, got, want)
+		}
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		baseURL, key string
+		problem      string // Expected in the error, or empty if the key is usable
+	}{
+		{DefaultBaseURL, "sk-proj-abc", ""},
+		{DefaultBaseURL, "", "no API key"},
+		{DefaultBaseURL, "sk-abc\n", "whitespace"},
+		{DefaultBaseURL, `"sk-abc"`, "quotes"},
+		{DefaultBaseURL, "abc", `start with "sk-"`},
+		{"https://api.mistral.ai/v1", "abc", ""},
+		{"https://api.mistral.ai/v1", "", "MISTRAL_API_KEY"},
+		{"http://localhost:11434/v1", "", ""},
+	}
+	for _, tt := range tests {
+		cfg := &Config{BaseURL: tt.baseURL, APIKey: tt.key}
+		err := cfg.CheckCredentials()
+		if tt.problem == "" {
+			if err != nil {
+				t.Errorf("CheckCredentials(%q, %q) = %v, want nil", tt.baseURL, tt.key, err)
+			}
+			continue
+		}
+		var credErr *CredentialsError
+		if !errors.As(err, &credErr) || !strings.Contains(err.Error(), tt.problem) {
+			t.Errorf("CheckCredentials(%q, %q) = %v, want a CredentialsError mentioning %q", tt.baseURL, tt.key, err, tt.problem)
+		}
+	}
+}
+
+func TestEmbeddingEndpoint(t *testing.T) {
+	t.Setenv("MISTRAL_API_KEY", "mistral-key")
+	tests := []struct {
+		name                    string
+		cfg                     Config
+		wantBaseURL, wantAPIKey string
+	}{
+		{"chat API", Config{BaseURL: DefaultBaseURL, APIKey: "sk-chat"}, DefaultBaseURL, "sk-chat"},
+		{"own key", Config{BaseURL: DefaultBaseURL, APIKey: "sk-chat", EmbeddingAPIKey: "sk-embed"}, DefaultBaseURL, "sk-embed"},
+		{"local", Config{BaseURL: DefaultBaseURL, APIKey: "sk-chat", EmbeddingBaseURL: "http://localhost:11434/v1"}, "http://localhost:11434/v1", ""},
+		{"other provider", Config{BaseURL: DefaultBaseURL, APIKey: "sk-chat", EmbeddingBaseURL: "https://api.mistral.ai/v1"}, "https://api.mistral.ai/v1", "mistral-key"},
+		{"same provider", Config{BaseURL: "https://api.mistral.ai/v1", APIKey: "chat-key", EmbeddingBaseURL: "https://api.mistral.ai/v1"}, "https://api.mistral.ai/v1", "chat-key"},
+	}
+	for _, tt := range tests {
+		baseURL, apiKey := tt.cfg.EmbeddingEndpoint()
+		if baseURL != tt.wantBaseURL || apiKey != tt.wantAPIKey {
+			t.Errorf("%s: EmbeddingEndpoint() = %q, %q; want %q, %q", tt.name, baseURL, apiKey, tt.wantBaseURL, tt.wantAPIKey)
+		}
+	}
+}
+
+func TestModels(t *testing.T) {
+	cfg := &Config{Model: "gpt-4o", ModelFallbacks: []string{"gpt-4o-mini", "gpt-4o", "", "o3-mini"}}
+	got := cfg.Models()
+	want := []string{"gpt-4o", "gpt-4o-mini", "o3-mini"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Models() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// TelemetryConfig exports OpenTelemetry traces of agent requests, tool
+// calls, sandboxed commands and patches over OTLP/HTTP
+type TelemetryConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`       // Export traces; implied by otlp_endpoint
+	Endpoint    string            `mapstructure:"otlp_endpoint"` // Such as http://localhost:4318; OTEL_EXPORTER_OTLP_ENDPOINT otherwise
+	Headers     map[string]string `mapstructure:"headers"`       // Sent with each export, such as an API key of the collector
+	ServiceName string            `mapstructure:"service_name"`  // Defaults to codex-go
+}
+
+// On reports whether traces are exported
+func (t TelemetryConfig) On() bool {
+	return t.Enabled || t.Endpoint != ""
+}
+
+// Validate checks that the endpoint is an http or https URL
+func (t TelemetryConfig) Validate() error {
+	if t.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(t.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("telemetry.otlp_endpoint: %q is not an http or https URL", t.Endpoint)
+	}
+	return nil
+}
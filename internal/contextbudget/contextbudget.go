@@ -0,0 +1,170 @@
+// Package contextbudget shares the token budget of a request among the
+// sources of its context: the system prompt, the repository map, the project
+// docs, the project memory, the files retrieved for the request and the
+// conversation history. Each source is allotted a share of the budget by
+// weight; a source needing less than its share leaves the rest to the
+// others. Sources are then cut to their allotment, always the same way for
+// the same input.
+package contextbudget
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/epuerta/codex-go/internal/repomap"
+)
+
+// Source is where a part of the context comes from
+type Source string
+
+const (
+	SystemPrompt   Source = "system_prompt"
+	RepoMap        Source = "repo_map"
+	ProjectDocs    Source = "project_docs"
+	Memory         Source = "memory"
+	RetrievedFiles Source = "retrieved_files"
+	History        Source = "history"
+)
+
+// Sources lists the sources in the order leftover tokens are handed out
+var Sources = []Source{SystemPrompt, ProjectDocs, Memory, RepoMap, RetrievedFiles, History}
+
+// Weights are the relative shares of the sources in the budget. A source
+// without weight is left out of the context.
+type Weights map[Source]float64
+
+// DefaultWeights returns the shares used unless the config sets others
+func DefaultWeights() Weights {
+	return Weights{
+		SystemPrompt:   10,
+		ProjectDocs:    10,
+		Memory:         5,
+		RepoMap:        10,
+		RetrievedFiles: 20,
+		History:        45,
+	}
+}
+
+// ParseWeights reads weights keyed by source name, as in the config. Names
+// missing from raw keep their default weight.
+func ParseWeights(raw map[string]float64) (Weights, error) {
+	weights := DefaultWeights()
+	for name, weight := range raw {
+		source := Source(name)
+		if _, ok := weights[source]; !ok {
+			return nil, fmt.Errorf("unknown context source %q (valid: %s)", name, sourceNames())
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("weight of context source %s must be non-negative, got %g", name, weight)
+		}
+		weights[source] = weight
+	}
+	return weights, nil
+}
+
+// sourceNames lists the names of the sources for error messages
+func sourceNames() string {
+	names := make([]string, len(Sources))
+	for i, s := range Sources {
+		names[i] = string(s)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Allocate divides total tokens among the sources of demand, which maps each
+// source to the tokens it would use uncut. A source gets its demand if it
+// fits in its share; the tokens it leaves are shared again among the others
+// by weight. Tokens no source can use, because all their demand is met or
+// all the sources left have no weight, stay unallocated.
+func Allocate(total int, weights Weights, demand map[Source]int) map[Source]int {
+	alloc := make(map[Source]int, len(demand))
+	var open []Source // Sources whose demand is not met yet
+	for _, s := range Sources {
+		if demand[s] > 0 {
+			open = append(open, s)
+		}
+	}
+
+	left := total
+	for left > 0 && len(open) > 0 {
+		weight := 0.0
+		for _, s := range open {
+			weight += weights[s]
+		}
+		if weight <= 0 {
+			break
+		}
+
+		// Sources whose demand fits in their share are settled first, so
+		// that what they leave is shared again among the others
+		var settled bool
+		var rest []Source
+		budget := float64(left)
+		for _, s := range open {
+			if need := demand[s] - alloc[s]; float64(need) <= budget*weights[s]/weight {
+				alloc[s] += need
+				left -= need
+				settled = true
+			} else {
+				rest = append(rest, s)
+			}
+		}
+		if settled {
+			open = rest
+			continue
+		}
+
+		// All want more than their share: each gets its share rounded
+		// down, and the tokens lost to rounding go to the sources in order
+		given := 0
+		for _, s := range open {
+			if share := int(float64(left) * weights[s] / weight); share > 0 {
+				alloc[s] += share
+				given += share
+			}
+		}
+		for _, s := range open {
+			if given == left {
+				break
+			}
+			if weights[s] > 0 {
+				alloc[s]++
+				given++
+			}
+		}
+		break
+	}
+	return alloc
+}
+
+// Tokens estimates the size of text in tokens
+func Tokens(text string) int {
+	return repomap.EstimateTokens(text)
+}
+
+// cutMarker ends text that was cut to fit its allotment
+const cutMarker = "\n[... cut to fit the context budget]"
+
+// Truncate cuts text to about tokens tokens, keeping its beginning up to the
+// last whole line that fits and noting the cut. It returns "" if not even
+// the note fits, and text as it is if it fits whole.
+func Truncate(text string, tokens int) string {
+	if Tokens(text) <= tokens {
+		return text
+	}
+	room := tokens*4 - len(cutMarker) // EstimateTokens counts 4 bytes a token
+	if room <= 0 {
+		return ""
+	}
+	for room > 0 && !utf8.RuneStart(text[room]) {
+		room--
+	}
+	kept := text[:room]
+	if i := strings.LastIndexByte(kept, '\n'); i > 0 {
+		kept = kept[:i]
+	}
+	return kept + cutMarker
+}
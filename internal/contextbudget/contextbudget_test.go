@@ -0,0 +1,68 @@
+package contextbudget
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	weights := Weights{SystemPrompt: 10, ProjectDocs: 10, History: 80}
+
+	// Everything fits
+	demand := map[Source]int{SystemPrompt: 100, ProjectDocs: 200, History: 300}
+	if got := Allocate(1000, weights, demand); !reflect.DeepEqual(got, demand) {
+		t.Errorf("Allocate() = %v, want the demand", got)
+	}
+
+	// The system prompt needs less than its share; the docs and the
+	// history share the rest by weight
+	demand = map[Source]int{SystemPrompt: 50, ProjectDocs: 5000, History: 5000}
+	want := map[Source]int{SystemPrompt: 50, ProjectDocs: 106, History: 844}
+	if got := Allocate(1000, weights, demand); !reflect.DeepEqual(got, want) {
+		t.Errorf("Allocate() = %v, want %v", got, want)
+	}
+
+	// Sources without weight get nothing
+	demand = map[Source]int{RepoMap: 500, History: 5000}
+	want = map[Source]int{History: 1000}
+	if got := Allocate(1000, weights, demand); !reflect.DeepEqual(got, want) {
+		t.Errorf("Allocate() = %v, want %v", got, want)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights(map[string]float64{"history": 60, "repo_map": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights[History] != 60 || weights[RepoMap] != 0 || weights[SystemPrompt] != DefaultWeights()[SystemPrompt] {
+		t.Errorf("Unexpected weights: %v", weights)
+	}
+	if _, err := ParseWeights(map[string]float64{"readme": 1}); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+	if _, err := ParseWeights(map[string]float64{"memory": -1}); err == nil {
+		t.Error("Expected an error for a negative weight")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	text := strings.Repeat("line of text\n", 20) // 260 bytes, 65 tokens
+	if got := Truncate(text, 65); got != text {
+		t.Errorf("Expected text that fits to be kept whole")
+	}
+	got := Truncate(text, 30)
+	if !strings.HasSuffix(got, cutMarker) || Tokens(got) > 30 || !strings.HasPrefix(text, strings.TrimSuffix(got, cutMarker)+"\n") {
+		t.Errorf("Expected the first whole lines and the note, got %q", got)
+	}
+	if got != Truncate(text, 30) {
+		t.Error("Expected the same cut every time")
+	}
+	if got := Truncate(text, 5); got != "" {
+		t.Errorf("Expected nothing when the note does not fit, got %q", got)
+	}
+	if got := Truncate(strings.Repeat("é", 100), 20); !strings.HasSuffix(got, cutMarker) || !strings.HasPrefix(got, "éé") || strings.ContainsRune(got, '�') {
+		t.Errorf("Expected a cut between characters, got %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+// Package credentials stores API keys outside the config files: in the
+// platform keychain (the macOS keychain, the Secret Service on Linux, the
+// Windows Credential Manager), or in an encrypted file when there is none.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Service names the keys of this program in the keychain
+const Service = "codex-go"
+
+// ErrNotFound is returned when no secret is stored for an account
+var ErrNotFound = errors.New("credential not found")
+
+// Store keeps secrets by account, such as the name of the environment
+// variable an API key would otherwise come from
+type Store interface {
+	// Name describes where secrets are kept, like "macOS keychain"
+	Name() string
+	// Get returns the secret of account, or ErrNotFound
+	Get(account string) (string, error)
+	// Set stores the secret of account, replacing any previous one
+	Set(account, secret string) error
+	// Delete removes the secret of account. Deleting a missing secret is not
+	// an error.
+	Delete(account string) error
+}
+
+// Chain is a list of stores tried in order
+type Chain []Store
+
+// Open returns the keychain of the platform, if it has one, followed by the
+// encrypted file in dir
+func Open(dir string) Chain {
+	var chain Chain
+	if k := keychain(); k != nil {
+		chain = append(chain, k)
+	}
+	return append(chain, NewFile(dir))
+}
+
+// Get returns the secret of account from the first store holding it, and
+// that store. It returns ErrNotFound if no store holds it, or the first error
+// if no store could be read.
+func (c Chain) Get(account string) (string, Store, error) {
+	var firstErr error
+	for _, s := range c {
+		secret, err := s.Get(account)
+		if err == nil {
+			return secret, s, nil
+		}
+		if !errors.Is(err, ErrNotFound) && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", s.Name(), err)
+		}
+	}
+	if firstErr != nil {
+		return "", nil, firstErr
+	}
+	return "", nil, ErrNotFound
+}
+
+// Set stores the secret of account in the first store accepting it and
+// returns that store. The secret is removed from the later stores, so an old
+// copy cannot shadow or outlive it.
+func (c Chain) Set(account, secret string) (Store, error) {
+	var errs []error
+	for i, s := range c {
+		if err := s.Set(account, secret); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		for _, later := range c[i+1:] {
+			later.Delete(account)
+		}
+		return s, nil
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no credential store is available")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Delete removes the secret of account from every store
+func (c Chain) Delete(account string) error {
+	var errs []error
+	for _, s := range c {
+		if err := s.Delete(account); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	f := NewFile(dir)
+	if _, err := f.Get("OPENAI_API_KEY"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound from an empty store, got %v", err)
+	}
+	if err := f.Set("OPENAI_API_KEY", "sk-test-1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("MISTRAL_API_KEY", "mistral-key"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new store reads what the first wrote
+	if got, err := NewFile(dir).Get("OPENAI_API_KEY"); err != nil || got != "sk-test-1234" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("sk-test-1234")) {
+		t.Error("Expected the secret to be encrypted")
+	}
+	for _, name := range []string{fileName, keyFileName} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to be readable by the user only, got %v (%v)", name, info.Mode().Perm(), err)
+		}
+	}
+
+	if err := f.Delete("OPENAI_API_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get("OPENAI_API_KEY"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the deleted key to be gone, got %v", err)
+	}
+	if got, _ := f.Get("MISTRAL_API_KEY"); got != "mistral-key" {
+		t.Errorf("Expected the other key to be kept, got %q", got)
+	}
+
+	// Another key cannot decrypt the file
+	if err := os.WriteFile(filepath.Join(dir, keyFileName), bytes.Repeat([]byte{1}, 32), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get("MISTRAL_API_KEY"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a decryption error, got %v", err)
+	}
+}
+
+// brokenStore fails like a keychain without a running daemon
+type brokenStore struct{}
+
+func (brokenStore) Name() string               { return "broken" }
+func (brokenStore) Get(string) (string, error) { return "", errors.New("no daemon") }
+func (brokenStore) Set(string, string) error   { return errors.New("no daemon") }
+func (brokenStore) Delete(string) error        { return errors.New("no daemon") }
+
+func TestChain(t *testing.T) {
+	first, second := NewFile(t.TempDir()), NewFile(t.TempDir())
+	chain := Chain{first, second}
+
+	if err := second.Set("OPENAI_API_KEY", "sk-old"); err != nil {
+		t.Fatal(err)
+	}
+	if got, from, err := chain.Get("OPENAI_API_KEY"); err != nil || got != "sk-old" || from != second {
+		t.Errorf("Get = %q from %v, %v", got, from, err)
+	}
+	// Storing in the first store removes the copy of the later one
+	if from, err := chain.Set("OPENAI_API_KEY", "sk-new"); err != nil || from != first {
+		t.Fatalf("Set stored in %v, %v", from, err)
+	}
+	if _, err := second.Get("OPENAI_API_KEY"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the old copy to be removed, got %v", err)
+	}
+
+	// A failing keychain falls back to the next store
+	fallback := Chain{brokenStore{}, second}
+	if from, err := fallback.Set("GEMINI_API_KEY", "gemini-key"); err != nil || from != second {
+		t.Errorf("Expected the fallback to store the key, got %v, %v", from, err)
+	}
+	if got, _, err := fallback.Get("GEMINI_API_KEY"); err != nil || got != "gemini-key" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if _, _, err := fallback.Get("MISSING"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the keychain error when no store has the key, got %v", err)
+	}
+	if _, _, err := chain.Get("MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := chain.Delete("OPENAI_API_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := chain.Get("OPENAI_API_KEY"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the key to be deleted, got %v", err)
+	}
+}
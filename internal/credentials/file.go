@@ -0,0 +1,179 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	fileName    = "credentials.enc" // Encrypted secrets
+	keyFileName = "credentials.key" // Random key encrypting them
+)
+
+// File keeps secrets in a file encrypted with AES-GCM, under a random key
+// kept in another file. Both are readable by the user only. This keeps keys
+// out of config files, backups of them and screen shares; it does not stop
+// someone who can read the user's home directory, which is what the
+// keychain is for.
+type File struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFile returns the encrypted file store in dir
+func NewFile(dir string) *File {
+	return &File{Dir: dir}
+}
+
+// Name implements Store
+func (f *File) Name() string {
+	return filepath.Join(f.Dir, fileName)
+}
+
+// Get implements Store
+func (f *File) Get(account string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// Set implements Store
+func (f *File) Set(account, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.read()
+	if err != nil {
+		return err
+	}
+	secrets[account] = secret
+	return f.write(secrets)
+}
+
+// Delete implements Store
+func (f *File) Delete(account string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[account]; !ok {
+		return nil
+	}
+	delete(secrets, account)
+	if len(secrets) == 0 {
+		if err := os.Remove(filepath.Join(f.Dir, fileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return f.write(secrets)
+}
+
+// read decrypts the secrets; a missing file holds none
+func (f *File) read() (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(f.Dir, fileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := f.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%s is corrupt", fileName)
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s (was %s replaced?): %w", fileName, keyFileName, err)
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", fileName, err)
+	}
+	return secrets, nil
+}
+
+// write encrypts the secrets, replacing the file atomically
+func (f *File) write(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := f.cipher(true)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.Dir, fileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(gcm.Seal(nonce, nonce, plain, nil)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(f.Dir, fileName))
+}
+
+// cipher returns the AES-GCM cipher of the key file, creating the key if
+// create is set and there is none
+func (f *File) cipher(create bool) (cipher.AEAD, error) {
+	path := filepath.Join(f.Dir, keyFileName)
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(f.Dir, 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, key, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", keyFileName, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s is corrupt", keyFileName)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,65 @@
+package credentials
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// macKeychain keeps secrets in the login keychain through security(1)
+type macKeychain struct{}
+
+// errItemNotFound is the exit status of security(1) for a missing item
+const errItemNotFound = 44
+
+// keychain returns the macOS keychain
+func keychain() Store {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil
+	}
+	return macKeychain{}
+}
+
+func (macKeychain) Name() string { return "macOS keychain" }
+
+func (macKeychain) Get(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", Service, "-a", account, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("security find-generic-password failed: %w", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (macKeychain) Set(account, secret string) error {
+	// The secret is passed hex-encoded on standard input, so it never shows
+	// in the process list
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", quote(Service), quote(account), hex.EncodeToString([]byte(secret))))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("security add-generic-password failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (macKeychain) Delete(account string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", Service, "-a", account).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("security delete-generic-password failed: %w", err)
+	}
+	return nil
+}
+
+// quote makes s a single argument of a security -i command line
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,69 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// secretService keeps secrets in the Secret Service (GNOME Keyring,
+// KWallet) through secret-tool(1), from libsecret
+type secretService struct{}
+
+// keychain returns the Secret Service when secret-tool is installed and a
+// session bus is running, as there is none on servers and in containers
+func keychain() Store {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+	return secretService{}
+}
+
+func (secretService) Name() string { return "Secret Service" }
+
+func (secretService) Get(account string) (string, error) {
+	var stderr strings.Builder
+	cmd := exec.Command("secret-tool", "lookup", "service", Service, "account", account)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	// A missing item exits with 1 and prints nothing
+	if errors.As(err, &exitErr) && len(out) == 0 && stderr.Len() == 0 {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("secret-tool lookup failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (secretService) Set(account, secret string) error {
+	// The secret is read from standard input, so it never shows in the
+	// process list
+	cmd := exec.Command("secret-tool", "store", "--label", Service+" "+account, "service", Service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool store failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (secretService) Delete(account string) error {
+	var stderr strings.Builder
+	cmd := exec.Command("secret-tool", "clear", "service", Service, "account", account)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && stderr.Len() == 0 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("secret-tool clear failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
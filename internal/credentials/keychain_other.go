@@ -0,0 +1,9 @@
+//go:build !darwin && !linux && !windows
+
+package credentials
+
+// keychain returns nil, as there is no keychain support on this platform;
+// secrets go to the encrypted file
+func keychain() Store {
+	return nil
+}
@@ -21,7 +21,7 @@ func RunUITest() {
 
 	// Add some test messages
 	chatModel.AddUserMessage("Hello, can you help me?")
-	chatModel.AddSystemMessage("System test message")
+	chatModel.AddNotice("System test message")
 	chatModel.AddAssistantMessage("I'm here to help! What can I do for you today?")
 
 	// Add a function call message
@@ -33,7 +33,7 @@ func RunUITest() {
 	chatModel.AddAssistantMessage("You're welcome! Let me know if you need anything else.")
 
 	// Create the program
-	p := tea.NewProgram(chatModel, tea.WithAltScreen())
+	p := tea.NewProgram(&chatModel, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,96 @@
+// Package draft keeps the unsent chat input of each repository, so that a
+// half-typed prompt survives quitting and is restored on the next launch.
+package draft
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DirName is the name of the drafts directory inside the codex config directory
+const DirName = "drafts"
+
+// Draft is the saved input of a repository
+type Draft struct {
+	Repo    string    `json:"repo"`
+	Text    string    `json:"text"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Store saves drafts as one JSON file per repository
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store backed by the given directory
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultDir returns the default drafts directory (~/.codex/drafts)
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".codex", DirName), nil
+}
+
+// path returns the file holding the draft of repo
+func (s *Store) path(repo string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(repo)))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Load returns the draft saved for repo, or nil if there is none
+func (s *Store) Load(repo string) (*Draft, error) {
+	data, err := os.ReadFile(s.path(repo))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read draft: %w", err)
+	}
+	var d Draft
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse draft: %w", err)
+	}
+	if d.Repo != filepath.Clean(repo) || d.Text == "" {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+// Save stores text as the draft of repo. Saving an empty text removes the
+// draft.
+func (s *Store) Save(repo, text string) error {
+	path := s.path(repo)
+	if text == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove draft: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %w", err)
+	}
+	data, err := json.Marshal(Draft{Repo: filepath.Clean(repo), Text: text, SavedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal draft: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write draft: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write draft: %w", err)
+	}
+	return nil
+}
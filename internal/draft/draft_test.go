@@ -0,0 +1,44 @@
+package draft
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), DirName))
+
+	if d, err := s.Load("/src/app"); d != nil || err != nil {
+		t.Fatalf("Expected no draft before saving, got %+v, %v", d, err)
+	}
+
+	text := "Refactor the parser so that\nerrors carry line numbers"
+	if err := s.Save("/src/app/", text); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	d, err := s.Load("/src/app")
+	if err != nil || d == nil || d.Text != text || d.SavedAt.IsZero() {
+		t.Fatalf("Expected the saved draft, got %+v, %v", d, err)
+	}
+	if d, _ := s.Load("/src/other"); d != nil {
+		t.Errorf("Expected drafts to be kept per repository, got %+v", d)
+	}
+
+	// Saving an empty input removes the draft
+	if err := s.Save("/src/app", ""); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if d, _ := s.Load("/src/app"); d != nil {
+		t.Errorf("Expected the draft to be removed, got %+v", d)
+	}
+	if err := s.Save("/src/app", ""); err != nil {
+		t.Errorf("Expected removing a missing draft to succeed, got %v", err)
+	}
+
+	// A corrupt file is reported
+	os.WriteFile(s.path("/src/app"), []byte("{"), 0600)
+	if _, err := s.Load("/src/app"); err == nil {
+		t.Error("Expected an error for a corrupt draft")
+	}
+}
@@ -0,0 +1,546 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/epuerta/codex-go/internal/redact"
+	"github.com/epuerta/codex-go/internal/telemetry"
+)
+
+// Approver decides whether a function call that requires approval may run.
+// Approve blocks until a decision is made or ctx is cancelled.
+type Approver interface {
+	Approve(ctx context.Context, call agent.FunctionCall) (bool, error)
+}
+
+// ApproverFunc adapts a function to the Approver interface
+type ApproverFunc func(ctx context.Context, call agent.FunctionCall) (bool, error)
+
+// Approve calls f(ctx, call)
+func (f ApproverFunc) Approve(ctx context.Context, call agent.FunctionCall) (bool, error) {
+	return f(ctx, call)
+}
+
+// Decision is a Reviewer's answer for a call
+type Decision struct {
+	Approved bool
+	Call     agent.FunctionCall // The call to run, which the reviewer may have changed
+	Note     string             // Added to what the agent is told, such as the hunks left out of a patch
+	// Refusal is set when the approver refused the call without asking,
+	// as a patch that cannot be applied. It stands for the call's result:
+	// its Output is what the agent is told.
+	Refusal *executor.Result
+}
+
+// Reviewer is implemented by approvers that can approve a changed call, such
+// as part of a patch. When the approver implements it, Review is asked
+// instead of Approve.
+type Reviewer interface {
+	Review(ctx context.Context, call agent.FunctionCall) (Decision, error)
+}
+
+// AllowList holds the calls the user chose to always allow. A call it
+// allows runs without asking the approver; rule describes what matched.
+type AllowList interface {
+	AllowsCall(call agent.FunctionCall) (rule string, ok bool)
+}
+
+// Notifier receives progress events from a run. Methods are called from the
+// goroutine executing Run and must not block for long.
+type Notifier interface {
+	// OnMessage receives the assistant message streamed so far in the current turn
+	OnMessage(content string)
+	// OnToolCall is called when the agent requests a function call, before approval
+	OnToolCall(call agent.FunctionCall)
+	// OnToolResult is called after a function call was executed
+	OnToolResult(call agent.FunctionCall, res *executor.Result)
+	// OnToolDenied is called when a function call was not approved
+	OnToolDenied(call agent.FunctionCall, reason string)
+}
+
+// ApprovalRecorder is implemented by notifiers that keep an audit trail.
+// OnApproval is called once per function call with the decision on whether it
+// may run, before it runs or is reported as denied.
+type ApprovalRecorder interface {
+	OnApproval(event agent.ApprovalEvent)
+}
+
+// ToolCallPreviewer is implemented by notifiers that show function calls
+// while the agent is still generating them. OnToolCallPreview receives the
+// arguments streamed so far, which are usually incomplete JSON; the call is
+// reported to OnToolCall once complete.
+type ToolCallPreviewer interface {
+	OnToolCallPreview(call agent.FunctionCall)
+}
+
+// ApprovalRequestObserver is implemented by notifiers and observers that
+// want to know when a run waits for the user to approve a call.
+// OnApprovalRequest is called just before the approver is asked.
+type ApprovalRequestObserver interface {
+	OnApprovalRequest(call agent.FunctionCall)
+}
+
+// MessageObserver is implemented by notifiers and observers that want each
+// assistant message once, when it is complete, rather than as it streams
+type MessageObserver interface {
+	OnMessageComplete(content string)
+}
+
+// BlockObserver is implemented by notifiers and observers that want to know
+// when a content filter stopped a response or the model refused to give one.
+// The run carries on as if the response had ended: any tool call it was
+// making is dropped.
+type BlockObserver interface {
+	OnBlocked(blocked agent.Blocked)
+}
+
+// RetryObserver is implemented by notifiers and observers that want to know
+// when a response stream broke off and is retried. The response starts
+// over, so the next OnMessage replaces what was streamed so far.
+type RetryObserver interface {
+	OnRetry(retry agent.StreamRetry)
+}
+
+// FallbackObserver is implemented by notifiers and observers that want to
+// know when a model failed and the next one of model_fallbacks answers
+// instead, for the rest of the turn
+type FallbackObserver interface {
+	OnModelFallback(fallback agent.ModelFallback)
+}
+
+// RedactionObserver is implemented by notifiers and observers that want to
+// know when secrets were replaced in the messages starting a run. Secrets
+// replaced in tool results are in the Redactions of the result instead.
+type RedactionObserver interface {
+	OnRedacted(findings []redact.Finding)
+}
+
+// NopNotifier ignores all events. Embed it to implement only some methods.
+type NopNotifier struct{}
+
+func (NopNotifier) OnMessage(string)                                  {}
+func (NopNotifier) OnToolCall(agent.FunctionCall)                     {}
+func (NopNotifier) OnToolResult(agent.FunctionCall, *executor.Result) {}
+func (NopNotifier) OnToolDenied(agent.FunctionCall, string)           {}
+
+// Outcome summarizes a completed run
+type Outcome struct {
+	FinalMessage string `json:"final_message"`
+	ToolCalls    int    `json:"tool_calls"`
+	ToolFailures int    `json:"tool_failures"` // Includes denied calls
+	Denied       int    `json:"denied"`
+
+	// Approvals records the decision on every function call of the run
+	Approvals []agent.ApprovalEvent `json:"approvals,omitempty"`
+
+	// Distillation describes the explore phase of RunExplore
+	Distillation *Distillation `json:"distillation,omitempty"`
+
+	// Blocked is set when the last blocked response of the run was stopped
+	// by a content filter or refused
+	Blocked *agent.Blocked `json:"blocked,omitempty"`
+
+	// Model is the model of model_fallbacks that served the end of the run,
+	// when the configured one failed
+	Model string `json:"model,omitempty"`
+}
+
+// InterruptedNote ends the assistant's response in the history when the run
+// was cancelled
+const InterruptedNote = "[Interrupted by the user]"
+
+// Engine drives the agent loop: it streams responses, asks for approval where
+// the approval mode requires it, executes tool calls and feeds the results back
+// until the agent stops requesting tools. It has no UI dependencies.
+type Engine struct {
+	Agent    agent.Agent
+	Executor *executor.Executor
+	Config   *config.Config
+	Logger   logging.Logger
+
+	// Observers receive the tool events of every run, along with the
+	// notifier of the run, and the events of the optional ApprovalRecorder,
+	// ApprovalRequestObserver and MessageObserver interfaces they implement.
+	// They are called synchronously and must not block.
+	Observers []Notifier
+
+	// AllowList, if set, is consulted before asking for approval
+	AllowList AllowList
+}
+
+// New creates an engine
+func New(ai agent.Agent, exec *executor.Executor, cfg *config.Config, logger logging.Logger) *Engine {
+	if logger == nil {
+		logger = logging.NewNilLogger()
+	}
+	return &Engine{Agent: ai, Executor: exec, Config: cfg, Logger: logger}
+}
+
+// Run sends prompt as a user message and runs the loop to completion.
+// A nil approver denies every call that requires approval; a nil notifier is allowed.
+func (e *Engine) Run(ctx context.Context, prompt string, approver Approver, notifier Notifier) (*Outcome, error) {
+	return e.RunMessages(ctx, []agent.Message{{Role: "user", Content: prompt}}, approver, notifier)
+}
+
+// RunMessages is like Run but sends arbitrary messages to start the turn
+func (e *Engine) RunMessages(ctx context.Context, messages []agent.Message, approver Approver, notifier Notifier) (*Outcome, error) {
+	if notifier == nil {
+		notifier = NopNotifier{}
+	}
+	return e.run(ctx, messages, approver, notifier, nil)
+}
+
+// run runs the loop for messages, limiting tool calls to an explore budget if one is given
+func (e *Engine) run(ctx context.Context, messages []agent.Message, approver Approver, notifier Notifier, explore *exploreBudget) (_ *Outcome, err error) {
+	r := &run{engine: e, approver: approver, notifier: notifier, outcome: &Outcome{}, explore: explore}
+	ctx, span := telemetry.Start(ctx, "engine.run", attribute.Int("codex.messages", len(messages)), attribute.Bool("codex.explore", explore != nil))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("codex.tool_calls", r.outcome.ToolCalls),
+			attribute.Int("codex.tool_failures", r.outcome.ToolFailures),
+			attribute.Int("codex.denied", r.outcome.Denied),
+		)
+		telemetry.End(span, err)
+	}()
+	defer func() {
+		if errors.Is(err, context.Canceled) {
+			r.markInterrupted()
+		}
+	}()
+
+	e.Logger.Log("Engine: starting run with %d message(s)", len(messages))
+	_, err = e.Agent.SendMessage(ctx, r.redact(messages), r.handleItem)
+	r.completeMessage()
+	if err != nil {
+		return r.outcome, err
+	}
+	r.streamed = "" // In the history now
+
+	// Tool calls issued by follow-up streams are queued by the handler as well
+	for len(r.pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return r.outcome, err
+		}
+
+		call := r.pending[0]
+		r.pending = r.pending[1:]
+
+		output, success, err := r.process(ctx, call)
+		if err != nil {
+			return r.outcome, err
+		}
+
+		err = e.Agent.SendFunctionResult(ctx, call.ID, call.Name, output, success)
+		r.completeMessage()
+		if err != nil {
+			return r.outcome, fmt.Errorf("failed to send function result for %s: %w", call.Name, err)
+		}
+		r.streamed = "" // In the history now
+	}
+
+	e.Logger.Log("Engine: run finished. Tool calls: %d, failures: %d", r.outcome.ToolCalls, r.outcome.ToolFailures)
+	return r.outcome, nil
+}
+
+// run holds the state of a single Run invocation
+type run struct {
+	engine   *Engine
+	approver Approver
+	notifier Notifier
+	outcome  *Outcome
+	pending  []agent.FunctionCall
+	explore  *exploreBudget // Set during the explore phase of RunExplore
+
+	completed string // The last message reported to MessageObservers
+	streamed  string // The text of the response being streamed, until it ends
+}
+
+// handleItem forwards streamed messages and call previews, and queues
+// function calls
+func (r *run) handleItem(itemJSON string) {
+	var item agent.ResponseItem
+	if err := json.Unmarshal([]byte(itemJSON), &item); err != nil {
+		r.engine.Logger.Log("[ERROR] Engine: failed to unmarshal response item: %v", err)
+		return
+	}
+
+	switch item.Type {
+	case "message":
+		if item.Message != nil && item.Message.Role == "assistant" {
+			// Content in each item is the full message so far
+			r.outcome.FinalMessage = item.Message.Content
+			r.streamed = item.Message.Content
+			r.notifier.OnMessage(item.Message.Content)
+		}
+	case "function_call_preview":
+		if previewer, ok := r.notifier.(ToolCallPreviewer); ok && item.FunctionCall != nil {
+			previewer.OnToolCallPreview(*item.FunctionCall)
+		}
+	case "function_call":
+		if item.FunctionCall != nil {
+			r.pending = append(r.pending, *item.FunctionCall)
+		}
+	case "blocked":
+		if item.Blocked != nil {
+			blocked := *item.Blocked
+			r.outcome.Blocked = &blocked
+			r.notifyAll(func(n interface{}) {
+				if observer, ok := n.(BlockObserver); ok {
+					observer.OnBlocked(blocked)
+				}
+			})
+		}
+	case "model_fallback":
+		if item.Fallback != nil {
+			fallback := *item.Fallback
+			r.outcome.Model = fallback.To
+			r.notifyAll(func(n interface{}) {
+				if observer, ok := n.(FallbackObserver); ok {
+					observer.OnModelFallback(fallback)
+				}
+			})
+		}
+	case "retry":
+		if item.Retry != nil {
+			retry := *item.Retry
+			r.notifyAll(func(n interface{}) {
+				if observer, ok := n.(RetryObserver); ok {
+					observer.OnRetry(retry)
+				}
+			})
+		}
+	}
+}
+
+// process approves and executes a single call, returning what to report to the agent
+func (r *run) process(ctx context.Context, call agent.FunctionCall) (_ string, success bool, err error) {
+	e := r.engine
+	ctx, span := telemetry.Start(ctx, "engine.tool_call", attribute.String("codex.tool", call.Name), attribute.String("codex.call_id", call.ID))
+	defer func() {
+		span.SetAttributes(attribute.Bool("codex.success", success))
+		telemetry.End(span, err)
+	}()
+	r.outcome.ToolCalls++
+	r.notify(func(n Notifier) { n.OnToolCall(call) })
+
+	if r.explore != nil {
+		reason, err := r.explore.check(call)
+		if err != nil {
+			return "", false, err
+		}
+		if reason != "" {
+			// Not a failure of the task: the agent is told to stop exploring
+			e.Logger.Log("Engine: %s", reason)
+			r.notify(func(n Notifier) { n.OnToolDenied(call, reason) })
+			return reason, false, nil
+		}
+	}
+
+	mode := string(e.Config.ApprovalMode)
+	note := ""
+	// Writes outside the allowed roots and patches overwriting existing
+	// files need approval in any mode but dangerous-auto-approve, and are
+	// never allowed by a remembered rule
+	escapes := e.Executor.Escapes(call)
+	escaping := len(escapes) > 0 && e.Config.ApprovalMode != config.DangerousAutoApprove
+	overwrites := e.Executor.Overwrites(call)
+	overwriting := len(overwrites) > 0 && e.Config.ApprovalMode != config.DangerousAutoApprove
+	if escaping || overwriting || executor.NeedsApproval(e.Config.ApprovalMode, call.Name) {
+		var event agent.ApprovalEvent
+		reason := fmt.Sprintf("Operation '%s' denied by user.", call.Name)
+		if rule, ok := r.allowed(call); ok && !escaping && !overwriting {
+			e.Logger.Log("Engine: %s is always allowed by %s", call.Name, rule)
+			event = agent.NewApprovalEvent(call, mode, true, agent.DecidedByPolicy)
+			event.Reason = "always allowed: " + rule
+		} else if r.approver == nil {
+			reason = fmt.Sprintf("Operation '%s' denied: approval is required in %s mode and cannot be requested non-interactively.", call.Name, e.Config.ApprovalMode)
+			switch {
+			case escaping:
+				reason = fmt.Sprintf("Operation '%s' denied: it writes outside the allowed roots (%s), which needs approval that cannot be requested non-interactively.", call.Name, strings.Join(escapes, ", "))
+			case overwriting:
+				reason = fmt.Sprintf("Operation '%s' denied: it overwrites existing files (%s), which needs approval that cannot be requested non-interactively.", call.Name, strings.Join(overwrites, ", "))
+			}
+			event = agent.NewApprovalEvent(call, mode, false, agent.DecidedByPolicy)
+			event.Reason = "approval cannot be requested non-interactively"
+		} else {
+			r.notifyAll(func(n interface{}) {
+				if observer, ok := n.(ApprovalRequestObserver); ok {
+					observer.OnApprovalRequest(call)
+				}
+			})
+			approvalCtx, approvalSpan := telemetry.Start(ctx, "engine.approval", attribute.String("codex.tool", call.Name))
+			decision, err := r.decide(approvalCtx, call)
+			approvalSpan.SetAttributes(attribute.Bool("codex.approved", decision.Approved))
+			telemetry.End(approvalSpan, err)
+			if err != nil {
+				return "", false, fmt.Errorf("approval for %s failed: %w", call.Name, err)
+			}
+			if refusal := decision.Refusal; refusal != nil {
+				// Nobody was asked, so this is a failed call rather than a denial
+				event = agent.NewApprovalEvent(call, mode, false, agent.DecidedByPolicy)
+				event.Reason = "refused without asking: the call cannot succeed"
+				r.recordApproval(event)
+				e.Logger.Log("Engine: %s refused without asking", call.Name)
+				r.outcome.ToolFailures++
+				r.notify(func(n Notifier) { n.OnToolResult(call, refusal) })
+				return refusal.Output, false, nil
+			}
+			event = agent.NewApprovalEvent(call, mode, decision.Approved, agent.DecidedByUser)
+			call, note = decision.Call, decision.Note
+		}
+		r.recordApproval(event)
+		if note != "" {
+			reason += " " + note
+		}
+		if !event.Approved() {
+			reason = e.Config.Messages.Render(config.MessageDenied, config.MessageData{
+				Default: reason,
+				Tool:    call.Name,
+				Mode:    mode,
+				Note:    note,
+			})
+			e.Logger.Log("Engine: %s", reason)
+			r.outcome.Denied++
+			r.outcome.ToolFailures++
+			r.notify(func(n Notifier) { n.OnToolDenied(call, reason) })
+			return reason, false, nil
+		}
+	} else {
+		r.recordApproval(agent.NewApprovalEvent(call, mode, true, agent.DecidedByPolicy))
+	}
+
+	// The approved call, which the reviewer may have changed, may write
+	// outside the allowed roots
+	defer e.Executor.AllowEscapes(e.Executor.Escapes(call))()
+	res := e.Executor.Execute(ctx, call)
+	if !res.Success {
+		r.outcome.ToolFailures++
+	}
+	r.notify(func(n Notifier) { n.OnToolResult(call, res) })
+	output := e.Executor.AgentOutput(call, res)
+	if note != "" {
+		output += "\n\n" + note
+	}
+	return output, res.Success, nil
+}
+
+// allowed reports whether the engine's allow list allows call
+func (r *run) allowed(call agent.FunctionCall) (string, bool) {
+	if r.engine.AllowList == nil {
+		return "", false
+	}
+	return r.engine.AllowList.AllowsCall(call)
+}
+
+// decide asks the approver about call, through Review if it is a Reviewer
+func (r *run) decide(ctx context.Context, call agent.FunctionCall) (Decision, error) {
+	if reviewer, ok := r.approver.(Reviewer); ok {
+		decision, err := reviewer.Review(ctx, call)
+		if decision.Call.Name == "" {
+			decision.Call = call
+		}
+		return decision, err
+	}
+	approved, err := r.approver.Approve(ctx, call)
+	return Decision{Approved: approved, Call: call}, err
+}
+
+// notify reports an event to the run's notifier and the engine's observers
+func (r *run) notify(event func(n Notifier)) {
+	event(r.notifier)
+	for _, observer := range r.engine.Observers {
+		event(observer)
+	}
+}
+
+// redact replaces the secrets in the user and system messages starting the
+// run, which hold what the user typed, attached files and repository
+// context, and reports what it found
+func (r *run) redact(messages []agent.Message) []agent.Message {
+	if r.engine.Executor == nil || r.engine.Executor.Redactor == nil {
+		return messages
+	}
+	redacted := make([]agent.Message, len(messages))
+	var findings []redact.Finding
+	for i, msg := range messages {
+		if msg.Role == "user" || msg.Role == "system" {
+			var found []redact.Finding
+			msg.Content, found = r.engine.Executor.Redactor.Redact(msg.Content)
+			findings = redact.Merge(findings, found)
+		}
+		redacted[i] = msg
+	}
+	if len(findings) > 0 {
+		r.engine.Logger.Log("Engine: redacted %s from the messages", redact.Summary(findings))
+		r.notifyAll(func(n interface{}) {
+			if observer, ok := n.(RedactionObserver); ok {
+				observer.OnRedacted(findings)
+			}
+		})
+	}
+	return redacted
+}
+
+// notifyAll is like notify for events of the optional notifier interfaces:
+// event checks which of them n implements
+func (r *run) notifyAll(event func(n interface{})) {
+	event(r.notifier)
+	for _, observer := range r.engine.Observers {
+		event(observer)
+	}
+}
+
+// recordApproval adds an approval decision to the outcome and reports it to
+// the notifier and observers that keep an audit trail
+func (r *run) recordApproval(event agent.ApprovalEvent) {
+	r.outcome.Approvals = append(r.outcome.Approvals, event)
+	r.notifyAll(func(n interface{}) {
+		if recorder, ok := n.(ApprovalRecorder); ok {
+			recorder.OnApproval(event)
+		}
+	})
+}
+
+// markInterrupted notes in the history that the run was cancelled, after
+// what the assistant had streamed of its response. Calls left unanswered
+// are reported as cancelled with the next message instead, as their results
+// must come first.
+func (r *run) markInterrupted() {
+	history := r.engine.Agent.GetHistory()
+	if history == nil {
+		return
+	}
+	if last, ok := history.GetLastMessage(); ok && last.Role == "assistant" && len(last.ToolCalls) > 0 {
+		return
+	}
+	content := InterruptedNote
+	if r.streamed != "" {
+		content = r.streamed + "\n\n" + content
+	}
+	history.AddMessage(agent.Message{Role: "assistant", Content: content})
+}
+
+// completeMessage reports the assistant message of the stream that just
+// ended to MessageObservers, unless the stream had no new text
+func (r *run) completeMessage() {
+	content := r.outcome.FinalMessage
+	if content == "" || content == r.completed {
+		return
+	}
+	r.completed = content
+	r.notifyAll(func(n interface{}) {
+		if observer, ok := n.(MessageObserver); ok {
+			observer.OnMessageComplete(content)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/executor"
+)
+
+// maxRefusedExploreCalls bounds the calls refused once the exploration budget
+// is spent before the explore phase is given up
+const maxRefusedExploreCalls = 3
+
+// summaryHeading starts the assistant message holding the distilled context
+const summaryHeading = "Context distilled from exploring the repository:"
+
+// Distillation describes how the explore phase condensed the history
+type Distillation struct {
+	Calls          int    `json:"calls"`           // Read-only tool calls made while exploring
+	Summary        string `json:"summary"`         // The distilled context
+	Messages       int    `json:"messages"`        // History messages replaced by the summary
+	TokensBefore   int    `json:"tokens_before"`   // Estimated history size before distilling
+	TokensAfter    int    `json:"tokens_after"`    // Estimated history size after distilling
+	BudgetExceeded bool   `json:"budget_exceeded"` // The agent asked for more calls than allowed
+}
+
+// DistillationReporter is implemented by notifiers that report the end of
+// the explore phase of RunExplore
+type DistillationReporter interface {
+	OnContextDistilled(d Distillation)
+}
+
+// exploreBudget limits the tool calls of an explore phase
+type exploreBudget struct {
+	remaining int
+	calls     int
+	refused   int
+}
+
+// check returns why a call may not run while exploring, or "" if it may.
+// It spends one unit of the budget on calls that may run.
+func (b *exploreBudget) check(call agent.FunctionCall) (string, error) {
+	switch {
+	case !executor.IsReadOnly(call.Name):
+		b.refused++
+		if b.refused > maxRefusedExploreCalls {
+			return "", fmt.Errorf("the agent kept calling %s during the read-only explore phase", call.Name)
+		}
+		return fmt.Sprintf("'%s' is not available while exploring: only read-only tools can be used until you have written the context summary.", call.Name), nil
+	case b.remaining == 0:
+		b.refused++
+		if b.refused > maxRefusedExploreCalls {
+			return "", errors.New("the agent kept calling tools after the exploration budget was spent")
+		}
+		return "The exploration budget is spent. Do not call any more tools; write the context summary now.", nil
+	}
+	b.remaining--
+	b.calls++
+	return "", nil
+}
+
+// explorePrompt asks the agent to explore for task within maxCalls
+func explorePrompt(task string, maxCalls int) string {
+	return fmt.Sprintf(`Before working on the task below, explore the repository to gather the context it needs. This is an exploration phase:
+- Use only read-only tools (%s), at most %d calls in total. Prefer few, targeted calls.
+- Do not change anything yet.
+- When you have enough context, or the calls run out, stop calling tools and reply with a distilled context summary. It replaces the raw tool outputs, which you will not see again, so include everything the implementation will need: relevant files and their roles, key types and functions with signatures, conventions to follow, how to build and test, and open questions. Quote short code excerpts only where exact text matters.
+
+Task:
+%s`, strings.Join(executor.ReadOnlyFunctions(), ", "), maxCalls, task)
+}
+
+// ExploreOptions configures RunExplore
+type ExploreOptions struct {
+	// MaxCalls bounds the read-only tool calls of the explore phase; zero or
+	// less uses config.DefaultExploreCalls
+	MaxCalls int
+
+	// Context holds messages sent ahead of the task, such as instructions.
+	// They stay in the history when the exploration is distilled.
+	Context []agent.Message
+}
+
+// RunExplore runs a task in two phases to keep long tasks within the context
+// limit. In the explore phase the agent may make at most opts.MaxCalls
+// read-only tool calls, then writes a distilled summary of what it learned.
+// The exploration's calls and outputs are then replaced in the history by
+// the task and that summary, and the task runs as with Run from there.
+//
+// The outcome covers both phases. If the agent writes no summary, the
+// history is left as it is and an error is returned.
+func (e *Engine) RunExplore(ctx context.Context, task string, opts ExploreOptions, approver Approver, notifier Notifier) (*Outcome, error) {
+	if notifier == nil {
+		notifier = NopNotifier{}
+	}
+	maxCalls := opts.MaxCalls
+	if maxCalls <= 0 {
+		maxCalls = config.DefaultExploreCalls
+	}
+	history := e.Agent.GetHistory()
+	if history == nil {
+		return &Outcome{}, errors.New("explore phase needs an agent that keeps a history")
+	}
+
+	prompt := agent.Message{Role: "user", Content: explorePrompt(task, maxCalls)}
+	budget := &exploreBudget{remaining: maxCalls}
+	e.Logger.Log("Engine: exploring with up to %d read-only calls", maxCalls)
+	messages := append(append([]agent.Message{}, opts.Context...), prompt)
+	explored, err := e.run(ctx, messages, approver, notifier, budget)
+	if err != nil {
+		return explored, err
+	}
+	summary := strings.TrimSpace(explored.FinalMessage)
+	if summary == "" {
+		return explored, errors.New("the agent wrote no context summary after exploring")
+	}
+
+	// Replace everything from the exploration prompt on. If pruning dropped
+	// the prompt, everything older went first, so replace all but the
+	// system messages it moved to the front.
+	messages = history.GetMessages()
+	start := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" && messages[i].Content == prompt.Content {
+			start = i
+			break
+		}
+	}
+	if start == len(messages) {
+		for start = 0; start < len(messages) && messages[start].Role == "system"; start++ {
+		}
+	}
+	d := Distillation{
+		Calls:          budget.calls,
+		Summary:        summary,
+		Messages:       len(messages) - start,
+		TokensBefore:   history.EstimateTokenCount(),
+		BudgetExceeded: budget.refused > 0,
+	}
+	history.ReplaceFrom(start, []agent.Message{
+		{Role: "user", Content: task},
+		{Role: "assistant", Content: summaryHeading + "\n\n" + summary},
+	})
+	d.TokensAfter = history.EstimateTokenCount()
+	e.Logger.Log("Engine: distilled %d exploration messages after %d calls (about %d -> %d tokens)", d.Messages, d.Calls, d.TokensBefore, d.TokensAfter)
+	if reporter, ok := notifier.(DistillationReporter); ok {
+		reporter.OnContextDistilled(d)
+	}
+
+	implement := agent.Message{Role: "user", Content: "The exploration phase is over. Using the context summary above, carry out the task now."}
+	outcome, err := e.run(ctx, []agent.Message{implement}, approver, notifier, nil)
+	outcome.ToolCalls += explored.ToolCalls
+	outcome.ToolFailures += explored.ToolFailures
+	outcome.Denied += explored.Denied
+	outcome.Approvals = append(explored.Approvals, outcome.Approvals...)
+	outcome.Distillation = &d
+	return outcome, err
+}
@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/sandbox"
+)
+
+// exploringAgent keeps a history like the OpenAI agent. While exploring it
+// requests the given calls and then answers with the summary; afterwards it
+// answers with the task's result.
+type exploringAgent struct {
+	scriptedAgent
+	history *agent.ConversationHistory
+	sent    [][]agent.Message // Context of each SendMessage
+	summary string
+}
+
+func (a *exploringAgent) GetHistory() *agent.ConversationHistory { return a.history }
+
+func (a *exploringAgent) SendMessage(ctx context.Context, messages []agent.Message, handler agent.ResponseHandler) (bool, error) {
+	a.history.AddMessages(messages)
+	a.sent = append(a.sent, a.history.GetMessages())
+	a.handler = handler
+	if !strings.HasPrefix(messages[len(messages)-1].Content, "Before working on the task") {
+		a.answer("Implemented the task.")
+		return false, nil
+	}
+	a.results = make(map[string]string)
+	for i := range a.calls {
+		a.history.AddToolMessage(a.calls[i].Name, nil, a.calls[i].ID)
+		a.emit(agent.ResponseItem{Type: "function_call", FunctionCall: &a.calls[i]})
+	}
+	return true, nil
+}
+
+func (a *exploringAgent) SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) error {
+	a.results[callID] = output
+	a.history.AddToolResultMessage(callID, functionName, map[string]interface{}{"output": output})
+	if len(a.results) == len(a.calls) {
+		a.answer(a.summary)
+	}
+	return nil
+}
+
+func (a *exploringAgent) answer(content string) {
+	a.history.AddMessage(agent.Message{Role: "assistant", Content: content})
+	a.emit(agent.ResponseItem{Type: "message", Message: &agent.Message{Role: "assistant", Content: content}})
+}
+
+// distillationRecorder records the distillation reported by RunExplore
+type distillationRecorder struct {
+	recordingNotifier
+	distilled []Distillation
+}
+
+func (n *distillationRecorder) OnContextDistilled(d Distillation) {
+	n.distilled = append(n.distilled, d)
+}
+
+func TestRunExplore(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	cfg := &config.Config{ApprovalMode: config.FullAuto, CWD: dir}
+	registry := functions.NewRegistry()
+	registry.Register("read_file", functions.ReadFile)
+	registry.Register("list_directory", functions.ListDirectory)
+	exec := executor.New(cfg, sandbox.NewBasicSandbox(), registry, nil)
+
+	history, err := agent.NewConversationHistory(agent.HistoryOptions{MaxTokenCount: 100000, SystemPrompt: "You are a coding agent."})
+	if err != nil {
+		t.Fatalf("NewConversationHistory failed: %v", err)
+	}
+	ai := &exploringAgent{history: history, summary: "Summary: main.go holds the entry point."}
+	ai.calls = []agent.FunctionCall{
+		{ID: "call_1", Name: "list_directory", Arguments: `{"path":"` + dir + `"}`},
+		{ID: "call_2", Name: "shell", Arguments: `{"command":"touch changed"}`},
+		{ID: "call_3", Name: "read_file", Arguments: `{"path":"` + filepath.Join(dir, "main.go") + `"}`},
+		{ID: "call_4", Name: "read_file", Arguments: `{"path":"` + filepath.Join(dir, "main.go") + `"}`},
+	}
+
+	notifier := &distillationRecorder{}
+	instructions := agent.Message{Role: "system", Content: "Report a status."}
+	outcome, err := New(ai, exec, cfg, nil).RunExplore(context.Background(), "Add logging", ExploreOptions{MaxCalls: 2, Context: []agent.Message{instructions}}, nil, notifier)
+	if err != nil {
+		t.Fatalf("RunExplore failed: %v", err)
+	}
+	if outcome.FinalMessage != "Implemented the task." || outcome.ToolCalls != 4 || outcome.ToolFailures != 0 {
+		t.Errorf("Unexpected outcome: %+v", outcome)
+	}
+
+	// Only the first two read-only calls ran
+	if _, err := os.Stat(filepath.Join(dir, "changed")); err == nil {
+		t.Error("Expected the shell call to be refused while exploring")
+	}
+	if !strings.Contains(ai.results["call_2"], "not available while exploring") {
+		t.Errorf("Unexpected output for the shell call: %q", ai.results["call_2"])
+	}
+	if !strings.Contains(ai.results["call_3"], "func main") {
+		t.Errorf("Expected read_file to run, got %q", ai.results["call_3"])
+	}
+	if !strings.Contains(ai.results["call_4"], "budget is spent") {
+		t.Errorf("Expected the third read-only call to be refused, got %q", ai.results["call_4"])
+	}
+	if strings.Join(notifier.denied, ",") != "shell,read_file" {
+		t.Errorf("Expected the refused calls to be reported, got %v", notifier.denied)
+	}
+
+	if len(notifier.distilled) != 1 || outcome.Distillation == nil || notifier.distilled[0] != *outcome.Distillation {
+		t.Fatalf("Expected one distillation in the notifier and the outcome, got %+v and %+v", notifier.distilled, outcome.Distillation)
+	}
+	d := notifier.distilled[0]
+	if d.Calls != 2 || !d.BudgetExceeded || d.Summary != ai.summary || d.Messages != 10 || d.TokensAfter >= d.TokensBefore {
+		t.Errorf("Unexpected distillation: %+v", d)
+	}
+
+	// The implementation starts from the instructions, the task and the
+	// summary, without the exploration
+	if len(ai.sent) != 2 {
+		t.Fatalf("Expected two requests, got %d", len(ai.sent))
+	}
+	var roles, contents []string
+	for _, m := range ai.sent[1] {
+		roles = append(roles, m.Role)
+		contents = append(contents, m.Content)
+	}
+	if got := strings.Join(roles, ","); got != "system,system,user,assistant,user" {
+		t.Fatalf("Unexpected history roles %s", got)
+	}
+	if contents[1] != instructions.Content || contents[2] != "Add logging" || !strings.HasSuffix(contents[3], ai.summary) || !strings.Contains(contents[4], "carry out the task") {
+		t.Errorf("Unexpected history after distilling: %q", contents)
+	}
+}
+
+func TestRunExploreWithoutSummary(t *testing.T) {
+	cfg := &config.Config{ApprovalMode: config.FullAuto, CWD: t.TempDir()}
+	exec := executor.New(cfg, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	history, _ := agent.NewConversationHistory(agent.HistoryOptions{MaxTokenCount: 100000})
+	ai := &exploringAgent{history: history}
+	ai.calls = []agent.FunctionCall{{ID: "call_1", Name: "list_directory", Arguments: `{}`}}
+
+	notifier := &distillationRecorder{}
+	if _, err := New(ai, exec, cfg, nil).RunExplore(context.Background(), "task", ExploreOptions{}, nil, notifier); err == nil || !strings.Contains(err.Error(), "no context summary") {
+		t.Errorf("Expected a missing summary to fail, got %v", err)
+	}
+	if len(notifier.distilled) != 0 || len(ai.sent) != 1 {
+		t.Errorf("Expected the run to stop after exploring, got %d distillations and %d requests", len(notifier.distilled), len(ai.sent))
+	}
+}
+
+func TestExploreBudgetGivesUp(t *testing.T) {
+	b := &exploreBudget{remaining: 1}
+	if reason, err := b.check(agent.FunctionCall{Name: "read_file"}); reason != "" || err != nil {
+		t.Fatalf("Expected the first read to run, got %q, %v", reason, err)
+	}
+	for i := 0; i < maxRefusedExploreCalls; i++ {
+		if reason, err := b.check(agent.FunctionCall{Name: "read_file"}); reason == "" || err != nil {
+			t.Fatalf("Expected call %d to be refused, got %q, %v", i, reason, err)
+		}
+	}
+	if _, err := b.check(agent.FunctionCall{Name: "read_file"}); err == nil {
+		t.Error("Expected the explore phase to give up")
+	}
+}
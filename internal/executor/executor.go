@@ -0,0 +1,1279 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/changes"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/fileops"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/guard"
+	"github.com/epuerta/codex-go/internal/ignore"
+	"github.com/epuerta/codex-go/internal/index"
+	"github.com/epuerta/codex-go/internal/instructions"
+	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/epuerta/codex-go/internal/memory"
+	"github.com/epuerta/codex-go/internal/redact"
+	"github.com/epuerta/codex-go/internal/sandbox"
+	"github.com/epuerta/codex-go/internal/scripts"
+	"github.com/epuerta/codex-go/internal/telemetry"
+	"github.com/epuerta/codex-go/internal/truncate"
+	"github.com/epuerta/codex-go/internal/webfetch"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultCommandTimeout is the timeout applied to shell commands
+const DefaultCommandTimeout = config.DefaultCommandTimeout * time.Second
+
+// DefaultMaxCommandTimeout is the longest timeout the agent may ask for
+const DefaultMaxCommandTimeout = config.DefaultMaxCommandTimeout * time.Second
+
+// MaxPatchAttempts is the number of unparseable patches in a row after which
+// the agent is told to stop and ask the user
+const MaxPatchAttempts = 3
+
+// formatTimeout bounds how long auto-formatting a patched file may take
+const formatTimeout = 15 * time.Second
+
+// Result is the outcome of executing a function call.
+// Output and Success are what gets reported back to the agent; the remaining
+// fields carry details a UI may want to render.
+type Result struct {
+	Output  string
+	Success bool
+
+	// Command is the shell command that was run, if any
+	Command string
+	// CommandResult is set for shell commands that were started
+	CommandResult *sandbox.CommandResult
+	// CommandErr is the error returned by the sandbox, if any
+	CommandErr error
+
+	// PatchResults holds one entry per patched file
+	PatchResults []*fileops.AgentPatchResult
+	// PatchParseErr is set if the patch could not be parsed
+	PatchParseErr error
+	// PatchAttempts counts the patches in a row, this one included, that
+	// could not be parsed; at MaxPatchAttempts the agent is told to stop
+	PatchAttempts int
+	// FormatErrors lists auto-formatting failures for patched files
+	FormatErrors []string
+
+	// DryRun is set for patch_file, write_file and edit_lines calls
+	// simulated in dry-run mode; Diff holds the changes they would have made
+	DryRun bool
+	Diff   string
+
+	// Verdict is the prompt-injection scan result for Output
+	Verdict guard.Verdict
+	// Redactions counts the secrets replaced with placeholders in Output
+	Redactions []redact.Finding
+
+	// Instructions holds the instruction files governing the files the call
+	// touched that the agent has not seen yet; they go along with Output
+	Instructions string
+
+	// Interrupted is set for commands stopped with InterruptCommand
+	Interrupted bool
+	// TimedOut is set for commands stopped because they ran out of time
+	TimedOut bool
+
+	// Environment is where the command ran, when SnapshotEnvironment is set
+	Environment *sandbox.Environment
+}
+
+// Executor dispatches agent function calls without any UI dependencies
+type Executor struct {
+	Config         *config.Config
+	Sandbox        sandbox.Sandbox
+	Registry       *functions.Registry
+	Logger         logging.Logger
+	CommandTimeout time.Duration
+
+	// MaxCommandTimeout bounds the longer timeouts the agent may ask for
+	// with the timeout argument of the shell tool
+	MaxCommandTimeout time.Duration
+
+	// Stdout and Stderr, if set, receive shell command output as it is produced
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// GuardOutput wraps results sent to the agent in untrusted-data blocks
+	GuardOutput bool
+	// Classifier, if set, scans every result for prompt-injection attempts
+	Classifier guard.Classifier
+	// Redactor, if set, replaces secrets in every result and in the
+	// messages starting a run before the agent sees them
+	Redactor *redact.Redactor
+
+	// Scripts are the project scripts run_project_script may run
+	Scripts []scripts.Script
+
+	// Workspace confines patched paths to the allowed roots; nil leaves them unchecked
+	Workspace *fileops.Workspace
+
+	// Terminal, if set, lends the user's terminal to interactive commands;
+	// without it they are refused
+	Terminal Terminal
+
+	// OutputLimits truncate command output sent to the agent; the full
+	// output stays in CommandResult
+	OutputLimits truncate.Limits
+
+	// SnapshotEnvironment records the environment of each shell command in
+	// its result, so that it can be reproduced later
+	SnapshotEnvironment bool
+
+	// Changes, if set, records the files written with patch_file,
+	// write_file and edit_lines, with their content before the first write
+	Changes *changes.Tracker
+
+	// Instructions, if set, adds the AGENTS.md and codex.md files governing
+	// the files a call touches to its result, the first time
+	Instructions *instructions.Tracker
+
+	dryRun atomic.Bool // patch_file, write_file and edit_lines only report what they would change
+
+	mu            sync.Mutex
+	interrupt     context.CancelCauseFunc // Stops the command in progress, if any
+	patchFailures int                     // Patches in a row that could not be parsed
+
+	envMu        sync.Mutex
+	environments map[string]*sandbox.Environment // Snapshots by working directory
+}
+
+// Terminal hands the user's terminal over to interactive commands
+type Terminal interface {
+	// Lend calls run with the terminal's input and output while nothing else
+	// uses the terminal, and returns run's error
+	Lend(ctx context.Context, run func(stdin io.Reader, stdout io.Writer) error) error
+}
+
+// errInterrupted is the cause given to a command's context by InterruptCommand
+var errInterrupted = errors.New("interrupted by the user")
+
+// errTimedOut is the cause given to a command's context when its timeout expires
+var errTimedOut = errors.New("timed out")
+
+// New creates an executor with the command timeouts of cfg, or the defaults
+// where it does not set them
+func New(cfg *config.Config, sb sandbox.Sandbox, registry *functions.Registry, logger logging.Logger) *Executor {
+	if logger == nil {
+		logger = logging.NewNilLogger()
+	}
+	e := &Executor{
+		Config:            cfg,
+		Sandbox:           sb,
+		Registry:          registry,
+		Logger:            logger,
+		CommandTimeout:    DefaultCommandTimeout,
+		MaxCommandTimeout: DefaultMaxCommandTimeout,
+		GuardOutput:       cfg.GuardToolOutput,
+		OutputLimits: truncate.Limits{
+			HeadLines: cfg.OutputHeadLines,
+			TailLines: cfg.OutputTailLines,
+			MaxBytes:  cfg.OutputMaxBytes,
+		},
+	}
+	if cfg.CommandTimeout > 0 {
+		e.CommandTimeout = time.Duration(cfg.CommandTimeout) * time.Second
+	}
+	if cfg.MaxCommandTimeout > 0 {
+		e.MaxCommandTimeout = time.Duration(cfg.MaxCommandTimeout) * time.Second
+	}
+	if cfg.InjectionScan {
+		e.Classifier = guard.NewHeuristicClassifier()
+	}
+	e.dryRun.Store(cfg.DryRun)
+	if cfg.RedactSecrets {
+		redactor, err := redact.New(cfg.RedactPatterns)
+		if err != nil {
+			logger.Log("WARN: Executor: %v; redacting with the built-in detectors only", err)
+			redactor, _ = redact.New(nil)
+		}
+		e.Redactor = redactor
+	}
+
+	policy, err := fileops.ParseSymlinkPolicy(cfg.SymlinkPolicy)
+	if err != nil {
+		logger.Log("WARN: Executor: %v; refusing symbolic links", err)
+		policy = fileops.RefuseSymlinks
+	}
+	if e.Workspace, err = fileops.NewWorkspace(cfg.CWD, policy); err != nil {
+		logger.Log("WARN: Executor: file operations are not confined: %v", err)
+	} else if err := e.Workspace.SetRoots(allowedRoots(cfg)); err != nil {
+		logger.Log("WARN: Executor: %v; confining file operations to %s", err, e.Workspace.Root)
+	}
+	if e.Workspace != nil {
+		e.Workspace.Ignore = loadIgnore(cfg, e.Workspace.Root, logger)
+		if e.Workspace.OnCreate, err = fileops.ParseCreateConflict(cfg.CreateConflict); err != nil {
+			logger.Log("WARN: Executor: %v; patches fail to create files that exist", err)
+		}
+	}
+	return e
+}
+
+// loadIgnore reads the .codexignore file at the root of the repository
+// holding the working directory, or else in the workspace root
+func loadIgnore(cfg *config.Config, root string, logger logging.Logger) *ignore.Matcher {
+	if repo := fileops.RepositoryRoot(cfg.CWD); repo != "" {
+		root = repo
+	}
+	m, err := ignore.Load(root)
+	if err != nil {
+		logger.Log("WARN: Executor: %v; no paths are ignored", err)
+		return nil
+	}
+	if m != nil {
+		logger.Log("Executor: ignoring %d patterns of %s", m.Len(), filepath.Join(root, ignore.FileName))
+	}
+	return m
+}
+
+// allowedRoots returns the allowed_roots of cfg, or else the root of the
+// repository holding the working directory. Outside a repository it returns
+// nil, which confines file operations to the working directory.
+func allowedRoots(cfg *config.Config) []string {
+	if len(cfg.AllowedRoots) > 0 {
+		return cfg.AllowedRoots
+	}
+	if root := fileops.RepositoryRoot(cfg.CWD); root != "" {
+		return []string{root}
+	}
+	return nil
+}
+
+// DryRun reports whether patch_file and write_file only report the changes
+// they would make
+func (e *Executor) DryRun() bool {
+	return e.dryRun.Load()
+}
+
+// SetDryRun turns dry-run mode on or off for the next calls
+func (e *Executor) SetDryRun(on bool) {
+	e.dryRun.Store(on)
+}
+
+// IsCommandFunction reports whether the function runs a shell command.
+// The agent advertises the tool as "shell" while the registry uses "execute_command".
+func IsCommandFunction(name string) bool {
+	return name == "execute_command" || name == "shell"
+}
+
+// isFileEdit reports whether the function changes files
+func isFileEdit(name string) bool {
+	return name == "patch_file" || name == "write_file" || name == "edit_lines"
+}
+
+// readOnlyFunctions only read the workspace, so suggest mode runs them without asking
+var readOnlyFunctions = map[string]bool{
+	"read_file": true, "stat_file": true, "checksum_file": true, "list_directory": true, "search_code": true,
+	"summarize_workspace": true, index.ToolName: true,
+}
+
+// IsReadOnly reports whether a function only reads the workspace
+func IsReadOnly(functionName string) bool {
+	return readOnlyFunctions[functionName]
+}
+
+// ReadOnlyFunctions lists the functions that only read the workspace, sorted
+func ReadOnlyFunctions() []string {
+	names := make([]string, 0, len(readOnlyFunctions))
+	for name := range readOnlyFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NeedsApproval determines if a function needs approval in the given mode
+func NeedsApproval(mode config.ApprovalMode, functionName string) bool {
+	if functionName == functions.ChoicesToolName {
+		return false // It only asks the user a question
+	}
+	if functionName == memory.ToolName {
+		// Notes outlive the session, so the user confirms each one
+		return mode != config.DangerousAutoApprove
+	}
+	switch mode {
+	case config.AutoEdit:
+		return IsCommandFunction(functionName) || functionName == scripts.ToolName
+	case config.FullAuto, config.DangerousAutoApprove:
+		return false
+	default:
+		// Suggest, and unknown modes fall back to suggest behavior
+		return !readOnlyFunctions[functionName]
+	}
+}
+
+// ApprovalReason explains NeedsApproval's answer for a function in mode
+func ApprovalReason(mode config.ApprovalMode, functionName string) string {
+	switch {
+	case functionName == functions.ChoicesToolName:
+		return "it only asks you a question"
+	case functionName == memory.ToolName && mode != config.DangerousAutoApprove:
+		return "notes outlive the session"
+	}
+	switch mode {
+	case config.AutoEdit:
+		if IsCommandFunction(functionName) || functionName == scripts.ToolName {
+			return "auto-edit mode asks before commands"
+		}
+		return "auto-edit mode applies edits"
+	case config.FullAuto:
+		return "full-auto mode runs everything in the sandbox"
+	case config.DangerousAutoApprove:
+		return "dangerous mode skips every approval"
+	default:
+		if readOnlyFunctions[functionName] {
+			return "read-only"
+		}
+		return "suggest mode asks before changes"
+	}
+}
+
+// ApprovalArgs extracts the part of a call's arguments worth showing a user
+// when asking for approval: the command, the patch, the file content or the
+// replacement lines. Falls back to the raw JSON arguments.
+func ApprovalArgs(call agent.FunctionCall) string {
+	if call.Name == scripts.ToolName {
+		var args scriptArgs
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil || args.Name == "" {
+			return call.Arguments
+		}
+		return strings.TrimSpace(args.Name + " " + strings.Join(args.Args, " "))
+	}
+	if call.Name == memory.ToolName {
+		if note := memory.Note(call.Arguments); note != "" {
+			return note
+		}
+		return call.Arguments
+	}
+	if call.Name == webfetch.ToolName {
+		var args struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil || args.URL == "" {
+			return call.Arguments
+		}
+		return args.URL
+	}
+	if !IsCommandFunction(call.Name) && !isFileEdit(call.Name) {
+		return call.Arguments
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return call.Arguments
+	}
+	for _, key := range []string{"command", "code_edit", "patch_content", "content", "replacement"} {
+		if v, ok := args[key].(string); ok {
+			return v
+		}
+	}
+	return call.Arguments
+}
+
+// WithPatch returns a copy of a patch_file call applying patch instead, for a
+// patch the user changed during review. Other arguments are kept.
+func WithPatch(call agent.FunctionCall, patch string) (agent.FunctionCall, error) {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return call, fmt.Errorf("error parsing patch_file args: %w", err)
+	}
+	key := "code_edit"
+	if _, ok := args[key].(string); !ok {
+		key = "patch_content"
+	}
+	args[key] = patch
+	data, err := json.Marshal(args)
+	if err != nil {
+		return call, err
+	}
+	call.Arguments = string(data)
+	return call, nil
+}
+
+// PreviewArgs is ApprovalArgs for arguments that are still streaming in and
+// usually not valid JSON yet. The command, patch or content is decoded as far
+// as it has arrived; before it starts, the raw arguments are returned.
+func PreviewArgs(call agent.FunctionCall) string {
+	if json.Valid([]byte(call.Arguments)) {
+		return ApprovalArgs(call)
+	}
+	if !IsCommandFunction(call.Name) && !isFileEdit(call.Name) {
+		return call.Arguments
+	}
+	for _, key := range []string{"command", "code_edit", "patch_content", "content", "replacement"} {
+		if v, ok := partialStringField(call.Arguments, key); ok {
+			return v
+		}
+	}
+	return call.Arguments
+}
+
+// partialStringField decodes the string value of key in the truncated JSON
+// object args, up to the end of args or the closing quote
+func partialStringField(args, key string) (string, bool) {
+	re := regexp.MustCompile(`"` + regexp.QuoteMeta(key) + `"\s*:\s*"`)
+	loc := re.FindStringIndex(args)
+	if loc == nil {
+		return "", false
+	}
+	raw := args[loc[1]:]
+	end := len(raw)
+scan:
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '"':
+			end = i
+			break scan
+		case '\\':
+			n := 2
+			if i+1 < len(raw) && raw[i+1] == 'u' {
+				n = 6
+			}
+			if i+n > len(raw) {
+				// An escape sequence cut off by the end of the stream
+				end = i
+				break scan
+			}
+			i += n - 1
+		}
+	}
+	var v string
+	if err := json.Unmarshal([]byte(`"`+raw[:end]+`"`), &v); err != nil {
+		return "", false
+	}
+	return v, true
+}
+
+// Execute runs a function call and returns its result
+func (e *Executor) Execute(ctx context.Context, call agent.FunctionCall) *Result {
+	e.Logger.Log("Executor: executing %s (ID: %s)", call.Name, call.ID)
+	ctx, span := telemetry.Start(ctx, "executor.execute", attribute.String("codex.tool", call.Name), attribute.String("codex.call_id", call.ID))
+	defer span.End()
+	res := e.execute(ctx, call)
+	span.SetAttributes(attribute.Bool("codex.success", res.Success))
+	if e.Redactor != nil && res.Output != "" {
+		res.Output, res.Redactions = e.redact(call, res.Output)
+		if len(res.Redactions) > 0 {
+			e.Logger.Log("Executor: redacted %s from %s output", redact.Summary(res.Redactions), call.Name)
+		}
+	}
+	if e.Classifier != nil && res.Output != "" {
+		res.Verdict = e.Classifier.Classify(res.Output)
+		if res.Verdict.Suspicious {
+			e.Logger.Log("WARN: Executor: possible prompt injection in %s output: %s", call.Name, res.Verdict.Summary())
+		}
+	}
+	if e.Instructions != nil && res.Success {
+		res.Instructions = e.instructionsFor(call)
+	}
+	return res
+}
+
+// instructionsFor returns the instruction files governing the files call
+// touched that were not handed out yet, rendered for the agent
+func (e *Executor) instructionsFor(call agent.FunctionCall) string {
+	var files []instructions.File
+	var omitted []string
+	for _, path := range e.touchedPaths(call) {
+		f, o := e.Instructions.For(path)
+		files = append(files, f...)
+		omitted = append(omitted, o...)
+	}
+	if len(files) > 0 || len(omitted) > 0 {
+		e.Logger.Log("Executor: %d instruction file(s) for %s, %d left out", len(files), call.Name, len(omitted))
+	}
+	return e.Instructions.Render(files, omitted)
+}
+
+// touchedPaths returns the absolute paths of the files and directories a
+// file function call reads or writes
+func (e *Executor) touchedPaths(call agent.FunctionCall) []string {
+	var paths []string
+	switch call.Name {
+	case "read_file", "write_file", "edit_lines", "list_directory":
+		var args struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err == nil && args.Path != "" {
+			paths = []string{args.Path}
+		}
+	case "patch_file":
+		paths = fileops.PatchFiles(ApprovalArgs(call))
+	}
+	var resolved []string
+	for _, path := range paths {
+		if abs := e.resolvePath(path); abs != "" {
+			resolved = append(resolved, abs)
+		}
+	}
+	return resolved
+}
+
+// redact replaces the secrets in the output of call. Every value of a .env
+// file read with read_file counts as a secret.
+func (e *Executor) redact(call agent.FunctionCall, output string) (string, []redact.Finding) {
+	if call.Name == "read_file" {
+		var args struct {
+			Path string `json:"path"`
+		}
+		if json.Unmarshal([]byte(call.Arguments), &args) == nil && redact.IsEnvFile(args.Path) {
+			return e.Redactor.EnvFile(output)
+		}
+	}
+	return e.Redactor.Redact(output)
+}
+
+// AgentOutput returns the text to send back to the agent for an executed call
+func (e *Executor) AgentOutput(call agent.FunctionCall, res *Result) string {
+	output := res.Output
+	if e.GuardOutput {
+		output = guard.Wrap(call.Name, call.ID, res.Output, res.Verdict)
+	}
+	// The project's instructions are not tool output, so they stay outside the guard
+	if res.Instructions != "" {
+		output += "\n\n" + res.Instructions
+	}
+	return output
+}
+
+// execute dispatches a function call to its handler
+func (e *Executor) execute(ctx context.Context, call agent.FunctionCall) *Result {
+	switch {
+	case IsCommandFunction(call.Name):
+		var args struct {
+			Command     string `json:"command"`
+			Interactive bool   `json:"interactive"`
+			Timeout     int    `json:"timeout"` // Seconds
+		}
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return &Result{Output: fmt.Sprintf("Error parsing command args: %v", err)}
+		}
+		if args.Command == "" {
+			return &Result{Output: fmt.Sprintf("Missing command argument for %s", call.Name)}
+		}
+		if args.Interactive {
+			return e.ExecuteInteractiveCommand(ctx, args.Command)
+		}
+		return e.executeCommand(ctx, args.Command, e.commandTimeout(args.Timeout))
+
+	case call.Name == "patch_file":
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return &Result{Output: fmt.Sprintf("Error parsing patch_file args: %v", err)}
+		}
+		patchContent, _ := args["code_edit"].(string)
+		if patchContent == "" {
+			patchContent, _ = args["patch_content"].(string)
+		}
+		if patchContent == "" {
+			return &Result{Output: "Missing patch_content argument for patch_file"}
+		}
+		return e.ApplyPatch(ctx, patchContent)
+
+	case call.Name == scripts.ToolName:
+		var args scriptArgs
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return &Result{Output: fmt.Sprintf("Error parsing %s args: %v", call.Name, err)}
+		}
+		return e.RunScript(ctx, args.Name, args.Args)
+
+	default:
+		fn := e.Registry.GetContext(call.Name)
+		if fn == nil {
+			return &Result{Output: fmt.Sprintf("Unknown function: %s", call.Name)}
+		}
+		if (call.Name == "write_file" || call.Name == "edit_lines") && e.DryRun() {
+			return e.previewWrite(call)
+		}
+		written := e.writtenPath(call)
+		if written != "" {
+			e.Changes.Before(written)
+		}
+		result, err := fn(ctx, call.Arguments)
+		e.Logger.Log("Executor: function '%s' result: ResultLen=%d, Error=%v", call.Name, len(result), err)
+		if err != nil {
+			return &Result{Output: fmt.Sprintf("Error: %v", err)}
+		}
+		if written != "" {
+			e.Changes.Written(written)
+		}
+		return &Result{Output: result, Success: true}
+	}
+}
+
+// ExecuteCommand runs a shell command in the sandbox with CommandTimeout.
+// The command can be stopped with InterruptCommand without cancelling ctx.
+func (e *Executor) ExecuteCommand(ctx context.Context, command string) *Result {
+	return e.executeCommand(ctx, command, e.CommandTimeout)
+}
+
+// commandTimeout returns the timeout for a command the agent asked to run
+// for seconds: CommandTimeout if it did not ask, and at most MaxCommandTimeout
+func (e *Executor) commandTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return e.CommandTimeout
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if limit := max(e.MaxCommandTimeout, e.CommandTimeout); timeout > limit {
+		e.Logger.Log("Executor: requested timeout %s exceeds the maximum, using %s", timeout, limit)
+		return limit
+	}
+	return timeout
+}
+
+// executeCommand runs a shell command in the sandbox, stopping it after timeout
+func (e *Executor) executeCommand(ctx context.Context, command string, timeout time.Duration) *Result {
+	e.Logger.Log("Executor: running command via sandbox (timeout %s): %s", timeout, command)
+	ctx, span := telemetry.Start(ctx, "sandbox.execute", attribute.String("codex.command", command), attribute.String("codex.sandbox", e.Sandbox.Name()), attribute.Int64("codex.timeout_ms", timeout.Milliseconds()))
+	env := e.environment(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	e.mu.Lock()
+	e.interrupt = cancel
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.interrupt = nil
+		e.mu.Unlock()
+	}()
+	if timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, timeout, errTimedOut)
+		defer stop()
+	}
+
+	result, err := e.Sandbox.Execute(ctx, sandbox.SandboxOptions{
+		Command:      command,
+		Shell:        e.Config.Sandbox.Shell,
+		WorkingDir:   e.Config.CWD,
+		AllowNetwork: e.Config.Sandbox.AllowNetwork,
+		Env:          e.Config.Sandbox.EnvMap(),
+		Stdout:       e.Stdout,
+		Stderr:       e.Stderr,
+	})
+	res := e.commandResult(ctx, command, result, err)
+	if result != nil {
+		span.SetAttributes(attribute.Int("codex.exit_code", result.ExitCode))
+	}
+	span.SetAttributes(attribute.Bool("codex.success", res.Success), attribute.Bool("codex.timed_out", res.TimedOut), attribute.Bool("codex.interrupted", res.Interrupted))
+	telemetry.End(span, err)
+	// A configured timeout message replaces this hint too
+	if res.TimedOut && e.Config.Messages.Timeout == "" {
+		res.Output += fmt.Sprintf("\nIf the command needs more time, run it again with a larger timeout (at most %d seconds).", int(max(e.MaxCommandTimeout, e.CommandTimeout).Seconds()))
+	}
+	res.Environment = env
+	return res
+}
+
+// ExecuteInteractiveCommand runs a shell command on a pseudo-terminal
+// connected to the user's terminal, for commands that prompt for input or
+// open an editor. The user works with the command directly until it exits;
+// there is no timeout.
+func (e *Executor) ExecuteInteractiveCommand(ctx context.Context, command string) *Result {
+	if e.Terminal == nil {
+		return &Result{Output: "Interactive commands are not available in this session: no one can answer them. Run the command without interactive, giving its input as arguments or flags instead."}
+	}
+	e.Logger.Log("Executor: running interactive command via sandbox: %s", command)
+	env := e.environment(ctx)
+	var result *sandbox.CommandResult
+	err := e.Terminal.Lend(ctx, func(stdin io.Reader, stdout io.Writer) error {
+		var err error
+		result, err = e.Sandbox.Execute(ctx, sandbox.SandboxOptions{
+			Command:      command,
+			Shell:        e.Config.Sandbox.Shell,
+			WorkingDir:   e.Config.CWD,
+			AllowNetwork: e.Config.Sandbox.AllowNetwork,
+			Env:          e.Config.Sandbox.EnvMap(),
+			Stdin:        stdin,
+			Stdout:       stdout,
+			Interactive:  true,
+		})
+		return err
+	})
+	res := e.commandResult(ctx, command, result, err)
+	res.Environment = env
+	return res
+}
+
+// environment returns the snapshot of the environment commands run in, or
+// nil when SnapshotEnvironment is off. Snapshots are taken once per working
+// directory.
+func (e *Executor) environment(ctx context.Context) *sandbox.Environment {
+	if !e.SnapshotEnvironment {
+		return nil
+	}
+	e.envMu.Lock()
+	defer e.envMu.Unlock()
+	if env, ok := e.environments[e.Config.CWD]; ok {
+		return env
+	}
+	env, err := sandbox.Snapshot(ctx, e.Sandbox, e.Config.CWD)
+	if err != nil {
+		e.Logger.Log("WARN: Executor: %v", err)
+		return nil // Tried again with the next command
+	}
+	if e.environments == nil {
+		e.environments = make(map[string]*sandbox.Environment)
+	}
+	e.environments[e.Config.CWD] = env
+	return env
+}
+
+// commandResult reports the outcome of a shell command run with ctx
+func (e *Executor) commandResult(ctx context.Context, command string, result *sandbox.CommandResult, err error) *Result {
+	res := &Result{Command: command, CommandResult: result, CommandErr: err}
+	var truncated bool
+	switch {
+	case errors.Is(context.Cause(ctx), errInterrupted):
+		res.Interrupted = true
+		res.Output = "Command interrupted by the user."
+		if result != nil {
+			var output string
+			output, truncated = e.OutputLimits.Apply(result.Stdout + result.Stderr)
+			res.Output = fmt.Sprintf("Command interrupted by the user after %s. Output so far:\n%s", result.Duration.Round(time.Millisecond), output)
+		}
+	case errors.Is(context.Cause(ctx), errTimedOut):
+		res.TimedOut = true
+		data := config.MessageData{Default: "Command timed out.", Tool: "shell", Command: command}
+		if result != nil {
+			data.Duration = result.Duration.Round(time.Millisecond).String()
+			data.Output, truncated = e.OutputLimits.Apply(result.Stdout + result.Stderr)
+			data.Default = fmt.Sprintf("Command timed out and was stopped after %s. Output so far:\n%s", data.Duration, data.Output)
+		}
+		res.Output = e.Config.Messages.Render(config.MessageTimeout, data)
+	case err != nil:
+		res.Output = e.Config.Messages.Render(config.MessageRefused, config.MessageData{
+			Default: fmt.Sprintf("Execution Error: %v", err),
+			Tool:    "shell",
+			Command: command,
+			Reason:  err.Error(),
+		})
+	case result.ExitCode != 0:
+		var stderr string
+		stderr, truncated = e.OutputLimits.Apply(result.Stderr)
+		res.Output = fmt.Sprintf("Command Failed (code %d): %s", result.ExitCode, stderr)
+	default:
+		res.Output, truncated = e.OutputLimits.Apply(result.Stdout)
+		res.Success = true
+	}
+	e.Logger.Log("Executor: command finished. Success: %t, interrupted: %t, timed out: %t, output truncated: %t", res.Success, res.Interrupted, res.TimedOut, truncated)
+	return res
+}
+
+// InterruptCommand kills the shell command in progress, along with the
+// processes it started, and reports whether one was running. The command's
+// result tells the agent it was interrupted; the run goes on.
+func (e *Executor) InterruptCommand() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.interrupt == nil {
+		return false
+	}
+	e.Logger.Log("Executor: interrupting the running command")
+	e.interrupt(errInterrupted)
+	e.interrupt = nil
+	return true
+}
+
+// scriptArgs are the arguments of run_project_script
+type scriptArgs struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+}
+
+// UseScripts lets the agent run the given project scripts, registering
+// run_project_script in the executor's registry
+func (e *Executor) UseScripts(list []scripts.Script) {
+	e.Scripts = list
+	e.Registry.RegisterTool(scripts.Tool(list, e.runScriptFunction))
+}
+
+// runScriptFunction is the registry handler of run_project_script. Calls
+// made through Execute get the full command result instead.
+func (e *Executor) runScriptFunction(ctx context.Context, args string) (string, error) {
+	var params scriptArgs
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	res := e.RunScript(ctx, params.Name, params.Args)
+	if !res.Success {
+		return "", fmt.Errorf("%s", res.Output)
+	}
+	return res.Output, nil
+}
+
+// RunScript runs a project script by name in the sandbox
+func (e *Executor) RunScript(ctx context.Context, name string, args []string) *Result {
+	if name == "" {
+		return &Result{Output: fmt.Sprintf("Missing name argument for %s", scripts.ToolName)}
+	}
+	script, err := scripts.Find(e.Scripts, name)
+	if err != nil {
+		return &Result{Output: fmt.Sprintf("Error: %v. Available scripts: %s", err, strings.Join(scripts.Names(e.Scripts), ", "))}
+	}
+	command, err := script.CommandLine(args)
+	if err != nil {
+		return &Result{Output: fmt.Sprintf("Error: %v", err)}
+	}
+	e.Logger.Log("Executor: running project script %s", script.QualifiedName())
+	return e.ExecuteCommand(ctx, command)
+}
+
+// ApplyPatch parses and applies an agent patch, auto-formatting patched files
+func (e *Executor) ApplyPatch(ctx context.Context, patchContent string) *Result {
+	e.Logger.Log("Executor: applying patch. Content length: %d", len(patchContent))
+	_, span := telemetry.Start(ctx, "patch.apply", attribute.Int("codex.patch_bytes", len(patchContent)))
+	defer span.End()
+	operations, err := fileops.ParseAgentPatch(patchContent)
+	span.SetAttributes(attribute.Int("codex.files", len(operations)))
+	if err != nil {
+		telemetry.Fail(span, err)
+		return e.patchParseFailure(err)
+	}
+	e.countPatchAttempt(nil)
+
+	dryRun := e.DryRun()
+	if e.Changes != nil && !dryRun {
+		for _, op := range operations {
+			for _, path := range []string{op.Path, op.MoveTo} {
+				if path = e.resolvePath(path); path != "" {
+					e.Changes.Before(path)
+				}
+			}
+			// A file created next to an existing one goes to the first free path
+			if path := e.resolvePath(op.Path); op.Create && path != "" {
+				e.Changes.Before(fileops.FreePath(path))
+			}
+		}
+	}
+	apply := fileops.ApplyAgentPatchIn
+	if dryRun {
+		apply = fileops.PreviewAgentPatchIn
+	}
+	applyResults, applyErr := apply(e.Workspace, operations)
+	e.Logger.Log("Executor: ApplyAgentPatch finished (dry run: %t). Results count: %d, Overall error: %v", dryRun, len(applyResults), applyErr)
+
+	res := &Result{PatchResults: applyResults, DryRun: dryRun}
+	successCount, failureCount := 0, 0
+	var retryPrompts, conflicts []string
+	for _, patchRes := range applyResults {
+		if !patchRes.Success {
+			failureCount++
+			var mismatch *fileops.ContextMismatchError
+			if errors.As(patchRes.Error, &mismatch) {
+				retryPrompts = append(retryPrompts, mismatch.RetryPrompt())
+			}
+			continue
+		}
+		successCount++
+		if note := patchRes.ConflictNote(e.workspaceRoot()); note != "" {
+			conflicts = append(conflicts, note)
+		}
+		if dryRun {
+			res.Diff += e.patchDiff(patchRes)
+			continue
+		}
+		path := patchRes.Path
+		switch {
+		case patchRes.CreatedAs != "":
+			path = patchRes.CreatedAs
+		case patchRes.MovedTo != "":
+			path = patchRes.MovedTo
+		}
+		if e.Changes != nil {
+			if patchRes.CreatedAs == "" {
+				e.Changes.Written(patchRes.Path)
+			}
+			e.Changes.Written(path)
+		}
+		if formatErr := e.formatFile(ctx, path); formatErr != "" {
+			res.FormatErrors = append(res.FormatErrors, formatErr)
+		}
+	}
+
+	switch {
+	case applyErr != nil:
+		res.Output = fmt.Sprintf("Patch application finished with errors. Succeeded: %d, Failed: %d. First error: %v", successCount, failureCount, applyErr)
+	case failureCount > 0:
+		res.Output = fmt.Sprintf("Patch application finished. Succeeded: %d, Failed: %d.", successCount, failureCount)
+	default:
+		res.Output = fmt.Sprintf("Patch application finished successfully. Operations applied: %d.", successCount)
+		res.Success = true
+	}
+	if len(conflicts) > 0 {
+		res.Output += " " + strings.Join(conflicts, " ")
+	}
+	if dryRun {
+		res.Output = dryRunOutput(res.Output, res.Diff)
+	}
+	e.Logger.Log("Executor: patch application summary: %s", res.Output)
+	span.SetAttributes(attribute.Bool("codex.success", res.Success), attribute.Int("codex.files_failed", failureCount))
+	// The model sees the current content of files it failed to patch, so its
+	// next attempt can be written against it
+	for _, prompt := range retryPrompts {
+		res.Output += "\n\n" + prompt
+	}
+	return res
+}
+
+// patchDiff returns the diff of a file a patch preview would change
+func (e *Executor) patchDiff(res *fileops.AgentPatchResult) string {
+	if e.Workspace == nil {
+		return res.UnifiedDiff("")
+	}
+	return res.UnifiedDiff(e.Workspace.Root)
+}
+
+// previewWrite reports the changes a write_file or edit_lines call would
+// make, without writing the file
+func (e *Executor) previewWrite(call agent.FunctionCall) *Result {
+	diff, summary, err := e.plannedWrite(call)
+	if err != nil {
+		return &Result{Output: fmt.Sprintf("Error: %v", err)}
+	}
+	e.Logger.Log("Executor: dry run: %s", summary)
+	return &Result{Output: dryRunOutput(summary, diff), Success: true, DryRun: true, Diff: diff}
+}
+
+// WriteDiff returns the diff a write_file, edit_lines or patch_file call
+// would apply, for the user to review, or "" if it cannot tell
+func (e *Executor) WriteDiff(call agent.FunctionCall) string {
+	if call.Name == "patch_file" {
+		preview, err := e.ValidatePatch(call)
+		if err != nil {
+			return ""
+		}
+		return preview.Diff()
+	}
+	diff, _, err := e.plannedWrite(call)
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+// ValidatePatch works out what a patch_file call would do without writing
+// anything, failing if the patch cannot be applied. Paths outside the
+// allowed roots pass, as approval decides on them.
+func (e *Executor) ValidatePatch(call agent.FunctionCall) (fileops.Preview, error) {
+	defer e.AllowEscapes(e.Escapes(call))()
+	return fileops.ValidatePatchIn(e.Workspace, ApprovalArgs(call))
+}
+
+// plannedWrite works out the diff of a write_file or edit_lines call
+// without writing anything, with a sentence saying what the call would do
+func (e *Executor) plannedWrite(call agent.FunctionCall) (diff, summary string, err error) {
+	var path, after string
+	var edit functions.LineEdit
+	switch call.Name {
+	case "edit_lines":
+		if edit, err = functions.ParseLineEdit(call.Arguments); err != nil {
+			return "", "", err
+		}
+		path = edit.Path
+	default:
+		var args struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return "", "", fmt.Errorf("failed to parse arguments: %w", err)
+		}
+		if args.Path == "" {
+			return "", "", fmt.Errorf("path parameter is required")
+		}
+		path, after = args.Path, args.Content
+		summary = fmt.Sprintf("write_file would write %d bytes to %s.", len(args.Content), args.Path)
+	}
+
+	abs, err := filepath.Abs(path)
+	if e.Workspace != nil {
+		abs, err = e.Workspace.Resolve(path)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	var before *string
+	if data, err := os.ReadFile(abs); err == nil {
+		content := string(data)
+		before = &content
+	} else if !os.IsNotExist(err) || call.Name == "edit_lines" {
+		return "", "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if call.Name == "edit_lines" {
+		if after, err = edit.Apply(*before); err != nil {
+			return "", "", err
+		}
+		summary = fmt.Sprintf("edit_lines would %s.", edit.Summary())
+	}
+	return changes.Unified(e.displayPath(abs), before, &after), summary, nil
+}
+
+// dryRunOutput tells the agent that a call was only simulated, with the
+// changes it would have made
+func dryRunOutput(summary, diff string) string {
+	out := "DRY RUN: nothing was written to disk, so later reads still show the original files. Carry on as if the change had been made.\n" + summary
+	if diff == "" {
+		return out + "\nNo file would change."
+	}
+	return out + "\n\n" + strings.TrimSuffix(diff, "\n")
+}
+
+// displayPath returns path relative to the workspace root for diffs, or
+// path itself outside it
+func (e *Executor) displayPath(path string) string {
+	if e.Workspace == nil {
+		return path
+	}
+	rel, err := filepath.Rel(e.Workspace.Root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Escapes returns the canonical paths call writes outside the workspace's
+// allowed roots: the file of write_file or edit_lines, or those a
+// patch_file patch changes. Writing them needs the user's approval and AllowEscapes.
+func (e *Executor) Escapes(call agent.FunctionCall) []string {
+	if e.Workspace == nil {
+		return nil
+	}
+	var paths []string
+	switch call.Name {
+	case "write_file", "edit_lines":
+		var args struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil || args.Path == "" {
+			return nil
+		}
+		paths = []string{args.Path}
+	case "patch_file":
+		paths = fileops.PatchFiles(ApprovalArgs(call))
+	default:
+		return nil
+	}
+
+	var escapes []string
+	for _, path := range paths {
+		if resolved, outside := e.Workspace.Outside(path); outside && !slices.Contains(escapes, resolved) {
+			escapes = append(escapes, resolved)
+		}
+	}
+	return escapes
+}
+
+// AllowEscapes lets file functions write paths returned by Escapes until
+// the returned function is called
+func (e *Executor) AllowEscapes(paths []string) (revoke func()) {
+	if e.Workspace == nil || len(paths) == 0 {
+		return func() {}
+	}
+	e.Logger.Log("Executor: allowing writes outside the allowed roots: %s", strings.Join(paths, ", "))
+	return e.Workspace.Grant(paths)
+}
+
+// writtenPath returns the file call writes to, if it is write_file or
+// edit_lines and the changes are tracked
+func (e *Executor) writtenPath(call agent.FunctionCall) string {
+	if e.Changes == nil || (call.Name != "write_file" && call.Name != "edit_lines") {
+		return ""
+	}
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil || args.Path == "" {
+		return ""
+	}
+	return e.resolvePath(args.Path)
+}
+
+// resolvePath returns the absolute path a file function operates on, or ""
+// if the workspace refuses it
+func (e *Executor) resolvePath(path string) string {
+	if e.Workspace != nil {
+		resolved, err := e.Workspace.Resolve(path)
+		if err != nil {
+			return ""
+		}
+		return resolved
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	return abs
+}
+
+// patchParseFailure counts a patch that could not be parsed and returns the
+// result telling the agent where it breaks the format, asking it to stop
+// after MaxPatchAttempts such patches in a row
+func (e *Executor) patchParseFailure(err error) *Result {
+	attempts := e.countPatchAttempt(err)
+	e.Logger.Log("ERROR: Executor: failed to parse agent patch (attempt %d): %v", attempts, err)
+	res := &Result{
+		Output:        fmt.Sprintf("Error parsing patch: %v", err),
+		PatchParseErr: err,
+		PatchAttempts: attempts,
+	}
+	var formatErr *fileops.PatchFormatError
+	if errors.As(err, &formatErr) {
+		res.Output = formatErr.Feedback()
+	}
+	if attempts >= MaxPatchAttempts {
+		res.Output += fmt.Sprintf("\n\nThat makes %d patches in a row that could not be parsed. Do not call patch_file again for now: tell the user what you are trying to change and ask how to proceed.", attempts)
+	}
+	return res
+}
+
+// RefusePatch returns the result of a patch_file call refused without
+// asking the user, as ValidatePatch found it cannot be applied. The patch
+// is counted as ApplyPatch counts them, so that unparseable patches still
+// stop the agent after MaxPatchAttempts, and the result carries the
+// statistics ApplyPatch's would.
+func (e *Executor) RefusePatch(preview fileops.Preview) *Result {
+	const refused = "The patch cannot be applied, so it was not shown to the user and nothing was changed.\n\n"
+	if preview.ParseError != nil {
+		res := e.patchParseFailure(preview.ParseError)
+		res.Output = refused + res.Output
+		return res
+	}
+	e.countPatchAttempt(nil)
+	return &Result{Output: refused + preview.Feedback(), PatchResults: preview.Results}
+}
+
+// countPatchAttempt records whether a patch could be parsed, and returns the
+// number of patches in a row that could not
+func (e *Executor) countPatchAttempt(parseErr error) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if parseErr == nil {
+		e.patchFailures = 0
+	} else {
+		e.patchFailures++
+	}
+	return e.patchFailures
+}
+
+// CreateConflicts says what a patch_file call would do about the files it
+// creates that already exist, as create_conflict says
+func (e *Executor) CreateConflicts(call agent.FunctionCall) []string {
+	operations := patchOperations(call)
+	if operations == nil {
+		return nil
+	}
+	return fileops.CreateConflicts(e.Workspace, operations)
+}
+
+// Overwrites returns the canonical paths of the existing files a patch_file
+// call replaces whole, by creating them with create_conflict set to
+// overwrite. Patching them needs the user's approval in every mode.
+func (e *Executor) Overwrites(call agent.FunctionCall) []string {
+	if e.Workspace == nil || e.Workspace.OnCreate != fileops.OverwriteExisting {
+		return nil
+	}
+	operations := patchOperations(call)
+	if operations == nil {
+		return nil
+	}
+	return fileops.ExistingCreates(e.Workspace, operations)
+}
+
+// patchOperations parses the patch of a patch_file call, or returns nil for
+// other calls and patches that do not parse
+func patchOperations(call agent.FunctionCall) []fileops.AgentPatchOperation {
+	if call.Name != "patch_file" {
+		return nil
+	}
+	operations, err := fileops.ParseAgentPatch(ApprovalArgs(call))
+	if err != nil {
+		return nil
+	}
+	return operations
+}
+
+// workspaceRoot is the root paths are reported relative to, or "" if file
+// operations are not confined
+func (e *Executor) workspaceRoot() string {
+	if e.Workspace == nil {
+		return ""
+	}
+	return e.Workspace.Root
+}
+
+// formatFile runs the formatter for a patched file, returning an error message on failure
+func (e *Executor) formatFile(ctx context.Context, path string) string {
+	argv := FormatterCommand(path)
+	if argv == nil {
+		e.Logger.Log("Executor: no formatter identified for %s, skipping auto-format.", path)
+		return ""
+	}
+
+	// The formatter is run directly rather than through a shell, so that no
+	// character of a path the model chose is interpreted
+	e.Logger.Log("Executor: auto-formatting %s with command: %q", path, argv)
+	formatCtx, cancel := context.WithTimeout(ctx, formatTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(formatCtx, argv[0], argv[1:]...)
+	cmd.Dir = e.Config.CWD
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	formatErr := cmd.Run()
+	if formatErr == nil {
+		e.Logger.Log("Executor: successfully auto-formatted %s.", path)
+		return ""
+	}
+
+	msg := fmt.Sprintf("Auto-formatting failed for %s.", path)
+	var exitErr *exec.ExitError
+	if errors.As(formatErr, &exitErr) {
+		msg = fmt.Sprintf("%s Exit Code: %d, Stderr: %s", msg, exitErr.ExitCode(), stderr.String())
+	} else {
+		msg = fmt.Sprintf("%s Error: %v", msg, formatErr)
+	}
+	e.Logger.Log("ERROR: %s", msg)
+	return msg
+}
+
+// FormatterCommand returns a suitable formatting command for a given file
+// path based on its extension, as the program and its arguments, the path
+// last. Returns nil if no suitable formatter is known.
+func FormatterCommand(filePath string) []string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+
+	switch ext {
+	case ".go":
+		// gofmt is built-in and standard
+		return []string{"gofmt", "-w", filePath}
+	case ".py":
+		// black is a very common and opinionated formatter
+		return []string{"black", "--quiet", filePath}
+	case ".js", ".jsx", ".ts", ".tsx", ".json", ".css", ".scss", ".html", ".yaml", ".yml", ".md":
+		// prettier is common for web/config files
+		return []string{"prettier", "--write", "--log-level=warn", filePath}
+	// Add cases for other languages as needed (e.g., rustfmt, clang-format)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,714 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/changes"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/fileops"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/instructions"
+	"github.com/epuerta/codex-go/internal/sandbox"
+	"github.com/epuerta/codex-go/internal/scripts"
+)
+
+func TestNeedsApproval(t *testing.T) {
+	tests := []struct {
+		mode     config.ApprovalMode
+		function string
+		want     bool
+	}{
+		{config.Suggest, "read_file", false},
+		{config.Suggest, "list_directory", false},
+		{config.Suggest, "search_code", false},
+		{config.Suggest, "semantic_search", false},
+		{config.Suggest, "patch_file", true},
+		{config.Suggest, "shell", true},
+		{config.AutoEdit, "patch_file", false},
+		{config.AutoEdit, "execute_command", true},
+		{config.AutoEdit, "shell", true},
+		{config.AutoEdit, "run_project_script", true},
+		{config.Suggest, "fetch_url", true},
+		{config.AutoEdit, "fetch_url", false},
+		{config.Suggest, "present_choices", false},
+		{config.FullAuto, "shell", false},
+		{config.DangerousAutoApprove, "write_file", false},
+		{config.FullAuto, "remember", true},
+		{config.DangerousAutoApprove, "remember", false},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsApproval(tt.mode, tt.function); got != tt.want {
+			t.Errorf("NeedsApproval(%s, %s) = %t, want %t", tt.mode, tt.function, got, tt.want)
+		}
+	}
+}
+
+func TestApprovalReason(t *testing.T) {
+	tests := []struct {
+		mode     config.ApprovalMode
+		function string
+		want     string
+	}{
+		{config.Suggest, "read_file", "read-only"},
+		{config.Suggest, "shell", "suggest mode asks before changes"},
+		{config.AutoEdit, "run_project_script", "auto-edit mode asks before commands"},
+		{config.AutoEdit, "write_file", "auto-edit mode applies edits"},
+		{config.FullAuto, "remember", "notes outlive the session"},
+		{config.DangerousAutoApprove, "remember", "dangerous mode skips every approval"},
+	}
+	for _, tt := range tests {
+		if got := ApprovalReason(tt.mode, tt.function); got != tt.want {
+			t.Errorf("ApprovalReason(%s, %s) = %q, want %q", tt.mode, tt.function, got, tt.want)
+		}
+	}
+}
+
+func TestApprovalArgs(t *testing.T) {
+	call := agent.FunctionCall{Name: "shell", Arguments: `{"command":"ls -la"}`}
+	if got := ApprovalArgs(call); got != "ls -la" {
+		t.Errorf("Expected command to be extracted, got %q", got)
+	}
+
+	call = agent.FunctionCall{Name: "read_file", Arguments: `{"path":"main.go"}`}
+	if got := ApprovalArgs(call); got != call.Arguments {
+		t.Errorf("Expected raw arguments for read_file, got %q", got)
+	}
+
+	call = agent.FunctionCall{Name: "run_project_script", Arguments: `{"name":"test","args":["VERBOSE=1"]}`}
+	if got := ApprovalArgs(call); got != "test VERBOSE=1" {
+		t.Errorf("Expected script and arguments, got %q", got)
+	}
+
+	call = agent.FunctionCall{Name: "remember", Arguments: `{"note":"Tests run with make check"}`}
+	if got := ApprovalArgs(call); got != "Tests run with make check" {
+		t.Errorf("Expected the note, got %q", got)
+	}
+
+	call = agent.FunctionCall{Name: "fetch_url", Arguments: `{"url":"https://go.dev/doc"}`}
+	if got := ApprovalArgs(call); got != "https://go.dev/doc" {
+		t.Errorf("Expected the URL, got %q", got)
+	}
+
+	call = agent.FunctionCall{Name: "edit_lines", Arguments: `{"path":"main.go","start_line":3,"end_line":4,"replacement":"x := 1\n"}`}
+	if got := ApprovalArgs(call); got != "x := 1\n" {
+		t.Errorf("Expected the replacement, got %q", got)
+	}
+}
+
+func TestWithPatch(t *testing.T) {
+	call := agent.FunctionCall{ID: "call_1", Name: "patch_file", Arguments: `{"patch_content":"old","explanation":"why"}`}
+	got, err := WithPatch(call, "new")
+	if err != nil {
+		t.Fatalf("WithPatch failed: %v", err)
+	}
+	if got.ID != "call_1" || ApprovalArgs(got) != "new" || !strings.Contains(got.Arguments, `"explanation":"why"`) {
+		t.Errorf("Unexpected call %+v", got)
+	}
+	if ApprovalArgs(call) != "old" {
+		t.Error("Expected the original call to be unchanged")
+	}
+}
+
+func TestFormatPathsAreNotInterpreted(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt is not installed")
+	}
+	dir := t.TempDir()
+	e := New(&config.Config{CWD: dir}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	for _, name := range []string{"$(touch injected).go", "`touch injected`.go", "$HOME.go", "a'b.go"} {
+		if argv := FormatterCommand(name); len(argv) == 0 || argv[len(argv)-1] != name {
+			t.Errorf("Expected %q to be passed as is, got %q", name, argv)
+		}
+		res := e.ApplyPatch(context.Background(), "// FILE: "+name+"\n// EDIT: create\nADD: package a\nADD: func  f() {}\n// END_EDIT")
+		if !res.Success || len(res.FormatErrors) > 0 {
+			t.Fatalf("Expected %q to be patched and formatted, got %q %v", name, res.Output, res.FormatErrors)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != "package a\n\nfunc f() {}\n" {
+			t.Errorf("Expected %q to be formatted, got %q", name, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "injected")); !os.IsNotExist(err) {
+		t.Error("Expected no command in a path to run")
+	}
+}
+
+func TestApplyPatchCreateConflict(t *testing.T) {
+	dir := t.TempDir()
+	e := New(&config.Config{CWD: dir, CreateConflict: "rename"}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := "// FILE: a.txt\n// EDIT: create\nADD: new\n// END_EDIT"
+	args, _ := json.Marshal(map[string]string{"patch_content": patch})
+	conflicts := e.CreateConflicts(agent.FunctionCall{Name: "patch_file", Arguments: string(args)})
+	if len(conflicts) != 1 || !strings.Contains(conflicts[0], "a-1.txt") {
+		t.Errorf("Expected the rename to be predicted, got %q", conflicts)
+	}
+	res := e.ApplyPatch(context.Background(), patch)
+	if !res.Success || !strings.Contains(res.Output, "a.txt already exists, so the new file is written to a-1.txt.") {
+		t.Fatalf("Expected the new file to be written next to a.txt, got %q", res.Output)
+	}
+	for name, want := range map[string]string{"a.txt": "old", "a-1.txt": "new\n"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != want {
+			t.Errorf("Expected %s to be %q, got %q", name, want, data)
+		}
+	}
+}
+
+func TestPreviewArgs(t *testing.T) {
+	tests := []struct {
+		name, args, want string
+	}{
+		{"patch_file", `{"patch_content":"*** Begin Patch\n*** Update File: a.go\n@@ \"x`, "*** Begin Patch\n*** Update File: a.go\n@@ \"x"},
+		{"patch_file", `{"patch_content":"line\`, "line"},
+		{"write_file", `{"path":"a.txt","content":"caf\u00`, "caf"},
+		{"write_file", `{"path":"a.txt","content":"done","mode":`, "done"},
+		{"shell", `{"command":"ls -la"}`, "ls -la"},
+		{"shell", `{"comm`, `{"comm`},
+		{"read_file", `{"path":"main`, `{"path":"main`},
+	}
+	for _, tt := range tests {
+		if got := PreviewArgs(agent.FunctionCall{Name: tt.name, Arguments: tt.args}); got != tt.want {
+			t.Errorf("PreviewArgs(%s, %q) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "codex-executor-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	registry := functions.NewRegistry()
+	registry.Register("read_file", functions.ReadFile)
+	e := New(&config.Config{CWD: tmpDir}, sandbox.NewBasicSandbox(), registry, nil)
+	ctx := context.Background()
+
+	res := e.Execute(ctx, agent.FunctionCall{Name: "shell", Arguments: `{"command":"echo hello"}`})
+	if !res.Success || strings.TrimSpace(res.Output) != "hello" {
+		t.Errorf("Expected successful echo, got success=%t output=%q", res.Success, res.Output)
+	}
+
+	res = e.Execute(ctx, agent.FunctionCall{Name: "execute_command", Arguments: `{"command":"exit 3"}`})
+	if res.Success || res.CommandResult == nil || res.CommandResult.ExitCode != 3 {
+		t.Errorf("Expected failure with exit code 3, got %+v", res)
+	}
+
+	path := filepath.Join(tmpDir, "note.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	res = e.Execute(ctx, agent.FunctionCall{Name: "read_file", Arguments: `{"path":"` + path + `"}`})
+	if !res.Success || res.Output != "1\tcontent\n" {
+		t.Errorf("Expected file content, got success=%t output=%q", res.Success, res.Output)
+	}
+
+	patch := "// FILE: " + path + "\n// EDIT: rename\nDEL: old content\nADD: new content\n// END_EDIT"
+	args, _ := json.Marshal(map[string]string{"patch_content": patch})
+	res = e.Execute(ctx, agent.FunctionCall{Name: "patch_file", Arguments: string(args)})
+	if res.Success || !strings.Contains(res.Output, "DEL lines match no line") || !strings.Contains(res.Output, "lines 1-1") {
+		t.Errorf("Expected the current content to be shown for a stale patch, got %q", res.Output)
+	}
+
+	e.UseScripts([]scripts.Script{{Name: "greet", Source: "make", Command: "echo greet"}})
+	if tool, ok := registry.Lookup(scripts.ToolName); !ok || tool.Parameters == nil {
+		t.Errorf("Expected %s to be registered with a schema", scripts.ToolName)
+	}
+	res = e.Execute(ctx, agent.FunctionCall{Name: "run_project_script", Arguments: `{"name":"greet","args":["WHO=you"]}`})
+	if !res.Success || strings.TrimSpace(res.Output) != "greet WHO=you" {
+		t.Errorf("Expected the script to run, got success=%t output=%q", res.Success, res.Output)
+	}
+	res = e.Execute(ctx, agent.FunctionCall{Name: "run_project_script", Arguments: `{"name":"deploy"}`})
+	if res.Success || res.Command != "" || !strings.Contains(res.Output, "Available scripts: greet") {
+		t.Errorf("Expected an unknown script to be refused, got %+v", res)
+	}
+
+	res = e.Execute(ctx, agent.FunctionCall{Name: "unknown_tool", Arguments: `{}`})
+	if res.Success {
+		t.Errorf("Expected unknown function to fail")
+	}
+}
+
+func TestApplyPatchFormatErrors(t *testing.T) {
+	dir := t.TempDir()
+	e := New(&config.Config{CWD: dir}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	ctx := context.Background()
+	path := filepath.Join(dir, "a.txt")
+	malformed := "// FILE: " + path + "\n// EDIT: add\n+ new line\n// END_EDIT"
+
+	for attempt := 1; attempt <= MaxPatchAttempts; attempt++ {
+		res := e.ApplyPatch(ctx, malformed)
+		if res.Success || res.PatchAttempts != attempt || !strings.Contains(res.Output, "line 3") || !strings.Contains(res.Output, fileops.PatchFormatReminder) {
+			t.Fatalf("Attempt %d: unexpected result %+v", attempt, res)
+		}
+		if escalated := strings.Contains(res.Output, "ask how to proceed"); escalated != (attempt == MaxPatchAttempts) {
+			t.Errorf("Attempt %d: escalated=%t", attempt, escalated)
+		}
+	}
+
+	// A patch that parses starts the count again
+	if res := e.ApplyPatch(ctx, "// FILE: "+path+"\n// EDIT: add\nADD: new line\n// END_EDIT"); !res.Success {
+		t.Fatalf("Expected the patch to apply, got %q", res.Output)
+	}
+	if res := e.ApplyPatch(ctx, malformed); res.PatchAttempts != 1 {
+		t.Errorf("Expected the count to restart, got %d", res.PatchAttempts)
+	}
+}
+
+func TestRefusePatch(t *testing.T) {
+	dir := t.TempDir()
+	e := New(&config.Config{CWD: dir}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	call := func(patch string) agent.FunctionCall {
+		args, _ := json.Marshal(map[string]string{"patch_content": patch})
+		return agent.FunctionCall{Name: "patch_file", Arguments: string(args)}
+	}
+	malformed := call("// FILE: a.txt\n// EDIT: add\n+ new line\n// END_EDIT")
+
+	// Refused patches count towards the escalation as applied ones do
+	for attempt := 1; attempt <= MaxPatchAttempts; attempt++ {
+		preview, err := e.ValidatePatch(malformed)
+		if err == nil {
+			t.Fatal("Expected the patch to be invalid")
+		}
+		res := e.RefusePatch(preview)
+		if res.PatchParseErr == nil || res.PatchAttempts != attempt || !strings.Contains(res.Output, "nothing was changed") {
+			t.Fatalf("Attempt %d: unexpected result %+v", attempt, res)
+		}
+		if escalated := strings.Contains(res.Output, "ask how to proceed"); escalated != (attempt == MaxPatchAttempts) {
+			t.Errorf("Attempt %d: escalated=%t", attempt, escalated)
+		}
+	}
+
+	// A patch that parses but does not match restarts the count, and
+	// carries its statistics
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	preview, _ := e.ValidatePatch(call("// FILE: a.txt\n// EDIT: fix\nDEL: two\nADD: 2\n// END_EDIT"))
+	if res := e.RefusePatch(preview); len(res.PatchResults) != 1 || res.PatchResults[0].MissedLines != 1 || !strings.Contains(res.Output, "two") {
+		t.Errorf("Unexpected result %+v", res)
+	}
+	if res := e.ApplyPatch(context.Background(), "// FILE: a.txt\n// EDIT: add\n+ new line\n// END_EDIT"); res.PatchAttempts != 1 {
+		t.Errorf("Expected the count to restart, got %d", res.PatchAttempts)
+	}
+}
+
+func TestTrackChanges(t *testing.T) {
+	dir := t.TempDir()
+	registry := functions.NewRegistry()
+	e := New(&config.Config{CWD: dir}, sandbox.NewBasicSandbox(), registry, nil)
+	functions.FileFunctions{Workspace: e.Workspace}.Register(registry)
+	e.Changes = changes.NewTracker(dir)
+	ctx := context.Background()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.txt"), []byte("x\ny\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := e.ApplyPatch(ctx, "// FILE: a.txt\n// EDIT: add\nADD: two\n// END_EDIT"); !res.Success {
+		t.Fatalf("Expected the patch to apply, got %q", res.Output)
+	}
+	if res := e.Execute(ctx, agent.FunctionCall{Name: "write_file", Arguments: `{"path":"b.txt","content":"new\n"}`}); !res.Success {
+		t.Fatalf("Expected write_file to succeed, got %q", res.Output)
+	}
+	if res := e.Execute(ctx, agent.FunctionCall{Name: "edit_lines", Arguments: `{"path":"c.txt","start_line":2,"end_line":2,"replacement":"z"}`}); !res.Success {
+		t.Fatalf("Expected edit_lines to succeed, got %q", res.Output)
+	}
+	// A refused patch records nothing
+	e.ApplyPatch(ctx, "// FILE: ../outside.txt\n// EDIT: add\nADD: x\n// END_EDIT")
+
+	if files := e.Changes.Files(); strings.Join(files, ",") != "a.txt,b.txt,c.txt" {
+		t.Errorf("Unexpected files: %v", files)
+	}
+	diff, err := e.Changes.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+++ b/a.txt\n") || !strings.Contains(diff, "+two\n") || !strings.Contains(diff, "--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1,1 @@\n+new\n") || !strings.Contains(diff, "-y\n+z\n") {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"AGENTS.md":           "Root rules",
+		"api/AGENTS.md":       "API rules",
+		"api/codex.md":        "More API rules",
+		"api/handler.go":      "package api\n",
+		"web/AGENTS.md":       strings.Repeat("Long web rules. ", 100),
+		"web/index.html":      "<html>\n",
+		"docs/AGENTS.md":      "Docs rules",
+		"docs/guide/intro.md": "# Intro\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := functions.NewRegistry()
+	e := New(&config.Config{CWD: dir}, sandbox.NewBasicSandbox(), registry, nil)
+	functions.FileFunctions{Workspace: e.Workspace}.Register(registry)
+	e.GuardOutput = true
+	e.Instructions = instructions.NewTracker(dir, 100)
+	e.Instructions.MarkSent(filepath.Join(dir, "AGENTS.md")) // In the repository context
+	ctx := context.Background()
+	read := func(path string) (*Result, string) {
+		call := agent.FunctionCall{Name: "read_file", ID: "c1", Arguments: `{"path":"` + path + `"}`}
+		res := e.Execute(ctx, call)
+		return res, e.AgentOutput(call, res)
+	}
+
+	// The files of the directories down to the file, after the guarded output
+	res, output := read("api/handler.go")
+	if !strings.Contains(res.Instructions, "--- api/AGENTS.md (applies to api/) ---\nAPI rules") || !strings.Contains(res.Instructions, "api/codex.md") || strings.Contains(res.Instructions, "Root rules") {
+		t.Errorf("Unexpected instructions: %q", res.Instructions)
+	}
+	if !strings.HasSuffix(output, res.Instructions) || strings.Index(output, "package api") > strings.Index(output, "API rules") {
+		t.Errorf("Expected the instructions after the output, got %q", output)
+	}
+	if res, _ := read("api/handler.go"); res.Instructions != "" {
+		t.Errorf("Expected the instructions only once, got %q", res.Instructions)
+	}
+
+	// Files past the budget are named instead
+	if res, _ := read("web/index.html"); !strings.Contains(res.Instructions, "left out for lack of room: web/AGENTS.md") {
+		t.Errorf("Expected web/AGENTS.md left out, got %q", res.Instructions)
+	}
+
+	// Patches bring those of the files they change
+	res = e.Execute(ctx, agent.FunctionCall{Name: "patch_file", Arguments: `{"patch_content":"// FILE: docs/guide/intro.md\n// EDIT: add\nADD: More\n// END_EDIT"}`})
+	if !res.Success || !strings.Contains(res.Instructions, "docs/AGENTS.md (applies to docs/)") {
+		t.Errorf("Expected the docs instructions, got %q (%s)", res.Instructions, res.Output)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	registry := functions.NewRegistry()
+	e := New(&config.Config{CWD: dir, DryRun: true}, sandbox.NewBasicSandbox(), registry, nil)
+	functions.FileFunctions{Workspace: e.Workspace}.Register(registry)
+	e.Changes = changes.NewTracker(dir)
+	ctx := context.Background()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := e.ApplyPatch(ctx, "// FILE: a.txt\n// EDIT: replace\nDEL: one\nADD: two\n// END_EDIT")
+	if !res.Success || !res.DryRun || !strings.HasPrefix(res.Output, "DRY RUN") {
+		t.Fatalf("Expected a simulated patch, got %+v", res)
+	}
+	if !strings.Contains(res.Diff, "+++ b/a.txt\n") || !strings.Contains(res.Diff, "-one\n+two\n") || !strings.Contains(res.Output, "-one\n+two") {
+		t.Errorf("Expected the diff in the result, got:\n%s", res.Output)
+	}
+	res = e.Execute(ctx, agent.FunctionCall{Name: "write_file", Arguments: `{"path":"sub/b.txt","content":"new\n"}`})
+	if !res.Success || !res.DryRun || !strings.Contains(res.Diff, "--- /dev/null\n+++ b/sub/b.txt\n") {
+		t.Fatalf("Expected a simulated write, got %+v", res)
+	}
+	if res := e.Execute(ctx, agent.FunctionCall{Name: "write_file", Arguments: `{"path":"../c.txt","content":"x"}`}); res.Success {
+		t.Errorf("Expected a simulated write outside the workspace to fail, got %q", res.Output)
+	}
+	edit := agent.FunctionCall{Name: "edit_lines", Arguments: `{"path":"a.txt","start_line":1,"end_line":1,"replacement":"uno"}`}
+	res = e.Execute(ctx, edit)
+	if !res.Success || !res.DryRun || !strings.Contains(res.Output, "edit_lines would replace line 1 of a.txt with 1 line(s).") || !strings.Contains(res.Diff, "+uno") {
+		t.Fatalf("Expected a simulated line edit, got %+v", res)
+	}
+	if diff := e.WriteDiff(edit); diff != res.Diff {
+		t.Errorf("Expected WriteDiff to match the dry run, got:\n%s", diff)
+	}
+	if res := e.Execute(ctx, agent.FunctionCall{Name: "edit_lines", Arguments: `{"path":"a.txt","start_line":5,"end_line":5,"replacement":"x"}`}); res.Success {
+		t.Errorf("Expected a simulated edit past the end of the file to fail, got %q", res.Output)
+	}
+	move := agent.FunctionCall{Name: "patch_file", Arguments: `{"patch_content":"// FILE: a.txt\n// MOVE: moved.txt"}`}
+	res = e.Execute(ctx, move)
+	if !res.Success || !strings.Contains(res.Diff, "--- a/a.txt\n+++ /dev/null\n") || !strings.Contains(res.Diff, "--- /dev/null\n+++ b/moved.txt\n") {
+		t.Fatalf("Expected a simulated move as a deletion and a creation, got %+v", res)
+	}
+	if diff := e.WriteDiff(move); diff != res.Diff {
+		t.Errorf("Expected WriteDiff to match the dry run, got:\n%s", diff)
+	}
+
+	// Nothing was written or recorded
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "one" {
+		t.Errorf("Expected a.txt to be unchanged, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub")); !os.IsNotExist(err) {
+		t.Errorf("Expected sub/b.txt not to be created, got %v", err)
+	}
+	if files := e.Changes.Files(); len(files) != 0 {
+		t.Errorf("Expected no recorded changes, got %v", files)
+	}
+
+	e.SetDryRun(false)
+	if res := e.Execute(ctx, agent.FunctionCall{Name: "write_file", Arguments: `{"path":"b.txt","content":"new\n"}`}); !res.Success || res.DryRun {
+		t.Fatalf("Expected a real write, got %+v", res)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "b.txt")); string(data) != "new\n" {
+		t.Errorf("Expected b.txt to be written, got %q", data)
+	}
+}
+
+// signalWriter reports each write on a channel
+type signalWriter chan string
+
+func (w signalWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestInterruptCommand(t *testing.T) {
+	e := New(&config.Config{CWD: t.TempDir()}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	if e.InterruptCommand() {
+		t.Error("Expected no command to interrupt")
+	}
+
+	output := make(signalWriter, 10)
+	e.Stdout = output
+	done := make(chan *Result, 1)
+	start := time.Now()
+	go func() {
+		done <- e.ExecuteCommand(context.Background(), "echo started; sleep 30; echo finished")
+	}()
+
+	// The output streams before the command ends
+	select {
+	case chunk := <-output:
+		if chunk != "started\n" {
+			t.Errorf("Unexpected output %q", chunk)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the output to stream while the command runs")
+	}
+	if !e.InterruptCommand() {
+		t.Fatal("Expected the running command to be interrupted")
+	}
+
+	res := <-done
+	if res.Success || !res.Interrupted || !strings.Contains(res.Output, "interrupted by the user") || !strings.Contains(res.Output, "started") {
+		t.Errorf("Unexpected result for an interrupted command: %+v", res)
+	}
+	// The sleep started by the shell is killed as well, rather than holding
+	// the output open until the wait delay passes
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the command to stop at once, took %s", elapsed)
+	}
+	if e.InterruptCommand() {
+		t.Error("Expected nothing left to interrupt")
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	e := New(&config.Config{CWD: t.TempDir(), CommandTimeout: 1, MaxCommandTimeout: 2}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	if e.CommandTimeout != time.Second || e.MaxCommandTimeout != 2*time.Second {
+		t.Fatalf("Expected timeouts from the config, got %s and %s", e.CommandTimeout, e.MaxCommandTimeout)
+	}
+	for _, tt := range []struct {
+		seconds int
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{2, 2 * time.Second},
+		{60, 2 * time.Second},
+	} {
+		if got := e.commandTimeout(tt.seconds); got != tt.want {
+			t.Errorf("commandTimeout(%d) = %s, want %s", tt.seconds, got, tt.want)
+		}
+	}
+
+	e.CommandTimeout = 100 * time.Millisecond
+	res := e.ExecuteCommand(context.Background(), "echo started; sleep 30")
+	if res.Success || !res.TimedOut || res.Interrupted || !strings.Contains(res.Output, "timed out") || !strings.Contains(res.Output, "started") {
+		t.Errorf("Unexpected result for a command that timed out: %+v", res)
+	}
+
+	// A configured message replaces the default one
+	e.Config.Messages.Timeout = "`{{.Command}}` was stopped after {{.Duration}}; run it in the background instead. Output so far:\n{{.Output}}"
+	res = e.ExecuteCommand(context.Background(), "echo started; sleep 30")
+	if !res.TimedOut || !strings.HasPrefix(res.Output, "`echo started; sleep 30` was stopped after 1") || !strings.HasSuffix(res.Output, "Output so far:\nstarted\n") {
+		t.Errorf("Expected the configured timeout message, got %q", res.Output)
+	}
+	e.Config.Messages.Timeout = ""
+
+	// A longer timeout asked for in the call lets the command finish
+	call := agent.FunctionCall{Name: "shell", Arguments: `{"command":"sleep 0.3; echo done","timeout":2}`}
+	if res := e.Execute(context.Background(), call); !res.Success || strings.TrimSpace(res.Output) != "done" {
+		t.Errorf("Expected the command to finish within the requested timeout, got %+v", res)
+	}
+}
+
+// fakeTerminal lends fixed input and collects the output
+type fakeTerminal struct {
+	input  string
+	output strings.Builder
+	lent   int
+}
+
+func (t *fakeTerminal) Lend(ctx context.Context, run func(stdin io.Reader, stdout io.Writer) error) error {
+	t.lent++
+	return run(strings.NewReader(t.input), &t.output)
+}
+
+func TestExecuteInteractiveCommand(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("interactive commands need a pseudo-terminal")
+	}
+	e := New(&config.Config{CWD: t.TempDir()}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	call := agent.FunctionCall{Name: "shell", Arguments: `{"command":"test -t 0 && read answer && echo \"got $answer\"","interactive":true}`}
+
+	// Without a terminal to lend, the agent is told to do without
+	res := e.Execute(context.Background(), call)
+	if res.Success || res.Command != "" || !strings.Contains(res.Output, "not available") {
+		t.Errorf("Expected the interactive command to be refused, got %+v", res)
+	}
+
+	terminal := &fakeTerminal{input: "yes\n"}
+	e.Terminal = terminal
+	res = e.Execute(context.Background(), call)
+	if !res.Success || !strings.Contains(res.Output, "got yes") {
+		t.Errorf("Expected the command to read from the terminal, got %+v", res)
+	}
+	if terminal.lent != 1 || !strings.Contains(terminal.output.String(), "got yes") {
+		t.Errorf("Expected the output on the lent terminal, got %q after %d loans", terminal.output.String(), terminal.lent)
+	}
+}
+
+func TestSnapshotEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	e := New(&config.Config{CWD: dir}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	if res := e.ExecuteCommand(context.Background(), "true"); res.Environment != nil {
+		t.Errorf("Expected no snapshot unless asked for, got %+v", res.Environment)
+	}
+
+	e.SnapshotEnvironment = true
+	res := e.ExecuteCommand(context.Background(), "false")
+	env := res.Environment
+	if env == nil {
+		t.Fatal("Expected the failed command's environment to be recorded")
+	}
+	// The PATH is the sandbox's, not this process's
+	if env.Path != "/usr/local/bin:/usr/bin:/bin" || env.WorkingDir != dir || env.Sandbox != e.Sandbox.Name() || env.OS != runtime.GOOS {
+		t.Errorf("Unexpected environment: %+v", env)
+	}
+	for name, version := range env.Tools {
+		if version == "" || strings.Contains(version, "\n") {
+			t.Errorf("Unexpected version %q for %s", version, name)
+		}
+	}
+
+	// The snapshot is taken once per working directory
+	if again := e.ExecuteCommand(context.Background(), "true"); again.Environment != env {
+		t.Error("Expected the snapshot to be reused")
+	}
+}
+
+func TestCommandOutputLimits(t *testing.T) {
+	e := New(&config.Config{CWD: t.TempDir(), OutputHeadLines: 2, OutputTailLines: 1}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	res := e.ExecuteCommand(context.Background(), "seq 10")
+	if want := "1\n2\n... [7 lines omitted] ...\n10\n"; res.Output != want {
+		t.Errorf("Expected the agent output to be truncated to %q, got %q", want, res.Output)
+	}
+	// The full output is kept for the UI
+	if res.CommandResult == nil || strings.Count(res.CommandResult.Stdout, "\n") != 10 {
+		t.Errorf("Expected the full output in the command result, got %+v", res.CommandResult)
+	}
+
+	res = e.ExecuteCommand(context.Background(), "seq 10 >&2; exit 3")
+	if !strings.HasPrefix(res.Output, "Command Failed (code 3): 1\n2\n... [7 lines omitted]") {
+		t.Errorf("Expected the error output to be truncated, got %q", res.Output)
+	}
+}
+
+func TestExecuteSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	e := New(&config.Config{CWD: t.TempDir()}, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+	e.Execute(context.Background(), agent.FunctionCall{ID: "call_1", Name: "shell", Arguments: `{"command":"exit 2"}`})
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "sandbox.execute" || spans[1].Name() != "executor.execute" {
+		t.Fatalf("Expected a sandbox.execute span in executor.execute, got %d spans", len(spans))
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("Expected sandbox.execute to be a child of executor.execute")
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["codex.exit_code"].AsInt64() != 2 || attrs["codex.success"].AsBool() {
+		t.Errorf("Expected the span to record the exit code, got %v", spans[0].Attributes())
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	// The repository root is allowed by default, even above the working directory
+	repo := t.TempDir()
+	cwd := filepath.Join(repo, "cmd")
+	outside := t.TempDir()
+	for _, dir := range []string{filepath.Join(repo, ".git"), cwd} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := functions.NewRegistry()
+	e := New(&config.Config{CWD: cwd}, sandbox.NewBasicSandbox(), registry, nil)
+	functions.FileFunctions{Workspace: e.Workspace}.Register(registry)
+	outside, _ = filepath.EvalSymlinks(outside)
+	escape := filepath.Join(outside, "notes.txt")
+
+	inRepo := agent.FunctionCall{Name: "write_file", Arguments: `{"path":"../README.md","content":"hi"}`}
+	if escapes := e.Escapes(inRepo); len(escapes) != 0 {
+		t.Errorf("Expected a write in the repository not to escape, got %v", escapes)
+	}
+	write := agent.FunctionCall{Name: "write_file", Arguments: `{"path":"` + escape + `","content":"hi"}`}
+	patch := agent.FunctionCall{Name: "patch_file", Arguments: `{"patch_content":"// FILE: main.go\n// EDIT: add\nADD: x\n// END_EDIT\n// FILE: ` + escape + `\n// EDIT: add\nADD: x\n// END_EDIT"}`}
+	for _, call := range []agent.FunctionCall{write, patch} {
+		if escapes := e.Escapes(call); len(escapes) != 1 || escapes[0] != escape {
+			t.Errorf("Expected %s to escape to %s, got %v", call.Name, escape, escapes)
+		}
+	}
+	if escapes := e.Escapes(agent.FunctionCall{Name: "read_file", Arguments: `{"path":"` + escape + `"}`}); len(escapes) != 0 {
+		t.Errorf("Expected reads never to escape, got %v", escapes)
+	}
+
+	// The write is refused until it is allowed
+	ctx := context.Background()
+	if res := e.Execute(ctx, write); res.Success {
+		t.Fatalf("Expected the write outside the repository to be refused, got %q", res.Output)
+	}
+	revoke := e.AllowEscapes(e.Escapes(write))
+	if res := e.Execute(ctx, write); !res.Success {
+		t.Fatalf("Expected the allowed write to succeed, got %q", res.Output)
+	}
+	revoke()
+	if res := e.Execute(ctx, write); res.Success {
+		t.Errorf("Expected the write to be refused again, got %q", res.Output)
+	}
+}
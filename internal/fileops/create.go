@@ -0,0 +1,136 @@
+package fileops
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CreateConflict says what a block creating a file, headed // EDIT: create,
+// does when the file already exists
+type CreateConflict int
+
+const (
+	// FailOnExisting fails the patch for the file, leaving it unchanged
+	FailOnExisting CreateConflict = iota
+	// OverwriteExisting replaces the file with the new content
+	OverwriteExisting
+	// RenameNew writes the new file next to the existing one, with a
+	// numeric suffix such as config-1.yaml
+	RenameNew
+)
+
+// ParseCreateConflict parses a strategy name: "fail" (the default when
+// empty), "overwrite" or "rename"
+func ParseCreateConflict(name string) (CreateConflict, error) {
+	switch name {
+	case "", "fail":
+		return FailOnExisting, nil
+	case "overwrite":
+		return OverwriteExisting, nil
+	case "rename":
+		return RenameNew, nil
+	}
+	return 0, fmt.Errorf("unknown create conflict strategy %q (want fail, overwrite or rename)", name)
+}
+
+// String names the strategy, as in the configuration
+func (c CreateConflict) String() string {
+	switch c {
+	case OverwriteExisting:
+		return "overwrite"
+	case RenameNew:
+		return "rename"
+	default:
+		return "fail"
+	}
+}
+
+// isCreateHeader reports whether the header of an // EDIT: block says it
+// creates its file: "create", possibly followed by a description
+func isCreateHeader(header string) bool {
+	word, _, _ := strings.Cut(header, " ")
+	return strings.EqualFold(word, "create")
+}
+
+// FreePath returns the first of path, path-1, path-2 and so on, the number
+// going before the extension, that names no file
+func FreePath(path string) string {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// CreateConflicts says what applying operations in ws would do about the
+// files they create that already exist, for the user to see before
+// approving the patch. A nil ws uses the paths as given and fails on them.
+func CreateConflicts(ws *Workspace, operations []AgentPatchOperation) []string {
+	onCreate, root := FailOnExisting, ""
+	if ws != nil {
+		onCreate, root = ws.OnCreate, ws.Root
+	}
+	var conflicts []string
+	for _, path := range ExistingCreates(ws, operations) {
+		res := &AgentPatchResult{Path: path}
+		switch onCreate {
+		case OverwriteExisting:
+			res.Overwritten = true
+		case RenameNew:
+			res.CreatedAs = FreePath(path)
+		default:
+			conflicts = append(conflicts, fmt.Sprintf("%s already exists, so the patch will fail for it.", relativePath(root, path)))
+			continue
+		}
+		conflicts = append(conflicts, res.ConflictNote(root))
+	}
+	return conflicts
+}
+
+// ExistingCreates returns the files operations create that already exist,
+// resolved in ws. A nil ws uses the paths as given.
+func ExistingCreates(ws *Workspace, operations []AgentPatchOperation) []string {
+	var paths []string
+	for _, op := range operations {
+		if !op.Create {
+			continue
+		}
+		path := op.Path
+		if ws != nil {
+			resolved, err := ws.Resolve(op.Path)
+			if err != nil {
+				continue
+			}
+			path = resolved
+		}
+		if slices.Contains(paths, path) {
+			continue
+		}
+		if _, err := os.Lstat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// ConflictNote says what was done about a file the patch creates that
+// already existed, with paths relative to root, or "" if there was no such
+// file
+func (res *AgentPatchResult) ConflictNote(root string) string {
+	switch {
+	case res.Overwritten:
+		return fmt.Sprintf("%s already exists and is overwritten.", relativePath(root, res.Path))
+	case res.CreatedAs != "":
+		return fmt.Sprintf("%s already exists, so the new file is written to %s.", relativePath(root, res.Path), relativePath(root, res.CreatedAs))
+	}
+	return ""
+}
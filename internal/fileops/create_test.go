@@ -0,0 +1,104 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCreateConflict(t *testing.T) {
+	for name, want := range map[string]CreateConflict{"": FailOnExisting, "fail": FailOnExisting, "overwrite": OverwriteExisting, "rename": RenameNew} {
+		if got, err := ParseCreateConflict(name); err != nil || got != want {
+			t.Errorf("ParseCreateConflict(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseCreateConflict("merge"); err == nil {
+		t.Error("Expected an unknown strategy to fail")
+	}
+}
+
+func TestFreePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if got := FreePath(path); got != path {
+		t.Errorf("Expected a missing path to be free, got %s", got)
+	}
+	for _, name := range []string{"config.yaml", "config-1.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := FreePath(path); got != filepath.Join(dir, "config-2.yaml") {
+		t.Errorf("Expected config-2.yaml, got %s", got)
+	}
+}
+
+func TestApplyAgentPatchCreateConflict(t *testing.T) {
+	const patch = "// FILE: config.yaml\n// EDIT: create the config\nADD: new: true\n// END_EDIT"
+	tests := []struct {
+		onCreate  CreateConflict
+		wantErr   bool
+		want      map[string]string
+		createdAs string
+	}{
+		{FailOnExisting, true, map[string]string{"config.yaml": "old: true"}, ""},
+		{OverwriteExisting, false, map[string]string{"config.yaml": "new: true\n"}, ""},
+		{RenameNew, false, map[string]string{"config.yaml": "old: true", "config-1.yaml": "new: true\n"}, "config-1.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onCreate.String(), func(t *testing.T) {
+			ws, _ := newTestWorkspace(t, FollowInWorkspace)
+			ws.OnCreate = tt.onCreate
+			if err := os.WriteFile(filepath.Join(ws.Root, "config.yaml"), []byte("old: true"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			operations, err := ParseAgentPatch(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conflicts := CreateConflicts(ws, operations)
+			if len(conflicts) != 1 || !strings.HasPrefix(conflicts[0], "config.yaml already exists") {
+				t.Errorf("Expected the conflict to be predicted, got %q", conflicts)
+			}
+			results, err := ApplyAgentPatchIn(ws, operations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), "the file exists") {
+					t.Errorf("Expected the existing file to be named, got %v", err)
+				}
+			} else if results[0].ConflictNote(ws.Root) != conflicts[0] {
+				t.Errorf("Expected the note %q, got %q", conflicts[0], results[0].ConflictNote(ws.Root))
+			}
+			if tt.createdAs != "" && results[0].CreatedAs != filepath.Join(ws.Root, tt.createdAs) {
+				t.Errorf("Expected the file to be created as %s, got %s", tt.createdAs, results[0].CreatedAs)
+			}
+			for name, want := range tt.want {
+				if data, _ := os.ReadFile(filepath.Join(ws.Root, name)); string(data) != want {
+					t.Errorf("Expected %s to be %q, got %q", name, want, data)
+				}
+			}
+		})
+	}
+
+	// Without the create header, lines are added to the existing file
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("old: true"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	operations, err := ParseAgentPatch("// FILE: " + path + "\n// EDIT: add a key\nADD: new: true\n// END_EDIT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CreateConflicts(nil, operations) != nil {
+		t.Error("Expected no conflict for an edit")
+	}
+	if _, err := ApplyAgentPatch(operations); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old: true\nnew: true" {
+		t.Errorf("Unexpected content %q", data)
+	}
+}
@@ -0,0 +1,70 @@
+package fileops
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchFormatReminder restates the agent patch format, for the model to read
+// after a patch it wrote could not be parsed
+const PatchFormatReminder = `The patch_file format is:
+// FILE: path/to/file
+// EDIT: short description
+DEL: an existing line to remove, as it is in the file
+ADD: a line to add
+// END_EDIT
+// MOVE: new/path/to/file (optional, after the file's edits, to rename it)
+Every line between // EDIT: and // END_EDIT starts with ADD: or DEL:, at the start of the line. The ADD: lines of a block take the place of its DEL: lines; a block headed // EDIT: create with only ADD: lines creates a new file. Add more // EDIT: blocks for more changes, and more // FILE: markers for more files.`
+
+// PatchFormatError describes where an agent patch breaks the format
+type PatchFormatError struct {
+	Line    int    // The 1-based line of the problem, or 0 for the patch as a whole
+	Text    string // The line, if any
+	Problem string // What is wrong
+	Hint    string // How to fix it, if there is more to say than the reminder
+}
+
+func (e *PatchFormatError) Error() string {
+	if e.Line == 0 {
+		return e.Problem
+	}
+	return fmt.Sprintf("line %d (%q): %s", e.Line, truncateLine(e.Text), e.Problem)
+}
+
+// Feedback describes the error with the format reminder, as a tool result
+// the model can correct its patch from
+func (e *PatchFormatError) Feedback() string {
+	msg := "Error parsing patch: " + e.Error() + "."
+	if e.Hint != "" {
+		msg += " " + e.Hint
+	}
+	return msg + "\n\n" + PatchFormatReminder
+}
+
+// truncateLine shortens a line quoted in an error
+func truncateLine(line string) string {
+	const max = 60
+	if len(line) > max {
+		return line[:max] + "..."
+	}
+	return line
+}
+
+// wholePatchError explains a patch without a single ADD: or DEL: line,
+// recognizing the other patch formats models tend to fall back to
+func wholePatchError(lines []string) *PatchFormatError {
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "*** Begin Patch"), strings.HasPrefix(line, "*** Update File:"), strings.HasPrefix(line, "*** Add File:"):
+			return &PatchFormatError{Line: i + 1, Text: line, Problem: "this is the apply_patch format, which patch_file does not read", Hint: "Rewrite the change with // FILE: and // EDIT: blocks."}
+		case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "@@ "):
+			return &PatchFormatError{Line: i + 1, Text: line, Problem: "this unified diff changes nothing: it needs --- and +++ file headers followed by @@ hunks", Hint: "Add the --- a/path and +++ b/path lines of each file, or rewrite the change with // FILE: and // EDIT: blocks."}
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "// FILE:") {
+			return &PatchFormatError{Problem: "the patch has no ADD: or DEL: lines inside // EDIT: blocks, so it changes nothing"}
+		}
+	}
+	return &PatchFormatError{Problem: "the patch has no // FILE: marker"}
+}
@@ -0,0 +1,49 @@
+package fileops
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseAgentPatchFormatErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		line    int
+		problem string
+	}{
+		{"indented op", "// FILE: a.go\n// EDIT:\n  ADD: x\n// END_EDIT", 3, "must start the line"},
+		{"diff line in block", "// FILE: a.go\n// EDIT:\nADD: x\n+y\n// END_EDIT", 4, "must start with ADD: or DEL:"},
+		{"op outside block", "// FILE: a.go\nADD: x", 2, "outside an // EDIT: block"},
+		{"edit before file", "// EDIT:\nADD: x\n// END_EDIT", 1, "before '// FILE:'"},
+		{"file without name", "// FILE:\n", 1, "no filename"},
+		{"unified diff without headers", "@@ -1 +1 @@\n-x\n+y", 1, "unified diff"},
+		{"unified diff deleting a file", "--- a/a.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-x", 1, "deleting a file is not supported"},
+		{"unified diff hunk line", "--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,2 @@\n-x\n*y", 5, "must start with a space, - or +"},
+		{"apply_patch", "*** Begin Patch\n*** Update File: a.go\n*** End Patch", 1, "apply_patch format"},
+		{"empty blocks", "// FILE: a.go\n// EDIT:\n// END_EDIT", 0, "changes nothing"},
+		{"no markers", "just some text", 0, "no // FILE: marker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := ParseAgentPatch(tt.patch)
+			var formatErr *PatchFormatError
+			if !errors.As(err, &formatErr) {
+				t.Fatalf("Expected a PatchFormatError, got %v with %d operations", err, len(ops))
+			}
+			if formatErr.Line != tt.line || !strings.Contains(formatErr.Error(), tt.problem) {
+				t.Errorf("Unexpected error %q at line %d", formatErr.Error(), formatErr.Line)
+			}
+			if feedback := formatErr.Feedback(); !strings.HasPrefix(feedback, "Error parsing patch: ") || !strings.HasSuffix(feedback, PatchFormatReminder) {
+				t.Errorf("Unexpected feedback %q", feedback)
+			}
+		})
+	}
+
+	// Blank lines in blocks and text between them are fine
+	ops, err := ParseAgentPatch("Here is the change:\n// FILE: a.go\n// EDIT: x\nDEL: old\n\nADD: new\n// END_EDIT\n")
+	if err != nil || len(ops) != 2 {
+		t.Errorf("Expected two operations, got %+v, %v", ops, err)
+	}
+}
@@ -0,0 +1,122 @@
+package fileops
+
+import (
+	"slices"
+	"strings"
+)
+
+// FuzzLevel is how loosely a DEL line of a patch matched a line of the file.
+// Each level accepts what the ones before it do.
+type FuzzLevel int
+
+const (
+	FuzzExact      FuzzLevel = iota // The same line, apart from a "\r" line ending
+	FuzzTrailing                    // The same once trailing whitespace is ignored
+	FuzzWhitespace                  // The same once leading and trailing whitespace are ignored
+)
+
+// FuzzLevels lists the levels from the strictest
+var FuzzLevels = []FuzzLevel{FuzzExact, FuzzTrailing, FuzzWhitespace}
+
+// String names the level, as in patch statistics
+func (l FuzzLevel) String() string {
+	switch l {
+	case FuzzExact:
+		return "exact"
+	case FuzzTrailing:
+		return "ignoring trailing whitespace"
+	default:
+		return "ignoring indentation"
+	}
+}
+
+// lineFuzz returns the strictest level at which line matches want, and
+// false if they do not match at any level
+func lineFuzz(line, want string) (FuzzLevel, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	want = strings.TrimSuffix(want, "\r")
+	switch {
+	case line == want:
+		return FuzzExact, true
+	case strings.TrimRight(line, " \t") == strings.TrimRight(want, " \t"):
+		return FuzzTrailing, true
+	case strings.TrimSpace(line) == strings.TrimSpace(want):
+		return FuzzWhitespace, true
+	}
+	return 0, false
+}
+
+// blockMatch is where the DEL lines of a hunk were found in a file
+type blockMatch struct {
+	lines  []int       // Index of the file line matching each DEL line, ascending
+	levels []FuzzLevel // The level each of them matched at
+}
+
+// findBlock finds the DEL lines of a hunk in lines, as a block of
+// consecutive lines, at the strictest level that finds one, starting at
+// from and then from the top. Lines found only apart from each other are
+// not a match, as splicing the ADD lines in would move what is between
+// them. Failing a match, it returns where most DEL lines line up, for the
+// mismatch to point at, and the DEL lines that do not, trimmed.
+func findBlock(lines, dels []string, from int) (blockMatch, []string) {
+	for _, level := range FuzzLevels {
+		for _, start := range searchOrder(len(lines)-len(dels)+1, from) {
+			if m, ok := matchAt(lines, dels, start, level); ok {
+				return m, nil
+			}
+		}
+	}
+
+	var best blockMatch
+	bestStart := -1
+	for start := range lines {
+		var m blockMatch
+		for i, del := range dels {
+			if start+i >= len(lines) {
+				break
+			}
+			if level, ok := lineFuzz(lines[start+i], del); ok {
+				m.lines = append(m.lines, start+i)
+				m.levels = append(m.levels, level)
+			}
+		}
+		if len(m.lines) > len(best.lines) {
+			best, bestStart = m, start
+		}
+	}
+	var missing []string
+	for i, del := range dels {
+		if bestStart < 0 || !slices.Contains(best.lines, bestStart+i) {
+			missing = append(missing, strings.TrimSpace(del))
+		}
+	}
+	return best, missing
+}
+
+// searchOrder returns the indexes 0 to n-1, starting with from
+func searchOrder(n, from int) []int {
+	from = min(max(from, 0), max(n, 0))
+	order := make([]int, 0, max(n, 0))
+	for i := from; i < n; i++ {
+		order = append(order, i)
+	}
+	for i := 0; i < from; i++ {
+		order = append(order, i)
+	}
+	return order
+}
+
+// matchAt reports whether dels match the lines from start on, each at
+// level or stricter
+func matchAt(lines, dels []string, start int, level FuzzLevel) (blockMatch, bool) {
+	var m blockMatch
+	for i, del := range dels {
+		got, ok := lineFuzz(lines[start+i], del)
+		if !ok || got > level {
+			return blockMatch{}, false
+		}
+		m.lines = append(m.lines, start+i)
+		m.levels = append(m.levels, got)
+	}
+	return m, true
+}
@@ -0,0 +1,50 @@
+package fileops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineFuzz(t *testing.T) {
+	tests := []struct {
+		line, want string
+		level      FuzzLevel
+		ok         bool
+	}{
+		{"x := 1", "x := 1", FuzzExact, true},
+		{"x := 1\r", "x := 1", FuzzExact, true},
+		{"x := 1  ", "x := 1", FuzzTrailing, true},
+		{"\tx := 1", "x := 1", FuzzWhitespace, true},
+		{"x := 2", "x := 1", 0, false},
+	}
+	for _, tt := range tests {
+		level, ok := lineFuzz(tt.line, tt.want)
+		if level != tt.level || ok != tt.ok {
+			t.Errorf("lineFuzz(%q, %q) = %v, %t, want %v, %t", tt.line, tt.want, level, ok, tt.level, tt.ok)
+		}
+	}
+}
+
+func TestFindBlock(t *testing.T) {
+	lines := []string{"a", "b", "c", "a", "b"}
+
+	// A block is looked for from the given line first, then from the top
+	if m, missing := findBlock(lines, []string{"a", "b"}, 2); missing != nil || !reflect.DeepEqual(m.lines, []int{3, 4}) {
+		t.Errorf("Expected the block after line 2, got %v, %v", m.lines, missing)
+	}
+	if m, missing := findBlock(lines, []string{"b", "c"}, 3); missing != nil || !reflect.DeepEqual(m.lines, []int{1, 2}) {
+		t.Errorf("Expected the block before line 3, got %v, %v", m.lines, missing)
+	}
+
+	// Lines apart are not a block: the lines that break it are reported,
+	// trimmed, with where the rest line up
+	if m, missing := findBlock(lines, []string{"a", "c"}, 0); !reflect.DeepEqual(missing, []string{"c"}) || !reflect.DeepEqual(m.lines, []int{0}) {
+		t.Errorf("Expected c to break the block, got %v, %v", m.lines, missing)
+	}
+	if _, missing := findBlock(lines, []string{"a", "  z "}, 0); !reflect.DeepEqual(missing, []string{"z"}) {
+		t.Errorf("Expected z to be missing, got %v", missing)
+	}
+	if m, missing := findBlock(nil, []string{"a"}, 0); !reflect.DeepEqual(missing, []string{"a"}) || m.lines != nil {
+		t.Errorf("Expected a to be missing from an empty file, got %v, %v", m.lines, missing)
+	}
+}
@@ -0,0 +1,147 @@
+package fileops
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchHunk is one block of an agent patch: an // EDIT: block, or a
+// // MOVE: marker renaming a file
+type PatchHunk struct {
+	Path   string   // The file of the block
+	Header string   // The text after // EDIT:, usually describing the change
+	Lines  []string // The lines between // EDIT: and // END_EDIT, as written
+	MoveTo string   // For a move, the new path of the file
+}
+
+// SplitAgentPatch splits an agent patch into its hunks, in order. Lines
+// outside blocks are dropped, as ParseAgentPatch ignores them, and a patch
+// breaking the format fails as it does there. A unified diff is rewritten
+// as an agent patch first.
+func SplitAgentPatch(patchContent string) ([]PatchHunk, error) {
+	if IsUnifiedDiff(patchContent) {
+		converted, err := UnifiedToAgentPatch(patchContent)
+		if err != nil {
+			return nil, err
+		}
+		patchContent = converted
+	}
+
+	var hunks []PatchHunk
+	var current *PatchHunk
+	currentFile := ""
+
+	for i, line := range strings.Split(patchContent, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		lineError := func(problem, hint string) error {
+			return &PatchFormatError{Line: i + 1, Text: line, Problem: problem, Hint: hint}
+		}
+
+		switch {
+		case strings.HasPrefix(trimmedLine, "// FILE:"):
+			currentFile = strings.TrimSpace(strings.TrimPrefix(trimmedLine, "// FILE:"))
+			if currentFile == "" {
+				return nil, lineError("found '// FILE:' marker with no filename", "")
+			}
+			current = nil
+		case strings.HasPrefix(trimmedLine, "// MOVE:"):
+			if currentFile == "" {
+				return nil, lineError("found '// MOVE:' marker before '// FILE:' marker", "Put it after the // FILE: marker of the file to move.")
+			}
+			dest := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "// MOVE:"))
+			if dest == "" {
+				return nil, lineError("found '// MOVE:' marker with no destination", "")
+			}
+			hunks = append(hunks, PatchHunk{Path: currentFile, MoveTo: dest})
+			current = nil
+		case strings.HasPrefix(trimmedLine, "// EDIT:"):
+			if currentFile == "" {
+				return nil, lineError("found '// EDIT:' marker before '// FILE:' marker", "Start with the // FILE: marker of the file the block changes.")
+			}
+			hunks = append(hunks, PatchHunk{Path: currentFile, Header: strings.TrimSpace(strings.TrimPrefix(trimmedLine, "// EDIT:"))})
+			current = &hunks[len(hunks)-1]
+		case strings.HasPrefix(trimmedLine, "// END_EDIT"):
+			current = nil
+		default:
+			isOp := strings.HasPrefix(line, "ADD:") || strings.HasPrefix(line, "DEL:")
+			looksLikeOp := strings.HasPrefix(trimmedLine, "ADD:") || strings.HasPrefix(trimmedLine, "DEL:")
+			switch {
+			case current == nil:
+				if looksLikeOp {
+					return nil, lineError("ADD: or DEL: line outside an // EDIT: block", "Put it between // EDIT: and // END_EDIT.")
+				}
+			case !isOp && looksLikeOp:
+				return nil, lineError("ADD: and DEL: must start the line", "Put indentation after the prefix, as in \"ADD:     return nil\".")
+			case !isOp && trimmedLine != "":
+				return nil, lineError("lines in an // EDIT: block must start with ADD: or DEL:", "Context lines are not supported; remove the line.")
+			default:
+				current.Lines = append(current.Lines, line)
+			}
+		}
+	}
+	return hunks, nil
+}
+
+// JoinAgentPatch assembles hunks into an agent patch, writing the FILE
+// marker again only when the file changes
+func JoinAgentPatch(hunks []PatchHunk) string {
+	var b strings.Builder
+	currentFile := ""
+	for _, h := range hunks {
+		if h.Path != currentFile {
+			fmt.Fprintf(&b, "// FILE: %s\n", h.Path)
+			currentFile = h.Path
+		}
+		h.writeBlock(&b)
+	}
+	return b.String()
+}
+
+// Patch returns the hunk as a patch of its own
+func (h PatchHunk) Patch() string {
+	return JoinAgentPatch([]PatchHunk{h})
+}
+
+// writeBlock writes the EDIT block of the hunk, or its MOVE marker
+func (h PatchHunk) writeBlock(b *strings.Builder) {
+	if h.MoveTo != "" {
+		fmt.Fprintf(b, "// MOVE: %s\n", h.MoveTo)
+		return
+	}
+	b.WriteString("// EDIT:")
+	if h.Header != "" {
+		b.WriteString(" " + h.Header)
+	}
+	b.WriteString("\n")
+	for _, line := range h.Lines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("// END_EDIT\n")
+}
+
+// Counts returns the number of lines the hunk adds and removes
+func (h PatchHunk) Counts() (added, removed int) {
+	for _, line := range h.Lines {
+		switch {
+		case strings.HasPrefix(line, "ADD:"):
+			added++
+		case strings.HasPrefix(line, "DEL:"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Summary describes the hunk in one line, like "main.go: +3 -1 (add flag)"
+// or "old.go: moved to new.go"
+func (h PatchHunk) Summary() string {
+	if h.MoveTo != "" {
+		return fmt.Sprintf("%s: moved to %s", h.Path, h.MoveTo)
+	}
+	added, removed := h.Counts()
+	s := fmt.Sprintf("%s: +%d -%d", h.Path, added, removed)
+	if h.Header != "" {
+		s += fmt.Sprintf(" (%s)", h.Header)
+	}
+	return s
+}
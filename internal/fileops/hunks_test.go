@@ -0,0 +1,64 @@
+package fileops
+
+import (
+	"reflect"
+	"testing"
+)
+
+const threeHunkPatch = `// FILE: a.go
+// EDIT: rename the flag
+DEL: verbose := false
+ADD: debug := false
+// END_EDIT
+// EDIT: log it
+ADD: log.Println(debug)
+// END_EDIT
+// FILE: b.go
+// EDIT:
+DEL: // TODO
+// END_EDIT
+`
+
+func TestSplitAgentPatch(t *testing.T) {
+	hunks, err := SplitAgentPatch(threeHunkPatch)
+	if err != nil {
+		t.Fatalf("SplitAgentPatch failed: %v", err)
+	}
+	want := []PatchHunk{
+		{Path: "a.go", Header: "rename the flag", Lines: []string{"DEL: verbose := false", "ADD: debug := false"}},
+		{Path: "a.go", Header: "log it", Lines: []string{"ADD: log.Println(debug)"}},
+		{Path: "b.go", Lines: []string{"DEL: // TODO"}},
+	}
+	if !reflect.DeepEqual(hunks, want) {
+		t.Fatalf("Unexpected hunks:\n%+v", hunks)
+	}
+	if s := hunks[0].Summary(); s != "a.go: +1 -1 (rename the flag)" {
+		t.Errorf("Unexpected summary %q", s)
+	}
+
+	// Joining all the hunks gives back a patch that parses the same
+	joined := JoinAgentPatch(hunks)
+	if joined != threeHunkPatch {
+		t.Errorf("Expected the patch back, got:\n%s", joined)
+	}
+	original, _ := ParseAgentPatch(threeHunkPatch)
+	rebuilt, _ := ParseAgentPatch(joined)
+	if !reflect.DeepEqual(original, rebuilt) {
+		t.Errorf("Expected the same operations, got %+v", rebuilt)
+	}
+
+	// A subset keeps only its own operations
+	ops, err := ParseAgentPatch(JoinAgentPatch([]PatchHunk{hunks[1], hunks[2]}))
+	if err != nil || len(ops) != 2 || ops[0].Content != "log.Println(debug)" || ops[1].Path != "b.go" {
+		t.Errorf("Unexpected operations for a subset: %+v, %v", ops, err)
+	}
+}
+
+func TestSplitAgentPatchErrors(t *testing.T) {
+	if _, err := SplitAgentPatch("// EDIT: x\nADD: y\n// END_EDIT\n"); err == nil {
+		t.Error("Expected an error for an EDIT block without a file")
+	}
+	if _, err := SplitAgentPatch("// FILE:\n"); err == nil {
+		t.Error("Expected an error for a FILE marker without a name")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package fileops
+
+import "os"
+
+// isLink reports whether info is a symbolic link
+func isLink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
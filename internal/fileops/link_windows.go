@@ -0,0 +1,11 @@
+package fileops
+
+import "os"
+
+// isLink reports whether info is a symbolic link or a directory junction.
+// Lstat reports junctions as irregular directories rather than links, yet
+// they lead elsewhere just the same, and os.Readlink reads their targets.
+func isLink(info os.FileInfo) bool {
+	mode := info.Mode()
+	return mode&os.ModeSymlink != 0 || (mode.IsDir() && mode&os.ModeIrregular != 0)
+}
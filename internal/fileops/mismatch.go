@@ -0,0 +1,91 @@
+package fileops
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mismatchRadius is how many lines are shown on each side of the place a
+// failed patch was meant to change
+const mismatchRadius = 10
+
+// ContextMismatchError reports DEL lines of an agent patch that match no line
+// of the file. The file is left unchanged, and Excerpt holds its current
+// content around the intended change so the patch can be regenerated.
+type ContextMismatchError struct {
+	Path      string
+	Missing   []string // DEL lines that matched nothing, trimmed
+	StartLine int      // First line of the excerpt (1-indexed)
+	EndLine   int      // Last line of the excerpt
+	Excerpt   string
+}
+
+func (e *ContextMismatchError) Error() string {
+	return fmt.Sprintf("%d line(s) to delete not found in %s; the file was left unchanged", len(e.Missing), e.Path)
+}
+
+// RetryPrompt tells the model what did not match and shows the current
+// content, asking it to regenerate the patch for the file
+func (e *ContextMismatchError) RetryPrompt() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Could not patch %s: these DEL lines match no line of the file:\n", e.Path)
+	for _, line := range e.Missing {
+		fmt.Fprintf(&sb, "  %s\n", line)
+	}
+	fmt.Fprintf(&sb, "The file was left unchanged. Its current content, lines %d-%d, around the intended change:\n", e.StartLine, e.EndLine)
+	sb.WriteString(e.Excerpt)
+	sb.WriteString("\nRegenerate the patch for this file against the content above, copying each DEL line exactly as it appears.")
+	return sb.String()
+}
+
+// newContextMismatch describes the missing DEL lines of a patch to path,
+// excerpting lines around the closest match
+func newContextMismatch(path string, lines, missing []string, anchor int) *ContextMismatchError {
+	if closest, score := closestLine(lines, missing); score > 0 {
+		anchor = closest
+	}
+	start := max(anchor-mismatchRadius, 0)
+	end := min(anchor+mismatchRadius+1, len(lines))
+	return &ContextMismatchError{
+		Path:      path,
+		Missing:   missing,
+		StartLine: start + 1,
+		EndLine:   end,
+		Excerpt:   strings.Join(lines[start:end], "\n"),
+	}
+}
+
+// closestLine returns the index of the line sharing the largest fraction of
+// words with one of the wanted lines, and that fraction
+func closestLine(lines, wanted []string) (int, float64) {
+	best, bestScore := 0, 0.0
+	for _, want := range wanted {
+		wantWords := strings.Fields(want)
+		for i, line := range lines {
+			if score := wordOverlap(wantWords, strings.Fields(line)); score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+	}
+	return best, bestScore
+}
+
+// wordOverlap returns the fraction of words a and b share, relative to the
+// longer of the two
+func wordOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(a))
+	for _, w := range a {
+		counts[w]++
+	}
+	shared := 0
+	for _, w := range b {
+		if counts[w] > 0 {
+			counts[w]--
+			shared++
+		}
+	}
+	return float64(shared) / float64(max(len(a), len(b)))
+}
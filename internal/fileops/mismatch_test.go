@@ -0,0 +1,62 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyAgentPatchContextMismatch(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 40; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	lines[29] = "func handler(w http.ResponseWriter, r *http.Request) {"
+	original := strings.Join(lines, "\n")
+	path := filepath.Join(t.TempDir(), "server.go")
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first DEL line matches, the second was written against older content
+	results, err := ApplyAgentPatch([]AgentPatchOperation{
+		{Type: "remove", Path: path, Content: "line 2"},
+		{Type: "remove", Path: path, Content: "func handler(w http.ResponseWriter, req *http.Request) {"},
+		{Type: "add", Path: path, Content: "func handler() {"},
+	})
+	var mismatch *ContextMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a context mismatch, got %v", err)
+	}
+	if len(results) != 1 || results[0].Success || results[0].MissedLines != 1 {
+		t.Errorf("Unexpected result %+v", results[0])
+	}
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Errorf("Expected the file to be left unchanged, got %q", data)
+	}
+
+	// The excerpt is centered on the line closest to the missing one
+	if mismatch.StartLine != 20 || mismatch.EndLine != 40 || !strings.HasPrefix(mismatch.Excerpt, "line 20\n") {
+		t.Errorf("Unexpected excerpt, lines %d-%d:\n%s", mismatch.StartLine, mismatch.EndLine, mismatch.Excerpt)
+	}
+	prompt := mismatch.RetryPrompt()
+	for _, want := range []string{"req *http.Request", lines[29], "lines 20-40", "Regenerate the patch"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the retry prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestClosestLineFallsBackToAnchor(t *testing.T) {
+	lines := make([]string, 30)
+	for i := range lines {
+		lines[i] = "x"
+	}
+	mismatch := newContextMismatch("f", lines, []string{"nothing alike"}, 25)
+	if mismatch.StartLine != 16 || mismatch.EndLine != 30 {
+		t.Errorf("Expected an excerpt around the anchor, got lines %d-%d", mismatch.StartLine, mismatch.EndLine)
+	}
+}
@@ -1,539 +1,423 @@
 package fileops
 
 import (
-	"bytes"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
-// AgentPatchOperation represents a single operation derived from the custom agent format
+// AgentPatchOperation is one line an agent patch adds or deletes, or the
+// move of a file
 type AgentPatchOperation struct {
-	Type    string // "add" or "remove"
+	Type    string // "add", "remove" or "move"
 	Path    string // Path to the file
 	Content string // Content to add or remove (without ADD:/DEL: prefix)
-	// Note: Line numbers are not directly available in this format
+	MoveTo  string // For a move, the new path of the file
+	Hunk    int    // The block of the patch the operation belongs to
+	After   *int   // For an ADD line of a block without DEL lines, the line the block goes after, 0 for the top
+	Create  bool   // For an ADD line of a block creating the file
 }
 
+// afterLinePattern matches the header of a block placed by line number
+var afterLinePattern = regexp.MustCompile(`^after line (\d+)\b`)
+
 // ParseAgentPatch parses the agent's specific patch format.
-// It looks for // FILE:, // EDIT:, // END_EDIT, ADD:, and DEL: markers.
+// It looks for // FILE:, // EDIT:, // END_EDIT, // MOVE:, ADD:, and DEL:
+// markers, and also reads unified diffs, as UnifiedToAgentPatch rewrites
+// them. A block without DEL: lines whose header starts with "after line N"
+// goes after line N of the file as it was before the patch, 0 for the top,
+// and one headed "create" creates the file, as the Workspace's OnCreate
+// says when it already exists. A patch that breaks the format, or changes nothing at all, fails
+// with a *PatchFormatError pointing at the problem.
 func ParseAgentPatch(patchContent string) ([]AgentPatchOperation, error) {
-	var operations []AgentPatchOperation
-	lines := strings.Split(patchContent, "\n")
-
-	currentFile := ""
-	inEditBlock := false
-	var fileParseError error
-
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
+	hunks, err := SplitAgentPatch(patchContent)
+	if err != nil {
+		return nil, err
+	}
 
-		if strings.HasPrefix(trimmedLine, "// FILE:") {
-			currentFile = strings.TrimSpace(strings.TrimPrefix(trimmedLine, "// FILE:"))
-			if currentFile == "" {
-				fileParseError = fmt.Errorf("found '// FILE:' marker with no filename")
-			}
-			inEditBlock = false
+	var operations []AgentPatchOperation
+	for i, h := range hunks {
+		if h.MoveTo != "" {
+			operations = append(operations, AgentPatchOperation{Type: "move", Path: h.Path, MoveTo: h.MoveTo, Hunk: i})
 			continue
 		}
-
-		if strings.HasPrefix(trimmedLine, "// EDIT:") {
-			if currentFile == "" {
-				fileParseError = fmt.Errorf("found '// EDIT:' marker before '// FILE:' marker")
+		var after *int
+		if match := afterLinePattern.FindStringSubmatch(h.Header); match != nil {
+			if n, err := strconv.Atoi(match[1]); err == nil {
+				after = &n
 			}
-			inEditBlock = true
-			continue
 		}
-
-		if strings.HasPrefix(trimmedLine, "// END_EDIT") {
-			inEditBlock = false
-			continue
+		create := isCreateHeader(h.Header)
+		if removed := slices.ContainsFunc(h.Lines, func(line string) bool { return strings.HasPrefix(line, "DEL:") }); removed {
+			after, create = nil, false
 		}
-
-		if inEditBlock && currentFile != "" {
-			if strings.HasPrefix(line, "ADD:") {
-				content := strings.TrimPrefix(line, "ADD:")
-				// Remove potential leading space after prefix
-				content = strings.TrimPrefix(content, " ")
-				operations = append(operations, AgentPatchOperation{
-					Type:    "add",
-					Path:    currentFile,
-					Content: content,
-				})
-			} else if strings.HasPrefix(line, "DEL:") {
-				content := strings.TrimPrefix(line, "DEL:")
-				// Remove potential leading space after prefix
-				content = strings.TrimPrefix(content, " ")
-				operations = append(operations, AgentPatchOperation{
-					Type:    "remove",
-					Path:    currentFile,
-					Content: content,
-				})
+		for _, line := range h.Lines {
+			op := AgentPatchOperation{Path: h.Path, Hunk: i, After: after, Create: create}
+			switch {
+			case strings.HasPrefix(line, "ADD:"):
+				op.Type = "add"
+				op.Content = strings.TrimPrefix(line, "ADD:")
+			case strings.HasPrefix(line, "DEL:"):
+				op.Type = "remove"
+				op.Content = strings.TrimPrefix(line, "DEL:")
+			default:
+				continue // A blank line
 			}
+			// Remove potential leading space after prefix
+			op.Content = strings.TrimPrefix(op.Content, " ")
+			operations = append(operations, op)
 		}
 	}
 
-	return operations, fileParseError
+	if len(operations) == 0 {
+		return nil, wholePatchError(strings.Split(patchContent, "\n"))
+	}
+	return operations, nil
 }
 
-// ApplyAgentPatch applies a series of custom agent patch operations.
-// This version attempts to remove lines based on content match (ignoring leading/trailing space)
-// and appends added lines.
-func ApplyAgentPatch(operations []AgentPatchOperation) ([]*AgentPatchResult, error) {
-	var results []*AgentPatchResult
-	var overallError error
-	opsByFile := make(map[string][]AgentPatchOperation)
-	for _, op := range operations {
-		opsByFile[op.Path] = append(opsByFile[op.Path], op)
+// PatchFiles returns the files an agent patch changes, in order, with the
+// new paths of the files it moves. It returns nil if the patch cannot be
+// parsed.
+func PatchFiles(patchContent string) []string {
+	operations, err := ParseAgentPatch(patchContent)
+	if err != nil {
+		return nil
 	}
-
-	for path, ops := range opsByFile {
-		result := &AgentPatchResult{Path: path, Success: false} // Default to failure
-		results = append(results, result)
-
-		// ----------------- Start Revised Logic -----------------
-
-		// 1. Collect lines to delete and lines to add
-		linesToDelete := make(map[string]bool)
-		var linesToAdd []string
-		deleteOpCount := 0 // Keep track of DEL operations for reporting
-		addOpCount := 0
-		for _, op := range ops {
-			if op.Type == "remove" {
-				// Split multi-line content into individual lines for deletion map
-				for _, lineToDelete := range strings.Split(op.Content, "\n") {
-					trimmedLine := strings.TrimSpace(lineToDelete)
-					if trimmedLine != "" { // Avoid adding empty lines from blank DEL blocks
-						linesToDelete[trimmedLine] = true
-					}
-				}
-				deleteOpCount++
-			} else if op.Type == "add" {
-				linesToAdd = append(linesToAdd, op.Content)
-				addOpCount++
-			}
-		}
-
-		// 2. Read original file (handle potential creation)
-		contentBytes, readErr := ioutil.ReadFile(path)
-		isNotExist := os.IsNotExist(readErr)
-
-		if readErr != nil && !isNotExist {
-			result.Error = fmt.Errorf("failed to read file %s: %w", path, readErr)
-			if overallError == nil {
-				overallError = result.Error
-			}
-			continue // Skip to next file
-		}
-
-		// Check if we should create the file
-		shouldCreate := isNotExist && addOpCount > 0
-		if isNotExist && !shouldCreate {
-			// File doesn't exist, and we aren't adding anything, so it's an error if trying to delete
-			if deleteOpCount > 0 {
-				result.Error = fmt.Errorf("file %s does not exist and cannot apply deletions", path)
-				if overallError == nil {
-					overallError = result.Error
-				}
-			} else {
-				// No error, but nothing to do
-				result.Success = true
-				result.Diff = "File does not exist, no operation performed."
-			}
-			continue // Skip to next file
-		}
-
-		var originalLines []string
-		if !isNotExist {
-			originalLines = strings.Split(string(contentBytes), "\n")
-		}
-		result.OriginalLines = len(originalLines)
-
-		// 3. Build new content excluding deleted lines
-		modifiedLines := make([]string, 0, len(originalLines))
-		actualDeletions := 0
-		for _, line := range originalLines {
-			if !linesToDelete[strings.TrimSpace(line)] {
-				modifiedLines = append(modifiedLines, line) // Keep the original line
-			} else {
-				actualDeletions++
-			}
-		}
-
-		// 4. Append added lines
-		modifiedLines = append(modifiedLines, linesToAdd...)
-
-		// 5. Check if changes were actually made
-		linesWereModified := (actualDeletions > 0) || (addOpCount > 0) || shouldCreate
-
-		if linesWereModified {
-			newContent := strings.Join(modifiedLines, "\n")
-			// Ensure directory exists if creating file
-			if shouldCreate {
-				dir := filepath.Dir(path)
-				if err := os.MkdirAll(dir, 0755); err != nil {
-					result.Error = fmt.Errorf("failed to create directory for %s: %w", path, err)
-					if overallError == nil {
-						overallError = result.Error
-					}
-					continue // Skip to next file
-				}
-			}
-			// Write the file
-			if err := ioutil.WriteFile(path, []byte(newContent), 0644); err != nil {
-				result.Error = fmt.Errorf("failed to write changes to file %s: %w", path, err)
-				if overallError == nil {
-					overallError = result.Error
-				}
-				continue // Skip to next file
+	var files []string
+	seen := make(map[string]bool)
+	for _, op := range operations {
+		for _, path := range []string{op.Path, op.MoveTo} {
+			if path != "" && !seen[path] {
+				seen[path] = true
+				files = append(files, path)
 			}
-			result.Success = true
-			result.NewLines = len(modifiedLines)
-			result.Diff = fmt.Sprintf("Applied +%d/-%d lines.", addOpCount, actualDeletions)
-		} else {
-			result.Success = true
-			result.Diff = "No effective changes applied."
-			result.NewLines = len(originalLines)
 		}
-		// ----------------- End Revised Logic -----------------
 	}
+	return files
+}
 
-	return results, overallError
+// ApplyAgentPatch applies a series of agent patch operations. Each hunk's
+// DEL lines are found in the file, at the strictest fuzz level that finds
+// them, and replaced with its ADD lines; a hunk without DEL lines adds its
+// lines after the line it names, or at the end of the file. DEL lines not found together as a block
+// leave the file unchanged.
+func ApplyAgentPatch(operations []AgentPatchOperation) ([]*AgentPatchResult, error) {
+	return ApplyAgentPatchIn(nil, operations)
 }
 
-// Helper to check if any operation implies file creation for the agent patch format
-func shouldCreateFileForAgentPatch(ops []AgentPatchOperation) bool {
-	for _, op := range ops {
-		if op.Type == "add" {
-			return true
-		}
-	}
-	return false
+// ApplyAgentPatchIn is ApplyAgentPatch with every path resolved in ws first.
+// Operations on paths the workspace refuses fail, and operations reaching the
+// same file through different paths are applied together. A nil ws uses the
+// paths as given.
+func ApplyAgentPatchIn(ws *Workspace, operations []AgentPatchOperation) ([]*AgentPatchResult, error) {
+	return applyAgentPatchIn(ws, operations, true)
 }
 
-// PatchOperation represents a single patch operation
-type PatchOperation struct {
-	Type      string // "add", "remove", "replace"
-	Path      string // Path to the file
-	Content   string // Content to add or replace with
-	StartLine int    // Start line for the operation (1-indexed)
-	EndLine   int    // End line for the operation (1-indexed)
+// PreviewAgentPatchIn is ApplyAgentPatchIn without writing anything, for dry
+// runs. Results that would change a file hold its content before and after.
+func PreviewAgentPatchIn(ws *Workspace, operations []AgentPatchOperation) ([]*AgentPatchResult, error) {
+	return applyAgentPatchIn(ws, operations, false)
 }
 
-// PatchResult represents the result of applying a patch
-type PatchResult struct {
-	Success       bool
-	Error         error
-	Path          string
-	OriginalLines int
-	NewLines      int
-	Diff          string
+// filePatch is the part of a patch that changes one file
+type filePatch struct {
+	path     string
+	moveTo   string
+	moveErr  error // Set if the workspace refuses the new path
+	hunks    []patchBlock
+	lastHunk int          // The Hunk of the operations of the last block
+	blocks   map[int]bool // Hunks of the patch for the file, moves included
 }
 
-// ApplyPatch applies a patch operation to a file
-func ApplyPatch(op PatchOperation) (*PatchResult, error) {
-	// Ensure the file exists or create it if adding new content
-	if op.Type == "add" && !fileExists(op.Path) {
-		// Ensure directory exists
-		dir := filepath.Dir(op.Path)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
-		}
+// patchBlock is the lines one hunk deletes and adds
+type patchBlock struct {
+	dels   []string
+	adds   []string
+	after  *int // The line of the original file a block without dels goes after
+	create bool // Whether the block creates the file
+}
 
-		// Create empty file
-		if err := ioutil.WriteFile(op.Path, []byte{}, 0644); err != nil {
-			return nil, fmt.Errorf("failed to create file %s: %w", op.Path, err)
+// applyAgentPatchIn applies operations, writing the files only if write is set
+func applyAgentPatchIn(ws *Workspace, operations []AgentPatchOperation, write bool) ([]*AgentPatchResult, error) {
+	var results []*AgentPatchResult
+	var overallError error
+	fail := func(result *AgentPatchResult, err error) {
+		result.Error = err
+		if overallError == nil {
+			overallError = err
 		}
-	} else if !fileExists(op.Path) {
-		return nil, fmt.Errorf("file not found: %s", op.Path)
-	}
-
-	// Read the file content
-	content, err := ioutil.ReadFile(op.Path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", op.Path, err)
 	}
-
-	// Split into lines
-	lines := strings.Split(string(content), "\n")
-	originalLines := len(lines)
-
-	// Apply the patch based on type
-	var newContent string
-	var diff string
-
-	switch op.Type {
-	case "add":
-		newContent, diff = addContent(lines, op)
-	case "remove":
-		newContent, diff = removeContent(lines, op)
-	case "replace":
-		newContent, diff = replaceContent(lines, op)
-	default:
-		return nil, fmt.Errorf("unknown patch operation type: %s", op.Type)
+	resolve := func(path string) (string, error) {
+		if ws == nil {
+			return path, nil
+		}
+		return ws.Resolve(path)
 	}
-
-	// Write the new content back to the file
-	if err := ioutil.WriteFile(op.Path, []byte(newContent), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write to file %s: %w", op.Path, err)
+	onCreate := FailOnExisting
+	if ws != nil {
+		onCreate = ws.OnCreate
 	}
 
-	// Calculate new line count
-	newLines := len(strings.Split(newContent, "\n"))
-
-	return &PatchResult{
-		Success:       true,
-		Path:          op.Path,
-		OriginalLines: originalLines,
-		NewLines:      newLines,
-		Diff:          diff,
-	}, nil
-}
-
-// ParseUnifiedDiff parses a unified diff format and converts it to patch operations
-func ParseUnifiedDiff(diff string, basePath string) ([]PatchOperation, error) {
-	var operations []PatchOperation
-
-	// Regex to match file headers in unified diff
-	fileHeaderRegex := regexp.MustCompile(`^--- (a/)?(.+?)\s.*\n\+\+\+ (b/)?(.+?)(\s.*)?$`)
-
-	// Regex to match hunks in unified diff
-	hunkHeaderRegex := regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
-
-	// Split diff into sections by file
-	lines := strings.Split(diff, "\n")
-	currentFile := ""
-	inHunk := false
-	srcStart := 0
-	dstStart := 0
-	hunkContent := []string{}
-
-	for i, line := range lines {
-		// Check for file header
-		if fileHeaderMatch := fileHeaderRegex.FindStringSubmatch(line); fileHeaderMatch != nil && i+1 < len(lines) {
-			// Process previous hunk if any
-			if inHunk && len(hunkContent) > 0 {
-				op := processHunk(hunkContent, srcStart, dstStart, currentFile)
-				operations = append(operations, op)
-				hunkContent = []string{}
+	// Operations are grouped by file, in the order the files come in
+	var files []*filePatch
+	byPath := make(map[string]*filePatch)
+	refused := make(map[string]bool)
+	for _, op := range operations {
+		path, err := resolve(op.Path)
+		if err != nil {
+			if !refused[op.Path] {
+				refused[op.Path] = true
+				result := &AgentPatchResult{Path: op.Path}
+				fail(result, err)
+				results = append(results, result)
 			}
-
-			// Get the new file path
-			currentFile = filepath.Join(basePath, fileHeaderMatch[4])
-			inHunk = false
 			continue
 		}
-
-		// Check for hunk header
-		if hunkHeaderMatch := hunkHeaderRegex.FindStringSubmatch(line); hunkHeaderMatch != nil {
-			// Process previous hunk if any
-			if inHunk && len(hunkContent) > 0 {
-				op := processHunk(hunkContent, srcStart, dstStart, currentFile)
-				operations = append(operations, op)
-				hunkContent = []string{}
+		fp := byPath[path]
+		if fp == nil {
+			fp = &filePatch{path: path, blocks: make(map[int]bool)}
+			byPath[path] = fp
+			files = append(files, fp)
+		}
+		fp.blocks[op.Hunk] = true
+		if op.Type == "move" {
+			if fp.moveTo, err = resolve(op.MoveTo); err != nil {
+				fp.moveErr = err
 			}
-
-			// Parse hunk header numbers
-			srcStart = atoi(hunkHeaderMatch[1])
-			dstStart = atoi(hunkHeaderMatch[3])
-
-			inHunk = true
 			continue
 		}
-
-		// Collect hunk content
-		if inHunk {
-			hunkContent = append(hunkContent, line)
+		if op.Hunk != fp.lastHunk || len(fp.hunks) == 0 {
+			fp.hunks = append(fp.hunks, patchBlock{})
+			fp.lastHunk = op.Hunk
+		}
+		block := &fp.hunks[len(fp.hunks)-1]
+		lines := strings.Split(op.Content, "\n")
+		if op.Type == "remove" {
+			block.dels = append(block.dels, lines...)
+		} else if op.Type == "add" {
+			block.adds = append(block.adds, lines...)
+			block.after = op.After
+			block.create = op.Create
 		}
 	}
 
-	// Process the last hunk if any
-	if inHunk && len(hunkContent) > 0 {
-		op := processHunk(hunkContent, srcStart, dstStart, currentFile)
-		operations = append(operations, op)
+	for _, fp := range files {
+		result := &AgentPatchResult{Path: fp.path, Hunks: len(fp.blocks), MovedTo: fp.moveTo}
+		results = append(results, result)
+		if fp.moveErr != nil {
+			fail(result, fp.moveErr)
+			continue
+		}
+		if err := applyFilePatch(fp, result, onCreate, write); err != nil {
+			fail(result, err)
+		}
 	}
-
-	return operations, nil
+	return results, overallError
 }
 
-// Helper function to process a hunk and convert it to a patch operation
-func processHunk(hunkContent []string, srcStart, dstStart int, filePath string) PatchOperation {
-	var addedContent []string
-	var removedContent []string
-	var contextBefore, contextAfter []string
-	inAddition := false
-	inRemoval := false
+// applyFilePatch applies the hunks of fp to its file, and moves it if the
+// patch says so, filling in result. A patch creating a file that exists
+// does as onCreate says.
+func applyFilePatch(fp *filePatch, result *AgentPatchResult, onCreate CreateConflict, write bool) error {
+	deletes, adds := 0, 0
+	for _, h := range fp.hunks {
+		deletes += len(h.dels)
+		adds += len(h.adds)
+	}
 
-	for _, line := range hunkContent {
-		if len(line) == 0 {
-			continue
+	contentBytes, readErr := os.ReadFile(fp.path)
+	isNotExist := os.IsNotExist(readErr)
+	switch {
+	case readErr != nil && !isNotExist:
+		return fmt.Errorf("failed to read file %s: %w", fp.path, readErr)
+	case isNotExist && fp.moveTo != "":
+		return fmt.Errorf("file %s does not exist and cannot be moved", fp.path)
+	case isNotExist && deletes > 0:
+		return fmt.Errorf("file %s does not exist and cannot apply deletions", fp.path)
+	case isNotExist && adds == 0:
+		result.Success = true
+		result.Diff = "File does not exist, no operation performed."
+		return nil
+	}
+	if fp.moveTo != "" && fp.moveTo != fp.path {
+		if _, err := os.Lstat(fp.moveTo); err == nil {
+			return fmt.Errorf("cannot move %s to %s: the file exists", fp.path, fp.moveTo)
 		}
+	}
 
-		switch line[0] {
-		case '+':
-			addedContent = append(addedContent, line[1:])
-			inAddition = true
-		case '-':
-			removedContent = append(removedContent, line[1:])
-			inRemoval = true
-		default:
-			if !inAddition && !inRemoval {
-				contextBefore = append(contextBefore, line[1:])
-			} else {
-				contextAfter = append(contextAfter, line[1:])
+	// A file the patch creates but that exists is left alone, replaced,
+	// or kept with the new one written next to it
+	content := string(contentBytes)
+	creates := slices.ContainsFunc(fp.hunks, func(h patchBlock) bool { return h.create })
+	if creates && !isNotExist {
+		switch onCreate {
+		case OverwriteExisting:
+			content, result.Overwritten = "", true
+		case RenameNew:
+			result.CreatedAs = FreePath(fp.path)
+			if fp.moveTo != "" {
+				result.CreatedAs = fp.moveTo
 			}
+			content, contentBytes, isNotExist = "", nil, true
+		default:
+			return fmt.Errorf("cannot create %s: the file exists; change it with DEL: and ADD: lines instead", fp.path)
 		}
 	}
 
-	// Determine operation type
-	var opType string
-	var content string
-	var startLine, endLine int
-
-	if len(removedContent) > 0 && len(addedContent) > 0 {
-		// Replace operation
-		opType = "replace"
-		content = strings.Join(addedContent, "\n")
-		startLine = srcStart
-		endLine = srcStart + len(removedContent) - 1
-	} else if len(removedContent) > 0 {
-		// Remove operation
-		opType = "remove"
-		content = ""
-		startLine = srcStart
-		endLine = srcStart + len(removedContent) - 1
-	} else {
-		// Add operation
-		opType = "add"
-		content = strings.Join(addedContent, "\n")
-		startLine = dstStart
-		endLine = dstStart
+	// Files with Windows line endings keep them: lines are matched
+	// without the "\r" and written back with it
+	lineEnding := "\n"
+	if UsesCRLF(content) {
+		lineEnding = "\r\n"
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	finalNewline := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	original := lines
+	result.OriginalLines = len(lines)
+
+	// Blocks placed by line number count lines of the original file, so
+	// the lines the blocks before them added or deleted are made up for
+	var missing []string
+	anchor, from, shift := 0, 0, 0
+	for _, h := range fp.hunks {
+		if len(h.dels) == 0 {
+			at := len(lines)
+			if h.after != nil {
+				if *h.after > len(original) {
+					return fmt.Errorf("cannot add lines after line %d: it is past the end of %s (%d lines)", *h.after, fp.path, len(original))
+				}
+				at = min(max(*h.after+shift, 0), len(lines))
+			}
+			lines = splice(lines, nil, at, h.adds)
+			shift += len(h.adds)
+			continue
+		}
+		m, notFound := findBlock(lines, h.dels, from)
+		for _, level := range m.levels {
+			if result.Matches == nil {
+				result.Matches = make(map[FuzzLevel]int)
+			}
+			result.Matches[level]++
+		}
+		if len(notFound) > 0 {
+			if len(missing) == 0 && len(m.lines) > 0 {
+				anchor = m.lines[0]
+			}
+			missing = append(missing, notFound...)
+			continue
+		}
+		lines = splice(lines, m.lines, m.lines[0], h.adds)
+		from = m.lines[0] + len(h.adds)
+		shift += len(h.adds) - len(h.dels)
 	}
+	result.MissedLines = len(missing)
 
-	return PatchOperation{
-		Type:      opType,
-		Path:      filePath,
-		Content:   content,
-		StartLine: startLine,
-		EndLine:   endLine,
+	// A DEL line that matches nothing means the patch was written against
+	// content the file no longer has; applying the rest would leave it half
+	// edited, so show the current content instead
+	if len(missing) > 0 {
+		return newContextMismatch(fp.path, original, missing, anchor)
 	}
-}
 
-// Helper function to add content to lines
-func addContent(lines []string, op PatchOperation) (string, string) {
-	// Adjust for 1-indexed to 0-indexed
-	insertLine := op.StartLine - 1
-	if insertLine < 0 {
-		insertLine = 0
+	newContent := strings.Join(lines, lineEnding)
+	if finalNewline && len(lines) > 0 {
+		newContent += lineEnding
 	}
-	if insertLine > len(lines) {
-		insertLine = len(lines)
+	result.NewLines = len(lines)
+	moved := fp.moveTo != "" && fp.moveTo != fp.path && result.CreatedAs == ""
+	if newContent == string(contentBytes) && !isNotExist && !moved {
+		result.Success = true
+		result.Diff = "No effective changes applied."
+		return nil
 	}
 
-	// Insert the content
-	newLines := make([]string, 0, len(lines)+1)
-	newLines = append(newLines, lines[:insertLine]...)
-	newLines = append(newLines, op.Content)
-	newLines = append(newLines, lines[insertLine:]...)
-
-	// Create a diff representation
-	var diff bytes.Buffer
-	diff.WriteString(fmt.Sprintf("--- %s (original)\n", op.Path))
-	diff.WriteString(fmt.Sprintf("+++ %s (modified)\n", op.Path))
-	diff.WriteString(fmt.Sprintf("@@ -%d,0 +%d,%d @@\n", insertLine+1, insertLine+1, 1))
-	diff.WriteString("+ " + op.Content + "\n")
-
-	return strings.Join(newLines, "\n"), diff.String()
-}
-
-// Helper function to remove content from lines
-func removeContent(lines []string, op PatchOperation) (string, string) {
-	// Adjust for 1-indexed to 0-indexed
-	startLine := op.StartLine - 1
-	endLine := op.EndLine - 1
-
-	if startLine < 0 {
-		startLine = 0
+	verb := "Applied"
+	if !write {
+		verb = "Would apply"
 	}
-	if endLine >= len(lines) {
-		endLine = len(lines) - 1
+	result.Diff = fmt.Sprintf("%s +%d/-%d lines", verb, adds, deletes)
+	switch {
+	case moved:
+		result.Diff += ", moving the file to " + fp.moveTo
+	case result.CreatedAs != "":
+		result.Diff += ", creating " + result.CreatedAs
+	case result.Overwritten:
+		result.Diff += ", overwriting the file"
 	}
-	if startLine > endLine {
-		return strings.Join(lines, "\n"), ""
+	result.Diff += "."
+	if !write {
+		if !isNotExist {
+			before := string(contentBytes)
+			result.Before = &before
+		}
+		result.After = newContent
+		result.Success = true
+		return nil
 	}
 
-	// Remove the lines
-	newLines := make([]string, 0, len(lines)-(endLine-startLine+1))
-	newLines = append(newLines, lines[:startLine]...)
-	newLines = append(newLines, lines[endLine+1:]...)
-
-	// Create a diff representation
-	var diff bytes.Buffer
-	diff.WriteString(fmt.Sprintf("--- %s (original)\n", op.Path))
-	diff.WriteString(fmt.Sprintf("+++ %s (modified)\n", op.Path))
-	diff.WriteString(fmt.Sprintf("@@ -%d,%d +%d,0 @@\n", startLine+1, endLine-startLine+1, startLine+1))
-	for i := startLine; i <= endLine; i++ {
-		diff.WriteString("- " + lines[i] + "\n")
+	target := fp.path
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(fp.path); err == nil {
+		mode = info.Mode().Perm()
 	}
-
-	return strings.Join(newLines, "\n"), diff.String()
-}
-
-// Helper function to replace content in lines
-func replaceContent(lines []string, op PatchOperation) (string, string) {
-	// Adjust for 1-indexed to 0-indexed
-	startLine := op.StartLine - 1
-	endLine := op.EndLine - 1
-
-	if startLine < 0 {
-		startLine = 0
+	if moved {
+		target = fp.moveTo
 	}
-	if endLine >= len(lines) {
-		endLine = len(lines) - 1
+	if result.CreatedAs != "" {
+		target = result.CreatedAs
 	}
-	if startLine > endLine {
-		return strings.Join(lines, "\n"), ""
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", target, err)
 	}
-
-	// Replace the lines
-	replacementLines := strings.Split(op.Content, "\n")
-	newLines := make([]string, 0, len(lines)-(endLine-startLine+1)+len(replacementLines))
-	newLines = append(newLines, lines[:startLine]...)
-	newLines = append(newLines, replacementLines...)
-	newLines = append(newLines, lines[endLine+1:]...)
-
-	// Create a diff representation
-	var diff bytes.Buffer
-	diff.WriteString(fmt.Sprintf("--- %s (original)\n", op.Path))
-	diff.WriteString(fmt.Sprintf("+++ %s (modified)\n", op.Path))
-	diff.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", startLine+1, endLine-startLine+1, startLine+1, len(replacementLines)))
-	for i := startLine; i <= endLine; i++ {
-		diff.WriteString("- " + lines[i] + "\n")
+	if err := os.WriteFile(target, []byte(newContent), mode); err != nil {
+		return fmt.Errorf("failed to write changes to file %s: %w", target, err)
 	}
-	for _, line := range replacementLines {
-		diff.WriteString("+ " + line + "\n")
+	if moved {
+		if err := os.Remove(fp.path); err != nil {
+			return fmt.Errorf("wrote %s but failed to remove %s: %w", target, fp.path, err)
+		}
 	}
-
-	return strings.Join(newLines, "\n"), diff.String()
+	result.Success = true
+	return nil
 }
 
-// Helper function to check if file exists
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
+// splice returns lines without the lines at the ascending indexes remove,
+// and with adds inserted where the line at index at was
+func splice(lines []string, remove []int, at int, adds []string) []string {
+	spliced := make([]string, 0, len(lines)-len(remove)+len(adds))
+	r := 0
+	for i := 0; i <= len(lines); i++ {
+		if i == at {
+			spliced = append(spliced, adds...)
+		}
+		if i == len(lines) {
+			break
+		}
+		if r < len(remove) && remove[r] == i {
+			r++
+			continue
+		}
+		spliced = append(spliced, lines[i])
+	}
+	return spliced
 }
 
-// Helper function to convert string to int
-func atoi(s string) int {
-	var n int
-	fmt.Sscanf(s, "%d", &n)
-	return n
+// UsesCRLF reports whether most lines of content end with "\r\n", as files
+// written on Windows do
+func UsesCRLF(content string) bool {
+	crlf := strings.Count(content, "\r\n")
+	return crlf > 0 && 2*crlf >= strings.Count(content, "\n")
 }
 
-// AgentPatchResult represents the result of applying an agent patch operation
+// AgentPatchResult represents the result of applying an agent patch to a file
 type AgentPatchResult struct {
 	Success       bool
 	Error         error
@@ -541,4 +425,22 @@ type AgentPatchResult struct {
 	OriginalLines int
 	NewLines      int
 	Diff          string // Represents outcome description
+
+	// MovedTo is the new path of a file the patch moves
+	MovedTo string
+
+	// For a file the patch creates that already existed: whether it was
+	// replaced, or else the path written instead
+	Overwritten bool
+	CreatedAs   string
+
+	// Match statistics for tuning the patch engine
+	Hunks       int               // Hunks of the patch for the file
+	Matches     map[FuzzLevel]int // Deleted lines found, by the level they matched at
+	MissedLines int               // Requested deletions that matched no line
+
+	// Set by PreviewAgentPatchIn for files the patch would change: the
+	// content before, nil for a new file, and after
+	Before *string
+	After  string
 }
@@ -0,0 +1,252 @@
+package fileops
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyPatchText parses patch and applies it to the files under dir
+func applyPatchText(t *testing.T, dir, patch string) ([]*AgentPatchResult, error) {
+	t.Helper()
+	ws, err := NewWorkspace(dir, FollowInWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	operations, err := ParseAgentPatch(patch)
+	if err != nil {
+		t.Fatalf("ParseAgentPatch failed: %v", err)
+	}
+	return ApplyAgentPatchIn(ws, operations)
+}
+
+// writeTestFile writes content to name under dir
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyAgentPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+	}{
+		{
+			"replace in place",
+			"a\nb\nc\n",
+			"// EDIT:\nDEL: b\nADD: B\n// END_EDIT",
+			"a\nB\nc\n",
+		},
+		{
+			"interleaved lines replace a block",
+			"a\nb\nc\nd\n",
+			"// EDIT:\nDEL: b\nADD: B\nDEL: c\nADD: C\n// END_EDIT",
+			"a\nB\nC\nd\n",
+		},
+		{
+			"hunk without deletions appends",
+			"a\n",
+			"// EDIT:\nADD: b\n// END_EDIT",
+			"a\nb\n",
+		},
+		{
+			"only the first occurrence after the previous hunk",
+			"x\nfirst\nx\n",
+			"// EDIT:\nDEL: first\nADD: 1\n// END_EDIT\n// EDIT:\nDEL: x\nADD: y\n// END_EDIT",
+			"x\n1\ny\n",
+		},
+		{
+			"no final newline is kept",
+			"a\nb",
+			"// EDIT:\nDEL: b\nADD: c\n// END_EDIT",
+			"a\nc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestFile(t, dir, "f.txt", tt.content)
+			results, err := applyPatchText(t, dir, "// FILE: f.txt\n"+tt.patch)
+			if err != nil || len(results) != 1 || !results[0].Success {
+				t.Fatalf("Expected the patch to apply, got %+v, %v", results, err)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestApplyAgentPatchRefusesLinesApart(t *testing.T) {
+	dir := t.TempDir()
+	content := "func b() {\n\tx := 2\n\treturn x\n}\n"
+	path := writeTestFile(t, dir, "b.go", content)
+	results, err := applyPatchText(t, dir, "// FILE: b.go\n// EDIT:\nDEL: func b() {\nDEL: }\nADD: func c() {\nADD: }\n// END_EDIT")
+	var mismatch *ContextMismatchError
+	if !errors.As(err, &mismatch) || results[0].Success || !reflect.DeepEqual(mismatch.Missing, []string{"}"}) {
+		t.Fatalf("Expected the lines apart to be reported, got %+v, %v", results[0], err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("Expected the file to be unchanged, got %q", data)
+	}
+}
+
+func TestApplyAgentPatchFuzzLevels(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "main.go", "func a() {}  \n\tfunc b() {}\nfunc c() {}\n")
+	results, err := applyPatchText(t, dir, "// FILE: main.go\n// EDIT:\nDEL: func a() {}\nDEL: func b() {}\nDEL: func c() {}\nADD: func d() {}\n// END_EDIT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[FuzzLevel]int{FuzzExact: 1, FuzzTrailing: 1, FuzzWhitespace: 1}
+	if !reflect.DeepEqual(results[0].Matches, want) {
+		t.Errorf("Unexpected matches %v, want %v", results[0].Matches, want)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "func d() {}\n" {
+		t.Errorf("Unexpected content %q", data)
+	}
+
+	// The strictest level wins: the indented line is not taken for the exact one
+	path = writeTestFile(t, dir, "b.go", "\tx := 1\nx := 1\n")
+	if _, err := applyPatchText(t, dir, "// FILE: b.go\n// EDIT:\nDEL: x := 1\nADD: x := 2\n// END_EDIT"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "\tx := 1\nx := 2\n" {
+		t.Errorf("Expected the exact match to be replaced, got %q", data)
+	}
+}
+
+func TestApplyAgentPatchKeepsCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("package main\r\n\r\nfunc old() {}\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ApplyAgentPatch([]AgentPatchOperation{
+		{Type: "remove", Path: path, Content: "func old() {}"},
+		{Type: "add", Path: path, Content: "func new() {}"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyAgentPatch failed: %v", err)
+	}
+	if results[0].Matches[FuzzExact] != 1 {
+		t.Errorf("Expected the line to match exactly without its \\r, got %+v", results[0])
+	}
+	data, _ := os.ReadFile(path)
+	if want := "package main\r\n\r\nfunc new() {}\r\n"; string(data) != want {
+		t.Errorf("Expected Windows line endings to be kept, got %q, want %q", data, want)
+	}
+}
+
+func TestApplyAgentPatchCreatesFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := applyPatchText(t, dir, "// FILE: sub/new.go\n// EDIT: create\nADD: package sub\n// END_EDIT"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "sub", "new.go")); string(data) != "package sub\n" {
+		t.Errorf("Unexpected new file %q", data)
+	}
+	results, err := applyPatchText(t, dir, "// FILE: missing.go\n// EDIT:\nDEL: x\n// END_EDIT")
+	if err == nil || results[0].Success {
+		t.Errorf("Expected deleting from a missing file to fail, got %+v", results[0])
+	}
+}
+
+func TestApplyAgentPatchMove(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "old.go", "package old\n")
+	writeTestFile(t, dir, "taken.go", "package taken\n")
+
+	results, err := applyPatchText(t, dir, "// FILE: old.go\n// EDIT: rename the package\nDEL: package old\nADD: package renamed\n// END_EDIT\n// MOVE: pkg/renamed.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].MovedTo != filepath.Join(dir, "pkg", "renamed.go") || results[0].Hunks != 2 || !strings.Contains(results[0].Diff, "moving the file to") {
+		t.Errorf("Unexpected result %+v", results[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected old.go to be gone, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "pkg", "renamed.go")); string(data) != "package renamed\n" {
+		t.Errorf("Unexpected moved file %q", data)
+	}
+
+	// A move never overwrites a file, nor leaves the workspace
+	if _, err := applyPatchText(t, dir, "// FILE: taken.go\n// MOVE: pkg/renamed.go"); err == nil || !strings.Contains(err.Error(), "the file exists") {
+		t.Errorf("Expected moving onto an existing file to fail, got %v", err)
+	}
+	if _, err := applyPatchText(t, dir, "// FILE: taken.go\n// MOVE: ../outside.go"); err == nil {
+		t.Error("Expected moving out of the workspace to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "taken.go")); err != nil {
+		t.Errorf("Expected taken.go to stay, got %v", err)
+	}
+}
+
+func TestPreviewAgentPatchIn(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.txt", "one\n")
+	operations, err := ParseAgentPatch("// FILE: a.txt\n// EDIT:\nDEL: one\nADD: two\n// END_EDIT\n// MOVE: b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := PreviewAgentPatchIn(nil, []AgentPatchOperation{
+		{Type: "remove", Path: path, Content: operations[0].Content},
+		{Type: "add", Path: path, Content: operations[1].Content},
+		{Type: "move", Path: path, MoveTo: filepath.Join(dir, "b.txt"), Hunk: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := results[0]
+	if !res.Success || res.Before == nil || *res.Before != "one\n" || res.After != "two\n" || res.MovedTo == "" || !strings.HasPrefix(res.Diff, "Would apply") {
+		t.Errorf("Unexpected preview %+v", res)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\n" {
+		t.Errorf("Expected nothing to be written, got %q", data)
+	}
+}
+
+func TestParseAgentPatchMove(t *testing.T) {
+	operations, err := ParseAgentPatch("// FILE: a.go\n// MOVE: b.go\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AgentPatchOperation{{Type: "move", Path: "a.go", MoveTo: "b.go"}}
+	if !reflect.DeepEqual(operations, want) {
+		t.Errorf("Unexpected operations %+v", operations)
+	}
+	if files := PatchFiles("// FILE: a.go\n// EDIT:\nADD: x\n// END_EDIT\n// MOVE: b.go\n// FILE: c.go\n// EDIT:\nADD: y\n// END_EDIT"); !reflect.DeepEqual(files, []string{"a.go", "b.go", "c.go"}) {
+		t.Errorf("Unexpected files %v", files)
+	}
+
+	var formatErr *PatchFormatError
+	if _, err := ParseAgentPatch("// MOVE: b.go\n"); !errors.As(err, &formatErr) || formatErr.Line != 1 {
+		t.Errorf("Expected a format error for a move without a file, got %v", err)
+	}
+	if _, err := ParseAgentPatch("// FILE: a.go\n// MOVE:\n"); !errors.As(err, &formatErr) || formatErr.Line != 2 {
+		t.Errorf("Expected a format error for a move without a destination, got %v", err)
+	}
+}
+
+func TestUsesCRLF(t *testing.T) {
+	for content, want := range map[string]bool{
+		"a\r\nb\r\n":     true,
+		"a\nb\n":         false,
+		"a\r\nb\nc\r\n":  true,
+		"a\r\nb\nc\nd\n": false,
+		"":               false,
+	} {
+		if got := UsesCRLF(content); got != want {
+			t.Errorf("UsesCRLF(%q) = %t, want %t", content, got, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/changes"
+)
+
+// Preview is what a patch would do, file by file, worked out without
+// writing anything
+type Preview struct {
+	Files      []FilePreview
+	ParseError error // Set if the patch could not be parsed, leaving Files empty
+
+	// Results are those of the dry run, for patch statistics
+	Results []*AgentPatchResult
+}
+
+// FilePreview is what a patch would do to one file
+type FilePreview struct {
+	Path    string  // The file, as resolved
+	MovedTo string  // The new path of a file the patch moves
+	Before  *string // The content before, nil for a new file
+	After   string  // The content after
+	Diff    string  // The unified diff, with paths relative to the workspace root
+	Error   error   // Why the patch cannot be applied to the file
+}
+
+// ValidatePatch parses an agent patch, finds the lines it deletes in each
+// file and works out the diffs it would make, without writing anything. It
+// fails if the patch cannot be applied as a whole: the Preview then says
+// why, file by file.
+func ValidatePatch(patchContent string) (Preview, error) {
+	return ValidatePatchIn(nil, patchContent)
+}
+
+// ValidatePatchIn is ValidatePatch with every path resolved in ws, as
+// ApplyAgentPatchIn resolves them. A nil ws uses the paths as given.
+func ValidatePatchIn(ws *Workspace, patchContent string) (Preview, error) {
+	operations, err := ParseAgentPatch(patchContent)
+	if err != nil {
+		return Preview{ParseError: err}, err
+	}
+	results, err := PreviewAgentPatchIn(ws, operations)
+	root := ""
+	if ws != nil {
+		root = ws.Root
+	}
+	preview := Preview{Results: results}
+	for _, res := range results {
+		preview.Files = append(preview.Files, FilePreview{
+			Path:    res.Path,
+			MovedTo: res.MovedTo,
+			Before:  res.Before,
+			After:   res.After,
+			Diff:    res.UnifiedDiff(root),
+			Error:   res.Error,
+		})
+	}
+	return preview, err
+}
+
+// Diff returns the diffs of all the files
+func (p Preview) Diff() string {
+	var diff string
+	for _, f := range p.Files {
+		diff += f.Diff
+	}
+	return diff
+}
+
+// Feedback explains why the patch cannot be applied, for the model to
+// correct it from: where it breaks the format, or the current content of
+// the files it does not match. It returns "" for a patch that applies.
+func (p Preview) Feedback() string {
+	if p.ParseError != nil {
+		var formatErr *PatchFormatError
+		if errors.As(p.ParseError, &formatErr) {
+			return formatErr.Feedback()
+		}
+		return fmt.Sprintf("Error parsing patch: %v", p.ParseError)
+	}
+	var problems []string
+	for _, f := range p.Files {
+		var mismatch *ContextMismatchError
+		switch {
+		case f.Error == nil:
+		case errors.As(f.Error, &mismatch):
+			problems = append(problems, mismatch.RetryPrompt())
+		default:
+			problems = append(problems, fmt.Sprintf("Error: %v", f.Error))
+		}
+	}
+	return strings.Join(problems, "\n\n")
+}
+
+// UnifiedDiff returns the diff of a file a patch preview would change, with
+// paths relative to root unless root is empty or does not hold them. A
+// moved file shows as deleted at its old path and created at the new one.
+func (res *AgentPatchResult) UnifiedDiff(root string) string {
+	if !res.Success || (res.Before == nil && res.After == "") {
+		return ""
+	}
+	if res.CreatedAs != "" {
+		return changes.Unified(relativePath(root, res.CreatedAs), nil, &res.After)
+	}
+	if res.MovedTo != "" && res.MovedTo != res.Path {
+		return changes.Unified(relativePath(root, res.Path), res.Before, nil) + changes.Unified(relativePath(root, res.MovedTo), nil, &res.After)
+	}
+	return changes.Unified(relativePath(root, res.Path), res.Before, &res.After)
+}
+
+// relativePath returns path relative to root for diffs, or path itself
+// outside it
+func relativePath(root, path string) string {
+	if root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
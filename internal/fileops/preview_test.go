@@ -0,0 +1,48 @@
+package fileops
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidatePatchIn(t *testing.T) {
+	ws, _ := newTestWorkspace(t, FollowInWorkspace)
+	writeTestFile(t, ws.Root, "a.txt", "one\ntwo\n")
+	writeTestFile(t, ws.Root, "b.txt", "keep\n")
+
+	preview, err := ValidatePatchIn(ws, "// FILE: a.txt\n// EDIT:\nDEL: two\nADD: three\n// END_EDIT\n// FILE: b.txt\n// MOVE: c.txt")
+	if err != nil {
+		t.Fatalf("ValidatePatchIn failed: %v", err)
+	}
+	if len(preview.Files) != 2 || preview.Files[0].After != "one\nthree\n" || preview.Feedback() != "" {
+		t.Fatalf("Unexpected preview %+v", preview)
+	}
+	diff := preview.Diff()
+	for _, want := range []string{"--- a/a.txt\n+++ b/a.txt\n", "-two\n+three\n", "--- a/b.txt\n+++ /dev/null\n", "--- /dev/null\n+++ b/c.txt\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in the diff:\n%s", want, diff)
+		}
+	}
+	if data, _ := os.ReadFile(preview.Files[0].Path); string(data) != "one\ntwo\n" {
+		t.Errorf("Expected nothing to be written, got %q", data)
+	}
+
+	// A file that does not match fails the patch, with its current content
+	// for the model, while the others are still previewed
+	preview, err = ValidatePatchIn(ws, "// FILE: a.txt\n// EDIT:\nDEL: gone\n// END_EDIT\n// FILE: b.txt\n// EDIT:\nDEL: keep\nADD: kept\n// END_EDIT")
+	var mismatch *ContextMismatchError
+	if !errors.As(err, &mismatch) || preview.Files[0].Error == nil || preview.Files[1].Diff == "" {
+		t.Fatalf("Expected the first file to fail, got %v, %+v", err, preview)
+	}
+	if feedback := preview.Feedback(); !strings.Contains(feedback, "gone") || !strings.Contains(feedback, "one") {
+		t.Errorf("Expected the missing line and the file's content in the feedback, got %q", feedback)
+	}
+
+	// A patch that cannot be parsed says where
+	preview, err = ValidatePatch("// FILE: a.txt\n  ADD: x")
+	if err == nil || len(preview.Files) != 0 || !strings.HasPrefix(preview.Feedback(), "Error parsing patch: ") {
+		t.Errorf("Expected a format error, got %v, %q", err, preview.Feedback())
+	}
+}
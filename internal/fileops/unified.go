@@ -0,0 +1,224 @@
+package fileops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IsUnifiedDiff reports whether a patch is a unified diff, as written by
+// diff -u or git diff, rather than an agent patch: it has a diff --git line
+// or a ---/+++ file header, and no // FILE: marker
+func IsUnifiedDiff(patchContent string) bool {
+	lines := strings.Split(patchContent, "\n")
+	unified := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), "// FILE:"):
+			return false
+		case strings.HasPrefix(line, "diff --git "), isFileHeader(lines, i):
+			unified = true
+		}
+	}
+	return unified
+}
+
+// UnifiedToAgentPatch rewrites a unified diff as the agent patch making the
+// same changes. Each @@ hunk becomes an // EDIT: block whose DEL: lines are
+// the hunk's context and - lines and whose ADD: lines are its context and +
+// lines, so that the context places the block as it does in the diff; a
+// hunk that only adds lines is placed by its line number instead. A file
+// created from /dev/null gets ADD: lines only, and a rename in a git diff
+// becomes a // MOVE: marker. Outside git diffs the +++ path is the file
+// patched, as diff -u old new leaves the old name in the --- line. Deleting
+// a file is not supported.
+func UnifiedToAgentPatch(diff string) (string, error) {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	var b strings.Builder
+	var oldPath, newPath string
+	started := false // Whether the // FILE: marker of the file was written
+	isGit := false   // Whether the file's diff started with diff --git
+
+	// finishFile ends the file the previous lines were about, writing its
+	// move if git renamed it, which is all there is to a rename without hunks
+	finishFile := func() {
+		if isGit && oldPath != newPath && oldPath != "" && newPath != "" && oldPath != "/dev/null" {
+			if !started {
+				fmt.Fprintf(&b, "// FILE: %s\n", oldPath)
+			}
+			fmt.Fprintf(&b, "// MOVE: %s\n", newPath)
+		}
+		oldPath, newPath, started, isGit = "", "", false, false
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		lineError := func(problem, hint string) error {
+			return &PatchFormatError{Line: i + 1, Text: line, Problem: problem, Hint: hint}
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			finishFile()
+			oldPath, newPath = gitDiffPaths(strings.TrimPrefix(line, "diff --git "))
+			isGit = true
+		case isGit && strings.HasPrefix(line, "rename from "):
+			oldPath = strings.TrimPrefix(line, "rename from ")
+		case isGit && strings.HasPrefix(line, "rename to "):
+			newPath = strings.TrimPrefix(line, "rename to ")
+		case isFileHeader(lines, i):
+			if started {
+				finishFile()
+			}
+			headerOld := diffHeaderPath(strings.TrimPrefix(line, "--- "))
+			headerNew := diffHeaderPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			if headerNew == "/dev/null" {
+				return "", lineError("deleting a file is not supported", "Delete it with a shell command instead.")
+			}
+			i++
+			// A git rename keeps the paths of its diff --git line
+			if !isGit || oldPath == newPath || headerOld == "/dev/null" {
+				oldPath, newPath = headerOld, headerNew
+			}
+		case strings.HasPrefix(line, "@@"):
+			oldStart, oldCount, newCount, section, ok := parseHunkHeader(line)
+			if !ok {
+				return "", lineError("malformed @@ hunk header", "Write it as \"@@ -start,count +start,count @@\".")
+			}
+			path := newPath
+			if isGit && oldPath != "/dev/null" {
+				path = oldPath
+			}
+			if path == "" {
+				return "", lineError("found an @@ hunk before a ---/+++ file header", "Start each file with its --- a/path and +++ b/path lines.")
+			}
+			if !started {
+				fmt.Fprintf(&b, "// FILE: %s\n", path)
+				started = true
+			}
+			// A hunk without context or deletions, as diff -U0 writes
+			// insertions, has nothing to find it by but the line it follows
+			header := fmt.Sprintf("line %d", oldStart)
+			if oldPath == "/dev/null" {
+				header = "create"
+			} else if oldCount == 0 {
+				header = fmt.Sprintf("after line %d", oldStart)
+			}
+			if section != "" {
+				header += " " + section
+			}
+			fmt.Fprintf(&b, "// EDIT: %s\n", header)
+
+			// The counts of the header say where the hunk ends; past them,
+			// lines that look like hunk lines are still taken, as models
+			// often miscount
+			for i+1 < len(lines) && !isHunkStart(lines, i+1) {
+				next := lines[i+1]
+				counted := oldCount > 0 || newCount > 0
+				if next == "" {
+					if !counted {
+						break
+					}
+					next = " " // A blank context line that lost its space
+				}
+				if !counted && !strings.ContainsRune(" -+\\", rune(next[0])) {
+					break
+				}
+				text := next[1:]
+				switch next[0] {
+				case ' ':
+					fmt.Fprintf(&b, "DEL: %s\nADD: %s\n", text, text)
+					oldCount--
+					newCount--
+				case '-':
+					fmt.Fprintf(&b, "DEL: %s\n", text)
+					oldCount--
+				case '+':
+					fmt.Fprintf(&b, "ADD: %s\n", text)
+					newCount--
+				case '\\':
+					// "\ No newline at end of file": the file keeps its final newline or lack of one
+				default:
+					return "", &PatchFormatError{Line: i + 2, Text: lines[i+1], Problem: "lines in an @@ hunk must start with a space, - or +", Hint: "Start context lines with a space, even blank ones."}
+				}
+				i++
+			}
+			b.WriteString("// END_EDIT\n")
+		}
+	}
+	finishFile()
+	return b.String(), nil
+}
+
+// isFileHeader reports whether lines[i] and the line after it are the
+// ---/+++ header of a file
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// isHunkStart reports whether lines[i] starts a hunk or a file of a diff
+func isHunkStart(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "@@") || strings.HasPrefix(lines[i], "diff --git ") || isFileHeader(lines, i)
+}
+
+// diffHeaderPath returns the path of a ---/+++ line without its a/ or b/
+// prefix and any timestamp after a tab
+func diffHeaderPath(path string) string {
+	path, _, _ = strings.Cut(path, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return path
+	}
+	return stripDiffPrefix(path)
+}
+
+// gitDiffPaths returns the two paths of a diff --git line
+func gitDiffPaths(paths string) (string, string) {
+	if i := strings.Index(paths, " b/"); strings.HasPrefix(paths, "a/") && i >= 0 {
+		return stripDiffPrefix(paths[:i]), stripDiffPrefix(paths[i+1:])
+	}
+	oldPath, newPath, _ := strings.Cut(paths, " ")
+	return oldPath, newPath
+}
+
+// stripDiffPrefix removes the a/ or b/ git puts before paths
+func stripDiffPrefix(path string) string {
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@ section". A missing count is 1.
+func parseHunkHeader(line string) (oldStart, oldCount, newCount int, section string, ok bool) {
+	fields := strings.SplitN(strings.TrimPrefix(line, "@@"), "@@", 2)
+	if len(fields) == 2 {
+		section = strings.TrimSpace(fields[1])
+	}
+	ranges := strings.Fields(fields[0])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return 0, 0, 0, "", false
+	}
+	oldStart, oldCount, ok = parseHunkRange(ranges[0][1:])
+	if !ok {
+		return 0, 0, 0, "", false
+	}
+	_, newCount, ok = parseHunkRange(ranges[1][1:])
+	return oldStart, oldCount, newCount, section, ok
+}
+
+// parseHunkRange parses the "start,count" of a hunk header
+func parseHunkRange(r string) (start, count int, ok bool) {
+	startText, countText, hasCount := strings.Cut(r, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, false
+	}
+	count = 1
+	if hasCount {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, count, true
+}
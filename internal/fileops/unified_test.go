@@ -0,0 +1,142 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsUnifiedDiff(t *testing.T) {
+	for patch, want := range map[string]bool{
+		"--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+y":                  true,
+		"diff --git a/a.go b/b.go\nrename from a.go\nrename to b.go":   true,
+		"// FILE: a.go\n// EDIT:\nDEL: --- x\nADD: +++ y\n// END_EDIT": false,
+		"@@ -1 +1 @@\n-x\n+y": false,
+	} {
+		if got := IsUnifiedDiff(patch); got != want {
+			t.Errorf("IsUnifiedDiff(%q) = %t, want %t", patch, got, want)
+		}
+	}
+}
+
+func TestUnifiedToAgentPatch(t *testing.T) {
+	tests := []struct {
+		name, diff, want string
+	}{
+		{
+			"context and changes",
+			"--- a/main.go\t2024-01-01 00:00:00\n+++ b/main.go\n@@ -1,3 +1,3 @@ func main() {\n a\n-b\n+B\n c\n",
+			"// FILE: main.go\n// EDIT: line 1 func main() {\nDEL: a\nADD: a\nDEL: b\nADD: B\nDEL: c\nADD: c\n// END_EDIT\n",
+		},
+		{
+			"new file",
+			"diff --git a/new.go b/new.go\nnew file mode 100644\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1,2 @@\n+package x\n+\n",
+			"// FILE: new.go\n// EDIT: create\nADD: package x\nADD: \n// END_EDIT\n",
+		},
+		{
+			"rename with changes",
+			"diff --git a/old.go b/new.go\nsimilarity index 90%\nrename from old.go\nrename to new.go\n--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-package old\n+package new\n",
+			"// FILE: old.go\n// EDIT: line 1\nDEL: package old\nADD: package new\n// END_EDIT\n// MOVE: new.go\n",
+		},
+		{
+			"pure rename then another file",
+			"diff --git a/a.go b/b.go\nsimilarity index 100%\nrename from a.go\nrename to b.go\ndiff --git a/c.go b/c.go\n--- a/c.go\n+++ b/c.go\n@@ -1 +1 @@\n-x\n+y\n\\ No newline at end of file\n",
+			"// FILE: a.go\n// MOVE: b.go\n// FILE: c.go\n// EDIT: line 1\nDEL: x\nADD: y\n// END_EDIT\n",
+		},
+		{
+			"plain diff of a backup takes the new name",
+			"--- main.go.orig\n+++ main.go\n@@ -1 +1 @@\n-x\n+y\n",
+			"// FILE: main.go\n// EDIT: line 1\nDEL: x\nADD: y\n// END_EDIT\n",
+		},
+		{
+			"insertion without context",
+			"--- a/a.txt\n+++ b/a.txt\n@@ -1,0 +2 @@\n+inserted\n",
+			"// FILE: a.txt\n// EDIT: after line 1\nADD: inserted\n// END_EDIT\n",
+		},
+		{
+			"miscounted hunk and blank context line",
+			"--- a/a.txt\n+++ b/a.txt\n@@ -1,1 +1,1 @@\n-x\n+y\n\n z\n+w\n",
+			"// FILE: a.txt\n// EDIT: line 1\nDEL: x\nADD: y\n// END_EDIT\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnifiedToAgentPatch(tt.diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Unexpected patch:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "one\ntwo\nthree\ntwo\nfive\n")
+	writeTestFile(t, dir, "old.txt", "keep\n")
+
+	// The context picks the second "two"
+	diff := "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -3,3 +3,3 @@\n three\n-two\n+four\n five\n" +
+		"diff --git a/old.txt b/sub/new.txt\nrename from old.txt\nrename to sub/new.txt\n" +
+		"diff --git a/created.txt b/created.txt\n--- /dev/null\n+++ b/created.txt\n@@ -0,0 +1 @@\n+hello\n"
+	if _, err := applyPatchText(t, dir, diff); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"a.txt":       "one\ntwo\nthree\nfour\nfive\n",
+		"sub/new.txt": "keep\n",
+		"created.txt": "hello\n",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Errorf("Expected %s to be %q, got %q, %v", name, want, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected old.txt to be moved, got %v", err)
+	}
+}
+
+func TestApplyUnifiedDiffWithoutContext(t *testing.T) {
+	tests := []struct {
+		name, diff, want string
+	}{
+		{
+			"insertion after a line",
+			"@@ -1,0 +2 @@\n+inserted\n",
+			"one\ninserted\ntwo\nthree\nfour\n",
+		},
+		{
+			"insertion at the top",
+			"@@ -0,0 +1 @@\n+inserted\n",
+			"inserted\none\ntwo\nthree\nfour\n",
+		},
+		{
+			// Line 3 is that of the file before the hunks above it
+			"hunks after other hunks",
+			"@@ -1 +1,2 @@\n-one\n+1\n+1.5\n@@ -3,0 +4 @@\n+3.5\n@@ -4,0 +6 @@\n+4.5\n",
+			"1\n1.5\ntwo\nthree\n3.5\nfour\n4.5\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestFile(t, dir, "a.txt", "one\ntwo\nthree\nfour\n")
+			if _, err := applyPatchText(t, dir, "--- a/a.txt\n+++ b/a.txt\n"+tt.diff); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, data)
+			}
+		})
+	}
+
+	// A line past the end of the file is not taken for the end
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "one\n")
+	if _, err := applyPatchText(t, dir, "--- a/a.txt\n+++ b/a.txt\n@@ -5,0 +6 @@\n+x\n"); err == nil || !strings.Contains(err.Error(), "past the end of") {
+		t.Errorf("Expected an insertion past the end to fail, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/epuerta/codex-go/internal/ignore"
+)
+
+// maxLinkHops bounds how many symbolic links one path may go through, so
+// link loops fail instead of spinning
+const maxLinkHops = 40
+
+var (
+	// ErrOutsideWorkspace is returned for paths that resolve outside the workspace
+	ErrOutsideWorkspace = errors.New("path is outside the workspace")
+	// ErrSymlinkRefused is returned for paths through a symbolic link when links are refused
+	ErrSymlinkRefused = errors.New("path goes through a symbolic link")
+	// ErrLinkLoop is returned when resolving a path takes more than maxLinkHops links
+	ErrLinkLoop = errors.New("too many levels of symbolic links")
+	// ErrIgnored is returned for paths excluded by the .codexignore file
+	ErrIgnored = errors.New("path is excluded by " + ignore.FileName)
+)
+
+// SymlinkPolicy says how paths through symbolic links inside the workspace
+// are handled
+type SymlinkPolicy int
+
+const (
+	// FollowInWorkspace follows links as long as every target stays inside
+	// the workspace
+	FollowInWorkspace SymlinkPolicy = iota
+	// RefuseSymlinks refuses any path that goes through a link inside the
+	// workspace
+	RefuseSymlinks
+)
+
+// ParseSymlinkPolicy parses a policy name: "follow" (the default when empty)
+// or "refuse"
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	switch name {
+	case "", "follow":
+		return FollowInWorkspace, nil
+	case "refuse":
+		return RefuseSymlinks, nil
+	}
+	return 0, fmt.Errorf("unknown symlink policy %q (want follow or refuse)", name)
+}
+
+// Workspace confines file operations to its allowed roots. Resolve
+// canonicalizes every path, so two spellings of the same file, through
+// links or "..", come out the same and nothing escapes the roots.
+type Workspace struct {
+	Root   string   // Canonical absolute path of the workspace; relative paths start here
+	Roots  []string // Canonical directories paths may resolve into; Root alone if empty
+	Policy SymlinkPolicy
+	Ignore *ignore.Matcher // Paths hidden from file operations; nil hides none
+
+	// OnCreate is what patches creating a file that already exists do
+	OnCreate CreateConflict
+
+	mu      sync.Mutex
+	granted map[string]int // Paths outside the roots allowed by Grant, with the grants in force
+}
+
+// NewWorkspace creates a workspace rooted at root, or at the current
+// directory if root is empty
+func NewWorkspace(root string, policy SymlinkPolicy) (*Workspace, error) {
+	if root == "" {
+		root = "."
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace %s: %w", root, err)
+	}
+	canonical, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace %s: %w", root, err)
+	}
+	return &Workspace{Root: canonical, Policy: policy}, nil
+}
+
+// RepositoryRoot returns the nearest directory holding .git, from dir up,
+// or "" outside a repository
+func RepositoryRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// SetRoots replaces the directories paths may resolve into. Relative roots
+// are taken from the workspace root; every root must exist.
+func (w *Workspace) SetRoots(roots []string) error {
+	canonical := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(w.Root, root)
+		}
+		resolved, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return fmt.Errorf("failed to resolve allowed root %s: %w", root, err)
+		}
+		canonical = append(canonical, resolved)
+	}
+	w.Roots = canonical
+	return nil
+}
+
+// AllowedRoots returns the directories paths may resolve into
+func (w *Workspace) AllowedRoots() []string {
+	if len(w.Roots) == 0 {
+		return []string{w.Root}
+	}
+	return w.Roots
+}
+
+// Outside returns the canonical path of path and reports whether it lies
+// outside the allowed roots, so that writing it needs a Grant. Paths that
+// cannot be resolved at all are not outside: Resolve refuses them anyway.
+func (w *Workspace) Outside(path string) (string, bool) {
+	resolved, err := w.resolve(path)
+	if err != nil {
+		return "", false
+	}
+	return resolved, !w.contains(resolved)
+}
+
+// Grant lets Resolve return the canonical paths given, which lie outside
+// the allowed roots, until the returned function is called. It is meant for
+// a write the user approved.
+func (w *Workspace) Grant(paths []string) (revoke func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.granted == nil {
+		w.granted = make(map[string]int)
+	}
+	for _, path := range paths {
+		w.granted[path]++
+	}
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for _, path := range paths {
+			if w.granted[path]--; w.granted[path] <= 0 {
+				delete(w.granted, path)
+			}
+		}
+	}
+}
+
+// isGranted reports whether a canonical path outside the roots is granted
+func (w *Workspace) isGranted(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.granted[path] > 0
+}
+
+// Ignored reports whether the canonical path is excluded by the ignore file
+func (w *Workspace) Ignored(path string, isDir bool) bool {
+	return w != nil && w.Ignore.Ignored(path, isDir)
+}
+
+// Resolve returns the canonical absolute path of path, which is taken
+// relative to the workspace root unless absolute. Links are resolved as the
+// policy says; components that do not exist yet are kept as written, so
+// paths of files about to be created resolve too. Paths outside the allowed
+// roots are refused unless granted, and ignored paths are refused always.
+func (w *Workspace) Resolve(path string) (string, error) {
+	resolved, err := w.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if !w.contains(resolved) && !w.isGranted(resolved) {
+		return "", fmt.Errorf("%s resolves to %s: %w %s", path, resolved, ErrOutsideWorkspace, strings.Join(w.AllowedRoots(), ", "))
+	}
+	if w.Ignore.Len() > 0 {
+		info, err := os.Stat(resolved)
+		if w.Ignored(resolved, err == nil && info.IsDir()) {
+			return "", fmt.Errorf("%s: %w", path, ErrIgnored)
+		}
+	}
+	return resolved, nil
+}
+
+// resolve canonicalizes path without checking that it stays in the roots
+func (w *Workspace) resolve(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.Root, path)
+	}
+
+	volume := filepath.VolumeName(path)
+	resolved := volume + string(filepath.Separator)
+	pending := splitPath(path[len(volume):])
+	hops := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			// resolved never contains links, so its parent is the real parent
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		info, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			resolved = filepath.Join(append([]string{next}, pending...)...)
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if !isLink(info) {
+			resolved = next
+			continue
+		}
+
+		// Links above the workspace, such as /tmp -> /private/tmp, are part
+		// of the system rather than the project and are always followed
+		if w.Policy == RefuseSymlinks && w.contains(resolved) {
+			return "", fmt.Errorf("%s: %w (%s)", path, ErrSymlinkRefused, w.rel(next))
+		}
+		hops++
+		if hops > maxLinkHops {
+			return "", fmt.Errorf("%s: %w", path, ErrLinkLoop)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", fmt.Errorf("failed to read link %s: %w", next, err)
+		}
+		if filepath.IsAbs(target) {
+			volume = filepath.VolumeName(target)
+			resolved = volume + string(filepath.Separator)
+			target = target[len(volume):]
+		}
+		pending = append(splitPath(target), pending...)
+	}
+
+	return filepath.Clean(resolved), nil
+}
+
+// contains reports whether the canonical path is an allowed root or inside one
+func (w *Workspace) contains(path string) bool {
+	for _, root := range w.AllowedRoots() {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// rel returns path relative to the root for messages, or path itself
+func (w *Workspace) rel(path string) string {
+	if rel, err := filepath.Rel(w.Root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// splitPath splits a path into its non-empty components
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return os.IsPathSeparator(uint8(r)) })
+}
@@ -0,0 +1,211 @@
+package fileops
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/ignore"
+)
+
+// newTestWorkspace creates a workspace in a temporary directory next to a
+// directory outside it
+func newTestWorkspace(t *testing.T, policy SymlinkPolicy) (*Workspace, string) {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "work")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "src"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewWorkspace(root, policy)
+	if err != nil {
+		t.Fatalf("NewWorkspace failed: %v", err)
+	}
+	outside, _ = filepath.EvalSymlinks(outside)
+	return ws, outside
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Symbolic links not supported: %v", err)
+	}
+}
+
+func TestWorkspaceResolve(t *testing.T) {
+	ws, outside := newTestWorkspace(t, FollowInWorkspace)
+	root := ws.Root
+	symlink(t, "src", filepath.Join(root, "code"))
+	symlink(t, outside, filepath.Join(root, "escape"))
+	symlink(t, "../../outside/secret", filepath.Join(root, "src", "relative-escape"))
+	symlink(t, "loop-b", filepath.Join(root, "loop-a"))
+	symlink(t, "loop-a", filepath.Join(root, "loop-b"))
+	symlink(t, "self", filepath.Join(root, "self"))
+	symlink(t, "missing.go", filepath.Join(root, "dangling"))
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{"src/main.go", filepath.Join(root, "src", "main.go"), nil},
+		{"./src/../src/main.go", filepath.Join(root, "src", "main.go"), nil},
+		{filepath.Join(root, "code", "new", "file.go"), filepath.Join(root, "src", "new", "file.go"), nil},
+		{"code/../README.md", filepath.Join(root, "README.md"), nil},
+		{"dangling", filepath.Join(root, "missing.go"), nil},
+		{".", root, nil},
+		{"../outside/file", "", ErrOutsideWorkspace},
+		{"escape/file", "", ErrOutsideWorkspace},
+		{"src/relative-escape", "", ErrOutsideWorkspace},
+		{"loop-a/file", "", ErrLinkLoop},
+		{"self", "", ErrLinkLoop},
+	}
+	for _, tt := range tests {
+		got, err := ws.Resolve(tt.path)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Resolve(%q) = %q, %v; want error %v", tt.path, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestWorkspaceRefuseSymlinks(t *testing.T) {
+	ws, _ := newTestWorkspace(t, RefuseSymlinks)
+	symlink(t, "src", filepath.Join(ws.Root, "code"))
+
+	if _, err := ws.Resolve("code/main.go"); !errors.Is(err, ErrSymlinkRefused) {
+		t.Errorf("Expected a link inside the workspace to be refused, got %v", err)
+	}
+	if got, err := ws.Resolve("src/main.go"); err != nil || got != filepath.Join(ws.Root, "src", "main.go") {
+		t.Errorf("Resolve(src/main.go) = %q, %v", got, err)
+	}
+
+	// Links above the workspace root are followed even when refusing links
+	alias := filepath.Join(t.TempDir(), "alias")
+	symlink(t, ws.Root, alias)
+	if got, err := ws.Resolve(filepath.Join(alias, "src", "main.go")); err != nil || got != filepath.Join(ws.Root, "src", "main.go") {
+		t.Errorf("Expected a path through a link to the root to resolve, got %q, %v", got, err)
+	}
+}
+
+func TestApplyAgentPatchInWorkspace(t *testing.T) {
+	ws, outside := newTestWorkspace(t, FollowInWorkspace)
+	target := filepath.Join(ws.Root, "src", "notes.txt")
+	if err := os.WriteFile(target, []byte("one\ntwo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, "src", filepath.Join(ws.Root, "code"))
+	symlink(t, outside, filepath.Join(ws.Root, "escape"))
+
+	// Both spellings reach the same file, so they are applied as one patch,
+	// the added line taking the place of the deleted one
+	results, err := ApplyAgentPatchIn(ws, []AgentPatchOperation{
+		{Type: "remove", Path: "src/notes.txt", Content: "one"},
+		{Type: "add", Path: "code/notes.txt", Content: "three"},
+		{Type: "add", Path: "escape/evil.txt", Content: "pwned"},
+	})
+	if !errors.Is(err, ErrOutsideWorkspace) {
+		t.Errorf("Expected the escaping path to fail, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected one refused and one applied result, got %d", len(results))
+	}
+	data, _ := os.ReadFile(target)
+	if string(data) != "three\ntwo" {
+		t.Errorf("Unexpected content %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside the workspace, got %v", err)
+	}
+}
+
+func TestWorkspaceRoots(t *testing.T) {
+	ws, outside := newTestWorkspace(t, FollowInWorkspace)
+	shared := filepath.Join(filepath.Dir(ws.Root), "shared")
+	if err := os.Mkdir(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative roots start at the workspace root, and paths may resolve
+	// into any of them
+	if err := ws.SetRoots([]string{".", "../shared"}); err != nil {
+		t.Fatalf("SetRoots failed: %v", err)
+	}
+	if _, err := ws.Resolve("../shared/lib.go"); err != nil {
+		t.Errorf("Expected a path in a second root to resolve, got %v", err)
+	}
+	if _, err := ws.Resolve("../outside/file"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Errorf("Expected a path outside the roots to be refused, got %v", err)
+	}
+	if err := ws.SetRoots([]string{"missing"}); err == nil {
+		t.Error("Expected a missing root to be refused")
+	}
+
+	// A granted path resolves until the grant is revoked
+	target, isOutside := ws.Outside("../outside/file")
+	if !isOutside || target != filepath.Join(outside, "file") {
+		t.Fatalf("Expected ../outside/file to be outside, got %q, %t", target, isOutside)
+	}
+	if _, isOutside := ws.Outside("src/main.go"); isOutside {
+		t.Error("Expected src/main.go to be inside")
+	}
+	revoke := ws.Grant([]string{target})
+	if resolved, err := ws.Resolve("../outside/file"); err != nil || resolved != target {
+		t.Errorf("Expected the granted path to resolve, got %q, %v", resolved, err)
+	}
+	if _, err := ws.Resolve("../outside/other"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Errorf("Expected only the granted path to resolve, got %v", err)
+	}
+	revoke()
+	if _, err := ws.Resolve("../outside/file"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Errorf("Expected the revoked path to be refused, got %v", err)
+	}
+}
+
+func TestRepositoryRoot(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := RepositoryRoot(sub); got != root {
+		t.Errorf("RepositoryRoot(%s) = %q, want %q", sub, got, root)
+	}
+}
+
+func TestWorkspaceIgnore(t *testing.T) {
+	ws, _ := newTestWorkspace(t, FollowInWorkspace)
+	if err := os.MkdirAll(filepath.Join(ws.Root, "secrets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ws.Ignore = ignore.New(ws.Root, []string{".env", "secrets/"})
+
+	for _, path := range []string{".env", "src/.env", "secrets", "secrets/key.pem"} {
+		if _, err := ws.Resolve(path); !errors.Is(err, ErrIgnored) {
+			t.Errorf("Expected %s to be refused as ignored, got %v", path, err)
+		}
+	}
+	if _, err := ws.Resolve("src/main.go"); err != nil {
+		t.Errorf("Expected src/main.go to resolve, got %v", err)
+	}
+
+	// Patches cannot touch ignored files either
+	if _, err := ApplyAgentPatchIn(ws, []AgentPatchOperation{{Type: "add", Path: ".env", Content: "TOKEN=x"}}); !errors.Is(err, ErrIgnored) {
+		t.Errorf("Expected patching an ignored file to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, ".env")); !os.IsNotExist(err) {
+		t.Errorf("Expected .env not to be written, got %v", err)
+	}
+}
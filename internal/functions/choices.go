@@ -0,0 +1,96 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChoicesToolName is the function the model calls to ask the user to pick
+// one of several options
+const ChoicesToolName = "present_choices"
+
+// maxChoices bounds the options of one question, so each can be picked with
+// a digit key
+const maxChoices = 9
+
+// Chooser shows a question with options to the user and returns the index
+// of the option picked, or -1 if the user dismissed the question
+type Chooser interface {
+	Choose(ctx context.Context, title string, options []string) (int, error)
+}
+
+// ChooserFunc adapts a function to a Chooser
+type ChooserFunc func(ctx context.Context, title string, options []string) (int, error)
+
+// Choose calls f
+func (f ChooserFunc) Choose(ctx context.Context, title string, options []string) (int, error) {
+	return f(ctx, title, options)
+}
+
+// choicesParams are the arguments of present_choices
+type choicesParams struct {
+	Title   string   `json:"title"`
+	Options []string `json:"options"`
+}
+
+// ChoicesTool describes present_choices, which asks the user through
+// chooser. It is only offered where someone can answer.
+func ChoicesTool(chooser Chooser) Tool {
+	return Tool{
+		Name:        ChoicesToolName,
+		Description: fmt.Sprintf("Ask the user to pick one of 2 to %d options, shown as a selectable list. Use this instead of an open question whenever the answer is one of a few known alternatives, such as which approach to take or which file to change. The result names the option picked.", maxChoices),
+		Parameters: objectSchema([]string{"title", "options"}, map[string]interface{}{
+			"title": stringParam("The question to ask, e.g. \"Which logging library should I use?\""),
+			"options": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"minItems":    2,
+				"maxItems":    maxChoices,
+				"description": "The options, each a short phrase",
+			},
+		}),
+		Handler: func(ctx context.Context, args string) (string, error) {
+			var params choicesParams
+			if err := json.Unmarshal([]byte(args), &params); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			title, options, err := checkChoices(params)
+			if err != nil {
+				return "", err
+			}
+			picked, err := chooser.Choose(ctx, title, options)
+			if err != nil {
+				return "", err
+			}
+			if picked < 0 || picked >= len(options) {
+				return "The user dismissed the question without picking an option. Ask in plain text if you still need an answer.", nil
+			}
+			return fmt.Sprintf("The user picked option %d: %s", picked+1, options[picked]), nil
+		},
+	}
+}
+
+// checkChoices validates the question and options, trimming them
+func checkChoices(params choicesParams) (string, []string, error) {
+	title := strings.TrimSpace(params.Title)
+	if title == "" {
+		return "", nil, errors.New("missing title argument")
+	}
+	seen := make(map[string]bool)
+	var options []string
+	for _, option := range params.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || seen[option] {
+			continue
+		}
+		seen[option] = true
+		options = append(options, option)
+	}
+	if len(options) < 2 || len(options) > maxChoices {
+		return "", nil, fmt.Errorf("give between 2 and %d distinct options, not %d", maxChoices, len(options))
+	}
+	return title, options, nil
+}
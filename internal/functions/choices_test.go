@@ -0,0 +1,46 @@
+package functions
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChoicesTool(t *testing.T) {
+	var asked []string
+	pick := 1
+	tool := ChoicesTool(ChooserFunc(func(ctx context.Context, title string, options []string) (int, error) {
+		asked = append([]string{title}, options...)
+		return pick, nil
+	}))
+	if tool.Name != ChoicesToolName || tool.Parameters == nil {
+		t.Fatalf("Unexpected tool %+v", tool)
+	}
+	ctx := context.Background()
+
+	out, err := tool.Handler(ctx, `{"title":" Which store? ","options":["SQLite"," Postgres ","SQLite",""]}`)
+	if err != nil || out != "The user picked option 2: Postgres" {
+		t.Errorf("Handler = %q, %v", out, err)
+	}
+	if want := []string{"Which store?", "SQLite", "Postgres"}; !reflect.DeepEqual(asked, want) {
+		t.Errorf("Asked %q, want %q", asked, want)
+	}
+
+	pick = -1
+	if out, err := tool.Handler(ctx, `{"title":"Which store?","options":["SQLite","Postgres"]}`); err != nil || !strings.Contains(out, "dismissed") {
+		t.Errorf("Handler after dismissal = %q, %v", out, err)
+	}
+
+	errTests := map[string]string{
+		`{"options":["a","b"]}`:                                                 "missing title",
+		`{"title":"Pick","options":["a","a"]}`:                                  "between 2 and 9 distinct options, not 1",
+		`{"title":"Pick","options":["1","2","3","4","5","6","7","8","9","10"]}`: "not 10",
+		`not json`: "invalid arguments",
+	}
+	for args, want := range errTests {
+		if _, err := tool.Handler(ctx, args); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Handler(%s) error = %v, want %q", args, err, want)
+		}
+	}
+}
@@ -7,69 +7,457 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/epuerta/codex-go/internal/agent"
 	"github.com/epuerta/codex-go/internal/fileops"
 	"github.com/epuerta/codex-go/internal/sandbox"
 )
 
-// Registry holds registered functions
-type Registry struct {
-	functions map[string]Function
-}
-
 // Function represents a function that can be called by the agent
 type Function func(args string) (string, error)
 
+// ContextFunction is a Function that receives the context of the run, so it
+// can stop when the run is cancelled
+type ContextFunction func(ctx context.Context, args string) (string, error)
+
+// Tool is a function the agent can call. Parameters is the JSON Schema of
+// its arguments, which Handler receives as a JSON string. Tools without
+// parameters can be called but are not offered to the model.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+	Handler     ContextFunction
+	Source      string // Where the tool comes from, e.g. "plugin lint"; empty for core tools
+}
+
+// Origin names where the tool comes from, "core" for built-in tools
+func (t Tool) Origin() string {
+	if t.Source == "" {
+		return "core"
+	}
+	return t.Source
+}
+
+// Definition describes the tool to the model
+func (t Tool) Definition() agent.ToolDefinition {
+	return agent.ToolDefinition{
+		Type:     "function",
+		Function: agent.FunctionDef{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+	}
+}
+
+// Registry holds the tools the agent can call. It is the single source of
+// both the tool list offered to the model and the handlers that run them.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string // Tool names in registration order
+}
+
 // NewRegistry creates a new function registry
 func NewRegistry() *Registry {
-	return &Registry{
-		functions: make(map[string]Function),
+	return &Registry{tools: make(map[string]Tool)}
+}
+
+// RegisterTool adds a tool to the registry, replacing any tool of the same name
+func (r *Registry) RegisterTool(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[tool.Name]; !ok {
+		r.order = append(r.order, tool.Name)
 	}
+	r.tools[tool.Name] = tool
 }
 
-// Register adds a function to the registry
+// Register adds a function without a schema to the registry
 func (r *Registry) Register(name string, fn Function) {
-	r.functions[name] = fn
+	r.RegisterTool(Tool{Name: name, Handler: withoutContext(fn)})
 }
 
-// Get retrieves a function from the registry
+// RegisterContext adds a context-aware function without a schema to the registry
+func (r *Registry) RegisterContext(name string, fn ContextFunction) {
+	r.RegisterTool(Tool{Name: name, Handler: fn})
+}
+
+// Lookup returns the tool registered under name
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Get retrieves a function from the registry. It returns nil if there is none.
 func (r *Registry) Get(name string) Function {
-	return r.functions[name]
+	fn := r.GetContext(name)
+	if fn == nil {
+		return nil
+	}
+	return func(args string) (string, error) { return fn(context.Background(), args) }
+}
+
+// GetContext retrieves a function from the registry as a ContextFunction,
+// whichever way it was registered. It returns nil if there is none.
+func (r *Registry) GetContext(name string) ContextFunction {
+	tool, ok := r.Lookup(name)
+	if !ok {
+		return nil
+	}
+	return tool.Handler
+}
+
+// Tools returns the registered tools in registration order
+func (r *Registry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name])
+	}
+	return tools
+}
+
+// ToolDefinitions describes the tools with a schema to the model, in
+// registration order. It implements agent.ToolSource.
+func (r *Registry) ToolDefinitions() []agent.ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var defs []agent.ToolDefinition
+	for _, name := range r.order {
+		if tool := r.tools[name]; tool.Parameters != nil {
+			defs = append(defs, tool.Definition())
+		}
+	}
+	return defs
+}
+
+// FileFunctions are the file functions, confined to Workspace if it is set.
+// Without a workspace, relative paths are taken from the current directory.
+type FileFunctions struct {
+	Workspace *fileops.Workspace
+}
+
+// Register adds read_file, stat_file, checksum_file, write_file,
+// edit_lines, patch_file, list_directory, search_code and
+// summarize_workspace to r
+func (f FileFunctions) Register(r *Registry) {
+	r.RegisterTool(Tool{
+		Name:        "read_file",
+		Description: "Read the contents of a text file. Each line is prefixed with its number and a tab, which are not part of the file. Large files are cut at max_bytes with a note saying which lines were shown; read on with start_line. Binary files are described instead, as by stat_file.",
+		Parameters: objectSchema([]string{"path"}, map[string]interface{}{
+			"path":       stringParam("The path to the file"),
+			"start_line": map[string]interface{}{"type": "integer", "description": "The first line to read, counting from 1 (default 1)"},
+			"end_line":   map[string]interface{}{"type": "integer", "description": "The last line to read (default the end of the file)"},
+			"max_bytes":  map[string]interface{}{"type": "integer", "description": fmt.Sprintf("The maximum bytes of output (default %d, at most %d)", defaultReadBytes, maxReadBytes)},
+		}),
+		Handler: withoutContext(f.ReadFile),
+	})
+	r.RegisterTool(Tool{
+		Name:        "stat_file",
+		Description: "Describe a file or directory without reading it: whether it is text or binary, its detected MIME type, size, line count, modification time and permissions. Use it before reading files that may be large or binary.",
+		Parameters: objectSchema([]string{"path"}, map[string]interface{}{
+			"path": stringParam("The path to the file or directory"),
+		}),
+		Handler: withoutContext(f.StatFile),
+	})
+	r.RegisterTool(Tool{
+		Name:        "checksum_file",
+		Description: "Compute a checksum of a file's contents, e.g. to tell whether two files are the same or a file changed, without reading it",
+		Parameters: objectSchema([]string{"path"}, map[string]interface{}{
+			"path":      stringParam("The path to the file"),
+			"algorithm": map[string]interface{}{"type": "string", "enum": []string{"sha256", "sha1", "md5"}, "description": "The hash to compute (default sha256)"},
+		}),
+		Handler: withoutContext(f.ChecksumFile),
+	})
+	r.RegisterTool(Tool{
+		Name:        "write_file",
+		Description: "Write content to a file, replacing existing content or creating a new file. Use patch_file for modifying existing files.",
+		Parameters: objectSchema([]string{"path", "content"}, map[string]interface{}{
+			"path":    stringParam("The path to the file"),
+			"content": stringParam("The full content to write"),
+		}),
+		Handler: withoutContext(f.WriteFile),
+	})
+	r.RegisterTool(Tool{
+		Name:        "edit_lines",
+		Description: "Replace a range of lines of an existing file, by the line numbers read_file shows. Simpler than patch_file for small edits. Set end_line to start_line-1 to insert before start_line, and leave replacement empty to delete. Line numbers below the edit shift, so read the numbers in the result before the next edit.",
+		Parameters: objectSchema([]string{"path", "start_line", "end_line", "replacement"}, map[string]interface{}{
+			"path":        stringParam("The path to the file"),
+			"start_line":  map[string]interface{}{"type": "integer", "description": "The first line to replace, counting from 1; one past the last line to append"},
+			"end_line":    map[string]interface{}{"type": "integer", "description": "The last line to replace, inclusive; start_line-1 to insert without replacing"},
+			"replacement": stringParam("The new lines, without line numbers; empty to delete the range"),
+		}),
+		Handler: withoutContext(f.EditLines),
+	})
+	r.RegisterTool(Tool{
+		Name:        "patch_file",
+		Description: "Modify an existing file by applying a patch in a specific format. Preferred for edits over write_file.",
+		// The patch uses a custom format, described in the parameter
+		Parameters: objectSchema([]string{"patch_content"}, map[string]interface{}{
+			"patch_content": stringParam("The patch content, including // FILE:, // EDIT:, // END_EDIT, ADD:, and DEL: markers. A block headed // EDIT: create with only ADD: lines creates a new file. A unified diff with --- a/path and +++ b/path headers and @@ hunks is accepted too."),
+		}),
+		Handler: withoutContext(f.PatchFile),
+	})
+	r.RegisterTool(Tool{
+		Name:        "list_directory",
+		Description: "List the contents of a directory. With recursive, list the files below it as an indented tree in one call, leaving out files ignored by git or the workspace's ignore file; prefer this over many calls to explore a project.",
+		Parameters: objectSchema([]string{"path"}, map[string]interface{}{
+			"path":           stringParam("The path to the directory"),
+			"recursive":      map[string]interface{}{"type": "boolean", "description": "List the whole tree below the directory instead of its entries only"},
+			"max_depth":      map[string]interface{}{"type": "integer", "description": fmt.Sprintf("How many levels a recursive listing goes down (default %d, at most %d); deeper directories show their file count. Implies recursive.", defaultTreeDepth, maxTreeDepth)},
+			"glob":           stringParam("Only list files matching this glob, e.g. *.go, or src/*.ts with a slash to match the path"),
+			"include_hidden": map[string]interface{}{"type": "boolean", "description": "Include files and directories whose name starts with a dot in a recursive listing"},
+		}),
+		Handler: withoutContext(f.ListDirectory),
+	})
+	r.RegisterTool(Tool{
+		Name:        "search_code",
+		Description: "Search file contents for a regular expression, e.g. to find where a symbol is defined or used. Returns matching lines as path:line: text. Prefer this over shell commands like grep.",
+		Parameters: objectSchema([]string{"pattern"}, map[string]interface{}{
+			"pattern":          stringParam("The regular expression to search for"),
+			"path":             stringParam("The directory to search (defaults to the working directory)"),
+			"glob":             stringParam("Only search files matching this glob, e.g. *.go or src/*.ts"),
+			"case_insensitive": map[string]interface{}{"type": "boolean", "description": "Ignore case when matching"},
+			"max_results":      map[string]interface{}{"type": "integer", "description": fmt.Sprintf("The maximum number of matching lines to return (default %d, at most %d)", defaultSearchResults, maxSearchResults)},
+		}),
+		Handler: f.SearchCode,
+	})
+	r.RegisterTool(Tool{
+		Name:        "summarize_workspace",
+		Description: "Summarize the files under a directory: counts and sizes by language, the largest directories and files, and the ratio of test files to source files. Call this once to plan large tasks instead of exploring with many list_directory calls.",
+		Parameters: objectSchema([]string{}, map[string]interface{}{
+			"path": stringParam("The directory to summarize (defaults to the working directory)"),
+			"top":  map[string]interface{}{"type": "integer", "description": fmt.Sprintf("How many of the largest files and directories to list (default %d, at most %d)", defaultSummaryTop, maxSummaryTop)},
+		}),
+		Handler: f.SummarizeWorkspace,
+	})
+}
+
+// RegisterShell adds the shell tool to r. Its handler runs commands directly;
+// the executor runs them in its sandbox instead.
+func RegisterShell(r *Registry) {
+	r.RegisterTool(Tool{
+		Name:        "shell",
+		Description: "Execute a shell command",
+		Parameters: objectSchema([]string{"command"}, map[string]interface{}{
+			"command":     stringParam("The shell command to execute"),
+			"interactive": map[string]interface{}{"type": "boolean", "description": "Hand the user's terminal to the command, for commands that prompt for input or open an editor (e.g. npm init, git rebase -i). The user answers it directly. Prefer non-interactive flags when they exist."},
+			"timeout":     map[string]interface{}{"type": "integer", "description": "Seconds to let the command run before it is stopped. Defaults to the configured command timeout; ask for more for slow builds or test suites. Capped by the configured maximum."},
+		}),
+		Handler: withoutContext(ExecuteCommand),
+	})
+}
+
+// withoutContext adapts a Function to a ContextFunction
+func withoutContext(fn Function) ContextFunction {
+	return func(ctx context.Context, args string) (string, error) { return fn(args) }
+}
+
+// objectSchema returns the JSON Schema of an object with the given properties
+func objectSchema(required []string, properties map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+}
+
+// stringParam returns the schema of a string parameter
+func stringParam(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+// resolve returns the absolute path to operate on
+func (f FileFunctions) resolve(path string) (string, error) {
+	if f.Workspace != nil {
+		return f.Workspace.Resolve(path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	return absPath, nil
+}
+
+// ignoredUnder returns a function reporting whether a slash-separated path
+// relative to dir is excluded by the workspace's ignore file
+func (f FileFunctions) ignoredUnder(dir string) func(rel string, isDir bool) bool {
+	return func(rel string, isDir bool) bool {
+		return f.Workspace.Ignored(filepath.Join(dir, filepath.FromSlash(rel)), isDir)
+	}
 }
 
 // ReadFile reads the contents of a file
 func ReadFile(args string) (string, error) {
+	return FileFunctions{}.ReadFile(args)
+}
+
+// WriteFile writes content to a file
+func WriteFile(args string) (string, error) {
+	return FileFunctions{}.WriteFile(args)
+}
+
+// EditLines replaces a range of lines of a file
+func EditLines(args string) (string, error) {
+	return FileFunctions{}.EditLines(args)
+}
+
+// PatchFile applies a patch to a file
+func PatchFile(args string) (string, error) {
+	return FileFunctions{}.PatchFile(args)
+}
+
+// StatFile describes a file without reading it whole
+func StatFile(args string) (string, error) {
+	return FileFunctions{}.StatFile(args)
+}
+
+// ChecksumFile computes a hash of the contents of a file
+func ChecksumFile(args string) (string, error) {
+	return FileFunctions{}.ChecksumFile(args)
+}
+
+// ListDirectory lists the contents of a directory
+func ListDirectory(args string) (string, error) {
+	return FileFunctions{}.ListDirectory(args)
+}
+
+// Limits on read_file output
+const (
+	defaultReadBytes = 32 << 10
+	maxReadBytes     = 256 << 10
+)
+
+// ReadFile reads the lines of a file from start_line to end_line, numbered,
+// stopping before the output exceeds max_bytes. When it does not show the
+// whole file, a last line says which lines it showed and where to read on.
+// Binary files are described rather than read.
+func (f FileFunctions) ReadFile(args string) (string, error) {
 	// Parse arguments
 	var params struct {
-		Path string `json:"path"`
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+		MaxBytes  int    `json:"max_bytes"`
 	}
 	if err := json.Unmarshal([]byte(args), &params); err != nil {
 		return "", fmt.Errorf("failed to parse arguments: %w", err)
 	}
 
-	// Check if the path is valid
+	// Check if the parameters are valid
 	if params.Path == "" {
 		return "", fmt.Errorf("path parameter is required")
 	}
+	if params.StartLine < 0 || params.EndLine < 0 {
+		return "", fmt.Errorf("start_line and end_line must be positive")
+	}
+	if params.EndLine > 0 && params.EndLine < params.StartLine {
+		return "", fmt.Errorf("end_line (%d) must not be before start_line (%d)", params.EndLine, params.StartLine)
+	}
+	if params.MaxBytes <= 0 {
+		params.MaxBytes = defaultReadBytes
+	}
+	params.MaxBytes = min(params.MaxBytes, maxReadBytes)
 
 	// Resolve the path
-	absPath, err := filepath.Abs(params.Path)
+	absPath, err := f.resolve(params.Path)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve absolute path: %w", err)
+		return "", err
+	}
+
+	// Binary files would only fill the context with noise
+	head, err := readHead(absPath, sniffBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if isBinary(head) {
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat file: %w", err)
+		}
+		metadata, err := fileMetadata(absPath, info)
+		if err != nil {
+			return "", err
+		}
+		return "This is a binary file, so its content is not shown.\n\n" + metadata, nil
 	}
 
 	// Read the file
-	content, err := ioutil.ReadFile(absPath)
+	content, err := os.ReadFile(absPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	if len(content) == 0 {
+		return "", nil
+	}
 
-	return string(content), nil
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	start := max(params.StartLine, 1)
+	if start > len(lines) {
+		return "", fmt.Errorf("start_line %d is past the end of the file (%d lines)", start, len(lines))
+	}
+	end := len(lines)
+	if params.EndLine > 0 {
+		end = min(params.EndLine, len(lines))
+	}
+	return numberLines(lines, start, end, params.MaxBytes), nil
+}
+
+// numberLines renders lines start to end, counting from 1, prefixed with
+// their number, within about maxBytes. A line too long to fit alone is cut.
+// A last line notes the lines shown when they are not all of them.
+func numberLines(lines []string, start, end, maxBytes int) string {
+	var b strings.Builder
+	width := len(strconv.Itoa(end))
+	last := start - 1
+	cut := false
+	for n := start; n <= end; n++ {
+		line := fmt.Sprintf("%*d\t%s", width, n, strings.TrimSuffix(lines[n-1], "\n"))
+		if b.Len()+len(line)+1 > maxBytes {
+			cut = true
+			if n == start {
+				// Show what fits of the line rather than nothing
+				b.WriteString(truncateBytes(line, maxBytes) + "...\n")
+				last = n
+			}
+			break
+		}
+		b.WriteString(line + "\n")
+		last = n
+	}
+
+	if start == 1 && last == len(lines) {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "[Lines %d-%d of %d.", start, last, len(lines))
+	if cut {
+		fmt.Fprintf(&b, " Output stopped at %d bytes.", maxBytes)
+	}
+	if last < len(lines) {
+		fmt.Fprintf(&b, " Read on with start_line=%d.", last+1)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a character
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
 
 // WriteFile writes content to a file
-func WriteFile(args string) (string, error) {
+func (f FileFunctions) WriteFile(args string) (string, error) {
 	// Parse arguments
 	var params struct {
 		Path    string `json:"path"`
@@ -85,9 +473,9 @@ func WriteFile(args string) (string, error) {
 	}
 
 	// Resolve the path
-	absPath, err := filepath.Abs(params.Path)
+	absPath, err := f.resolve(params.Path)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve absolute path: %w", err)
+		return "", err
 	}
 
 	// Create the directory if it doesn't exist
@@ -104,45 +492,38 @@ func WriteFile(args string) (string, error) {
 	return fmt.Sprintf("Successfully wrote %d bytes to %s", len(params.Content), params.Path), nil
 }
 
-// PatchFile applies a patch to a file
-func PatchFile(args string) (string, error) {
+// PatchFile applies an agent patch with the same engine the executor
+// uses, confined to the workspace
+func (f FileFunctions) PatchFile(args string) (string, error) {
 	// Parse arguments
 	var params struct {
-		Path      string `json:"path"`
-		Patch     string `json:"patch"`
-		StartLine int    `json:"startLine"`
-		EndLine   int    `json:"endLine"`
-		Type      string `json:"type"`
-		Content   string `json:"content"`
+		PatchContent string `json:"patch_content"`
+		CodeEdit     string `json:"code_edit"`
 	}
 	if err := json.Unmarshal([]byte(args), &params); err != nil {
 		return "", fmt.Errorf("failed to parse arguments: %w", err)
 	}
-
-	// Check if parameters are valid
-	if params.Path == "" {
-		return "", fmt.Errorf("path parameter is required")
+	patch := params.PatchContent
+	if patch == "" {
+		patch = params.CodeEdit
 	}
-	if params.Type == "" {
-		params.Type = "replace" // Default to replace
+	if patch == "" {
+		return "", fmt.Errorf("patch_content parameter is required")
 	}
 
-	// Create a patch operation
-	op := fileops.PatchOperation{
-		Type:      params.Type,
-		Path:      params.Path,
-		Content:   params.Content,
-		StartLine: params.StartLine,
-		EndLine:   params.EndLine,
+	operations, err := fileops.ParseAgentPatch(patch)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse patch: %w", err)
 	}
-
-	// Apply the patch
-	result, err := fileops.ApplyPatch(op)
+	results, err := fileops.ApplyAgentPatchIn(f.Workspace, operations)
 	if err != nil {
 		return "", fmt.Errorf("failed to apply patch: %w", err)
 	}
-
-	return fmt.Sprintf("Successfully patched %s (%d -> %d lines)", params.Path, result.OriginalLines, result.NewLines), nil
+	var summaries []string
+	for _, res := range results {
+		summaries = append(summaries, fmt.Sprintf("%s: %s", res.Path, res.Diff))
+	}
+	return "Successfully patched " + strings.Join(summaries, "; "), nil
 }
 
 // ExecuteCommand executes a shell command
@@ -207,12 +588,11 @@ func ExecuteCommand(args string) (string, error) {
 	return result.Stdout, nil
 }
 
-// ListDirectory lists the contents of a directory
-func ListDirectory(args string) (string, error) {
+// ListDirectory lists the contents of a directory, or the tree below it when
+// recursive or max_depth is set
+func (f FileFunctions) ListDirectory(args string) (string, error) {
 	// Parse arguments
-	var params struct {
-		Path string `json:"path"`
-	}
+	var params listParams
 	// Only unmarshal if args is not empty
 	if args != "" {
 		if err := json.Unmarshal([]byte(args), &params); err != nil {
@@ -220,19 +600,19 @@ func ListDirectory(args string) (string, error) {
 		}
 	}
 
-	// Use current directory if path is not specified
+	// Use the current directory (or workspace root) if path is not specified
 	if params.Path == "" {
-		var err error
-		params.Path, err = os.Getwd()
-		if err != nil {
-			return "", fmt.Errorf("failed to get current directory: %w", err)
-		}
+		params.Path = "."
 	}
 
 	// Resolve the path
-	absPath, err := filepath.Abs(params.Path)
+	absPath, err := f.resolve(params.Path)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve absolute path: %w", err)
+		return "", err
+	}
+
+	if params.Recursive || params.MaxDepth > 0 {
+		return f.listTree(absPath, params)
 	}
 
 	// List the directory
@@ -245,23 +625,19 @@ func ListDirectory(args string) (string, error) {
 	var result string
 	result = fmt.Sprintf("Contents of %s:\n\n", absPath)
 
+	ignored := f.ignoredUnder(absPath)
 	for _, file := range files {
+		if ignored(file.Name(), file.IsDir()) || (!file.IsDir() && !matchGlob(params.Glob, file.Name())) {
+			continue
+		}
 		fileType := "file"
 		if file.IsDir() {
 			fileType = "dir"
+		} else if file.Mode()&os.ModeSymlink != 0 {
+			fileType = "link"
 		}
 
-		size := file.Size()
-		var sizeStr string
-		if size < 1024 {
-			sizeStr = fmt.Sprintf("%dB", size)
-		} else if size < 1024*1024 {
-			sizeStr = fmt.Sprintf("%.1fKB", float64(size)/1024)
-		} else {
-			sizeStr = fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
-		}
-
-		result += fmt.Sprintf("[%s] %s (%s, %s)\n", fileType, file.Name(), sizeStr, file.ModTime().Format("2006-01-02 15:04:05"))
+		result += fmt.Sprintf("[%s] %s (%s, %s)\n", fileType, file.Name(), formatSize(file.Size()), file.ModTime().Format("2006-01-02 15:04:05"))
 	}
 
 	return result, nil
@@ -0,0 +1,97 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	FileFunctions{}.Register(r)
+	RegisterShell(r)
+	r.Register("legacy", func(args string) (string, error) { return "legacy " + args, nil })
+
+	var names []string
+	for _, def := range r.ToolDefinitions() {
+		names = append(names, def.Function.Name)
+		if def.Type != "function" || def.Function.Description == "" || def.Function.Parameters == nil {
+			t.Errorf("Incomplete definition %+v", def)
+		}
+	}
+	// Functions registered without a schema are callable but not offered
+	want := []string{"read_file", "stat_file", "checksum_file", "write_file", "edit_lines", "patch_file", "list_directory", "search_code", "summarize_workspace", "shell"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("ToolDefinitions() = %v, want %v", names, want)
+	}
+	if out, err := r.GetContext("legacy")(context.Background(), "x"); err != nil || out != "legacy x" {
+		t.Errorf("Unexpected legacy result %q, %v", out, err)
+	}
+
+	// Replacing a tool keeps its place
+	r.RegisterTool(Tool{Name: "read_file", Description: "Read", Parameters: map[string]interface{}{"type": "object"}})
+	if defs := r.ToolDefinitions(); defs[0].Function.Description != "Read" || len(defs) != len(want) {
+		t.Errorf("Expected read_file to be replaced in place, got %+v", defs[0])
+	}
+	tools := r.Tools()
+	if len(tools) != len(want)+1 || tools[len(tools)-1].Name != "legacy" || tools[0].Origin() != "core" {
+		t.Errorf("Unexpected tools %+v", tools)
+	}
+	if origin := (Tool{Source: "plugin lint"}).Origin(); origin != "plugin lint" {
+		t.Errorf("Expected the source as origin, got %q", origin)
+	}
+	if r.GetContext("missing") != nil || r.Get("missing") != nil {
+		t.Error("Expected no function for an unknown name")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	var content strings.Builder
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	path := filepath.Join(dir, "long.txt")
+	if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	read := func(args string) string {
+		t.Helper()
+		out, err := ReadFile(args)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", args, err)
+		}
+		return out
+	}
+
+	if got := read(`{"path":"` + path + `"}`); !strings.HasPrefix(got, " 1\tline 1\n 2\tline 2\n") || !strings.HasSuffix(got, "12\tline 12\n") {
+		t.Errorf("Expected the whole file numbered, got %q", got)
+	}
+	if got, want := read(`{"path":"`+path+`","start_line":3,"end_line":4}`), "3\tline 3\n4\tline 4\n[Lines 3-4 of 12. Read on with start_line=5.]"; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+	if got, want := read(`{"path":"`+path+`","start_line":11,"end_line":99}`), "11\tline 11\n12\tline 12\n[Lines 11-12 of 12.]"; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+	// Each line takes 10 bytes numbered; 25 bytes hold two
+	if got, want := read(`{"path":"`+path+`","start_line":5,"max_bytes":25}`), " 5\tline 5\n 6\tline 6\n[Lines 5-6 of 12. Output stopped at 25 bytes. Read on with start_line=7.]"; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+	if got, want := read(`{"path":"`+path+`","max_bytes":5}`), " 1\tli...\n[Lines 1-1 of 12. Output stopped at 5 bytes. Read on with start_line=2.]"; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+
+	for _, args := range []string{
+		`{"path":"` + path + `","start_line":13}`,
+		`{"path":"` + path + `","start_line":5,"end_line":4}`,
+		`{"path":"` + path + `","start_line":-1}`,
+	} {
+		if _, err := ReadFile(args); err == nil {
+			t.Errorf("Expected ReadFile(%s) to fail", args)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// editContextLines is how many lines around an edit edit_lines shows
+const editContextLines = 3
+
+// LineEdit is the arguments of edit_lines: lines StartLine to EndLine of
+// Path, counting from 1, are replaced with Replacement. An EndLine of
+// StartLine-1 inserts before StartLine, and an empty Replacement deletes.
+type LineEdit struct {
+	Path        string `json:"path"`
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	Replacement string `json:"replacement"`
+}
+
+// ParseLineEdit reads the arguments of an edit_lines call
+func ParseLineEdit(args string) (LineEdit, error) {
+	var edit LineEdit
+	if err := json.Unmarshal([]byte(args), &edit); err != nil {
+		return edit, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	if edit.Path == "" {
+		return edit, fmt.Errorf("path parameter is required")
+	}
+	if edit.StartLine < 1 {
+		return edit, fmt.Errorf("start_line must be at least 1")
+	}
+	if edit.EndLine < edit.StartLine-1 {
+		return edit, fmt.Errorf("end_line (%d) must not be before start_line (%d); use end_line %d to insert before line %d", edit.EndLine, edit.StartLine, edit.StartLine-1, edit.StartLine)
+	}
+	return edit, nil
+}
+
+// Apply returns content with the edit made. The replacement takes the line
+// endings of the file, and the file keeps or lacks its final newline.
+func (e LineEdit) Apply(content string) (string, error) {
+	lines := splitLines(content)
+	if e.StartLine > len(lines)+1 {
+		return "", fmt.Errorf("start_line %d is past the end of the file (%d lines); use start_line %d to append", e.StartLine, len(lines), len(lines)+1)
+	}
+	if e.EndLine > len(lines) {
+		return "", fmt.Errorf("end_line %d is past the end of the file (%d lines)", e.EndLine, len(lines))
+	}
+	eol := "\n"
+	if len(lines) > 0 && strings.HasSuffix(lines[0], "\r\n") {
+		eol = "\r\n"
+	}
+
+	var b strings.Builder
+	for _, line := range lines[:e.StartLine-1] {
+		b.WriteString(line)
+	}
+	if e.Replacement != "" {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString(eol) // Appending after a last line without a newline
+		}
+		replacement := strings.ReplaceAll(e.Replacement, "\r\n", "\n")
+		b.WriteString(strings.ReplaceAll(strings.TrimSuffix(replacement, "\n")+"\n", "\n", eol))
+	}
+	for _, line := range lines[e.EndLine:] {
+		b.WriteString(line)
+	}
+
+	edited := b.String()
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		edited = strings.TrimSuffix(edited, eol)
+	}
+	return edited, nil
+}
+
+// splitLines splits content into lines, each with its line ending
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// replacementLines counts the lines of a replacement
+func (e LineEdit) replacementLines() int {
+	if e.Replacement == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(e.Replacement, "\n"), "\n") + 1
+}
+
+// Summary describes the edit in a few words, such as "replace lines 3-5
+// of main.go with 2 lines"
+func (e LineEdit) Summary() string {
+	var what string
+	switch {
+	case e.EndLine < e.StartLine:
+		what = fmt.Sprintf("insert before line %d of %s", e.StartLine, e.Path)
+	case e.EndLine == e.StartLine:
+		what = fmt.Sprintf("replace line %d of %s", e.StartLine, e.Path)
+	default:
+		what = fmt.Sprintf("replace lines %d-%d of %s", e.StartLine, e.EndLine, e.Path)
+	}
+	switch n := e.replacementLines(); {
+	case e.EndLine < e.StartLine:
+		return fmt.Sprintf("%s %d line(s)", what, n)
+	case n == 0:
+		return "delete" + strings.TrimPrefix(what, "replace")
+	default:
+		return fmt.Sprintf("%s with %d line(s)", what, n)
+	}
+}
+
+// EditLines replaces, inserts or deletes a range of lines of an existing
+// file, then shows the edited lines with a few around them, numbered as
+// they are now, so that the next edit can be placed
+func (f FileFunctions) EditLines(args string) (string, error) {
+	edit, err := ParseLineEdit(args)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := f.resolve(edit.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	edited, err := edit.Apply(string(content))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(absPath, []byte(edited), info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	out := fmt.Sprintf("Edit applied: %s.", edit.Summary())
+	lines := splitLines(edited)
+	if len(lines) == 0 {
+		return out + " The file is empty now.", nil
+	}
+	start := max(edit.StartLine-editContextLines, 1)
+	end := min(edit.StartLine+edit.replacementLines()-1+editContextLines, len(lines))
+	return fmt.Sprintf("%s The edited lines as they are now:\n\n%s", out, strings.TrimSuffix(numberLines(lines, start, end, defaultReadBytes), "\n")), nil
+}
@@ -0,0 +1,73 @@
+package functions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineEditApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		start, end  int
+		replacement string
+		want        string
+	}{
+		{"replace", "a\nb\nc\n", 2, 2, "B", "a\nB\nc\n"},
+		{"replace range with more", "a\nb\nc\n", 1, 2, "x\ny\nz\n", "x\ny\nz\nc\n"},
+		{"insert", "a\nb\n", 2, 1, "new", "a\nnew\nb\n"},
+		{"delete", "a\nb\nc\n", 2, 3, "", "a\n"},
+		{"append", "a\nb\n", 3, 2, "c", "a\nb\nc\n"},
+		{"append without final newline", "a\nb", 3, 2, "c", "a\nb\nc"},
+		{"replace last line without final newline", "a\nb", 2, 2, "c\n", "a\nc"},
+		{"empty file", "", 1, 0, "a", "a\n"},
+		{"crlf", "a\r\nb\r\n", 2, 2, "x\ny", "a\r\nx\r\ny\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LineEdit{StartLine: tt.start, EndLine: tt.end, Replacement: tt.replacement}.Apply(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := (LineEdit{StartLine: 4, EndLine: 4}).Apply("a\nb\n"); err == nil || !strings.Contains(err.Error(), "past the end of the file (2 lines)") {
+		t.Errorf("Expected an error past the end of the file, got %v", err)
+	}
+	if _, err := ParseLineEdit(`{"path":"a.txt","start_line":3,"end_line":1}`); err == nil || !strings.Contains(err.Error(), "use end_line 2 to insert") {
+		t.Errorf("Expected an error for an end before the start, got %v", err)
+	}
+}
+
+func TestEditLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	writeSearchFile(t, dir, "main.go", "package main\n\nfunc main() {\n\tprintln(1)\n}\n")
+
+	out, err := EditLines(`{"path":"` + path + `","start_line":4,"end_line":4,"replacement":"\tprintln(2)\n\tprintln(3)"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Edit applied: replace line 4 of " + path + " with 2 line(s). The edited lines as they are now:\n\n1\tpackage main\n2\t\n3\tfunc main() {\n4\t\tprintln(2)\n5\t\tprintln(3)\n6\t}"
+	if out != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "package main\n\nfunc main() {\n\tprintln(2)\n\tprintln(3)\n}\n" {
+		t.Errorf("Unexpected content: %q", data)
+	}
+
+	out, err = EditLines(`{"path":"` + path + `","start_line":1,"end_line":6,"replacement":""}`)
+	if err != nil || !strings.HasPrefix(out, "Edit applied: delete lines 1-6 of ") || !strings.HasSuffix(out, "The file is empty now.") {
+		t.Errorf("Unexpected output deleting every line: %q, %v", out, err)
+	}
+
+	if _, err := EditLines(`{"path":"` + filepath.Join(dir, "missing.go") + `","start_line":1,"end_line":0,"replacement":"x"}`); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
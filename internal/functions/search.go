@@ -0,0 +1,274 @@
+package functions
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Limits on search_code results
+const (
+	defaultSearchResults = 100
+	maxSearchResults     = 500
+	maxSearchLineLength  = 200
+	maxSearchFileSize    = 1 << 20
+)
+
+// searchSkipDirs are not searched by the fallback walker; ripgrep skips
+// whatever .gitignore lists instead
+var searchSkipDirs = map[string]bool{
+	".git": true, ".hg": true, ".svn": true, ".codex": true, "node_modules": true,
+	"vendor": true, "dist": true, "build": true, "target": true, "__pycache__": true,
+}
+
+// lookRipgrep finds the ripgrep binary; tests replace it to force the fallback
+var lookRipgrep = func() (string, error) { return exec.LookPath("rg") }
+
+// searchParams are the arguments of search_code
+type searchParams struct {
+	Pattern         string `json:"pattern"`
+	Path            string `json:"path"`
+	Glob            string `json:"glob"`
+	CaseInsensitive bool   `json:"case_insensitive"`
+	MaxResults      int    `json:"max_results"`
+}
+
+// SearchCode searches file contents for a regular expression
+func SearchCode(ctx context.Context, args string) (string, error) {
+	return FileFunctions{}.SearchCode(ctx, args)
+}
+
+// SearchCode searches file contents for a regular expression, with ripgrep
+// if it is installed and a walk of the directory tree otherwise. Matches are
+// listed as "path:line: text", relative to the searched directory.
+func (f FileFunctions) SearchCode(ctx context.Context, args string) (string, error) {
+	var params searchParams
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	if params.Pattern == "" {
+		return "", fmt.Errorf("pattern parameter is required")
+	}
+	if params.MaxResults <= 0 {
+		params.MaxResults = defaultSearchResults
+	}
+	if params.MaxResults > maxSearchResults {
+		params.MaxResults = maxSearchResults
+	}
+	if params.Path == "" {
+		params.Path = "."
+	}
+
+	root, err := f.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to search %s: %w", params.Path, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", params.Path)
+	}
+
+	// Validate the pattern up front so both searchers report it the same way
+	re, err := compileSearchPattern(params)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	if rg, err := lookRipgrep(); err == nil {
+		matches, err = searchRipgrep(ctx, rg, root, params, f.ignoredUnder(root))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		matches, err = searchWalk(ctx, root, re, params, f.ignoredUnder(root))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("No matches for %q in %s", params.Pattern, params.Path), nil
+	}
+	result := strings.Join(matches[:min(len(matches), params.MaxResults)], "\n")
+	if len(matches) > params.MaxResults {
+		result += fmt.Sprintf("\n(Showing the first %d matches; narrow the pattern, path or glob to see more.)", params.MaxResults)
+	}
+	return result, nil
+}
+
+// compileSearchPattern compiles the pattern as the fallback walker uses it
+func compileSearchPattern(params searchParams) (*regexp.Regexp, error) {
+	pattern := params.Pattern
+	if params.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re, nil
+}
+
+// searchRipgrep runs ripgrep in root, stopping once one match more than
+// requested has been read. Matches in files ignored says are dropped.
+func searchRipgrep(ctx context.Context, rg, root string, params searchParams, ignored func(rel string, isDir bool) bool) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	args := []string{"--line-number", "--no-heading", "--color", "never", "--max-columns", fmt.Sprint(maxSearchLineLength), "--max-columns-preview"}
+	if params.CaseInsensitive {
+		args = append(args, "--ignore-case")
+	}
+	if params.Glob != "" {
+		args = append(args, "--glob", params.Glob)
+	}
+	args = append(args, "--regexp", params.Pattern, "--", ".")
+
+	cmd := exec.CommandContext(ctx, rg, args...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ripgrep: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run ripgrep: %w", err)
+	}
+
+	var matches []string
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() && len(matches) <= params.MaxResults {
+		match := formatRipgrepLine(scanner.Text())
+		if path, _, _ := strings.Cut(match, ":"); ignored(path, false) {
+			continue
+		}
+		matches = append(matches, match)
+	}
+	truncated := len(matches) > params.MaxResults
+	if truncated {
+		cancel()
+	}
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+	switch {
+	case truncated, err == nil:
+		return matches, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return nil, nil // No matches
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	}
+	return nil, fmt.Errorf("ripgrep failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+}
+
+// formatRipgrepLine turns "./path:12:text" into "path:12: text"
+func formatRipgrepLine(line string) string {
+	line = strings.TrimPrefix(line, "./")
+	parts := strings.SplitN(line, ":", 3)
+	if len(parts) != 3 {
+		return line
+	}
+	return fmt.Sprintf("%s:%s: %s", filepath.ToSlash(parts[0]), parts[1], strings.TrimSpace(parts[2]))
+}
+
+// searchWalk searches the files under root without ripgrep, skipping
+// well-known dependency and build directories, binary files, large files and
+// whatever ignored says
+func searchWalk(ctx context.Context, root string, re *regexp.Regexp, params searchParams, ignored func(rel string, isDir bool) bool) ([]string, error) {
+	var matches []string
+	errDone := errors.New("enough matches")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if path != root && (searchSkipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".") || ignored(rel, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") || !matchSearchGlob(params.Glob, rel) || ignored(rel, false) {
+			return nil
+		}
+
+		found, err := searchFile(path, rel, re, params.MaxResults+1-len(matches))
+		if err != nil {
+			return nil
+		}
+		matches = append(matches, found...)
+		if len(matches) > params.MaxResults {
+			return errDone
+		}
+		return nil
+	})
+	if err != nil && err != errDone {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// matchSearchGlob reports whether the slash-separated relative path matches
+// glob. Globs without a slash match the file name, as in ripgrep.
+func matchSearchGlob(glob, rel string) bool {
+	if glob == "" {
+		return true
+	}
+	name := rel
+	if !strings.Contains(glob, "/") {
+		name = filepath.Base(rel)
+	}
+	ok, _ := filepath.Match(strings.TrimPrefix(glob, "/"), name)
+	return ok
+}
+
+// searchFile returns up to limit matching lines of a text file
+func searchFile(path, rel string, re *regexp.Regexp, limit int) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxSearchFileSize {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, nil // Binary file
+	}
+
+	var matches []string
+	for i, line := range strings.Split(string(data), "\n") {
+		if !re.MatchString(line) {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if len(line) > maxSearchLineLength {
+			line = line[:maxSearchLineLength] + " [...]"
+		}
+		matches = append(matches, fmt.Sprintf("%s:%d: %s", rel, i+1, line))
+		if len(matches) >= limit {
+			break
+		}
+	}
+	return matches, nil
+}
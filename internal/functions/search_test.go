@@ -0,0 +1,141 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/fileops"
+	"github.com/epuerta/codex-go/internal/ignore"
+)
+
+func writeSearchFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchCode(t *testing.T) {
+	root := t.TempDir()
+	writeSearchFile(t, root, "main.go", "package main\n\nfunc NewServer() *Server {\n\treturn &Server{}\n}\n")
+	writeSearchFile(t, root, "web/app.ts", "export function newServer() {}\n")
+	writeSearchFile(t, root, "node_modules/dep/index.js", "function NewServer() {}\n")
+	writeSearchFile(t, root, "image.bin", "NewServer\x00\x01")
+	var many strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&many, "match %d\n", i)
+	}
+	writeSearchFile(t, root, "many.txt", many.String())
+
+	ws, err := fileops.NewWorkspace(root, fileops.FollowInWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := FileFunctions{Workspace: ws}
+	search := func(t *testing.T, args string) string {
+		t.Helper()
+		out, err := f.SearchCode(context.Background(), args)
+		if err != nil {
+			t.Fatalf("SearchCode(%s) failed: %v", args, err)
+		}
+		return out
+	}
+
+	searchers := map[string]func() (string, error){
+		"walk": func() (string, error) { return "", errors.New("not installed") },
+	}
+	if _, err := exec.LookPath("rg"); err == nil {
+		searchers["ripgrep"] = lookRipgrep
+	}
+	defer func(orig func() (string, error)) { lookRipgrep = orig }(lookRipgrep)
+
+	for name, look := range searchers {
+		t.Run(name, func(t *testing.T) {
+			lookRipgrep = look
+
+			if out := search(t, `{"pattern":"func NewServer"}`); out != "main.go:3: func NewServer() *Server {" {
+				t.Errorf("Unexpected result:\n%s", out)
+			}
+			out := search(t, `{"pattern":"newserver\\(","case_insensitive":true,"glob":"*.ts"}`)
+			if out != "web/app.ts:1: export function newServer() {}" {
+				t.Errorf("Unexpected result for glob search:\n%s", out)
+			}
+			out = search(t, `{"pattern":"^match","max_results":5}`)
+			if lines := strings.Split(out, "\n"); len(lines) != 6 || !strings.Contains(lines[5], "first 5 matches") {
+				t.Errorf("Expected 5 matches and a truncation note, got:\n%s", out)
+			}
+			if out := search(t, `{"pattern":"no such text"}`); !strings.HasPrefix(out, "No matches") {
+				t.Errorf("Expected no matches, got:\n%s", out)
+			}
+		})
+	}
+
+	if _, err := f.SearchCode(context.Background(), `{"pattern":"("}`); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("Expected an invalid pattern error, got %v", err)
+	}
+	if _, err := f.SearchCode(context.Background(), `{"pattern":"x","path":".."}`); !errors.Is(err, fileops.ErrOutsideWorkspace) {
+		t.Errorf("Expected searching outside the workspace to fail, got %v", err)
+	}
+}
+
+func TestFileFunctionsIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeSearchFile(t, root, "main.go", "const token = lookup()\n")
+	writeSearchFile(t, root, ".env", "token=secret\n")
+	writeSearchFile(t, root, "secrets/prod.yaml", "token: secret\n")
+
+	ws, err := fileops.NewWorkspace(root, fileops.FollowInWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Ignore = ignore.New(ws.Root, []string{".env", "secrets/"})
+	f := FileFunctions{Workspace: ws}
+
+	if _, err := f.ReadFile(`{"path":".env"}`); !errors.Is(err, fileops.ErrIgnored) {
+		t.Errorf("Expected reading an ignored file to fail, got %v", err)
+	}
+	listing, err := f.ListDirectory(`{"path":"."}`)
+	if err != nil {
+		t.Fatalf("ListDirectory failed: %v", err)
+	}
+	if !strings.Contains(listing, "main.go") || strings.Contains(listing, ".env") || strings.Contains(listing, "secrets") {
+		t.Errorf("Expected ignored entries to be left out:\n%s", listing)
+	}
+	summary, err := f.SummarizeWorkspace(context.Background(), "")
+	if err != nil {
+		t.Fatalf("SummarizeWorkspace failed: %v", err)
+	}
+	if strings.Contains(summary, "prod.yaml") || strings.Contains(summary, "YAML") {
+		t.Errorf("Expected ignored files to be left out of the summary:\n%s", summary)
+	}
+
+	searchers := map[string]func() (string, error){
+		"walk": func() (string, error) { return "", errors.New("not installed") },
+	}
+	if _, err := exec.LookPath("rg"); err == nil {
+		searchers["ripgrep"] = lookRipgrep
+	}
+	defer func(orig func() (string, error)) { lookRipgrep = orig }(lookRipgrep)
+	for name, look := range searchers {
+		t.Run(name, func(t *testing.T) {
+			lookRipgrep = look
+			out, err := f.SearchCode(context.Background(), `{"pattern":"token","case_insensitive":true}`)
+			if err != nil {
+				t.Fatalf("SearchCode failed: %v", err)
+			}
+			if out != "main.go:1: const token = lookup()" {
+				t.Errorf("Expected only main.go to match, got:\n%s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package functions
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffBytes is how much of a file is looked at to detect its type and
+// whether it is binary
+const sniffBytes = 8000
+
+// maxLineCountSize bounds the text files whose lines stat_file counts
+const maxLineCountSize = 10 << 20
+
+// checksumAlgorithms are the hashes checksum_file computes
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// StatFile describes a file or directory without reading it whole: its
+// type, detected MIME type, size, line count, modification time and
+// permissions
+func (f FileFunctions) StatFile(args string) (string, error) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	if params.Path == "" {
+		return "", fmt.Errorf("path parameter is required")
+	}
+	absPath, err := f.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	return fileMetadata(absPath, info)
+}
+
+// ChecksumFile computes a hash of the contents of a file, to tell whether
+// two files or two versions of one are the same without reading them
+func (f FileFunctions) ChecksumFile(args string) (string, error) {
+	var params struct {
+		Path      string `json:"path"`
+		Algorithm string `json:"algorithm"`
+	}
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	if params.Path == "" {
+		return "", fmt.Errorf("path parameter is required")
+	}
+	if params.Algorithm == "" {
+		params.Algorithm = "sha256"
+	}
+	newHash, ok := checksumAlgorithms[strings.ToLower(params.Algorithm)]
+	if !ok {
+		return "", fmt.Errorf("unknown algorithm %q (want sha256, sha1 or md5)", params.Algorithm)
+	}
+	absPath, err := f.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", params.Path)
+	}
+	h := newHash()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return fmt.Sprintf("%s  %s  %s", strings.ToLower(params.Algorithm), hex.EncodeToString(h.Sum(nil)), absPath), nil
+}
+
+// fileMetadata describes the file at path, one property a line
+func fileMetadata(path string, info os.FileInfo) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Path: %s\n", path)
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read directory: %w", err)
+		}
+		fmt.Fprintf(&b, "Type: directory (%d entries)\n", len(entries))
+	} else {
+		head, err := readHead(path, sniffBytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		kind := "text"
+		if isBinary(head) {
+			kind = "binary"
+		}
+		fmt.Fprintf(&b, "Type: %s file, %s\n", kind, detectType(path, head))
+		fmt.Fprintf(&b, "Size: %s (%d bytes)\n", formatSize(info.Size()), info.Size())
+		if kind == "text" && info.Size() <= maxLineCountSize {
+			if data, err := os.ReadFile(path); err == nil {
+				fmt.Fprintf(&b, "Lines: %d\n", countLines(data))
+			}
+		}
+	}
+	fmt.Fprintf(&b, "Modified: %s\n", info.ModTime().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "Permissions: %s", info.Mode().Perm())
+	return b.String(), nil
+}
+
+// readHead returns up to n bytes from the start of the file at path
+func readHead(path string, n int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	head := make([]byte, n)
+	read, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:read], nil
+}
+
+// isBinary reports whether head, the start of a file, holds binary data
+// rather than text: a NUL byte, or more than a tenth of it in control
+// characters and bytes that are not UTF-8. Text in a legacy encoding has
+// few enough of those to be read.
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	odd := 0
+	for i := 0; i < len(head); {
+		r, size := utf8.DecodeRune(head[i:])
+		switch {
+		case r == utf8.RuneError && size == 1 && len(head)-i >= utf8.UTFMax:
+			odd++ // The last character may have been cut by the end of the head
+		case r < 0x20 && !strings.ContainsRune("\t\n\r\f\v\b\x1b", r):
+			odd++
+		}
+		i += size
+	}
+	return odd*10 > len(head)
+}
+
+// detectType returns the MIME type of a file from its first bytes, or from
+// its extension when they are not telling
+func detectType(path string, head []byte) string {
+	detected := http.DetectContentType(head)
+	if detected == "application/octet-stream" || strings.HasPrefix(detected, "text/plain") {
+		if byExt := mime.TypeByExtension(filepath.Ext(path)); byExt != "" {
+			return byExt
+		}
+	}
+	return detected
+}
+
+// countLines counts the lines of data, including a last one without a
+// newline
+func countLines(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	n := strings.Count(string(data), "\n")
+	if data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
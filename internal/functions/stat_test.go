@@ -0,0 +1,91 @@
+package functions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader starts every PNG file
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestStatFile(t *testing.T) {
+	dir := t.TempDir()
+	writeSearchFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), append(pngHeader, make([]byte, 2000)...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := StatFile(`{"path":"` + filepath.Join(dir, "main.go") + `"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Type: text file, ", "Size: 29B (29 bytes)", "Lines: 3", "Permissions: -rw-"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+
+	out, err = StatFile(`{"path":"` + filepath.Join(dir, "logo.png") + `"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Type: binary file, image/png") || strings.Contains(out, "Lines:") || !strings.Contains(out, "Permissions: -rw-------") {
+		t.Errorf("Unexpected metadata of an image:\n%s", out)
+	}
+
+	if out, err := StatFile(`{"path":"` + dir + `"}`); err != nil || !strings.Contains(out, "Type: directory (2 entries)") {
+		t.Errorf("Unexpected metadata of a directory: %q, %v", out, err)
+	}
+	if _, err := StatFile(`{"path":"` + filepath.Join(dir, "missing") + `"}`); err == nil {
+		t.Error("Expected a missing file to fail")
+	}
+
+	// read_file describes binary files instead of reading them
+	out, err = ReadFile(`{"path":"` + filepath.Join(dir, "logo.png") + `"}`)
+	if err != nil || !strings.HasPrefix(out, "This is a binary file") || !strings.Contains(out, "image/png") {
+		t.Errorf("Expected the metadata of the image, got %q, %v", out, err)
+	}
+}
+
+func TestChecksumFile(t *testing.T) {
+	dir := t.TempDir()
+	writeSearchFile(t, dir, "hello.txt", "hello\n")
+	path := filepath.Join(dir, "hello.txt")
+
+	for algorithm, want := range map[string]string{
+		"":     "sha256  5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  " + path,
+		"md5":  "md5  b1946ac92492d2347c6235b4d2611184  " + path,
+		"SHA1": "sha1  f572d396fae9206628714fb2ce00f72e94f2258f  " + path,
+	} {
+		out, err := ChecksumFile(`{"path":"` + path + `","algorithm":"` + algorithm + `"}`)
+		if err != nil || out != want {
+			t.Errorf("%q: got %q, %v, want %q", algorithm, out, err, want)
+		}
+	}
+	if _, err := ChecksumFile(`{"path":"` + path + `","algorithm":"crc32"}`); err == nil {
+		t.Error("Expected an unknown algorithm to fail")
+	}
+	if _, err := ChecksumFile(`{"path":"` + dir + `"}`); err == nil {
+		t.Error("Expected a directory to fail")
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	for _, tt := range []struct {
+		head string
+		want bool
+	}{
+		{"package main\n", false},
+		{"caf\xe9 cr\xe8me, na\xefve r\xe9sum\xe9 and a long enough sentence around them", false}, // Latin-1
+		{"\x1b[31mred\x1b[0m\n", false},
+		{string(pngHeader), true},
+		{"\x01\x02\x03\x04abc", true},
+		{"", false},
+	} {
+		if got := isBinary([]byte(tt.head)); got != tt.want {
+			t.Errorf("isBinary(%q) = %t, want %t", tt.head, got, tt.want)
+		}
+	}
+}
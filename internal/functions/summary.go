@@ -0,0 +1,252 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/repomap"
+)
+
+// Limits on summarize_workspace listings
+const (
+	defaultSummaryTop = 10
+	maxSummaryTop     = 50
+	// summaryDirDepth is the deepest directory level listed among the largest
+	summaryDirDepth = 2
+)
+
+// summaryLanguages maps file extensions to language names
+var summaryLanguages = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".rs": "Rust", ".java": "Java", ".kt": "Kotlin", ".kts": "Kotlin",
+	".rb": "Ruby", ".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".cxx": "C++", ".hpp": "C++",
+	".cs": "C#", ".swift": "Swift", ".php": "PHP", ".scala": "Scala", ".ex": "Elixir", ".exs": "Elixir",
+	".sh": "Shell", ".bash": "Shell", ".zsh": "Shell", ".html": "HTML", ".css": "CSS", ".scss": "CSS",
+	".vue": "Vue", ".svelte": "Svelte", ".sql": "SQL", ".proto": "Protocol Buffers",
+	".md": "Markdown", ".json": "JSON", ".yaml": "YAML", ".yml": "YAML", ".toml": "TOML",
+}
+
+// summaryFileNames identifies files without a telling extension
+var summaryFileNames = map[string]string{
+	"Makefile": "Makefile", "GNUmakefile": "Makefile", "Dockerfile": "Dockerfile", "Taskfile.yml": "YAML",
+}
+
+// docLanguages hold documentation and data rather than code, so their files
+// count neither as source nor as tests
+var docLanguages = map[string]bool{
+	"Markdown": true, "JSON": true, "YAML": true, "TOML": true, "Other": true,
+}
+
+// testDirs hold test files in languages that keep tests apart from the code
+var testDirs = map[string]bool{"test": true, "tests": true, "__tests__": true, "spec": true}
+
+// summaryParams are the arguments of summarize_workspace
+type summaryParams struct {
+	Path string `json:"path"`
+	Top  int    `json:"top"`
+}
+
+// languageStats counts the files of one language
+type languageStats struct {
+	name  string
+	files int
+	tests int
+	bytes int64
+}
+
+// sizedPath is a file or directory with its size in bytes
+type sizedPath struct {
+	path  string
+	files int
+	bytes int64
+}
+
+// SummarizeWorkspace reports the files under a directory by language, the
+// largest files and directories, and how many files are tests: an overview
+// for planning that takes one call instead of many list_directory calls.
+// Git checkouts leave out ignored files.
+func (f FileFunctions) SummarizeWorkspace(ctx context.Context, args string) (string, error) {
+	var params summaryParams
+	if args != "" {
+		if err := json.Unmarshal([]byte(args), &params); err != nil {
+			return "", fmt.Errorf("failed to parse arguments: %w", err)
+		}
+	}
+	if params.Path == "" {
+		params.Path = "."
+	}
+	if params.Top <= 0 {
+		params.Top = defaultSummaryTop
+	}
+	params.Top = min(params.Top, maxSummaryTop)
+
+	root, err := f.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", params.Path, err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", params.Path)
+	}
+
+	paths, err := repomap.ListFiles(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to list files in %s: %w", params.Path, err)
+	}
+
+	ignored := f.ignoredUnder(root)
+	languages := make(map[string]*languageStats)
+	dirs := make(map[string]*sizedPath)
+	var files []sizedPath
+	var total int64
+	for _, rel := range paths {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if ignored(rel, false) {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue // Deleted but still tracked, or not a regular file
+		}
+		size := info.Size()
+		total += size
+		files = append(files, sizedPath{path: rel, files: 1, bytes: size})
+
+		lang := summaryLanguage(rel)
+		stats := languages[lang]
+		if stats == nil {
+			stats = &languageStats{name: lang}
+			languages[lang] = stats
+		}
+		stats.files++
+		stats.bytes += size
+		if isTestFile(rel) {
+			stats.tests++
+		}
+
+		parts := strings.Split(path.Dir(rel), "/")
+		for depth := 1; depth <= min(len(parts), summaryDirDepth) && parts[0] != "."; depth++ {
+			dir := strings.Join(parts[:depth], "/")
+			if dirs[dir] == nil {
+				dirs[dir] = &sizedPath{path: dir + "/"}
+			}
+			dirs[dir].files++
+			dirs[dir].bytes += size
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Sprintf("No files in %s", params.Path), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Workspace summary of %s: %d files, %s\n", params.Path, len(files), formatSize(total))
+
+	langs := make([]*languageStats, 0, len(languages))
+	for _, stats := range languages {
+		langs = append(langs, stats)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if langs[i].bytes != langs[j].bytes {
+			return langs[i].bytes > langs[j].bytes
+		}
+		return langs[i].name < langs[j].name
+	})
+	var sourceFiles, testFiles int
+	sb.WriteString("\nLanguages (files, size, test files):\n")
+	for _, stats := range langs {
+		fmt.Fprintf(&sb, "  %-18s %6d  %8s", stats.name, stats.files, formatSize(stats.bytes))
+		if !docLanguages[stats.name] {
+			sourceFiles += stats.files - stats.tests
+			testFiles += stats.tests
+			fmt.Fprintf(&sb, "  %d tests", stats.tests)
+			if source := stats.files - stats.tests; source > 0 {
+				fmt.Fprintf(&sb, " (%.2f per source file)", float64(stats.tests)/float64(source))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if sourceFiles > 0 {
+		fmt.Fprintf(&sb, "\nTests: %d test files for %d source files (%.2f per source file)\n", testFiles, sourceFiles, float64(testFiles)/float64(sourceFiles))
+	}
+
+	if len(dirs) > 0 {
+		var list []sizedPath
+		for _, dir := range dirs {
+			list = append(list, *dir)
+		}
+		fmt.Fprintf(&sb, "\nLargest directories, up to %d levels deep (files, size):\n", summaryDirDepth)
+		writeLargest(&sb, list, params.Top, true)
+	}
+	sb.WriteString("\nLargest files:\n")
+	writeLargest(&sb, files, params.Top, false)
+	return sb.String(), nil
+}
+
+// writeLargest lists the top entries by size
+func writeLargest(sb *strings.Builder, entries []sizedPath, top int, counts bool) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].bytes != entries[j].bytes {
+			return entries[i].bytes > entries[j].bytes
+		}
+		return entries[i].path < entries[j].path
+	})
+	for _, e := range entries[:min(len(entries), top)] {
+		if counts {
+			fmt.Fprintf(sb, "  %-40s %6d  %8s\n", e.path, e.files, formatSize(e.bytes))
+		} else {
+			fmt.Fprintf(sb, "  %-40s %8s\n", e.path, formatSize(e.bytes))
+		}
+	}
+}
+
+// summaryLanguage names the language of a slash-separated path
+func summaryLanguage(rel string) string {
+	name := path.Base(rel)
+	if lang, ok := summaryFileNames[name]; ok {
+		return lang
+	}
+	if lang, ok := summaryLanguages[strings.ToLower(path.Ext(name))]; ok {
+		return lang
+	}
+	return "Other"
+}
+
+// isTestFile reports whether a slash-separated path is a test by the naming
+// conventions of common languages, or lies in a test directory
+func isTestFile(rel string) bool {
+	name := path.Base(rel)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	switch {
+	case strings.HasSuffix(stem, "_test"), strings.HasSuffix(stem, "_spec"), strings.HasPrefix(stem, "test_"),
+		strings.HasSuffix(stem, ".test"), strings.HasSuffix(stem, ".spec"),
+		strings.HasSuffix(stem, "Test") && stem != "Test", strings.HasSuffix(stem, "Tests") && stem != "Tests":
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(rel), "/") {
+		if testDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSize formats a byte count for listings
+func formatSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%dB", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	}
+}
@@ -0,0 +1,75 @@
+package functions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/fileops"
+)
+
+func TestSummarizeWorkspace(t *testing.T) {
+	root := t.TempDir()
+	writeSearchFile(t, root, "main.go", "package main\n")
+	writeSearchFile(t, root, "internal/server/server.go", strings.Repeat("// server\n", 300))
+	writeSearchFile(t, root, "internal/server/server_test.go", "package server\n")
+	writeSearchFile(t, root, "web/src/app.ts", "export {}\n")
+	writeSearchFile(t, root, "web/src/app.test.ts", "test()\n")
+	writeSearchFile(t, root, "README.md", "# App\n")
+	writeSearchFile(t, root, "node_modules/dep/index.js", strings.Repeat("x", 5000))
+
+	ws, err := fileops.NewWorkspace(root, fileops.FollowInWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := FileFunctions{Workspace: ws}
+
+	out, err := f.SummarizeWorkspace(context.Background(), `{"top":2}`)
+	if err != nil {
+		t.Fatalf("SummarizeWorkspace failed: %v", err)
+	}
+	// Compare with columns collapsed to single spaces
+	flat := strings.Join(strings.Fields(out), " ")
+	for _, want := range []string{
+		"Workspace summary of .: 6 files, 3.0KB",
+		"Go 3 3.0KB 1 tests (0.50 per source file)",
+		"TypeScript 2 17B 1 tests (1.00 per source file) Markdown 1 6B Tests:",
+		"Tests: 2 test files for 3 source files (0.67 per source file)",
+		"(files, size): internal/ 2 2.9KB internal/server/ 2 2.9KB Largest files:",
+		"Largest files: internal/server/server.go 2.9KB internal/server/server_test.go 15B",
+	} {
+		if !strings.Contains(flat, want) {
+			t.Errorf("Expected %q in summary:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "node_modules") {
+		t.Errorf("Expected dependencies to be skipped:\n%s", out)
+	}
+
+	if _, err := f.SummarizeWorkspace(context.Background(), `{"path":"main.go"}`); err == nil {
+		t.Error("Expected summarizing a file to fail")
+	}
+	if _, err := f.SummarizeWorkspace(context.Background(), `{"path":"../.."}`); err == nil {
+		t.Error("Expected paths outside the workspace to be refused")
+	}
+}
+
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"pkg/a_test.go":       true,
+		"test_api.py":         true,
+		"src/app.spec.ts":     true,
+		"src/UserTest.java":   true,
+		"spec/models/user.rb": true,
+		"src/__tests__/a.js":  true,
+		"src/app.ts":          false,
+		"latest.go":           false,
+		"testdata/input.txt":  false,
+		"Test.java":           false,
+	}
+	for path, want := range tests {
+		if got := isTestFile(path); got != want {
+			t.Errorf("isTestFile(%q) = %t, want %t", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package functions
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Limits on recursive list_directory listings
+const (
+	defaultTreeDepth = 3
+	maxTreeDepth     = 20
+	maxTreeEntries   = 500
+	// maxTreeFiles bounds the files collected from a directory tree
+	maxTreeFiles = 50_000
+)
+
+// listParams are the arguments of list_directory
+type listParams struct {
+	Path          string `json:"path"`
+	Recursive     bool   `json:"recursive"`
+	MaxDepth      int    `json:"max_depth"`
+	Glob          string `json:"glob"`
+	IncludeHidden bool   `json:"include_hidden"`
+}
+
+// treeNode is a file or directory of a tree listing
+type treeNode struct {
+	name     string
+	size     int64
+	files    int // Files below a directory that passed the filters
+	children map[string]*treeNode
+}
+
+// isDir reports whether the node is a directory
+func (n *treeNode) isDir() bool {
+	return n.children != nil
+}
+
+// matchGlob reports whether the slash-separated path rel matches pattern.
+// Patterns without a slash are matched against the file name alone.
+func matchGlob(pattern, rel string) bool {
+	if pattern == "" {
+		return true
+	}
+	name := rel
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(rel)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// hiddenPath reports whether a component of the slash-separated path rel
+// starts with a dot
+func hiddenPath(rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// listTree renders the files under root as an indented tree, down to
+// params.MaxDepth levels; deeper directories show how many files they hold.
+// Git checkouts leave out the files git ignores, and the ignore file of the
+// workspace applies either way.
+func (f FileFunctions) listTree(root string, params listParams) (string, error) {
+	depth := params.MaxDepth
+	if depth <= 0 {
+		depth = defaultTreeDepth
+	}
+	depth = min(depth, maxTreeDepth)
+	if params.Glob != "" {
+		if _, err := path.Match(params.Glob, ""); err != nil {
+			return "", fmt.Errorf("invalid glob %q: %w", params.Glob, err)
+		}
+	}
+
+	paths, err := treeFiles(root, params.IncludeHidden)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s: %w", params.Path, err)
+	}
+
+	ignored := f.ignoredUnder(root)
+	ignoredDirs := make(map[string]bool)
+	dirIgnored := func(rel string) bool {
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			skip, ok := ignoredDirs[dir]
+			if !ok {
+				skip = ignored(dir, true)
+				ignoredDirs[dir] = skip
+			}
+			if skip {
+				return true
+			}
+		}
+		return false
+	}
+
+	tree := &treeNode{children: make(map[string]*treeNode)}
+	for _, rel := range paths {
+		if (!params.IncludeHidden && hiddenPath(rel)) || !matchGlob(params.Glob, rel) || ignored(rel, false) || dirIgnored(rel) {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || info.IsDir() {
+			continue // Deleted but still tracked, or a submodule
+		}
+		node := tree
+		parts := strings.Split(rel, "/")
+		for i, part := range parts {
+			node.files++
+			child := node.children[part]
+			if child == nil {
+				child = &treeNode{name: part}
+				if i < len(parts)-1 {
+					child.children = make(map[string]*treeNode)
+				}
+				node.children[part] = child
+			}
+			node = child
+		}
+		node.size = info.Size()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tree of %s (depth %d", root, depth)
+	if params.Glob != "" {
+		fmt.Fprintf(&b, ", files matching %s", params.Glob)
+	}
+	b.WriteString("):\n\n")
+	if tree.files == 0 {
+		b.WriteString("(no files)\n")
+		return b.String(), nil
+	}
+	shown, total := renderTree(&b, tree, 0, depth, 0)
+	if shown < total {
+		fmt.Fprintf(&b, "\n[%d of %d entries shown. List a subdirectory, or use a smaller max_depth or a glob, to see the rest.]\n", shown, total)
+	}
+	return b.String(), nil
+}
+
+// renderTree writes the children of node at the given level, directories
+// first, each sorted by name, while fewer than maxTreeEntries lines have been
+// written. It returns the entries written and the entries there were.
+func renderTree(b *strings.Builder, node *treeNode, level, depth, shown int) (int, int) {
+	children := make([]*treeNode, 0, len(node.children))
+	for _, child := range node.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].isDir() != children[j].isDir() {
+			return children[i].isDir()
+		}
+		return children[i].name < children[j].name
+	})
+
+	total := 0
+	indent := strings.Repeat("  ", level)
+	for _, child := range children {
+		total++
+		show := shown < maxTreeEntries
+		switch {
+		case !child.isDir():
+			if show {
+				fmt.Fprintf(b, "%s%s (%s)\n", indent, child.name, formatSize(child.size))
+			}
+		case level+1 >= depth:
+			if show {
+				files := fmt.Sprintf("%d files", child.files)
+				if child.files == 1 {
+					files = "1 file"
+				}
+				fmt.Fprintf(b, "%s%s/ (%s)\n", indent, child.name, files)
+			}
+		default:
+			if show {
+				fmt.Fprintf(b, "%s%s/\n", indent, child.name)
+				shown++
+			}
+			var below int
+			shown, below = renderTree(b, child, level+1, depth, shown)
+			total += below
+			continue
+		}
+		if show {
+			shown++
+		}
+	}
+	return shown, total
+}
+
+// treeFiles returns the slash-separated paths of the files under root. In a
+// git checkout, they are the tracked and untracked files git does not
+// ignore; elsewhere the tree is walked, skipping dependency and build
+// directories, and hidden ones unless includeHidden is set.
+func treeFiles(root string, includeHidden bool) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		var paths []string
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() && len(paths) < maxTreeFiles {
+			if line := scanner.Text(); line != "" {
+				paths = append(paths, line)
+			}
+		}
+		return paths, scanner.Err()
+	}
+
+	var paths []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if len(paths) >= maxTreeFiles {
+			return filepath.SkipAll
+		}
+		if d.IsDir() {
+			if p != root && (searchSkipDirs[d.Name()] || (!includeHidden && strings.HasPrefix(d.Name(), "."))) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel, err := filepath.Rel(root, p); err == nil {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return paths, err
+}
@@ -0,0 +1,87 @@
+package functions
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/fileops"
+	"github.com/epuerta/codex-go/internal/ignore"
+)
+
+func TestListDirectoryTree(t *testing.T) {
+	root := t.TempDir()
+	for rel, content := range map[string]string{
+		"go.mod":                    "module example\n",
+		"cmd/app/main.go":           "package main\n",
+		"internal/store/store.go":   "package store\n",
+		"internal/store/db/db.go":   "package db\n",
+		"internal/store/README.md":  "# Store\n",
+		".github/workflows/ci.yml":  "on: push\n",
+		"secrets/prod.yaml":         "token: secret\n",
+		"node_modules/left/pad.js":  "module.exports = 1\n",
+		"internal/store/db/test.go": "package db\n",
+	} {
+		writeSearchFile(t, root, rel, content)
+	}
+	ws, err := fileops.NewWorkspace(root, fileops.FollowInWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Ignore = ignore.New(ws.Root, []string{"secrets/"})
+	f := FileFunctions{Workspace: ws}
+	list := func(args string) string {
+		t.Helper()
+		out, err := f.ListDirectory(args)
+		if err != nil {
+			t.Fatalf("ListDirectory(%s) failed: %v", args, err)
+		}
+		_, tree, _ := strings.Cut(out, "\n\n")
+		return tree
+	}
+
+	want := `cmd/
+  app/
+    main.go (13B)
+internal/
+  store/
+    db/ (2 files)
+    README.md (8B)
+    store.go (14B)
+go.mod (15B)
+`
+	if got := list(`{"path":".","recursive":true}`); got != want {
+		t.Errorf("Unexpected tree:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = `internal/
+  store/
+    db/
+      db.go (11B)
+      test.go (11B)
+    store.go (14B)
+`
+	if got := list(`{"path":".","max_depth":5,"glob":"*.go","include_hidden":false}`); !strings.HasSuffix(got, want) || !strings.HasPrefix(got, "cmd/\n") {
+		t.Errorf("Unexpected tree of Go files:\n%s", got)
+	}
+	if got := list(`{"path":".","recursive":true,"include_hidden":true}`); !strings.Contains(got, ".github/\n  workflows/\n    ci.yml (9B)\n") {
+		t.Errorf("Expected hidden directories to be listed:\n%s", got)
+	}
+	if got := list(`{"path":".","recursive":true,"glob":"*.rs"}`); got != "(no files)\n" {
+		t.Errorf("Expected no files, got:\n%s", got)
+	}
+	if _, err := f.ListDirectory(`{"path":".","recursive":true,"glob":"["}`); err == nil {
+		t.Error("Expected an invalid glob to fail")
+	}
+
+	// Git checkouts leave out what git ignores
+	if _, err := exec.LookPath("git"); err == nil {
+		writeSearchFile(t, root, ".gitignore", "*.md\n")
+		if err := exec.Command("git", "-C", root, "init", "-q").Run(); err != nil {
+			t.Fatal(err)
+		}
+		if got := list(`{"path":"internal","recursive":true}`); strings.Contains(got, "README.md") || !strings.Contains(got, "store.go") {
+			t.Errorf("Expected the files git ignores to be left out:\n%s", got)
+		}
+	}
+}
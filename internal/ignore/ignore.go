@@ -0,0 +1,196 @@
+// Package ignore reads .codexignore files: paths listed there, in gitignore
+// syntax, are hidden from the agent. They are left out of directory
+// listings, searches and the repository context, and file tools refuse to
+// read or change them, so secrets and vendored bulk never reach the model.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FileName is the ignore file, at the repository root
+const FileName = ".codexignore"
+
+// rule is one pattern of the file
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool // "!pattern" re-includes what earlier patterns excluded
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// Matcher holds the patterns of an ignore file. A nil Matcher ignores
+// nothing.
+type Matcher struct {
+	Root  string // Directory the patterns are relative to
+	rules []rule
+}
+
+// Load reads the .codexignore file of root. It returns nil without error if
+// there is none.
+func Load(root string) (*Matcher, error) {
+	f, err := os.Open(filepath.Join(root, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	if canonical, err := filepath.EvalSymlinks(root); err == nil {
+		root = canonical
+	}
+	return New(root, patterns), nil
+}
+
+// New returns a matcher for patterns in gitignore syntax, relative to root.
+// Blank lines and comments are skipped.
+func New(root string, patterns []string) *Matcher {
+	m := &Matcher{Root: root}
+	for _, pattern := range patterns {
+		if r, ok := compile(pattern); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+	return m
+}
+
+// Len returns the number of patterns
+func (m *Matcher) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.rules)
+}
+
+// Match reports whether the slash-separated path, relative to the root, is
+// ignored. As with git, a file in an ignored directory is ignored, whatever
+// later patterns say about the file.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	if m.Len() == 0 {
+		return false
+	}
+	rel = strings.Trim(rel, "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.match(rel, isDir)
+}
+
+// Ignored is Match for an absolute path. Paths outside the root are never
+// ignored.
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	if m.Len() == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(m.Root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return m.Match(filepath.ToSlash(rel), isDir)
+}
+
+// match applies the rules to one path; the last rule matching it decides
+func (m *Matcher) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// compile turns a line of an ignore file into a rule, reporting false for
+// blank lines and comments
+func compile(line string) (rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// Patterns with a slash before the end are relative to the root; others
+	// match at any depth
+	var b strings.Builder
+	b.WriteString("^")
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], "**/") && (i == 0 || line[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**") && i+2 == len(line) && (i == 0 || line[i-1] == '/'):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
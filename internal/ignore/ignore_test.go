@@ -0,0 +1,88 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	m := New("/repo", []string{
+		"# Secrets",
+		".env",
+		"*.pem",
+		"/build",
+		"vendor/",
+		"docs/**/*.gen.md",
+		"logs/**",
+		"config/secret?.yaml",
+		"!keep.pem",
+		"data/[ab].csv",
+		`\#notes`,
+		"",
+	})
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{".env", false, true},
+		{"services/api/.env", false, true},
+		{".env.example", false, false},
+		{"certs/server.pem", false, true},
+		{"certs/keep.pem", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"cmd/build", true, false}, // Anchored to the root
+		{"vendor", true, true},
+		{"lib/vendor/x.go", false, true},
+		{"vendor", false, false}, // Only directories
+		{"docs/api.gen.md", false, true},
+		{"docs/a/b/api.gen.md", false, true},
+		{"docs/api.md", false, false},
+		{"logs/2024/app.log", false, true},
+		{"config/secret1.yaml", false, true},
+		{"config/secret12.yaml", false, false},
+		{"data/a.csv", false, true},
+		{"data/c.csv", false, false},
+		{"#notes", false, true},
+		{"main.go", false, false},
+		{".", true, false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %t) = %t, want %t", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatchIgnoredDirectoryWins(t *testing.T) {
+	// As with git, a file cannot be re-included when its directory is excluded
+	m := New("/repo", []string{"secrets/", "!secrets/public.txt"})
+	if !m.Match("secrets/public.txt", false) {
+		t.Error("Expected a file in an ignored directory to stay ignored")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	if m, err := Load(root); err != nil || m != nil || m.Match(".env", false) {
+		t.Fatalf("Expected no matcher without a file, got %v (%v)", m, err)
+	}
+	if err := os.WriteFile(filepath.Join(root, FileName), []byte(".env\nsecrets/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Expected 2 patterns, got %d", m.Len())
+	}
+	if !m.Ignored(filepath.Join(m.Root, "secrets", "key"), false) || m.Ignored(filepath.Join(m.Root, "main.go"), false) {
+		t.Error("Unexpected matching of absolute paths")
+	}
+	if m.Ignored(filepath.Join(filepath.Dir(m.Root), ".env"), false) {
+		t.Error("Expected paths outside the root not to be ignored")
+	}
+}
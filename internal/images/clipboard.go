@@ -0,0 +1,87 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrNoClipboardImage is returned by ReadClipboard when the clipboard holds no image
+var ErrNoClipboardImage = errors.New("the clipboard does not contain an image")
+
+// ReadClipboard returns the image on the system clipboard, prepared with
+// Prepare. It shells out to the platform clipboard tool: osascript on macOS,
+// wl-paste or xclip on Linux, and PowerShell on Windows.
+func ReadClipboard(ctx context.Context) (*Image, error) {
+	var data []byte
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		data, err = readClipboardFile(ctx, func(path string) *exec.Cmd {
+			return exec.CommandContext(ctx, "osascript",
+				"-e", fmt.Sprintf(`set f to open for access POSIX file %q with write permission`, path),
+				"-e", `write (the clipboard as «class PNGf») to f`,
+				"-e", `close access f`)
+		})
+	case "windows":
+		data, err = readClipboardFile(ctx, func(path string) *exec.Cmd {
+			script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; $img = [System.Windows.Forms.Clipboard]::GetImage(); if ($img) { $img.Save('%s', [System.Drawing.Imaging.ImageFormat]::Png) }`, path)
+			return exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script)
+		})
+	case "linux", "freebsd", "openbsd":
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			data, err = readClipboardStdout(ctx, "wl-paste", "--no-newline", "--type", "image/png")
+		} else {
+			data, err = readClipboardStdout(ctx, "xclip", "-selection", "clipboard", "-t", "image/png", "-o")
+		}
+	default:
+		return nil, fmt.Errorf("reading images from the clipboard is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrNoClipboardImage
+	}
+	return Prepare("clipboard.png", data)
+}
+
+// readClipboardStdout runs a tool that writes the clipboard image to stdout
+func readClipboardStdout(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is required to paste images from the clipboard", name)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Both tools fail when no image target is offered
+		return nil, ErrNoClipboardImage
+	}
+	return stdout.Bytes(), nil
+}
+
+// readClipboardFile runs a command that saves the clipboard image to a file
+func readClipboardFile(ctx context.Context, command func(path string) *exec.Cmd) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "codex-clipboard-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "clipboard.png")
+	if err := command(path).Run(); err != nil {
+		return nil, ErrNoClipboardImage
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoClipboardImage
+	}
+	return data, err
+}
@@ -0,0 +1,176 @@
+// Package images prepares image attachments for vision-capable models
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register GIF decoding
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// MaxDimension is the longest side, in pixels, an image is sent at.
+	// Larger images are downscaled; the API would shrink them anyway.
+	MaxDimension = 2048
+
+	// MaxBytes is the largest encoded image accepted by the API
+	MaxBytes = 20 << 20
+
+	// jpegQuality is used when re-encoding downscaled images as JPEG
+	jpegQuality = 85
+)
+
+// supportedTypes are the MIME types accepted by the API
+var supportedTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Image is an image ready to be attached to a message
+type Image struct {
+	Name     string // File name shown to the user
+	MIMEType string
+	Data     []byte
+	Width    int // 0 if the format could not be decoded (WebP)
+	Height   int
+	Resized  bool // Whether the image was downscaled or re-encoded
+}
+
+// DataURL returns the image encoded as a data URL
+func (img *Image) DataURL() string {
+	return "data:" + img.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// Summary describes the image for display, e.g. "shot.png (1024x768, 210 KB)"
+func (img *Image) Summary() string {
+	size := fmt.Sprintf("%d KB", (len(img.Data)+1023)/1024)
+	if img.Width == 0 {
+		return fmt.Sprintf("%s (%s)", img.Name, size)
+	}
+	note := ""
+	if img.Resized {
+		note = ", downscaled"
+	}
+	return fmt.Sprintf("%s (%dx%d, %s%s)", img.Name, img.Width, img.Height, size, note)
+}
+
+// Load reads an image file and prepares it with Prepare
+func Load(path string) (*Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return Prepare(filepath.Base(path), data)
+}
+
+// Prepare checks that data is a supported image and downscales it if it
+// exceeds MaxDimension or MaxBytes
+func Prepare(name string, data []byte) (*Image, error) {
+	mimeType := http.DetectContentType(data)
+	if !supportedTypes[mimeType] {
+		return nil, fmt.Errorf("%s is not a supported image (got %s; want PNG, JPEG, GIF or WebP)", name, mimeType)
+	}
+
+	img := &Image{Name: name, MIMEType: mimeType, Data: data}
+	if mimeType == "image/webp" {
+		// The standard library cannot decode WebP, so it is sent as is
+		if len(data) > MaxBytes {
+			return nil, fmt.Errorf("%s is %d MB; WebP images larger than %d MB cannot be downscaled", name, len(data)>>20, MaxBytes>>20)
+		}
+		return img, nil
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	bounds := decoded.Bounds()
+	img.Width, img.Height = bounds.Dx(), bounds.Dy()
+	if img.Width <= MaxDimension && img.Height <= MaxDimension && len(data) <= MaxBytes {
+		return img, nil
+	}
+
+	scaled := downscale(decoded, MaxDimension)
+	img.Width, img.Height = scaled.Bounds().Dx(), scaled.Bounds().Dy()
+	img.Resized = true
+
+	// Keep PNG for lossless sources unless it is still too large
+	var buf bytes.Buffer
+	if mimeType == "image/png" {
+		if err := png.Encode(&buf, scaled); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+	}
+	if buf.Len() == 0 || buf.Len() > MaxBytes {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, flatten(scaled), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		mimeType = "image/jpeg"
+	}
+	if buf.Len() > MaxBytes {
+		return nil, fmt.Errorf("%s is still larger than %d MB after downscaling", name, MaxBytes>>20)
+	}
+	img.MIMEType = mimeType
+	img.Data = buf.Bytes()
+	return img, nil
+}
+
+// downscale shrinks src so that neither side exceeds maxDim, averaging the
+// source pixels covered by each destination pixel
+func downscale(src image.Image, maxDim int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxDim && h <= maxDim {
+		return src
+	}
+
+	dw, dh := maxDim, h*maxDim/w
+	if h > w {
+		dw, dh = w*maxDim/h, maxDim
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// flatten draws src over a white background, since JPEG has no alpha channel
+func flatten(src image.Image) image.Image {
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,58 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPrepare(t *testing.T) {
+	small := encodePNG(t, 40, 30)
+	img, err := Prepare("small.png", small)
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if img.Resized || img.Width != 40 || img.Height != 30 || !bytes.Equal(img.Data, small) {
+		t.Errorf("Expected small image to be kept as is, got %s", img.Summary())
+	}
+	if !strings.HasPrefix(img.DataURL(), "data:image/png;base64,") {
+		t.Errorf("Unexpected data URL prefix: %.40s", img.DataURL())
+	}
+
+	big, err := Prepare("big.png", encodePNG(t, MaxDimension*2, MaxDimension/2))
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if !big.Resized || big.Width != MaxDimension || big.Height != MaxDimension/4 {
+		t.Errorf("Expected downscale to %dx%d, got %s", MaxDimension, MaxDimension/4, big.Summary())
+	}
+	decoded, err := png.Decode(bytes.NewReader(big.Data))
+	if err != nil {
+		t.Fatalf("Downscaled data is not a PNG: %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != big.Width || b.Dy() != big.Height {
+		t.Errorf("Encoded size %v does not match %dx%d", b, big.Width, big.Height)
+	}
+
+	if _, err := Prepare("notes.txt", []byte("just some text")); err == nil {
+		t.Error("Expected an error for non-image data")
+	}
+}
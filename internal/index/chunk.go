@@ -0,0 +1,174 @@
+package index
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Chunk size limits, in lines
+const (
+	maxChunkLines = 60 // Longer declarations are split into windows of this size
+	minChunkLines = 8  // Shorter declarations are merged into the chunk before them
+)
+
+// maxFileSize is the largest file that is indexed
+const maxFileSize = 256 << 10
+
+// skipFiles are generated files whose contents are not worth embedding
+var skipFiles = map[string]bool{
+	"go.sum": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"bun.lockb": true, "Cargo.lock": true, "poetry.lock": true, "Gemfile.lock": true,
+}
+
+// declPattern matches a top-level declaration in languages without a parser
+// and captures its name
+var declPattern = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:pub(?:\([^)]*\))?\s+)?(?:abstract\s+)?(?:async\s+)?(?:def|class|func|function\*?|fn|struct|enum|trait|impl|interface|type|module)\s+([A-Za-z_$][\w$]*)`)
+
+// headingPattern matches a Markdown heading that starts a new section
+var headingPattern = regexp.MustCompile(`^#{1,3}\s+(.+)$`)
+
+// boundary is a line where a declaration or section starts
+type boundary struct {
+	line   int    // 0-based
+	symbol string // May be empty, e.g. for imports
+}
+
+// indexable reports whether a file should be chunked at all
+func indexable(rel string, size int64) bool {
+	return size > 0 && size <= maxFileSize && !skipFiles[path.Base(rel)]
+}
+
+// chunkFile splits a source file into chunks along its top-level
+// declarations. Binary files yield no chunks.
+func chunkFile(rel string, src []byte) []Chunk {
+	if bytes.IndexByte(src[:min(len(src), 8000)], 0) >= 0 {
+		return nil
+	}
+	text := strings.TrimRight(string(src), "\n")
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+
+	bounds := fileBoundaries(rel, src, lines)
+	if len(bounds) == 0 || bounds[0].line != 0 {
+		bounds = append([]boundary{{line: 0}}, bounds...)
+	}
+
+	var chunks []Chunk
+	for i, b := range bounds {
+		end := len(lines)
+		if i+1 < len(bounds) {
+			end = bounds[i+1].line
+		}
+		if end <= b.line {
+			continue
+		}
+
+		// Fold short sections into the previous chunk while it has room
+		if n := len(chunks); n > 0 && end-b.line < minChunkLines && end-chunks[n-1].StartLine+1 <= maxChunkLines {
+			last := &chunks[n-1]
+			last.EndLine = end
+			if b.symbol != "" {
+				last.Symbols = append(last.Symbols, b.symbol)
+			}
+			continue
+		}
+
+		for start := b.line; start < end; start += maxChunkLines {
+			c := Chunk{Path: rel, StartLine: start + 1, EndLine: min(start+maxChunkLines, end)}
+			if b.symbol != "" {
+				c.Symbols = []string{b.symbol}
+			}
+			chunks = append(chunks, c)
+		}
+	}
+
+	for i := range chunks {
+		c := &chunks[i]
+		c.Text = strings.Join(lines[c.StartLine-1:c.EndLine], "\n")
+	}
+	return chunks
+}
+
+// fileBoundaries finds where the top-level declarations of a file start,
+// sorted by line
+func fileBoundaries(rel string, src []byte, lines []string) []boundary {
+	var bounds []boundary
+	switch strings.ToLower(path.Ext(rel)) {
+	case ".go":
+		bounds = goBoundaries(src)
+	case ".md", ".markdown":
+		for i, line := range lines {
+			if m := headingPattern.FindStringSubmatch(line); m != nil {
+				bounds = append(bounds, boundary{line: i, symbol: strings.TrimSpace(m[1])})
+			}
+		}
+	default:
+		for i, line := range lines {
+			if m := declPattern.FindStringSubmatch(line); m != nil {
+				bounds = append(bounds, boundary{line: i, symbol: m[1]})
+			}
+		}
+	}
+	sort.SliceStable(bounds, func(i, j int) bool { return bounds[i].line < bounds[j].line })
+	return bounds
+}
+
+// goBoundaries lists the declarations of Go source, starting at their doc
+// comments. Methods are named Type.Method.
+func goBoundaries(src []byte) []boundary {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil && file == nil {
+		return nil
+	}
+
+	var bounds []boundary
+	for _, decl := range file.Decls {
+		pos := decl.Pos()
+		var symbol string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+			symbol = d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				if recv := receiverName(d.Recv.List[0].Type); recv != "" {
+					symbol = recv + "." + symbol
+				}
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+			if d.Tok == token.TYPE && len(d.Specs) > 0 {
+				symbol = d.Specs[0].(*ast.TypeSpec).Name.Name
+			}
+		}
+		bounds = append(bounds, boundary{line: fset.Position(pos).Line - 1, symbol: symbol})
+	}
+	return bounds
+}
+
+// receiverName returns the type name of a method receiver
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/provider"
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultModel is the embedding model used when none is configured
+const DefaultModel = "text-embedding-3-small"
+
+// embedBatchSize is the number of chunks embedded per request
+const embedBatchSize = 64
+
+// maxEmbeddingInput bounds the text of one chunk sent to the embedder, well
+// under the model's token limit
+const maxEmbeddingInput = 8000
+
+// Embedder turns texts into embedding vectors
+type Embedder interface {
+	// Model names the embedding model; vectors of different models are not comparable
+	Model() string
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// OpenAIEmbedder embeds texts with the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAIEmbedder creates an embedder using the embeddings endpoint and
+// model of cfg. Any OpenAI-compatible embeddings API works, such as Ollama's.
+func NewOpenAIEmbedder(cfg *config.Config) *OpenAIEmbedder {
+	model := cfg.EmbeddingModel
+	if model == "" {
+		model = DefaultModel
+	}
+	return &OpenAIEmbedder{client: provider.NewClient(provider.EmbeddingOptions(cfg)), model: model}
+}
+
+// Model returns the embedding model
+func (e *OpenAIEmbedder) Model() string {
+	return e.model
+}
+
+// Embed returns the embeddings of texts
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: openai.EmbeddingModel(e.model),
+	})
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// embeddingInput is the text embedded for a chunk: its location and symbols
+// followed by the code
+func embeddingInput(c *Chunk) string {
+	header := c.Path
+	if len(c.Symbols) > 0 {
+		header += " (" + strings.Join(c.Symbols, ", ") + ")"
+	}
+	input := header + "\n" + c.Text
+	if len(input) > maxEmbeddingInput {
+		input = input[:maxEmbeddingInput]
+	}
+	return input
+}
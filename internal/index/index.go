@@ -0,0 +1,356 @@
+// Package index builds and searches an embeddings index of a repository,
+// chunked along top-level declarations, so the agent can find code by
+// meaning rather than by exact text.
+package index
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/repomap"
+)
+
+// Dir is the index directory, relative to the repository root
+const Dir = ".codex/index"
+
+// FileName is the name of the persisted index inside Dir
+const FileName = "index.json"
+
+// indexVersion is bumped whenever the entry format or chunking changes
+const indexVersion = 1
+
+// Chunk is a span of a file and its embedding
+type Chunk struct {
+	Path      string   `json:"path"`       // Slash-separated, relative to the root
+	StartLine int      `json:"start_line"` // 1-based, inclusive
+	EndLine   int      `json:"end_line"`   // 1-based, inclusive
+	Symbols   []string `json:"symbols,omitempty"`
+	Text      string   `json:"text"`
+	Vector    Vector   `json:"vector"`
+}
+
+// FileEntry holds the chunks of one indexed file
+type FileEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Chunks  []Chunk   `json:"chunks,omitempty"`
+}
+
+// Index is an embeddings index of the files in a repository
+type Index struct {
+	Version int         `json:"version"`
+	Root    string      `json:"root"`
+	Model   string      `json:"model"`
+	BuiltAt time.Time   `json:"built_at"`
+	Files   []FileEntry `json:"files"`
+}
+
+// Stats reports the work done by an incremental build
+type Stats struct {
+	Embedded int // New or changed files that were chunked and embedded
+	Reused   int // Unchanged files taken from the previous index
+	Removed  int // Indexed files that no longer exist
+	Chunks   int // Chunks embedded
+}
+
+// Status compares an index with the files currently in the repository
+type Status struct {
+	Files   int
+	Chunks  int
+	Model   string
+	BuiltAt time.Time
+	Changed int // Indexed files modified since the build
+	Added   int // Files not in the index
+	Removed int // Indexed files that no longer exist
+}
+
+// Stale reports whether the index no longer matches the repository
+func (s Status) Stale() bool {
+	return s.Changed > 0 || s.Added > 0 || s.Removed > 0
+}
+
+// Result is a chunk matching a query
+type Result struct {
+	Chunk
+	Score float32 // Cosine similarity with the query
+}
+
+// Path returns the location of the persisted index for a repository root
+func Path(root string) string {
+	return filepath.Join(root, Dir, FileName)
+}
+
+// Load reads the persisted index for root. It returns nil without error if
+// there is no usable index.
+func Load(root string) (*Index, error) {
+	data, err := os.ReadFile(Path(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil || ix.Version != indexVersion || ix.Root != root {
+		// Corrupt, outdated or moved indexes are rebuilt from scratch
+		return nil, nil
+	}
+	return &ix, nil
+}
+
+// Save persists the index under the repository's index directory
+func (ix *Index) Save() error {
+	dir := filepath.Join(ix.Root, Dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	// Keep the index out of git status
+	ignorePath := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignorePath); errors.Is(err, os.ErrNotExist) {
+		os.WriteFile(ignorePath, []byte("*\n"), 0644)
+	}
+
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	tmp := Path(ix.Root) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return os.Rename(tmp, Path(ix.Root))
+}
+
+// Build chunks and embeds the repository's files, except those ignore
+// matches. Entries from prev whose
+// size and modification time are unchanged are reused without calling the
+// embedder, unless prev was built with another model. progress, if not nil,
+// is called after each batch with the chunks embedded so far and in total.
+func Build(ctx context.Context, root string, prev *Index, emb Embedder, ignore repomap.Ignore, progress func(done, total int)) (*Index, Stats, error) {
+	var stats Stats
+	paths, err := repomap.ListFiles(root)
+	if err != nil {
+		return nil, stats, err
+	}
+	paths = ignore.Filter(paths)
+
+	cached := make(map[string]FileEntry)
+	if prev != nil && prev.Model == emb.Model() {
+		for _, entry := range prev.Files {
+			cached[entry.Path] = entry
+		}
+	}
+
+	ix := &Index{Version: indexVersion, Root: root, Model: emb.Model(), BuiltAt: time.Now()}
+	var pending []*Chunk
+	var fresh []int // Indexes into ix.Files of entries whose chunks are pending
+	for _, rel := range paths {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() || !indexable(rel, info.Size()) {
+			continue
+		}
+
+		if entry, ok := cached[rel]; ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			ix.Files = append(ix.Files, entry)
+			delete(cached, rel)
+			stats.Reused++
+			continue
+		}
+		delete(cached, rel)
+
+		src, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		entry := FileEntry{Path: rel, Size: info.Size(), ModTime: info.ModTime(), Chunks: chunkFile(rel, src)}
+		if len(entry.Chunks) == 0 {
+			continue
+		}
+		ix.Files = append(ix.Files, entry)
+		fresh = append(fresh, len(ix.Files)-1)
+		stats.Embedded++
+	}
+	stats.Removed = len(cached)
+
+	for _, i := range fresh {
+		for j := range ix.Files[i].Chunks {
+			pending = append(pending, &ix.Files[i].Chunks[j])
+		}
+	}
+	for start := 0; start < len(pending); start += embedBatchSize {
+		batch := pending[start:min(start+embedBatchSize, len(pending))]
+		inputs := make([]string, len(batch))
+		for i, c := range batch {
+			inputs[i] = embeddingInput(c)
+		}
+		vectors, err := emb.Embed(ctx, inputs)
+		if err != nil {
+			return nil, stats, fmt.Errorf("failed to embed %s: %w", batch[0].Path, err)
+		}
+		if len(vectors) != len(batch) {
+			return nil, stats, fmt.Errorf("embedder returned %d vectors for %d inputs", len(vectors), len(batch))
+		}
+		for i, c := range batch {
+			c.Vector = normalize(vectors[i])
+		}
+		stats.Chunks += len(batch)
+		if progress != nil {
+			progress(stats.Chunks, len(pending))
+		}
+	}
+
+	sort.Slice(ix.Files, func(i, j int) bool { return ix.Files[i].Path < ix.Files[j].Path })
+	return ix, stats, nil
+}
+
+// Refresh loads the index for root, updates it incrementally and saves it
+func Refresh(ctx context.Context, root string, emb Embedder, ignore repomap.Ignore, progress func(done, total int)) (*Index, Stats, error) {
+	prev, err := Load(root)
+	if err != nil {
+		prev = nil
+	}
+	ix, stats, err := Build(ctx, root, prev, emb, ignore, progress)
+	if err != nil {
+		return nil, stats, err
+	}
+	if prev == nil || stats.Embedded > 0 || stats.Removed > 0 || prev.Model != ix.Model {
+		if err := ix.Save(); err != nil {
+			return ix, stats, err
+		}
+	}
+	return ix, stats, nil
+}
+
+// Status compares the index with the files currently in the repository,
+// except those ignore matches
+func (ix *Index) Status(ignore repomap.Ignore) (Status, error) {
+	st := Status{Files: len(ix.Files), Model: ix.Model, BuiltAt: ix.BuiltAt}
+	indexed := make(map[string]FileEntry, len(ix.Files))
+	for _, entry := range ix.Files {
+		indexed[entry.Path] = entry
+		st.Chunks += len(entry.Chunks)
+	}
+
+	paths, err := repomap.ListFiles(ix.Root)
+	if err != nil {
+		return st, err
+	}
+	paths = ignore.Filter(paths)
+	for _, rel := range paths {
+		info, err := os.Stat(filepath.Join(ix.Root, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() || !indexable(rel, info.Size()) {
+			continue
+		}
+		entry, ok := indexed[rel]
+		switch {
+		case !ok:
+			st.Added++
+		case entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()):
+			st.Changed++
+		}
+		delete(indexed, rel)
+	}
+	st.Removed = len(indexed)
+	return st, nil
+}
+
+// Search returns the k chunks most similar to the query
+func (ix *Index) Search(ctx context.Context, emb Embedder, query string, k int) ([]Result, error) {
+	if emb.Model() != ix.Model {
+		return nil, fmt.Errorf("index was built with %s, not %s; rebuild it with 'codex index build'", ix.Model, emb.Model())
+	}
+	vectors, err := emb.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for the query", len(vectors))
+	}
+	q := normalize(vectors[0])
+
+	var results []Result
+	for _, entry := range ix.Files {
+		for _, c := range entry.Chunks {
+			if len(c.Vector) != len(q) {
+				continue
+			}
+			results = append(results, Result{Chunk: c, Score: dot(q, c.Vector)})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Vector is an embedding. It is stored as base64 little-endian float32s,
+// which is several times smaller than a JSON array.
+type Vector []float32
+
+// MarshalJSON encodes the vector as a base64 string
+func (v Vector) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON decodes a vector written by MarshalJSON
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(buf)%4 != 0 {
+		return fmt.Errorf("vector has %d bytes, not a multiple of 4", len(buf))
+	}
+	*v = make(Vector, len(buf)/4)
+	for i := range *v {
+		(*v)[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return nil
+}
+
+// normalize scales a vector to unit length, so the dot product of two
+// normalized vectors is their cosine similarity
+func normalize(v []float32) Vector {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	out := make(Vector, len(v))
+	if sum == 0 {
+		return out
+	}
+	norm := float32(math.Sqrt(sum))
+	for i, f := range v {
+		out[i] = f / norm
+	}
+	return out
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b Vector) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,180 @@
+package index
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// wordEmbedder embeds texts as bags of hashed words, so texts sharing
+// words are similar
+type wordEmbedder struct {
+	model string
+	calls int // Texts embedded so far
+}
+
+func (e *wordEmbedder) Model() string { return e.model }
+
+func (e *wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, 64)
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			v[h.Sum32()%64]++
+		}
+		vectors[i] = v
+	}
+	e.calls += len(texts)
+	return vectors, nil
+}
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChunkFile(t *testing.T) {
+	var long strings.Builder
+	for i := 0; i < maxChunkLines+10; i++ {
+		long.WriteString("\tx++\n")
+	}
+	src := `package server
+
+import "net/http"
+
+// Server answers requests
+type Server struct{}
+
+// Retry retries failed requests with backoff
+func (s *Server) Retry() {
+` + long.String() + `}
+
+func helper() {}
+`
+	chunks := chunkFile("server.go", []byte(src))
+	var got []string
+	for _, c := range chunks {
+		got = append(got, strings.Join(c.Symbols, ","))
+	}
+	// The short type folds into the preamble; the long method is split in two
+	want := []string{"Server", "Server.Retry", "Server.Retry,helper"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunk symbols = %q, want %q", got, want)
+	}
+	if chunks[1].StartLine != 8 || chunks[1].EndLine != 8+maxChunkLines-1 {
+		t.Errorf("Expected the method to start at its doc comment, got lines %d-%d", chunks[1].StartLine, chunks[1].EndLine)
+	}
+	if !strings.HasPrefix(chunks[1].Text, "// Retry retries") {
+		t.Errorf("Unexpected chunk text %q", chunks[1].Text[:30])
+	}
+
+	if chunks := chunkFile("README.md", []byte("# Title\nIntro\n## Usage\nRun it\n")); len(chunks) != 1 || !reflect.DeepEqual(chunks[0].Symbols, []string{"Title", "Usage"}) {
+		t.Errorf("Unexpected Markdown chunks %+v", chunks)
+	}
+	if chunks := chunkFile("logo.png", []byte("\x89PNG\x00\x01")); chunks != nil {
+		t.Errorf("Expected binary files to be skipped, got %d chunks", len(chunks))
+	}
+}
+
+func TestBuildAndSearch(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "retry.go", "package api\n\n// Retry requests with exponential backoff\nfunc Retry() {}\n")
+	writeFile(t, root, "render.go", "package ui\n\n// Render draws the chat view\nfunc Render() {}\n")
+	writeFile(t, root, "go.sum", "example.com/dep v1.0.0 h1:abc=\n")
+
+	emb := &wordEmbedder{model: "words"}
+	ix, stats, err := Refresh(context.Background(), root, emb, nil, nil)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if stats.Embedded != 2 || stats.Reused != 0 || len(ix.Files) != 2 {
+		t.Errorf("Expected two files embedded, got %+v with %d files", stats, len(ix.Files))
+	}
+
+	results, err := ix.Search(context.Background(), emb, "exponential backoff for requests", 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != "retry.go" {
+		t.Errorf("Expected retry.go to match best, got %+v", results)
+	}
+
+	// The persisted index round-trips, vectors included
+	loaded, err := Load(root)
+	if err != nil || loaded == nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Files[0].Chunks[0].Vector, ix.Files[0].Chunks[0].Vector) {
+		t.Error("Expected vectors to survive saving and loading")
+	}
+
+	// Only changed files are embedded again
+	later := time.Now().Add(time.Hour)
+	writeFile(t, root, "render.go", "package ui\n\n// Render draws the chat view and the input\nfunc Render() {}\n")
+	os.Chtimes(filepath.Join(root, "render.go"), later, later)
+	os.Remove(filepath.Join(root, "retry.go"))
+	writeFile(t, root, "docs/notes.txt", "Notes about retries\n")
+
+	st, err := loaded.Status(nil)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !st.Stale() || st.Changed != 1 || st.Added != 1 || st.Removed != 1 || st.Chunks != 2 {
+		t.Errorf("Unexpected status %+v", st)
+	}
+
+	emb.calls = 0
+	_, stats, err = Refresh(context.Background(), root, emb, nil, nil)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if stats.Embedded != 2 || stats.Removed != 1 || emb.calls != 2 {
+		t.Errorf("Expected two files re-embedded and one removed, got %+v after %d embeddings", stats, emb.calls)
+	}
+
+	// Changing the model discards the previous vectors
+	other := &wordEmbedder{model: "other"}
+	if _, err := loaded.Search(context.Background(), other, "x", 1); err == nil {
+		t.Error("Expected searching with another model to fail")
+	}
+	if _, stats, _ := Refresh(context.Background(), root, other, nil, nil); stats.Reused != 0 {
+		t.Errorf("Expected nothing to be reused across models, got %+v", stats)
+	}
+}
+
+func TestSemanticSearchTool(t *testing.T) {
+	root := t.TempDir()
+	s := NewSearcher(root, &wordEmbedder{model: "words"})
+	if _, err := s.SemanticSearch(context.Background(), `{"query":"retries"}`); err == nil || !strings.Contains(err.Error(), "codex index build") {
+		t.Errorf("Expected a missing index error, got %v", err)
+	}
+
+	writeFile(t, root, "retry.go", "package api\n\n// Retry requests with exponential backoff\nfunc Retry() {}\n")
+	if _, _, err := Refresh(context.Background(), root, s.Embedder, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	out, err := s.SemanticSearch(context.Background(), `{"query":"exponential backoff"}`)
+	if err != nil {
+		t.Fatalf("SemanticSearch failed: %v", err)
+	}
+	if !strings.HasPrefix(out, "retry.go:1-4 (Retry) score") || !strings.Contains(out, "func Retry() {}") {
+		t.Errorf("Unexpected result:\n%s", out)
+	}
+	if _, err := s.SemanticSearch(context.Background(), `{"query":" "}`); err == nil {
+		t.Error("Expected an empty query to be refused")
+	}
+}
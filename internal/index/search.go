@@ -0,0 +1,151 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/repomap"
+)
+
+// ToolName is the name of the tool that searches the index
+const ToolName = "semantic_search"
+
+// Limits on semantic_search results
+const (
+	defaultSearchResults = 5
+	maxSearchResults     = 20
+	maxResultBytes       = 12000
+)
+
+// Searcher searches the persisted index of a repository, loading it on
+// first use
+type Searcher struct {
+	Root     string
+	Embedder Embedder
+	Ignore   repomap.Ignore // Paths left out of results, in case the index predates them; nil leaves none out
+
+	mu    sync.Mutex
+	index *Index
+}
+
+// NewSearcher creates a searcher for the index of root
+func NewSearcher(root string, emb Embedder) *Searcher {
+	return &Searcher{Root: root, Embedder: emb}
+}
+
+// Search returns the k chunks most similar to the query
+func (s *Searcher) Search(ctx context.Context, query string, k int) ([]Result, error) {
+	s.mu.Lock()
+	if s.index == nil {
+		ix, err := Load(s.Root)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		if ix == nil {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("no semantic index in %s; build one with 'codex index build'", s.Root)
+		}
+		s.index = ix
+	}
+	ix := s.index
+	s.mu.Unlock()
+	results, err := ix.Search(ctx, s.Embedder, query, k)
+	if err != nil || s.Ignore == nil {
+		return results, err
+	}
+	kept := results[:0]
+	for _, r := range results {
+		if !s.Ignore(r.Path) {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
+
+// searchParams are the arguments of semantic_search
+type searchParams struct {
+	Query      string `json:"query"`
+	MaxResults int    `json:"max_results"`
+}
+
+// SemanticSearch implements the semantic_search tool
+func (s *Searcher) SemanticSearch(ctx context.Context, args string) (string, error) {
+	var params searchParams
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %w", err)
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return "", fmt.Errorf("query parameter is required")
+	}
+	if params.MaxResults <= 0 {
+		params.MaxResults = defaultSearchResults
+	}
+	if params.MaxResults > maxSearchResults {
+		params.MaxResults = maxSearchResults
+	}
+
+	results, err := s.Search(ctx, params.Query, params.MaxResults)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No indexed code matches %q", params.Query), nil
+	}
+	return FormatResults(results, maxResultBytes), nil
+}
+
+// FormatResults renders results as fenced snippets headed by their location,
+// stopping before maxBytes
+func FormatResults(results []Result, maxBytes int) string {
+	var sb strings.Builder
+	for i, r := range results {
+		var block strings.Builder
+		fmt.Fprintf(&block, "%s:%d-%d", r.Path, r.StartLine, r.EndLine)
+		if len(r.Symbols) > 0 {
+			fmt.Fprintf(&block, " (%s)", strings.Join(r.Symbols, ", "))
+		}
+		fmt.Fprintf(&block, " score %.2f\n```\n%s\n```\n", r.Score, r.Text)
+		if maxBytes > 0 && sb.Len()+block.Len() > maxBytes {
+			if i == 0 {
+				// Always show something, even if the first snippet is long
+				sb.WriteString(block.String()[:maxBytes] + "\n[...]\n")
+				i++
+			}
+			if rest := len(results) - i; rest > 0 {
+				fmt.Fprintf(&sb, "... (%d more results omitted)\n", rest)
+			}
+			break
+		}
+		sb.WriteString(block.String())
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Tool describes semantic_search to the model, run by s
+func (s *Searcher) Tool() functions.Tool {
+	return functions.Tool{
+		Name:        ToolName,
+		Source:      "semantic index",
+		Description: "Find code by meaning using the repository's embeddings index. Describe what the code does (e.g. \"where are API retries configured\"); use search_code instead for exact names or text.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "A natural-language description of the code to find",
+				},
+				"max_results": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of snippets to return (default %d, at most %d)", defaultSearchResults, maxSearchResults),
+				},
+			},
+			"required": []string{"query"},
+		},
+		Handler: s.SemanticSearch,
+	}
+}
@@ -0,0 +1,149 @@
+// Package inputhistory keeps the prompts sent from the chat input, across
+// sessions, so that they can be recalled with the arrow keys and found
+// again with reverse search.
+package inputhistory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the history file inside the codex config directory
+const FileName = "input_history"
+
+// DefaultMax is the number of prompts kept
+const DefaultMax = 1000
+
+// History is the prompts sent so far, oldest first. The file holds one JSON
+// string per line, so prompts spanning lines take one line each, and
+// sessions running at the same time append to it without clobbering each
+// other.
+type History struct {
+	path    string
+	entries []string
+	Max     int
+}
+
+// DefaultPath returns the default history file (~/.codex/input_history)
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".codex", FileName), nil
+}
+
+// Open reads the history kept at path. A missing file is an empty history;
+// lines that cannot be read are skipped.
+func Open(path string) (*History, error) {
+	h := &History{path: path, Max: DefaultMax}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && strings.TrimSpace(entry) != "" {
+			h.entries = append(h.entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input history: %w", err)
+	}
+	if len(h.entries) > h.Max {
+		h.entries = h.entries[len(h.entries)-h.Max:]
+	}
+	return h, nil
+}
+
+// Len returns the number of prompts in the history
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// At returns the i-th prompt, counting from the oldest
+func (h *History) At(i int) string {
+	return h.entries[i]
+}
+
+// Add appends a prompt to the history and its file. Blank prompts and
+// repeats of the latest one are not added. Once the file holds twice Max
+// prompts it is rewritten with the latest Max.
+func (h *History) Add(entry string) error {
+	if strings.TrimSpace(entry) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+	if h.path == "" {
+		return nil
+	}
+	if len(h.entries) >= 2*h.Max {
+		h.entries = h.entries[len(h.entries)-h.Max:]
+		return h.rewrite()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to create input history directory: %w", err)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode input history: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write input history: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write input history: %w", err)
+	}
+	return f.Close()
+}
+
+// rewrite replaces the file with the prompts in memory
+func (h *History) rewrite() error {
+	var sb strings.Builder
+	for _, entry := range h.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode input history: %w", err)
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write input history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("failed to write input history: %w", err)
+	}
+	return nil
+}
+
+// Search returns the index of the latest prompt before the one at index
+// before that contains query, ignoring case, or -1 if there is none
+func (h *History) Search(query string, before int) int {
+	if query == "" {
+		return -1
+	}
+	query = strings.ToLower(query)
+	for i := min(before, len(h.entries)) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(h.entries[i]), query) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,66 @@
+package inputhistory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	h, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for _, entry := range []string{"fix the build", "fix the build", "  ", "explain\nmain.go"} {
+		if err := h.Add(entry); err != nil {
+			t.Fatalf("Add(%q) error = %v", entry, err)
+		}
+	}
+
+	h, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if h.Len() != 2 || h.At(0) != "fix the build" || h.At(1) != "explain\nmain.go" {
+		t.Errorf("Expected the two distinct prompts, got %q", h.entries)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file to be private, got %v, %v", info, err)
+	}
+}
+
+func TestAddTrims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	h, _ := Open(path)
+	h.Max = 3
+	for _, entry := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := h.Add(entry); err != nil {
+			t.Fatalf("Add(%q) error = %v", entry, err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if got := strings.Count(string(data), "\n"); got != 3 {
+		t.Errorf("Expected the file rewritten with 3 prompts, got %d:\n%s", got, data)
+	}
+	if h.Len() != 3 || h.At(0) != "d" {
+		t.Errorf("Expected the latest 3 prompts, got %q", h.entries)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	h := &History{entries: []string{"run the tests", "Fix the build", "explain main.go", "fix lint"}}
+	if got := h.Search("fix", h.Len()); got != 3 {
+		t.Errorf("Search(fix) = %d, want 3", got)
+	}
+	if got := h.Search("fix", 3); got != 1 {
+		t.Errorf("Search(fix) before 3 = %d, want 1 (ignoring case)", got)
+	}
+	if got := h.Search("fix", 1); got != -1 {
+		t.Errorf("Search(fix) before 1 = %d, want -1", got)
+	}
+	if got := h.Search("", h.Len()); got != -1 {
+		t.Errorf("Search() with no query = %d, want -1", got)
+	}
+}
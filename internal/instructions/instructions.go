@@ -0,0 +1,146 @@
+// Package instructions finds the instruction files (AGENTS.md and codex.md)
+// that govern the files the agent touches. Teams put them next to the code
+// they are about; those of subdirectories reach the agent along with the
+// first result of a call touching a file below them, within a token budget.
+// Deeper files take precedence over those of the directories above them.
+package instructions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/epuerta/codex-go/internal/repomap"
+)
+
+// FileNames are the instruction files of a directory, in the order they are
+// given to the agent
+var FileNames = []string{"AGENTS.md", "codex.md"}
+
+// File is an instruction file
+type File struct {
+	Path    string // Absolute path
+	Content string
+}
+
+// Tracker hands out the instruction files governing paths, each only once
+type Tracker struct {
+	// Root is the repository root; files above it are not looked for
+	Root string
+	// MaxTokens is the approximate budget of all the files handed out; 0
+	// means no limit
+	MaxTokens int
+
+	mu      sync.Mutex
+	handled map[string]bool // Files handed out, left out or already sent
+	used    int             // Tokens handed out so far
+}
+
+// NewTracker returns a tracker of the instruction files below root
+func NewTracker(root string, maxTokens int) *Tracker {
+	return &Tracker{Root: root, MaxTokens: maxTokens, handled: make(map[string]bool)}
+}
+
+// MarkSent records files the agent already has, such as those of the
+// repository context, so that they are not handed out again
+func (t *Tracker) MarkSent(paths ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, path := range paths {
+		t.handled[filepath.Clean(path)] = true
+	}
+}
+
+// For returns the instruction files governing path, a file or a directory,
+// that were not handed out yet, from the outermost directory to the
+// innermost. Files that no longer fit in the budget are returned in omitted
+// instead; they are not offered again either.
+func (t *Tracker) For(path string) (files []File, omitted []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, dir := range t.dirs(path) {
+		for _, name := range FileNames {
+			file := filepath.Join(dir, name)
+			if t.handled[file] {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			t.handled[file] = true
+			tokens := repomap.EstimateTokens(string(data))
+			if t.MaxTokens > 0 && t.used+tokens > t.MaxTokens {
+				omitted = append(omitted, file)
+				continue
+			}
+			t.used += tokens
+			files = append(files, File{Path: file, Content: string(data)})
+		}
+	}
+	return files, omitted
+}
+
+// dirs returns the directories from the root down to path, or to its
+// directory if it is a file. It returns none for paths outside the root.
+func (t *Tracker) dirs(path string) []string {
+	dir := filepath.Clean(path)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	rel, err := filepath.Rel(t.Root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	dirs := []string{t.Root}
+	if rel == "." {
+		return dirs
+	}
+	current := t.Root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		dirs = append(dirs, current)
+	}
+	return dirs
+}
+
+// Render formats files and omitted for the agent, with paths relative to
+// the root. It returns "" if both are empty.
+func (t *Tracker) Render(files []File, omitted []string) string {
+	if len(files) == 0 && len(omitted) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	if len(files) > 0 {
+		b.WriteString("Instructions for the files touched, from the directories holding them. Those of deeper directories take precedence over those above them and over the repository context.\n")
+		for _, file := range files {
+			scope := t.rel(filepath.Dir(file.Path)) + "/"
+			if scope == "./" {
+				scope = "the whole repository"
+			}
+			fmt.Fprintf(&b, "\n--- %s (applies to %s) ---\n%s\n", t.rel(file.Path), scope, strings.TrimRight(file.Content, "\n"))
+		}
+	}
+	if len(omitted) > 0 {
+		if len(files) > 0 {
+			b.WriteString("\n")
+		}
+		names := make([]string, len(omitted))
+		for i, path := range omitted {
+			names[i] = t.rel(path)
+		}
+		fmt.Fprintf(&b, "Instruction files left out for lack of room: %s. Read them with read_file before changing files they govern.\n", strings.Join(names, ", "))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// rel returns path relative to the root, with forward slashes
+func (t *Tracker) rel(path string) string {
+	rel, err := filepath.Rel(t.Root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
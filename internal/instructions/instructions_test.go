@@ -0,0 +1,68 @@
+package instructions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrackerFor(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"AGENTS.md", "codex.md", "a/AGENTS.md", "a/b/codex.md", "a/b/c/main.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("rules of "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tracker := NewTracker(root, 0)
+
+	// From the root down, AGENTS.md before codex.md
+	files, omitted := tracker.For(filepath.Join(root, "a", "b", "c", "main.go"))
+	var got []string
+	for _, file := range files {
+		got = append(got, tracker.rel(file.Path))
+	}
+	if strings.Join(got, ",") != "AGENTS.md,codex.md,a/AGENTS.md,a/b/codex.md" || len(omitted) != 0 {
+		t.Errorf("Unexpected files: %v, omitted %v", got, omitted)
+	}
+	rendered := tracker.Render(files, nil)
+	for _, want := range []string{"deeper directories take precedence", "--- AGENTS.md (applies to the whole repository) ---\nrules of AGENTS.md", "--- a/b/codex.md (applies to a/b/) ---"} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("Expected %q in:\n%s", want, rendered)
+		}
+	}
+
+	// Each file is handed out once; directories count like files
+	if files, _ := tracker.For(filepath.Join(root, "a")); len(files) != 0 {
+		t.Errorf("Expected nothing new, got %v", files)
+	}
+	if files, _ := tracker.For(filepath.Dir(root)); len(files) != 0 {
+		t.Errorf("Expected nothing outside the root, got %v", files)
+	}
+	if tracker.Render(nil, nil) != "" {
+		t.Error("Expected nothing to render")
+	}
+}
+
+func TestTrackerBudget(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "AGENTS.md"), []byte(strings.Repeat("x", 400)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewTracker(root, 50)
+	files, omitted := tracker.For(filepath.Join(root, "main.go"))
+	if len(files) != 0 || len(omitted) != 1 {
+		t.Fatalf("Expected the file left out, got %v and %v", files, omitted)
+	}
+	if got := tracker.Render(files, omitted); got != "Instruction files left out for lack of room: AGENTS.md. Read them with read_file before changing files they govern." {
+		t.Errorf("Unexpected rendering: %q", got)
+	}
+	tracker.MarkSent(filepath.Join(root, "codex.md"))
+	if _, omitted := tracker.For(filepath.Join(root, "main.go")); len(omitted) != 0 {
+		t.Errorf("Expected a file left out once, got %v", omitted)
+	}
+}
@@ -0,0 +1,161 @@
+// Package memory keeps what the agent learned about a project, such as how
+// to run its tests or which logging library it uses, in .codex/memory.md at
+// the repository root. The file is added to the agent's context at the start
+// of each session. The agent adds to it with the remember tool, which always
+// asks the user first.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/functions"
+)
+
+// ToolName is the function the model calls to save a note
+const ToolName = "remember"
+
+// FileName is the memory file, relative to the repository root
+const FileName = ".codex/memory.md"
+
+// maxNoteBytes bounds one note; memory is for short conventions
+const maxNoteBytes = 300
+
+// header starts a new memory file
+const header = "# Project memory\n\nConventions learned in earlier codex sessions, one per line. Edit or remove them freely.\n\n"
+
+// Memory is the memory file of a repository
+type Memory struct {
+	Path string
+}
+
+// New returns the memory of the repository at root
+func New(root string) *Memory {
+	return &Memory{Path: filepath.Join(root, FileName)}
+}
+
+// Load returns the content of the file, or "" if there is none
+func (m *Memory) Load() (string, error) {
+	data, err := os.ReadFile(m.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// Notes returns the notes of the file: its bullet points
+func (m *Memory) Notes() ([]string, error) {
+	content, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	var notes []string
+	for _, line := range strings.Split(content, "\n") {
+		if note, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok && strings.TrimSpace(note) != "" {
+			notes = append(notes, strings.TrimSpace(note))
+		}
+	}
+	return notes, nil
+}
+
+// Normalize puts note on one line without a leading bullet, and checks that
+// it is neither empty nor too long
+func Normalize(note string) (string, error) {
+	note = strings.Join(strings.Fields(note), " ")
+	note = strings.TrimSpace(strings.TrimPrefix(note, "- "))
+	switch {
+	case note == "":
+		return "", errors.New("missing note argument")
+	case len(note) > maxNoteBytes:
+		return "", fmt.Errorf("the note is %d bytes; keep notes under %d bytes, one convention each", len(note), maxNoteBytes)
+	}
+	return note, nil
+}
+
+// Add appends note to the file as a bullet point, creating the file if
+// needed. It returns false, leaving the file alone, if the note is already
+// there.
+func (m *Memory) Add(note string) (bool, error) {
+	note, err := Normalize(note)
+	if err != nil {
+		return false, err
+	}
+	notes, err := m.Notes()
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range notes {
+		if strings.EqualFold(existing, note) {
+			return false, nil
+		}
+	}
+
+	content, err := m.Load()
+	if err != nil {
+		return false, err
+	}
+	if content == "" {
+		if err := os.MkdirAll(filepath.Dir(m.Path), 0755); err != nil {
+			return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(m.Path), err)
+		}
+		content = header
+	} else if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(m.Path, []byte(content+"- "+note+"\n"), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", m.Path, err)
+	}
+	return true, nil
+}
+
+// noteArgs are the arguments of remember
+type noteArgs struct {
+	Note string `json:"note"`
+}
+
+// Note returns the note of a remember call's arguments, or "" if there is none
+func Note(args string) string {
+	var params noteArgs
+	if err := json.Unmarshal([]byte(args), &params); err != nil {
+		return ""
+	}
+	return params.Note
+}
+
+// Tool describes remember, which saves a note to the memory file
+func (m *Memory) Tool() functions.Tool {
+	return functions.Tool{
+		Name:        ToolName,
+		Source:      "project memory",
+		Description: fmt.Sprintf("Save a durable project convention to %s, which is loaded into your context at the start of every future session in this repository. Use it when you learn something that would otherwise need repeating, especially after the user corrects you: how to build or test (\"tests run with make check\"), libraries to use (\"use zap for logging\"), style rules. One short convention per call; not for facts about the current task. The user confirms each note before it is saved.", FileName),
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"note": map[string]interface{}{
+					"type":        "string",
+					"description": "The convention, as one short sentence",
+				},
+			},
+			"required": []string{"note"},
+		},
+		Handler: func(ctx context.Context, args string) (string, error) {
+			var params noteArgs
+			if err := json.Unmarshal([]byte(args), &params); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			added, err := m.Add(params.Note)
+			if err != nil {
+				return "", err
+			}
+			if !added {
+				return fmt.Sprintf("%s already has this note; nothing was added.", FileName), nil
+			}
+			return fmt.Sprintf("Saved to %s; it will be loaded in future sessions.", FileName), nil
+		},
+	}
+}
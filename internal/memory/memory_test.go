@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	m := New(t.TempDir())
+	if content, err := m.Load(); err != nil || content != "" {
+		t.Fatalf("Expected no memory yet, got %q, %v", content, err)
+	}
+
+	for _, note := range []string{"Tests run with\n  make check", "- Use zap for logging"} {
+		if added, err := m.Add(note); err != nil || !added {
+			t.Fatalf("Add(%q) = %t, %v", note, added, err)
+		}
+	}
+	if added, err := m.Add("tests run with make check"); err != nil || added {
+		t.Errorf("Expected a duplicate note to be left out, got %t, %v", added, err)
+	}
+	notes, err := m.Notes()
+	if want := []string{"Tests run with make check", "Use zap for logging"}; err != nil || !reflect.DeepEqual(notes, want) {
+		t.Errorf("Expected notes %q, got %q (%v)", want, notes, err)
+	}
+	content, _ := m.Load()
+	if !strings.HasPrefix(content, "# Project memory\n") || !strings.HasSuffix(content, "\n- Use zap for logging\n") {
+		t.Errorf("Unexpected file:\n%s", content)
+	}
+
+	// Notes the user wrote without a final newline are kept
+	os.WriteFile(m.Path, []byte("- Prefer table tests"), 0644)
+	m.Add("Wrap errors with %w")
+	if content, _ := m.Load(); content != "- Prefer table tests\n- Wrap errors with %w\n" {
+		t.Errorf("Unexpected file:\n%s", content)
+	}
+
+	for _, note := range []string{"  ", strings.Repeat("x", maxNoteBytes+1)} {
+		if _, err := m.Add(note); err == nil {
+			t.Errorf("Expected %q to be refused", note)
+		}
+	}
+}
+
+func TestTool(t *testing.T) {
+	m := New(t.TempDir())
+	tool := m.Tool()
+	out, err := tool.Handler(context.Background(), `{"note":"Run go vet before committing"}`)
+	if err != nil || !strings.Contains(out, "Saved to .codex/memory.md") {
+		t.Fatalf("Unexpected result %q, %v", out, err)
+	}
+	if out, _ = tool.Handler(context.Background(), `{"note":"run go vet before committing"}`); !strings.Contains(out, "already has this note") {
+		t.Errorf("Expected the duplicate to be reported, got %q", out)
+	}
+	if _, err := tool.Handler(context.Background(), `{}`); err == nil {
+		t.Error("Expected a missing note to fail")
+	}
+	if Note(`{"note":"x"}`) != "x" || Note(`not json`) != "" {
+		t.Error("Unexpected Note")
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import "strings"
+
+// Capabilities describes what a model can do
+type Capabilities struct {
+	ContextWindow int  // Tokens of input the model accepts
+	Tools         bool // Supports function calling, which the agent needs
+	Vision        bool // Accepts images
+}
+
+// capabilities lists known models, matched by longest prefix like the prices
+// in the stats package
+var capabilities = map[string]Capabilities{
+	"gpt-4o-mini":        {ContextWindow: 128_000, Tools: true, Vision: true},
+	"gpt-4o":             {ContextWindow: 128_000, Tools: true, Vision: true},
+	"gpt-4-turbo":        {ContextWindow: 128_000, Tools: true, Vision: true},
+	"gpt-4.1":            {ContextWindow: 1_047_576, Tools: true, Vision: true},
+	"gpt-4":              {ContextWindow: 8_192, Tools: true},
+	"gpt-3.5-turbo":      {ContextWindow: 16_385, Tools: true},
+	"o4-mini":            {ContextWindow: 200_000, Tools: true, Vision: true},
+	"o3-mini":            {ContextWindow: 200_000, Tools: true},
+	"o3":                 {ContextWindow: 200_000, Tools: true, Vision: true},
+	"o1-mini":            {ContextWindow: 128_000},
+	"o1":                 {ContextWindow: 200_000, Tools: true, Vision: true},
+	"gemini-1.5":         {ContextWindow: 1_048_576, Tools: true, Vision: true},
+	"gemini-2":           {ContextWindow: 1_048_576, Tools: true, Vision: true},
+	"mistral-large":      {ContextWindow: 131_072, Tools: true},
+	"mistral-small":      {ContextWindow: 32_768, Tools: true},
+	"codestral":          {ContextWindow: 256_000, Tools: true},
+	"pixtral":            {ContextWindow: 131_072, Tools: true, Vision: true},
+	"qwen2.5-coder":      {ContextWindow: 32_768, Tools: true},
+	"llama3.1":           {ContextWindow: 131_072, Tools: true},
+	"llama3.2-vision":    {ContextWindow: 131_072, Vision: true},
+	"deepseek-coder-v2":  {ContextWindow: 163_840},
+	"text-embedding-3":   {ContextWindow: 8_191},
+	"text-embedding-ada": {ContextWindow: 8_191},
+}
+
+// Lookup returns the capabilities of model. ok is false if the model is
+// unknown. Provider prefixes such as "models/" are ignored.
+func Lookup(model string) (caps Capabilities, ok bool) {
+	model = strings.ToLower(model)
+	model = model[strings.LastIndex(model, "/")+1:]
+	best := ""
+	for prefix, c := range capabilities {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+			caps = c
+		}
+	}
+	return caps, best != ""
+}
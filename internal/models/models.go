@@ -0,0 +1,180 @@
+// Package models lists the models the configured provider serves. Listings
+// are cached per base URL so that checking a model name needs no request
+// most of the time, and an old listing is used when the provider is offline.
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/provider"
+	"github.com/sashabaranov/go-openai"
+)
+
+// DirName is the name of the listings cache inside the codex config directory
+const DirName = "models"
+
+// DefaultMaxAge is how long a cached listing is used without asking the provider
+const DefaultMaxAge = 24 * time.Hour
+
+// Model is a model the provider serves
+type Model struct {
+	ID      string `json:"id"`
+	OwnedBy string `json:"owned_by,omitempty"`
+}
+
+// Fetcher asks a provider for its models
+type Fetcher interface {
+	FetchModels(ctx context.Context) ([]Model, error)
+}
+
+// OpenAIFetcher lists models with the OpenAI-compatible /models endpoint
+type OpenAIFetcher struct {
+	client *openai.Client
+}
+
+// NewOpenAIFetcher creates a fetcher using the API key and base URL of cfg
+func NewOpenAIFetcher(cfg *config.Config) *OpenAIFetcher {
+	return &OpenAIFetcher{client: provider.NewClient(provider.ChatOptions(cfg))}
+}
+
+// FetchModels returns the provider's models
+func (f *OpenAIFetcher) FetchModels(ctx context.Context) ([]Model, error) {
+	list, err := f.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]Model, len(list.Models))
+	for i, m := range list.Models {
+		models[i] = Model{ID: m.ID, OwnedBy: m.OwnedBy}
+	}
+	return models, nil
+}
+
+// Listing is the models of a provider at some point in time
+type Listing struct {
+	BaseURL   string    `json:"base_url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Models    []Model   `json:"models"`
+
+	// Stale is set when the provider could not be reached and an older
+	// cached listing was returned instead
+	Stale bool `json:"-"`
+}
+
+// Has reports whether the listing includes the model id
+func (l *Listing) Has(id string) bool {
+	for _, m := range l.Models {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// IDs returns the model ids, sorted
+func (l *Listing) IDs() []string {
+	ids := make([]string, len(l.Models))
+	for i, m := range l.Models {
+		ids[i] = m.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Cache keeps the listing of each provider as one JSON file
+type Cache struct {
+	dir    string
+	MaxAge time.Duration
+}
+
+// NewCache creates a cache backed by the given directory
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir, MaxAge: DefaultMaxAge}
+}
+
+// DefaultDir returns the default cache directory (~/.codex/models)
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, config.DefaultConfigDir, DirName), nil
+}
+
+// path returns the file holding the listing of baseURL
+func (c *Cache) path(baseURL string) string {
+	sum := sha256.Sum256([]byte(baseURL))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Load returns the cached listing of baseURL, or nil if there is none
+func (c *Cache) Load(baseURL string) (*Listing, error) {
+	data, err := os.ReadFile(c.path(baseURL))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model listing: %w", err)
+	}
+	var l Listing
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("failed to parse model listing: %w", err)
+	}
+	return &l, nil
+}
+
+// Save stores the listing
+func (c *Cache) Save(l *Listing) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create models directory: %w", err)
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode model listing: %w", err)
+	}
+	tmp := c.path(l.BaseURL) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write model listing: %w", err)
+	}
+	return os.Rename(tmp, c.path(l.BaseURL))
+}
+
+// List returns the models served at baseURL. A cached listing younger than
+// MaxAge is used unless refresh is set; otherwise the provider is asked and
+// the cache updated. When the provider cannot be reached, the cached listing
+// is returned as Stale, however old; without one the error is returned. A
+// listing that cannot be cached is returned along with the error.
+func (c *Cache) List(ctx context.Context, baseURL string, f Fetcher, refresh bool) (*Listing, error) {
+	cached, err := c.Load(baseURL)
+	if err != nil {
+		cached = nil // Replaced by a fresh listing
+	}
+	if cached != nil && !refresh && time.Since(cached.FetchedAt) < c.MaxAge {
+		return cached, nil
+	}
+
+	models, err := f.FetchModels(ctx)
+	if err != nil {
+		if cached != nil {
+			cached.Stale = true
+			return cached, nil
+		}
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	l := &Listing{BaseURL: baseURL, FetchedAt: time.Now(), Models: models}
+	sort.Slice(l.Models, func(i, j int) bool { return l.Models[i].ID < l.Models[j].ID })
+	if err := c.Save(l); err != nil {
+		return l, err
+	}
+	return l, nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeFetcher returns fixed models, or err, and counts the requests
+type fakeFetcher struct {
+	models []Model
+	err    error
+	calls  int
+}
+
+func (f *fakeFetcher) FetchModels(ctx context.Context) ([]Model, error) {
+	f.calls++
+	return f.models, f.err
+}
+
+func TestCacheList(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	const baseURL = "https://api.example/v1"
+	f := &fakeFetcher{models: []Model{{ID: "gpt-4o"}, {ID: "gpt-4o-mini"}}}
+
+	l, err := cache.List(context.Background(), baseURL, f, false)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !l.Has("gpt-4o-mini") || l.Stale || f.calls != 1 {
+		t.Errorf("Unexpected first listing %+v after %d calls", l, f.calls)
+	}
+
+	// A fresh cached listing needs no request, unless a refresh is asked for
+	if _, err := cache.List(context.Background(), baseURL, f, false); err != nil || f.calls != 1 {
+		t.Errorf("Expected the cached listing, got err=%v after %d calls", err, f.calls)
+	}
+	if _, err := cache.List(context.Background(), baseURL, f, true); err != nil || f.calls != 2 {
+		t.Errorf("Expected a refresh, got err=%v after %d calls", err, f.calls)
+	}
+
+	// Offline: an old listing is still returned, marked stale
+	cache.MaxAge = time.Nanosecond
+	f.err = errors.New("connection refused")
+	l, err = cache.List(context.Background(), baseURL, f, false)
+	if err != nil || !l.Stale || !l.Has("gpt-4o") {
+		t.Errorf("Expected the stale listing, got %+v, %v", l, err)
+	}
+
+	// Without a cached listing the error is returned
+	if _, err := cache.List(context.Background(), "https://other.example/v1", f, false); err == nil {
+		t.Error("Expected an error without a cached listing")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if caps, ok := Lookup("gpt-4o-mini-2024-07-18"); !ok || caps.ContextWindow != 128_000 || !caps.Tools || !caps.Vision {
+		t.Errorf("Unexpected capabilities for gpt-4o-mini: %+v, %t", caps, ok)
+	}
+	if caps, ok := Lookup("models/gemini-2.0-flash"); !ok || !caps.Tools {
+		t.Errorf("Expected the provider prefix to be ignored, got %+v, %t", caps, ok)
+	}
+	if _, ok := Lookup("my-finetune"); ok {
+		t.Error("Expected an unknown model")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	ids := []string{"gpt-4o", "gpt-4o-mini", "gpt-4.1", "o3-mini", "text-embedding-3-small"}
+	tests := map[string][]string{
+		"gpt4o":       {"gpt-4o"},
+		"gpt-4o-mnii": {"gpt-4o-mini"},
+		"4o":          {"gpt-4o", "gpt-4o-mini"},
+		"o3mini":      {"o3-mini"},
+		"claude":      nil,
+	}
+	for name, want := range tests {
+		if got := Suggest(name, ids); !reflect.DeepEqual(got, want) {
+			t.Errorf("Suggest(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the number of names Suggest returns at most
+const maxSuggestions = 3
+
+// Suggest returns the ids closest to name, best first, for a "did you mean"
+// hint. Ids that contain name, or are within a few edits of it, qualify.
+func Suggest(name string, ids []string) []string {
+	name = strings.ToLower(name)
+	limit := max(2, len(name)/3)
+
+	type candidate struct {
+		id       string
+		distance int
+	}
+	var candidates []candidate
+	for _, id := range ids {
+		lower := strings.ToLower(id)
+		d := editDistance(name, lower)
+		if strings.Contains(lower, name) {
+			d = min(d, 1) // What was typed is part of the name
+		}
+		if d <= limit {
+			candidates = append(candidates, candidate{id, d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].id < candidates[j].id
+	})
+
+	var out []string
+	for _, c := range candidates {
+		if len(out) == maxSuggestions {
+			break
+		}
+		out = append(out, c.id)
+	}
+	return out
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
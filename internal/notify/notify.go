@@ -0,0 +1,56 @@
+// Package notify tells the user that the session needs them while they are
+// in another window: with the terminal bell and, when enabled, a desktop
+// notification.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Bell is the control character ringing the terminal bell
+const Bell = "\a"
+
+// RingBell rings the bell of the terminal w writes to
+func RingBell(w io.Writer) error {
+	_, err := io.WriteString(w, Bell)
+	return err
+}
+
+// Desktop shows a desktop notification. It shells out to the platform tool:
+// osascript on macOS and notify-send on Linux and the BSDs.
+func Desktop(ctx context.Context, title, body string) error {
+	cmd, err := desktopCommand(ctx, runtime.GOOS, title, body)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		return fmt.Errorf("%s is required for desktop notifications", cmd.Args[0])
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// desktopCommand returns the command showing a notification on goos
+func desktopCommand(ctx context.Context, goos, title, body string) (*exec.Cmd, error) {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return exec.CommandContext(ctx, "osascript", "-e", script), nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return exec.CommandContext(ctx, "notify-send", "--app-name=codex-go", title, body), nil
+	default:
+		return nil, fmt.Errorf("desktop notifications are not supported on %s", goos)
+	}
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRingBell(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RingBell(&buf); err != nil || buf.String() != "\a" {
+		t.Errorf("Expected the bell, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestDesktopCommand(t *testing.T) {
+	ctx := context.Background()
+	cmd, err := desktopCommand(ctx, "darwin", "codex-go", `Approve "rm -rf build"?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(cmd.Args, " "), `osascript -e display notification "Approve \"rm -rf build\"?" with title "codex-go"`; got != want {
+		t.Errorf("Unexpected command:\n got %s\nwant %s", got, want)
+	}
+
+	cmd, err = desktopCommand(ctx, "linux", "codex-go", "The assistant is done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(cmd.Args, " "), "notify-send --app-name=codex-go codex-go The assistant is done"; got != want {
+		t.Errorf("Unexpected command:\n got %s\nwant %s", got, want)
+	}
+
+	if _, err := desktopCommand(ctx, "plan9", "codex-go", "done"); err == nil {
+		t.Error("Expected an error for an unsupported platform")
+	}
+}
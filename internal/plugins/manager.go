@@ -0,0 +1,94 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/logging"
+)
+
+// Manager runs the plugins of a session. It implements engine.Notifier to
+// forward tool events to the plugins subscribed to them.
+type Manager struct {
+	engine.NopNotifier
+
+	Plugins []*Plugin
+}
+
+// Load starts the plugins discovered in pluginDir, running them in dir.
+// Plugins failing to start are reported in the returned warnings and left out.
+func Load(pluginDir, dir string, logger logging.Logger) (*Manager, []error) {
+	paths, err := Discover(pluginDir)
+	if err != nil {
+		return &Manager{}, []error{err}
+	}
+
+	m := &Manager{}
+	var warnings []error
+	for _, path := range paths {
+		p, err := Start(path, dir, logger)
+		if err != nil {
+			warnings = append(warnings, err)
+			continue
+		}
+		m.Plugins = append(m.Plugins, p)
+	}
+	return m, warnings
+}
+
+// Register adds the plugins' tools to the registry. A plugin cannot replace
+// a tool that is already registered; such tools are reported in the returned
+// warnings and skipped.
+func (m *Manager) Register(r *functions.Registry) []error {
+	var warnings []error
+	for _, p := range m.Plugins {
+		for _, tool := range p.Tools() {
+			if _, ok := r.Lookup(tool.Name); ok {
+				warnings = append(warnings, fmt.Errorf("plugin %s: tool %s is already registered", p.Name(), tool.Name))
+				continue
+			}
+			r.RegisterTool(tool)
+		}
+	}
+	return warnings
+}
+
+// Close stops every plugin
+func (m *Manager) Close() error {
+	var firstErr error
+	for _, p := range m.Plugins {
+		if err := p.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("plugin %s: %w", p.Name(), err)
+		}
+	}
+	return firstErr
+}
+
+// notify sends event to the plugins subscribed to it
+func (m *Manager) notify(event Event) {
+	for _, p := range m.Plugins {
+		if p.Subscribed(event.Type) {
+			p.Notify(event)
+		}
+	}
+}
+
+func (m *Manager) OnToolCall(call agent.FunctionCall) {
+	m.notify(NewEvent(EventToolCall, call))
+}
+
+func (m *Manager) OnToolResult(call agent.FunctionCall, res *executor.Result) {
+	event := NewEvent(EventToolResult, call)
+	event.Output = res.Output
+	event.Success = res.Success
+	m.notify(event)
+}
+
+func (m *Manager) OnToolDenied(call agent.FunctionCall, reason string) {
+	event := NewEvent(EventToolDenied, call)
+	event.Reason = reason
+	m.notify(event)
+}
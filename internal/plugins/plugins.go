@@ -0,0 +1,407 @@
+// Package plugins runs the executables in ~/.codex/plugins, which extend the
+// agent with tools and observe its tool calls.
+//
+// A plugin speaks JSON-RPC style messages over stdin and stdout, one JSON
+// object per line. Codex first sends
+//
+//	{"id":1,"method":"initialize","params":{"protocol_version":1,"cwd":"/path/to/repo"}}
+//
+// and the plugin describes itself in its reply:
+//
+//	{"id":1,"result":{"name":"jira","tools":[{"name":"jira_issue","description":"...","parameters":{...}}],"events":["tool_result"]}}
+//
+// Tools are then called with
+//
+//	{"id":2,"method":"call_tool","params":{"name":"jira_issue","arguments":{"key":"ABC-1"}}}
+//
+// which the plugin answers with {"id":2,"result":{"output":"..."}} or
+// {"id":2,"error":{"message":"..."}}. Replies may come in any order.
+//
+// The events a plugin subscribes to ("tool_call", "tool_result" and
+// "tool_denied") arrive as notifications, which have no ID and get no reply:
+//
+//	{"method":"event","params":{"type":"tool_result","call":{...},"output":"...","success":true}}
+//
+// When the session ends Codex closes the plugin's stdin and, if it has not
+// exited shortly after, kills it. Anything the plugin writes to stderr is
+// logged.
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/logging"
+)
+
+// DirName is the directory of ~/.codex holding plugins
+const DirName = "plugins"
+
+// ProtocolVersion is the version of the protocol sent to plugins
+const ProtocolVersion = 1
+
+// Event types plugins can subscribe to
+const (
+	EventToolCall   = "tool_call"
+	EventToolResult = "tool_result"
+	EventToolDenied = "tool_denied"
+)
+
+var (
+	// initTimeout bounds how long a plugin may take to describe itself
+	initTimeout = 5 * time.Second
+	// exitTimeout is how long a plugin has to exit once its stdin is closed
+	exitTimeout = 2 * time.Second
+)
+
+// eventQueueSize is the number of events buffered for a plugin reading them
+// slowly; further events are dropped
+const eventQueueSize = 64
+
+// DefaultDir returns the directory plugins are discovered in
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".codex", DirName), nil
+}
+
+// Discover returns the executable files in dir, sorted by name. A missing
+// directory has no plugins.
+func Discover(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path) // Follows links, so linked plugins work
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ToolSpec describes a tool provided by a plugin
+type ToolSpec struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// Manifest is a plugin's reply to initialize
+type Manifest struct {
+	Name   string     `json:"name"`
+	Tools  []ToolSpec `json:"tools"`
+	Events []string   `json:"events"`
+}
+
+// Call describes a function call in events
+type Call struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON string of arguments
+}
+
+// Event is sent to plugins subscribed to its type
+type Event struct {
+	Type    string `json:"type"`
+	Call    Call   `json:"call"`
+	Output  string `json:"output,omitempty"`  // For tool_result
+	Success bool   `json:"success,omitempty"` // For tool_result
+	Reason  string `json:"reason,omitempty"`  // For tool_denied
+}
+
+// NewEvent creates an event about call
+func NewEvent(eventType string, call agent.FunctionCall) Event {
+	return Event{Type: eventType, Call: Call{ID: call.ID, Name: call.Name, Arguments: call.Arguments}}
+}
+
+// message is a request, reply or notification
+type message struct {
+	ID     int             `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params interface{}     `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Plugin is a running plugin process
+type Plugin struct {
+	Path     string
+	Manifest Manifest
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	logger logging.Logger
+
+	writeMu sync.Mutex // Serializes writes to stdin
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan message // Replies awaited, by request ID
+	exited  chan struct{}        // Closed when the plugin's stdout ends
+	events  chan Event
+	closed  bool
+}
+
+// Start runs the plugin at path in dir and asks it to describe itself
+func Start(path, dir string, logger logging.Logger) (*Plugin, error) {
+	if logger == nil {
+		logger = logging.NewNilLogger()
+	}
+	cmd := exec.Command(path)
+	cmd.Dir = dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", path, err)
+	}
+
+	p := &Plugin{
+		Path:    path,
+		cmd:     cmd,
+		stdin:   stdin,
+		logger:  logger,
+		pending: make(map[int]chan message),
+		exited:  make(chan struct{}),
+		events:  make(chan Event, eventQueueSize),
+	}
+	go p.readReplies(stdout)
+	go p.logStderr(stderr)
+	go p.sendEvents()
+
+	ctx, cancel := context.WithTimeout(context.Background(), initTimeout)
+	defer cancel()
+	params := map[string]interface{}{"protocol_version": ProtocolVersion, "cwd": dir}
+	if err := p.call(ctx, "initialize", params, &p.Manifest); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s failed to initialize: %w", path, err)
+	}
+	if p.Manifest.Name == "" {
+		p.Manifest.Name = filepath.Base(path)
+	}
+	return p, nil
+}
+
+// Name returns the name the plugin gave itself
+func (p *Plugin) Name() string {
+	return p.Manifest.Name
+}
+
+// Tools returns the plugin's tools, whose handlers call the plugin
+func (p *Plugin) Tools() []functions.Tool {
+	tools := make([]functions.Tool, 0, len(p.Manifest.Tools))
+	for _, spec := range p.Manifest.Tools {
+		name := spec.Name
+		params := spec.Parameters
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		tools = append(tools, functions.Tool{
+			Name:        name,
+			Description: spec.Description,
+			Parameters:  params,
+			Handler: func(ctx context.Context, args string) (string, error) {
+				return p.CallTool(ctx, name, args)
+			},
+			Source: "plugin " + p.Name(),
+		})
+	}
+	return tools
+}
+
+// CallTool runs one of the plugin's tools with JSON arguments
+func (p *Plugin) CallTool(ctx context.Context, name, args string) (string, error) {
+	if args == "" {
+		args = "{}"
+	}
+	if !json.Valid([]byte(args)) {
+		return "", fmt.Errorf("invalid JSON arguments for %s", name)
+	}
+	params := map[string]interface{}{"name": name, "arguments": json.RawMessage(args)}
+	var result struct {
+		Output string `json:"output"`
+	}
+	if err := p.call(ctx, "call_tool", params, &result); err != nil {
+		return "", err
+	}
+	return result.Output, nil
+}
+
+// Subscribed reports whether the plugin wants events of the given type
+func (p *Plugin) Subscribed(eventType string) bool {
+	for _, t := range p.Manifest.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify queues an event for the plugin without waiting for it to be read.
+// Events are dropped while the queue is full.
+func (p *Plugin) Notify(event Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.events <- event:
+	default:
+		p.logger.Log("Warning: plugin %s is not reading events; dropped %s", p.Name(), event.Type)
+	}
+}
+
+// Close stops the plugin, killing it if it does not exit soon after its
+// stdin is closed
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.events) // Ends sendEvents, which closes stdin
+	p.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(exitTimeout):
+		p.logger.Log("Plugin %s did not exit; killing it", p.Name())
+		p.cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// call sends a request and decodes the reply's result into result
+func (p *Plugin) call(ctx context.Context, method string, params, result interface{}) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errors.New("plugin is closed")
+	}
+	p.nextID++
+	id := p.nextID
+	reply := make(chan message, 1)
+	p.pending[id] = reply
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+	}()
+
+	if err := p.write(message{ID: id, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-p.exited:
+		return errors.New("plugin exited")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// write sends a message as a line of JSON
+func (p *Plugin) write(msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	if _, err := p.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to plugin: %w", err)
+	}
+	return nil
+}
+
+// readReplies routes the replies on stdout to the requests awaiting them
+func (p *Plugin) readReplies(stdout io.Reader) {
+	defer close(p.exited)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.ID == 0 {
+			p.logger.Log("Plugin %s: ignoring output line: %s", p.Path, scanner.Text())
+			continue
+		}
+		p.mu.Lock()
+		reply, ok := p.pending[msg.ID]
+		p.mu.Unlock()
+		if ok {
+			reply <- msg
+		}
+	}
+}
+
+// logStderr logs what the plugin writes to stderr
+func (p *Plugin) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		p.logger.Log("Plugin %s: %s", p.Path, scanner.Text())
+	}
+}
+
+// sendEvents writes queued events to the plugin, then closes its stdin once
+// the plugin is closed
+func (p *Plugin) sendEvents() {
+	defer p.stdin.Close()
+	for event := range p.events {
+		if err := p.write(message{Method: "event", Params: event}); err != nil {
+			p.logger.Log("Warning: failed to send %s event to plugin %s: %v", event.Type, p.Name(), err)
+		}
+	}
+}
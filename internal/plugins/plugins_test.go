@@ -0,0 +1,160 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+)
+
+// TestHelperPlugin is not a test: it is the plugin run by the other tests,
+// which provides an echo tool and appends tool_result events to the file
+// named by CODEX_TEST_PLUGIN_EVENTS
+func TestHelperPlugin(t *testing.T) {
+	if os.Getenv("CODEX_TEST_PLUGIN") != "1" {
+		return
+	}
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(scanner.Bytes(), &msg)
+		switch msg.Method {
+		case "initialize":
+			fmt.Fprintln(os.Stderr, "initializing")
+			out.Encode(map[string]interface{}{"id": msg.ID, "result": map[string]interface{}{
+				"name":   "helper",
+				"tools":  []map[string]interface{}{{"name": "echo", "description": "Echoes its arguments"}, {"name": "read_file"}},
+				"events": []string{"tool_result"},
+			}})
+		case "call_tool":
+			var params struct {
+				Name      string          `json:"name"`
+				Arguments json.RawMessage `json:"arguments"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			if strings.Contains(string(params.Arguments), "fail") {
+				out.Encode(map[string]interface{}{"id": msg.ID, "error": map[string]string{"message": "asked to fail"}})
+				continue
+			}
+			out.Encode(map[string]interface{}{"id": msg.ID, "result": map[string]string{"output": "echo " + string(params.Arguments)}})
+		case "event":
+			f, _ := os.OpenFile(os.Getenv("CODEX_TEST_PLUGIN_EVENTS"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			f.Write(append(msg.Params, '\n'))
+			f.Close()
+		}
+	}
+	os.Exit(0)
+}
+
+// installHelperPlugin installs a plugin in a new directory that runs
+// TestHelperPlugin, and returns the directory and the plugin's events file
+func installHelperPlugin(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	script := fmt.Sprintf("#!/bin/sh\nCODEX_TEST_PLUGIN=1 CODEX_TEST_PLUGIN_EVENTS=%q exec %q -test.run=TestHelperPlugin\n", events, os.Args[0])
+	if err := os.WriteFile(filepath.Join(dir, "helper"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Files that are not executable are not plugins
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, events
+}
+
+func TestDiscover(t *testing.T) {
+	dir, _ := installHelperPlugin(t)
+	paths, err := Discover(dir)
+	if err != nil || len(paths) != 1 || filepath.Base(paths[0]) != "helper" {
+		t.Errorf("Discover = %v, %v; want the helper plugin only", paths, err)
+	}
+	if paths, err := Discover(filepath.Join(dir, "missing")); paths != nil || err != nil {
+		t.Errorf("Discover of a missing directory = %v, %v", paths, err)
+	}
+}
+
+func TestManager(t *testing.T) {
+	dir, events := installHelperPlugin(t)
+	m, warnings := Load(dir, t.TempDir(), nil)
+	if len(warnings) != 0 || len(m.Plugins) != 1 {
+		t.Fatalf("Load = %d plugins, warnings %v", len(m.Plugins), warnings)
+	}
+	defer m.Close()
+	if name := m.Plugins[0].Name(); name != "helper" {
+		t.Errorf("Name = %q", name)
+	}
+
+	registry := functions.NewRegistry()
+	registry.Register("read_file", func(string) (string, error) { return "", nil })
+	warnings = m.Register(registry)
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "read_file is already registered") {
+		t.Errorf("Expected a warning about read_file, got %v", warnings)
+	}
+	defs := registry.ToolDefinitions()
+	if len(defs) != 1 || defs[0].Function.Name != "echo" || defs[0].Function.Parameters == nil {
+		t.Fatalf("Expected the echo tool to be advertised with a schema, got %+v", defs)
+	}
+
+	echo := registry.GetContext("echo")
+	ctx := context.Background()
+	if out, err := echo(ctx, `{"text":"hi"}`); err != nil || out != `echo {"text":"hi"}` {
+		t.Errorf("echo = %q, %v", out, err)
+	}
+	if _, err := echo(ctx, `{"text":"fail"}`); err == nil || err.Error() != "asked to fail" {
+		t.Errorf("Expected the plugin's error, got %v", err)
+	}
+	if _, err := echo(ctx, `{"text":`); err == nil {
+		t.Error("Expected invalid arguments to be refused")
+	}
+
+	// Only subscribed events are sent
+	call := agent.FunctionCall{ID: "call_1", Name: "echo", Arguments: `{}`}
+	m.OnToolCall(call)
+	m.OnToolResult(call, &executor.Result{Output: "echo {}", Success: true})
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	data, err := os.ReadFile(events)
+	if err != nil {
+		t.Fatalf("Expected the plugin to record events: %v", err)
+	}
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil || event.Type != EventToolResult || event.Call.ID != "call_1" || !event.Success {
+		t.Errorf("Unexpected events %s (%v)", data, err)
+	}
+
+	if _, err := echo(ctx, `{}`); err == nil {
+		t.Error("Expected calls to a closed plugin to fail")
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	m, warnings := Load(dir, dir, nil)
+	if len(m.Plugins) != 0 || len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "failed to initialize") {
+		t.Errorf("Expected the broken plugin to be reported, got %d plugins and %v", len(m.Plugins), warnings)
+	}
+	if time.Since(start) >= initTimeout {
+		t.Error("Expected a plugin that exits to fail without waiting for the timeout")
+	}
+}
@@ -0,0 +1,217 @@
+// Package prompts loads the prompt templates in ~/.codex/prompts. A template
+// is a markdown file whose name, without the .md extension, is the name of
+// the prompt. Placeholders in double braces are filled in before the prompt
+// is sent:
+//
+//	{{VERSION}}   is replaced by the value of the variable VERSION
+//	{{git diff}}  is replaced by the output of the shell command "git diff"
+//
+// A placeholder is a variable when it is a single upper-case identifier, and
+// a shell command otherwise, so {{date}} runs date. The first non-empty line
+// of a template describes it in listings.
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/sandbox"
+)
+
+// DirName is the directory of the templates, under ~/.codex
+const DirName = "prompts"
+
+// Ext is the extension of template files
+const Ext = ".md"
+
+// ArgsVar holds the arguments that are not KEY=VALUE pairs
+const ArgsVar = "ARGS"
+
+// maxOutputBytes bounds the output a shell snippet adds to a prompt
+const maxOutputBytes = 64 * 1024
+
+var (
+	placeholderRe = regexp.MustCompile(`\{\{(.*?)\}\}`)
+	variableRe    = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+)
+
+// Template is a prompt template
+type Template struct {
+	Name        string
+	Path        string
+	Description string
+	Body        string
+}
+
+// DefaultDir returns ~/.codex/prompts
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".codex", DirName), nil
+}
+
+// List returns the templates in dir, sorted by name. A missing directory has
+// no templates.
+func List(dir string) ([]Template, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var templates []Template
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != Ext {
+			continue
+		}
+		t, err := Load(dir, strings.TrimSuffix(e.Name(), Ext))
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// Load reads the template called name in dir
+func Load(dir, name string) (Template, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return Template{}, fmt.Errorf("invalid prompt name %q", name)
+	}
+	path := filepath.Join(dir, name+Ext)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Template{}, fmt.Errorf("no prompt named %q in %s", name, dir)
+	}
+	if err != nil {
+		return Template{}, fmt.Errorf("failed to read prompt %q: %w", name, err)
+	}
+	body := string(data)
+	return Template{Name: name, Path: path, Description: describe(body), Body: body}, nil
+}
+
+// describe returns the first non-empty line of body, without a heading marker
+func describe(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "# ")); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// ParseArgs turns command-line arguments into variables: KEY=VALUE pairs with
+// an upper-case KEY set it, and the other arguments are joined into ARGS
+func ParseArgs(args []string) map[string]string {
+	vars := map[string]string{}
+	var rest []string
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok && variableRe.MatchString(key) {
+			vars[key] = value
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	if len(rest) > 0 {
+		vars[ArgsVar] = strings.Join(rest, " ")
+	}
+	return vars
+}
+
+// Runner runs a shell snippet in dir and returns its output
+type Runner func(ctx context.Context, dir, command string) (string, error)
+
+// Expander fills in the placeholders of templates
+type Expander struct {
+	Dir     string        // The directory shell snippets run in
+	Timeout time.Duration // How long each snippet may run; 0 means no limit
+	Run     Runner        // Runs snippets; nil means RunShell
+}
+
+// Expand returns the body of t with its placeholders filled in. A variable
+// without a value is an error, as is a snippet that fails. ARGS is empty
+// when not given.
+func (e *Expander) Expand(ctx context.Context, t Template, vars map[string]string) (string, error) {
+	var missing []string
+	seen := map[string]bool{}
+	for _, m := range placeholderRe.FindAllStringSubmatch(t.Body, -1) {
+		name := strings.TrimSpace(m[1])
+		if !variableRe.MatchString(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := vars[name]; !ok && name != ArgsVar {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("prompt %q needs %s (pass them as KEY=VALUE)", t.Name, strings.Join(missing, ", "))
+	}
+
+	run := e.Run
+	if run == nil {
+		run = RunShell
+	}
+	var err error
+	expanded := placeholderRe.ReplaceAllStringFunc(t.Body, func(match string) string {
+		if err != nil {
+			return match
+		}
+		inner := strings.TrimSpace(match[2 : len(match)-2])
+		if variableRe.MatchString(inner) {
+			return vars[inner]
+		}
+		snippetCtx, cancel := ctx, context.CancelFunc(func() {})
+		if e.Timeout > 0 {
+			snippetCtx, cancel = context.WithTimeout(ctx, e.Timeout)
+		}
+		defer cancel()
+		var out string
+		out, err = run(snippetCtx, e.Dir, inner)
+		if err != nil {
+			err = fmt.Errorf("prompt %q: {{%s}} failed: %w", t.Name, inner, err)
+			return match
+		}
+		return strings.TrimRight(out, "\n")
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(expanded), nil
+}
+
+// RunShell runs command with the system shell in dir. The output is cut at
+// 64KB; the error includes stderr when the command fails.
+func RunShell(ctx context.Context, dir, command string) (string, error) {
+	cmd, err := sandbox.ShellCommand(ctx, "", command)
+	if err != nil {
+		return "", err
+	}
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	out := stdout.String()
+	if len(out) > maxOutputBytes {
+		out = out[:maxOutputBytes] + "\n[output truncated]"
+	}
+	return out, nil
+}
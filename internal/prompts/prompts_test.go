@@ -0,0 +1,108 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writePrompt(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	writePrompt(t, dir, "review.md", "\n# Review the staged changes\n\n{{git diff --cached}}\n")
+	writePrompt(t, dir, "release-notes.md", "Write release notes for {{VERSION}}\n")
+	writePrompt(t, dir, "notes.txt", "not a prompt")
+
+	templates, err := List(dir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(templates) != 2 || templates[0].Name != "release-notes" || templates[1].Name != "review" {
+		t.Fatalf("Unexpected templates: %+v", templates)
+	}
+	if d := templates[1].Description; d != "Review the staged changes" {
+		t.Errorf("Unexpected description %q", d)
+	}
+
+	if templates, err := List(filepath.Join(dir, "missing")); err != nil || templates != nil {
+		t.Errorf("Expected no templates for a missing directory, got %v, %v", templates, err)
+	}
+	if _, err := Load(dir, "nope"); err == nil {
+		t.Error("Expected an error for an unknown prompt")
+	}
+	if _, err := Load(dir, "../review"); err == nil {
+		t.Error("Expected an error for a name with a path")
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	got := ParseArgs([]string{"VERSION=v1.2", "focus", "on", "errors", "x=1"})
+	want := map[string]string{"VERSION": "v1.2", ArgsVar: "focus on errors x=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected variables %v", got)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	var commands []string
+	e := &Expander{Dir: "/repo", Run: func(ctx context.Context, dir, command string) (string, error) {
+		commands = append(commands, dir+": "+command)
+		return "diff output\n", nil
+	}}
+	tmpl := Template{Name: "release-notes", Body: "Notes for {{ VERSION }}.\n{{ARGS}}\n\n{{git log --oneline}}\n"}
+
+	got, err := e.Expand(context.Background(), tmpl, map[string]string{"VERSION": "v1.2"})
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	if got != "Notes for v1.2.\n\n\ndiff output" {
+		t.Errorf("Unexpected expansion %q", got)
+	}
+	if !reflect.DeepEqual(commands, []string{"/repo: git log --oneline"}) {
+		t.Errorf("Unexpected commands %v", commands)
+	}
+
+	// Missing variables are all reported, and no snippet runs
+	commands = nil
+	_, err = e.Expand(context.Background(), Template{Name: "x", Body: "{{A}} {{B}} {{A}} {{date}}"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "needs A, B") {
+		t.Errorf("Expected the missing variables, got %v", err)
+	}
+	if len(commands) != 0 {
+		t.Errorf("Expected no commands, got %v", commands)
+	}
+
+	// A failing snippet fails the expansion
+	e.Run = func(ctx context.Context, dir, command string) (string, error) { return "", errors.New("exit status 1") }
+	if _, err := e.Expand(context.Background(), Template{Name: "x", Body: "{{false}}"}, nil); err == nil || !strings.Contains(err.Error(), "{{false}} failed") {
+		t.Errorf("Expected the snippet error, got %v", err)
+	}
+}
+
+func TestRunShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	out, err := RunShell(context.Background(), dir, "pwd && echo hi")
+	if err != nil {
+		t.Fatalf("RunShell failed: %v", err)
+	}
+	if resolved, _ := filepath.EvalSymlinks(dir); !strings.Contains(out, resolved) || !strings.HasSuffix(out, "hi\n") {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if _, err := RunShell(context.Background(), dir, "echo oops >&2; exit 3"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("Expected stderr in the error, got %v", err)
+	}
+}
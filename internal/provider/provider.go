@@ -0,0 +1,70 @@
+// Package provider builds the clients of OpenAI-compatible APIs, so that the
+// agent, the summarizer, the model list and the embedder all authenticate,
+// reach the API through the proxy and retry failed requests the same way.
+package provider
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Options configures a client
+type Options struct {
+	APIKey     string
+	BaseURL    string // The API's URL; OpenAI's if empty
+	Proxy      string // Proxy URL; the HTTPS_PROXY and HTTP_PROXY variables if empty
+	MaxRetries int    // Retries of requests failing with 429, 5xx or a network error
+}
+
+// ChatOptions returns the options of the chat completions API of cfg
+func ChatOptions(cfg *config.Config) Options {
+	return Options{APIKey: cfg.APIKey, BaseURL: cfg.BaseURL, Proxy: cfg.Proxy, MaxRetries: cfg.MaxRetries}
+}
+
+// EmbeddingOptions returns the options of the embeddings API of cfg, which
+// may be another service than the chat API
+func EmbeddingOptions(cfg *config.Config) Options {
+	baseURL, apiKey := cfg.EmbeddingEndpoint()
+	return Options{APIKey: apiKey, BaseURL: baseURL, Proxy: cfg.Proxy, MaxRetries: cfg.MaxRetries}
+}
+
+// NewClient returns a client of the API opts describe
+func NewClient(opts Options) *openai.Client {
+	clientConfig := openai.DefaultConfig(opts.APIKey)
+	if opts.BaseURL != "" {
+		clientConfig.BaseURL = opts.BaseURL
+	}
+	clientConfig.HTTPClient = HTTPClient(opts)
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+// HTTPClient returns an HTTP client going through the proxy of opts and
+// retrying failed requests. Clients with the same proxy share connections.
+func HTTPClient(opts Options) *http.Client {
+	return &http.Client{Transport: &retryTransport{base: transport(opts.Proxy), maxRetries: opts.MaxRetries}}
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = map[string]*http.Transport{}
+)
+
+// transport returns the shared transport using proxy. An invalid proxy
+// URL, which config.Load refuses, falls back to the environment.
+func transport(proxy string) *http.Transport {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[proxy]; ok {
+		return t
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if u, err := url.Parse(proxy); proxy != "" && err == nil {
+		t.Proxy = http.ProxyURL(u)
+	}
+	transports[proxy] = t
+	return t
+}
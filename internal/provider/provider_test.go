@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func init() {
+	retryBackoff = time.Millisecond
+}
+
+func TestRetries(t *testing.T) {
+	var hits atomic.Int32
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if hits.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer ts.Close()
+
+	client := NewClient(Options{APIKey: "test", BaseURL: ts.URL, MaxRetries: 2})
+	resp, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "m", Messages: []openai.ChatCompletionMessage{{Role: "user", Content: "hi"}}})
+	if err != nil || resp.Choices[0].Message.Content != "ok" {
+		t.Fatalf("Expected success after two retries, got %+v, %v", resp, err)
+	}
+	if hits.Load() != 3 || bodies[0] == "" || bodies[2] != bodies[0] {
+		t.Errorf("Expected 3 requests with the same body, got %d: %q", hits.Load(), bodies)
+	}
+
+	// Out of retries, the last error is returned
+	hits.Store(0)
+	client = NewClient(Options{APIKey: "test", BaseURL: ts.URL, MaxRetries: 1})
+	if _, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "m"}); err == nil || hits.Load() != 2 {
+		t.Errorf("Expected an error after 2 requests, got %v after %d", err, hits.Load())
+	}
+}
+
+func TestNoRetryOnClientErrors(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	client := NewClient(Options{APIKey: "test", BaseURL: ts.URL, MaxRetries: 3})
+	if _, err := client.ListModels(context.Background()); err == nil || hits.Load() != 1 {
+		t.Errorf("Expected one failed request, got %v after %d", err, hits.Load())
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object":"list","data":[{"id":"gpt-test"}]}`)
+	}))
+	defer proxy.Close()
+
+	client := NewClient(Options{APIKey: "test", BaseURL: "http://api.invalid/v1", Proxy: proxy.URL})
+	list, err := client.ListModels(context.Background())
+	if err != nil || len(list.Models) != 1 {
+		t.Fatalf("Expected the model list through the proxy, got %+v, %v", list, err)
+	}
+	if got, _ := proxied.Load().(string); !strings.HasPrefix(got, "http://api.invalid/v1/models") {
+		t.Errorf("Expected the proxy to get the API request, got %q", got)
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"7"}}}
+	if got := retryWait(0, resp); got != 7*time.Second {
+		t.Errorf("Expected the Retry-After pause, got %v", got)
+	}
+	resp.Header.Set("Retry-After", "3600")
+	if got := retryWait(0, resp); got != maxRetryWait {
+		t.Errorf("Expected the pause to be capped, got %v", got)
+	}
+	if got := retryWait(2, nil); got != 4*retryBackoff {
+		t.Errorf("Expected exponential backoff, got %v", got)
+	}
+}
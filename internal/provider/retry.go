@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryBackoff is the pause before the first retry; it doubles with each one
+var retryBackoff = 500 * time.Millisecond
+
+// maxRetryWait bounds the pause a Retry-After header may ask for
+const maxRetryWait = 30 * time.Second
+
+// retryTransport retries requests failing with a network error, a rate limit
+// or a server error, as long as their body can be sent again
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.maxRetries || !retryable(resp, err) || ctx.Err() != nil || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		wait := retryWait(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		req = req.Clone(ctx)
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryable reports whether a request that got resp or err is worth retrying
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryWait returns the pause before retry attempt+1: what the Retry-After
+// header asks for, if anything, or an exponential backoff
+func retryWait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if after := resp.Header.Get("Retry-After"); after != "" {
+			var wait time.Duration
+			if secs, err := strconv.Atoi(after); err == nil {
+				wait = time.Duration(secs) * time.Second
+			} else if at, err := http.ParseTime(after); err == nil {
+				wait = time.Until(at)
+			}
+			if wait > maxRetryWait {
+				wait = maxRetryWait
+			}
+			if wait > 0 {
+				return wait
+			}
+		}
+	}
+	return retryBackoff << attempt
+}
@@ -0,0 +1,241 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Scheduler admits API requests under requests-per-minute and tokens-per-minute
+// limits. Waiters are admitted in arrival order, so concurrent sessions sharing
+// one API key queue fairly instead of racing each other into 429 responses.
+// A nil *Scheduler imposes no limits.
+type Scheduler struct {
+	mu          sync.Mutex
+	requests    *bucket // nil if RPM is unlimited
+	tokens      *bucket // nil if TPM is unlimited
+	pausedUntil time.Time
+	queue       []*waiter
+	now         func() time.Time
+}
+
+// waiter is a queued Wait call
+type waiter struct {
+	tokens int
+	wake   chan struct{} // Signalled when the waiter may re-check the queue
+}
+
+// NewScheduler creates a scheduler. A limit of zero or less disables that limit;
+// if both are disabled NewScheduler returns nil.
+func NewScheduler(rpm, tpm int) *Scheduler {
+	if rpm <= 0 && tpm <= 0 {
+		return nil
+	}
+	s := &Scheduler{now: time.Now}
+	start := s.now()
+	if rpm > 0 {
+		s.requests = newBucket(float64(rpm), start)
+	}
+	if tpm > 0 {
+		s.tokens = newBucket(float64(tpm), start)
+	}
+	return s
+}
+
+var (
+	sharedMu   sync.Mutex
+	schedulers = make(map[string]*Scheduler)
+)
+
+// Shared returns the process-wide scheduler for key, typically the API key and
+// endpoint, creating it with the given limits on first use.
+func Shared(key string, rpm, tpm int) *Scheduler {
+	if rpm <= 0 && tpm <= 0 {
+		return nil
+	}
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if s, ok := schedulers[key]; ok {
+		return s
+	}
+	s := NewScheduler(rpm, tpm)
+	schedulers[key] = s
+	return s
+}
+
+// Wait blocks until a request estimated to use the given number of tokens may
+// be sent, then reserves capacity for it. It returns ctx.Err() if ctx is done first.
+func (s *Scheduler) Wait(ctx context.Context, tokens int) error {
+	if s == nil {
+		return nil
+	}
+
+	w := &waiter{tokens: tokens, wake: make(chan struct{}, 1)}
+	s.mu.Lock()
+	s.queue = append(s.queue, w)
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		var delay time.Duration
+		if s.queue[0] == w {
+			delay = s.reserveLocked(w.tokens)
+			if delay == 0 {
+				s.queue = s.queue[1:]
+				s.wakeHeadLocked()
+				s.mu.Unlock()
+				return nil
+			}
+		}
+		s.mu.Unlock()
+
+		// Only the head of the queue sleeps on a timer; the rest wait their turn
+		var t *time.Timer
+		var timer <-chan time.Time
+		if delay > 0 {
+			t = time.NewTimer(delay)
+			timer = t.C
+		}
+
+		select {
+		case <-timer:
+		case <-w.wake:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			s.remove(w)
+			return err
+		}
+	}
+}
+
+// Adjust corrects the token reservation of a completed request by delta, e.g.
+// to account for completion tokens that were not known up front.
+func (s *Scheduler) Adjust(delta int) {
+	if s == nil || s.tokens == nil || delta == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens.refill(s.now())
+	s.tokens.available -= float64(delta)
+	if s.tokens.available > s.tokens.capacity {
+		s.tokens.available = s.tokens.capacity
+	}
+}
+
+// Backoff pauses all admissions for d, e.g. after the API returned 429
+func (s *Scheduler) Backoff(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if until := s.now().Add(d); until.After(s.pausedUntil) {
+		s.pausedUntil = until
+	}
+}
+
+// reserveLocked takes capacity for a request, or returns how long to wait before retrying
+func (s *Scheduler) reserveLocked(tokens int) time.Duration {
+	now := s.now()
+	if now.Before(s.pausedUntil) {
+		return s.pausedUntil.Sub(now)
+	}
+
+	var delay time.Duration
+	if s.requests != nil {
+		delay = maxDuration(delay, s.requests.delay(1, now))
+	}
+	if s.tokens != nil {
+		delay = maxDuration(delay, s.tokens.delay(float64(tokens), now))
+	}
+	if delay > 0 {
+		return delay
+	}
+
+	if s.requests != nil {
+		s.requests.take(1)
+	}
+	if s.tokens != nil {
+		s.tokens.take(float64(tokens))
+	}
+	return 0
+}
+
+// remove drops a cancelled waiter from the queue
+func (s *Scheduler) remove(w *waiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, q := range s.queue {
+		if q == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			if i == 0 {
+				s.wakeHeadLocked()
+			}
+			return
+		}
+	}
+}
+
+// wakeHeadLocked signals the waiter at the head of the queue
+func (s *Scheduler) wakeHeadLocked() {
+	if len(s.queue) == 0 {
+		return
+	}
+	select {
+	case s.queue[0].wake <- struct{}{}:
+	default:
+	}
+}
+
+// bucket is a token bucket holding up to one minute's worth of capacity
+type bucket struct {
+	capacity  float64
+	available float64
+	perSecond float64
+	updated   time.Time
+}
+
+func newBucket(perMinute float64, now time.Time) *bucket {
+	return &bucket{capacity: perMinute, available: perMinute, perSecond: perMinute / 60, updated: now}
+}
+
+// refill adds the capacity accrued since the last update
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.available += elapsed * b.perSecond
+		if b.available > b.capacity {
+			b.available = b.capacity
+		}
+	}
+	b.updated = now
+}
+
+// delay returns how long until n units are available. Requests larger than the
+// bucket are clamped to its capacity so they are not starved forever.
+func (b *bucket) delay(n float64, now time.Time) time.Duration {
+	b.refill(now)
+	if n > b.capacity {
+		n = b.capacity
+	}
+	if b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.perSecond * float64(time.Second))
+}
+
+// take consumes n units; availability may go negative for oversized requests
+func (b *bucket) take(n float64) {
+	b.available -= n
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNilScheduler(t *testing.T) {
+	s := NewScheduler(0, 0)
+	if s != nil {
+		t.Fatalf("Expected nil scheduler without limits")
+	}
+	if err := s.Wait(context.Background(), 1000); err != nil {
+		t.Errorf("Expected nil scheduler to admit immediately, got %v", err)
+	}
+	s.Adjust(10)
+	s.Backoff(time.Second)
+}
+
+func TestSchedulerLimits(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewScheduler(2, 100)
+	s.now = func() time.Time { return now }
+	s.requests.updated, s.tokens.updated = now, now
+
+	ctx := context.Background()
+	if err := s.Wait(ctx, 10); err != nil {
+		t.Fatalf("First request should be admitted: %v", err)
+	}
+	if err := s.Wait(ctx, 10); err != nil {
+		t.Fatalf("Second request should be admitted: %v", err)
+	}
+
+	// The RPM bucket is empty: one request refills every 30s
+	if d := s.reserveLocked(10); d != 30*time.Second {
+		t.Errorf("Expected 30s delay, got %v", d)
+	}
+
+	// A queued waiter is released when its context ends and leaves the queue
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := s.Wait(waitCtx, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline error, got %v", err)
+	}
+	if len(s.queue) != 0 {
+		t.Errorf("Expected empty queue after cancellation, got %d", len(s.queue))
+	}
+
+	// After refilling, the TPM bucket limits an oversized request to its capacity
+	now = now.Add(time.Minute)
+	s.Adjust(50)
+	if d := s.reserveLocked(500); d != 30*time.Second {
+		t.Errorf("Expected 30s delay for token refill, got %v", d)
+	}
+
+	// Backoff pauses admissions regardless of capacity
+	now = now.Add(time.Minute)
+	s.Backoff(5 * time.Second)
+	if d := s.reserveLocked(1); d != 5*time.Second {
+		t.Errorf("Expected 5s backoff, got %v", d)
+	}
+}
+
+func TestShared(t *testing.T) {
+	a := Shared("key", 10, 0)
+	if a == nil || Shared("key", 20, 0) != a {
+		t.Errorf("Expected the same scheduler for the same key")
+	}
+	if Shared("other", 10, 0) == a {
+		t.Errorf("Expected a different scheduler for a different key")
+	}
+	if Shared("none", 0, 0) != nil {
+		t.Errorf("Expected nil scheduler without limits")
+	}
+}
@@ -0,0 +1,173 @@
+// Package redact replaces secrets in text with placeholders before the text
+// is sent to the model: AWS keys, private keys, secret-looking assignments
+// such as those of .env files, and any patterns the user configures.
+package redact
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPrefix starts every placeholder; text already redacted is not
+// redacted again
+const placeholderPrefix = "[REDACTED:"
+
+// Placeholder returns the text replacing a secret found by the named detector
+func Placeholder(name string) string {
+	return placeholderPrefix + name + "]"
+}
+
+// detector finds one kind of secret. With group set, only that capture group
+// of a match is the secret, such as the value of an assignment.
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	group   int
+}
+
+// builtinDetectors are always applied. They look for well-known formats and
+// for values assigned to names that say they are secret, so ordinary code
+// and output are left alone.
+var builtinDetectors = []detector{
+	{"private-key", regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY[A-Z ]*-----(?:.*?-----END [A-Z0-9 ]*PRIVATE KEY[A-Z ]*-----|.*)`), 0},
+	{"aws-access-key-id", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`), 0},
+	{"aws-secret-access-key", regexp.MustCompile(`(?i)aws_?secret(?:_?access)?_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`), 1},
+	{"secret-value", regexp.MustCompile(`(?m)^[ \t]*(?:\d+\t)?(?:export[ \t]+)?[A-Z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?)[A-Z0-9_]*[ \t]*[:=][ \t]*["']?([^\s"'#]{4,})`), 1},
+}
+
+// envValue matches every assignment of a .env file, for EnvFile. Lines may
+// start with their number, as read_file shows them.
+var envValue = regexp.MustCompile(`(?m)^[ \t]*(?:\d+\t)?(?:export[ \t]+)?[A-Za-z_][A-Za-z0-9_.]*[ \t]*=[ \t]*["']?([^\n"']+)`)
+
+// Finding counts the secrets one detector redacted
+type Finding struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Summary describes findings, like "2 aws-access-key-id, 1 private-key"
+func Summary(findings []Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = fmt.Sprintf("%d %s", f.Count, f.Name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Total returns the number of secrets redacted
+func Total(findings []Finding) int {
+	n := 0
+	for _, f := range findings {
+		n += f.Count
+	}
+	return n
+}
+
+// Redactor replaces secrets with placeholders. A nil Redactor leaves text
+// unchanged.
+type Redactor struct {
+	detectors []detector
+}
+
+// New creates a redactor with the built-in detectors and the given regular
+// expressions. A pattern with a capture group redacts only the first group
+// of its matches; otherwise the whole match is redacted.
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{detectors: builtinDetectors}
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		r.detectors = append(r.detectors, detector{name: fmt.Sprintf("pattern-%d", i+1), pattern: re, group: group})
+	}
+	return r, nil
+}
+
+// Redact replaces the secrets in text and reports what it found
+func (r *Redactor) Redact(text string) (string, []Finding) {
+	if r == nil || text == "" {
+		return text, nil
+	}
+	var findings []Finding
+	for _, d := range r.detectors {
+		var n int
+		text, n = replace(text, d)
+		if n > 0 {
+			findings = append(findings, Finding{Name: d.name, Count: n})
+		}
+	}
+	return text, findings
+}
+
+// EnvFile is Redact for the contents of a .env file: every value is taken
+// for a secret, whatever its name
+func (r *Redactor) EnvFile(text string) (string, []Finding) {
+	if r == nil {
+		return text, nil
+	}
+	text, findings := r.Redact(text)
+	text, n := replace(text, detector{name: "env-value", pattern: envValue, group: 1})
+	if n > 0 {
+		findings = append(findings, Finding{Name: "env-value", Count: n})
+	}
+	return text, findings
+}
+
+// IsEnvFile reports whether path names a .env file, like .env or
+// .env.production
+func IsEnvFile(path string) bool {
+	name := filepath.Base(path)
+	return name == ".env" || strings.HasPrefix(name, ".env.")
+}
+
+// Merge adds up findings of the same detectors, sorted by name
+func Merge(lists ...[]Finding) []Finding {
+	counts := make(map[string]int)
+	for _, list := range lists {
+		for _, f := range list {
+			counts[f.Name] += f.Count
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	merged := make([]Finding, 0, len(counts))
+	for name, count := range counts {
+		merged = append(merged, Finding{Name: name, Count: count})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
+
+// replace substitutes the placeholder of d for its matches in text
+func replace(text string, d detector) (string, int) {
+	matches := d.pattern.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text, 0
+	}
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range matches {
+		start, end := m[2*d.group], m[2*d.group+1]
+		if start < 0 || start == end || strings.HasPrefix(text[start:end], placeholderPrefix) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(Placeholder(d.name))
+		last = end
+		n++
+	}
+	if n == 0 {
+		return text, 0
+	}
+	b.WriteString(text[last:])
+	return b.String(), n
+}
@@ -0,0 +1,281 @@
+// Package repocontext fits the repository context given to the agent, the
+// project docs (codex.md) and the repository map, into a token budget. When
+// it does not fit, the sections most relevant to the user's prompt are kept.
+package repocontext
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/epuerta/codex-go/internal/repomap"
+)
+
+// Section is a part of the repository context that is kept or left out as a whole
+type Section struct {
+	Source string // Where the section comes from, e.g. "codex.md" or "repository map"
+	Title  string // Heading of a document section or directory of the map
+	Text   string
+}
+
+// Tokens estimates the size of the section in tokens
+func (s Section) Tokens() int {
+	return repomap.EstimateTokens(s.Text)
+}
+
+// SplitMarkdown splits a document into one section per heading. Text before
+// the first heading forms its own section.
+func SplitMarkdown(source, text string) []Section {
+	var sections []Section
+	var current strings.Builder
+	title := ""
+	inFence := false
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			sections = append(sections, Section{Source: source, Title: title, Text: current.String()})
+		}
+		current.Reset()
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(trimmed, "#") {
+			flush()
+			title = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return sections
+}
+
+// SplitTree splits an indented listing, such as a rendered repository map,
+// into one section per top-level entry and everything nested under it
+func SplitTree(source, text string) []Section {
+	var sections []Section
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" {
+			continue
+		}
+		if len(sections) == 0 || !strings.HasPrefix(line, " ") {
+			title := strings.TrimSuffix(strings.TrimSpace(line), "/")
+			title, _, _ = strings.Cut(title, ":")
+			sections = append(sections, Section{Source: source, Title: title})
+		}
+		sections[len(sections)-1].Text += line
+	}
+	return sections
+}
+
+// Selection is the part of the context that fits the budget
+type Selection struct {
+	Sections []Section // In their original order
+	Omitted  []Section // Left out, most relevant first
+}
+
+// Select returns the sections that fit in maxTokens (no limit if 0 or less).
+// If they all fit, all are kept. Otherwise sections are ranked by how
+// relevant they are to prompt and the best ones are kept while they fit.
+func Select(sections []Section, prompt string, maxTokens int) Selection {
+	total := 0
+	for _, s := range sections {
+		total += s.Tokens()
+	}
+	if maxTokens <= 0 || total <= maxTokens {
+		return Selection{Sections: sections}
+	}
+
+	scores := Score(sections, prompt)
+	order := make([]int, len(sections))
+	for i := range order {
+		order[i] = i
+	}
+	// Ties keep the original order, so untitled leading sections and
+	// earlier documents win when the prompt says nothing about them
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	keep := make([]bool, len(sections))
+	used := 0
+	var omitted []Section
+	for _, i := range order {
+		if tokens := sections[i].Tokens(); used+tokens <= maxTokens {
+			keep[i] = true
+			used += tokens
+		} else {
+			omitted = append(omitted, sections[i])
+		}
+	}
+	var kept []Section
+	for i, s := range sections {
+		if keep[i] {
+			kept = append(kept, s)
+		}
+	}
+	return Selection{Sections: kept, Omitted: omitted}
+}
+
+// Score rates the relevance of each section to prompt with BM25 over the
+// words of the prompt, so that rare words count for more than common ones
+// and long sections do not win merely by being long
+func Score(sections []Section, prompt string) []float64 {
+	const k1, b = 1.2, 0.75
+	terms := uniqueWords(prompt)
+	scores := make([]float64, len(sections))
+	if len(terms) == 0 || len(sections) == 0 {
+		return scores
+	}
+
+	counts := make([]map[string]int, len(sections))
+	lengths := make([]int, len(sections))
+	docFreq := make(map[string]int)
+	totalLength := 0
+	for i, s := range sections {
+		counts[i] = make(map[string]int)
+		for _, w := range words(s.Title + "\n" + s.Text) {
+			counts[i][w]++
+			lengths[i]++
+		}
+		for _, t := range terms {
+			if counts[i][t] > 0 {
+				docFreq[t]++
+			}
+		}
+		totalLength += lengths[i]
+	}
+	avgLength := float64(totalLength) / float64(len(sections))
+	if avgLength == 0 {
+		return scores
+	}
+
+	n := float64(len(sections))
+	for i := range sections {
+		for _, t := range terms {
+			tf := float64(counts[i][t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(docFreq[t])+0.5)/(float64(docFreq[t])+0.5))
+			scores[i] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(lengths[i])/avgLength))
+		}
+	}
+	return scores
+}
+
+// Only returns the part of the selection whose sections satisfy keep
+func (sel Selection) Only(keep func(Section) bool) Selection {
+	var part Selection
+	for _, s := range sel.Sections {
+		if keep(s) {
+			part.Sections = append(part.Sections, s)
+		}
+	}
+	for _, s := range sel.Omitted {
+		if keep(s) {
+			part.Omitted = append(part.Omitted, s)
+		}
+	}
+	return part
+}
+
+// Render joins the selected sections, grouped by source under the headers
+// given for each source, and notes what was left out
+func (sel Selection) Render(headers map[string]string) string {
+	var parts []string
+	var current strings.Builder
+	source := ""
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+		}
+	}
+	for _, s := range sel.Sections {
+		if s.Source != source || current.Len() == 0 {
+			flush()
+			source = s.Source
+			if header := headers[s.Source]; header != "" {
+				current.WriteString(header + "\n")
+			}
+		}
+		current.WriteString(s.Text)
+		if !strings.HasSuffix(s.Text, "\n") {
+			current.WriteString("\n")
+		}
+	}
+	flush()
+
+	if len(sel.Omitted) > 0 {
+		var names []string
+		for _, s := range sel.Omitted {
+			name := s.Source
+			if s.Title != "" {
+				name += ": " + s.Title
+			}
+			names = append(names, name)
+		}
+		parts = append(parts, fmt.Sprintf("(%d sections less relevant to the request were left out to save space; read the files if you need them: %s)", len(sel.Omitted), strings.Join(names, "; ")))
+	}
+	return strings.Join(parts, "\n\n---\n\n")
+}
+
+// stopWords are too common to tell sections apart
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"can": true, "do": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true,
+	"it": true, "me": true, "my": true, "of": true, "on": true, "or": true, "please": true, "so": true,
+	"that": true, "the": true, "this": true, "to": true, "we": true, "what": true, "when": true,
+	"where": true, "which": true, "with": true, "you": true,
+}
+
+// words splits text into lowercase words, also splitting identifiers such as
+// parseConfig or parse_config into their parts
+func words(text string) []string {
+	var out []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		lower := strings.ToLower(field)
+		if !stopWords[lower] {
+			out = append(out, lower)
+		}
+		if parts := camelParts(field); len(parts) > 1 {
+			for _, p := range parts {
+				if p = strings.ToLower(p); !stopWords[p] {
+					out = append(out, p)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// camelParts splits a camelCase or PascalCase identifier
+func camelParts(s string) []string {
+	var parts []string
+	start := 0
+	runes := []rune(s)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1]) {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
+
+// uniqueWords returns the distinct words of text
+func uniqueWords(text string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, w := range words(text) {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
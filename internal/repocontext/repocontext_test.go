@@ -0,0 +1,121 @@
+package repocontext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitMarkdown(t *testing.T) {
+	doc := "Intro text\n\n# Build\nRun make.\n```sh\n# not a heading\nmake\n```\n## Testing\nRun go test.\n"
+	sections := SplitMarkdown("codex.md", doc)
+	if len(sections) != 3 {
+		t.Fatalf("got %d sections, want 3: %+v", len(sections), sections)
+	}
+	if sections[0].Title != "" || !strings.Contains(sections[0].Text, "Intro") {
+		t.Errorf("first section = %+v, want the untitled intro", sections[0])
+	}
+	if sections[1].Title != "Build" || !strings.Contains(sections[1].Text, "# not a heading") {
+		t.Errorf("second section = %+v, want Build with its code block", sections[1])
+	}
+	if sections[2].Title != "Testing" {
+		t.Errorf("third section title = %q, want Testing", sections[2].Title)
+	}
+	if got := sections[0].Text + sections[1].Text + sections[2].Text; got != doc {
+		t.Errorf("sections do not add up to the document:\n%s", got)
+	}
+}
+
+func TestSplitTree(t *testing.T) {
+	tree := "cmd/\n  main.go: main\ninternal/\n  config/\n    config.go: Load, Config\nREADME.md\n"
+	sections := SplitTree("repository map", tree)
+	var titles []string
+	for _, s := range sections {
+		titles = append(titles, s.Title)
+	}
+	if got := strings.Join(titles, ","); got != "cmd,internal,README.md" {
+		t.Fatalf("titles = %s, want cmd,internal,README.md", got)
+	}
+	if !strings.Contains(sections[1].Text, "config.go: Load, Config") {
+		t.Errorf("internal section = %q, want its nested entries", sections[1].Text)
+	}
+}
+
+func TestSelectKeepsEverythingWithinBudget(t *testing.T) {
+	sections := []Section{{Source: "a", Text: "one"}, {Source: "b", Text: "two"}}
+	sel := Select(sections, "anything", 1000)
+	if len(sel.Sections) != 2 || len(sel.Omitted) != 0 {
+		t.Fatalf("got %d kept, %d omitted; want 2 kept", len(sel.Sections), len(sel.Omitted))
+	}
+	sel = Select(sections, "anything", 0)
+	if len(sel.Sections) != 2 {
+		t.Fatalf("no limit kept %d sections, want 2", len(sel.Sections))
+	}
+}
+
+func TestSelectPrefersRelevantSections(t *testing.T) {
+	filler := strings.Repeat("general notes about the project layout ", 20)
+	sections := []Section{
+		{Source: "codex.md", Title: "Style", Text: "# Style\n" + filler},
+		{Source: "codex.md", Title: "Database", Text: "# Database\nMigrations live in db/migrations; run migrate up.\n" + filler},
+		{Source: "codex.md", Title: "Release", Text: "# Release\n" + filler},
+	}
+	budget := sections[1].Tokens() + 5
+	sel := Select(sections, "add a database migration for users", budget)
+	if len(sel.Sections) != 1 || sel.Sections[0].Title != "Database" {
+		t.Fatalf("kept %+v, want only the Database section", sel.Sections)
+	}
+	if len(sel.Omitted) != 2 {
+		t.Fatalf("omitted %d sections, want 2", len(sel.Omitted))
+	}
+}
+
+func TestSelectKeepsOriginalOrder(t *testing.T) {
+	sections := []Section{
+		{Source: "map", Title: "cmd", Text: "cmd/\n  main.go: parseFlags\n"},
+		{Source: "map", Title: "docs", Text: "docs/\n  guide.md\n" + strings.Repeat("x ", 200)},
+		{Source: "map", Title: "internal", Text: "internal/\n  config.go: LoadConfig\n"},
+	}
+	sel := Select(sections, "where is the config loaded and flags parsed", sections[0].Tokens()+sections[2].Tokens())
+	if len(sel.Sections) != 2 || sel.Sections[0].Title != "cmd" || sel.Sections[1].Title != "internal" {
+		t.Fatalf("kept %+v, want cmd then internal", sel.Sections)
+	}
+}
+
+func TestScoreSplitsIdentifiers(t *testing.T) {
+	sections := []Section{
+		{Text: "func parseConfig() error"},
+		{Text: "func render() string"},
+	}
+	scores := Score(sections, "fix config parsing in parse")
+	if scores[0] <= scores[1] {
+		t.Errorf("scores = %v, want the parseConfig section first", scores)
+	}
+}
+
+func TestRender(t *testing.T) {
+	sel := Selection{
+		Sections: []Section{
+			{Source: "codex.md", Title: "Build", Text: "# Build\nmake"},
+			{Source: "codex.md", Title: "Test", Text: "# Test\ngo test\n"},
+			{Source: "map", Title: "cmd", Text: "cmd/\n"},
+		},
+		Omitted: []Section{{Source: "map", Title: "docs"}},
+	}
+	got := sel.Render(map[string]string{"map": "Repository Map:"})
+	want := "# Build\nmake\n# Test\ngo test\n\n---\n\nRepository Map:\ncmd/\n\n---\n\n" +
+		"(1 sections less relevant to the request were left out to save space; read the files if you need them: map: docs)"
+	if got != want {
+		t.Errorf("Render() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestOnly(t *testing.T) {
+	sel := Selection{
+		Sections: []Section{{Source: "codex.md", Title: "Build"}, {Source: "map", Title: "cmd"}},
+		Omitted:  []Section{{Source: "map", Title: "docs"}, {Source: "codex.md", Title: "Style"}},
+	}
+	part := sel.Only(func(s Section) bool { return s.Source == "map" })
+	if len(part.Sections) != 1 || part.Sections[0].Title != "cmd" || len(part.Omitted) != 1 || part.Omitted[0].Title != "docs" {
+		t.Errorf("Only() = %+v, want the sections of the map", part)
+	}
+}
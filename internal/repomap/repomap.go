@@ -0,0 +1,309 @@
+package repomap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheDir is the cache directory, relative to the repository root
+const CacheDir = ".codex/cache"
+
+// CacheFileName is the name of the persisted map inside CacheDir
+const CacheFileName = "repomap.json"
+
+// cacheVersion is bumped whenever the entry format or symbol extraction changes
+const cacheVersion = 2
+
+// bytesPerToken is the rough size of a token, used to apply token budgets
+const bytesPerToken = 4
+
+// maxFileSize is the largest file whose symbols are extracted
+const maxFileSize = 1 << 20
+
+// skipDirs are never descended into when the repository is not a git checkout
+var skipDirs = map[string]bool{
+	".git": true, ".codex": true, "node_modules": true, "vendor": true,
+	"dist": true, "build": true, "target": true, "__pycache__": true,
+}
+
+// FileEntry describes one file in the repository map
+type FileEntry struct {
+	Path     string    `json:"path"` // Slash-separated, relative to the root
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Language string    `json:"language,omitempty"`
+	Symbols  []string  `json:"symbols,omitempty"`
+}
+
+// Map is an index of the files and top-level symbols in a repository
+type Map struct {
+	Version     int         `json:"version"`
+	Root        string      `json:"root"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Files       []FileEntry `json:"files"`
+}
+
+// Stats reports the work done by an incremental refresh
+type Stats struct {
+	Parsed  int // New or changed files that were (re)parsed
+	Reused  int // Unchanged files taken from the cache
+	Removed int // Cached files that no longer exist
+}
+
+// CachePath returns the location of the persisted map for a repository root
+func CachePath(root string) string {
+	return filepath.Join(root, CacheDir, CacheFileName)
+}
+
+// Load reads the persisted map for root. It returns nil without error if there
+// is no usable cache.
+func Load(root string) (*Map, error) {
+	data, err := os.ReadFile(CachePath(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repo map cache: %w", err)
+	}
+
+	var m Map
+	if err := json.Unmarshal(data, &m); err != nil || m.Version != cacheVersion || m.Root != root {
+		// Corrupt, outdated or moved caches are rebuilt from scratch
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// Save persists the map under the repository's cache directory
+func (m *Map) Save() error {
+	dir := filepath.Join(m.Root, CacheDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// Keep the cache out of git status
+	ignorePath := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignorePath); errors.Is(err, os.ErrNotExist) {
+		os.WriteFile(ignorePath, []byte("*\n"), 0644)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode repo map: %w", err)
+	}
+	tmp := CachePath(m.Root) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write repo map cache: %w", err)
+	}
+	return os.Rename(tmp, CachePath(m.Root))
+}
+
+// Build scans the repository and returns a fresh map, leaving out the files
+// ignore matches. Entries from prev whose size and modification time are
+// unchanged are reused without re-reading the file.
+func Build(root string, prev *Map, ignore Ignore) (*Map, Stats, error) {
+	var stats Stats
+	paths, err := ListFiles(root)
+	if err != nil {
+		return nil, stats, err
+	}
+	paths = ignore.Filter(paths)
+
+	cached := make(map[string]FileEntry)
+	if prev != nil {
+		for _, entry := range prev.Files {
+			cached[entry.Path] = entry
+		}
+	}
+
+	m := &Map{Version: cacheVersion, Root: root, GeneratedAt: time.Now()}
+	for _, rel := range paths {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		if entry, ok := cached[rel]; ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			m.Files = append(m.Files, entry)
+			delete(cached, rel)
+			stats.Reused++
+			continue
+		}
+		delete(cached, rel)
+
+		entry := FileEntry{Path: rel, Size: info.Size(), ModTime: info.ModTime(), Language: languageFor(rel)}
+		if entry.Language != "" && info.Size() <= maxFileSize {
+			if src, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel))); err == nil {
+				entry.Symbols = extractSymbols(entry.Language, src)
+			}
+		}
+		m.Files = append(m.Files, entry)
+		stats.Parsed++
+	}
+	stats.Removed = len(cached)
+
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, stats, nil
+}
+
+// Refresh loads the cached map for root, updates it incrementally and saves it.
+// A cache that cannot be saved is not an error; the fresh map is still returned.
+func Refresh(root string, ignore Ignore) (*Map, Stats, error) {
+	prev, err := Load(root)
+	if err != nil {
+		prev = nil
+	}
+	m, stats, err := Build(root, prev, ignore)
+	if err != nil {
+		return nil, stats, err
+	}
+	if prev == nil || stats.Parsed > 0 || stats.Removed > 0 {
+		if err := m.Save(); err != nil {
+			return m, stats, err
+		}
+	}
+	return m, stats, nil
+}
+
+// Render formats the map as a directory tree listing the exported symbols of
+// each file, estimated to fit in maxTokens tokens (no limit if 0). Once the
+// budget runs short, files are listed without their symbols, and the files
+// that do not fit at all are counted at the end.
+func (m *Map) Render(maxTokens int) string {
+	maxBytes := maxTokens * bytesPerToken
+	var sb strings.Builder
+	var prevDir []string
+	for i, entry := range m.Files {
+		dir := strings.Split(path.Dir(entry.Path), "/")
+		if dir[0] == "." {
+			dir = nil
+		}
+
+		// Headers for the directories not shared with the previous file
+		common := 0
+		for common < len(dir) && common < len(prevDir) && dir[common] == prevDir[common] {
+			common++
+		}
+		var header strings.Builder
+		for depth := common; depth < len(dir); depth++ {
+			fmt.Fprintf(&header, "%s%s/\n", strings.Repeat("  ", depth), dir[depth])
+		}
+
+		line := strings.Repeat("  ", len(dir)) + path.Base(entry.Path)
+		full := line
+		if symbols := exportedSymbols(entry); len(symbols) > 0 {
+			full += ": " + strings.Join(symbols, ", ")
+		}
+
+		switch {
+		case maxBytes <= 0 || sb.Len()+header.Len()+len(full)+1 <= maxBytes:
+			line = full
+		case sb.Len()+header.Len()+len(line)+1 > maxBytes:
+			fmt.Fprintf(&sb, "... (%d more files)\n", len(m.Files)-i)
+			return sb.String()
+		}
+		sb.WriteString(header.String())
+		sb.WriteString(line + "\n")
+		prevDir = dir
+	}
+	return sb.String()
+}
+
+// EstimateTokens estimates the number of tokens in rendered text
+func EstimateTokens(text string) int {
+	return (len(text) + bytesPerToken - 1) / bytesPerToken
+}
+
+// exportedSymbols returns the symbols of a file other packages or modules
+// can use: capitalized names in Go and names without a leading underscore in
+// Python. Other languages have no naming convention, so all are kept.
+func exportedSymbols(entry FileEntry) []string {
+	var keep func(part string) bool
+	switch entry.Language {
+	case "go":
+		keep = token.IsExported
+	case "python":
+		keep = func(part string) bool { return !strings.HasPrefix(part, "_") }
+	default:
+		return entry.Symbols
+	}
+
+	var exported []string
+	for _, symbol := range entry.Symbols {
+		ok := true
+		for _, part := range strings.Split(symbol, ".") {
+			ok = ok && keep(part)
+		}
+		if ok {
+			exported = append(exported, symbol)
+		}
+	}
+	return exported
+}
+
+// Ignore reports whether a slash-separated path relative to the repository
+// root is left out. A nil Ignore leaves out nothing.
+type Ignore func(rel string) bool
+
+// Filter returns the paths ig does not ignore
+func (ig Ignore) Filter(paths []string) []string {
+	if ig == nil {
+		return paths
+	}
+	kept := paths[:0:0]
+	for _, p := range paths {
+		if !ig(p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// ListFiles returns slash-separated paths relative to root. Git checkouts use
+// tracked and untracked, non-ignored files; other directories are walked.
+func ListFiles(root string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+		cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard")
+		cmd.Dir = root
+		if out, err := cmd.Output(); err == nil {
+			var paths []string
+			scanner := bufio.NewScanner(bytes.NewReader(out))
+			for scanner.Scan() {
+				if line := scanner.Text(); line != "" {
+					paths = append(paths, line)
+				}
+			}
+			return paths, scanner.Err()
+		}
+	}
+
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if d.IsDir() {
+			if path != root && (skipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return paths, err
+}
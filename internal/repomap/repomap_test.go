@@ -0,0 +1,142 @@
+package repomap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", rel, err)
+	}
+}
+
+func TestRefreshIncremental(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\ntype Server struct{}\n\nfunc (s *Server) Run() {}\n\nfunc main() {}\n")
+	writeFile(t, root, "tools/build.py", "import os\n\nclass Builder:\n    def run(self):\n        pass\n\ndef main():\n    pass\n")
+	writeFile(t, root, "node_modules/dep/index.js", "function ignored() {}\n")
+
+	m, stats, err := Refresh(root, nil)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if stats.Parsed != 2 || stats.Reused != 0 {
+		t.Errorf("Unexpected stats for cold start: %+v", stats)
+	}
+	if len(m.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %+v", m.Files)
+	}
+	if want := []string{"Server", "Server.Run", "main"}; !reflect.DeepEqual(m.Files[0].Symbols, want) {
+		t.Errorf("Go symbols = %v, want %v", m.Files[0].Symbols, want)
+	}
+	if want := []string{"Builder", "main"}; !reflect.DeepEqual(m.Files[1].Symbols, want) {
+		t.Errorf("Python symbols = %v, want %v", m.Files[1].Symbols, want)
+	}
+
+	// Warm start: only the changed and new files are parsed, deleted ones dropped
+	writeFile(t, root, "main.go", "package main\n\nfunc main() {}\n\nfunc helper() {}\n")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(root, "main.go"), future, future)
+	writeFile(t, root, "web/app.ts", "export interface Props {}\nexport function render() {}\n")
+	os.Remove(filepath.Join(root, "tools/build.py"))
+
+	m, stats, err = Refresh(root, nil)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if stats.Parsed != 2 || stats.Removed != 1 || stats.Reused != 0 {
+		t.Errorf("Unexpected stats for warm start: %+v", stats)
+	}
+
+	_, stats, _ = Refresh(root, nil)
+	if stats.Parsed != 0 || stats.Reused != 2 {
+		t.Errorf("Expected every file to be reused, got %+v", stats)
+	}
+
+	out := m.Render(0)
+	if !strings.Contains(out, "main.go\n") || !strings.Contains(out, "web/\n  app.ts: Props, render\n") {
+		t.Errorf("Unexpected render:\n%s", out)
+	}
+	if out := m.Render(5); !strings.Contains(out, "more files") {
+		t.Errorf("Expected truncated render, got:\n%s", out)
+	}
+}
+
+func TestBuildIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, root, "gen/types.go", "package gen\n\ntype Generated struct{}\n")
+
+	ignore := Ignore(func(rel string) bool { return strings.HasPrefix(rel, "gen/") })
+	m, _, err := Build(root, nil, ignore)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "main.go" {
+		t.Errorf("Expected only main.go, got %+v", m.Files)
+	}
+}
+
+func TestRenderTree(t *testing.T) {
+	m := &Map{Files: []FileEntry{
+		{Path: "README.md"},
+		{Path: "cmd/codex/main.go", Language: "go", Symbols: []string{"main", "Run", "server.Start", "Server.Start", "Server.stop"}},
+		{Path: "internal/agent/openai.go", Language: "go", Symbols: []string{"OpenAIAgent", "NewOpenAIAgent"}},
+		{Path: "internal/agent/tools.py", Language: "python", Symbols: []string{"_private", "Tool"}},
+		{Path: "internal/ui/chat.ts", Language: "typescript", Symbols: []string{"render"}},
+	}}
+
+	want := `README.md
+cmd/
+  codex/
+    main.go: Run, Server.Start
+internal/
+  agent/
+    openai.go: OpenAIAgent, NewOpenAIAgent
+    tools.py: Tool
+  ui/
+    chat.ts: render
+`
+	if got := m.Render(0); got != want {
+		t.Errorf("Render(0) =\n%s\nwant\n%s", got, want)
+	}
+
+	// A tight budget drops symbols before dropping files
+	got := m.Render(EstimateTokens(want) - 1)
+	if !strings.Contains(got, "main.go: Run, Server.Start\n") || !strings.Contains(got, "    chat.ts\n") {
+		t.Errorf("Expected the last files without symbols, got:\n%s", got)
+	}
+	if EstimateTokens(got) > EstimateTokens(want)-1 {
+		t.Errorf("Render exceeded its budget: %d tokens", EstimateTokens(got))
+	}
+}
+
+func TestExtractSymbolsSkipsPrivate(t *testing.T) {
+	tests := []struct {
+		language string
+		src      string
+		want     []string
+	}{
+		{"rust", "pub struct Server;\nstruct Conn;\n\nimpl Server {\n    pub fn run(&self) {}\n    fn stop(&self) {}\n}\n\npub async fn serve() {}\npub(crate) fn internal() {}\nfn helper() {}\npub mod api;\nmod util;\n",
+			[]string{"Server", "serve", "api"}},
+		{"python", "class Builder:\n    def run(self):\n        pass\n\n    def _prepare(self):\n        pass\n\nclass _Cache:\n    pass\n\ndef main():\n    pass\n\nasync def _fetch():\n    pass\n",
+			[]string{"Builder", "main"}},
+		{"ruby", "module Shop\n  class Cart < Base\n    def add(item)\n    end\n\n    private\n\n    def total\n    end\n  end\nend\n\nclass Shop::Order\n  class << self\n    def build; end\n  end\nend\n\ndef helper\nend\n",
+			[]string{"Shop", "Cart", "Shop::Order"}},
+	}
+	for _, tt := range tests {
+		if got := extractSymbols(tt.language, []byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s symbols = %v, want %v", tt.language, got, tt.want)
+		}
+	}
+}
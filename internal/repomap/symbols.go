@@ -0,0 +1,107 @@
+package repomap
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxSymbolsPerFile bounds the outline of a single file
+const maxSymbolsPerFile = 40
+
+// languages maps file extensions to language names
+var languages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".rs":   "rust",
+	".java": "java",
+	".rb":   "ruby",
+}
+
+// symbolPatterns capture the top-level declarations of languages without a
+// parser. Where a language marks what other modules can use, only those
+// declarations match: pub items in Rust, names without a leading underscore
+// in Python, and classes and modules rather than methods in Ruby.
+var symbolPatterns = map[string]*regexp.Regexp{
+	"python":     regexp.MustCompile(`(?m)^(?:async\s+)?(?:def|class)\s+([A-Za-z]\w*)`),
+	"javascript": regexp.MustCompile(`(?m)^(?:export\s+)?(?:default\s+)?(?:async\s+)?(?:function\*?|class|const|let)\s+([A-Za-z_$][\w$]*)`),
+	"typescript": regexp.MustCompile(`(?m)^(?:export\s+)?(?:default\s+)?(?:abstract\s+)?(?:async\s+)?(?:function\*?|class|interface|type|enum|const|let)\s+([A-Za-z_$][\w$]*)`),
+	"rust":       regexp.MustCompile(`(?m)^pub\s+(?:(?:const|async|unsafe)\s+)*(?:fn|struct|enum|trait|type|mod)\s+([A-Za-z_]\w*)`),
+	"java":       regexp.MustCompile(`(?m)^\s*(?:public\s+|protected\s+)?(?:abstract\s+|final\s+|static\s+)*(?:class|interface|enum|record)\s+([A-Za-z_]\w*)`),
+	"ruby":       regexp.MustCompile(`(?m)^\s*(?:class|module)\s+([A-Z]\w*(?:::[A-Z]\w*)*)`),
+}
+
+// languageFor returns the language of a file, or "" if symbols are not extracted for it
+func languageFor(path string) string {
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// extractSymbols returns the top-level declarations of a source file
+func extractSymbols(language string, src []byte) []string {
+	var symbols []string
+	if language == "go" {
+		symbols = goSymbols(src)
+	} else if pattern, ok := symbolPatterns[language]; ok {
+		for _, match := range pattern.FindAllSubmatch(src, -1) {
+			symbols = append(symbols, string(match[1]))
+		}
+	}
+	if len(symbols) > maxSymbolsPerFile {
+		symbols = symbols[:maxSymbolsPerFile]
+	}
+	return symbols
+}
+
+// goSymbols lists types, functions and methods (as Type.Method) declared in Go source
+func goSymbols(src []byte) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	if err != nil && file == nil {
+		return nil
+	}
+
+	var symbols []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				if recv := receiverType(d.Recv.List[0].Type); recv != "" {
+					name = recv + "." + name
+				}
+			}
+			symbols = append(symbols, name)
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					symbols = append(symbols, ts.Name.Name)
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// receiverType returns the base type name of a method receiver
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+// Package respcache keeps model responses on disk, keyed by a hash of the
+// request, so that running the same scripted prompt again is answered
+// without a request to the provider. It is used by quiet and exec mode when
+// response_cache is set in the config.
+package respcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/config"
+)
+
+// DirName is the name of the response cache inside the codex config directory
+const DirName = "responses"
+
+// Entry is a cached response
+type Entry struct {
+	Key       string          `json:"key"`
+	CreatedAt time.Time       `json:"created_at"`
+	Response  json.RawMessage `json:"response"`
+}
+
+// Cache keeps each response as one JSON file. Entries older than TTL are
+// neither returned nor kept.
+type Cache struct {
+	dir string
+	TTL time.Duration
+}
+
+// New creates a cache backed by the given directory
+func New(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, TTL: ttl}
+}
+
+// DefaultDir returns the default cache directory (~/.codex/responses)
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, config.DefaultConfigDir, DirName), nil
+}
+
+// Key returns the key of a request: the hash of its JSON encoding, which
+// includes the model and messages
+func Key(request any) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// path returns the file holding the response of key
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get decodes the response cached under key into v. It reports false when
+// there is none, or it has expired or cannot be read.
+func (c *Cache) Get(key string, v any) bool {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Key != key {
+		return false
+	}
+	if c.expired(e.CreatedAt) {
+		os.Remove(c.path(key))
+		return false
+	}
+	return json.Unmarshal(e.Response, v) == nil
+}
+
+// Put caches v as the response of key
+func (c *Cache) Put(key string, v any) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create response cache directory: %w", err)
+	}
+	response, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	data, err := json.Marshal(Entry{Key: key, CreatedAt: time.Now(), Response: response})
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+// Prune removes the expired entries and returns how many it removed
+func (c *Cache) Prune() (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response cache: %w", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !c.expired(info.ModTime()) {
+			continue
+		}
+		if os.Remove(filepath.Join(c.dir, entry.Name())) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// expired reports whether an entry created at t is older than the TTL
+func (c *Cache) expired(t time.Time) bool {
+	return c.TTL > 0 && time.Since(t) > c.TTL
+}
@@ -0,0 +1,73 @@
+package respcache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type response struct {
+	Content string
+}
+
+func TestPutGet(t *testing.T) {
+	c := New(t.TempDir(), time.Hour)
+	key, err := Key(map[string]any{"model": "gpt-4o", "messages": []string{"hi"}})
+	if err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+
+	var got response
+	if c.Get(key, &got) {
+		t.Fatal("Get() found an entry in an empty cache")
+	}
+	if err := c.Put(key, response{Content: "hello"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if !c.Get(key, &got) || got.Content != "hello" {
+		t.Errorf("Get() = %+v, want the cached response", got)
+	}
+}
+
+func TestKeyDependsOnRequest(t *testing.T) {
+	a, _ := Key(map[string]any{"model": "gpt-4o", "messages": []string{"hi"}})
+	b, _ := Key(map[string]any{"model": "gpt-4o-mini", "messages": []string{"hi"}})
+	c, _ := Key(map[string]any{"model": "gpt-4o", "messages": []string{"hi"}})
+	if a == b {
+		t.Error("Key() is the same for different models")
+	}
+	if a != c {
+		t.Error("Key() differs for the same request")
+	}
+}
+
+func TestExpiredEntries(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, time.Hour)
+	key, _ := Key("request")
+	if err := c.Put(key, response{Content: "old"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	c.TTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	var got response
+	if c.Get(key, &got) {
+		t.Error("Get() returned an expired entry")
+	}
+	if _, err := os.Stat(filepath.Join(dir, key+".json")); !os.IsNotExist(err) {
+		t.Error("Get() kept the expired entry")
+	}
+
+	// Prune goes by modification time
+	if err := c.Put(key, response{Content: "new"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(dir, key+".json"), old, old)
+	c.TTL = time.Hour
+	if n, err := c.Prune(); err != nil || n != 1 {
+		t.Errorf("Prune() = %d, %v, want 1 entry removed", n, err)
+	}
+}
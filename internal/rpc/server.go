@@ -0,0 +1,223 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"math"
+	"sync"
+
+	codexv1 "github.com/epuerta/codex-go/api/codex/v1"
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/epuerta/codex-go/internal/server"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements codexv1.CodexServiceServer on top of the same sessions
+// used by the HTTP API
+type Server struct {
+	codexv1.UnimplementedCodexServiceServer
+
+	NewEngine server.EngineFactory
+	Logger    logging.Logger
+	// Token, if set, must be sent as "authorization: Bearer <token>" metadata
+	Token string
+
+	mu       sync.Mutex
+	sessions map[string]*server.Session
+}
+
+// New creates a gRPC service
+func New(factory server.EngineFactory, logger logging.Logger) *Server {
+	if logger == nil {
+		logger = logging.NewNilLogger()
+	}
+	return &Server{NewEngine: factory, Logger: logger, sessions: make(map[string]*server.Session)}
+}
+
+// NewGRPCServer creates a grpc.Server with the service registered and
+// token authentication enabled if configured
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.unaryAuth),
+		grpc.ChainStreamInterceptor(s.streamAuth),
+	)
+	g := grpc.NewServer(opts...)
+	codexv1.RegisterCodexServiceServer(g, s)
+	return g
+}
+
+// Close cancels all sessions
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.sessions {
+		sess.Close()
+		delete(s.sessions, id)
+	}
+}
+
+// StartSession creates a new agent session
+func (s *Server) StartSession(ctx context.Context, req *codexv1.StartSessionRequest) (*codexv1.StartSessionResponse, error) {
+	eng, err := s.NewEngine()
+	if err != nil {
+		s.Logger.Log("[ERROR] RPC: failed to create engine: %v", err)
+		return nil, status.Errorf(codes.Internal, "failed to create session: %v", err)
+	}
+
+	sess := server.NewSession(uuid.New().String(), eng)
+	s.mu.Lock()
+	s.sessions[sess.ID] = sess
+	s.mu.Unlock()
+
+	s.Logger.Log("RPC: created session %s", sess.ID)
+	return &codexv1.StartSessionResponse{SessionId: sess.ID}, nil
+}
+
+// StreamMessages sends a message and streams events until the run ends
+func (s *Server) StreamMessages(req *codexv1.StreamMessagesRequest, stream codexv1.CodexService_StreamMessagesServer) error {
+	sess, err := s.session(req.GetSessionId())
+	if err != nil {
+		return err
+	}
+	if req.GetContent() == "" {
+		return status.Error(codes.InvalidArgument, "content is required")
+	}
+
+	// Subscribe before starting the run so no event is missed
+	_, events := sess.Subscribe(math.MaxInt64)
+	defer sess.Unsubscribe(events)
+
+	if err := sess.Send(req.GetContent()); err != nil {
+		if errors.Is(err, server.ErrSessionBusy) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return status.Error(codes.Aborted, "event stream closed")
+			}
+			if err := stream.Send(toProto(ev)); err != nil {
+				return err
+			}
+			if ev.Type == server.EventRunComplete || ev.Type == server.EventError {
+				return nil
+			}
+		case <-stream.Context().Done():
+			// The client went away; stop the run rather than leave it waiting on approvals
+			sess.Cancel()
+			return stream.Context().Err()
+		}
+	}
+}
+
+// SubmitToolResult approves or denies a tool call awaiting approval
+func (s *Server) SubmitToolResult(ctx context.Context, req *codexv1.SubmitToolResultRequest) (*codexv1.SubmitToolResultResponse, error) {
+	sess, err := s.session(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	if err := sess.Decide(req.GetCallId(), req.GetApproved()); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &codexv1.SubmitToolResultResponse{}, nil
+}
+
+// Cancel stops the session's active run
+func (s *Server) Cancel(ctx context.Context, req *codexv1.CancelRequest) (*codexv1.CancelResponse, error) {
+	sess, err := s.session(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	return &codexv1.CancelResponse{Cancelled: sess.Cancel()}, nil
+}
+
+// session looks up a session by ID
+func (s *Server) session(id string) (*server.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session %q not found", id)
+	}
+	return sess, nil
+}
+
+// authorize checks the bearer token in the request metadata
+func (s *Server) authorize(ctx context.Context) error {
+	if s.Token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(value), []byte("Bearer "+s.Token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// toProto converts a session event to its protobuf form
+func toProto(ev server.Event) *codexv1.Event {
+	out := &codexv1.Event{Id: ev.ID, Time: timestamppb.New(ev.Time)}
+	switch data := ev.Data.(type) {
+	case server.MessageData:
+		out.Payload = &codexv1.Event_Message{Message: &codexv1.AssistantMessage{Content: data.Content}}
+	case server.ToolCallData:
+		out.Payload = &codexv1.Event_ToolCall{ToolCall: &codexv1.ToolCall{Call: callToProto(data.Call)}}
+	case server.ApprovalData:
+		out.Payload = &codexv1.Event_ApprovalRequired{ApprovalRequired: &codexv1.ApprovalRequired{
+			Call: callToProto(data.Call),
+			Args: data.Args,
+		}}
+	case server.ToolResultData:
+		out.Payload = &codexv1.Event_ToolResult{ToolResult: &codexv1.ToolResult{
+			Call:       callToProto(data.Call),
+			Output:     data.Output,
+			Success:    data.Success,
+			Suspicious: data.Suspicious,
+			Reasons:    data.Reasons,
+		}}
+	case server.ToolDeniedData:
+		out.Payload = &codexv1.Event_ToolDenied{ToolDenied: &codexv1.ToolDenied{Call: callToProto(data.Call), Reason: data.Reason}}
+	case *engine.Outcome:
+		out.Payload = &codexv1.Event_RunComplete{RunComplete: &codexv1.RunComplete{
+			FinalMessage: data.FinalMessage,
+			ToolCalls:    int32(data.ToolCalls),
+			ToolFailures: int32(data.ToolFailures),
+			Denied:       int32(data.Denied),
+		}}
+	case server.ErrorData:
+		out.Payload = &codexv1.Event_Error{Error: &codexv1.Error{Message: data.Error}}
+	}
+	return out
+}
+
+func callToProto(call agent.FunctionCall) *codexv1.FunctionCall {
+	return &codexv1.FunctionCall{Id: call.ID, Name: call.Name, Arguments: call.Arguments}
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	codexv1 "github.com/epuerta/codex-go/api/codex/v1"
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/sandbox"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// shellAgent requests one shell command, then replies with its output
+type shellAgent struct {
+	agent.Agent
+	handler agent.ResponseHandler
+}
+
+func (a *shellAgent) emit(item agent.ResponseItem) {
+	data, _ := json.Marshal(item)
+	a.handler(string(data))
+}
+
+func (a *shellAgent) SendMessage(ctx context.Context, messages []agent.Message, handler agent.ResponseHandler) (bool, error) {
+	a.handler = handler
+	a.emit(agent.ResponseItem{Type: "function_call", FunctionCall: &agent.FunctionCall{
+		ID: "call_1", Name: "shell", Arguments: `{"command":"echo hello"}`,
+	}})
+	return true, nil
+}
+
+func (a *shellAgent) SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) error {
+	a.emit(agent.ResponseItem{Type: "message", Message: &agent.Message{Role: "assistant", Content: "ran: " + output}})
+	return nil
+}
+
+func (a *shellAgent) Cancel() {}
+
+func TestCodexService(t *testing.T) {
+	cfg := &config.Config{ApprovalMode: config.Suggest, CWD: t.TempDir()}
+	srv := New(func() (*engine.Engine, error) {
+		exec := executor.New(cfg, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+		return engine.New(&shellAgent{}, exec, cfg, nil), nil
+	}, nil)
+	srv.Token = "secret"
+	defer srv.Close()
+
+	lis := bufconn.Listen(1 << 20)
+	g := srv.NewGRPCServer()
+	go g.Serve(lis)
+	defer g.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	client := codexv1.NewCodexServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := client.StartSession(ctx, &codexv1.StartSessionRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without token, got %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	started, err := client.StartSession(ctx, &codexv1.StartSessionRequest{})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+
+	stream, err := client.StreamMessages(ctx, &codexv1.StreamMessagesRequest{SessionId: started.SessionId, Content: "say hello"})
+	if err != nil {
+		t.Fatalf("StreamMessages failed: %v", err)
+	}
+
+	var final *codexv1.RunComplete
+	for final == nil {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Stream ended early: %v", err)
+		}
+		switch p := ev.Payload.(type) {
+		case *codexv1.Event_ApprovalRequired:
+			if p.ApprovalRequired.Args != "echo hello" {
+				t.Errorf("Unexpected approval args: %q", p.ApprovalRequired.Args)
+			}
+			_, err := client.SubmitToolResult(ctx, &codexv1.SubmitToolResultRequest{
+				SessionId: started.SessionId,
+				CallId:    p.ApprovalRequired.Call.Id,
+				Approved:  true,
+			})
+			if err != nil {
+				t.Fatalf("SubmitToolResult failed: %v", err)
+			}
+		case *codexv1.Event_ToolResult:
+			if !p.ToolResult.Success {
+				t.Errorf("Expected tool to succeed: %+v", p.ToolResult)
+			}
+		case *codexv1.Event_RunComplete:
+			final = p.RunComplete
+		case *codexv1.Event_Error:
+			t.Fatalf("Run failed: %s", p.Error.Message)
+		}
+	}
+	if !strings.Contains(final.FinalMessage, "hello") || final.ToolCalls != 1 {
+		t.Errorf("Unexpected run outcome: %+v", final)
+	}
+
+	if _, err := client.Cancel(ctx, &codexv1.CancelRequest{SessionId: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for unknown session, got %v", err)
+	}
+}
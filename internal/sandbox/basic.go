@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"runtime"
 	"time"
 )
 
@@ -33,8 +34,19 @@ func (s *BasicSandbox) IsAvailable() bool {
 func (s *BasicSandbox) Execute(ctx context.Context, opts SandboxOptions) (*CommandResult, error) {
 	startTime := time.Now()
 
+	// Apply timeout if specified
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
 	// Build the command
-	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", opts.Command)
+	cmd, err := ShellCommand(ctx, opts.Shell, opts.Command)
+	if err != nil {
+		return nil, err
+	}
+	killProcessGroup(cmd)
 	cmd.Dir = opts.WorkingDir
 
 	// Set up restricted environment
@@ -46,6 +58,9 @@ func (s *BasicSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 		"LANG=" + os.Getenv("LANG"),
 		"CODEX_SANDBOX=1", // Mark that we're running in a sandbox
 	}
+	if runtime.GOOS == "windows" {
+		env = windowsEnv()
+	}
 
 	// Add custom environment variables
 	if opts.Env != nil {
@@ -56,6 +71,10 @@ func (s *BasicSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 
 	cmd.Env = env
 
+	if opts.Interactive {
+		return runInteractive(cmd, opts, startTime)
+	}
+
 	// Set up stdin, stdout, stderr
 	if opts.Stdin != nil {
 		cmd.Stdin = opts.Stdin
@@ -74,23 +93,8 @@ func (s *BasicSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 		cmd.Stderr = &stderr
 	}
 
-	// Apply timeout if specified
-	if opts.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
-		defer cancel()
-		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", opts.Command)
-		cmd.Dir = opts.WorkingDir
-		cmd.Env = env
-		if opts.Stdin != nil {
-			cmd.Stdin = opts.Stdin
-		}
-		cmd.Stdout = cmd.Stdout
-		cmd.Stderr = cmd.Stderr
-	}
-
 	// Execute the command
-	err := cmd.Run()
+	err = cmd.Run()
 	duration := time.Since(startTime)
 
 	// Build the result
@@ -114,3 +118,24 @@ func (s *BasicSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 
 	return result, nil
 }
+
+// windowsVars are the variables Windows programs expect, kept from the
+// environment of this process. Most tools fail to start without SystemRoot,
+// and PATH holds the toolchains rather than fixed Unix directories.
+var windowsVars = []string{
+	"PATH", "PATHEXT", "SystemRoot", "SystemDrive", "windir", "COMSPEC", "TEMP", "TMP",
+	"USERPROFILE", "USERNAME", "HOMEDRIVE", "HOMEPATH", "APPDATA", "LOCALAPPDATA", "ProgramData",
+	"ProgramFiles", "ProgramFiles(x86)", "ProgramW6432", "CommonProgramFiles", "PSModulePath",
+	"NUMBER_OF_PROCESSORS", "PROCESSOR_ARCHITECTURE", "OS",
+}
+
+// windowsEnv returns the environment of commands on Windows
+func windowsEnv() []string {
+	env := []string{"CODEX_SANDBOX=1"}
+	for _, name := range windowsVars {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
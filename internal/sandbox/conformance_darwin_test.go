@@ -0,0 +1,12 @@
+//go:build darwin
+
+package sandbox
+
+import "testing"
+
+func TestConformance(t *testing.T) {
+	requireConformance(t, "darwin", []conformanceCase{
+		{sandbox: NewMacOSSandbox(), confinesWrites: true, blocksNetwork: true},
+		{sandbox: NewBasicSandbox()},
+	})
+}
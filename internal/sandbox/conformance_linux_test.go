@@ -0,0 +1,12 @@
+//go:build linux
+
+package sandbox
+
+import "testing"
+
+func TestConformance(t *testing.T) {
+	requireConformance(t, "linux", []conformanceCase{
+		{sandbox: NewLinuxSandbox()},
+		{sandbox: NewBasicSandbox()},
+	})
+}
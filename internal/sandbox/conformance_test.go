@@ -0,0 +1,230 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the conformance snapshots in testdata")
+
+// dialEnv makes the test binary dial the address it holds and exit, so the
+// conformance suite can try the network from inside a sandbox without
+// depending on curl or nc being installed
+const dialEnv = "CODEX_CONFORMANCE_DIAL"
+
+func TestMain(m *testing.M) {
+	if addr := os.Getenv(dialEnv); addr != "" {
+		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+		if err != nil {
+			fmt.Println("blocked")
+			os.Exit(0)
+		}
+		conn.Close()
+		fmt.Println("connected")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// conformanceOutputSize is the output each sandbox must return intact
+const conformanceOutputSize = 1 << 20
+
+// conformanceCase is a sandbox to check and the confinement it claims,
+// which the checks assert rather than only record
+type conformanceCase struct {
+	sandbox Sandbox
+
+	// confinesWrites is set for sandboxes that keep writes to the working
+	// and temporary directories
+	confinesWrites bool
+	// blocksNetwork is set for sandboxes that block the network unless
+	// AllowNetwork is set
+	blocksNetwork bool
+}
+
+// requireConformance runs the conformance checks on each sandbox and
+// compares what they observed with testdata/conformance_<os>.golden, or
+// writes the file when the tests run with -update. A change in how any
+// sandbox behaves, such as one starting to block the network, shows up as
+// a snapshot diff to review. Without a snapshot for the OS, only the
+// assertions are made.
+func requireConformance(t *testing.T, goos string, cases []conformanceCase) {
+	var b strings.Builder
+	for _, c := range cases {
+		fmt.Fprintf(&b, "== %s\n", c.sandbox.Name())
+		if !c.sandbox.IsAvailable() {
+			b.WriteString("not available\n")
+			continue
+		}
+		t.Run(strings.ReplaceAll(c.sandbox.Name(), " ", "_"), func(t *testing.T) {
+			b.WriteString(checkConformance(t, c))
+		})
+	}
+
+	path := filepath.Join("testdata", "conformance_"+goos+".golden")
+	got := b.String()
+	if _, err := os.Stat(path); os.IsNotExist(err) && !*update {
+		t.Logf("No snapshot in %s (run the test with -update on %s to record one)", path, goos)
+		return
+	}
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run the test with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("The sandboxes behave differently from %s (run the test with -update to accept it)\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
+
+// checkConformance observes how the sandbox of c confines a command and
+// returns a line per behavior. Behaviors every sandbox must have, and those
+// c claims, are also asserted.
+func checkConformance(t *testing.T, c conformanceCase) string {
+	sb := c.sandbox
+	var b strings.Builder
+	// The sandbox gets a temporary directory of its own, so that a
+	// directory outside both it and the working directory can be made
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workDir, outside := filepath.Join(base, "work"), filepath.Join(base, "outside")
+	for _, dir := range []string{workDir, outside, filepath.Join(base, "tmp")} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("TMPDIR", filepath.Join(base, "tmp"))
+	run := func(opts SandboxOptions) *CommandResult {
+		t.Helper()
+		if opts.WorkingDir == "" {
+			opts.WorkingDir = workDir
+		}
+		res, err := sb.Execute(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Execute(%q): %v", opts.Command, err)
+		}
+		return res
+	}
+
+	// Working directory
+	res := run(SandboxOptions{Command: "pwd -P"})
+	if !res.Success || strings.TrimSpace(res.Stdout) != workDir {
+		t.Errorf("Expected the command to run in %s, got %q (%v)", workDir, res.Stdout, res.Error)
+	}
+	b.WriteString("runs in the working directory: yes\n")
+	res = run(SandboxOptions{Command: "echo inside > inside.txt"})
+	if _, err := os.Stat(filepath.Join(workDir, "inside.txt")); !res.Success || err != nil {
+		t.Errorf("Expected writes to the working directory to work: %v %s", res.Error, res.Stderr)
+	}
+	b.WriteString("writes in the working directory: allowed\n")
+
+	// Writes outside the working directory, and outside the temporary
+	// directory some sandboxes allow too
+	run(SandboxOptions{Command: fmt.Sprintf("echo escaped > %q", filepath.Join(outside, "escaped.txt"))})
+	if _, err := os.Stat(filepath.Join(outside, "escaped.txt")); err == nil {
+		if c.confinesWrites {
+			t.Error("Expected the write outside the working directory to be denied")
+		}
+		b.WriteString("writes outside the working directory: allowed\n")
+	} else {
+		b.WriteString("writes outside the working directory: denied\n")
+	}
+
+	// Network, to a listener of the test so no internet access is needed
+	network := checkNetwork(t, run, false)
+	if c.blocksNetwork {
+		if network != "blocked" {
+			t.Error("Expected the network to be blocked")
+		}
+		if checkNetwork(t, run, true) != "allowed" {
+			t.Error("Expected AllowNetwork to let the command connect")
+		}
+	}
+	b.WriteString("network: " + network + "\n")
+
+	// Timeouts stop the command and the processes it started
+	marker := filepath.Join(workDir, "late.txt")
+	start := time.Now()
+	res = run(SandboxOptions{Command: fmt.Sprintf("(sleep 1; echo late > %q) & sleep 30", marker), Timeout: 200 * time.Millisecond})
+	elapsed := time.Since(start)
+	if res.Success {
+		t.Error("Expected a command that ran out of time to fail")
+	}
+	if elapsed > waitDelay+time.Second {
+		t.Errorf("Expected the timeout to stop the command, took %s", elapsed)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the timeout to kill the command's child processes")
+	}
+	fmt.Fprintf(&b, "timeout: stopped with its child processes, exit code %d\n", res.ExitCode)
+
+	// Output is returned whole, and streamed, for the caller to truncate
+	var streamed bytes.Buffer
+	res = run(SandboxOptions{Command: fmt.Sprintf("head -c %d /dev/zero | tr '\\0' x", conformanceOutputSize), Stdout: &streamed})
+	if len(res.Stdout) != conformanceOutputSize || streamed.Len() != conformanceOutputSize {
+		t.Errorf("Expected %d bytes of output, got %d (%d streamed)", conformanceOutputSize, len(res.Stdout), streamed.Len())
+	}
+	truncated := TruncateOutput(res.Stdout, 64*1024)
+	if len(truncated) > 64*1024+len("\n...[truncated]...\n") || !strings.Contains(truncated, "[truncated]") {
+		t.Errorf("Unexpected truncated output of %d bytes", len(truncated))
+	}
+	fmt.Fprintf(&b, "output: %d bytes returned and streamed; TruncateOutput to 64 KiB keeps %d bytes\n", len(res.Stdout), len(truncated))
+	return b.String()
+}
+
+// checkNetwork reports whether a command in the sandbox can connect to a
+// local listener, with the network allowed or not
+func checkNetwork(t *testing.T, run func(SandboxOptions) *CommandResult, allow bool) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := run(SandboxOptions{Command: fmt.Sprintf("%q", self), Env: map[string]string{dialEnv: ln.Addr().String()}, AllowNetwork: allow})
+	switch out := strings.TrimSpace(res.Stdout); out {
+	case "connected":
+		return "allowed"
+	case "blocked":
+		return "blocked"
+	default:
+		// The dialer did not run, so the check says nothing of the network
+		t.Fatalf("Unexpected output of the network check: %q (%v, stderr %q)", out, res.Error, res.Stderr)
+		return ""
+	}
+}
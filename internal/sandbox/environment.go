@@ -0,0 +1,119 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// snapshotTimeout bounds how long probing the toolchains may take
+const snapshotTimeout = 10 * time.Second
+
+// Environment describes where a command ran, so that it can be reproduced
+// later: the sandbox, the working directory, the PATH the sandbox resolves
+// commands with and the versions of the toolchains found on it
+type Environment struct {
+	Sandbox    string `json:"sandbox"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	WorkingDir string `json:"working_dir"`
+	Path       string `json:"path"`
+
+	// Tools maps each toolchain found on Path to its version
+	Tools map[string]string `json:"tools,omitempty"`
+}
+
+// toolVersions are the toolchains recorded in snapshots and the arguments
+// that print their versions
+var toolVersions = map[string]string{
+	"go":      "version",
+	"node":    "--version",
+	"npm":     "--version",
+	"python3": "--version",
+	"pip3":    "--version",
+	"cargo":   "--version",
+	"rustc":   "--version",
+	"java":    "-version",
+	"ruby":    "--version",
+	"make":    "--version",
+	"git":     "--version",
+}
+
+// Snapshot probes the environment commands run in with sb in workingDir.
+// It runs a short script in the sandbox itself, so the PATH and versions are
+// the ones commands see rather than those of this process.
+func Snapshot(ctx context.Context, sb Sandbox, workingDir string) (*Environment, error) {
+	result, err := sb.Execute(ctx, SandboxOptions{
+		Command:    snapshotScript(),
+		WorkingDir: workingDir,
+		Timeout:    snapshotTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe the command environment: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to probe the command environment: exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	env := &Environment{
+		Sandbox:    sb.Name(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		WorkingDir: workingDir,
+		Tools:      make(map[string]string),
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSuffix(line, "\r"), "\t")
+		if !ok {
+			continue
+		}
+		if key == "PATH" {
+			env.Path = value
+		} else if value != "" {
+			env.Tools[key] = value
+		}
+	}
+	return env, nil
+}
+
+// snapshotScript prints the PATH and, for each toolchain on it, the first
+// line of its version, as tab-separated name and value lines
+func snapshotScript() string {
+	names := make([]string, 0, len(toolVersions))
+	for name := range toolVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if runtime.GOOS == "windows" {
+		return powershellSnapshotScript(names)
+	}
+
+	var script strings.Builder
+	script.WriteString(`printf 'PATH\t%s\n' "$PATH"` + "\n")
+	for _, name := range names {
+		fmt.Fprintf(&script, "if command -v %[1]s >/dev/null 2>&1; then printf '%[1]s\\t%%s\\n' \"$(%[1]s %[2]s 2>&1 | head -n 1)\"; fi\n", name, toolVersions[name])
+	}
+	return script.String()
+}
+
+// powershellSnapshotScript is snapshotScript for Windows. It runs in Windows
+// PowerShell, which every Windows has, whatever the shell; the script is
+// encoded so that no shell can mangle it.
+func powershellSnapshotScript(names []string) string {
+	var script strings.Builder
+	script.WriteString("Write-Output \"PATH`t$env:PATH\"\n")
+	for _, name := range names {
+		fmt.Fprintf(&script, "if (Get-Command %[1]s -CommandType Application -ErrorAction SilentlyContinue) { $v = & %[1]s %[2]s 2>&1 | Select-Object -First 1; Write-Output \"%[1]s`t$v\" }\n", name, toolVersions[name])
+	}
+	utf16le := make([]byte, 0, 2*script.Len())
+	for _, r := range utf16.Encode([]rune(script.String())) {
+		utf16le = binary.LittleEndian.AppendUint16(utf16le, r)
+	}
+	return "powershell -NoProfile -NonInteractive -EncodedCommand " + base64.StdEncoding.EncodeToString(utf16le)
+}
@@ -6,6 +6,10 @@ import (
 	"time"
 )
 
+// waitDelay bounds how long output is still read once a command has been
+// killed, in case processes it started keep the output open
+const waitDelay = 2 * time.Second
+
 // CommandResult represents the result of executing a command
 type CommandResult struct {
 	Stdout     string
@@ -23,6 +27,10 @@ type SandboxOptions struct {
 	// Command to execute
 	Command string
 
+	// Shell runs the command: one of ShellNames, or the default of the
+	// platform if empty
+	Shell string
+
 	// Working directory
 	WorkingDir string
 
@@ -44,6 +52,11 @@ type SandboxOptions struct {
 	// Capture stdout and stderr
 	Stdout io.Writer
 	Stderr io.Writer
+
+	// Interactive runs the command on a pseudo-terminal, for commands that
+	// prompt or open an editor. Stdin feeds the terminal and Stdout receives
+	// everything the command prints; Stderr is not used.
+	Interactive bool
 }
 
 // Sandbox defines the interface for sandboxed command execution
@@ -33,8 +33,19 @@ func (s *LinuxSandbox) IsAvailable() bool {
 func (s *LinuxSandbox) Execute(ctx context.Context, opts SandboxOptions) (*CommandResult, error) {
 	startTime := time.Now()
 
+	// Apply timeout if specified
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
 	// Build the command
-	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", opts.Command)
+	cmd, err := ShellCommand(ctx, opts.Shell, opts.Command)
+	if err != nil {
+		return nil, err
+	}
+	killProcessGroup(cmd)
 	cmd.Dir = opts.WorkingDir
 
 	// Set up restricted environment
@@ -55,6 +66,10 @@ func (s *LinuxSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 
 	cmd.Env = env
 
+	if opts.Interactive {
+		return runInteractive(cmd, opts, startTime)
+	}
+
 	// Set up stdin, stdout, stderr
 	if opts.Stdin != nil {
 		cmd.Stdin = opts.Stdin
@@ -74,7 +89,7 @@ func (s *LinuxSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 	}
 
 	// Execute the command
-	err := cmd.Run()
+	err = cmd.Run()
 	duration := time.Since(startTime)
 
 	// Build the result
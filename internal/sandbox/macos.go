@@ -34,6 +34,13 @@ func (s *MacOSSandbox) IsAvailable() bool {
 func (s *MacOSSandbox) Execute(ctx context.Context, opts SandboxOptions) (*CommandResult, error) {
 	startTime := time.Now()
 
+	// Apply timeout if specified
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
 	// Create the sandbox profile
 	profile, err := s.createSandboxProfile(opts)
 	if err != nil {
@@ -55,7 +62,12 @@ func (s *MacOSSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 	}
 
 	// Build the command
-	cmd := exec.CommandContext(ctx, "sandbox-exec", "-f", profileFile.Name(), "/bin/sh", "-c", opts.Command)
+	shell, err := FindShell(opts.Shell)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "sandbox-exec", append([]string{"-f", profileFile.Name()}, shell.Argv(opts.Command)...)...)
+	killProcessGroup(cmd)
 	cmd.Dir = opts.WorkingDir
 
 	// Set up environment
@@ -67,6 +79,10 @@ func (s *MacOSSandbox) Execute(ctx context.Context, opts SandboxOptions) (*Comma
 		cmd.Env = env
 	}
 
+	if opts.Interactive {
+		return runInteractive(cmd, opts, startTime)
+	}
+
 	// Set up stdin, stdout, stderr
 	if opts.Stdin != nil {
 		cmd.Stdin = opts.Stdin
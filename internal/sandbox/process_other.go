@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package sandbox
+
+import "os/exec"
+
+// killProcessGroup bounds how long a cancelled command may keep its output
+// open; without process groups only the shell itself is killed
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = waitDelay
+}
@@ -0,0 +1,18 @@
+package sandbox
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// killProcessGroup makes cancelling cmd kill the processes it started as
+// well, with taskkill /T, as Windows has no process groups to signal
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = waitDelay
+}
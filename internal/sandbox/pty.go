@@ -0,0 +1,85 @@
+package sandbox
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/cancelreader"
+)
+
+// runInteractive runs cmd on a new pseudo-terminal connected to opts.Stdin
+// and opts.Stdout, for commands that need a terminal, such as `npm init` or
+// `git rebase -i`. It stands in for cmd.Run when opts.Interactive is set.
+// What the command printed is returned as Stdout, without terminal escapes.
+func runInteractive(cmd *exec.Cmd, opts SandboxOptions, startTime time.Time) (*CommandResult, error) {
+	master, err := startInPty(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer master.Close()
+
+	if f, ok := opts.Stdout.(*os.File); ok && term.IsTerminal(f.Fd()) {
+		if width, height, err := term.GetSize(f.Fd()); err == nil {
+			setPtySize(master, width, height)
+		}
+	}
+	// Keys go to the command as they are typed; the command's terminal does
+	// the echoing and line editing
+	if f, ok := opts.Stdin.(*os.File); ok && term.IsTerminal(f.Fd()) {
+		if state, err := term.MakeRaw(f.Fd()); err == nil {
+			defer term.Restore(f.Fd(), state)
+		}
+	}
+	if opts.Stdin != nil {
+		// The copy must stop with the command, or it would swallow the next
+		// key meant for whoever reads the input afterwards
+		if input, err := cancelreader.NewReader(opts.Stdin); err == nil {
+			defer input.Close()
+			defer input.Cancel()
+			go io.Copy(master, input)
+		}
+	}
+
+	var output bytes.Buffer
+	out := io.Writer(&output)
+	if opts.Stdout != nil {
+		out = io.MultiWriter(&output, opts.Stdout)
+	}
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(out, master) // Ends when every process has closed the terminal
+		close(copied)
+	}()
+
+	err = cmd.Wait()
+	select {
+	case <-copied:
+	case <-time.After(waitDelay):
+		// Processes the command left behind still hold the terminal
+		master.Close()
+		<-copied
+	}
+
+	result := &CommandResult{
+		Stdout:     ansi.Strip(strings.ReplaceAll(output.String(), "\r\n", "\n")),
+		Duration:   time.Since(startTime),
+		Command:    opts.Command,
+		WorkingDir: opts.WorkingDir,
+		Success:    err == nil,
+	}
+	if err != nil {
+		result.Error = err
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// startInPty fails: interactive commands need a Unix terminal
+func startInPty(cmd *exec.Cmd) (*os.File, error) {
+	return nil, fmt.Errorf("interactive commands are not supported on %s", runtime.GOOS)
+}
+
+// setPtySize does nothing without pseudo-terminals
+func setPtySize(master *os.File, width, height int) {}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// startInPty starts cmd with a new pseudo-terminal as its controlling
+// terminal and standard streams, and returns the terminal's master side
+func startInPty(cmd *exec.Cmd) (*os.File, error) {
+	// A new session is also a new process group, so cancelling still kills
+	// everything the command started; the session replaces the process
+	// group killProcessGroup asks for, which cannot be combined with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	master, err := pty.Start(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the command on a terminal: %w", err)
+	}
+	return master, nil
+}
+
+// setPtySize sets the size of the terminal whose master side is master
+func setPtySize(master *os.File, width, height int) {
+	pty.Setsize(master, &pty.Winsize{Rows: uint16(height), Cols: uint16(width)})
+}
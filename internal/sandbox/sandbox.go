@@ -62,6 +62,8 @@ func CreateExecutor() (Executor, error) {
 		return NewMacOSExecutor(), nil
 	case "linux":
 		return NewLinuxExecutor(), nil
+	case "windows":
+		return &BasicExecutor{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
 	}
@@ -218,7 +220,10 @@ func executeUnsandboxedCommand(cmd string) (*CommandResult, error) {
 	startTime := time.Now()
 
 	// Prepare the command for execution
-	execCmd := exec.Command("sh", "-c", cmd)
+	execCmd, err := ShellCommand(context.Background(), "", cmd)
+	if err != nil {
+		return nil, err
+	}
 
 	// Set up pipes for stdout and stderr
 	stdout, err := execCmd.StdoutPipe()
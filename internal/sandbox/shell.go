@@ -0,0 +1,104 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// shellArgs are the shells sandbox.shell may name and the arguments that
+// come before the command line
+var shellArgs = map[string][]string{
+	"sh":         {"-c"},
+	"bash":       {"-c"},
+	"zsh":        {"-c"},
+	"cmd":        {"/d", "/s", "/c"},
+	"powershell": {"-NoProfile", "-NonInteractive", "-Command"},
+	"pwsh":       {"-NoProfile", "-NonInteractive", "-Command"},
+}
+
+// Shell runs command lines
+type Shell struct {
+	Name string   // One of ShellNames
+	Path string   // The program
+	Args []string // Arguments before the command line
+}
+
+// ShellNames lists the shells commands may run with
+func ShellNames() []string {
+	names := make([]string, 0, len(shellArgs))
+	for name := range shellArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckShell reports an error unless name is empty or one of ShellNames.
+// Only these are accepted, so a config cannot make an arbitrary program the
+// shell.
+func CheckShell(name string) error {
+	if _, ok := shellArgs[name]; ok || name == "" {
+		return nil
+	}
+	return fmt.Errorf("unknown shell %q (want one of %s)", name, strings.Join(ShellNames(), ", "))
+}
+
+// FindShell returns the named shell, found on the PATH. An empty name is
+// the default of the platform: /bin/sh on Unix, and on Windows PowerShell
+// (pwsh, then Windows PowerShell), or cmd.exe when neither is installed.
+func FindShell(name string) (Shell, error) {
+	if err := CheckShell(name); err != nil {
+		return Shell{}, err
+	}
+	if name == "" {
+		if runtime.GOOS != "windows" {
+			return Shell{Name: "sh", Path: "/bin/sh", Args: shellArgs["sh"]}, nil
+		}
+		for _, candidate := range []string{"pwsh", "powershell"} {
+			if shell, err := FindShell(candidate); err == nil {
+				return shell, nil
+			}
+		}
+		name = "cmd"
+	}
+
+	if name == "cmd" {
+		if comspec := os.Getenv("COMSPEC"); comspec != "" {
+			return Shell{Name: name, Path: comspec, Args: shellArgs[name]}, nil
+		}
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return Shell{}, fmt.Errorf("shell %s not found: %w", name, err)
+	}
+	return Shell{Name: name, Path: path, Args: shellArgs[name]}, nil
+}
+
+// Argv returns the arguments running line with the shell, the program first
+func (s Shell) Argv(line string) []string {
+	argv := append([]string{s.Path}, s.Args...)
+	return append(argv, line)
+}
+
+// Command returns the command running line with the shell
+func (s Shell) Command(ctx context.Context, line string) *exec.Cmd {
+	argv := s.Argv(line)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	setCommandLine(cmd, s, line)
+	return cmd
+}
+
+// ShellCommand returns the command running line with the named shell, or
+// the default one when name is empty
+func ShellCommand(ctx context.Context, name, line string) (*exec.Cmd, error) {
+	shell, err := FindShell(name)
+	if err != nil {
+		return nil, err
+	}
+	return shell.Command(ctx, line), nil
+}
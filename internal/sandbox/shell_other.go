@@ -0,0 +1,8 @@
+//go:build !windows
+
+package sandbox
+
+import "os/exec"
+
+// setCommandLine does nothing: arguments reach Unix programs as they are
+func setCommandLine(cmd *exec.Cmd, s Shell, line string) {}
@@ -0,0 +1,59 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestCheckShell(t *testing.T) {
+	for _, name := range []string{"", "sh", "bash", "cmd", "powershell", "pwsh"} {
+		if err := CheckShell(name); err != nil {
+			t.Errorf("CheckShell(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"./evil.exe", "/bin/sh", "python"} {
+		if err := CheckShell(name); err == nil {
+			t.Errorf("Expected CheckShell(%q) to fail", name)
+		}
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	cmd, err := ShellCommand(context.Background(), "", "echo hello")
+	if err != nil {
+		t.Fatalf("No default shell: %v", err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Running echo failed: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "hello" {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestPowershellSnapshotScript(t *testing.T) {
+	command := powershellSnapshotScript([]string{"git", "go"})
+	encoded, ok := strings.CutPrefix(command, "powershell -NoProfile -NonInteractive -EncodedCommand ")
+	if !ok {
+		t.Fatalf("Unexpected command %q", command)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	script := string(utf16.Decode(units))
+	for _, want := range []string{"Write-Output \"PATH`t$env:PATH\"", "Get-Command git ", "& go version 2>&1"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Expected the script to contain %q, got:\n%s", want, script)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package sandbox
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setCommandLine passes the line to cmd.exe verbatim. Go quotes arguments
+// for programs parsing them like the C runtime, which cmd.exe does not: with
+// /s it strips the outer quotes and runs the rest as typed.
+func setCommandLine(cmd *exec.Cmd, s Shell, line string) {
+	if s.Name != "cmd" {
+		return
+	}
+	cmdLine := syscall.EscapeArg(s.Path)
+	for _, arg := range s.Args {
+		cmdLine += " " + arg
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: cmdLine + ` "` + line + `"`}
+}
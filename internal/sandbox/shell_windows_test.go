@@ -0,0 +1,31 @@
+package sandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCmdQuoting(t *testing.T) {
+	cmd, err := ShellCommand(context.Background(), "cmd", `echo "a  b" & echo c`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("cmd.exe failed: %v", err)
+	}
+	if got := strings.ReplaceAll(string(out), "\r\n", "\n"); got != "\"a  b\" \nc\n" {
+		t.Errorf("Expected cmd.exe to see the line as typed, got %q", got)
+	}
+}
+
+func TestBasicSandboxWindows(t *testing.T) {
+	result, err := NewBasicSandbox().Execute(context.Background(), SandboxOptions{Command: "Write-Output $env:CODEX_SANDBOX", Shell: "powershell", WorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || strings.TrimSpace(result.Stdout) != "1" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,336 @@
+package scripts
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/functions"
+	"gopkg.in/yaml.v3"
+)
+
+// ToolName is the function the model calls to run a project script
+const ToolName = "run_project_script"
+
+// Limits on the extra arguments passed to a script
+const (
+	maxArgs      = 16
+	maxArgLength = 256
+)
+
+// Script is a named task defined by the project's build files
+type Script struct {
+	Name        string `json:"name"`
+	Source      string `json:"source"` // "make", "npm" (or the detected package manager), "task", or "tool" for custom tools from the config
+	File        string `json:"file"`   // Build file that defines it, relative to the project root; empty for custom tools
+	Command     string `json:"command"`
+	Description string `json:"description,omitempty"`
+}
+
+// QualifiedName is the name with its source, e.g. "make:test"
+func (s Script) QualifiedName() string {
+	return s.Source + ":" + s.Name
+}
+
+// CommandLine returns the shell command running the script with args.
+// Make targets only take VAR=value arguments, so a call cannot run other
+// targets; the arguments of other scripts are passed through quoted.
+func (s Script) CommandLine(args []string) (string, error) {
+	if len(args) > maxArgs {
+		return "", fmt.Errorf("too many arguments for %s: %d (at most %d)", s.Name, len(args), maxArgs)
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if len(arg) > maxArgLength {
+			return "", fmt.Errorf("argument %d for %s is longer than %d bytes", i+1, s.Name, maxArgLength)
+		}
+		if strings.ContainsAny(arg, "\x00\n\r") {
+			return "", fmt.Errorf("argument %d for %s contains a control character", i+1, s.Name)
+		}
+		if s.Source == "make" && !makeVarPattern.MatchString(arg) {
+			return "", fmt.Errorf("make targets only accept VAR=value arguments, got %q", arg)
+		}
+		quoted[i] = shellQuote(arg)
+	}
+
+	if len(args) == 0 {
+		return s.Command, nil
+	}
+	sep := " -- "
+	if s.Source == "make" {
+		sep = " "
+	}
+	return s.Command + sep + strings.Join(quoted, " "), nil
+}
+
+// makeVarPattern matches a make variable assignment argument
+var makeVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// shellQuote quotes s for a POSIX shell unless it is plainly safe
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./:,+@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Detect finds the scripts defined by the Makefile, package.json and
+// Taskfile in dir. Missing files are skipped; files that cannot be parsed
+// are reported in the error alongside the scripts that were found.
+func Detect(dir string) ([]Script, error) {
+	var found []Script
+	var errs []error
+	for _, detect := range []func(string) ([]Script, error){detectMake, detectPackageJSON, detectTaskfile} {
+		list, err := detect(dir)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		found = append(found, list...)
+	}
+	return found, errors.Join(errs...)
+}
+
+// Find returns the script called name, which may be qualified with its
+// source ("npm:test") when several build files define the same name
+func Find(list []Script, name string) (Script, error) {
+	var matches []Script
+	for _, s := range list {
+		if s.QualifiedName() == name {
+			return s, nil
+		}
+		if s.Name == name {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Script{}, fmt.Errorf("unknown project script %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, s := range matches {
+		names[i] = s.QualifiedName()
+	}
+	return Script{}, fmt.Errorf("project script %q is ambiguous; use one of %s", name, strings.Join(names, ", "))
+}
+
+// Names lists the name to call each script by: the plain name, or the
+// qualified name when it is defined more than once
+func Names(list []Script) []string {
+	count := make(map[string]int)
+	for _, s := range list {
+		count[s.Name]++
+	}
+	names := make([]string, len(list))
+	for i, s := range list {
+		names[i] = s.Name
+		if count[s.Name] > 1 {
+			names[i] = s.QualifiedName()
+		}
+	}
+	return names
+}
+
+// Summary lists the scripts one per line for the user
+func Summary(list []Script) string {
+	if len(list) == 0 {
+		return "No project scripts found (looked for a Makefile, package.json, Taskfile.yml and tools in the config)."
+	}
+	var sb strings.Builder
+	for i, name := range Names(list) {
+		s := list[i]
+		fmt.Fprintf(&sb, "  %-20s %s", name, s.Command)
+		if s.Description != "" {
+			fmt.Fprintf(&sb, "  # %s", s.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Tool describes run_project_script, run by handler, to the model. The
+// script names are listed as an enum so the model cannot invent one.
+func Tool(list []Script, handler functions.ContextFunction) functions.Tool {
+	names := Names(list)
+	var desc strings.Builder
+	desc.WriteString("Run one of the project's own scripts (Makefile targets, package.json scripts, Taskfile tasks, custom tools). Prefer this over shell for building, testing and linting. Available scripts:")
+	for i, name := range names {
+		fmt.Fprintf(&desc, "\n- %s: %s", name, list[i].Command)
+		if list[i].Description != "" {
+			fmt.Fprintf(&desc, " (%s)", list[i].Description)
+		}
+	}
+	return functions.Tool{
+		Name:        ToolName,
+		Source:      "project scripts",
+		Description: desc.String(),
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The script to run",
+					"enum":        names,
+				},
+				"args": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Extra arguments for the script. Make targets only accept VAR=value.",
+				},
+			},
+			"required": []string{"name"},
+		},
+		Handler: handler,
+	}
+}
+
+// makeTargetPattern matches a rule line and captures its targets and the rest
+var makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_./ -]*?)\s*::?([^=].*)?$`)
+
+// detectMake lists the explicit targets of the Makefile in dir. A "## text"
+// comment after the prerequisites, or a "#" comment on the line above,
+// becomes the description.
+func detectMake(dir string) ([]Script, error) {
+	var file string
+	var f *os.File
+	for _, name := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		var err error
+		if f, err = os.Open(filepath.Join(dir, name)); err == nil {
+			file = name
+			break
+		}
+	}
+	if f == nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	var list []Script
+	seen := make(map[string]bool)
+	comment := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			comment = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			continue
+		}
+		m := makeTargetPattern.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(line, "\t") || strings.Contains(line, ":=") {
+			comment = ""
+			continue
+		}
+		desc := comment
+		if i := strings.Index(m[2], "##"); i >= 0 {
+			desc = strings.TrimSpace(m[2][i+2:])
+		}
+		comment = ""
+		for _, target := range strings.Fields(m[1]) {
+			if seen[target] || strings.ContainsAny(target, "%$/") {
+				continue
+			}
+			seen[target] = true
+			list = append(list, Script{Name: target, Source: "make", File: file, Command: "make " + target, Description: desc})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return list, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return list, nil
+}
+
+// detectPackageJSON lists the scripts of package.json, run with the package
+// manager whose lock file is present
+func detectPackageJSON(dir string) ([]Script, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, nil
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	manager := "npm"
+	for _, lock := range []struct{ file, manager string }{
+		{"pnpm-lock.yaml", "pnpm"}, {"yarn.lock", "yarn"}, {"bun.lockb", "bun"}, {"bun.lock", "bun"},
+	} {
+		if _, err := os.Stat(filepath.Join(dir, lock.file)); err == nil {
+			manager = lock.manager
+			break
+		}
+	}
+
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		// Lifecycle hooks run implicitly around other scripts
+		if strings.HasPrefix(name, "pre") || strings.HasPrefix(name, "post") {
+			if _, ok := pkg.Scripts[strings.TrimPrefix(strings.TrimPrefix(name, "pre"), "post")]; ok {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]Script, len(names))
+	for i, name := range names {
+		list[i] = Script{Name: name, Source: manager, File: "package.json", Command: manager + " run " + shellQuote(name), Description: pkg.Scripts[name]}
+	}
+	return list, nil
+}
+
+// detectTaskfile lists the tasks of a Taskfile (https://taskfile.dev)
+func detectTaskfile(dir string) ([]Script, error) {
+	var file string
+	var data []byte
+	for _, name := range []string{"Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml"} {
+		var err error
+		if data, err = os.ReadFile(filepath.Join(dir, name)); err == nil {
+			file = name
+			break
+		}
+	}
+	if file == "" {
+		return nil, nil
+	}
+
+	var taskfile struct {
+		Tasks map[string]yaml.Node `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &taskfile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	names := make([]string, 0, len(taskfile.Tasks))
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var list []Script
+	for _, name := range names {
+		var task struct {
+			Desc     string `yaml:"desc"`
+			Internal bool   `yaml:"internal"`
+		}
+		node := taskfile.Tasks[name]
+		if node.Kind == yaml.MappingNode {
+			node.Decode(&task)
+		}
+		if task.Internal {
+			continue
+		}
+		list = append(list, Script{Name: name, Source: "task", File: file, Command: "task " + shellQuote(name), Description: task.Desc})
+	}
+	return list, nil
+}
@@ -0,0 +1,114 @@
+package scripts
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "Makefile", `BIN := codex
+VERSION ?= dev
+
+.PHONY: build test lint
+
+# Build the binary
+build: $(BIN)
+
+test: build ## Run the tests
+	go test ./...
+
+lint vet:
+	go vet ./...
+
+%.o: %.c
+	cc -c $<
+`)
+	writeFile(t, dir, "package.json", `{"scripts": {"test": "vitest", "pretest": "tsc", "dev": "vite"}}`)
+	writeFile(t, dir, "pnpm-lock.yaml", "")
+	writeFile(t, dir, "Taskfile.yml", `version: '3'
+tasks:
+  release:
+    desc: Publish a release
+    cmds: [goreleaser]
+  helper:
+    internal: true
+  fmt: gofmt -w .
+`)
+
+	found, err := Detect(dir)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	var got []string
+	for _, s := range found {
+		got = append(got, s.QualifiedName()+"="+s.Command+"|"+s.Description)
+	}
+	want := []string{
+		"make:build=make build|Build the binary",
+		"make:test=make test|Run the tests",
+		"make:lint=make lint|",
+		"make:vet=make vet|",
+		"pnpm:dev=pnpm run dev|vite",
+		"pnpm:test=pnpm run test|vitest",
+		"task:fmt=task fmt|",
+		"task:release=task release|Publish a release",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Detect() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	names := Names(found)
+	if names[1] != "make:test" || names[0] != "build" {
+		t.Errorf("Expected duplicated names to be qualified, got %v", names)
+	}
+
+	writeFile(t, dir, "package.json", `{"scripts": `)
+	if found, err := Detect(dir); err == nil || len(found) != 6 {
+		t.Errorf("Expected a parse error alongside the other scripts, got %d scripts, err=%v", len(found), err)
+	}
+}
+
+func TestFindAndCommandLine(t *testing.T) {
+	list := []Script{
+		{Name: "test", Source: "make", Command: "make test"},
+		{Name: "test", Source: "npm", Command: "npm run test"},
+		{Name: "build", Source: "make", Command: "make build"},
+	}
+
+	if _, err := Find(list, "test"); err == nil || !strings.Contains(err.Error(), "make:test, npm:test") {
+		t.Errorf("Expected an ambiguity error, got %v", err)
+	}
+	if _, err := Find(list, "deploy"); err == nil {
+		t.Error("Expected an error for an unknown script")
+	}
+
+	npmTest, err := Find(list, "npm:test")
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	cmd, err := npmTest.CommandLine([]string{"--watch", "it's; rm -rf /"})
+	if want := `npm run test -- --watch 'it'\''s; rm -rf /'`; err != nil || cmd != want {
+		t.Errorf("CommandLine() = %q, %v; want %q", cmd, err, want)
+	}
+
+	build, _ := Find(list, "build")
+	if cmd, err := build.CommandLine([]string{"GOOS=linux"}); err != nil || cmd != "make build GOOS=linux" {
+		t.Errorf("CommandLine() = %q, %v", cmd, err)
+	}
+	for _, args := range [][]string{{"clean"}, {"-f", "other.mk"}, {"X=a\nb"}, make([]string, maxArgs+1)} {
+		if _, err := build.CommandLine(args); err == nil {
+			t.Errorf("Expected arguments %q to be refused", args)
+		}
+	}
+}
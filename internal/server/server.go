@@ -0,0 +1,266 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/google/uuid"
+)
+
+// EngineFactory creates the engine for a new session
+type EngineFactory func() (*engine.Engine, error)
+
+// Server exposes agent sessions over a REST API with Server-Sent Events:
+//
+//	POST   /sessions                           create a session
+//	GET    /sessions/{id}                      session status and pending approvals
+//	DELETE /sessions/{id}                      cancel and remove a session
+//	POST   /sessions/{id}/messages             send a message ({"content": "..."})
+//	POST   /sessions/{id}/cancel               cancel the active run
+//	GET    /sessions/{id}/events               stream events (SSE; resumes after Last-Event-ID)
+//	POST   /sessions/{id}/approvals/{callID}   approve or deny a call ({"approved": true})
+//	GET    /sessions/{id}/history              conversation history
+type Server struct {
+	NewEngine EngineFactory
+	Logger    logging.Logger
+	// Token, if set, must be sent as "Authorization: Bearer <token>"
+	Token string
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// New creates a server
+func New(factory EngineFactory, logger logging.Logger) *Server {
+	if logger == nil {
+		logger = logging.NewNilLogger()
+	}
+	return &Server{NewEngine: factory, Logger: logger, sessions: make(map[string]*Session)}
+}
+
+// sessionInfo is the JSON representation of a session
+type sessionInfo struct {
+	ID               string               `json:"id"`
+	CreatedAt        time.Time            `json:"created_at"`
+	Running          bool                 `json:"running"`
+	PendingApprovals []agent.FunctionCall `json:"pending_approvals"`
+}
+
+// Handler returns the HTTP handler serving the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sessions", s.handleCreateSession)
+	mux.HandleFunc("GET /sessions/{id}", s.withSession(s.handleGetSession))
+	mux.HandleFunc("DELETE /sessions/{id}", s.handleDeleteSession)
+	mux.HandleFunc("POST /sessions/{id}/messages", s.withSession(s.handleSendMessage))
+	mux.HandleFunc("POST /sessions/{id}/cancel", s.withSession(s.handleCancel))
+	mux.HandleFunc("GET /sessions/{id}/events", s.withSession(s.handleEvents))
+	mux.HandleFunc("POST /sessions/{id}/approvals/{callID}", s.withSession(s.handleApproval))
+	mux.HandleFunc("GET /sessions/{id}/history", s.withSession(s.handleHistory))
+	return s.authenticate(mux)
+}
+
+// Close cancels all sessions
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.sessions {
+		sess.Close()
+		delete(s.sessions, id)
+	}
+}
+
+// authenticate enforces the bearer token when one is configured
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != "" {
+			expected := "Bearer " + s.Token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withSession resolves the {id} path value to a session
+func (s *Server) withSession(h func(http.ResponseWriter, *http.Request, *Session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		sess, ok := s.sessions[r.PathValue("id")]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("session %q not found", r.PathValue("id")))
+			return
+		}
+		h(w, r, sess)
+	}
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	eng, err := s.NewEngine()
+	if err != nil {
+		s.Logger.Log("[ERROR] Server: failed to create engine: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sess := NewSession(uuid.New().String(), eng)
+	s.mu.Lock()
+	s.sessions[sess.ID] = sess
+	s.mu.Unlock()
+
+	s.Logger.Log("Server: created session %s", sess.ID)
+	writeJSON(w, http.StatusCreated, infoFor(sess))
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request, sess *Session) {
+	writeJSON(w, http.StatusOK, infoFor(sess))
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("session %q not found", id))
+		return
+	}
+
+	sess.Close()
+	s.Logger.Log("Server: deleted session %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request, sess *Session) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Content == "" {
+		writeError(w, http.StatusBadRequest, errors.New("content is required"))
+		return
+	}
+
+	if err := sess.Send(req.Content); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, infoFor(sess))
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request, sess *Session) {
+	writeJSON(w, http.StatusOK, map[string]bool{"cancelled": sess.Cancel()})
+}
+
+func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request, sess *Session) {
+	var req struct {
+		Approved *bool `json:"approved"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Approved == nil {
+		writeError(w, http.StatusBadRequest, errors.New(`request body must be {"approved": true|false}`))
+		return
+	}
+
+	if err := sess.Decide(r.PathValue("callID"), *req.Approved); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, sess *Session) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": sess.History()})
+}
+
+// handleEvents streams session events as Server-Sent Events. Clients resume
+// with the Last-Event-ID header or the "after" query parameter.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, sess *Session) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = r.URL.Query().Get("after")
+	}
+	afterID, _ := strconv.ParseInt(after, 10, 64)
+
+	backlog, ch := sess.Subscribe(afterID)
+	defer sess.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range backlog {
+		writeEvent(w, ev)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, ev)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// infoFor builds the JSON representation of a session
+func infoFor(sess *Session) sessionInfo {
+	return sessionInfo{
+		ID:               sess.ID,
+		CreatedAt:        sess.CreatedAt,
+		Running:          sess.Running(),
+		PendingApprovals: sess.PendingApprovals(),
+	}
+}
+
+// writeEvent writes a single SSE frame
+func writeEvent(w http.ResponseWriter, ev Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
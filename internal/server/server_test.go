@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/sandbox"
+)
+
+// shellAgent requests one shell command, then replies with its output
+type shellAgent struct {
+	agent.Agent
+	handler agent.ResponseHandler
+}
+
+func (a *shellAgent) emit(item agent.ResponseItem) {
+	data, _ := json.Marshal(item)
+	a.handler(string(data))
+}
+
+func (a *shellAgent) SendMessage(ctx context.Context, messages []agent.Message, handler agent.ResponseHandler) (bool, error) {
+	a.handler = handler
+	a.emit(agent.ResponseItem{Type: "function_call", FunctionCall: &agent.FunctionCall{
+		ID: "call_1", Name: "shell", Arguments: `{"command":"echo hello"}`,
+	}})
+	return true, nil
+}
+
+func (a *shellAgent) SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) error {
+	a.emit(agent.ResponseItem{Type: "message", Message: &agent.Message{Role: "assistant", Content: "ran: " + output}})
+	return nil
+}
+
+func (a *shellAgent) Cancel() {}
+
+func TestServerApprovalFlow(t *testing.T) {
+	cfg := &config.Config{ApprovalMode: config.Suggest, CWD: t.TempDir()}
+	srv := New(func() (*engine.Engine, error) {
+		exec := executor.New(cfg, sandbox.NewBasicSandbox(), functions.NewRegistry(), nil)
+		return engine.New(&shellAgent{}, exec, cfg, nil), nil
+	}, nil)
+	srv.Token = "secret"
+	defer srv.Close()
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	do := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp
+	}
+
+	// Requests without the token are rejected
+	resp, err := http.Post(ts.URL+"/sessions", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /sessions failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	resp = do("POST", "/sessions", "")
+	var info sessionInfo
+	json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || info.ID == "" {
+		t.Fatalf("Expected a created session, got %d %+v", resp.StatusCode, info)
+	}
+
+	srv.mu.Lock()
+	sess := srv.sessions[info.ID]
+	srv.mu.Unlock()
+	_, events := sess.Subscribe(0)
+
+	if resp = do("POST", "/sessions/"+info.ID+"/messages", `{"content":"say hello"}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202 for message, got %d", resp.StatusCode)
+	}
+
+	waitFor := func(eventType string) Event {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == eventType {
+					return ev
+				}
+			case <-timeout:
+				t.Fatalf("Timed out waiting for %s event", eventType)
+			}
+		}
+	}
+
+	waitFor(EventApprovalRequired)
+	if resp = do("POST", "/sessions/"+info.ID+"/approvals/unknown", `{"approved":true}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown call, got %d", resp.StatusCode)
+	}
+	if resp = do("POST", "/sessions/"+info.ID+"/approvals/call_1", `{"approved":true}`); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected 204 for approval, got %d", resp.StatusCode)
+	}
+
+	if approval := waitFor(EventApproval).Data.(agent.ApprovalEvent); !approval.Approved() || approval.DecidedBy != agent.DecidedByUser || approval.CallID != "call_1" {
+		t.Errorf("Unexpected approval event: %+v", approval)
+	}
+	result := waitFor(EventToolResult).Data.(ToolResultData)
+	if !result.Success || !strings.Contains(result.Output, "hello") {
+		t.Errorf("Unexpected tool result: %+v", result)
+	}
+	outcome := waitFor(EventRunComplete).Data.(*engine.Outcome)
+	if !strings.HasPrefix(outcome.FinalMessage, "ran: ") {
+		t.Errorf("Unexpected final message: %q", outcome.FinalMessage)
+	}
+}
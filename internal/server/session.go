@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+)
+
+// maxEventBacklog bounds how many past events a session keeps for replay
+const maxEventBacklog = 1000
+
+// subscriberBuffer is the number of events buffered per stream subscriber
+const subscriberBuffer = 64
+
+// Event types streamed to clients
+const (
+	EventMessage          = "message"
+	EventToolCall         = "tool_call"
+	EventApprovalRequired = "approval_required"
+	EventToolResult       = "tool_result"
+	EventToolDenied       = "tool_denied"
+	EventApproval         = "approval" // Carries an agent.ApprovalEvent for every tool call
+	EventRunComplete      = "run_complete"
+	EventError            = "error"
+)
+
+// ErrSessionBusy is returned when a message is sent while a run is in progress
+var ErrSessionBusy = errors.New("session is busy")
+
+// ErrNoPendingApproval is returned when approving a call that is not waiting
+var ErrNoPendingApproval = errors.New("no pending approval for call")
+
+// Event is a single session event. IDs increase monotonically per session.
+type Event struct {
+	ID   int64       `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// MessageData is the payload of message events
+type MessageData struct {
+	Content string `json:"content"`
+}
+
+// ToolCallData is the payload of tool_call events
+type ToolCallData struct {
+	Call agent.FunctionCall `json:"call"`
+}
+
+// ApprovalData is the payload of approval_required events
+type ApprovalData struct {
+	Call agent.FunctionCall `json:"call"`
+	Args string             `json:"args"`
+}
+
+// ToolDeniedData is the payload of tool_denied events
+type ToolDeniedData struct {
+	Call   agent.FunctionCall `json:"call"`
+	Reason string             `json:"reason"`
+}
+
+// ErrorData is the payload of error events
+type ErrorData struct {
+	Error string `json:"error"`
+}
+
+// ToolResultData is the payload of tool_result events. run_complete events carry an *engine.Outcome.
+type ToolResultData struct {
+	Call       agent.FunctionCall `json:"call"`
+	Output     string             `json:"output"`
+	Success    bool               `json:"success"`
+	Suspicious bool               `json:"suspicious,omitempty"`
+	Reasons    []string           `json:"reasons,omitempty"`
+}
+
+// Session is one conversation driven by its own engine. It implements
+// engine.Approver, engine.Notifier and engine.ApprovalRecorder, turning engine callbacks into events
+// and waiting for approvals submitted through the API.
+type Session struct {
+	ID        string
+	CreatedAt time.Time
+
+	engine *engine.Engine
+
+	mu          sync.Mutex
+	running     bool
+	cancel      context.CancelFunc
+	events      []Event
+	nextEventID int64
+	subscribers map[chan Event]struct{}
+	approvals   map[string]chan bool // Pending approvals by call ID
+	pending     map[string]agent.FunctionCall
+}
+
+// NewSession creates a session around an engine
+func NewSession(id string, eng *engine.Engine) *Session {
+	return &Session{
+		ID:          id,
+		CreatedAt:   time.Now(),
+		engine:      eng,
+		nextEventID: 1,
+		subscribers: make(map[chan Event]struct{}),
+		approvals:   make(map[string]chan bool),
+		pending:     make(map[string]agent.FunctionCall),
+	}
+}
+
+// Send starts a run for the message in the background. Only one run may be
+// active per session.
+func (s *Session) Send(content string) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrSessionBusy
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running = true
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		outcome, err := s.engine.Run(ctx, content, s, s)
+
+		s.mu.Lock()
+		s.running = false
+		s.cancel = nil
+		s.mu.Unlock()
+
+		if err != nil {
+			s.publish(EventError, ErrorData{Error: err.Error()})
+			return
+		}
+		s.publish(EventRunComplete, outcome)
+	}()
+	return nil
+}
+
+// Cancel stops the active run, if any. It reports whether a run was cancelled.
+func (s *Session) Cancel() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	s.engine.Agent.Cancel()
+	return true
+}
+
+// Running reports whether a run is in progress
+func (s *Session) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// PendingApprovals returns the calls waiting for a decision
+func (s *Session) PendingApprovals() []agent.FunctionCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := make([]agent.FunctionCall, 0, len(s.pending))
+	for _, call := range s.pending {
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// Decide approves or denies a pending call
+func (s *Session) Decide(callID string, approved bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reply, ok := s.approvals[callID]
+	if !ok {
+		return ErrNoPendingApproval
+	}
+	delete(s.approvals, callID)
+	delete(s.pending, callID)
+	reply <- approved
+	return nil
+}
+
+// History returns the conversation history
+func (s *Session) History() []agent.Message {
+	return s.engine.Agent.GetHistory().GetMessages()
+}
+
+// Subscribe returns past events with IDs greater than after and a channel
+// receiving new ones. The channel is closed if the subscriber falls behind.
+func (s *Session) Subscribe(after int64) ([]Event, chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var backlog []Event
+	for _, ev := range s.events {
+		if ev.ID > after {
+			backlog = append(backlog, ev)
+		}
+	}
+	ch := make(chan Event, subscriberBuffer)
+	s.subscribers[ch] = struct{}{}
+	return backlog, ch
+}
+
+// Unsubscribe stops delivering events to ch
+func (s *Session) Unsubscribe(ch chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Close cancels the active run and disconnects all subscribers
+func (s *Session) Close() {
+	s.Cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish records an event and fans it out to subscribers
+func (s *Session) publish(eventType string, data interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ev := Event{ID: s.nextEventID, Type: eventType, Time: time.Now(), Data: data}
+	s.nextEventID++
+	s.events = append(s.events, ev)
+	if len(s.events) > maxEventBacklog {
+		s.events = s.events[len(s.events)-maxEventBacklog:]
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Slow subscriber; drop it rather than block the engine
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Approve publishes an approval_required event and waits for Decide
+func (s *Session) Approve(ctx context.Context, call agent.FunctionCall) (bool, error) {
+	reply := make(chan bool, 1)
+	s.mu.Lock()
+	s.approvals[call.ID] = reply
+	s.pending[call.ID] = call
+	s.mu.Unlock()
+
+	s.publish(EventApprovalRequired, ApprovalData{Call: call, Args: executor.ApprovalArgs(call)})
+
+	select {
+	case approved := <-reply:
+		return approved, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.approvals, call.ID)
+		delete(s.pending, call.ID)
+		s.mu.Unlock()
+		return false, ctx.Err()
+	}
+}
+
+func (s *Session) OnMessage(content string) {
+	s.publish(EventMessage, MessageData{Content: content})
+}
+
+func (s *Session) OnToolCall(call agent.FunctionCall) {
+	s.publish(EventToolCall, ToolCallData{Call: call})
+}
+
+func (s *Session) OnToolResult(call agent.FunctionCall, res *executor.Result) {
+	s.publish(EventToolResult, ToolResultData{
+		Call:       call,
+		Output:     res.Output,
+		Success:    res.Success,
+		Suspicious: res.Verdict.Suspicious,
+		Reasons:    res.Verdict.Reasons,
+	})
+}
+
+func (s *Session) OnToolDenied(call agent.FunctionCall, reason string) {
+	s.publish(EventToolDenied, ToolDeniedData{Call: call, Reason: reason})
+}
+
+func (s *Session) OnApproval(event agent.ApprovalEvent) {
+	s.publish(EventApproval, event)
+}
@@ -0,0 +1,222 @@
+// Package sessions lists, searches and renames the saved session rollouts,
+// and derives the titles that identify them.
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/epuerta/codex-go/internal/agent"
+)
+
+// maxTitleLength bounds generated titles, in runes
+const maxTitleLength = 60
+
+// titleFillers are dropped from the start of a prompt when titling it
+var titleFillers = []string{"please", "can you", "could you", "would you", "i want you to", "i need you to", "help me"}
+
+// Title derives a short title for a session from its first prompt: the first
+// line of text, without courtesy phrases, cut at a word boundary
+func Title(prompt string) string {
+	var line string
+	inFence := false
+	for _, l := range strings.Split(prompt, "\n") {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "```") {
+			inFence = !inFence
+			continue
+		}
+		if l = strings.TrimLeft(l, "#>*-` "); l != "" && !inFence {
+			line = l
+			break
+		}
+	}
+	line = strings.Join(strings.Fields(line), " ")
+
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, filler := range titleFillers {
+			if len(line) > len(filler) && strings.EqualFold(line[:len(filler)], filler) && !isLetter(line[len(filler)]) {
+				line = strings.TrimLeft(line[len(filler):], " ,:")
+				trimmed = true
+			}
+		}
+	}
+	line = strings.TrimRight(line, " ?.!:,")
+
+	runes := []rune(line)
+	if len(runes) > maxTitleLength {
+		cut := maxTitleLength
+		for i := cut; i > maxTitleLength/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		runes = append([]rune(strings.TrimRight(string(runes[:cut]), " ,:;")), '…')
+	}
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// isLetter reports whether b is an ASCII letter
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// Entry describes a saved session
+type Entry struct {
+	Path      string    `json:"path"`
+	SessionID string    `json:"session_id"`
+	Title     string    `json:"title"`
+	Repo      string    `json:"repo,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	prompts []string // The user's messages, for searching
+}
+
+// rolloutFile holds the fields of a rollout that describe it
+type rolloutFile struct {
+	SessionID string          `json:"session_id"`
+	Title     string          `json:"title"`
+	Repo      string          `json:"repo"`
+	Model     string          `json:"model"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Messages  []agent.Message `json:"messages"`
+}
+
+// Load reads the entry of the rollout at path. Rollouts saved before titles
+// existed are titled from their first prompt.
+func Load(path string) (Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read session: %w", err)
+	}
+	var r rolloutFile
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Entry{}, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+
+	e := Entry{Path: path, SessionID: r.SessionID, Title: r.Title, Repo: r.Repo, Model: r.Model, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt}
+	for _, msg := range r.Messages {
+		if msg.Role == "user" && msg.Content != "" {
+			e.prompts = append(e.prompts, msg.Content)
+		}
+	}
+	if e.Title == "" && len(e.prompts) > 0 {
+		e.Title = Title(e.prompts[0])
+	}
+	return e, nil
+}
+
+// List returns the sessions saved in dir, most recently updated first.
+// Unreadable rollouts are skipped and reported in the returned warnings.
+func List(dir string) ([]Entry, []error, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var entries []Entry
+	var warnings []error
+	for _, path := range paths {
+		e, err := Load(path)
+		if err != nil {
+			warnings = append(warnings, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].UpdatedAt.After(entries[j].UpdatedAt) })
+	return entries, warnings, nil
+}
+
+// Matches reports whether every word of query appears, ignoring case, in the
+// session's title, repository or prompts
+func (e Entry) Matches(query string) bool {
+	text := strings.ToLower(e.Title + "\n" + e.Repo + "\n" + strings.Join(e.prompts, "\n"))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the entries matching query
+func Filter(entries []Entry, query string) []Entry {
+	var matched []Entry
+	for _, e := range entries {
+		if e.Matches(query) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// Find returns the entry whose session ID or file name is id, or starts
+// with it if only one does
+func Find(entries []Entry, id string) (Entry, error) {
+	if id == "" {
+		return Entry{}, errors.New("session ID is required")
+	}
+	var found []Entry
+	for _, e := range entries {
+		name := strings.TrimSuffix(filepath.Base(e.Path), ".json")
+		if e.SessionID == id || name == id {
+			return e, nil
+		}
+		if strings.HasPrefix(e.SessionID, id) || strings.HasPrefix(name, id) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Entry{}, fmt.Errorf("no session matches %q", id)
+	case 1:
+		return found[0], nil
+	}
+	return Entry{}, fmt.Errorf("%q matches %d sessions; use more of the ID", id, len(found))
+}
+
+// Rename sets the title of the rollout at path, keeping the rest of the file
+func Rename(path, title string) error {
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		return errors.New("title is required")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read session: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+	fields["title"], _ = json.Marshal(title)
+	data, err = json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
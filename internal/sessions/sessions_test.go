@@ -0,0 +1,116 @@
+package sessions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"fix the flaky login test", "Fix the flaky login test"},
+		{"Can you please add retries to the HTTP client?", "Add retries to the HTTP client"},
+		{"  \n## Refactor   the parser\nmore detail", "Refactor the parser"},
+		{"```\npanic: nil map\n```\nwhy does this crash", "Why does this crash"},
+		{"", ""},
+		{"Rewrite the configuration loader so that it reads environment variables before files and reports every invalid key", "Rewrite the configuration loader so that it reads…"},
+	}
+	for _, tt := range tests {
+		if got := Title(tt.prompt); got != tt.want {
+			t.Errorf("Title(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
+
+// writeRollout saves a rollout with the given fields in dir
+func writeRollout(t *testing.T, dir, name string, fields map[string]interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestListFilterFind(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeRollout(t, dir, "codex-session-1.json", map[string]interface{}{
+		"session_id": "3f2a9c1e-old", "updated_at": now.Add(-time.Hour), "repo": "api",
+		"messages": []map[string]string{{"role": "user", "content": "please fix the login test"}},
+	})
+	writeRollout(t, dir, "codex-session-2.json", map[string]interface{}{
+		"session_id": "3f2b0000-new", "updated_at": now, "repo": "web", "title": "Dark mode",
+		"messages": []map[string]string{{"role": "user", "content": "add a theme toggle"}},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, warnings, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || len(warnings) != 1 {
+		t.Fatalf("got %d entries and %d warnings, want 2 and 1", len(entries), len(warnings))
+	}
+	if entries[0].Title != "Dark mode" || entries[1].Title != "Fix the login test" {
+		t.Errorf("titles = %q, %q", entries[0].Title, entries[1].Title)
+	}
+
+	if got := Filter(entries, "THEME toggle"); len(got) != 1 || got[0].Repo != "web" {
+		t.Errorf("Filter by prompt = %+v", got)
+	}
+	if got := Filter(entries, "login api"); len(got) != 1 || got[0].Repo != "api" {
+		t.Errorf("Filter by title and repo = %+v", got)
+	}
+
+	if _, err := Find(entries, "3f2"); err == nil || !strings.Contains(err.Error(), "matches 2 sessions") {
+		t.Errorf("Find ambiguous prefix: err = %v", err)
+	}
+	if e, err := Find(entries, "3f2b"); err != nil || e.Repo != "web" {
+		t.Errorf("Find(3f2b) = %+v, %v", e, err)
+	}
+	if e, err := Find(entries, "codex-session-1"); err != nil || e.Repo != "api" {
+		t.Errorf("Find by file name = %+v, %v", e, err)
+	}
+	if _, err := Find(entries, "zzz"); err == nil {
+		t.Error("Find(zzz) succeeded")
+	}
+}
+
+func TestRename(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRollout(t, dir, "s.json", map[string]interface{}{
+		"session_id": "abc", "commands_run": []string{"go test ./..."},
+	})
+
+	if err := Rename(path, "  "); err == nil {
+		t.Error("Rename with an empty title succeeded")
+	}
+	if err := Rename(path, "Release  prep"); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Title != "Release prep" || e.SessionID != "abc" {
+		t.Errorf("after rename: %+v", e)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "go test ./...") {
+		t.Errorf("rename dropped other fields: %s", data)
+	}
+}
@@ -0,0 +1,236 @@
+// Package slash parses and dispatches the slash commands typed in the chat
+// input. Commands are declared with their arguments and a description; the
+// registry checks the arguments, renders the help and lists the completions
+// offered as the user types.
+package slash
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// UnknownError is returned by Dispatch for commands that are not registered
+type UnknownError struct {
+	Name    string   // As typed, without the slash
+	Similar []string // Registered commands with a similar name, with the slash
+}
+
+func (e *UnknownError) Error() string {
+	if len(e.Similar) == 0 {
+		return fmt.Sprintf("unknown command /%s", e.Name)
+	}
+	return fmt.Sprintf("unknown command /%s (did you mean %s?)", e.Name, strings.Join(e.Similar, ", "))
+}
+
+// UsageError is returned by Dispatch when the arguments do not fit the command
+type UsageError struct {
+	Command *Command
+}
+
+func (e *UsageError) Error() string {
+	return "usage: " + e.Command.Synopsis()
+}
+
+// Args are the arguments typed after a command's name
+type Args struct {
+	Raw    string   // Everything after the name, trimmed
+	Fields []string // Raw split on spaces, keeping quoted strings together
+}
+
+// Arg returns the i-th argument, or "" if there are fewer
+func (a Args) Arg(i int) string {
+	if i < len(a.Fields) {
+		return a.Fields[i]
+	}
+	return ""
+}
+
+// Command is a slash command
+type Command struct {
+	Name        string   // Without the slash
+	Aliases     []string // Other names, without the slash
+	Usage       string   // The arguments, like "<path>" or "[name] [args...]"
+	Description string   // One line, shown in the help and the completion popup
+
+	MinArgs int // Number of arguments required
+	MaxArgs int // Number of arguments accepted; -1 accepts any number
+
+	// Complete returns the values offered as completions of the first
+	// argument, such as script names; may be nil
+	Complete func() []string
+
+	// Run carries out the command. The arguments were checked against
+	// MinArgs and MaxArgs.
+	Run func(args Args) tea.Cmd
+}
+
+// Completion is a completion of the chat input
+type Completion struct {
+	Value       string
+	Description string
+}
+
+// Registry holds the slash commands
+type Registry struct {
+	commands []*Command
+	names    map[string]*Command // By name and alias
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]*Command)}
+}
+
+// Register adds cmd. Registering a name or alias twice panics, since
+// commands are declared by the program.
+func (r *Registry) Register(cmd Command) {
+	c := &cmd
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		if _, ok := r.names[name]; ok {
+			panic(fmt.Sprintf("slash command /%s registered twice", name))
+		}
+		r.names[name] = c
+	}
+	r.commands = append(r.commands, c)
+	sort.Slice(r.commands, func(i, j int) bool { return r.commands[i].Name < r.commands[j].Name })
+}
+
+// Lookup returns the command with the given name or alias, without the slash
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	c, ok := r.names[name]
+	return c, ok
+}
+
+// Commands returns the commands, sorted by name
+func (r *Registry) Commands() []*Command {
+	return r.commands
+}
+
+// Parse splits input into a command name and its arguments. ok is false if
+// input is not a slash command.
+func Parse(input string) (name string, args Args, ok bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") || len(input) == 1 {
+		return "", Args{}, false
+	}
+	name, raw, _ := strings.Cut(input[1:], " ")
+	raw = strings.TrimSpace(raw)
+	return name, Args{Raw: raw, Fields: splitFields(raw)}, true
+}
+
+// splitFields splits s on spaces. Single or double quotes keep a field
+// together and a backslash escapes the next character.
+func splitFields(s string) []string {
+	var fields []string
+	var field strings.Builder
+	inField, escaped := false, false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped, inField = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				field.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inField = r, true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// Dispatch runs the command typed in input. It returns an *UnknownError for
+// commands that are not registered and a *UsageError when the arguments do
+// not fit the command.
+func (r *Registry) Dispatch(input string) (tea.Cmd, error) {
+	name, args, _ := Parse(input)
+	c, ok := r.Lookup(name)
+	if !ok {
+		return nil, &UnknownError{Name: name, Similar: r.similar(name)}
+	}
+	if len(args.Fields) < c.MinArgs || (c.MaxArgs >= 0 && len(args.Fields) > c.MaxArgs) {
+		return nil, &UsageError{Command: c}
+	}
+	return c.Run(args), nil
+}
+
+// similar returns the commands whose name starts with name or shares its
+// first letters, for a "did you mean" hint
+func (r *Registry) similar(name string) []string {
+	var out []string
+	if name == "" {
+		return nil
+	}
+	for _, c := range r.commands {
+		if strings.HasPrefix(c.Name, name) || (len(name) >= 2 && strings.HasPrefix(name, c.Name[:min(2, len(c.Name))])) {
+			out = append(out, "/"+c.Name)
+		}
+	}
+	return out
+}
+
+// Synopsis returns the command's name and arguments, like "/run [name]"
+func (c *Command) Synopsis() string {
+	if c.Usage == "" {
+		return "/" + c.Name
+	}
+	return "/" + c.Name + " " + c.Usage
+}
+
+// Help lists the commands with their descriptions, aligned
+func (r *Registry) Help() string {
+	width := 0
+	for _, c := range r.commands {
+		width = max(width, len(c.Synopsis()))
+	}
+	var b strings.Builder
+	for _, c := range r.commands {
+		description := c.Description
+		if len(c.Aliases) > 0 {
+			description += " (also /" + strings.Join(c.Aliases, ", /") + ")"
+		}
+		fmt.Fprintf(&b, "  %-*s : %s\n", width, c.Synopsis(), description)
+	}
+	return b.String()
+}
+
+// Completions lists the commands, followed by a space for those that take
+// arguments, and "/name value" for each value their Complete offers
+func (r *Registry) Completions() []Completion {
+	var out []Completion
+	for _, c := range r.commands {
+		value := "/" + c.Name
+		if c.MaxArgs != 0 {
+			value += " "
+		}
+		out = append(out, Completion{Value: value, Description: c.Description})
+		if c.Complete == nil {
+			continue
+		}
+		for _, arg := range c.Complete() {
+			out = append(out, Completion{Value: "/" + c.Name + " " + arg, Description: c.Description})
+		}
+	}
+	return out
+}
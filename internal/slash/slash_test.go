@@ -0,0 +1,118 @@
+package slash
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input  string
+		name   string
+		raw    string
+		fields []string
+		ok     bool
+	}{
+		{input: "/clear", name: "clear", ok: true},
+		{input: "  /run test  -v ", name: "run", raw: "test  -v", fields: []string{"test", "-v"}, ok: true},
+		{input: `/attach "my notes.txt"`, name: "attach", raw: `"my notes.txt"`, fields: []string{"my notes.txt"}, ok: true},
+		{input: `/run echo it\'s 'a b'`, name: "run", raw: `echo it\'s 'a b'`, fields: []string{"echo", "it's", "a b"}, ok: true},
+		{input: "/", ok: false},
+		{input: "hello /clear", ok: false},
+	}
+	for _, tt := range tests {
+		name, args, ok := Parse(tt.input)
+		if ok != tt.ok || name != tt.name || args.Raw != tt.raw || !reflect.DeepEqual(args.Fields, tt.fields) {
+			t.Errorf("Parse(%q) = %q, %+v, %t; want %q, %q, %q, %t", tt.input, name, args, ok, tt.name, tt.raw, tt.fields, tt.ok)
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	var got []string
+	r := NewRegistry()
+	r.Register(Command{
+		Name:    "model",
+		Usage:   "[name]",
+		MaxArgs: 1,
+		Run: func(args Args) tea.Cmd {
+			got = append(got, "model:"+args.Arg(0))
+			return nil
+		},
+	})
+	r.Register(Command{
+		Name:    "attach",
+		Aliases: []string{"image"},
+		Usage:   "<path>",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Run: func(args Args) tea.Cmd {
+			got = append(got, "attach:"+args.Raw)
+			return nil
+		},
+	})
+
+	for _, input := range []string{"/model", "/model gpt-4o", "/image a b.png"} {
+		if _, err := r.Dispatch(input); err != nil {
+			t.Errorf("Dispatch(%q) failed: %v", input, err)
+		}
+	}
+	if want := []string{"model:", "model:gpt-4o", "attach:a b.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected runs %q, got %q", want, got)
+	}
+
+	var usage *UsageError
+	if _, err := r.Dispatch("/model a b"); !errors.As(err, &usage) || usage.Command.Name != "model" {
+		t.Errorf("Expected a usage error for too many arguments, got %v", err)
+	}
+	if _, err := r.Dispatch("/attach"); !errors.As(err, &usage) || err.Error() != "usage: /attach <path>" {
+		t.Errorf("Expected a usage error for a missing argument, got %v", err)
+	}
+	var unknown *UnknownError
+	if _, err := r.Dispatch("/mod"); !errors.As(err, &unknown) || !reflect.DeepEqual(unknown.Similar, []string{"/model"}) {
+		t.Errorf("Expected an unknown command suggesting /model, got %v", err)
+	}
+	if _, err := r.Dispatch("/xyz"); !errors.As(err, &unknown) || len(unknown.Similar) != 0 {
+		t.Errorf("Expected an unknown command without suggestions, got %v", err)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Command{Name: "attach"})
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering an alias of another command to panic")
+		}
+	}()
+	r.Register(Command{Name: "image", Aliases: []string{"attach"}})
+}
+
+func TestHelpAndCompletions(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Command{Name: "run", Usage: "[name]", Description: "Runs a script.", MaxArgs: -1, Complete: func() []string { return []string{"build", "test"} }})
+	r.Register(Command{Name: "clear", Description: "Clears the history."})
+
+	help := r.Help()
+	if want := "  /clear      : Clears the history.\n  /run [name] : Runs a script.\n"; help != want {
+		t.Errorf("Unexpected help:\n%s", help)
+	}
+
+	var values []string
+	for _, c := range r.Completions() {
+		values = append(values, c.Value)
+		if c.Description == "" {
+			t.Errorf("Expected a description for %q", c.Value)
+		}
+	}
+	if want := []string{"/clear", "/run ", "/run build", "/run test"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected completions %q, got %q", want, values)
+	}
+	if !strings.HasPrefix(r.Commands()[0].Synopsis(), "/clear") {
+		t.Errorf("Expected the commands sorted by name, got %s first", r.Commands()[0].Name)
+	}
+}
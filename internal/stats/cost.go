@@ -0,0 +1,49 @@
+package stats
+
+import "strings"
+
+// ModelPrice holds the USD price per million tokens for a model
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// modelPrices lists known model prices, matched by longest prefix
+var modelPrices = map[string]ModelPrice{
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.60},
+	"gpt-4o":        {Input: 2.50, Output: 10.00},
+	"gpt-4-turbo":   {Input: 10.00, Output: 30.00},
+	"gpt-4.1-nano":  {Input: 0.10, Output: 0.40},
+	"gpt-4.1-mini":  {Input: 0.40, Output: 1.60},
+	"gpt-4.1":       {Input: 2.00, Output: 8.00},
+	"gpt-4":         {Input: 30.00, Output: 60.00},
+	"gpt-3.5-turbo": {Input: 0.50, Output: 1.50},
+	"o4-mini":       {Input: 1.10, Output: 4.40},
+	"o3-mini":       {Input: 1.10, Output: 4.40},
+	"o3":            {Input: 10.00, Output: 40.00},
+	"o1-mini":       {Input: 1.10, Output: 4.40},
+	"o1":            {Input: 15.00, Output: 60.00},
+}
+
+// LookupPrice returns the price for a model. ok is false if the model is unknown.
+func LookupPrice(model string) (price ModelPrice, ok bool) {
+	model = strings.ToLower(model)
+	best := ""
+	for prefix, p := range modelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+			price = p
+		}
+	}
+	return price, best != ""
+}
+
+// EstimateCost returns the estimated USD cost of the given token usage.
+// Unknown models are priced at zero.
+func EstimateCost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := LookupPrice(model)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1_000_000
+}
@@ -0,0 +1,155 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// UnknownRepo is used for sessions that were not started inside a repository
+const UnknownRepo = "(unknown)"
+
+// SessionSummary is the per-session input to a digest, assembled from
+// rollout metadata and the stats store
+type SessionSummary struct {
+	SessionID        string
+	Repo             string
+	Model            string
+	CreatedAt        time.Time
+	Tasks            int
+	CommandsRun      []string
+	FilesModified    []string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// RepoDigest aggregates activity for a single repository
+type RepoDigest struct {
+	Repo             string  `json:"repo"`
+	Sessions         int     `json:"sessions"`
+	Tasks            int     `json:"tasks"`
+	FilesChanged     int     `json:"files_changed"`
+	TestsRun         int     `json:"tests_run"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	EstimatedCost    float64 `json:"estimated_cost_usd"`
+}
+
+// testCommandPattern matches common test runner invocations
+var testCommandPattern = regexp.MustCompile(`(^|[\s;&|(])(go test|npm (run )?test|yarn test|pnpm (run )?test|pytest|python -m pytest|python -m unittest|cargo test|make test|mvn test|gradle test|\./gradlew test|jest|vitest|rspec|phpunit|dotnet test)\b`)
+
+// IsTestCommand reports whether a shell command looks like a test run
+func IsTestCommand(command string) bool {
+	return testCommandPattern.MatchString(strings.TrimSpace(command))
+}
+
+// BuildDigest groups sessions by repository. Repositories are ordered by
+// session count, then name.
+func BuildDigest(sessions []SessionSummary) []RepoDigest {
+	byRepo := make(map[string]*RepoDigest)
+	files := make(map[string]map[string]struct{})
+
+	for _, s := range sessions {
+		repo := s.Repo
+		if repo == "" {
+			repo = UnknownRepo
+		}
+		d, ok := byRepo[repo]
+		if !ok {
+			d = &RepoDigest{Repo: repo}
+			byRepo[repo] = d
+			files[repo] = make(map[string]struct{})
+		}
+
+		d.Sessions++
+		d.Tasks += s.Tasks
+		d.PromptTokens += s.PromptTokens
+		d.CompletionTokens += s.CompletionTokens
+		d.EstimatedCost += EstimateCost(s.Model, s.PromptTokens, s.CompletionTokens)
+		for _, cmd := range s.CommandsRun {
+			if IsTestCommand(cmd) {
+				d.TestsRun++
+			}
+		}
+		for _, f := range s.FilesModified {
+			files[repo][f] = struct{}{}
+		}
+	}
+
+	digests := make([]RepoDigest, 0, len(byRepo))
+	for repo, d := range byRepo {
+		d.FilesChanged = len(files[repo])
+		digests = append(digests, *d)
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		if digests[i].Sessions != digests[j].Sessions {
+			return digests[i].Sessions > digests[j].Sessions
+		}
+		return digests[i].Repo < digests[j].Repo
+	})
+	return digests
+}
+
+// ParseSince parses a lookback window such as "7d", "2w" or "36h"
+func ParseSince(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	unit := s[len(s)-1]
+	if unit == 'd' || unit == 'w' {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		day := 24 * time.Hour
+		if unit == 'w' {
+			return time.Duration(n) * 7 * day, nil
+		}
+		return time.Duration(n) * day, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// WriteDigest renders digests as a plain text table
+func WriteDigest(w io.Writer, since time.Time, digests []RepoDigest) error {
+	fmt.Fprintf(w, "Codex activity since %s\n\n", since.Format("Jan 2, 2006 15:04"))
+	if len(digests) == 0 {
+		_, err := fmt.Fprintln(w, "No sessions recorded in this period.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tSESSIONS\tTASKS\tFILES CHANGED\tTESTS RUN\tTOKENS\tEST. COST")
+
+	var total RepoDigest
+	for _, d := range digests {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t$%.2f\n",
+			d.Repo, d.Sessions, d.Tasks, d.FilesChanged, d.TestsRun,
+			d.PromptTokens+d.CompletionTokens, d.EstimatedCost)
+		total.Sessions += d.Sessions
+		total.Tasks += d.Tasks
+		total.FilesChanged += d.FilesChanged
+		total.TestsRun += d.TestsRun
+		total.PromptTokens += d.PromptTokens
+		total.CompletionTokens += d.CompletionTokens
+		total.EstimatedCost += d.EstimatedCost
+	}
+	if len(digests) > 1 {
+		fmt.Fprintf(tw, "TOTAL\t%d\t%d\t%d\t%d\t%d\t$%.2f\n",
+			total.Sessions, total.Tasks, total.FilesChanged, total.TestsRun,
+			total.PromptTokens+total.CompletionTokens, total.EstimatedCost)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/epuerta/codex-go/internal/fileops"
+)
+
+// PatchMetrics aggregates patch application statistics for a session
+type PatchMetrics struct {
+	Patches     int         `json:"patches"`      // patch_file calls executed
+	Hunks       int         `json:"hunks"`        // Operations attempted
+	Files       int         `json:"files"`        // Files touched by patches
+	Failures    int         `json:"failures"`     // Files that failed to patch
+	ParseErrors int         `json:"parse_errors"` // Patches that could not be parsed
+	Fuzz        map[int]int `json:"fuzz"`         // Matched deleted lines by fileops.FuzzLevel
+	MissedLines int         `json:"missed_lines"` // Requested deletions that matched nothing
+	Approved    int         `json:"approved"`     // Patches the user approved
+	Denied      int         `json:"denied"`       // Patches the user denied
+}
+
+// RecordApply adds the outcome of one patch_file call
+func (m *PatchMetrics) RecordApply(results []*fileops.AgentPatchResult, parseErr error) {
+	m.Patches++
+	if parseErr != nil {
+		m.ParseErrors++
+		return
+	}
+	if m.Fuzz == nil {
+		m.Fuzz = make(map[int]int)
+	}
+	for _, res := range results {
+		m.Files++
+		m.Hunks += res.Hunks
+		if !res.Success {
+			m.Failures++
+		}
+		for level, n := range res.Matches {
+			m.Fuzz[int(level)] += n
+		}
+		m.MissedLines += res.MissedLines
+	}
+}
+
+// RecordDecision adds a user's approval decision for a patch
+func (m *PatchMetrics) RecordDecision(approved bool) {
+	if approved {
+		m.Approved++
+	} else {
+		m.Denied++
+	}
+}
+
+// Empty reports whether nothing has been recorded
+func (m *PatchMetrics) Empty() bool {
+	return m.Patches == 0 && m.Approved == 0 && m.Denied == 0
+}
+
+// Format renders the metrics as a short multi-line summary
+func (m *PatchMetrics) Format() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Patches applied: %d (%d hunks across %d files)\n", m.Patches, m.Hunks, m.Files)
+	fmt.Fprintf(&sb, "Failures: %d files, %d unparseable patches\n", m.Failures, m.ParseErrors)
+	sb.WriteString("Line matches:")
+	for _, level := range fileops.FuzzLevels {
+		fmt.Fprintf(&sb, " %d %s,", m.Fuzz[int(level)], level)
+	}
+	fmt.Fprintf(&sb, " %d missed\n", m.MissedLines)
+	fmt.Fprintf(&sb, "Approvals: %d approved, %d denied", m.Approved, m.Denied)
+	return sb.String()
+}
@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/fileops"
+)
+
+func TestStoreLatestRecordWins(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "codex-stats-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store := NewStore(filepath.Join(tmpDir, DefaultFileName))
+	now := time.Now()
+
+	records := []SessionRecord{
+		{SessionID: "a", Repo: "repo1", Tasks: 1, UpdatedAt: now.Add(-time.Hour)},
+		{SessionID: "b", Repo: "repo2", Tasks: 1, UpdatedAt: now.Add(-30 * 24 * time.Hour)},
+		{SessionID: "a", Repo: "repo1", Tasks: 3, UpdatedAt: now},
+	}
+	for _, r := range records {
+		if err := store.Append(r); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	got, err := store.Sessions(now.Add(-7 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Sessions failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 session, got %d", len(got))
+	}
+	if got[0].SessionID != "a" || got[0].Tasks != 3 {
+		t.Errorf("Expected latest record for session a with 3 tasks, got %+v", got[0])
+	}
+}
+
+func TestStoreMissingFile(t *testing.T) {
+	store := NewStore(filepath.Join(os.TempDir(), "codex-stats-does-not-exist.jsonl"))
+	got, err := store.Sessions(time.Time{})
+	if err != nil {
+		t.Fatalf("Expected no error for missing store, got %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Expected no sessions, got %d", len(got))
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	sessions := []SessionSummary{
+		{
+			Repo:             "/src/app",
+			Model:            "gpt-4o",
+			Tasks:            2,
+			CommandsRun:      []string{"go test ./...", "ls -la", "cd web && npm test"},
+			FilesModified:    []string{"main.go", "util.go"},
+			PromptTokens:     1_000_000,
+			CompletionTokens: 100_000,
+		},
+		{
+			Repo:          "/src/app",
+			Model:         "gpt-4o",
+			Tasks:         1,
+			FilesModified: []string{"main.go"},
+		},
+		{
+			Model: "unknown-model",
+			Tasks: 1,
+		},
+	}
+
+	digests := BuildDigest(sessions)
+	if len(digests) != 2 {
+		t.Fatalf("Expected 2 repositories, got %d", len(digests))
+	}
+
+	app := digests[0]
+	if app.Repo != "/src/app" {
+		t.Fatalf("Expected /src/app first, got %s", app.Repo)
+	}
+	if app.Sessions != 2 || app.Tasks != 3 {
+		t.Errorf("Expected 2 sessions and 3 tasks, got %d and %d", app.Sessions, app.Tasks)
+	}
+	if app.FilesChanged != 2 {
+		t.Errorf("Expected 2 unique files changed, got %d", app.FilesChanged)
+	}
+	if app.TestsRun != 2 {
+		t.Errorf("Expected 2 tests run, got %d", app.TestsRun)
+	}
+	if app.EstimatedCost < 3.49 || app.EstimatedCost > 3.51 {
+		t.Errorf("Expected estimated cost of $3.50, got $%.4f", app.EstimatedCost)
+	}
+
+	if digests[1].Repo != UnknownRepo || digests[1].EstimatedCost != 0 {
+		t.Errorf("Expected unknown repo with zero cost, got %+v", digests[1])
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+	}
+	for input, want := range tests {
+		got, err := ParseSince(input)
+		if err != nil {
+			t.Errorf("ParseSince(%q) failed: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseSince(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"", "d", "-1d", "abc"} {
+		if _, err := ParseSince(input); err == nil {
+			t.Errorf("ParseSince(%q) expected error", input)
+		}
+	}
+}
+
+func TestPatchMetrics(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "codex-patch-metrics-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc a() {}\n\tfunc b() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	results, err := fileops.ApplyAgentPatch([]fileops.AgentPatchOperation{
+		{Type: "remove", Path: path, Content: "func a() {}\nfunc b() {}\nfunc missing() {}"},
+		{Type: "add", Path: path, Content: "func c() {}", Hunk: 1},
+	})
+	var mismatch *fileops.ContextMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected the missing line to fail the patch, got %v", err)
+	}
+
+	var m PatchMetrics
+	m.RecordDecision(true)
+	m.RecordApply(results, nil)
+	m.RecordDecision(false)
+	m.RecordApply(nil, errors.New("bad patch"))
+
+	if m.Patches != 2 || m.Hunks != 2 || m.Files != 1 || m.Failures != 1 || m.ParseErrors != 1 {
+		t.Errorf("Unexpected counts: %+v", m)
+	}
+	if m.Fuzz[int(fileops.FuzzExact)] != 1 || m.Fuzz[int(fileops.FuzzWhitespace)] != 1 || m.MissedLines != 1 {
+		t.Errorf("Unexpected match distribution: fuzz=%v missed=%d", m.Fuzz, m.MissedLines)
+	}
+	if m.Approved != 1 || m.Denied != 1 {
+		t.Errorf("Unexpected decisions: %+v", m)
+	}
+}
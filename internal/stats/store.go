@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultFileName is the name of the stats store inside the codex config directory
+const DefaultFileName = "stats.jsonl"
+
+// SessionRecord captures usage metadata for a single session.
+// Records are appended to the store; the latest record for a session wins.
+type SessionRecord struct {
+	SessionID        string    `json:"session_id"`
+	Repo             string    `json:"repo"`
+	Model            string    `json:"model"`
+	StartedAt        time.Time `json:"started_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Tasks            int       `json:"tasks"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+
+	// Patch holds patch application statistics, if any patches were attempted
+	Patch *PatchMetrics `json:"patch,omitempty"`
+}
+
+// Store is an append-only JSON lines file of session records
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore creates a store backed by the given file path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath returns the default store location (~/.codex/stats.jsonl)
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".codex", DefaultFileName), nil
+}
+
+// Path returns the file path backing the store
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append writes a record to the end of the store
+func (s *Store) Append(record SessionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create stats directory: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open stats store: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats record: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write stats record: %w", err)
+	}
+	return nil
+}
+
+// Sessions returns the latest record for every session updated at or after since.
+// A missing store is not an error and yields no records.
+func (s *Store) Sessions(since time.Time) ([]SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open stats store: %w", err)
+	}
+	defer f.Close()
+
+	latest := make(map[string]SessionRecord)
+	var order []string
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record SessionRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			// Skip corrupt lines rather than failing the whole report
+			continue
+		}
+		if _, seen := latest[record.SessionID]; !seen {
+			order = append(order, record.SessionID)
+		}
+		latest[record.SessionID] = record
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stats store: %w", err)
+	}
+
+	var records []SessionRecord
+	for _, id := range order {
+		record := latest[id]
+		if record.UpdatedAt.Before(since) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
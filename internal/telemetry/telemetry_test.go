@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/epuerta/codex-go/internal/config"
+)
+
+func TestSetupOff(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TelemetryConfig{}, "test")
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Expected shutting down disabled telemetry to do nothing, got %v", err)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("exit status 1"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	got, want := spans[0], spans[1]
+	if got.Name() != "child" || got.Parent().SpanID() != want.SpanContext().SpanID() {
+		t.Errorf("Expected child to be a child of parent, got %s under %s", got.Name(), got.Parent().SpanID())
+	}
+	if got.Status().Code != codes.Error || len(got.Events()) != 1 {
+		t.Errorf("Expected the failed span to record its error, got %+v", got.Status())
+	}
+	if want.Status().Code != codes.Unset {
+		t.Errorf("Expected the parent not to fail, got %+v", want.Status())
+	}
+}
@@ -0,0 +1,66 @@
+// Package truncate shortens command output to its first and last lines and
+// a byte budget, marking what was left out.
+package truncate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits bounds the size of output. Lines are not cut when HeadLines and
+// TailLines are both zero, nor bytes when MaxBytes is zero.
+type Limits struct {
+	HeadLines int // Lines kept from the start
+	TailLines int // Lines kept from the end
+	MaxBytes  int // Bytes kept in all, split between the start and the end
+}
+
+// Apply returns s within the limits and whether anything was left out.
+// Lines are cut first: when s has more than HeadLines+TailLines lines, the
+// lines in between are replaced with a note saying how many were omitted.
+// The result is then cut to MaxBytes in the same way.
+func (l Limits) Apply(s string) (string, bool) {
+	out, cutLines := l.applyLines(s)
+	out, cutBytes := l.applyBytes(out)
+	return out, cutLines || cutBytes
+}
+
+func (l Limits) applyLines(s string) (string, bool) {
+	if l.HeadLines <= 0 && l.TailLines <= 0 {
+		return s, false
+	}
+	trailing := strings.HasSuffix(s, "\n")
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) <= l.HeadLines+l.TailLines {
+		return s, false
+	}
+
+	omitted := len(lines) - l.HeadLines - l.TailLines
+	kept := make([]string, 0, l.HeadLines+l.TailLines+1)
+	kept = append(kept, lines[:l.HeadLines]...)
+	kept = append(kept, fmt.Sprintf("... [%d lines omitted] ...", omitted))
+	kept = append(kept, lines[len(lines)-l.TailLines:]...)
+	out := strings.Join(kept, "\n")
+	if trailing {
+		out += "\n"
+	}
+	return out, true
+}
+
+func (l Limits) applyBytes(s string) (string, bool) {
+	if l.MaxBytes <= 0 || len(s) <= l.MaxBytes {
+		return s, false
+	}
+	head := l.MaxBytes / 2
+	tail := l.MaxBytes - head
+	// Cut at rune boundaries so the result stays valid UTF-8
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	start := len(s) - tail
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted] ...\n%s", s[:head], start-head, s[start:]), true
+}
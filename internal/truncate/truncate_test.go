@@ -0,0 +1,52 @@
+package truncate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func numberedLines(n int) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestApplyLines(t *testing.T) {
+	text := numberedLines(10)
+	if out, cut := (Limits{HeadLines: 5, TailLines: 5}).Apply(text); cut || out != text {
+		t.Errorf("Expected output within the limits to be kept, got %q", out)
+	}
+
+	out, cut := Limits{HeadLines: 2, TailLines: 3}.Apply(text)
+	want := "line 1\nline 2\n... [5 lines omitted] ...\nline 8\nline 9\nline 10\n"
+	if !cut || out != want {
+		t.Errorf("Apply = %q, want %q", out, want)
+	}
+
+	// Only the end is kept without head lines
+	out, _ = Limits{TailLines: 1}.Apply(text)
+	if want := "... [9 lines omitted] ...\nline 10\n"; out != want {
+		t.Errorf("Apply = %q, want %q", out, want)
+	}
+}
+
+func TestApplyBytes(t *testing.T) {
+	text := strings.Repeat("é", 100) // Two bytes each
+	out, cut := Limits{MaxBytes: 21}.Apply(text)
+	if !cut || !utf8.ValidString(out) || !strings.Contains(out, "bytes omitted") {
+		t.Fatalf("Expected valid truncated output, got %q", out)
+	}
+	head, tail, _ := strings.Cut(out, "\n... [")
+	_, tail, _ = strings.Cut(tail, "] ...\n")
+	if len(head)+len(tail) > 21 || len(head) == 0 || len(tail) == 0 {
+		t.Errorf("Expected about 21 bytes from both ends, kept %d and %d", len(head), len(tail))
+	}
+
+	if out, cut := (Limits{}).Apply(text); cut || out != text {
+		t.Error("Expected no limits to keep everything")
+	}
+}
@@ -13,6 +13,7 @@ import (
 // ApprovalResultMsg is sent when the user makes a choice in the approval UI
 type ApprovalResultMsg struct {
 	Approved bool // true if approved, false if denied or cancelled
+	Always   bool // Approved with "Always allow": identical calls run without asking
 }
 
 // Styles for approval UI
@@ -70,7 +71,9 @@ type approvalKeyMap struct {
 	PageDown key.Binding
 	Approve  key.Binding
 	Deny     key.Binding
+	Always   key.Binding
 	Help     key.Binding // Added Help key
+	Stop     key.Binding // Stops the generation shown in a preview
 }
 
 func defaultApprovalKeyMap() approvalKeyMap {
@@ -111,10 +114,18 @@ func defaultApprovalKeyMap() approvalKeyMap {
 			key.WithKeys("n"),
 			key.WithHelp("n", "deny"),
 		),
+		Always: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "always allow"),
+		),
 		Help: key.NewBinding( // Added Help key binding
 			key.WithKeys("?"),
 			key.WithHelp("?", "toggle help"), // Simple toggle description
 		),
+		Stop: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "stop generation"),
+		),
 	}
 }
 
@@ -124,10 +135,13 @@ type ApprovalModel struct {
 	Description  string
 	Action       string // The *raw* arguments or content being approved
 	Approved     bool   // Tracks the currently selected option (true = yes)
+	Always       bool   // With Approved, the "Always allow" option is selected
 	YesText      string
 	NoText       string
+	AlwaysText   string // Offers a third option, "Always allow", if set
 	keyMap       approvalKeyMap
 	showFullHelp bool // Added state for toggling help
+	preview      bool // Read-only: the action is still being generated
 
 	viewport viewport.Model
 	ready    bool // Viewport readiness flag
@@ -158,6 +172,27 @@ func NewApprovalModel(title, description, action string) ApprovalModel {
 	}
 }
 
+// NewPreviewModel creates a read-only model showing an action while the
+// assistant is still generating it. It has no buttons; the caller handles
+// the Stop key and replaces it with an approval model once the action is
+// complete.
+func NewPreviewModel(title, description, action string) ApprovalModel {
+	m := NewApprovalModel(title, description, action)
+	m.preview = true
+	return m
+}
+
+// SetAction replaces the content shown, keeping the view on the end of it
+// if it was there, so a preview follows the generation as it grows
+func (m *ApprovalModel) SetAction(action string) {
+	follow := m.viewport.AtBottom()
+	m.Action = action
+	m.SetSize(m.terminalWidth, m.terminalHeight)
+	if follow {
+		m.viewport.GotoBottom()
+	}
+}
+
 // SetSize calculates layout dimensions based on terminal size
 func (m *ApprovalModel) SetSize(termWidth, termHeight int) {
 	m.terminalWidth = termWidth
@@ -299,23 +334,32 @@ func (m ApprovalModel) Update(msg tea.Msg) (ApprovalModel, tea.Cmd) {
 		if contentOverflows && isScrollingKey {
 			m.viewport, cmd = m.viewport.Update(msg)
 			cmds = append(cmds, cmd)
+		} else if m.preview {
+			if key.Matches(msg, m.keyMap.Help) {
+				m.showFullHelp = !m.showFullHelp
+				m.SetSize(m.terminalWidth, m.terminalHeight)
+			}
 		} else {
 			// Handle non-scrolling keys or if content fits
 			switch {
 			case key.Matches(msg, m.keyMap.Select):
-				m.Approved = !m.Approved // Toggle selection
+				m.selectNext(msg.String() == "left" || msg.String() == "h" || msg.String() == "shift+tab")
 
 			case key.Matches(msg, m.keyMap.Confirm):
-				cmds = append(cmds, func() tea.Msg { return ApprovalResultMsg{Approved: m.Approved} })
+				approved, always := m.Approved, m.Approved && m.Always
+				cmds = append(cmds, func() tea.Msg { return ApprovalResultMsg{Approved: approved, Always: always} })
 			case key.Matches(msg, m.keyMap.Approve):
-				m.Approved = true
+				m.Approved, m.Always = true, false
 				cmds = append(cmds, func() tea.Msg { return ApprovalResultMsg{Approved: true} })
+			case key.Matches(msg, m.keyMap.Always) && m.AlwaysText != "":
+				m.Approved, m.Always = true, true
+				cmds = append(cmds, func() tea.Msg { return ApprovalResultMsg{Approved: true, Always: true} })
 			case key.Matches(msg, m.keyMap.Deny):
-				m.Approved = false
+				m.Approved, m.Always = false, false
 				cmds = append(cmds, func() tea.Msg { return ApprovalResultMsg{Approved: false} })
 
 			case key.Matches(msg, m.keyMap.Cancel):
-				m.Approved = false // Treat cancel as denial for simplicity
+				m.Approved, m.Always = false, false // Treat cancel as denial for simplicity
 				cmds = append(cmds, func() tea.Msg { return ApprovalResultMsg{Approved: false} })
 
 			case key.Matches(msg, m.keyMap.Help):
@@ -341,29 +385,69 @@ func (m ApprovalModel) Update(msg tea.Msg) (ApprovalModel, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-// renderButtons renders the Approve/Deny buttons
+// selectNext moves the selection to the next option, or the previous one
+// if back is set, wrapping around
+func (m *ApprovalModel) selectNext(back bool) {
+	if m.AlwaysText == "" {
+		m.Approved, m.Always = !m.Approved, false
+		return
+	}
+	// The options in the order shown: Approve, Always allow, Deny
+	option := 2
+	if m.Approved {
+		option = 0
+		if m.Always {
+			option = 1
+		}
+	}
+	step := 1
+	if back {
+		step = 2
+	}
+	option = (option + step) % 3
+	m.Approved, m.Always = option != 2, option == 1
+}
+
+// renderButtons renders the Approve/Deny buttons, or the progress of a preview
 func (m ApprovalModel) renderButtons() string {
+	if m.preview {
+		return approvalDescriptionStyle.Copy().MarginBottom(0).Italic(true).Render(fmt.Sprintf("Receiving... (%d lines so far)", strings.Count(m.Action, "\n")+1))
+	}
+
 	yesStyle := approvalButtonInactiveStyle
+	alwaysStyle := approvalButtonInactiveStyle
 	noStyle := approvalButtonInactiveStyle
 
-	if m.Approved {
+	switch {
+	case m.Approved && m.Always && m.AlwaysText != "":
+		alwaysStyle = approvalButtonActiveStyle
+	case m.Approved:
 		yesStyle = approvalButtonActiveStyle
-	} else {
+	default:
 		noStyle = approvalButtonActiveStyle
 	}
 
-	yes := yesStyle.Render(m.YesText)
-	no := noStyle.Render(m.NoText)
+	buttons := []string{yesStyle.Render(m.YesText)}
+	if m.AlwaysText != "" {
+		buttons = append(buttons, alwaysStyle.Render(m.AlwaysText))
+	}
+	buttons = append(buttons, noStyle.Render(m.NoText))
 
 	// Join buttons side-by-side, centered within available space
 	// Use dialogWidth for centering context if needed, but simple join is usually fine
-	return lipgloss.JoinHorizontal(lipgloss.Center, yes, no)
+	return lipgloss.JoinHorizontal(lipgloss.Center, buttons...)
 }
 
 // renderHelp builds and renders the help string
 func (m ApprovalModel) renderHelp(maxWidth int) string {
 	// Base keys available always
 	keys := []key.Binding{m.keyMap.Select, m.keyMap.Confirm, m.keyMap.Approve, m.keyMap.Deny, m.keyMap.Cancel, m.keyMap.Help}
+	if m.AlwaysText != "" {
+		keys = []key.Binding{m.keyMap.Select, m.keyMap.Confirm, m.keyMap.Approve, m.keyMap.Always, m.keyMap.Deny, m.keyMap.Cancel, m.keyMap.Help}
+	}
+	if m.preview {
+		keys = []key.Binding{m.keyMap.Stop, m.keyMap.Help}
+	}
 
 	// Add scrolling keys if content overflows
 	if m.viewport.TotalLineCount() > m.viewport.Height {
@@ -387,7 +471,8 @@ func (m ApprovalModel) renderHelp(maxWidth int) string {
 		// Compare primary key representation for equality check
 		isApproveKey := k.Keys()[0] == m.keyMap.Approve.Keys()[0] // Assuming first key is representative
 		isDenyKey := k.Keys()[0] == m.keyMap.Deny.Keys()[0]
-		if !m.showFullHelp && (isApproveKey || isDenyKey) {
+		isAlwaysKey := k.Keys()[0] == m.keyMap.Always.Keys()[0]
+		if !m.showFullHelp && (isApproveKey || isDenyKey || isAlwaysKey) {
 			continue
 		}
 
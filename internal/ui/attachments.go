@@ -0,0 +1,206 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/epuerta/codex-go/internal/images"
+)
+
+const (
+	// MaxAttachmentBytes is how much of a text file is attached; the rest is cut off
+	MaxAttachmentBytes = 64 * 1024
+
+	// MaxAttachments is how many files may be attached to one message
+	MaxAttachments = 8
+)
+
+// attachmentBadgeStyle renders the attachment badges above the input box
+var attachmentBadgeStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("0")).
+	Background(lipgloss.Color("6")). // Cyan
+	Padding(0, 1)
+
+// Attachment is a file or image attached to the next user message
+type Attachment struct {
+	Name      string        // Display name
+	Path      string        // Source path; empty for clipboard images
+	Text      string        // Contents of a text file, possibly truncated
+	Size      int64         // Size of the original file in bytes
+	Truncated bool          // Whether Text was cut to MaxAttachmentBytes
+	Image     *images.Image // Set for image attachments instead of Text
+}
+
+// Label describes the attachment for badges and chips
+func (a Attachment) Label() string {
+	if a.Image != nil {
+		return a.Image.Summary()
+	}
+	label := fmt.Sprintf("%s (%s)", a.Name, formatSize(a.Size))
+	if a.Truncated {
+		label += fmt.Sprintf(", first %s", formatSize(MaxAttachmentBytes))
+	}
+	return label
+}
+
+// LoadAttachment reads the file at path as an image or, failing that, as text.
+// Text files are cut to MaxAttachmentBytes at a line boundary; binary files
+// are refused.
+func LoadAttachment(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return Attachment{}, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	if isImagePath(path) {
+		img, err := images.Load(path)
+		if err != nil {
+			return Attachment{}, err
+		}
+		return Attachment{Name: img.Name, Path: path, Size: info.Size(), Image: img}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, MaxAttachmentBytes+1))
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return Attachment{}, fmt.Errorf("%s looks like a binary file; only text files and images can be attached", filepath.Base(path))
+	}
+
+	att := Attachment{Name: filepath.Base(path), Path: path, Size: info.Size()}
+	if len(data) > MaxAttachmentBytes {
+		data = data[:MaxAttachmentBytes]
+		if i := bytes.LastIndexByte(data, '\n'); i > 0 {
+			data = data[:i+1]
+		}
+		att.Truncated = true
+	}
+	att.Text = string(data)
+	return att, nil
+}
+
+// isImagePath reports whether path has an image file extension
+func isImagePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// PathFromPaste returns the file path in text pasted or dropped into the
+// terminal. Terminals quote paths or escape spaces when a file is dragged in,
+// and some paste file:// URLs. It returns "" if text is not the path of an
+// existing regular file.
+func PathFromPaste(text string) string {
+	path := strings.TrimSpace(text)
+	if len(path) >= 2 && (path[0] == '\'' || path[0] == '"') && path[len(path)-1] == path[0] {
+		path = path[1 : len(path)-1]
+	} else {
+		path = strings.ReplaceAll(path, `\ `, " ")
+	}
+	if strings.HasPrefix(path, "file://") {
+		u, err := url.Parse(path)
+		if err != nil {
+			return ""
+		}
+		path = u.Path
+	}
+	if path == "" || strings.Contains(path, "\n") {
+		return ""
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	return path
+}
+
+// AttachmentPrompt appends the text attachments to content for the model.
+// Image attachments are sent separately as content parts.
+func AttachmentPrompt(content string, attachments []Attachment) string {
+	var sb strings.Builder
+	sb.WriteString(content)
+	for _, att := range attachments {
+		if att.Image != nil {
+			continue
+		}
+		name := att.Path
+		if name == "" {
+			name = att.Name
+		}
+		fmt.Fprintf(&sb, "\n\nAttached file %s:\n```\n%s", name, att.Text)
+		if !strings.HasSuffix(att.Text, "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("```")
+		if att.Truncated {
+			fmt.Fprintf(&sb, "\n(Truncated: only the first %s of %s are included.)", formatSize(int64(len(att.Text))), formatSize(att.Size))
+		}
+	}
+	return sb.String()
+}
+
+// AddAttachment attaches a file to the next message
+func (m *ChatModel) AddAttachment(att Attachment) error {
+	if len(m.attachments) >= MaxAttachments {
+		return fmt.Errorf("at most %d files can be attached to a message", MaxAttachments)
+	}
+	m.attachments = append(m.attachments, att)
+	return nil
+}
+
+// Attachments returns the files attached to the next message
+func (m *ChatModel) Attachments() []Attachment {
+	return m.attachments
+}
+
+// TakeAttachments returns the attached files and clears them
+func (m *ChatModel) TakeAttachments() []Attachment {
+	attachments := m.attachments
+	m.attachments = nil
+	return attachments
+}
+
+// attachmentBadges renders the pending attachments, or "" if there are none
+func (m ChatModel) attachmentBadges() string {
+	if len(m.attachments) == 0 {
+		return ""
+	}
+	badges := make([]string, len(m.attachments))
+	for i, att := range m.attachments {
+		icon := "📄"
+		if att.Image != nil {
+			icon = "▣"
+		}
+		badges[i] = attachmentBadgeStyle.Render(icon + " " + att.Label())
+	}
+	return infoStyle.Render(strings.Join(badges, " "))
+}
+
+// formatSize formats a byte count for display
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", (n+1023)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathFromPaste(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "my notes.txt")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pasted string
+		want   string
+	}{
+		{path, path},
+		{"'" + path + "'", path},
+		{`"` + path + `"` + "\n", path},
+		{strings.ReplaceAll(path, " ", `\ `), path},
+		{"file://" + strings.ReplaceAll(path, " ", "%20"), path},
+		{filepath.Join(dir, "missing.txt"), ""},
+		{dir, ""},
+		{"look at this file", ""},
+	}
+	for _, tt := range tests {
+		if got := PathFromPaste(tt.pasted); got != tt.want {
+			t.Errorf("PathFromPaste(%q) = %q, want %q", tt.pasted, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAttachment(t *testing.T) {
+	dir := t.TempDir()
+
+	small := filepath.Join(dir, "small.go")
+	if err := os.WriteFile(small, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	att, err := LoadAttachment(small)
+	if err != nil {
+		t.Fatalf("LoadAttachment failed: %v", err)
+	}
+	if att.Truncated || att.Text != "package main\n" || att.Name != "small.go" {
+		t.Errorf("Unexpected attachment: %+v", att)
+	}
+
+	line := strings.Repeat("x", 99) + "\n"
+	big := filepath.Join(dir, "big.log")
+	if err := os.WriteFile(big, []byte(strings.Repeat(line, 1000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	att, err = LoadAttachment(big)
+	if err != nil {
+		t.Fatalf("LoadAttachment failed: %v", err)
+	}
+	if !att.Truncated || att.Size != 100000 {
+		t.Errorf("Expected a truncated 100000 byte file, got %d bytes, truncated=%t", att.Size, att.Truncated)
+	}
+	if len(att.Text) > MaxAttachmentBytes || !strings.HasSuffix(att.Text, "\n") {
+		t.Errorf("Expected text cut at a line boundary within %d bytes, got %d bytes", MaxAttachmentBytes, len(att.Text))
+	}
+
+	binary := filepath.Join(dir, "a.out")
+	if err := os.WriteFile(binary, []byte{0x7f, 'E', 'L', 'F', 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAttachment(binary); err == nil {
+		t.Error("Expected an error for a binary file")
+	}
+	if _, err := LoadAttachment(dir); err == nil {
+		t.Error("Expected an error for a directory")
+	}
+}
+
+func TestAttachmentPrompt(t *testing.T) {
+	attachments := []Attachment{
+		{Name: "main.go", Path: "cmd/main.go", Text: "package main", Size: 12},
+		{Name: "big.log", Path: "big.log", Text: "line\n", Size: 1 << 20, Truncated: true},
+	}
+	got := AttachmentPrompt("Explain these", attachments)
+	want := "Explain these" +
+		"\n\nAttached file cmd/main.go:\n```\npackage main\n```" +
+		"\n\nAttached file big.log:\n```\nline\n```\n(Truncated: only the first 5 B of 1.0 MB are included.)"
+	if got != want {
+		t.Errorf("AttachmentPrompt() =\n%s\nwant\n%s", got, want)
+	}
+
+	var m ChatModel
+	for i := 0; i < MaxAttachments; i++ {
+		if err := m.AddAttachment(Attachment{Name: "f"}); err != nil {
+			t.Fatalf("AddAttachment %d failed: %v", i, err)
+		}
+	}
+	if err := m.AddAttachment(Attachment{Name: "f"}); err == nil {
+		t.Error("Expected an error past MaxAttachments")
+	}
+	if taken := m.TakeAttachments(); len(taken) != MaxAttachments || len(m.Attachments()) != 0 {
+		t.Errorf("Expected TakeAttachments to return %d and clear them, got %d with %d left", MaxAttachments, len(taken), len(m.Attachments()))
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/epuerta/codex-go/internal/agent"
 	"github.com/epuerta/codex-go/internal/fileops"
+	"github.com/epuerta/codex-go/internal/inputhistory"
 	"github.com/epuerta/codex-go/internal/logging"
+	"github.com/epuerta/codex-go/internal/truncate"
 	"github.com/google/uuid"
 )
 
@@ -51,6 +53,11 @@ var (
 			Foreground(lipgloss.Color("7")).
 			PaddingLeft(1)
 
+	noticeStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("11")). // Bright yellow
+			Bold(true).
+			PaddingLeft(1)
+
 	errorStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("1")).
 			Bold(true).
@@ -90,6 +97,11 @@ var (
 				Foreground(lipgloss.Color("1")). // Red
 				Bold(true).
 				PaddingLeft(1)
+
+	attachmentChipStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("0")).
+				Background(lipgloss.Color("13")). // Bright magenta
+				Padding(0, 1)
 )
 
 // CommandResult represents the result of a command execution
@@ -100,8 +112,50 @@ type CommandResult struct {
 	ExitCode int           `json:"exit_code"`
 	Duration time.Duration `json:"duration"`
 	Error    error         `json:"-"` // Don't marshal error
+
+	Running     bool `json:"-"`                     // Output is still streaming in Stdout
+	Interrupted bool `json:"interrupted,omitempty"` // Stopped by the user
+	TimedOut    bool `json:"timed_out,omitempty"`   // Stopped when its timeout expired
+}
+
+// commandOutput returns the output a command message shows, with the label
+// telling which output it is
+func commandOutput(r *CommandResult) (prefix, output string) {
+	switch {
+	case r.Running:
+		return "command.output", lastLines(r.Stdout, liveOutputLines)
+	case r.Interrupted:
+		return "command.interrupted", r.Stdout + r.Stderr
+	case r.TimedOut:
+		return "command.timeout", r.Stdout + r.Stderr
+	case r.ExitCode == 0:
+		return "command.stdout", r.Stdout
+	}
+	output = r.Stderr
+	if output == "" && r.Error != nil {
+		output = r.Error.Error()
+	}
+	return "command.stderr", output
 }
 
+// Lines around the viewport: the status bar above it, and the help, the
+// input box and the thinking indicator below it
+const (
+	statusBarHeight = 1
+	footerHeight    = 5
+)
+
+// liveOutputLines is how many of the latest lines a running command shows
+const liveOutputLines = 20
+
+// Roles of the chat messages that are not the conversation's own turns.
+// RoleNotice messages are for the user only; RoleSystem messages mirror
+// system messages of the conversation history.
+const (
+	RoleNotice = "notice"
+	RoleSystem = "system"
+)
+
 // Message represents a chat message
 type Message struct {
 	Role      string    `json:"role"`
@@ -109,12 +163,23 @@ type Message struct {
 	Timestamp time.Time `json:"timestamp"`
 	ANSI      bool      `json:"ansi"` // Whether the content contains ANSI escape codes
 
+	// Labels of the files attached to a user message, shown as chips below the text
+	Attachments []string `json:"attachments,omitempty"`
+
 	// For thinking state - Maybe remove if only using status bar?
 	// IsThinking    bool      `json:"-"`
 	// ThinkingStart time.Time `json:"-"`
 
 	// For command execution - Store the result directly
 	CommandResult *CommandResult `json:"command_result,omitempty"`
+
+	// For approval annotations - the recorded decision on a function call
+	Approval *agent.ApprovalEvent `json:"approval,omitempty"`
+
+	// Whether the user expanded the output of a collapsible block
+	Expanded bool `json:"-"`
+	// How the output is folded when rendered, set by ChatModel
+	fold outputFold
 }
 
 // SendMessageCmd is a tea.Cmd to signal sending a message
@@ -128,6 +193,8 @@ type ChatModel struct {
 	viewport       viewport.Model
 	textInput      CustomTextInput
 	ready          bool
+	content        string        // The rendered conversation, before search highlighting
+	search         *scrollSearch // The search of the conversation, if any
 	width          int
 	height         int
 	agent          agent.Agent    // Reference to the agent for history access
@@ -141,12 +208,40 @@ type ChatModel struct {
 	thinkingStart time.Time
 	thinkingSub   chan time.Time // For thinking timer updates
 	currentStatus string         // Current status message during thinking
+	tickPending   bool           // A thinkTickMsg is on its way
+
+	notice string // Shown in place of the key help while set
+
+	// The effective configuration, shown above the messages
+	startupSummary string
+	startupWarn    bool
 
 	// Status bar info
 	sessionID    string
 	workDir      string
 	model        string
 	approvalMode string
+	toolsInfo    string // Summary of the agent's tools, shown after the approval mode
+
+	// Files and images attached to the next message
+	attachments []Attachment
+
+	// The result of the command whose output is streaming, if any
+	liveCommand *CommandResult
+
+	// outputLimits truncate the output of finished commands
+	outputLimits truncate.Limits
+
+	// What the status bar shows, and the state of the repository
+	statusItems []string // Items of the status bar; nil for DefaultStatusItems
+	gitBranch   string
+	gitChanged  int
+
+	// Outputs longer than collapseLines are collapsed; 0 shows them whole
+	collapseLines int
+	blocks        []int       // Indices of the shown messages whose output collapses
+	blockLines    map[int]int // Line of the viewport each of blocks starts at
+	selected      int         // Index of the block ctrl+o toggles, or -1 for the latest
 
 	// Callbacks
 	onSendMessage func(content string)
@@ -170,6 +265,7 @@ func NewChatModel() ChatModel {
 		model:          "o4-mini",            // Default model
 		approvalMode:   "suggest",            // Default approval mode
 		logger:         &logging.NilLogger{}, // Default to nil logger
+		selected:       -1,
 	}
 }
 
@@ -207,6 +303,11 @@ func (m *ChatModel) SetSessionInfo(sessionID, workDir, model, approvalMode strin
 	}
 }
 
+// SetToolsInfo sets the summary of the agent's tools shown in the status bar
+func (m *ChatModel) SetToolsInfo(info string) {
+	m.toolsInfo = info
+}
+
 // SetAgent sets the agent reference for history access
 func (m *ChatModel) SetAgent(a agent.Agent) {
 	m.agent = a
@@ -227,7 +328,7 @@ func (m *ChatModel) SetLogger(logger logging.Logger) {
 }
 
 // Init initializes the model
-func (m ChatModel) Init() tea.Cmd {
+func (m *ChatModel) Init() tea.Cmd {
 	return tea.Batch(textinput.Blink, tea.EnterAltScreen, m.thinkTick())
 }
 
@@ -250,6 +351,16 @@ func (m *ChatModel) AddUserMessage(content string) {
 	})
 }
 
+// AddUserMessageWithAttachments adds a user message with a chip for each attachment label
+func (m *ChatModel) AddUserMessageWithAttachments(content string, attachments []string) {
+	m.AddMessage(Message{
+		Role:        "user",
+		Content:     content,
+		Attachments: attachments,
+		Timestamp:   time.Now(),
+	})
+}
+
 // AddAssistantMessage adds an assistant message to the local messages
 func (m *ChatModel) AddAssistantMessage(content string) {
 	// Use logger instead of direct stderr output
@@ -264,15 +375,38 @@ func (m *ChatModel) AddAssistantMessage(content string) {
 	})
 }
 
-// AddSystemMessage adds a system message to the local messages
+// AddNotice adds status text for the user, such as command output or
+// "waiting for the assistant". Notices only ever live in the chat: they are
+// not part of the conversation history, so the model never sees them.
+func (m *ChatModel) AddNotice(content string) {
+	m.AddMessage(Message{
+		Role:      RoleNotice,
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+}
+
+// AddSystemMessage shows a system message of the conversation history, which
+// the model does see, such as the repository context of a resumed session.
+// Use AddNotice for anything else.
 func (m *ChatModel) AddSystemMessage(content string) {
 	m.AddMessage(Message{
-		Role:      "system",
+		Role:      RoleSystem,
 		Content:   content,
 		Timestamp: time.Now(),
 	})
 }
 
+// AddApprovalMessage annotates the transcript with the decision on a function call
+func (m *ChatModel) AddApprovalMessage(event agent.ApprovalEvent) {
+	m.AddMessage(Message{
+		Role:      "approval",
+		Content:   event.String(),
+		Timestamp: event.Timestamp,
+		Approval:  &event,
+	})
+}
+
 // AddFunctionCallMessage adds a function call message to the local messages
 func (m *ChatModel) AddFunctionCallMessage(name, args string) {
 	// Use logger instead of direct stderr output
@@ -302,35 +436,19 @@ func (m *ChatModel) AddFunctionResultMessage(result string, isError bool) {
 	})
 }
 
-// AddPatchResultMessage adds a formatted patch result message to the local messages
-// This handles the CustomPatchResult type.
-func (m *ChatModel) AddPatchResultMessage(result *fileops.CustomPatchResult) {
-	var content string
-
-	if result.Success {
-		prefix := "[✓ Patch Applied]"
-		content = fmt.Sprintf("%s Op: %s, Path: %s, Lines: %d -> %d",
-			prefix,
-			result.Operation,
-			result.Path,
-			result.OriginalLines,
-			result.NewLines,
-		)
+// AddDryRunMessage shows the diff of a file change simulated in dry-run
+// mode, which was not written
+func (m *ChatModel) AddDryRunMessage(diff string) {
+	content := "[Dry Run] Nothing was written."
+	if diff == "" {
+		content += " No file would change."
 	} else {
-		prefix := "[✗ Patch Failed] "
-		content = fmt.Sprintf("%s Op: %s, Path: %s, Error: %v",
-			prefix,
-			result.Operation,
-			result.Path,
-			result.Error,
-		)
+		content += " The change would be:\n" + strings.TrimSuffix(diff, "\n")
 	}
-
 	m.AddMessage(Message{
-		Role:      "patch_result", // Use a specific role
+		Role:      "patch_result",
 		Content:   content,
 		Timestamp: time.Now(),
-		// Use the calculated style for this specific message type (handled in formatMessage)
 	})
 }
 
@@ -435,6 +553,7 @@ func (m *ChatModel) ClearHistory() {
 		m.agent.ClearHistory()
 	}
 	m.messages = []Message{}
+	m.selected = -1
 	if m.ready {
 		m.updateViewport()
 	}
@@ -447,19 +566,22 @@ func (m *ChatModel) updateViewport() {
 	// --- REMOVED History Merging Logic ---
 	// We will now only render messages explicitly added to m.messages by the App
 	var allMessages []Message
+	var allIndices []int // Index in m.messages of each of allMessages
 
 	// Build the list of messages to display ONLY from local m.messages
-	for _, msg := range m.messages {
+	for i, msg := range m.messages {
 		// Skip system messages if hidden OR any message containing DEBUG:
-		if (m.hideSystemMsgs && msg.Role == "system") ||
+		if (m.hideSystemMsgs && (msg.Role == RoleSystem || msg.Role == RoleNotice)) ||
 			strings.Contains(msg.Content, "DEBUG:") {
 			continue
 		}
 		allMessages = append(allMessages, msg)
+		allIndices = append(allIndices, i)
 	}
 
 	// --- NEW: Filter out function call/result messages if a subsequent assistant message exists ---
 	filteredMessages := []Message{}
+	filteredIndices := []int{}
 	assistantResponseFound := false
 	// Iterate backwards to easily find the last assistant message
 	for i := len(allMessages) - 1; i >= 0; i-- {
@@ -471,17 +593,32 @@ func (m *ChatModel) updateViewport() {
 
 	if assistantResponseFound {
 		// If an assistant response exists, filter out preceding function messages
-		for _, msg := range allMessages {
+		for i, msg := range allMessages {
 			if msg.Role != "function_call" && msg.Role != "function_result" {
 				filteredMessages = append(filteredMessages, msg)
+				filteredIndices = append(filteredIndices, allIndices[i])
 			}
 		}
 	} else {
 		// If no assistant response found yet (e.g., during the function call), keep all messages
 		filteredMessages = allMessages
+		filteredIndices = allIndices
 	}
 	// --- End Filtering ---
 
+	// Long outputs are collapsed unless expanded; ctrl+o toggles the
+	// selected one
+	m.findBlocks(filteredIndices)
+	selected := m.selectedBlock()
+	m.blockLines = make(map[int]int, len(m.blocks))
+	lines := 0
+
+	if startup := m.startupView(); startup != "" {
+		sb.WriteString(startup)
+		sb.WriteString("\n\n")
+		lines += strings.Count(startup, "\n") + 2
+	}
+
 	// Render the filtered messages with a separator between them
 	for i, msg := range filteredMessages { // Use filteredMessages now
 		// Add a separator line between messages
@@ -493,27 +630,28 @@ func (m *ChatModel) updateViewport() {
 			separator := separatorStyle.Render("───────────────────")
 			sb.WriteString(separator)
 			sb.WriteString("\n\n")
+			lines += strings.Count(separator, "\n") + 2
 		}
 
-		formattedMsg := formatMessage(msg, m.width-2, m.showTimestamps)
+		index := filteredIndices[i]
+		if m.collapsible(msg) {
+			msg.fold = outputFold{collapse: !msg.Expanded, lines: m.collapseLines, selected: index == selected}
+			m.blockLines[index] = lines
+		}
+		formattedMsg := formatMessage(msg, m.width-2, m.showTimestamps, m.outputLimits)
 		sb.WriteString(formattedMsg)
 		sb.WriteString("\n\n")
+		lines += strings.Count(formattedMsg, "\n") + 2
 	}
 
-	finalContent := sb.String()
-
-	// Set the viewport content
-	m.viewport.SetContent(finalContent)
-
-	// Safety check - only scroll to bottom if there's content and viewport is properly sized
-	if len(finalContent) > 0 && m.viewport.Height > 0 {
-		// Scroll to the bottom
-		m.viewport.GotoBottom()
-	}
+	// Set the viewport content, following it to the bottom unless the
+	// user scrolled back
+	m.setContent(sb.String())
 }
 
-// formatMessage formats a single message for display
-func formatMessage(msg Message, width int, showTimestamp bool) string {
+// formatMessage formats a single message for display, truncating command
+// output to limits
+func formatMessage(msg Message, width int, showTimestamp bool, limits truncate.Limits) string {
 	var prefix string
 	var style lipgloss.Style
 	var renderedContent string
@@ -535,6 +673,9 @@ func formatMessage(msg Message, width int, showTimestamp bool) string {
 
 		// Combine prefix and content
 		prefixedContent := style.Render(prefix) + " " + renderedContent
+		if len(msg.Attachments) > 0 {
+			prefixedContent += "\n" + renderAttachmentChips(msg.Attachments)
+		}
 
 		// Apply border
 		finalRendered = borderStyle.Render(prefixedContent)
@@ -544,8 +685,12 @@ func formatMessage(msg Message, width int, showTimestamp bool) string {
 		prefix = "codex"
 		style = assistantStyle.Copy().Bold(true)                     // Make assistant messages bold
 		renderedContent = wordWrap(msg.Content, width-len(prefix)-6) // Account for border and padding
-	case "system":
-		prefix = "system"
+	case RoleNotice, RoleSystem:
+		// System messages are labelled as the context the model sees
+		prefix = "notice"
+		if msg.Role == RoleSystem {
+			prefix = "context"
+		}
 		style = systemStyle
 		renderedContent = wordWrap(msg.Content, width-len(prefix)-2)
 	case "thinking":
@@ -564,27 +709,21 @@ func formatMessage(msg Message, width int, showTimestamp bool) string {
 		// Render the result if available
 		formattedResult := ""
 		if msg.CommandResult != nil {
-			resultPrefix := ""
 			resultStyle := commandOutputStyle // Use existing style
-			resultOutput := ""
-
-			if msg.CommandResult.ExitCode == 0 {
-				resultPrefix = "command.stdout"
-				resultOutput = msg.CommandResult.Stdout
-			} else {
-				resultPrefix = "command.stderr"
-				resultOutput = msg.CommandResult.Stderr
-				if resultOutput == "" && msg.CommandResult.Error != nil {
-					resultOutput = msg.CommandResult.Error.Error()
-				}
-			}
+			resultPrefix, resultOutput := commandOutput(msg.CommandResult)
 
 			// Add metadata
 			metadata := fmt.Sprintf("(code: %d, duration: %s)",
 				msg.CommandResult.ExitCode,
 				msg.CommandResult.Duration.Round(time.Millisecond)) // More precision for duration
+			if msg.CommandResult.Running {
+				metadata = "(running; Ctrl+C stops it)"
+			}
 
-			// TODO: Implement truncation logic like "... (X more lines)"
+			if !msg.CommandResult.Running {
+				resultOutput, _ = limits.Apply(resultOutput)
+				resultOutput = msg.fold.apply(resultOutput)
+			}
 			formattedResult = resultStyle.Render(resultPrefix+" "+metadata) + "\n" + resultOutput
 		}
 
@@ -604,6 +743,13 @@ func formatMessage(msg Message, width int, showTimestamp bool) string {
 	case "function_result":
 		prefix = "tool.result"
 		style = commandOutputStyle // Reuse style for now
+		renderedContent = wordWrap(msg.fold.apply(msg.Content), width-len(prefix)-2)
+	case "approval":
+		prefix = "approval"
+		style = patchFailureStyle
+		if msg.Approval != nil && msg.Approval.Approved() {
+			style = patchSuccessStyle
+		}
 		renderedContent = wordWrap(msg.Content, width-len(prefix)-2)
 	case "patch_result": // Handle the new message role
 		// Determine style based on success prefix
@@ -653,6 +799,15 @@ func formatMessage(msg Message, width int, showTimestamp bool) string {
 	return finalRendered
 }
 
+// renderAttachmentChips renders one chip per attachment label
+func renderAttachmentChips(labels []string) string {
+	chips := make([]string, len(labels))
+	for i, label := range labels {
+		chips[i] = attachmentChipStyle.Render(label)
+	}
+	return strings.Join(chips, " ")
+}
+
 // Helper function to truncate content for logs
 func truncateForLog(content string, maxLen int) string {
 	if len(content) <= maxLen {
@@ -661,15 +816,20 @@ func truncateForLog(content string, maxLen int) string {
 	return content[:maxLen] + "..."
 }
 
-// Update handles messages for the model
-func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+// Update handles messages for the model. It changes the model in place, so
+// the state callers set between updates is never lost
+func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		cmd  tea.Cmd
 		cmds []tea.Cmd
 	)
 
 	// Handle think tick
-	if _, ok := msg.(thinkTickMsg); ok && m.isThinking {
+	if _, ok := msg.(thinkTickMsg); ok {
+		m.tickPending = false
+		if !m.isThinking {
+			return m, nil
+		}
 		if m.ready {
 			m.updateViewport()
 		}
@@ -678,12 +838,47 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.search != nil && m.handleSearchKey(msg) {
+			return m, nil
+		}
+		if m.textInput.HandleHistoryKey(msg) {
+			return m, nil
+		}
 		switch msg.Type {
+		case tea.KeyCtrlF:
+			m.StartSearch()
+			return m, nil
+		case tea.KeyCtrlO:
+			m.ToggleBlock()
+			return m, nil
+		case tea.KeyShiftUp:
+			m.SelectBlock(-1)
+			return m, nil
+		case tea.KeyShiftDown:
+			m.SelectBlock(1)
+			return m, nil
+		case tea.KeyPgUp, tea.KeyPgDown, tea.KeyCtrlHome, tea.KeyCtrlEnd:
+			m.handleScrollKey(msg)
+			return m, nil
+		case tea.KeyUp, tea.KeyDown:
+			// Up and down move through the completion popup while it is shown
+			if !m.textInput.PopupVisible() {
+				m.handleScrollKey(msg)
+			}
 		case tea.KeyEnter:
 			// Only handle enter if there's text input
 			if m.textInput.Value() != "" {
 				userMsg := m.textInput.Value()
 				m.textInput.SetValue("") // Clear input here
+				if m.ready {
+					// Show the reply as it comes, however far back the view was
+					m.viewport.GotoBottom()
+				}
+				if err := m.textInput.Remember(userMsg); err != nil && m.logger != nil {
+					m.logger.Log("Failed to save input history: %v", err)
+				}
+				// The request may add or remove files
+				m.textInput.RefreshFiles()
 				// Return a command that sends the UserInputSubmitMsg
 				return m, func() tea.Msg {
 					return UserInputSubmitMsg{Content: userMsg}
@@ -708,8 +903,7 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Set up the viewport if not ready
 		if !m.ready {
-			headerHeight := 6 // Status bar takes up space
-			footerHeight := 3 // Input box and help text take up space
+			headerHeight := statusBarHeight
 
 			// Make sure we have a valid height to work with
 			viewportHeight := msg.Height - headerHeight - footerHeight
@@ -718,6 +912,7 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			m.viewport = viewport.New(msg.Width, viewportHeight)
+			m.viewport.KeyMap = chatScrollKeys
 			m.viewport.YPosition = headerHeight
 			// m.viewport.HighPerformanceRendering = true // Disable this for debugging
 
@@ -732,7 +927,7 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			// If already initialized, just resize the viewport
 			// Make sure we have a valid height to work with
-			viewportHeight := msg.Height - 9
+			viewportHeight := msg.Height - statusBarHeight - footerHeight
 			if viewportHeight < 1 {
 				viewportHeight = 1 // Ensure minimum height of 1
 			}
@@ -744,7 +939,8 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	// Only update the viewport if we're ready
+	// Only update the viewport if we're ready. It scrolls with the mouse;
+	// the keys that scroll it are handled above.
 	if m.ready {
 		// Update viewport
 		m.viewport, cmd = m.viewport.Update(msg)
@@ -773,44 +969,13 @@ func (m ChatModel) View() string {
 		return "Initializing..."
 	}
 
-	// Create status bar
-	sessionStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("7")).
-		Background(lipgloss.Color("0")).
-		Bold(true).
-		Width(m.width).
-		Padding(0, 1)
-
-	statusLine1 := sessionStyle.Render("codex-go")
-
-	// Add thinking indicator to the status bar if active
-	statusInfo := fmt.Sprintf("localhost session: %s\n• workdir: %s\n• model: %s\n• approval: %s",
-		m.sessionID, m.workDir, m.model, m.approvalMode)
-
-	if m.isThinking {
-		elapsed := time.Since(m.thinkingStart).Round(time.Second)
-		thinkingStatus := fmt.Sprintf("THINKING: %s", elapsed)
-		if m.currentStatus != "" {
-			thinkingStatus += fmt.Sprintf(" - %s", m.currentStatus)
-		}
-		// Add thinking status to status bar with bright color
-		statusInfo += fmt.Sprintf("\n• %s", lipgloss.NewStyle().
-			Foreground(lipgloss.Color("11")). // Bright yellow
-			Bold(true).
-			Render(thinkingStatus))
-	}
-
-	statusLine2 := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("7")).
-		Background(lipgloss.Color("0")).
-		Padding(0, 1).
-		Width(m.width).
-		Render(statusInfo)
-
-	statusBar := lipgloss.JoinVertical(lipgloss.Left, statusLine1, statusLine2)
+	statusBar := m.statusBarView()
 
 	// Add key bindings help
 	helpText := infoStyle.Render("send q or ctrl+c to exit | send \"/clear\" to reset | send \"/help\" for commands | press enter to send")
+	if m.notice != "" {
+		helpText = noticeStyle.Render(m.notice)
+	}
 
 	// Get viewport content - make sure we've updated it
 	// No need to force update on every view since we already do it after message processing
@@ -840,13 +1005,24 @@ func (m ChatModel) View() string {
 		viewContent += thinkingStyle.Render(thinkingText)
 	}
 
+	// Show attachments for the next message right above the input
+	inputView := m.textInput.View()
+	if m.search != nil {
+		inputView = m.searchView()
+	} else if popup := m.textInput.PopupView(); popup != "" {
+		inputView = popup + "\n" + inputView
+	}
+	if badges := m.attachmentBadges(); badges != "" {
+		inputView = badges + "\n" + inputView
+	}
+
 	// Combine the status bar, viewport, help text, and textinput
 	finalView := fmt.Sprintf(
 		"%s\n%s\n%s\n%s\n",
 		statusBar,
 		viewContent, // Use our adjusted viewport content
 		helpText,
-		m.textInput.View(),
+		inputView,
 	)
 	return finalView
 }
@@ -854,7 +1030,13 @@ func (m ChatModel) View() string {
 // Simple ticker for thinking updates
 type thinkTickMsg struct{}
 
-func (m ChatModel) thinkTick() tea.Cmd {
+// thinkTick schedules the next refresh of the thinking time, unless one is
+// already scheduled
+func (m *ChatModel) thinkTick() tea.Cmd {
+	if m.tickPending {
+		return nil
+	}
+	m.tickPending = true
 	return tea.Tick(time.Millisecond*200, func(t time.Time) tea.Msg {
 		return thinkTickMsg{}
 	})
@@ -879,6 +1061,15 @@ func (m *ChatModel) StartThinking() {
 	}
 }
 
+// ThinkingTime returns how long the assistant has been thinking, or 0 if it
+// is not
+func (m ChatModel) ThinkingTime() time.Duration {
+	if !m.isThinking {
+		return 0
+	}
+	return time.Since(m.thinkingStart)
+}
+
 // StopThinking stops the thinking timer
 func (m *ChatModel) StopThinking() {
 	m.isThinking = false
@@ -999,6 +1190,20 @@ func (m *ChatModel) AddCommandMessage(cmdStr string, result *CommandResult) {
 		m.logger.Log("AddCommandMessage called with command: %s", cmdStr)
 	}
 
+	// A command that streamed its output gets its result in place
+	if m.liveCommand != nil {
+		for i := len(m.messages) - 1; i >= 0; i-- {
+			if m.messages[i].CommandResult == m.liveCommand {
+				m.liveCommand = nil
+				m.messages[i].Content = cmdStr
+				m.messages[i].CommandResult = result
+				m.ForceUpdateViewport()
+				return
+			}
+		}
+		m.liveCommand = nil
+	}
+
 	m.AddMessage(Message{
 		Role:          "command",
 		Content:       cmdStr,
@@ -1007,6 +1212,56 @@ func (m *ChatModel) AddCommandMessage(cmdStr string, result *CommandResult) {
 	})
 }
 
+// StartCommandMessage adds a message for a command that has started running.
+// Its output is added with AppendCommandOutput as it is produced, and the
+// next AddCommandMessage completes it with the result.
+func (m *ChatModel) StartCommandMessage(cmdStr string) {
+	m.liveCommand = &CommandResult{Command: cmdStr, Running: true}
+	m.AddMessage(Message{
+		Role:          "command",
+		Content:       cmdStr,
+		Timestamp:     time.Now(),
+		CommandResult: m.liveCommand,
+	})
+}
+
+// AppendCommandOutput adds output to the running command's message and
+// reports whether there is one
+func (m *ChatModel) AppendCommandOutput(output string) bool {
+	if m.liveCommand == nil {
+		return false
+	}
+	m.liveCommand.Stdout += output
+	m.ForceUpdateViewport()
+	return true
+}
+
+// RemoveCommandMessage removes the message added by StartCommandMessage if
+// the command did not run after all
+func (m *ChatModel) RemoveCommandMessage() {
+	if m.liveCommand == nil {
+		return
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].CommandResult == m.liveCommand {
+			m.messages = append(m.messages[:i], m.messages[i+1:]...)
+			break
+		}
+	}
+	m.liveCommand = nil
+	m.ForceUpdateViewport()
+}
+
+// lastLines returns the last n lines of s, noting how many were left out
+func lastLines(s string, n int) string {
+	s = strings.TrimSuffix(s, "\n")
+	lines := strings.Split(s, "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return fmt.Sprintf("... (%d earlier lines)\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
+
 // InputIsEmpty returns true if the input field is empty
 func (m ChatModel) InputIsEmpty() bool {
 	return m.textInput.Value() == ""
@@ -1017,11 +1272,47 @@ func (m *ChatModel) InputValue() string {
 	return m.textInput.Value()
 }
 
+// SetOutputLimits sets how the output of finished commands is truncated;
+// zero limits show it in full
+func (m *ChatModel) SetOutputLimits(limits truncate.Limits) {
+	m.outputLimits = limits
+}
+
+// SetSuggestions sets the completions offered in the text input, such as
+// slash commands
+func (m *ChatModel) SetSuggestions(suggestions []Suggestion) {
+	m.textInput.SetSuggestions(suggestions)
+}
+
+// SetInputHistory sets the prompts the up and down keys and ctrl+r recall
+// into the text input
+func (m *ChatModel) SetInputHistory(h *inputhistory.History) {
+	m.textInput.SetHistory(h)
+}
+
+// SearchingHistory reports whether a reverse search of the input history is
+// in progress, during which esc cancels the search
+func (m ChatModel) SearchingHistory() bool {
+	return m.textInput.Searching()
+}
+
+// SetFileLister sets how the repository files completed after @ in the
+// text input are listed
+func (m *ChatModel) SetFileLister(list func() []string) {
+	m.textInput.SetFileLister(list)
+}
+
 // SetInputValue sets the value of the text input
 func (m *ChatModel) SetInputValue(s string) {
 	m.textInput.SetValue(s)
 }
 
+// SetNotice shows a notice in place of the key help, such as a request to
+// confirm quitting. An empty notice restores the help.
+func (m *ChatModel) SetNotice(notice string) {
+	m.notice = notice
+}
+
 // ForceUpdateViewport explicitly calls updateViewport if the model is ready.
 func (m *ChatModel) ForceUpdateViewport() {
 	// Only force update if the viewport is ready
@@ -1033,6 +1324,8 @@ func (m *ChatModel) ForceUpdateViewport() {
 // ClearMessages clears the locally displayed messages in the UI.
 func (m *ChatModel) ClearMessages() {
 	m.messages = []Message{}
+	m.liveCommand = nil
+	m.selected = -1
 	// Optionally, force a viewport update after clearing
 	m.ForceUpdateViewport()
 }
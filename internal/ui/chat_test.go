@@ -0,0 +1,312 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/truncate"
+)
+
+func TestCommandMessageStreaming(t *testing.T) {
+	m := NewChatModel()
+	if m.AppendCommandOutput("lost") {
+		t.Error("Expected output without a running command to be dropped")
+	}
+
+	m.StartCommandMessage("go test ./...")
+	m.AppendCommandOutput("ok  \tpkg/a\n")
+	m.AppendCommandOutput("ok  \tpkg/b\n")
+	if len(m.messages) != 1 || !m.messages[0].CommandResult.Running || m.messages[0].CommandResult.Stdout != "ok  \tpkg/a\nok  \tpkg/b\n" {
+		t.Fatalf("Unexpected live message: %+v", m.messages)
+	}
+
+	// The result completes the live message in place
+	m.AddNotice("note")
+	m.AddCommandMessage("go test ./...", &CommandResult{Command: "go test ./...", Stdout: "done"})
+	if len(m.messages) != 2 || m.messages[0].CommandResult.Running || m.messages[0].CommandResult.Stdout != "done" {
+		t.Errorf("Expected the live message to get the result, got %+v", m.messages)
+	}
+	if m.AppendCommandOutput("late") {
+		t.Error("Expected no running command after the result")
+	}
+
+	// Later results are added as new messages
+	m.AddCommandMessage("ls", &CommandResult{Command: "ls"})
+	if len(m.messages) != 3 {
+		t.Errorf("Expected a new message, got %d messages", len(m.messages))
+	}
+
+	m.StartCommandMessage("make lint")
+	m.RemoveCommandMessage()
+	if len(m.messages) != 3 || m.liveCommand != nil {
+		t.Errorf("Expected the live message to be removed, got %d messages", len(m.messages))
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 5; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	text := strings.Join(lines, "\n") + "\n"
+	if got := lastLines(text, 10); got != strings.TrimSuffix(text, "\n") {
+		t.Errorf("lastLines kept %q", got)
+	}
+	if got, want := lastLines(text, 2), "... (3 earlier lines)\nline 4\nline 5"; got != want {
+		t.Errorf("lastLines = %q, want %q", got, want)
+	}
+}
+
+func TestFormatMessageTruncatesCommandOutput(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 50; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	msg := Message{Role: "command", Content: "seq 50", CommandResult: &CommandResult{Command: "seq 50", Stdout: strings.Join(lines, "\n") + "\n"}}
+
+	full := formatMessage(msg, 80, false, truncate.Limits{})
+	if !strings.Contains(full, "line 25") {
+		t.Error("Expected the full output without limits")
+	}
+	short := formatMessage(msg, 80, false, truncate.Limits{HeadLines: 2, TailLines: 2})
+	if strings.Contains(short, "line 25") || !strings.Contains(short, "line 2\n") || !strings.Contains(short, "line 50") || !strings.Contains(short, "[46 lines omitted]") {
+		t.Errorf("Expected the first and last lines, got:\n%s", short)
+	}
+}
+
+func TestFormatMessageNoticeAndSystem(t *testing.T) {
+	notice := formatMessage(Message{Role: RoleNotice, Content: "Waiting for the assistant..."}, 80, false, truncate.Limits{})
+	if !strings.Contains(notice, "notice") || !strings.Contains(notice, "Waiting for the assistant...") {
+		t.Errorf("Unexpected notice: %q", notice)
+	}
+	system := formatMessage(Message{Role: RoleSystem, Content: "Repository Context: ..."}, 80, false, truncate.Limits{})
+	if !strings.Contains(system, "context") || strings.Contains(system, "notice") {
+		t.Errorf("Expected a system message to be labelled as context, got %q", system)
+	}
+}
+
+func TestUserMessageAttachmentChips(t *testing.T) {
+	m := NewChatModel()
+	m.AddUserMessageWithAttachments("What is on this screenshot?", []string{"screen.png (1.2 MB)", "notes.txt"})
+	if got := m.messages[0].Attachments; len(got) != 2 {
+		t.Fatalf("Expected 2 attachments, got %v", got)
+	}
+
+	lines := strings.Split(ansi.Strip(formatMessage(m.messages[0], 80, false, truncate.Limits{})), "\n")
+	text, chips := -1, -1
+	for i, line := range lines {
+		if strings.Contains(line, "What is on this screenshot?") {
+			text = i
+		}
+		if strings.Contains(line, "screen.png (1.2 MB)") && strings.Contains(line, "notes.txt") {
+			chips = i
+		}
+	}
+	if text < 0 || chips <= text {
+		t.Errorf("Expected the chips on a line below the text, got:\n%s", strings.Join(lines, "\n"))
+	}
+
+	m.AddUserMessage("no attachments")
+	if plain := ansi.Strip(formatMessage(m.messages[1], 80, false, truncate.Limits{})); strings.Contains(plain, "screen.png") {
+		t.Errorf("Expected no chips without attachments, got:\n%s", plain)
+	}
+}
+
+func TestThinkTick(t *testing.T) {
+	m := NewChatModel()
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+	m.StartThinking()
+
+	// Only one tick is scheduled however many messages arrive
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")}); cmd == nil {
+		t.Fatal("Expected a tick while thinking")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	if !m.tickPending || m.InputValue() != "ab" {
+		t.Fatalf("Expected a pending tick and the typed input, got %t and %q", m.tickPending, m.InputValue())
+	}
+	if _, cmd := m.Update(thinkTickMsg{}); cmd == nil || !m.tickPending {
+		t.Error("Expected the tick to schedule the next one")
+	}
+
+	// The tick stops with the thinking
+	m.StopThinking()
+	if _, cmd := m.Update(thinkTickMsg{}); cmd != nil || m.tickPending {
+		t.Error("Expected no tick after thinking stopped")
+	}
+}
+
+func TestScrollbackSearch(t *testing.T) {
+	m := NewChatModel()
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+	for i := 1; i <= 30; i++ {
+		m.AddAssistantMessage(fmt.Sprintf("Answer %d", i))
+	}
+	m.AddAssistantMessage("The needle is here")
+	press := func(keys ...tea.KeyMsg) {
+		for _, key := range keys {
+			m.Update(key)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	// Scrolled back, the view stays put as messages arrive
+	press(tea.KeyMsg{Type: tea.KeyPgUp})
+	offset := m.viewport.YOffset
+	m.AddAssistantMessage("Answer 31")
+	if m.viewport.AtBottom() || m.viewport.YOffset != offset {
+		t.Errorf("Expected the view to stay scrolled back, at %d then %d", offset, m.viewport.YOffset)
+	}
+	press(tea.KeyMsg{Type: tea.KeyCtrlHome})
+	if !m.viewport.AtTop() {
+		t.Error("Expected ctrl+home to jump to the top")
+	}
+	press(tea.KeyMsg{Type: tea.KeyCtrlEnd})
+	if !m.viewport.AtBottom() {
+		t.Error("Expected ctrl+end to jump to the bottom")
+	}
+
+	// Typing the query highlights the matches and scrolls to the latest
+	press(tea.KeyMsg{Type: tea.KeyCtrlF}, runes("answer 1"))
+	if current, total := m.SearchMatches(); total != 11 || current != 11 {
+		t.Fatalf("Expected 11 matches, on the latest, got %d of %d", current, total)
+	}
+	if !strings.Contains(m.View(), "search /answer 1") {
+		t.Errorf("Expected the search in place of the input, got:\n%s", m.View())
+	}
+	press(tea.KeyMsg{Type: tea.KeyEnter}, runes("n"), runes("n"))
+	if current, _ := m.SearchMatches(); current != 9 {
+		t.Errorf("Expected n to go to the match above, got %d", current)
+	}
+	if !strings.Contains(m.viewport.View(), "Answer 17") {
+		t.Errorf("Expected the view scrolled to the match, got:\n%s", m.viewport.View())
+	}
+	press(runes("N"))
+	if current, _ := m.SearchMatches(); current != 10 {
+		t.Errorf("Expected N to go to the match below, got %d", current)
+	}
+
+	// A new query, then esc ends the search where it is
+	press(runes("/"), runes("NEEDLE"), tea.KeyMsg{Type: tea.KeyEnter})
+	if current, total := m.SearchMatches(); total != 1 || current != 1 {
+		t.Errorf("Expected the new query to find one match, got %d of %d", current, total)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.SearchingChat() || strings.Contains(m.View(), "search /") {
+		t.Error("Expected esc to end the search")
+	}
+
+	// Other keys end the search and go to the input
+	press(tea.KeyMsg{Type: tea.KeyCtrlF}, runes("Answer"), tea.KeyMsg{Type: tea.KeyEnter}, runes("x"))
+	if m.SearchingChat() || m.InputValue() != "x" {
+		t.Errorf("Expected typing to end the search, got input %q", m.InputValue())
+	}
+}
+
+func TestCollapsibleOutput(t *testing.T) {
+	m := NewChatModel()
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	m.SetCollapseLines(5)
+	var long []string
+	for i := 1; i <= 20; i++ {
+		long = append(long, fmt.Sprintf("test %d ok", i))
+	}
+	m.AddCommandMessage("go test ./...", &CommandResult{Command: "go test ./...", Stdout: strings.Join(long, "\n") + "\n"})
+	m.AddCommandMessage("ls", &CommandResult{Command: "ls", Stdout: "go.mod\nmain.go\n"})
+	m.AddCommandMessage("make lint", &CommandResult{Command: "make lint", ExitCode: 1, Stderr: strings.Join(long, "\n")})
+	press := func(keyType tea.KeyType) {
+		m.Update(tea.KeyMsg{Type: keyType})
+	}
+
+	// Long outputs are collapsed to their first lines; short ones are whole
+	content := m.content
+	if strings.Contains(content, "test 4 ok") || strings.Count(content, "▸ 17 more lines") != 2 || !strings.Contains(content, "main.go") {
+		t.Fatalf("Expected the long outputs collapsed, got:\n%s", content)
+	}
+	if !strings.Contains(content, "17 more lines · ctrl+o expands") {
+		t.Errorf("Expected the latest block to be selected, got:\n%s", content)
+	}
+
+	// ctrl+o expands the selected block, the latest
+	press(tea.KeyCtrlO)
+	if !m.messages[2].Expanded || m.messages[0].Expanded || !strings.Contains(m.content, "▾ ctrl+o collapses") {
+		t.Fatalf("Expected the latest block expanded, got:\n%s", m.content)
+	}
+
+	// shift+up selects the block above, skipping the short output
+	press(tea.KeyShiftUp)
+	press(tea.KeyCtrlO)
+	if !m.messages[0].Expanded || strings.Count(m.content, "test 4 ok") != 2 {
+		t.Errorf("Expected both blocks expanded, got:\n%s", m.content)
+	}
+	press(tea.KeyCtrlO)
+	press(tea.KeyShiftDown)
+	press(tea.KeyCtrlO)
+	if m.messages[0].Expanded || m.messages[2].Expanded {
+		t.Errorf("Expected both blocks collapsed again, got %t and %t", m.messages[0].Expanded, m.messages[2].Expanded)
+	}
+
+	// Without a limit, outputs are shown whole
+	m.SetCollapseLines(0)
+	if strings.Contains(m.content, "more lines") || strings.Count(m.content, "test 20 ok") != 2 {
+		t.Errorf("Expected no collapsing, got:\n%s", m.content)
+	}
+}
+
+// usageAgent is an agent reporting the tokens it used
+type usageAgent struct {
+	agent.Agent
+	usage agent.Usage
+}
+
+func (a usageAgent) Usage() agent.Usage { return a.usage }
+
+func TestStatusBar(t *testing.T) {
+	m := NewChatModel()
+	m.Update(tea.WindowSizeMsg{Width: 200, Height: 40})
+	m.SetSessionInfo("s1", "/work", "gpt-4o", "suggest")
+	m.SetToolsInfo("9 tools")
+
+	// Items without a value are left out
+	if got := ansi.Strip(m.statusBarView()); strings.TrimSpace(got) != "codex-go · gpt-4o · approval: suggest · 9 tools" {
+		t.Errorf("Unexpected status bar: %q", got)
+	}
+
+	m.SetGitStatus("main", 3)
+	m.SetAgent(usageAgent{usage: agent.Usage{PromptTokens: 12000, CompletionTokens: 345, Estimated: true}})
+	m.StartThinking()
+	m.SetThinkingStatus("Running tests")
+	want := "approval: suggest · 9 tools · ⎇ main · 3 files changed · ~12.3k tokens · thinking 0s - Running tests"
+	if got := ansi.Strip(m.statusBarView()); !strings.Contains(got, want) {
+		t.Errorf("Expected %q in the status bar, got %q", want, got)
+	}
+
+	// The configured items, in order
+	m.SetStatusItems([]string{"workdir", "changes", "session"})
+	m.SetGitStatus("main", 1)
+	if got := strings.TrimSpace(ansi.Strip(m.statusBarView())); got != "codex-go · /work · 1 file changed · session: s1" {
+		t.Errorf("Unexpected status bar: %q", got)
+	}
+
+	// It is cut to the width of the window
+	m.Update(tea.WindowSizeMsg{Width: 20, Height: 40})
+	if got := ansi.Strip(m.statusBarView()); ansi.StringWidth(got) != 20 || !strings.Contains(got, "…") {
+		t.Errorf("Expected the status bar cut to 20 columns, got %q", got)
+	}
+}
+
+func TestFormatTokens(t *testing.T) {
+	for usage, want := range map[agent.Usage]string{
+		{PromptTokens: 900, CompletionTokens: 50}:     "950 tokens",
+		{PromptTokens: 12_000, CompletionTokens: 345}: "12.3k tokens",
+		{PromptTokens: 1_200_000, Estimated: true}:    "~1.2M tokens",
+	} {
+		if got := formatTokens(usage); got != want {
+			t.Errorf("formatTokens(%+v) = %q, want %q", usage, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ChoiceResultMsg is sent when the user picks an option in the choice UI
+type ChoiceResultMsg struct {
+	Index int // The option picked, or -1 if the user dismissed the question
+}
+
+// Styles for the choice UI; the dialog reuses the approval styles
+var (
+	choiceOptionStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("7")).
+				PaddingLeft(1)
+
+	choiceSelectedStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("0")).  // Black text
+				Background(lipgloss.Color("10")). // Green background
+				PaddingLeft(1).
+				PaddingRight(1)
+)
+
+// choiceKeyMap holds the keys of the choice UI
+type choiceKeyMap struct {
+	Up      key.Binding
+	Down    key.Binding
+	Pick    key.Binding
+	Confirm key.Binding
+	Cancel  key.Binding
+}
+
+func defaultChoiceKeyMap() choiceKeyMap {
+	return choiceKeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k", "shift+tab"),
+			key.WithHelp("↑/k", "up"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j", "tab"),
+			key.WithHelp("↓/j", "down"),
+		),
+		Pick: key.NewBinding(
+			key.WithKeys("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			key.WithHelp("1-9", "pick"),
+		),
+		Confirm: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "confirm"),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("esc", "q", "ctrl+c"),
+			key.WithHelp("esc", "answer in chat instead"),
+		),
+	}
+}
+
+// ChoiceModel is a bubble tea model that asks the user to pick one of
+// several options, for the agent's present_choices tool
+type ChoiceModel struct {
+	Title   string
+	Options []string
+	Cursor  int // The highlighted option
+	keyMap  choiceKeyMap
+
+	terminalWidth  int
+	terminalHeight int
+}
+
+// NewChoiceModel creates a choice model with the first option highlighted
+func NewChoiceModel(title string, options []string) ChoiceModel {
+	return ChoiceModel{Title: title, Options: options, keyMap: defaultChoiceKeyMap()}
+}
+
+// SetSize records the terminal size the dialog is centered in
+func (m *ChoiceModel) SetSize(termWidth, termHeight int) {
+	m.terminalWidth = termWidth
+	m.terminalHeight = termHeight
+}
+
+// Init initializes the model
+func (m ChoiceModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update moves the highlight and reports the pick
+func (m ChoiceModel) Update(msg tea.Msg) (ChoiceModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.SetSize(msg.Width, msg.Height)
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keyMap.Up):
+			m.Cursor = (m.Cursor + len(m.Options) - 1) % len(m.Options)
+		case key.Matches(msg, m.keyMap.Down):
+			m.Cursor = (m.Cursor + 1) % len(m.Options)
+		case key.Matches(msg, m.keyMap.Pick):
+			if n := int(msg.Runes[0] - '1'); n < len(m.Options) {
+				m.Cursor = n
+				return m, choiceResult(n)
+			}
+		case key.Matches(msg, m.keyMap.Confirm):
+			return m, choiceResult(m.Cursor)
+		case key.Matches(msg, m.keyMap.Cancel):
+			return m, choiceResult(-1)
+		}
+	}
+	return m, nil
+}
+
+// choiceResult reports the option picked
+func choiceResult(index int) tea.Cmd {
+	return func() tea.Msg { return ChoiceResultMsg{Index: index} }
+}
+
+// View renders the question and its options as a centered dialog
+func (m ChoiceModel) View() string {
+	width := min(max(int(float64(m.terminalWidth)*0.6), 40), 100, max(m.terminalWidth-2, 0))
+	contentWidth := max(width-approvalDialogStyle.GetHorizontalPadding()-2, 10)
+
+	var options []string
+	for i, option := range m.Options {
+		line := fmt.Sprintf("%d. %s", i+1, option)
+		if i == m.Cursor {
+			options = append(options, choiceSelectedStyle.Width(contentWidth).Render("> "+line))
+		} else {
+			options = append(options, choiceOptionStyle.Width(contentWidth).Render("  "+line))
+		}
+	}
+
+	keys := []key.Binding{m.keyMap.Up, m.keyMap.Down, m.keyMap.Pick, m.keyMap.Confirm, m.keyMap.Cancel}
+	var help []string
+	for _, k := range keys {
+		help = append(help, fmt.Sprintf("%s: %s", k.Help().Key, k.Help().Desc))
+	}
+
+	ui := lipgloss.JoinVertical(lipgloss.Left,
+		approvalTitleStyle.Copy().Width(contentWidth).Render("The assistant asks"),
+		approvalDescriptionStyle.Copy().Width(contentWidth).Render(m.Title),
+		strings.Join(options, "\n"),
+		approvalHelpStyle.Copy().Width(contentWidth).Render(strings.Join(help, " • ")),
+	)
+	dialog := approvalDialogStyle.Width(width - approvalDialogStyle.GetHorizontalPadding()).Render(ui)
+	return lipgloss.Place(m.terminalWidth, m.terminalHeight, lipgloss.Center, lipgloss.Center, dialog)
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pickedIndex runs cmd and returns the index it reports, or -2 if none
+func pickedIndex(cmd tea.Cmd) int {
+	if cmd == nil {
+		return -2
+	}
+	if msg, ok := cmd().(ChoiceResultMsg); ok {
+		return msg.Index
+	}
+	return -2
+}
+
+func TestChoiceModel(t *testing.T) {
+	m := NewChoiceModel("Which store?", []string{"SQLite", "Postgres", "Files"})
+	m.SetSize(80, 24)
+
+	var cmd tea.Cmd
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if m.Cursor != 2 || cmd != nil {
+		t.Errorf("Expected up to wrap to the last option, got %d", m.Cursor)
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if m.Cursor != 1 {
+		t.Errorf("Expected the second option, got %d", m.Cursor)
+	}
+	if view := m.View(); !strings.Contains(view, "Which store?") || !strings.Contains(view, "> 2. Postgres") {
+		t.Errorf("Unexpected view:\n%s", view)
+	}
+	if _, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter}); pickedIndex(cmd) != 1 {
+		t.Errorf("Expected enter to pick the highlighted option, got %d", pickedIndex(cmd))
+	}
+	if _, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3")}); pickedIndex(cmd) != 2 {
+		t.Errorf("Expected 3 to pick the third option, got %d", pickedIndex(cmd))
+	}
+	if _, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("4")}); cmd != nil {
+		t.Error("Expected a digit past the options to be ignored")
+	}
+	if _, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEsc}); pickedIndex(cmd) != -1 {
+		t.Errorf("Expected esc to dismiss the question, got %d", pickedIndex(cmd))
+	}
+}
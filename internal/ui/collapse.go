@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// foldPreviewLines is how many lines of a collapsed output stay shown
+const foldPreviewLines = 3
+
+// foldSelectedStyle renders the hint of the block ctrl+o toggles
+var foldSelectedStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("5")). // Purple
+	Bold(true)
+
+// outputFold is how the output of a collapsible block is rendered:
+// collapsed to its first lines, and with a hint when it is selected
+type outputFold struct {
+	collapse bool // Show only the first lines and how many are hidden
+	lines    int  // Lines beyond which the output collapses
+	selected bool // Whether ctrl+o toggles this block
+}
+
+// apply folds output, a command's output or a tool result
+func (f outputFold) apply(output string) string {
+	if !f.collapse && !f.selected {
+		return output
+	}
+	style := infoStyle
+	if f.selected {
+		style = foldSelectedStyle
+	}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if !f.collapse || len(lines) <= f.lines {
+		return strings.TrimRight(output, "\n") + "\n" + style.Render("▾ ctrl+o collapses")
+	}
+	hint := fmt.Sprintf("▸ %d more lines", len(lines)-foldPreviewLines)
+	if f.selected {
+		hint += " · ctrl+o expands, shift+↑/↓ selects another output"
+	}
+	return strings.Join(lines[:foldPreviewLines], "\n") + "\n" + style.Render(hint)
+}
+
+// SetCollapseLines sets the number of lines beyond which command output and
+// tool results are collapsed; 0 shows them whole
+func (m *ChatModel) SetCollapseLines(lines int) {
+	m.collapseLines = max(lines, 0)
+	if m.ready {
+		m.updateViewport()
+	}
+}
+
+// collapsible reports whether the output of msg is long enough to collapse:
+// that of a finished command, or a tool result
+func (m *ChatModel) collapsible(msg Message) bool {
+	if m.collapseLines <= 0 {
+		return false
+	}
+	var output string
+	switch {
+	case msg.Role == "command" && msg.CommandResult != nil && !msg.CommandResult.Running:
+		_, output = commandOutput(msg.CommandResult)
+		output, _ = m.outputLimits.Apply(output)
+	case msg.Role == "function_result":
+		output = msg.Content
+	default:
+		return false
+	}
+	return strings.Count(strings.TrimRight(output, "\n"), "\n")+1 > max(m.collapseLines, foldPreviewLines)
+}
+
+// findBlocks records which of the shown messages, by index, collapse
+func (m *ChatModel) findBlocks(shown []int) {
+	m.blocks = m.blocks[:0]
+	for _, i := range shown {
+		if m.collapsible(m.messages[i]) {
+			m.blocks = append(m.blocks, i)
+		}
+	}
+}
+
+// selectedBlock returns the index of the message ctrl+o toggles: the one
+// selected with shift+up and shift+down, or else the latest block; -1 if
+// there is none
+func (m *ChatModel) selectedBlock() int {
+	if slices.Contains(m.blocks, m.selected) {
+		return m.selected
+	}
+	if len(m.blocks) == 0 {
+		return -1
+	}
+	return m.blocks[len(m.blocks)-1]
+}
+
+// ToggleBlock expands the selected output if it is collapsed and collapses
+// it otherwise. It reports whether there was an output to toggle.
+func (m *ChatModel) ToggleBlock() bool {
+	i := m.selectedBlock()
+	if i < 0 {
+		return false
+	}
+	m.messages[i].Expanded = !m.messages[i].Expanded
+	m.updateViewport()
+	m.scrollToBlock(i)
+	return true
+}
+
+// SelectBlock selects the collapsible output step blocks away from the
+// selected one and scrolls to it. Stepping past the latest selects whichever
+// is latest from then on.
+func (m *ChatModel) SelectBlock(step int) {
+	if len(m.blocks) == 0 {
+		return
+	}
+	at := slices.Index(m.blocks, m.selectedBlock()) + step
+	switch {
+	case at < 0:
+		at = 0
+	case at >= len(m.blocks):
+		m.selected = -1
+		m.updateViewport()
+		m.scrollToBlock(m.selectedBlock())
+		return
+	}
+	m.selected = m.blocks[at]
+	m.updateViewport()
+	m.scrollToBlock(m.selected)
+}
+
+// scrollToBlock scrolls so the block of message i starts in view
+func (m *ChatModel) scrollToBlock(i int) {
+	line, ok := m.blockLines[i]
+	if !ok {
+		return
+	}
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line)
+	}
+}
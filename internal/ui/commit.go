@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// commitKeyMap holds the keys of the commit message UI
+type commitKeyMap struct {
+	Commit  key.Binding
+	Edit    key.Binding
+	Cancel  key.Binding
+	Save    key.Binding
+	Discard key.Binding
+}
+
+func defaultCommitKeyMap() commitKeyMap {
+	return commitKeyMap{
+		Commit: key.NewBinding(
+			key.WithKeys("y", "enter"),
+			key.WithHelp("y/enter", "commit"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit"),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("n", "esc", "q", "ctrl+c"),
+			key.WithHelp("n/esc", "cancel"),
+		),
+		Save: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", "done editing"),
+		),
+		Discard: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "discard edits"),
+		),
+	}
+}
+
+// CommitModel is a bubble tea program that shows a generated commit message
+// with the staged files, for `codex commit`. The user commits it, edits it
+// first, or cancels; Confirmed and Message hold the outcome once it quits.
+type CommitModel struct {
+	Stat      string // The staged files, as git diff --stat shows them
+	Message   string
+	Confirmed bool
+
+	editing bool
+	editor  textarea.Model
+	keyMap  commitKeyMap
+
+	terminalWidth  int
+	terminalHeight int
+}
+
+// NewCommitModel creates a commit model showing message
+func NewCommitModel(message, stat string) *CommitModel {
+	editor := textarea.New()
+	editor.ShowLineNumbers = false
+	editor.CharLimit = 0
+	editor.SetHeight(10)
+	return &CommitModel{Stat: stat, Message: message, editor: editor, keyMap: defaultCommitKeyMap(), terminalWidth: 80}
+}
+
+// Init initializes the model
+func (m *CommitModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles the keys of the review and of the editor
+func (m *CommitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.terminalWidth, m.terminalHeight = msg.Width, msg.Height
+		m.editor.SetWidth(m.contentWidth())
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.editing {
+			switch {
+			case key.Matches(msg, m.keyMap.Save):
+				if edited := strings.TrimSpace(m.editor.Value()); edited != "" {
+					m.Message = edited
+				}
+				m.stopEditing()
+				return m, nil
+			case key.Matches(msg, m.keyMap.Discard):
+				m.stopEditing()
+				return m, nil
+			case msg.Type == tea.KeyCtrlC:
+				return m, tea.Quit
+			}
+			var cmd tea.Cmd
+			m.editor, cmd = m.editor.Update(msg)
+			return m, cmd
+		}
+		switch {
+		case key.Matches(msg, m.keyMap.Commit):
+			m.Confirmed = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keyMap.Edit):
+			m.editing = true
+			m.editor.SetWidth(m.contentWidth())
+			m.editor.SetValue(m.Message)
+			return m, m.editor.Focus()
+		case key.Matches(msg, m.keyMap.Cancel):
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// stopEditing goes back to the review of the message
+func (m *CommitModel) stopEditing() {
+	m.editing = false
+	m.editor.Blur()
+}
+
+// contentWidth is the width of the text inside the dialog
+func (m *CommitModel) contentWidth() int {
+	width := min(max(m.terminalWidth-2, 40), 100)
+	return max(width-approvalDialogStyle.GetHorizontalPadding()-2, 10)
+}
+
+// View renders the staged files, the message or its editor, and the keys
+func (m *CommitModel) View() string {
+	contentWidth := m.contentWidth()
+	keys := []key.Binding{m.keyMap.Commit, m.keyMap.Edit, m.keyMap.Cancel}
+	body := approvalActionStyle.Copy().Width(contentWidth - 2).Render(m.Message)
+	if m.editing {
+		keys = []key.Binding{m.keyMap.Save, m.keyMap.Discard}
+		body = m.editor.View()
+	}
+	var help []string
+	for _, k := range keys {
+		help = append(help, fmt.Sprintf("%s: %s", k.Help().Key, k.Help().Desc))
+	}
+
+	ui := lipgloss.JoinVertical(lipgloss.Left,
+		approvalTitleStyle.Copy().Width(contentWidth).Render("Commit the staged changes?"),
+		approvalDescriptionStyle.Copy().Width(contentWidth).Render(m.Stat),
+		body,
+		approvalHelpStyle.Copy().Width(contentWidth).Render(strings.Join(help, " • ")),
+	)
+	return approvalDialogStyle.Width(contentWidth+2).Render(ui) + "\n"
+}
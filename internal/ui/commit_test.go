@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// isQuit reports whether cmd quits the program
+func isQuit(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(tea.QuitMsg)
+	return ok
+}
+
+func TestCommitModel(t *testing.T) {
+	m := NewCommitModel("feat: add flag", " main.go | 3 ++-")
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	if view := m.View(); !strings.Contains(view, "feat: add flag") || !strings.Contains(view, "main.go | 3 ++-") {
+		t.Errorf("Unexpected view:\n%s", view)
+	}
+
+	// Editing replaces the message
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	if !strings.Contains(m.View(), "ctrl+s: done editing") {
+		t.Errorf("Expected the editor, got:\n%s", m.View())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if m.Message != "feat: add flags" {
+		t.Errorf("Expected the edited message, got %q", m.Message)
+	}
+
+	// Esc in the editor discards the edits
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("!")})
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc}); isQuit(cmd) || m.Message != "feat: add flags" {
+		t.Errorf("Expected esc to leave the editor only, got %q", m.Message)
+	}
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter}); !isQuit(cmd) || !m.Confirmed {
+		t.Error("Expected enter to confirm the commit")
+	}
+
+	cancelled := NewCommitModel("fix: x", "")
+	if _, cmd := cancelled.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")}); !isQuit(cmd) || cancelled.Confirmed {
+		t.Error("Expected n to cancel")
+	}
+}
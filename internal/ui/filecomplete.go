@@ -0,0 +1,175 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxFileMatches is the number of files the completion popup offers
+const maxFileMatches = 50
+
+// SetFileLister sets how the files completed after @ are listed: relative,
+// slash-separated paths of the repository. It is called when the list is
+// first needed, and again after RefreshFiles.
+func (m *CustomTextInput) SetFileLister(list func() []string) {
+	m.listFiles = list
+	m.files = nil
+	m.filesLoaded = false
+}
+
+// RefreshFiles makes the next completion list the files again, as they
+// may have changed
+func (m *CustomTextInput) RefreshFiles() {
+	m.filesLoaded = false
+}
+
+// fileFragment returns the word at the cursor when it asks for a file: one
+// starting with @, or a path fragment holding a slash. start and end are rune
+// offsets of the word in the value, and query is what to match files with.
+func (m CustomTextInput) fileFragment() (start, end int, query string, ok bool) {
+	if m.listFiles == nil || strings.HasPrefix(m.value, "/") {
+		return 0, 0, "", false
+	}
+	runes := []rune(m.value)
+	pos := min(m.textInput.Position(), len(runes))
+	start, end = pos, pos
+	for start > 0 && !unicode.IsSpace(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+	word := string(runes[start:end])
+	switch {
+	case strings.HasPrefix(word, "@"):
+		return start, end, word[1:], true
+	case strings.Contains(word, "/") && !strings.Contains(word, "://"):
+		return start, end, word, true
+	}
+	return 0, 0, "", false
+}
+
+// updateFileMatches offers the files matching the word at the cursor, if it
+// asks for one
+func (m *CustomTextInput) updateFileMatches() {
+	_, _, query, ok := m.fileFragment()
+	if !ok || !m.focused {
+		m.fileMatches, m.fileIndex = nil, 0
+		return
+	}
+	if !m.filesLoaded {
+		m.files, m.filesLoaded = m.listFiles(), true
+	}
+	previous := ""
+	if m.fileIndex < len(m.fileMatches) {
+		previous = m.fileMatches[m.fileIndex]
+	}
+	m.fileMatches, m.fileIndex = matchFiles(query, m.files), 0
+	for i, path := range m.fileMatches {
+		if path == previous {
+			m.fileIndex = i
+		}
+	}
+}
+
+// filePopupVisible reports whether files are offered for the word at the
+// cursor. A word already naming the only match needs no popup.
+func (m CustomTextInput) filePopupVisible() bool {
+	if !m.focused || len(m.fileMatches) == 0 {
+		return false
+	}
+	_, _, query, _ := m.fileFragment()
+	return len(m.fileMatches) > 1 || m.fileMatches[0] != query
+}
+
+// moveFileSelection moves the highlighted file by step, wrapping around
+func (m *CustomTextInput) moveFileSelection(step int) {
+	n := len(m.fileMatches)
+	m.fileIndex = ((m.fileIndex+step)%n + n) % n
+}
+
+// acceptFile replaces the word at the cursor with a reference to the
+// highlighted file, @path, which is attached when the message is sent
+func (m *CustomTextInput) acceptFile() {
+	start, end, _, ok := m.fileFragment()
+	if !ok || m.fileIndex >= len(m.fileMatches) {
+		return
+	}
+	runes := []rune(m.value)
+	ref := "@" + m.fileMatches[m.fileIndex]
+	rest := string(runes[end:])
+	if !strings.HasPrefix(rest, " ") {
+		ref += " "
+	}
+	value := string(runes[:start]) + ref + rest
+	recalled := m.recalled
+	m.SetValue(value)
+	m.recalled = recalled
+	m.textInput.SetCursor(start + len([]rune(ref)))
+	m.fileMatches, m.fileIndex = nil, 0
+}
+
+// matchFiles returns the paths fuzzily matching query, best first. An empty
+// query matches every path, shortest first.
+func matchFiles(query string, paths []string) []string {
+	type scored struct {
+		path  string
+		score int
+	}
+	var matches []scored
+	for _, path := range paths {
+		if score, ok := fuzzyMatch(query, path); ok {
+			matches = append(matches, scored{path, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		if len(matches[i].path) != len(matches[j].path) {
+			return len(matches[i].path) < len(matches[j].path)
+		}
+		return matches[i].path < matches[j].path
+	})
+	if len(matches) > maxFileMatches {
+		matches = matches[:maxFileMatches]
+	}
+	result := make([]string, len(matches))
+	for i, match := range matches {
+		result[i] = match.path
+	}
+	return result
+}
+
+// fuzzyMatch reports whether the characters of query appear in path in
+// order, ignoring case, and scores the match: runs of consecutive
+// characters, characters starting a word and a query found in the file name
+// score higher, and longer paths lower.
+func fuzzyMatch(query, path string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	p := []rune(strings.ToLower(path))
+	score, qi, last := 0, 0, -2
+	for pi := 0; pi < len(p) && qi < len(q); pi++ {
+		if p[pi] != q[qi] {
+			continue
+		}
+		score++
+		if pi == last+1 {
+			score += 5
+		}
+		if pi == 0 || strings.ContainsRune("/_-. ", p[pi-1]) {
+			score += 3
+		}
+		last = pi
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	base := strings.ToLower(path[strings.LastIndex(path, "/")+1:])
+	if query != "" && strings.Contains(base, strings.ToLower(query)) {
+		score += 10
+	}
+	return score - len(p)/8, true
+}
@@ -0,0 +1,200 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/epuerta/codex-go/internal/truncate"
+)
+
+// replayKeyMap holds the keys of the replay UI
+type replayKeyMap struct {
+	Next  key.Binding
+	Prev  key.Binding
+	First key.Binding
+	Last  key.Binding
+	Quit  key.Binding
+}
+
+func defaultReplayKeyMap() replayKeyMap {
+	return replayKeyMap{
+		Next: key.NewBinding(
+			key.WithKeys(" ", "right", "n", "enter"),
+			key.WithHelp("space", "next"),
+		),
+		Prev: key.NewBinding(
+			key.WithKeys("left", "p", "backspace"),
+			key.WithHelp("←", "back"),
+		),
+		First: key.NewBinding(
+			key.WithKeys("home", "g"),
+			key.WithHelp("g", "start"),
+		),
+		Last: key.NewBinding(
+			key.WithKeys("end", "G"),
+			key.WithHelp("G", "end"),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys("q", "esc", "ctrl+c"),
+			key.WithHelp("q", "quit"),
+		),
+	}
+}
+
+// ReplayStep is one event of a replayed session, shown as a chat message
+type ReplayStep struct {
+	Label   string    // What happened, such as "tool_call shell"
+	Time    time.Time // When it happened; zero if unknown
+	Message Message
+}
+
+var replayStatusStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("8")).
+	PaddingLeft(1)
+
+// ReplayModel is a bubble tea program that steps through the events of a
+// saved session, for `codex replay`. Each key press shows the next event
+// as a chat message; the ↑/↓ and page keys scroll.
+type ReplayModel struct {
+	Title  string
+	Steps  []ReplayStep
+	Limits truncate.Limits // Truncate command output as the chat does
+
+	shown    int // Steps shown so far
+	viewport viewport.Model
+	keyMap   replayKeyMap
+	ready    bool
+	width    int
+}
+
+// NewReplayModel creates a replay of steps with none shown yet
+func NewReplayModel(title string, steps []ReplayStep) *ReplayModel {
+	return &ReplayModel{Title: title, Steps: steps, keyMap: defaultReplayKeyMap(), width: 80}
+}
+
+// Shown returns how many steps are shown
+func (m *ReplayModel) Shown() int {
+	return m.shown
+}
+
+// Init initializes the model
+func (m *ReplayModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update moves through the steps and scrolls the messages shown
+func (m *ReplayModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		height := max(msg.Height-3, 1) // Title and status lines
+		if !m.ready {
+			m.viewport = viewport.New(msg.Width, height)
+			m.ready = true
+		} else {
+			m.viewport.Width, m.viewport.Height = msg.Width, height
+		}
+		m.render(false)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keyMap.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keyMap.Next):
+			m.show(m.shown + 1)
+			return m, nil
+		case key.Matches(msg, m.keyMap.Prev):
+			m.show(m.shown - 1)
+			return m, nil
+		case key.Matches(msg, m.keyMap.First):
+			m.show(0)
+			return m, nil
+		case key.Matches(msg, m.keyMap.Last):
+			m.show(len(m.Steps))
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+// show shows the first n steps, scrolled to the last one
+func (m *ReplayModel) show(n int) {
+	n = min(max(n, 0), len(m.Steps))
+	if n == m.shown {
+		return
+	}
+	m.shown = n
+	m.render(true)
+}
+
+// render lays out the steps shown, optionally scrolling to the bottom
+func (m *ReplayModel) render(bottom bool) {
+	if !m.ready {
+		return
+	}
+	var parts []string
+	for _, step := range m.Steps[:m.shown] {
+		parts = append(parts, formatMessage(step.Message, m.width, false, m.Limits))
+	}
+	m.viewport.SetContent(strings.Join(parts, "\n\n"))
+	if bottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+// status describes the last step shown and its time since the session
+// started
+func (m *ReplayModel) status() string {
+	if m.shown == 0 {
+		return fmt.Sprintf("%d events. Press space to start.", len(m.Steps))
+	}
+	step := m.Steps[m.shown-1]
+	status := fmt.Sprintf("Event %d/%d: %s", m.shown, len(m.Steps), step.Label)
+	if !step.Time.IsZero() {
+		status += " at " + step.Time.Local().Format("15:04:05")
+		if start := m.start(); !start.IsZero() && step.Time.After(start) {
+			status += fmt.Sprintf(" (+%s)", step.Time.Sub(start).Round(100*time.Millisecond))
+		}
+	}
+	if m.shown == len(m.Steps) {
+		status += ". End of the session."
+	}
+	return status
+}
+
+// start returns the time of the first step with one
+func (m *ReplayModel) start() time.Time {
+	for _, step := range m.Steps {
+		if !step.Time.IsZero() {
+			return step.Time
+		}
+	}
+	return time.Time{}
+}
+
+// View renders the title, the steps shown so far and the status line
+func (m *ReplayModel) View() string {
+	if !m.ready {
+		return "Loading..."
+	}
+	var help []string
+	for _, k := range []key.Binding{m.keyMap.Next, m.keyMap.Prev, m.keyMap.First, m.keyMap.Last, m.keyMap.Quit} {
+		help = append(help, fmt.Sprintf("%s: %s", k.Help().Key, k.Help().Desc))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		systemStyle.Render("Replay: "+m.Title),
+		m.viewport.View(),
+		replayStatusStyle.Render(m.status()),
+		replayStatusStyle.Render(strings.Join(help, " • ")),
+	)
+}
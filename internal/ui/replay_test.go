@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestReplayModel(t *testing.T) {
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	m := NewReplayModel("Fix the test", []ReplayStep{
+		{Label: "user_message", Time: start, Message: Message{Role: "user", Content: "run the tests"}},
+		{Label: "tool_result shell", Time: start.Add(3 * time.Second), Message: Message{Role: "command", Content: "go test ./...", CommandResult: &CommandResult{Command: "go test ./...", Stdout: "ok  pkg"}}},
+		{Label: "assistant_message", Message: Message{Role: "assistant", Content: "All tests pass."}},
+	})
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+	if view := m.View(); !strings.Contains(view, "Replay: Fix the test") || !strings.Contains(view, "3 events") || strings.Contains(view, "run the tests") {
+		t.Errorf("Expected no event shown yet, got:\n%s", view)
+	}
+
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+	m.Update(space)
+	m.Update(space)
+	view := m.View()
+	if m.Shown() != 2 || !strings.Contains(view, "run the tests") || !strings.Contains(view, "$ go test ./...") || strings.Contains(view, "All tests pass.") {
+		t.Errorf("Expected two events, got:\n%s", view)
+	}
+	if !strings.Contains(view, "Event 2/3: tool_result shell") || !strings.Contains(view, "(+3s)") {
+		t.Errorf("Unexpected status:\n%s", view)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if m.Shown() != 1 {
+		t.Errorf("Expected ← to go back, showing %d", m.Shown())
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	if m.Shown() != 3 || !strings.Contains(m.View(), "End of the session.") {
+		t.Errorf("Expected G to show every event, showing %d", m.Shown())
+	}
+	m.Update(space)
+	if m.Shown() != 3 {
+		t.Errorf("Expected to stay at the end, showing %d", m.Shown())
+	}
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); !isQuit(cmd) {
+		t.Error("Expected q to quit")
+	}
+}
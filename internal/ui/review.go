@@ -0,0 +1,415 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/epuerta/codex-go/internal/fileops"
+)
+
+// HunkState is the user's decision on a hunk under review
+type HunkState int
+
+const (
+	HunkAccepted HunkState = iota
+	HunkSkipped
+	HunkEdited // Accepted after the user changed it
+)
+
+// ReviewResultMsg is sent when the user is done reviewing a patch
+type ReviewResultMsg struct {
+	Approved bool                // false if the whole patch was denied, or every hunk skipped
+	Accepted []fileops.PatchHunk // The hunks to apply, in order, with the user's edits
+	Skipped  []fileops.PatchHunk
+	Edited   []fileops.PatchHunk // The accepted hunks the user changed
+}
+
+// hunkEditedMsg returns a hunk from the user's editor
+type hunkEditedMsg struct {
+	index int
+	hunk  fileops.PatchHunk
+	err   error
+}
+
+// maxReviewRows is the number of hunks listed at once
+const maxReviewRows = 8
+
+var (
+	reviewAcceptedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10")) // Green
+	reviewSkippedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))  // Red
+	reviewEditedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("11")) // Yellow
+	reviewErrorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Italic(true)
+)
+
+// reviewKeyMap holds the keys of the review UI
+type reviewKeyMap struct {
+	Up        key.Binding
+	Down      key.Binding
+	Accept    key.Binding
+	Skip      key.Binding
+	Edit      key.Binding
+	AcceptAll key.Binding
+	Confirm   key.Binding
+	Deny      key.Binding
+	PageUp    key.Binding
+	PageDown  key.Binding
+}
+
+func defaultReviewKeyMap() reviewKeyMap {
+	return reviewKeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k", "shift+tab"),
+			key.WithHelp("↑/k", "previous"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j", "tab"),
+			key.WithHelp("↓/j", "next"),
+		),
+		Accept: key.NewBinding(
+			key.WithKeys("a", "y"),
+			key.WithHelp("a", "accept"),
+		),
+		Skip: key.NewBinding(
+			key.WithKeys("s", "n"),
+			key.WithHelp("s", "skip"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit"),
+		),
+		AcceptAll: key.NewBinding(
+			key.WithKeys("A"),
+			key.WithHelp("A", "apply all"),
+		),
+		Confirm: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "apply accepted"),
+		),
+		Deny: key.NewBinding(
+			key.WithKeys("esc", "q", "ctrl+c"),
+			key.WithHelp("esc", "deny patch"),
+		),
+		PageUp: key.NewBinding(
+			key.WithKeys("pgup"),
+			key.WithHelp("pgup", "scroll hunk"),
+		),
+		PageDown: key.NewBinding(
+			key.WithKeys("pgdown"),
+			key.WithHelp("pgdn", "scroll hunk"),
+		),
+	}
+}
+
+// ReviewModel is a bubble tea model for reviewing a patch hunk by hunk, like
+// git add -p. Every hunk starts accepted; the user skips or edits some and
+// confirms, and the accepted ones are reported in a ReviewResultMsg.
+type ReviewModel struct {
+	Title  string
+	Hunks  []fileops.PatchHunk
+	States []HunkState
+	Cursor int // The highlighted hunk
+	keyMap reviewKeyMap
+	err    error // Of the last edit
+
+	viewport       viewport.Model // The highlighted hunk
+	terminalWidth  int
+	terminalHeight int
+}
+
+// NewReviewModel creates a review of hunks with every hunk accepted
+func NewReviewModel(title string, hunks []fileops.PatchHunk) ReviewModel {
+	vp := viewport.New(0, 0)
+	vp.Style = lipgloss.NewStyle().MarginLeft(1)
+	return ReviewModel{
+		Title:    title,
+		Hunks:    hunks,
+		States:   make([]HunkState, len(hunks)),
+		keyMap:   defaultReviewKeyMap(),
+		viewport: vp,
+	}
+}
+
+// SetSize records the terminal size and lays out the hunk view. The view
+// keeps its scroll position while the size is unchanged.
+func (m *ReviewModel) SetSize(termWidth, termHeight int) {
+	if termWidth == m.terminalWidth && termHeight == m.terminalHeight && m.viewport.Width > 0 {
+		return
+	}
+	m.terminalWidth = termWidth
+	m.terminalHeight = termHeight
+	m.viewport.Width = max(m.dialogWidth()-approvalDialogStyle.GetHorizontalPadding()-approvalActionStyle.GetHorizontalFrameSize()-m.viewport.Style.GetHorizontalMargins()-2, 10)
+	// Room for the title, the list, the help and the borders
+	m.viewport.Height = min(max(termHeight-min(len(m.Hunks), maxReviewRows)-14, 3), 15)
+	m.showHunk()
+}
+
+// dialogWidth is the width of the dialog in the terminal
+func (m ReviewModel) dialogWidth() int {
+	return min(max(int(float64(m.terminalWidth)*0.8), 40), 120, max(m.terminalWidth-2, 0))
+}
+
+// showHunk puts the highlighted hunk in the viewport
+func (m *ReviewModel) showHunk() {
+	if len(m.Hunks) == 0 {
+		return
+	}
+	m.viewport.SetContent(lipgloss.NewStyle().Width(m.viewport.Width).Render(strings.TrimSuffix(FormatPatchForDisplay(m.Hunks[m.Cursor].Patch()), "\n")))
+	m.viewport.GotoTop()
+}
+
+// Init initializes the model
+func (m ReviewModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update moves between hunks, records decisions and reports the result
+func (m ReviewModel) Update(msg tea.Msg) (ReviewModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.SetSize(msg.Width, msg.Height)
+
+	case hunkEditedMsg:
+		m.err = msg.err
+		if msg.err == nil {
+			m.Hunks[msg.index] = msg.hunk
+			m.States[msg.index] = HunkEdited
+			m.move(1)
+		}
+		m.showHunk()
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keyMap.Up):
+			m.move(-1)
+		case key.Matches(msg, m.keyMap.Down):
+			m.move(1)
+		case key.Matches(msg, m.keyMap.Accept):
+			if m.States[m.Cursor] != HunkEdited {
+				m.States[m.Cursor] = HunkAccepted
+			}
+			m.move(1)
+		case key.Matches(msg, m.keyMap.Skip):
+			m.States[m.Cursor] = HunkSkipped
+			m.move(1)
+		case key.Matches(msg, m.keyMap.Edit):
+			return m, editHunkCmd(m.Cursor, m.Hunks[m.Cursor])
+		case key.Matches(msg, m.keyMap.AcceptAll):
+			for i, state := range m.States {
+				if state == HunkSkipped {
+					m.States[i] = HunkAccepted
+				}
+			}
+			return m, m.result(true)
+		case key.Matches(msg, m.keyMap.Confirm):
+			return m, m.result(true)
+		case key.Matches(msg, m.keyMap.Deny):
+			return m, m.result(false)
+		case key.Matches(msg, m.keyMap.PageUp), key.Matches(msg, m.keyMap.PageDown):
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// move highlights the hunk delta rows away, staying in the list
+func (m *ReviewModel) move(delta int) {
+	cursor := min(max(m.Cursor+delta, 0), len(m.Hunks)-1)
+	if cursor != m.Cursor {
+		m.Cursor = cursor
+		m.showHunk()
+	}
+}
+
+// result reports the decisions; approved is false if the user denied the patch
+func (m ReviewModel) result(approved bool) tea.Cmd {
+	result := ReviewResultMsg{}
+	for i, h := range m.Hunks {
+		switch m.States[i] {
+		case HunkSkipped:
+			result.Skipped = append(result.Skipped, h)
+		case HunkEdited:
+			result.Edited = append(result.Edited, h)
+			fallthrough
+		default:
+			result.Accepted = append(result.Accepted, h)
+		}
+	}
+	if !approved {
+		result = ReviewResultMsg{Skipped: m.Hunks}
+	}
+	result.Approved = len(result.Accepted) > 0
+	return func() tea.Msg { return result }
+}
+
+// Editor returns the user's editor: $VISUAL, $EDITOR, or the system's default
+func Editor() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// EditorCommand returns the command opening path in the user's editor. The
+// editor may be a command line with arguments, where quotes group words, as
+// in "C:\Program Files\Notepad++\notepad++.exe" -multiInst; a value naming
+// an existing program is taken whole, spaces and all.
+func EditorCommand(path string) *exec.Cmd {
+	editor := Editor()
+	if info, err := os.Stat(editor); err == nil && !info.IsDir() {
+		return exec.Command(editor, path)
+	}
+	fields := splitCommandLine(editor)
+	if len(fields) == 0 {
+		fields = []string{"vi"}
+	}
+	return exec.Command(fields[0], append(fields[1:], path)...)
+}
+
+// splitCommandLine splits s into words at blanks outside quotes. Backslashes
+// are kept as they are, as they separate Windows paths.
+func splitCommandLine(s string) []string {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// editHunkCmd opens the hunk in the user's editor and returns it as saved.
+// The edited text must still hold exactly one EDIT block.
+func editHunkCmd(index int, h fileops.PatchHunk) tea.Cmd {
+	f, err := os.CreateTemp("", "codex-hunk-*.patch")
+	if err != nil {
+		return func() tea.Msg { return hunkEditedMsg{index: index, err: err} }
+	}
+	path := f.Name()
+	_, err = f.WriteString(h.Patch())
+	f.Close()
+	if err != nil {
+		os.Remove(path)
+		return func() tea.Msg { return hunkEditedMsg{index: index, err: err} }
+	}
+
+	return tea.ExecProcess(EditorCommand(path), func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return hunkEditedMsg{index: index, err: fmt.Errorf("editor failed: %w", err)}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return hunkEditedMsg{index: index, err: err}
+		}
+		hunks, err := fileops.SplitAgentPatch(string(data))
+		if err == nil && len(hunks) != 1 {
+			err = fmt.Errorf("expected one // EDIT: block, found %d; the hunk was not changed", len(hunks))
+		}
+		if err != nil {
+			return hunkEditedMsg{index: index, err: err}
+		}
+		return hunkEditedMsg{index: index, hunk: hunks[0]}
+	})
+}
+
+// renderList renders the hunks around the cursor with their states
+func (m ReviewModel) renderList(width int) string {
+	start := min(max(m.Cursor-maxReviewRows/2, 0), max(len(m.Hunks)-maxReviewRows, 0))
+	end := min(start+maxReviewRows, len(m.Hunks))
+	var rows []string
+	for i := start; i < end; i++ {
+		var mark string
+		switch m.States[i] {
+		case HunkSkipped:
+			mark = reviewSkippedStyle.Render("[skip]")
+		case HunkEdited:
+			mark = reviewEditedStyle.Render("[edit]")
+		default:
+			mark = reviewAcceptedStyle.Render("[ ok ]")
+		}
+		line := fmt.Sprintf("%d. %s", i+1, m.Hunks[i].Summary())
+		if i == m.Cursor {
+			line = choiceSelectedStyle.Render("> " + line)
+		} else {
+			line = choiceOptionStyle.Render("  " + line)
+		}
+		rows = append(rows, lipgloss.NewStyle().MaxWidth(width).Render(mark+" "+line))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// View renders the hunk list and the highlighted hunk as a centered dialog
+func (m ReviewModel) View() string {
+	width := m.dialogWidth()
+	contentWidth := max(width-approvalDialogStyle.GetHorizontalPadding()-2, 10)
+
+	accepted := 0
+	for _, state := range m.States {
+		if state != HunkSkipped {
+			accepted++
+		}
+	}
+	description := fmt.Sprintf("%d of %d hunks will be applied. Skipped hunks are reported to the assistant.", accepted, len(m.Hunks))
+
+	keys := []key.Binding{m.keyMap.Up, m.keyMap.Down, m.keyMap.Accept, m.keyMap.Skip, m.keyMap.Edit, m.keyMap.Confirm, m.keyMap.AcceptAll, m.keyMap.Deny}
+	if m.viewport.TotalLineCount() > m.viewport.Height {
+		keys = append(keys, m.keyMap.PageUp)
+	}
+	var help []string
+	for _, k := range keys {
+		help = append(help, fmt.Sprintf("%s: %s", k.Help().Key, k.Help().Desc))
+	}
+
+	parts := []string{
+		approvalTitleStyle.Copy().Width(contentWidth).Render(m.Title),
+		approvalDescriptionStyle.Copy().Width(contentWidth).Render(description),
+		m.renderList(contentWidth),
+		approvalActionStyle.Width(m.viewport.Width).Height(m.viewport.Height).Render(m.viewport.View()),
+	}
+	if m.err != nil {
+		parts = append(parts, reviewErrorStyle.Width(contentWidth).Render(fmt.Sprintf("Edit failed: %v", m.err)))
+	}
+	parts = append(parts, approvalHelpStyle.Copy().Width(contentWidth).Render(strings.Join(help, " • ")))
+
+	dialog := approvalDialogStyle.Width(width - approvalDialogStyle.GetHorizontalPadding()).Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+	return lipgloss.Place(m.terminalWidth, m.terminalHeight, lipgloss.Center, lipgloss.Center, dialog)
+}
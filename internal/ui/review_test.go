@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/fileops"
+)
+
+// reviewResult runs cmd and returns the result it reports
+func reviewResult(t *testing.T, cmd tea.Cmd) ReviewResultMsg {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("Expected a command")
+	}
+	result, ok := cmd().(ReviewResultMsg)
+	if !ok {
+		t.Fatal("Expected a ReviewResultMsg")
+	}
+	return result
+}
+
+func TestReviewModel(t *testing.T) {
+	hunks := []fileops.PatchHunk{
+		{Path: "a.go", Header: "first", Lines: []string{"ADD: one"}},
+		{Path: "a.go", Header: "second", Lines: []string{"DEL: two"}},
+		{Path: "b.go", Header: "third", Lines: []string{"ADD: three"}},
+	}
+	m := NewReviewModel("Review Patch (3 hunks)", hunks)
+	m.SetSize(100, 40)
+
+	key := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	m, _ = m.Update(key("a")) // Accept the first, move to the second
+	m, _ = m.Update(key("s")) // Skip the second, move to the third
+	if m.Cursor != 2 || m.States[1] != HunkSkipped {
+		t.Fatalf("Expected the second hunk skipped and the third highlighted, got %d %v", m.Cursor, m.States)
+	}
+
+	// An edit replaces the hunk and marks it
+	edited := fileops.PatchHunk{Path: "b.go", Header: "third", Lines: []string{"ADD: 3"}}
+	m, _ = m.Update(hunkEditedMsg{index: 2, hunk: edited})
+	if m.States[2] != HunkEdited || m.Hunks[2].Lines[0] != "ADD: 3" {
+		t.Errorf("Expected the edited hunk, got %v %+v", m.States, m.Hunks[2])
+	}
+	view := m.View()
+	if !strings.Contains(view, "2 of 3 hunks will be applied") || !strings.Contains(view, "[skip]") || !strings.Contains(view, "[edit]") {
+		t.Errorf("Unexpected view:\n%s", view)
+	}
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	result := reviewResult(t, cmd)
+	if !result.Approved || len(result.Accepted) != 2 || result.Accepted[1].Lines[0] != "ADD: 3" {
+		t.Errorf("Expected the first and the edited hunks accepted, got %+v", result)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Header != "second" || len(result.Edited) != 1 {
+		t.Errorf("Expected the second hunk skipped and one edit, got %+v", result)
+	}
+
+	// A failed edit keeps the hunk
+	m, _ = m.Update(hunkEditedMsg{index: 0, err: errors.New("no editor")})
+	if m.States[0] != HunkAccepted || !strings.Contains(m.View(), "Edit failed") {
+		t.Error("Expected a failed edit to be shown and the hunk kept")
+	}
+
+	// A applies every hunk; esc denies the whole patch
+	_, cmd = m.Update(key("A"))
+	if result := reviewResult(t, cmd); len(result.Accepted) != 3 || len(result.Skipped) != 0 {
+		t.Errorf("Expected every hunk applied, got %+v", result)
+	}
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if result := reviewResult(t, cmd); result.Approved || len(result.Skipped) != 3 {
+		t.Errorf("Expected the patch denied, got %+v", result)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := map[string][]string{
+		"vim":         {"vim"},
+		"code --wait": {"code", "--wait"},
+		`"C:\Program Files\Notepad++\notepad++.exe" -multiInst`: {`C:\Program Files\Notepad++\notepad++.exe`, "-multiInst"},
+		`emacs -nw  '-q'`: {"emacs", "-nw", "-q"},
+		"  ":              nil,
+	}
+	for line, want := range tests {
+		if got := splitCommandLine(line); strings.Join(got, "|") != strings.Join(want, "|") || len(got) != len(want) {
+			t.Errorf("splitCommandLine(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
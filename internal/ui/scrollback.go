@@ -0,0 +1,287 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	// searchMatchStyle highlights the matches of a scrollback search
+	searchMatchStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("0")).
+				Background(lipgloss.Color("11")) // Bright yellow
+
+	// searchCurrentStyle highlights the match the search is on
+	searchCurrentStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("0")).
+				Background(lipgloss.Color("5")). // Purple
+				Bold(true)
+)
+
+// scrollSearch is a search of the conversation, started with ctrl+f
+type scrollSearch struct {
+	query   string
+	editing bool // Whether the query is being typed; n and N move between matches once it is entered
+	matches []searchMatch
+	current int // Index of the match scrolled to, or -1
+}
+
+// searchMatch is where the query was found: a line of the viewport content
+// and the byte range on it, once its styling is stripped
+type searchMatch struct {
+	line, start, end int
+}
+
+// chatScrollKeys are the viewport's own keys: none, as the chat input takes
+// letters and ChatModel scrolls with the arrow and page keys itself. The
+// mouse wheel still scrolls.
+var chatScrollKeys = viewport.KeyMap{}
+
+// setContent shows the rendered conversation in the viewport, highlighting
+// the matches of a search. Unless a search is on, the view follows new
+// messages while it is at the bottom.
+func (m *ChatModel) setContent(content string) {
+	follow := m.viewport.AtBottom()
+	m.content = content
+	if m.search != nil {
+		m.findMatches()
+	}
+	m.showContent()
+	if m.search == nil && follow {
+		m.viewport.GotoBottom()
+	}
+}
+
+// showContent puts the conversation in the viewport, with the lines holding
+// matches redrawn plain and their matches highlighted
+func (m *ChatModel) showContent() {
+	if m.search == nil || len(m.search.matches) == 0 {
+		m.viewport.SetContent(m.content)
+		return
+	}
+	lines := strings.Split(m.content, "\n")
+	byLine := make(map[int][]int)
+	for i, match := range m.search.matches {
+		byLine[match.line] = append(byLine[match.line], i)
+	}
+	for line, indices := range byLine {
+		plain := ansi.Strip(lines[line])
+		var sb strings.Builder
+		at := 0
+		for _, i := range indices {
+			match := m.search.matches[i]
+			style := searchMatchStyle
+			if i == m.search.current {
+				style = searchCurrentStyle
+			}
+			sb.WriteString(plain[at:match.start])
+			sb.WriteString(style.Render(plain[match.start:match.end]))
+			at = match.end
+		}
+		sb.WriteString(plain[at:])
+		lines[line] = sb.String()
+	}
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+// findMatches finds the query in the conversation, ignoring case, and keeps
+// the search on the match it was on if it is still there
+func (m *ChatModel) findMatches() {
+	s := m.search
+	var previous *searchMatch
+	if s.current >= 0 && s.current < len(s.matches) {
+		previous = &s.matches[s.current]
+	}
+	s.matches, s.current = nil, -1
+	if s.query == "" {
+		return
+	}
+	query := strings.ToLower(s.query)
+	for i, line := range strings.Split(m.content, "\n") {
+		plain := ansi.Strip(line)
+		text := strings.ToLower(plain)
+		if len(text) != len(plain) {
+			// Lowering changed the byte offsets; match the case as typed
+			text, query = plain, s.query
+		}
+		for at := 0; ; {
+			j := strings.Index(text[at:], query)
+			if j < 0 {
+				break
+			}
+			start := at + j
+			s.matches = append(s.matches, searchMatch{line: i, start: start, end: start + len(query)})
+			at = start + len(query)
+		}
+		query = strings.ToLower(s.query)
+	}
+	if previous != nil {
+		for i, match := range s.matches {
+			if match == *previous {
+				s.current = i
+			}
+		}
+	}
+}
+
+// StartSearch starts a search of the conversation. Typing the query
+// highlights its matches and scrolls to the latest one.
+func (m *ChatModel) StartSearch() {
+	if m.search == nil {
+		m.search = &scrollSearch{current: -1}
+	}
+	m.search.editing = true
+}
+
+// EndSearch ends the search of the conversation, leaving the view where it is
+func (m *ChatModel) EndSearch() {
+	if m.search == nil {
+		return
+	}
+	m.search = nil
+	if m.ready {
+		m.showContent()
+	}
+}
+
+// SearchingChat reports whether a search of the conversation is on, during
+// which esc ends the search and letters go to it rather than the input
+func (m ChatModel) SearchingChat() bool {
+	return m.search != nil
+}
+
+// SearchMatches returns the number of matches of the search and the
+// position, counting from 1, of the one scrolled to
+func (m ChatModel) SearchMatches() (current, total int) {
+	if m.search == nil {
+		return 0, 0
+	}
+	return m.search.current + 1, len(m.search.matches)
+}
+
+// setQuery searches for query, scrolling to its latest match
+func (m *ChatModel) setQuery(query string) {
+	m.search.query = query
+	m.search.current = -1
+	m.findMatches()
+	m.gotoMatch(len(m.search.matches) - 1)
+}
+
+// gotoMatch scrolls to the i-th match, wrapping around, and highlights it
+func (m *ChatModel) gotoMatch(i int) {
+	s := m.search
+	if len(s.matches) == 0 {
+		s.current = -1
+		m.showContent()
+		return
+	}
+	n := len(s.matches)
+	s.current = (i%n + n) % n
+	m.showContent()
+	line := s.matches[s.current].line
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line - m.viewport.Height/2)
+	}
+}
+
+// handleSearchKey handles a key while a search of the conversation is on.
+// While the query is typed, letters edit it, enter keeps it and esc cancels;
+// then n and N go to the match above and below, / or ctrl+f types a new
+// query, and esc or enter end the search. It reports whether it used the
+// key; other keys end the search and are left for the input.
+func (m *ChatModel) handleSearchKey(msg tea.KeyMsg) bool {
+	s := m.search
+	switch msg.Type {
+	case tea.KeyUp, tea.KeyDown, tea.KeyPgUp, tea.KeyPgDown, tea.KeyCtrlHome, tea.KeyCtrlEnd:
+		return m.handleScrollKey(msg)
+	case tea.KeyEsc, tea.KeyCtrlG:
+		m.EndSearch()
+		return true
+	}
+	if s.editing {
+		switch msg.Type {
+		case tea.KeyRunes, tea.KeySpace:
+			m.setQuery(s.query + string(msg.Runes))
+		case tea.KeyBackspace:
+			if r := []rune(s.query); len(r) > 0 {
+				m.setQuery(string(r[:len(r)-1]))
+			}
+		case tea.KeyEnter:
+			if s.query == "" {
+				m.EndSearch()
+			} else {
+				s.editing = false
+			}
+		}
+		return true
+	}
+	switch {
+	case msg.String() == "n":
+		m.gotoMatch(s.current - 1)
+	case msg.String() == "N":
+		m.gotoMatch(s.current + 1)
+	case msg.String() == "/" || msg.Type == tea.KeyCtrlF:
+		m.setQuery("")
+		s.editing = true
+	case msg.Type == tea.KeyEnter:
+		m.EndSearch()
+	default:
+		m.EndSearch()
+		return false
+	}
+	return true
+}
+
+// handleScrollKey scrolls the conversation: the arrow keys by a line, the
+// page keys by a page, and ctrl+home and ctrl+end to either end. It reports
+// whether it used the key.
+func (m *ChatModel) handleScrollKey(msg tea.KeyMsg) bool {
+	if !m.ready {
+		return false
+	}
+	switch msg.Type {
+	case tea.KeyUp:
+		m.viewport.ScrollUp(1)
+	case tea.KeyDown:
+		m.viewport.ScrollDown(1)
+	case tea.KeyPgUp:
+		m.viewport.PageUp()
+	case tea.KeyPgDown:
+		m.viewport.PageDown()
+	case tea.KeyCtrlHome:
+		m.viewport.GotoTop()
+	case tea.KeyCtrlEnd:
+		m.viewport.GotoBottom()
+	default:
+		return false
+	}
+	return true
+}
+
+// searchView renders the search in place of the input
+func (m ChatModel) searchView() string {
+	s := m.search
+	prompt := m.textInput.prefixStyle.Render("search") + " /" + s.query
+	if s.editing {
+		prompt += "█"
+	}
+	var status string
+	switch {
+	case s.query == "":
+		status = "type to search the conversation, esc to cancel"
+	case len(s.matches) == 0:
+		status = "no matches"
+	case s.editing && len(s.matches) == 1:
+		status = "1 match · enter to keep, esc to cancel"
+	case s.editing:
+		status = fmt.Sprintf("%d matches · enter to keep, esc to cancel", len(s.matches))
+	default:
+		status = fmt.Sprintf("%d of %d · n/N: above/below, /: new search, esc: done", s.current+1, len(s.matches))
+	}
+	return prompt + "  " + infoStyle.Render(status)
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	// startupStyle frames the summary of the configuration shown above the
+	// messages
+	startupStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("8")).
+			Padding(0, 1)
+
+	// startupWarnStyle frames it when tools run without asking
+	startupWarnStyle = startupStyle.Copy().
+				BorderForeground(lipgloss.Color("1"))
+)
+
+// SetStartupSummary sets the summary of the effective configuration shown
+// above the messages, framed in red when warn is set. An empty summary
+// removes it.
+func (m *ChatModel) SetStartupSummary(summary string, warn bool) {
+	m.startupSummary = summary
+	m.startupWarn = warn
+	if m.ready {
+		m.updateViewport()
+	}
+}
+
+// startupView renders the startup summary, or "" if there is none
+func (m ChatModel) startupView() string {
+	if m.startupSummary == "" {
+		return ""
+	}
+	style := startupStyle
+	if m.startupWarn {
+		style = startupWarnStyle
+	}
+	return style.Width(m.width - 6).Render(strings.TrimRight(m.startupSummary, "\n"))
+}
@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/epuerta/codex-go/internal/agent"
+)
+
+// DefaultStatusItems are the items of the status bar unless configured
+var DefaultStatusItems = []string{"model", "approval", "branch", "changes", "tokens", "thinking"}
+
+var (
+	// statusBarStyle is the line at the top of the chat
+	statusBarStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("7")).
+			Background(lipgloss.Color("0"))
+
+	// statusThinkingStyle marks the thinking time in the status bar
+	statusThinkingStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("11")). // Bright yellow
+				Background(lipgloss.Color("0")).
+				Bold(true)
+)
+
+// statusSeparator goes between the items of the status bar
+const statusSeparator = " · "
+
+// SetStatusItems sets the items of the status bar, in order: model,
+// approval, branch, changes, tokens, thinking, session and workdir. Items
+// without a value, such as the branch outside a repository, are left out.
+func (m *ChatModel) SetStatusItems(items []string) {
+	m.statusItems = items
+}
+
+// SetGitStatus sets the branch and the number of changed files the status
+// bar shows. An empty branch hides both, as outside a repository.
+func (m *ChatModel) SetGitStatus(branch string, changed int) {
+	m.gitBranch = branch
+	m.gitChanged = changed
+}
+
+// statusItem renders one item of the status bar, or "" if it has no value
+func (m ChatModel) statusItem(name string) string {
+	switch name {
+	case "model":
+		return m.model
+	case "approval":
+		item := "approval: " + m.approvalMode
+		if m.toolsInfo != "" {
+			item += " · " + m.toolsInfo
+		}
+		return item
+	case "branch":
+		if m.gitBranch != "" {
+			return "⎇ " + m.gitBranch
+		}
+	case "changes":
+		switch {
+		case m.gitBranch == "":
+		case m.gitChanged == 0:
+			return "clean"
+		case m.gitChanged == 1:
+			return "1 file changed"
+		default:
+			return fmt.Sprintf("%d files changed", m.gitChanged)
+		}
+	case "tokens":
+		if reporter, ok := m.agent.(agent.UsageReporter); ok {
+			if usage := reporter.Usage(); usage.Total() > 0 {
+				return formatTokens(usage)
+			}
+		}
+	case "thinking":
+		if m.isThinking {
+			item := "thinking " + time.Since(m.thinkingStart).Round(time.Second).String()
+			if m.currentStatus != "" {
+				item += " - " + m.currentStatus
+			}
+			return statusThinkingStyle.Render(item)
+		}
+	case "session":
+		return "session: " + m.sessionID
+	case "workdir":
+		return m.workDir
+	}
+	return ""
+}
+
+// statusBarView renders the status bar: "codex-go" and the configured
+// items on one line, cut to the width of the window
+func (m ChatModel) statusBarView() string {
+	items := m.statusItems
+	if items == nil {
+		items = DefaultStatusItems
+	}
+	parts := []string{lipgloss.NewStyle().Bold(true).Render("codex-go")}
+	for _, name := range items {
+		if item := m.statusItem(name); item != "" {
+			parts = append(parts, item)
+		}
+	}
+	line := " " + strings.Join(parts, statusSeparator)
+	if m.width > 0 {
+		line = ansi.Truncate(line, m.width, "…")
+	}
+	return statusBarStyle.Width(m.width).Render(line)
+}
+
+// formatTokens renders a token count compactly, as 950 or 12.3k, marked
+// with ~ when it is estimated
+func formatTokens(usage agent.Usage) string {
+	total := usage.Total()
+	text := fmt.Sprintf("%d tokens", total)
+	switch {
+	case total >= 1_000_000:
+		text = fmt.Sprintf("%.1fM tokens", float64(total)/1_000_000)
+	case total >= 1000:
+		text = fmt.Sprintf("%.1fk tokens", float64(total)/1000)
+	}
+	if usage.Estimated {
+		text = "~" + text
+	}
+	return text
+}
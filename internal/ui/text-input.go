@@ -2,15 +2,28 @@ package ui
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/epuerta/codex-go/internal/inputhistory"
 )
 
+// maxPopupRows is the number of completions the popup shows at once
+const maxPopupRows = 6
+
+// Suggestion is a completion offered in the text input, such as a slash
+// command, with a description shown next to it in the completion popup
+type Suggestion struct {
+	Value       string
+	Description string
+}
+
 // CustomTextInput is a text input component that supports multiline text input
 type CustomTextInput struct {
 	textInput    textinput.Model
+	descriptions map[string]string // Descriptions of the suggestions, by value
 	value        string
 	width        int
 	height       int
@@ -23,6 +36,27 @@ type CustomTextInput struct {
 	prefixStyle  lipgloss.Style
 	cursorStyle  lipgloss.Style
 	blurredStyle lipgloss.Style
+
+	// Prompts up, down and ctrl+r recall; nil for none
+	history  *inputhistory.History
+	recalled int            // Index of the prompt recalled into the input, or -1
+	draft    string         // The input as it was before recalling began
+	search   *historySearch // The reverse search in progress, if any
+
+	// Repository files completed after @; nil listFiles for none
+	listFiles   func() []string
+	files       []string
+	filesLoaded bool
+	fileMatches []string // Files matching the word at the cursor, best first
+	fileIndex   int      // Index of the highlighted file
+}
+
+// historySearch is a reverse search of the input history, started with ctrl+r
+type historySearch struct {
+	query    string
+	match    int    // Index of the prompt found, or -1
+	failed   bool   // Whether the last key found nothing further
+	original string // The input before the search, restored if it is cancelled
 }
 
 // NewCustomTextInput creates a new custom text input
@@ -40,6 +74,7 @@ func NewCustomTextInput() CustomTextInput {
 		prefix:      "user",
 		placeholder: "Send a message or press tab to select a suggestion",
 		focused:     true,
+		recalled:    -1,
 		showCursor:  true,
 		style: lipgloss.NewStyle().
 			Border(lipgloss.NormalBorder()).
@@ -67,6 +102,19 @@ func (m CustomTextInput) Update(msg tea.Msg) (CustomTextInput, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.filePopupVisible() {
+			switch msg.Type {
+			case tea.KeyUp:
+				m.moveFileSelection(-1)
+				return m, nil
+			case tea.KeyDown:
+				m.moveFileSelection(1)
+				return m, nil
+			case tea.KeyTab:
+				m.acceptFile()
+				return m, nil
+			}
+		}
 		switch msg.Type {
 		case tea.KeyEnter:
 			// Submit the value
@@ -78,10 +126,153 @@ func (m CustomTextInput) Update(msg tea.Msg) (CustomTextInput, tea.Cmd) {
 	}
 
 	m.textInput, cmd = m.textInput.Update(msg)
+	if m.textInput.Value() != m.value {
+		// Editing a recalled prompt makes it a new input
+		m.recalled = -1
+	}
 	m.value = m.textInput.Value()
+	m.updateFileMatches()
 	return m, cmd
 }
 
+// SetHistory sets the prompts up, down and ctrl+r recall
+func (m *CustomTextInput) SetHistory(h *inputhistory.History) {
+	m.history = h
+	m.recalled = -1
+}
+
+// Remember adds a sent prompt to the history and starts a new input
+func (m *CustomTextInput) Remember(prompt string) error {
+	m.recalled, m.draft, m.search = -1, "", nil
+	if m.history == nil {
+		return nil
+	}
+	return m.history.Add(prompt)
+}
+
+// Searching reports whether a reverse search of the history is in progress
+func (m CustomTextInput) Searching() bool {
+	return m.search != nil
+}
+
+// HandleHistoryKey recalls prompts from the history: up and down step
+// through them, and ctrl+r searches them. It reports whether it used the
+// key. Enter during a search puts the match in the input and is left for
+// the caller to send.
+func (m *CustomTextInput) HandleHistoryKey(msg tea.KeyMsg) bool {
+	if m.search != nil {
+		return m.updateSearch(msg)
+	}
+	if m.history == nil || m.history.Len() == 0 || !m.focused || m.PopupVisible() {
+		return false
+	}
+	switch msg.Type {
+	case tea.KeyCtrlR:
+		m.search = &historySearch{match: -1, original: m.value}
+		return true
+	case tea.KeyUp:
+		m.recall(-1)
+		return true
+	case tea.KeyDown:
+		// Down only leaves the history; otherwise it scrolls the chat
+		if m.recalled < 0 {
+			return false
+		}
+		m.recall(1)
+		return true
+	}
+	return false
+}
+
+// recall shows the prompt step entries away from the one shown. Stepping
+// past the latest prompt puts back the input typed before recalling.
+func (m *CustomTextInput) recall(step int) {
+	i := m.recalled
+	if i < 0 {
+		i = m.history.Len()
+	}
+	i += step
+	switch {
+	case i < 0:
+		return
+	case i >= m.history.Len():
+		m.recalled = -1
+		m.showValue(m.draft)
+		return
+	}
+	if m.recalled < 0 {
+		m.draft = m.value
+	}
+	m.recalled = i
+	m.showValue(m.history.At(i))
+}
+
+// updateSearch handles a key during a reverse search: typing refines the
+// query, ctrl+r finds an older match, esc and ctrl+g cancel, and any other
+// key ends the search with the match in the input
+func (m *CustomTextInput) updateSearch(msg tea.KeyMsg) bool {
+	s := m.search
+	find := func(before int) {
+		if i := m.history.Search(s.query, before); i >= 0 {
+			s.match, s.failed = i, false
+		} else {
+			s.failed = s.query != ""
+		}
+	}
+	switch msg.Type {
+	case tea.KeyCtrlR:
+		if s.match >= 0 {
+			find(s.match)
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		s.query += string(msg.Runes)
+		before := m.history.Len()
+		if s.match >= 0 {
+			before = s.match + 1
+		}
+		find(before)
+	case tea.KeyBackspace:
+		if r := []rune(s.query); len(r) > 0 {
+			s.query = string(r[:len(r)-1])
+		}
+		s.match = -1
+		find(m.history.Len())
+	case tea.KeyEsc, tea.KeyCtrlG:
+		m.search = nil
+		m.showValue(s.original)
+	case tea.KeyEnter:
+		m.endSearch()
+		return false
+	default:
+		m.endSearch()
+	}
+	return true
+}
+
+// endSearch ends the reverse search, leaving its match in the input, from
+// where up and down carry on
+func (m *CustomTextInput) endSearch() {
+	s := m.search
+	m.search = nil
+	if s.match < 0 {
+		m.showValue(s.original)
+		return
+	}
+	if m.recalled < 0 {
+		m.draft = s.original
+	}
+	m.recalled = s.match
+	m.showValue(m.history.At(s.match))
+}
+
+// showValue sets the input to a recalled value, with the cursor at its end
+func (m *CustomTextInput) showValue(value string) {
+	recalled := m.recalled
+	m.SetValue(value)
+	m.textInput.CursorEnd()
+	m.recalled = recalled
+}
+
 // View renders the model
 func (m CustomTextInput) View() string {
 	if !m.focused {
@@ -97,13 +288,29 @@ func (m CustomTextInput) View() string {
 	// Format as "user: "
 	prefix := m.prefixStyle.Render(m.prefix)
 
+	if s := m.search; s != nil {
+		label := "reverse-i-search"
+		if s.failed {
+			label = "failing " + label
+		}
+		match := ""
+		if s.match >= 0 {
+			match = m.history.At(s.match)
+		}
+		return fmt.Sprintf("%s %s %s", prefix, m.blurredStyle.Render(fmt.Sprintf("(%s)`%s':", label, s.query)), match)
+	}
+
 	// Only show cursor if there's no content
 	if m.value == "" {
 		return fmt.Sprintf("%s %s", prefix, cursor)
 	}
 
-	// Show the text with cursor
-	return fmt.Sprintf("%s %s", prefix, m.value)
+	// Show the text, followed by the rest of the suggested completion
+	completion := ""
+	if suggestion := m.textInput.CurrentSuggestion(); len(suggestion) > len(m.value) && strings.HasPrefix(strings.ToLower(suggestion), strings.ToLower(m.value)) {
+		completion = m.blurredStyle.Render(suggestion[len(m.value):])
+	}
+	return fmt.Sprintf("%s %s%s", prefix, m.value, completion)
 }
 
 // Focus focuses the model
@@ -121,7 +328,13 @@ func (m *CustomTextInput) Blur() {
 // SetValue sets the value of the model
 func (m *CustomTextInput) SetValue(value string) {
 	m.value = value
+	m.recalled = -1
 	m.textInput.SetValue(value)
+	if m.textInput.ShowSuggestions {
+		// Match the suggestions against the new value, as typing does
+		m.textInput.SetSuggestions(m.textInput.AvailableSuggestions())
+	}
+	m.updateFileMatches()
 }
 
 // Value returns the current value of the model
@@ -141,6 +354,73 @@ func (m *CustomTextInput) SetWidth(width int) {
 	m.textInput.Width = width
 }
 
+// SetSuggestions sets the completions offered as the user types; up and down
+// pick one in the popup and tab accepts it
+func (m *CustomTextInput) SetSuggestions(suggestions []Suggestion) {
+	values := make([]string, len(suggestions))
+	m.descriptions = make(map[string]string, len(suggestions))
+	for i, s := range suggestions {
+		values[i] = s.Value
+		m.descriptions[s.Value] = s.Description
+	}
+	m.textInput.ShowSuggestions = len(suggestions) > 0
+	m.textInput.SetSuggestions(values)
+}
+
+// PopupVisible reports whether the completion popup is shown, in which case
+// up and down move through it
+func (m CustomTextInput) PopupVisible() bool {
+	if m.filePopupVisible() {
+		return true
+	}
+	if !m.focused || !strings.HasPrefix(m.value, "/") {
+		return false
+	}
+	matched := m.textInput.MatchedSuggestions()
+	return len(matched) > 1 || (len(matched) == 1 && matched[0] != m.value)
+}
+
+// PopupView renders the completions matching the input, with the current one
+// highlighted, or "" when the popup is hidden
+func (m CustomTextInput) PopupView() string {
+	if m.filePopupVisible() {
+		return m.renderPopup(m.fileMatches, m.fileIndex, nil)
+	}
+	if !m.PopupVisible() {
+		return ""
+	}
+	return m.renderPopup(m.textInput.MatchedSuggestions(), m.textInput.CurrentSuggestionIndex(), m.descriptions)
+}
+
+// renderPopup renders up to maxPopupRows of values, scrolled so the current
+// one is in view, each followed by its description if any
+func (m CustomTextInput) renderPopup(values []string, current int, descriptions map[string]string) string {
+	start := 0
+	if current >= maxPopupRows {
+		start = current - maxPopupRows + 1
+	}
+	end := min(start+maxPopupRows, len(values))
+
+	width := 0
+	for _, value := range values[start:end] {
+		width = max(width, len(value))
+	}
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("5")).Bold(true)
+	var lines []string
+	for i := start; i < end; i++ {
+		value := values[i]
+		line := fmt.Sprintf("  %-*s  %s", width, value, m.blurredStyle.Render(descriptions[value]))
+		if i == current {
+			line = selectedStyle.Render("> "+fmt.Sprintf("%-*s", width, value)) + "  " + m.blurredStyle.Render(descriptions[value])
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	if hidden := len(values) - (end - start); hidden > 0 {
+		lines = append(lines, m.blurredStyle.Render(fmt.Sprintf("  ... %d more (up/down to scroll, tab to complete)", hidden)))
+	}
+	return strings.Join(lines, "\n")
+}
+
 // SetPrefix sets the prefix text
 func (m *CustomTextInput) SetPrefix(prefix string) {
 	m.prefix = prefix
@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/epuerta/codex-go/internal/inputhistory"
+)
+
+func TestCompletionPopup(t *testing.T) {
+	input := NewCustomTextInput()
+	input.SetSuggestions([]Suggestion{
+		{Value: "/model ", Description: "Switches the model."},
+		{Value: "/map", Description: "Shows the repository map."},
+		{Value: "/clear", Description: "Clears the history."},
+	})
+	input.SetValue("/m")
+	if !input.PopupVisible() {
+		t.Fatal("Expected the popup for a partial command")
+	}
+	popup := input.PopupView()
+	if !strings.Contains(popup, "Switches the model.") || !strings.Contains(popup, "/map") || strings.Contains(popup, "/clear") {
+		t.Errorf("Expected the matching commands with descriptions, got:\n%s", popup)
+	}
+
+	// Down moves to the next completion and tab accepts it
+	input, _ = input.Update(tea.KeyMsg{Type: tea.KeyDown})
+	input, _ = input.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if input.Value() != "/map" {
+		t.Errorf("Expected /map to be completed, got %q", input.Value())
+	}
+	if input.PopupVisible() {
+		t.Error("Expected no popup once the command is complete")
+	}
+
+	input.SetValue("hello")
+	if input.PopupVisible() {
+		t.Error("Expected no popup for plain messages")
+	}
+}
+
+func TestInputHistoryRecall(t *testing.T) {
+	history, err := inputhistory.Open(filepath.Join(t.TempDir(), inputhistory.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := NewCustomTextInput()
+	input.SetHistory(history)
+	for _, prompt := range []string{"run the tests", "fix the build"} {
+		if err := input.Remember(prompt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input.SetValue("half typed")
+
+	press := func(keyType tea.KeyType) bool {
+		return input.HandleHistoryKey(tea.KeyMsg{Type: keyType})
+	}
+	press(tea.KeyUp)
+	if input.Value() != "fix the build" {
+		t.Errorf("Expected up to recall the latest prompt, got %q", input.Value())
+	}
+	press(tea.KeyUp)
+	press(tea.KeyUp)
+	if input.Value() != "run the tests" {
+		t.Errorf("Expected up to stop at the oldest prompt, got %q", input.Value())
+	}
+	press(tea.KeyDown)
+	press(tea.KeyDown)
+	if input.Value() != "half typed" {
+		t.Errorf("Expected down past the latest prompt to restore the draft, got %q", input.Value())
+	}
+	if press(tea.KeyDown) {
+		t.Error("Expected down outside the history to be left for scrolling")
+	}
+}
+
+func TestInputHistorySearch(t *testing.T) {
+	history, _ := inputhistory.Open(filepath.Join(t.TempDir(), inputhistory.FileName))
+	input := NewCustomTextInput()
+	input.SetHistory(history)
+	for _, prompt := range []string{"fix the lint errors", "run the tests", "fix the build"} {
+		input.Remember(prompt)
+	}
+	typeKeys := func(s string) {
+		for _, r := range s {
+			input.HandleHistoryKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+
+	input.HandleHistoryKey(tea.KeyMsg{Type: tea.KeyCtrlR})
+	typeKeys("fix")
+	if view := input.View(); !strings.Contains(view, "(reverse-i-search)`fix':") || !strings.Contains(view, "fix the build") {
+		t.Errorf("Expected the latest match, got %q", view)
+	}
+	input.HandleHistoryKey(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if view := input.View(); !strings.Contains(view, "fix the lint errors") {
+		t.Errorf("Expected ctrl+r to find an older match, got %q", view)
+	}
+	input.HandleHistoryKey(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if view := input.View(); !strings.Contains(view, "failing reverse-i-search") {
+		t.Errorf("Expected the search to fail past the oldest match, got %q", view)
+	}
+
+	// Enter leaves the match in the input for the caller to send
+	if input.HandleHistoryKey(tea.KeyMsg{Type: tea.KeyEnter}) || input.Searching() || input.Value() != "fix the lint errors" {
+		t.Errorf("Expected enter to accept the match, got %q (searching: %t)", input.Value(), input.Searching())
+	}
+
+	// Esc puts back the input from before the search
+	input.SetValue("draft")
+	input.HandleHistoryKey(tea.KeyMsg{Type: tea.KeyCtrlR})
+	typeKeys("tests")
+	input.HandleHistoryKey(tea.KeyMsg{Type: tea.KeyEsc})
+	if input.Searching() || input.Value() != "draft" {
+		t.Errorf("Expected esc to cancel the search, got %q", input.Value())
+	}
+}
+
+func TestMatchFiles(t *testing.T) {
+	files := []string{"README.md", "cmd/codex/main.go", "internal/ui/chat.go", "internal/ui/text-input.go", "internal/agent/chat_test.go"}
+	got := matchFiles("chat", files)
+	if len(got) != 2 || got[0] != "internal/ui/chat.go" {
+		t.Errorf("Expected the file named chat first, got %q", got)
+	}
+	if got := matchFiles("uitxt", files); len(got) != 1 || got[0] != "internal/ui/text-input.go" {
+		t.Errorf("Expected a fuzzy match, got %q", got)
+	}
+	if got := matchFiles("MAIN", files); len(got) != 1 || got[0] != "cmd/codex/main.go" {
+		t.Errorf("Expected matching to ignore case, got %q", got)
+	}
+	if got := matchFiles("", files); len(got) != len(files) || got[0] != "README.md" {
+		t.Errorf("Expected every file, shortest first, got %q", got)
+	}
+	if got := matchFiles("zzz", files); len(got) != 0 {
+		t.Errorf("Expected no match, got %q", got)
+	}
+}
+
+func TestFileCompletion(t *testing.T) {
+	listed := 0
+	input := NewCustomTextInput()
+	input.SetFileLister(func() []string {
+		listed++
+		return []string{"cmd/codex/main.go", "internal/ui/chat.go", "internal/ui/text-input.go"}
+	})
+	input.SetValue("explain @ui/")
+	if !input.PopupVisible() {
+		t.Fatal("Expected the file popup after @")
+	}
+	if popup := input.PopupView(); !strings.Contains(popup, "internal/ui/chat.go") || strings.Contains(popup, "main.go") {
+		t.Errorf("Expected the matching files, got:\n%s", popup)
+	}
+
+	// Down moves to the next file and tab puts a reference to it in the input
+	input, _ = input.Update(tea.KeyMsg{Type: tea.KeyDown})
+	input, _ = input.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if input.Value() != "explain @internal/ui/text-input.go " {
+		t.Errorf("Expected the reference to be completed, got %q", input.Value())
+	}
+	if input.PopupVisible() {
+		t.Error("Expected no popup once the file is chosen")
+	}
+
+	// Path fragments complete without @; URLs and commands do not
+	input.SetValue("see codex/ma")
+	if !input.PopupVisible() {
+		t.Error("Expected the file popup for a path fragment")
+	}
+	for _, value := range []string{"see https://example.com/ui", "/attach ui/", "plain words"} {
+		input.SetValue(value)
+		if input.PopupVisible() && input.filePopupVisible() {
+			t.Errorf("Expected no file popup for %q", value)
+		}
+	}
+	if listed != 1 {
+		t.Errorf("Expected the files to be listed once, got %d", listed)
+	}
+	input.RefreshFiles()
+	input.SetValue("@")
+	if listed != 2 {
+		t.Errorf("Expected the files to be listed again after a refresh, got %d", listed)
+	}
+}
@@ -0,0 +1,142 @@
+package uitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Reply is a scripted answer of ChatServer: text, tool calls, or both
+type Reply struct {
+	Content   string
+	ToolCalls []ToolCall
+}
+
+// ToolCall is a tool call of a scripted reply
+type ToolCall struct {
+	ID        string // Generated from the call's position if empty
+	Name      string
+	Arguments string // JSON
+}
+
+// ChatServer is an OpenAI-compatible chat completions API answering with
+// scripted replies, in order. Point the agent's base_url at its URL.
+type ChatServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []Reply
+	requests []openai.ChatCompletionRequest
+}
+
+// NewChatServer starts a server answering with replies; it is closed when
+// the test ends. Requests past the script fail the test.
+func NewChatServer(t testing.TB, replies ...Reply) *ChatServer {
+	s := &ChatServer{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply, ok := s.next(req)
+		if !ok {
+			t.Errorf("uitest: unexpected request %d past the scripted replies", len(s.Requests()))
+			http.Error(w, "no scripted reply left", http.StatusInternalServerError)
+			return
+		}
+		if req.Stream {
+			writeStream(w, reply)
+		} else {
+			writeCompletion(w, reply)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Add appends replies to the script
+func (s *ChatServer) Add(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Requests returns the requests received so far
+func (s *ChatServer) Requests() []openai.ChatCompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), s.requests...)
+}
+
+// next records req and takes the next reply of the script
+func (s *ChatServer) next(req openai.ChatCompletionRequest) (Reply, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if len(s.replies) == 0 {
+		return Reply{}, false
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply, true
+}
+
+// toolCalls returns the calls of reply in the API's format
+func toolCalls(reply Reply) []openai.ToolCall {
+	var calls []openai.ToolCall
+	for i, call := range reply.ToolCalls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i+1)
+		}
+		index := i
+		calls = append(calls, openai.ToolCall{
+			Index:    &index,
+			ID:       id,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+		})
+	}
+	return calls
+}
+
+// finishReason returns why the reply ends
+func finishReason(reply Reply) openai.FinishReason {
+	if len(reply.ToolCalls) > 0 {
+		return openai.FinishReasonToolCalls
+	}
+	return openai.FinishReasonStop
+}
+
+// writeStream answers a streaming request with reply in one chunk
+func writeStream(w http.ResponseWriter, reply Reply) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	send := func(delta openai.ChatCompletionStreamChoiceDelta, finish openai.FinishReason) {
+		data, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+			ID: "chatcmpl-uitest", Object: "chat.completion.chunk", Model: "uitest",
+			Choices: []openai.ChatCompletionStreamChoice{{Delta: delta, FinishReason: finish}},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	send(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant, Content: reply.Content, ToolCalls: toolCalls(reply)}, "")
+	send(openai.ChatCompletionStreamChoiceDelta{}, finishReason(reply))
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// writeCompletion answers a request that does not stream
+func writeCompletion(w http.ResponseWriter, reply Reply) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+		ID: "chatcmpl-uitest", Object: "chat.completion", Model: "uitest",
+		Choices: []openai.ChatCompletionChoice{{
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply.Content, ToolCalls: toolCalls(reply)},
+			FinishReason: finishReason(reply),
+		}},
+	})
+}
@@ -0,0 +1,206 @@
+// Package uitest drives Bubble Tea models in tests without a terminal:
+// scripted key presses go through Update, commands run in the background
+// and their messages are fed back in, and rendered views can be compared
+// with golden files. ChatServer stands in for the model's API.
+//
+// Commands batched with tea.Batch are run; tea.Sequence is not supported,
+// as the message it produces is not exported.
+package uitest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// DefaultTimeout bounds how long WaitFor waits for a view
+const DefaultTimeout = 5 * time.Second
+
+// Driver runs a model the way a tea.Program would, one message at a time
+type Driver struct {
+	Timeout time.Duration // How long WaitFor waits; DefaultTimeout if zero
+
+	t     testing.TB
+	model tea.Model
+	msgs  chan tea.Msg
+	done  chan struct{} // Closed when the test ends, dropping the messages of commands still running
+	quit  bool
+}
+
+// New initializes model, sizes it to width x height and returns its driver.
+// When the test ends, commands still running have their messages dropped,
+// so that their goroutines end with them rather than with the test binary.
+func New(t testing.TB, model tea.Model, width, height int) *Driver {
+	t.Helper()
+	d := &Driver{t: t, model: model, msgs: make(chan tea.Msg, 256), done: make(chan struct{})}
+	t.Cleanup(func() { close(d.done) })
+	d.run(model.Init())
+	d.Send(tea.WindowSizeMsg{Width: width, Height: height})
+	return d
+}
+
+// Model returns the model as last updated
+func (d *Driver) Model() tea.Model {
+	return d.model
+}
+
+// Quit reports whether the model asked the program to quit
+func (d *Driver) Quit() bool {
+	return d.quit
+}
+
+// Send updates the model with msg and starts the commands it returns
+func (d *Driver) Send(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case nil:
+		return
+	case tea.QuitMsg:
+		d.quit = true
+		return
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			d.run(cmd)
+		}
+		return
+	}
+	var cmd tea.Cmd
+	d.model, cmd = d.model.Update(msg)
+	d.run(cmd)
+}
+
+// Type sends each rune of s as a key press
+func (d *Driver) Type(s string) {
+	for _, r := range s {
+		d.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+// Press sends key presses by name, like "enter", "esc", "ctrl+c" or "y"
+func (d *Driver) Press(keys ...string) {
+	d.t.Helper()
+	for _, k := range keys {
+		if typ, ok := keyTypes[k]; ok {
+			d.Send(tea.KeyMsg{Type: typ})
+			continue
+		}
+		if len([]rune(k)) != 1 {
+			d.t.Fatalf("uitest: unknown key %q", k)
+		}
+		d.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+	}
+}
+
+// View returns the model's view without styling and trailing spaces
+func (d *Driver) View() string {
+	return Plain(d.model.View())
+}
+
+// WaitFor feeds the messages of running commands to the model until cond
+// holds for its view, failing the test if it does not within the timeout
+func (d *Driver) WaitFor(cond func(view string) bool) string {
+	d.t.Helper()
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.After(timeout)
+	for {
+		if view := d.View(); cond(view) {
+			return view
+		}
+		select {
+		case msg := <-d.msgs:
+			d.Send(msg)
+		case <-deadline:
+			d.t.Fatalf("uitest: timed out after %v; the view is:\n%s", timeout, d.View())
+		}
+	}
+}
+
+// WaitForText waits until the view contains text
+func (d *Driver) WaitForText(text string) string {
+	d.t.Helper()
+	return d.WaitFor(func(view string) bool { return strings.Contains(view, text) })
+}
+
+// Settle feeds the messages of running commands to the model until none
+// arrives for quiet, or the timeout passes
+func (d *Driver) Settle(quiet time.Duration) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg := <-d.msgs:
+			d.Send(msg)
+		case <-time.After(quiet):
+			return
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// run starts cmd in the background, queuing its message for WaitFor until
+// the test ends
+func (d *Driver) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	go func() {
+		msg := cmd()
+		if msg == nil {
+			return
+		}
+		select {
+		case d.msgs <- msg:
+		case <-d.done:
+		}
+	}()
+}
+
+// Plain strips styling from a rendered view, along with trailing spaces
+func Plain(view string) string {
+	lines := strings.Split(ansi.Strip(view), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// keyTypes maps key names to the keys they stand for
+var keyTypes = map[string]tea.KeyType{
+	"enter":      tea.KeyEnter,
+	"esc":        tea.KeyEsc,
+	"tab":        tea.KeyTab,
+	"shift+tab":  tea.KeyShiftTab,
+	"shift+up":   tea.KeyShiftUp,
+	"shift+down": tea.KeyShiftDown,
+	"backspace":  tea.KeyBackspace,
+	"space":      tea.KeySpace,
+	"up":         tea.KeyUp,
+	"down":       tea.KeyDown,
+	"left":       tea.KeyLeft,
+	"right":      tea.KeyRight,
+	"pgup":       tea.KeyPgUp,
+	"pgdown":     tea.KeyPgDown,
+	"home":       tea.KeyHome,
+	"end":        tea.KeyEnd,
+	"ctrl+c":     tea.KeyCtrlC,
+	"ctrl+d":     tea.KeyCtrlD,
+	"ctrl+end":   tea.KeyCtrlEnd,
+	"ctrl+f":     tea.KeyCtrlF,
+	"ctrl+g":     tea.KeyCtrlG,
+	"ctrl+home":  tea.KeyCtrlHome,
+	"ctrl+l":     tea.KeyCtrlL,
+	"ctrl+o":     tea.KeyCtrlO,
+	"ctrl+r":     tea.KeyCtrlR,
+	"ctrl+s":     tea.KeyCtrlS,
+	"ctrl+t":     tea.KeyCtrlT,
+	"ctrl+v":     tea.KeyCtrlV,
+}
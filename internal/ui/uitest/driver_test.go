@@ -0,0 +1,136 @@
+package uitest
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sashabaranov/go-openai"
+)
+
+// tickMsg is sent by the commands of counter
+type tickMsg struct{}
+
+// counter counts ticks; typed text is kept, enter starts two ticks and q
+// quits
+type counter struct {
+	text  string
+	ticks int
+	width int
+}
+
+func (c counter) Init() tea.Cmd { return nil }
+
+func (c counter) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	tick := func() tea.Msg { time.Sleep(time.Millisecond); return tickMsg{} }
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.width = msg.Width
+	case tickMsg:
+		c.ticks++
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "enter":
+			return c, tea.Batch(tick, tick)
+		case "q":
+			return c, tea.Quit
+		default:
+			c.text += msg.String()
+		}
+	}
+	return c, nil
+}
+
+func (c counter) View() string {
+	return fmt.Sprintf("\x1b[1m%s\x1b[0m   \nticks: %d, width: %d", c.text, c.ticks, c.width)
+}
+
+func TestDriver(t *testing.T) {
+	d := New(t, counter{}, 40, 10)
+	d.Type("hi")
+	d.Press("enter")
+	view := d.WaitForText("ticks: 2")
+	if view != "hi\nticks: 2, width: 40" {
+		t.Errorf("Unexpected view %q", view)
+	}
+	d.Press("q")
+	d.Settle(10 * time.Millisecond)
+	if !d.Quit() {
+		t.Error("Expected the model to quit")
+	}
+}
+
+// flood starts more commands than the driver queues, each returning once
+// release is closed
+type flood struct {
+	counter
+	release chan struct{}
+}
+
+func (f flood) Init() tea.Cmd {
+	cmds := make([]tea.Cmd, 300)
+	for i := range cmds {
+		cmds[i] = func() tea.Msg { <-f.release; return tickMsg{} }
+	}
+	return tea.Batch(cmds...)
+}
+
+func TestDriverReleasesCommands(t *testing.T) {
+	before := runtime.NumGoroutine()
+	t.Run("flood", func(t *testing.T) {
+		release := make(chan struct{})
+		// Cleanups run last to first, so this one runs after the driver's
+		t.Cleanup(func() { close(release) })
+		d := New(t, flood{release: release}, 40, 10)
+		d.Settle(10 * time.Millisecond)
+	})
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running after the test, expected %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestChatServer(t *testing.T) {
+	server := NewChatServer(t,
+		Reply{Content: "hello"},
+		Reply{ToolCalls: []ToolCall{{Name: "shell", Arguments: `{"command":"ls"}`}}},
+	)
+	cfg := openai.DefaultConfig("test")
+	cfg.BaseURL = server.URL
+	client := openai.NewClientWithConfig(cfg)
+	req := openai.ChatCompletionRequest{Model: "test", Messages: []openai.ChatCompletionMessage{{Role: "user", Content: "hi"}}}
+
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	if err != nil || resp.Choices[0].Message.Content != "hello" {
+		t.Fatalf("Unexpected completion %+v, %v", resp, err)
+	}
+
+	stream, err := client.CreateChatCompletionStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var calls []string
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		for _, call := range chunk.Choices[0].Delta.ToolCalls {
+			calls = append(calls, call.ID+" "+call.Function.Name+" "+call.Function.Arguments)
+		}
+	}
+	if strings.Join(calls, ";") != `call_1 shell {"command":"ls"}` {
+		t.Errorf("Unexpected tool calls %q", calls)
+	}
+	if len(server.Requests()) != 2 {
+		t.Errorf("Expected 2 recorded requests, got %d", len(server.Requests()))
+	}
+}
@@ -0,0 +1,35 @@
+package uitest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites golden files with the views the tests render
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// RequireGolden compares got with testdata/<test name>.golden, or writes
+// the file when the tests run with -update
+func RequireGolden(t testing.TB, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", strings.ReplaceAll(t.Name(), "/", "_")+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("uitest: %v (run the test with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("uitest: the view differs from %s (run the test with -update to accept it)\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
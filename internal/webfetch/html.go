@@ -0,0 +1,167 @@
+package webfetch
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skippedElements hold no readable text
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Iframe: true, atom.Nav: true, atom.Form: true,
+}
+
+// blockElements start on a new line
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Tr: true, atom.Br: true, atom.Hr: true, atom.Figure: true,
+	atom.Figcaption: true, atom.Details: true, atom.Summary: true,
+}
+
+// headingLevels maps heading elements to their Markdown level
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// textWriter accumulates readable text, collapsing whitespace outside
+// preformatted blocks
+type textWriter struct {
+	sb      strings.Builder
+	pending string // Separator to write before the next text: "", " " or newlines
+}
+
+// newline ends the current line, or the paragraph if blank is set
+func (w *textWriter) newline(blank bool) {
+	if w.sb.Len() == 0 {
+		return
+	}
+	if blank {
+		w.pending = "\n\n"
+	} else if w.pending != "\n\n" {
+		w.pending = "\n"
+	}
+}
+
+// text writes s, collapsing runs of whitespace into single spaces
+func (w *textWriter) text(s string) {
+	if s == "" {
+		return
+	}
+	if isSpace(s[0]) && w.pending == "" && w.sb.Len() > 0 {
+		w.pending = " "
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return
+	}
+	w.raw(strings.Join(fields, " "))
+	if isSpace(s[len(s)-1]) {
+		w.pending = " "
+	}
+}
+
+// isSpace reports whether b is HTML whitespace
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+// raw writes s as is after any pending separator
+func (w *textWriter) raw(s string) {
+	if w.sb.Len() > 0 {
+		w.sb.WriteString(w.pending)
+	}
+	w.pending = ""
+	w.sb.WriteString(s)
+}
+
+// HTMLToText extracts the title and the readable text of an HTML document.
+// Scripts, styles, navigation and forms are dropped; headings, list items and
+// preformatted blocks keep a Markdown-like shape.
+func HTMLToText(r io.Reader) (title, text string, err error) {
+	z := html.NewTokenizer(r)
+	var w textWriter
+	var skip, pre int // Depth inside skipped and preformatted elements
+	inTitle := false
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return strings.TrimSpace(title), strings.TrimSpace(w.sb.String()), nil
+			}
+			return "", "", z.Err()
+
+		case html.TextToken:
+			data := string(z.Text())
+			switch {
+			case inTitle:
+				title += data
+			case skip > 0:
+			case pre > 0:
+				w.raw(data)
+			default:
+				w.text(data)
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tn, _ := z.TagName()
+			a := atom.Lookup(tn)
+			if a == atom.Title {
+				inTitle = true
+				continue
+			}
+			if skippedElements[a] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			switch {
+			case a == atom.Pre:
+				w.newline(true)
+				w.raw("```\n")
+				pre++
+			case a == atom.Li:
+				w.newline(false)
+				w.raw("- ")
+			case headingLevels[a] > 0:
+				w.newline(true)
+				w.raw(strings.Repeat("#", headingLevels[a]) + " ")
+			case a == atom.Td || a == atom.Th:
+				w.text(" | ")
+			case blockElements[a]:
+				w.newline(a == atom.P || a == atom.Blockquote || a == atom.Table)
+			}
+
+		case html.EndTagToken:
+			tn, _ := z.TagName()
+			a := atom.Lookup(tn)
+			switch {
+			case a == atom.Title:
+				inTitle = false
+			case skippedElements[a]:
+				if skip > 0 {
+					skip--
+				}
+			case skip > 0:
+			case a == atom.Pre && pre > 0:
+				pre--
+				w.raw("\n```")
+				w.newline(true)
+			case headingLevels[a] > 0:
+				w.newline(true)
+			case blockElements[a] || a == atom.Li:
+				w.newline(a == atom.P || a == atom.Blockquote || a == atom.Table)
+			}
+		}
+	}
+}
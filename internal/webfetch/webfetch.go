@@ -0,0 +1,209 @@
+// Package webfetch implements fetch_url, which lets the agent read web pages
+// from an allowlist of domains as plain text.
+package webfetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/functions"
+)
+
+// ToolName is the function the model calls to fetch a URL
+const ToolName = "fetch_url"
+
+const (
+	// bytesPerToken approximates the length of a token in text
+	bytesPerToken = 4
+	// maxBodyBytes bounds how much of a response is read
+	maxBodyBytes = 5 << 20
+	// maxRedirects bounds the redirects followed, all within the allowlist
+	maxRedirects = 5
+	// fetchTimeout bounds a whole fetch
+	fetchTimeout = 30 * time.Second
+)
+
+// Fetcher downloads pages from allowed domains and renders them as text
+type Fetcher struct {
+	Allowed   []string // Domains that may be fetched, including subdomains; "*" allows any
+	MaxTokens int      // Approximate token budget of the returned text
+	Client    *http.Client
+}
+
+// New creates a fetcher for the domains allowed by cfg
+func New(cfg *config.Config) *Fetcher {
+	f := &Fetcher{Allowed: cfg.AllowedDomains, MaxTokens: cfg.FetchMaxTokens}
+	f.Client = &http.Client{
+		Timeout: fetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return f.check(req.URL)
+		},
+	}
+	return f
+}
+
+// Enabled reports whether cfg turns fetch_url on: network tools must be
+// allowed and at least one domain listed
+func Enabled(cfg *config.Config) bool {
+	return cfg.AllowNetworkTools && len(cfg.AllowedDomains) > 0
+}
+
+// AllowedHost reports whether host is one of the allowed domains or a
+// subdomain of one
+func (f *Fetcher) AllowedHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return false
+	}
+	for _, domain := range f.Allowed {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+		if domain == "*" || host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// check refuses URLs that are not HTTP or are outside the allowlist
+func (f *Fetcher) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("only http and https URLs can be fetched, not %q", u.Scheme)
+	}
+	if !f.AllowedHost(u.Hostname()) {
+		return fmt.Errorf("%s is not in the allowed domains (allowed_domains in the config)", u.Hostname())
+	}
+	return nil
+}
+
+// Fetch downloads rawURL and returns its readable text, headed by the URL and
+// the page title, and cut to the token budget
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := f.check(u); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9, application/json;q=0.8, */*;q=0.1")
+	req.Header.Set("User-Agent", "codex-go fetch_url")
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to fetch %s: %s", u, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", u, err)
+	}
+
+	var title, text string
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || (mediaType == "" && looksLikeHTML(body)):
+		if title, text, err = HTMLToText(bytes.NewReader(body)); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", u, err)
+		}
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml") || mediaType == "":
+		if !utf8.Valid(body) {
+			return "", fmt.Errorf("%s is not valid UTF-8 text", u)
+		}
+		text = string(body)
+	default:
+		return "", fmt.Errorf("%s has unsupported content type %s", u, mediaType)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "URL: %s\n", resp.Request.URL)
+	if title != "" {
+		fmt.Fprintf(&sb, "Title: %s\n", title)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(truncate(text, f.maxTokens()))
+	return sb.String(), nil
+}
+
+// maxTokens returns the token budget, or the default if none is set
+func (f *Fetcher) maxTokens() int {
+	if f.MaxTokens > 0 {
+		return f.MaxTokens
+	}
+	return config.DefaultFetchMaxTokens
+}
+
+// looksLikeHTML reports whether an untyped body is an HTML document
+func looksLikeHTML(body []byte) bool {
+	start := strings.ToLower(string(bytes.TrimSpace(body[:min(len(body), 512)])))
+	return strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+}
+
+// truncate cuts text to about maxTokens tokens at a line or rune boundary
+func truncate(text string, maxTokens int) string {
+	limit := maxTokens * bytesPerToken
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if nl := strings.LastIndexByte(text[:cut], '\n'); nl > limit/2 {
+		cut = nl
+	}
+	return fmt.Sprintf("%s\n\n[Truncated: showing about %d of %d tokens]", text[:cut], maxTokens, (len(text)+bytesPerToken-1)/bytesPerToken)
+}
+
+// Tool describes fetch_url to the model
+func (f *Fetcher) Tool() functions.Tool {
+	return functions.Tool{
+		Name:        ToolName,
+		Source:      "config (allow_network_tools)",
+		Description: fmt.Sprintf("Fetch a web page or text file over HTTP(S) and return its readable text (HTML is converted to text, long pages are truncated). Only these domains and their subdomains can be fetched: %s.", strings.Join(f.Allowed, ", ")),
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "The http or https URL to fetch",
+				},
+			},
+			"required": []string{"url"},
+		},
+		Handler: func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				URL string `json:"url"`
+			}
+			if err := json.Unmarshal([]byte(args), &params); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			if params.URL == "" {
+				return "", errors.New("missing url argument")
+			}
+			return f.Fetch(ctx, params.URL)
+		},
+	}
+}
@@ -0,0 +1,135 @@
+package webfetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/config"
+)
+
+func TestHTMLToText(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><title> Release  notes </title><style>body { color: red }</style><script>alert("x")</script></head>
+<body>
+<nav><a href="/">Home</a> <a href="/docs">Docs</a></nav>
+<h1>Go 1.23</h1>
+<p>Iterators are   <em>here</em>.
+Range over functions.</p>
+<ul><li>First</li><li>Second <code>item</code></li></ul>
+<pre>for x := range seq {
+    fmt.Println(x)
+}</pre>
+<table><tr><th>Name</th><th>Value</th></tr><tr><td>a</td><td>1</td></tr></table>
+</body></html>`
+
+	title, text, err := HTMLToText(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("HTMLToText failed: %v", err)
+	}
+	if title != "Release  notes" {
+		t.Errorf("title = %q", title)
+	}
+	want := "# Go 1.23\n\nIterators are here. Range over functions.\n\n- First\n- Second item\n\n```\nfor x := range seq {\n    fmt.Println(x)\n}\n```\n\n| Name | Value\n| a | 1"
+	if text != want {
+		t.Errorf("text =\n%s\nwant\n%s", text, want)
+	}
+}
+
+func TestAllowedHost(t *testing.T) {
+	f := &Fetcher{Allowed: []string{"go.dev", "*.example.com"}}
+	tests := map[string]bool{
+		"go.dev":          true,
+		"pkg.go.dev":      true,
+		"GO.DEV.":         true,
+		"notgo.dev":       false,
+		"example.com":     true,
+		"api.example.com": true,
+		"example.org":     false,
+		"":                false,
+	}
+	for host, want := range tests {
+		if got := f.AllowedHost(host); got != want {
+			t.Errorf("AllowedHost(%q) = %t, want %t", host, got, want)
+		}
+	}
+	if !(&Fetcher{Allowed: []string{"*"}}).AllowedHost("anything.test") {
+		t.Error(`Expected "*" to allow any host`)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if Enabled(&config.Config{AllowedDomains: []string{"go.dev"}}) {
+		t.Error("Expected fetch_url to need allow_network_tools")
+	}
+	if Enabled(&config.Config{AllowNetworkTools: true}) {
+		t.Error("Expected fetch_url to need allowed domains")
+	}
+	if !Enabled(&config.Config{AllowNetworkTools: true, AllowedDomains: []string{"go.dev"}}) {
+		t.Error("Expected fetch_url to be enabled")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><title>Docs</title></head><body><p>" + strings.Repeat("word ", 100) + "</p></body></html>"))
+	})
+	mux.HandleFunc("/data.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://elsewhere.test/", http.StatusFound)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := New(&config.Config{AllowedDomains: []string{"127.0.0.1"}, FetchMaxTokens: 20})
+	ctx := context.Background()
+
+	out, err := f.Fetch(ctx, srv.URL+"/page")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if !strings.HasPrefix(out, "URL: "+srv.URL+"/page\nTitle: Docs\n\nword word") {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+	if !strings.Contains(out, "[Truncated: showing about 20 of 125 tokens]") {
+		t.Errorf("Expected the page to be truncated:\n%s", out)
+	}
+
+	if out, err := f.Fetch(ctx, srv.URL+"/data.json"); err != nil || !strings.HasSuffix(out, `{"ok":true}`) {
+		t.Errorf("Fetch JSON = %q, %v", out, err)
+	}
+
+	errTests := map[string]string{
+		srv.URL + "/image.png":   "unsupported content type image/png",
+		srv.URL + "/away":        "elsewhere.test is not in the allowed domains",
+		srv.URL + "/missing":     "404 Not Found",
+		"http://other.test/page": "other.test is not in the allowed domains",
+		"file:///etc/passwd":     "only http and https",
+	}
+	for url, want := range errTests {
+		if _, err := f.Fetch(ctx, url); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Fetch(%s) error = %v, want %q", url, err, want)
+		}
+	}
+
+	// The tool decodes its arguments
+	tool := f.Tool()
+	if out, err := tool.Handler(ctx, `{"url":"`+srv.URL+`/data.json"}`); err != nil || !strings.Contains(out, "ok") {
+		t.Errorf("Tool handler = %q, %v", out, err)
+	}
+	if _, err := tool.Handler(ctx, `{}`); err == nil {
+		t.Error("Expected a missing url to be refused")
+	}
+}
@@ -0,0 +1,225 @@
+// Package webhook posts the events of a session to a local HTTP endpoint as
+// they happen, for dashboards, notifications and archiving.
+//
+// Each event is POSTed as one JSON object:
+//
+//	{"type":"tool_result","time":"2026-01-02T15:04:05Z","session_id":"...","cwd":"/path/to/repo","call":{...},"output":"...","success":true}
+//
+// Events are delivered in order by a background goroutine; the session never
+// waits for the endpoint. When it falls behind, further events are dropped.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/logging"
+)
+
+// Event types
+const (
+	EventMessage         = "message"
+	EventToolCall        = "tool_call"
+	EventToolResult      = "tool_result"
+	EventToolDenied      = "tool_denied"
+	EventApprovalRequest = "approval_request"
+	EventApproval        = "approval"
+)
+
+var (
+	// postTimeout bounds each delivery
+	postTimeout = 5 * time.Second
+	// closeTimeout bounds how long Close waits for queued events
+	closeTimeout = 3 * time.Second
+)
+
+// queueSize is the number of events buffered for a slow endpoint
+const queueSize = 256
+
+// Event is the body of a webhook request
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id,omitempty"`
+	CWD       string    `json:"cwd,omitempty"`
+
+	Content  string               `json:"content,omitempty"`  // For message
+	Call     *Call                `json:"call,omitempty"`     // For tool events and approval_request
+	Output   string               `json:"output,omitempty"`   // For tool_result
+	Success  bool                 `json:"success,omitempty"`  // For tool_result
+	Reason   string               `json:"reason,omitempty"`   // For tool_denied
+	Approval *agent.ApprovalEvent `json:"approval,omitempty"` // For approval
+}
+
+// Call is the function call an event is about
+type Call struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// newCall converts a function call for an event
+func newCall(call agent.FunctionCall) *Call {
+	return &Call{ID: call.ID, Name: call.Name, Arguments: call.Arguments}
+}
+
+// Webhook posts events to URL. It implements engine.Notifier,
+// engine.ApprovalRecorder, engine.ApprovalRequestObserver and
+// engine.MessageObserver, so it can be added to an engine's observers.
+type Webhook struct {
+	engine.NopNotifier
+
+	URL       string
+	SessionID string
+	CWD       string
+
+	client *http.Client
+	logger logging.Logger
+	queue  chan Event
+	done   chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// ValidateURL checks that rawURL is an http(s) URL on this machine.
+// Conversations may contain code and secrets, so they are only streamed to
+// endpoints on the loopback interface.
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook URL %q: the scheme must be http or https", rawURL)
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("invalid webhook URL %q: the host must be localhost or a loopback address", rawURL)
+}
+
+// New starts delivering events to rawURL, which must pass ValidateURL
+func New(rawURL, sessionID, cwd string, logger logging.Logger) (*Webhook, error) {
+	if err := ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = logging.NewNilLogger()
+	}
+	w := &Webhook{
+		URL:       rawURL,
+		SessionID: sessionID,
+		CWD:       cwd,
+		client:    &http.Client{Timeout: postTimeout},
+		logger:    logger,
+		queue:     make(chan Event, queueSize),
+		done:      make(chan struct{}),
+	}
+	go w.deliver()
+	return w, nil
+}
+
+// Send queues event for delivery, filling in its time and session. It drops
+// the event if the queue is full or the webhook is closed.
+func (w *Webhook) Send(event Event) {
+	event.Time = time.Now().UTC()
+	event.SessionID = w.SessionID
+	event.CWD = w.CWD
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.queue <- event:
+	default:
+		w.logger.Log("WARN: Webhook: queue full, dropping %s event", event.Type)
+	}
+}
+
+// Close delivers the queued events, waiting at most a few seconds, and stops
+func (w *Webhook) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(closeTimeout):
+		return fmt.Errorf("webhook %s: gave up delivering queued events", w.URL)
+	}
+}
+
+// deliver posts queued events in order until the queue is closed
+func (w *Webhook) deliver() {
+	defer close(w.done)
+	for event := range w.queue {
+		if err := w.post(event); err != nil {
+			w.logger.Log("WARN: Webhook: failed to deliver %s event: %v", event.Type, err)
+		}
+	}
+}
+
+// post sends one event
+func (w *Webhook) post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) OnMessageComplete(content string) {
+	w.Send(Event{Type: EventMessage, Content: content})
+}
+
+func (w *Webhook) OnToolCall(call agent.FunctionCall) {
+	w.Send(Event{Type: EventToolCall, Call: newCall(call)})
+}
+
+func (w *Webhook) OnToolResult(call agent.FunctionCall, res *executor.Result) {
+	w.Send(Event{Type: EventToolResult, Call: newCall(call), Output: res.Output, Success: res.Success})
+}
+
+func (w *Webhook) OnToolDenied(call agent.FunctionCall, reason string) {
+	w.Send(Event{Type: EventToolDenied, Call: newCall(call), Reason: reason})
+}
+
+func (w *Webhook) OnApprovalRequest(call agent.FunctionCall) {
+	w.Send(Event{Type: EventApprovalRequest, Call: newCall(call)})
+}
+
+func (w *Webhook) OnApproval(event agent.ApprovalEvent) {
+	w.Send(Event{Type: EventApproval, Approval: &event})
+}
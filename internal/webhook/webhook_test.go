@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/executor"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"http://localhost:8080/hook", true},
+		{"https://127.0.0.1/hook", true},
+		{"http://[::1]:9000", true},
+		{"http://example.com/hook", false},
+		{"http://10.0.0.5/hook", false},
+		{"ftp://localhost/hook", false},
+		{"localhost:8080", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("ValidateURL(%q) = %v, want ok=%t", tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode event: %v", err)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	w, err := New(server.URL, "session-1", "/repo", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	call := agent.FunctionCall{ID: "call_1", Name: "shell", Arguments: `{"command":"ls"}`}
+	w.OnToolCall(call)
+	w.OnApprovalRequest(call)
+	w.OnApproval(agent.NewApprovalEvent(call, "suggest", true, agent.DecidedByUser))
+	w.OnToolResult(call, &executor.Result{Output: "README.md\n", Success: true})
+	w.OnMessageComplete("Listed the files.")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	w.OnMessageComplete("After closing") // Dropped
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{EventToolCall, EventApprovalRequest, EventApproval, EventToolResult, EventMessage}
+	if len(received) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), received)
+	}
+	for i, event := range received {
+		if event.Type != want[i] || event.SessionID != "session-1" || event.CWD != "/repo" || event.Time.IsZero() {
+			t.Errorf("Unexpected event %d: %+v", i, event)
+		}
+	}
+	if c := received[0].Call; c == nil || c.ID != "call_1" || c.Name != "shell" || c.Arguments != call.Arguments {
+		t.Errorf("Unexpected call in tool_call event: %+v", c)
+	}
+	if received[2].Approval == nil || !received[2].Approval.Approved() {
+		t.Errorf("Expected the approval decision, got %+v", received[2].Approval)
+	}
+	if received[3].Output != "README.md\n" || !received[3].Success {
+		t.Errorf("Unexpected tool_result event: %+v", received[3])
+	}
+	if received[4].Content != "Listed the files." {
+		t.Errorf("Unexpected message event: %+v", received[4])
+	}
+}
+
+func TestWebhookRefusesRemoteURL(t *testing.T) {
+	if _, err := New("https://hooks.example.com/codex", "", "", nil); err == nil {
+		t.Error("Expected a remote webhook URL to be refused")
+	}
+}
@@ -0,0 +1,189 @@
+// Package codex embeds the codex-go coding agent in other Go programs.
+//
+// A Client holds the configuration and any extra tools; each Session is one
+// conversation with its own history:
+//
+//	client, err := codex.New(codex.Config{ApprovalMode: codex.AutoEdit})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	session, err := client.NewSession()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer session.Close()
+//
+//	result, err := session.Ask(ctx, "Add a unit test for parseArgs", nil)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(result.FinalMessage)
+//
+// Tool calls follow the approval mode. Calls that need approval are denied
+// unless AskOptions.Approve is set.
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+	"github.com/epuerta/codex-go/internal/functions"
+	"github.com/epuerta/codex-go/internal/sandbox"
+)
+
+// ApprovalMode controls which tool calls run without approval
+type ApprovalMode string
+
+const (
+	// Suggest requires approval for file edits, commands and custom tools
+	Suggest ApprovalMode = ApprovalMode(config.Suggest)
+	// AutoEdit requires approval for commands only
+	AutoEdit ApprovalMode = ApprovalMode(config.AutoEdit)
+	// FullAuto runs every call without approval, inside the sandbox
+	FullAuto ApprovalMode = ApprovalMode(config.FullAuto)
+)
+
+// builtinTools are the tool names reserved by the agent
+var builtinTools = map[string]bool{
+	"shell": true, "execute_command": true, "read_file": true, "stat_file": true,
+	"checksum_file": true, "write_file": true, "edit_lines": true, "patch_file": true, "list_directory": true,
+	"search_code": true, "summarize_workspace": true,
+}
+
+// Config configures a Client. Zero values fall back to the CLI defaults.
+type Config struct {
+	APIKey  string // Defaults to $OPENAI_API_KEY
+	Model   string // Defaults to gpt-4o
+	BaseURL string // Defaults to the OpenAI API
+
+	// WorkDir is where commands run and relative paths resolve. Defaults to the current directory.
+	WorkDir      string
+	ApprovalMode ApprovalMode // Defaults to Suggest
+	Instructions string       // Replaces the default system prompt if set
+
+	// Rate limits shared by all sessions using the same API key (0 = unlimited)
+	RateLimitRPM int
+	RateLimitTPM int
+
+	// Tools are offered to the model in addition to the built-in ones
+	Tools []Tool
+}
+
+// Tool is a function the model may call. Parameters is a JSON Schema object
+// describing the arguments, which Handler receives as a JSON string.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+	Handler     func(ctx context.Context, args string) (string, error)
+}
+
+// Client creates sessions sharing one configuration
+type Client struct {
+	config *config.Config
+	tools  []Tool
+
+	// newAgent creates the model backend for a session; replaced in tests
+	newAgent func(cfg *config.Config, tools agent.ToolSource) (agent.Agent, error)
+}
+
+// New validates cfg and creates a client
+func New(cfg Config) (*Client, error) {
+	internal := &config.Config{
+		APIKey:          cfg.APIKey,
+		Model:           cfg.Model,
+		BaseURL:         cfg.BaseURL,
+		APITimeout:      config.DefaultAPITimeout,
+		RateLimitRPM:    cfg.RateLimitRPM,
+		RateLimitTPM:    cfg.RateLimitTPM,
+		CWD:             cfg.WorkDir,
+		Instructions:    cfg.Instructions,
+		ApprovalMode:    config.ApprovalMode(cfg.ApprovalMode),
+		GuardToolOutput: true,
+		InjectionScan:   true,
+		RedactSecrets:   true,
+	}
+	if internal.APIKey == "" {
+		internal.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if internal.APIKey == "" {
+		return nil, errors.New("an API key is required (set Config.APIKey or OPENAI_API_KEY)")
+	}
+	if internal.Model == "" {
+		internal.Model = config.DefaultModel
+	}
+	if internal.BaseURL == "" {
+		internal.BaseURL = config.DefaultBaseURL
+	}
+	if internal.CWD == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get working directory: %w", err)
+		}
+		internal.CWD = wd
+	}
+	switch cfg.ApprovalMode {
+	case "":
+		internal.ApprovalMode = config.Suggest
+	case Suggest, AutoEdit, FullAuto:
+	default:
+		return nil, fmt.Errorf("unknown approval mode %q", cfg.ApprovalMode)
+	}
+
+	seen := make(map[string]bool)
+	for _, tool := range cfg.Tools {
+		switch {
+		case tool.Name == "":
+			return nil, errors.New("tool name is required")
+		case tool.Handler == nil:
+			return nil, fmt.Errorf("tool %q has no handler", tool.Name)
+		case builtinTools[tool.Name]:
+			return nil, fmt.Errorf("tool %q conflicts with a built-in tool", tool.Name)
+		case seen[tool.Name]:
+			return nil, fmt.Errorf("tool %q is defined twice", tool.Name)
+		}
+		seen[tool.Name] = true
+	}
+
+	c := &Client{config: internal, tools: append([]Tool(nil), cfg.Tools...)}
+	c.newAgent = c.openAIAgent
+	return c, nil
+}
+
+// NewSession starts a conversation
+func (c *Client) NewSession() (*Session, error) {
+	registry := functions.NewRegistry()
+	exec := executor.New(c.config, sandbox.NewSandbox(), registry, nil)
+	functions.FileFunctions{Workspace: exec.Workspace}.Register(registry)
+	functions.RegisterShell(registry)
+	for _, tool := range c.tools {
+		params := tool.Parameters
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		registry.RegisterTool(functions.Tool{Name: tool.Name, Description: tool.Description, Parameters: params, Handler: tool.Handler, Source: "client"})
+	}
+
+	ai, err := c.newAgent(c.config, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{engine: engine.New(ai, exec, c.config, nil)}, nil
+}
+
+// openAIAgent creates an OpenAI agent advertising the session's tools
+func (c *Client) openAIAgent(cfg *config.Config, tools agent.ToolSource) (agent.Agent, error) {
+	ai, err := agent.NewOpenAIAgent(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	ai.SetToolSource(tools)
+	return ai, nil
+}
@@ -0,0 +1,163 @@
+package codex
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/config"
+)
+
+// toolAgent calls the "lookup" tool once, then echoes its output
+type toolAgent struct {
+	agent.Agent
+	handler agent.ResponseHandler
+}
+
+func (a *toolAgent) emit(item agent.ResponseItem) {
+	data, _ := json.Marshal(item)
+	a.handler(string(data))
+}
+
+func (a *toolAgent) SendMessage(ctx context.Context, messages []agent.Message, handler agent.ResponseHandler) (bool, error) {
+	a.handler = handler
+	a.emit(agent.ResponseItem{Type: "function_call", FunctionCall: &agent.FunctionCall{
+		ID: "call_1", Name: "lookup", Arguments: `{"key":"answer"}`,
+	}})
+	return true, nil
+}
+
+func (a *toolAgent) SendFunctionResult(ctx context.Context, callID, functionName, output string, success bool) error {
+	a.emit(agent.ResponseItem{Type: "message", Message: &agent.Message{Role: "assistant", Content: "The answer is " + output}})
+	return nil
+}
+
+func newTestClient(t *testing.T, cfg Config) *Client {
+	t.Helper()
+	cfg.APIKey = "test"
+	cfg.WorkDir = t.TempDir()
+	cfg.Tools = []Tool{{
+		Name: "lookup",
+		Handler: func(ctx context.Context, args string) (string, error) {
+			var in struct{ Key string }
+			json.Unmarshal([]byte(args), &in)
+			return map[string]string{"answer": "42"}[in.Key], nil
+		},
+	}}
+	client, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	client.config.GuardToolOutput = false
+	client.newAgent = func(*config.Config, agent.ToolSource) (agent.Agent, error) { return &toolAgent{}, nil }
+	return client
+}
+
+func TestAsk(t *testing.T) {
+	session, err := newTestClient(t, Config{}).NewSession()
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+
+	events := make(chan Event)
+	var types []EventType
+	done := make(chan struct{})
+	go func() {
+		for ev := range events {
+			types = append(types, ev.Type)
+		}
+		close(done)
+	}()
+
+	var approved []string
+	result, err := session.Ask(context.Background(), "what is the answer?", &AskOptions{
+		Approve: func(ctx context.Context, call ToolCall) (bool, error) {
+			approved = append(approved, call.Name)
+			return true, nil
+		},
+		Events: events,
+	})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	<-done
+
+	if result.FinalMessage != "The answer is 42" || result.ToolCalls != 1 || result.ToolFailures != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(approved) != 1 || approved[0] != "lookup" {
+		t.Errorf("Expected custom tool to need approval in suggest mode, got %v", approved)
+	}
+	want := []EventType{EventToolCall, EventToolResult, EventMessage}
+	if strings.Join(eventNames(types), ",") != strings.Join(eventNames(want), ",") {
+		t.Errorf("Expected events %v, got %v", want, types)
+	}
+
+	// Without an approver the call is denied
+	result, err = session.Ask(context.Background(), "again", nil)
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if result.Denied != 1 || !strings.Contains(result.FinalMessage, "denied") {
+		t.Errorf("Expected denied call, got %+v", result)
+	}
+	if len(result.Approvals) != 1 || result.Approvals[0].Approved || result.Approvals[0].DecidedBy != "policy" || result.Approvals[0].Tool != "lookup" {
+		t.Errorf("Expected the denial to be recorded, got %+v", result.Approvals)
+	}
+}
+
+func TestSessionTools(t *testing.T) {
+	client := newTestClient(t, Config{})
+	var offered []string
+	client.newAgent = func(_ *config.Config, tools agent.ToolSource) (agent.Agent, error) {
+		for _, def := range tools.ToolDefinitions() {
+			offered = append(offered, def.Function.Name)
+		}
+		return &toolAgent{}, nil
+	}
+	if _, err := client.NewSession(); err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+
+	// Every reserved name is offered, except the legacy alias of shell
+	want := map[string]bool{"lookup": true}
+	for name := range builtinTools {
+		want[name] = name != "execute_command"
+	}
+	for _, name := range offered {
+		if !want[name] {
+			t.Errorf("Unexpected tool %q offered", name)
+		}
+		delete(want, name)
+	}
+	for name, expected := range want {
+		if expected {
+			t.Errorf("Expected tool %q to be offered, got %v", name, offered)
+		}
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	handler := func(context.Context, string) (string, error) { return "", nil }
+	tests := []Config{
+		{APIKey: "k", ApprovalMode: "yolo"},
+		{APIKey: "k", Tools: []Tool{{Name: "shell", Handler: handler}}},
+		{APIKey: "k", Tools: []Tool{{Name: "x", Handler: handler}, {Name: "x", Handler: handler}}},
+		{APIKey: "k", Tools: []Tool{{Name: "x"}}},
+	}
+	for i, cfg := range tests {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("Case %d: expected an error", i)
+		}
+	}
+}
+
+func eventNames(types []EventType) []string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return names
+}
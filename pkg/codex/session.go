@@ -0,0 +1,200 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/epuerta/codex-go/internal/agent"
+	"github.com/epuerta/codex-go/internal/engine"
+	"github.com/epuerta/codex-go/internal/executor"
+)
+
+// ErrSessionBusy is returned by Ask while another Ask is running on the session
+var ErrSessionBusy = errors.New("session is busy")
+
+// EventType identifies an Event
+type EventType string
+
+const (
+	// EventMessage carries the assistant message streamed so far
+	EventMessage EventType = "message"
+	// EventToolCall is sent before a tool call is approved and run
+	EventToolCall EventType = "tool_call"
+	// EventToolResult is sent after a tool call has run
+	EventToolResult EventType = "tool_result"
+	// EventToolDenied is sent when a tool call was not approved
+	EventToolDenied EventType = "tool_denied"
+)
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string // JSON-encoded arguments
+}
+
+// Event reports progress during Ask
+type Event struct {
+	Type    EventType
+	Message string   // EventMessage: the full message so far
+	Call    ToolCall // Tool events: the call concerned
+	Output  string   // EventToolResult: output passed back to the model
+	Success bool     // EventToolResult: whether the call succeeded
+	Reason  string   // EventToolDenied: why the call was denied
+}
+
+// AskOptions customizes a single Ask. A nil *AskOptions is valid.
+type AskOptions struct {
+	// Approve decides calls the approval mode does not allow on its own.
+	// If nil, those calls are denied.
+	Approve func(ctx context.Context, call ToolCall) (bool, error)
+
+	// Events, if set, receives progress events. Sends block, so the channel
+	// must be drained; Ask closes it before returning.
+	Events chan<- Event
+}
+
+// Result summarizes a completed Ask
+type Result struct {
+	FinalMessage string // The last assistant message
+	ToolCalls    int    // Tool calls requested by the model
+	ToolFailures int    // Tool calls that failed or were denied
+	Denied       int    // Tool calls that were denied
+
+	// Approvals records the decision on every tool call, in order
+	Approvals []Approval
+}
+
+// Approval records whether a tool call was allowed to run
+type Approval struct {
+	CallID    string
+	Tool      string
+	ArgsHash  string // SHA-256 of the JSON arguments, as "sha256:<hex>"
+	Approved  bool
+	DecidedBy string // "user" for AskOptions.Approve, "policy" for the approval mode
+	Mode      string // The approval mode in effect
+	Reason    string
+	Time      time.Time
+}
+
+// Message is an entry in the conversation history
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Session is one conversation with the agent. Asks on a session run one at a
+// time and share history.
+type Session struct {
+	engine *engine.Engine
+
+	mu      sync.Mutex
+	running bool
+}
+
+// Ask sends prompt and runs the agent, including any tool calls, until it
+// replies without requesting more. Cancel ctx to stop it.
+func (s *Session) Ask(ctx context.Context, prompt string, opts *AskOptions) (*Result, error) {
+	if opts == nil {
+		opts = &AskOptions{}
+	}
+	if opts.Events != nil {
+		defer close(opts.Events)
+	}
+
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, ErrSessionBusy
+	}
+	s.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	var approver engine.Approver
+	if opts.Approve != nil {
+		approver = engine.ApproverFunc(func(ctx context.Context, call agent.FunctionCall) (bool, error) {
+			return opts.Approve(ctx, toolCall(call))
+		})
+	}
+
+	outcome, err := s.engine.Run(ctx, prompt, approver, &eventNotifier{ctx: ctx, events: opts.Events})
+	if outcome == nil {
+		return nil, err
+	}
+	result := &Result{
+		FinalMessage: outcome.FinalMessage,
+		ToolCalls:    outcome.ToolCalls,
+		ToolFailures: outcome.ToolFailures,
+		Denied:       outcome.Denied,
+	}
+	for _, ev := range outcome.Approvals {
+		result.Approvals = append(result.Approvals, Approval{
+			CallID:    ev.CallID,
+			Tool:      ev.Tool,
+			ArgsHash:  ev.ArgsHash,
+			Approved:  ev.Approved(),
+			DecidedBy: ev.DecidedBy,
+			Mode:      ev.Mode,
+			Reason:    ev.Reason,
+			Time:      ev.Timestamp,
+		})
+	}
+	return result, err
+}
+
+// History returns the conversation so far, including the system prompt and tool results
+func (s *Session) History() []Message {
+	var messages []Message
+	for _, msg := range s.engine.Agent.GetHistory().GetMessages() {
+		messages = append(messages, Message{Role: msg.Role, Content: msg.Content})
+	}
+	return messages
+}
+
+// Close cancels any running Ask and releases the session
+func (s *Session) Close() error {
+	return s.engine.Agent.Close()
+}
+
+// eventNotifier forwards engine callbacks to an Events channel
+type eventNotifier struct {
+	ctx    context.Context
+	events chan<- Event
+}
+
+func (n *eventNotifier) send(ev Event) {
+	if n.events == nil {
+		return
+	}
+	select {
+	case n.events <- ev:
+	case <-n.ctx.Done():
+	}
+}
+
+func (n *eventNotifier) OnMessage(content string) {
+	n.send(Event{Type: EventMessage, Message: content})
+}
+
+func (n *eventNotifier) OnToolCall(call agent.FunctionCall) {
+	n.send(Event{Type: EventToolCall, Call: toolCall(call)})
+}
+
+func (n *eventNotifier) OnToolResult(call agent.FunctionCall, res *executor.Result) {
+	n.send(Event{Type: EventToolResult, Call: toolCall(call), Output: res.Output, Success: res.Success})
+}
+
+func (n *eventNotifier) OnToolDenied(call agent.FunctionCall, reason string) {
+	n.send(Event{Type: EventToolDenied, Call: toolCall(call), Reason: reason})
+}
+
+func toolCall(call agent.FunctionCall) ToolCall {
+	return ToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments}
+}